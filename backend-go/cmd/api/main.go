@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/phil-my-meds/backend-gogit/internal/config"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
 )
 
 func main() {
@@ -19,6 +20,9 @@ func main() {
 	cfg := config.Load()
 	log.Printf("📋 Configuration loaded (Environment: %s)", cfg.AppEnv)
 
+	// Events published outside a handler are recorded as produced by this service
+	events.SetDefaultProducer("api")
+
 	// Initialize server with all dependencies
 	server, err := InitializeServer(cfg)
 	if err != nil {
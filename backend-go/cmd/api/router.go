@@ -2,10 +2,16 @@
 package main
 
 import (
+	"context"
+	"time"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
 	"github.com/phil-my-meds/backend-gogit/internal/handlers"
 	appMiddleware "github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
 )
 
 // setupRouter configures and returns the HTTP router
@@ -28,6 +34,9 @@ func (s *Server) setupRouter() *chi.Mux {
 	// 5. Panic recovery - recover from panics
 	r.Use(appMiddleware.RecoveryMiddleware)
 
+	// 6. Audit - record PHI access and changes with the client's IP address and user agent
+	r.Use(appMiddleware.AuditMiddleware(s.Audit))
+
 	// Create handler dependencies with all injected services
 	deps := handlers.NewDependencies(
 		s.MongoClient,
@@ -45,7 +54,130 @@ func (s *Server) setupRouter() *chi.Mux {
 		// Prescription routes
 		prescriptionHandler := handlers.NewPrescriptionHandler(deps)
 		r.Post("/prescriptions/intake", prescriptionHandler.Intake)
+
+		// Payment provider webhooks (authenticated by signature)
+		paymentWebhookHandler := handlers.NewPaymentWebhookHandler(deps, s.Config.PaymentWebhookSecret)
+		r.Post("/webhooks/payments", paymentWebhookHandler.Receive)
+
+		// Carrier tracking webhooks (authenticated by signature)
+		carrierWebhookHandler := handlers.NewCarrierWebhookHandler(deps, services.NewShipmentTrackingService(s.MongoClient, s.KafkaProducer), s.Config.CarrierWebhookSecret)
+		r.Post("/webhooks/carriers", carrierWebhookHandler.Receive)
+
+		// SMS provider webhooks (authenticated by signature); the API only records receipts and opt-outs,
+		// so its notification service has no mailer or SMS sender and fallbacks are sent by the scheduler
+		communicationPreferences := services.NewCommunicationPreferenceService(s.MongoClient)
+		receiptNotifications := services.NewNotificationService(s.MongoClient, nil, nil, communicationPreferences, services.NotificationConfig{})
+		smsWebhookHandler := handlers.NewSMSWebhookHandler(deps, receiptNotifications, communicationPreferences,
+			s.Config.TwilioAuthToken, s.Config.SMSWebhookBaseURL, s.Config.NotificationSupport)
+		r.Post("/webhooks/sms/status", smsWebhookHandler.Status)
+		r.Post("/webhooks/sms/inbound", smsWebhookHandler.Inbound)
+
+		// Partner pharmacy routes (authenticated by API key)
+		r.Group(func(r chi.Router) {
+			r.Use(appMiddleware.PharmacyAuthMiddleware(pharmacyAuthenticator(services.NewPharmacyAuthService(s.MongoClient))))
+
+			// Manufacturer copay program lookup (used by pharmacies for secondary claims)
+			programHandler := handlers.NewProgramHandler(deps, services.NewManufacturerProgramService(s.MongoClient, s.Redis))
+			r.Get("/programs/lookup", programHandler.Lookup)
+
+			// Adjudication results reported back by the dispensing pharmacy
+			adjudicationHandler := handlers.NewAdjudicationHandler(deps)
+			r.Post("/adjudication/results", adjudicationHandler.SubmitResults)
+		})
+
+		// Authenticated ops routes
+		r.Group(func(r chi.Router) {
+			r.Use(appMiddleware.AuthMiddleware(s.Config.JWTSecret))
+			r.Use(appMiddleware.RequireRole(appMiddleware.OpsRoles...))
+
+			routingPolicies := services.NewRoutingPolicyService(s.MongoClient, models.RoutingMode(s.Config.RoutingMode), s.Config.RoutingTopN)
+			pharmacySelectionHandler := handlers.NewPharmacySelectionHandler(deps, routingPolicies)
+			r.Post("/prescriptions/{id}/pharmacy", pharmacySelectionHandler.SelectPharmacy)
+
+			priorAuthHandler := handlers.NewPriorAuthHandler(deps, services.NewPriorAuthService(s.MongoClient))
+			r.Post("/prior-authorizations", priorAuthHandler.Create)
+			r.Get("/prior-authorizations", priorAuthHandler.List)
+			r.Get("/prior-authorizations/{id}", priorAuthHandler.Get)
+			r.Post("/prior-authorizations/{id}/status", priorAuthHandler.UpdateStatus)
+
+			// Refunds are requested by any ops user and approved by a second, more senior one
+			refundHandler := handlers.NewRefundHandler(deps, services.NewRefundService(s.MongoClient, s.PaymentProvider))
+			r.Post("/payments/{id}/refunds", refundHandler.Create)
+			r.Get("/payments/{id}/refunds", refundHandler.ListForPayment)
+			r.Get("/refunds", refundHandler.List)
+			r.Get("/refunds/{id}", refundHandler.Get)
+			r.With(appMiddleware.RequireRole(appMiddleware.RoleAdmin, appMiddleware.RoleOpsManager)).Post("/refunds/{id}/approve", refundHandler.Approve)
+			r.With(appMiddleware.RequireRole(appMiddleware.RoleAdmin, appMiddleware.RoleOpsManager)).Post("/refunds/{id}/reject", refundHandler.Reject)
+
+			// Shipping documents, and cold chain excursion reports that flag the shipment and trigger a replacement
+			shipmentDocuments := services.NewShipmentDocumentService(s.MongoClient, s.MinIO, s.Config.ShippingDocumentsBucket, time.Duration(s.Config.DocumentURLTTLMinutes)*time.Minute)
+			shipmentHandler := handlers.NewShipmentHandler(deps, services.NewShipmentService(s.MongoClient), shipmentDocuments)
+			r.Get("/shipments/{id}", shipmentHandler.Get)
+			r.Get("/shipments/{id}/documents", shipmentHandler.Documents)
+			r.Post("/shipments/{id}/temperature-excursions", shipmentHandler.ReportExcursion)
+
+			// Everything that happened under an intake request's correlation ID, as one timeline
+			correlationHandler := handlers.NewCorrelationHandler(deps, services.NewCorrelationService(s.MongoClient))
+			r.Get("/correlations/{id}", correlationHandler.Get)
+
+			// Each prescription's progress through the workers, step deadlines and compensations of failed steps
+			sagaHandler := handlers.NewSagaHandler(deps, services.NewSagaService(s.MongoClient, services.SagaConfig{}))
+			r.Get("/prescriptions/{id}/saga", sagaHandler.Get)
+			r.Get("/sagas", sagaHandler.List)
+
+			// Patient communication preferences and consent; changes are recorded as made by ops
+			communicationPreferencesHandler := handlers.NewCommunicationPreferencesHandler(deps, communicationPreferences)
+			r.Get("/patients/{id}/communication-preferences", communicationPreferencesHandler.Get)
+			r.Put("/patients/{id}/communication-preferences", communicationPreferencesHandler.Update)
+		})
+
+		// Pharmacist queues: drug utilization review with the allergies it checks, and clinical verification
+		r.Group(func(r chi.Router) {
+			r.Use(appMiddleware.AuthMiddleware(s.Config.JWTSecret))
+			r.Use(appMiddleware.RequireRole(appMiddleware.ClinicalRoles...))
+
+			// Only licensed pharmacists decide reviews; pharmacists and admins record allergies
+			durHandler := handlers.NewDURHandler(deps, services.NewDURService(s.MongoClient))
+			r.Get("/dur-reviews", durHandler.ListReviews)
+			r.Get("/dur-reviews/{id}", durHandler.GetReview)
+			r.With(appMiddleware.RequireLicensedPharmacist()).Post("/dur-reviews/{id}/decision", durHandler.DecideReview)
+			r.Get("/patients/{id}/allergies", durHandler.GetAllergies)
+			r.With(appMiddleware.RequireRole(appMiddleware.RolePharmacist, appMiddleware.RoleAdmin)).Put("/patients/{id}/allergies", durHandler.UpdateAllergies)
+
+			// Clinical verification of paid prescriptions; only licensed pharmacists decide
+			verificationHandler := handlers.NewVerificationHandler(deps, services.NewVerificationService(s.MongoClient))
+			r.Get("/verifications", verificationHandler.List)
+			r.Get("/verifications/{id}", verificationHandler.Get)
+			r.With(appMiddleware.RequireLicensedPharmacist()).Post("/verifications/{id}/decision", verificationHandler.Decide)
+		})
+
+		// Audit log search for compliance staff
+		r.Group(func(r chi.Router) {
+			r.Use(appMiddleware.AuthMiddleware(s.Config.JWTSecret))
+			r.Use(appMiddleware.RequireRole(appMiddleware.RoleAdmin, appMiddleware.RoleCompliance))
+
+			auditLogHandler := handlers.NewAuditLogHandler(deps, audit.NewPostgresStore(s.Postgres.DB))
+			r.Get("/audit", auditLogHandler.List)
+		})
 	})
 
 	return r
 }
+
+// pharmacyAuthenticator adapts the pharmacy auth service to the API key middleware
+func pharmacyAuthenticator(auth *services.PharmacyAuthService) appMiddleware.PharmacyAuthenticator {
+	return func(ctx context.Context, apiKey string) (*appMiddleware.AuthPharmacy, error) {
+		pharmacy, err := auth.Authenticate(ctx, apiKey)
+		if err != nil {
+			return nil, err
+		}
+		if pharmacy == nil {
+			return nil, appMiddleware.ErrInvalidAPIKey
+		}
+		return &appMiddleware.AuthPharmacy{
+			ID:      pharmacy.ID.Hex(),
+			NCPDPID: pharmacy.NCPDPID,
+			Name:    pharmacy.Name,
+		}, nil
+	}
+}
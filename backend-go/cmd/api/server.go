@@ -8,19 +8,25 @@ import (
 	"strings"
 	"time"
 
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
 	"github.com/phil-my-meds/backend-gogit/internal/config"
 	"github.com/phil-my-meds/backend-gogit/internal/database"
 	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/payments"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
 )
 
 // Server holds all the dependencies for the API server
 type Server struct {
-	Config        *config.Config
-	MongoClient   *database.MongoClient
-	Postgres      *database.PostgresClient
-	Redis         *database.RedisClient
-	KafkaProducer kafka.Producer
-	Router        *http.Server
+	Config          *config.Config
+	MongoClient     *database.MongoClient
+	Postgres        *database.PostgresClient
+	Redis           *database.RedisClient
+	KafkaProducer   kafka.Producer
+	PaymentProvider payments.PaymentProvider
+	MinIO           *services.MinIOService
+	Audit           *audit.Logger
+	Router          *http.Server
 }
 
 // InitializeServer sets up all database connections and returns a configured server
@@ -65,6 +71,14 @@ func InitializeServer(cfg *config.Config) (*Server, error) {
 		log.Println("✅ PostgreSQL migrations completed successfully")
 	}
 
+	// Audit trail (audit_logs)
+	server.Audit = audit.NewLogger(audit.NewPostgresStore(pgClient.DB), audit.Config{
+		BufferSize:    cfg.AuditBufferSize,
+		BatchSize:     cfg.AuditBatchSize,
+		FlushInterval: time.Duration(cfg.AuditFlushIntervalMS) * time.Millisecond,
+		WriteTimeout:  time.Duration(cfg.AuditWriteTimeoutSeconds) * time.Second,
+	})
+
 	// Connect to Redis
 	log.Println("🔌 Connecting to Redis...")
 	redisClient, err := database.ConnectRedis(cfg.RedisURL)
@@ -85,6 +99,20 @@ func InitializeServer(cfg *config.Config) (*Server, error) {
 	server.KafkaProducer = kafkaProducer
 	log.Println("✅ Kafka producer initialized successfully")
 
+	// Payment provider (refunds are issued from the ops API)
+	paymentProvider, err := payments.NewProvider(cfg.PaymentProvider, cfg.StripeAPIBase, cfg.StripeSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	server.PaymentProvider = paymentProvider
+
+	// Object storage (shipping documents)
+	minioService, err := services.NewMinIOService(cfg.MinIOEndpoint, cfg.MinIOAccessKey, cfg.MinIOSecretKey, cfg.MinIOUseSSL)
+	if err != nil {
+		return nil, err
+	}
+	server.MinIO = minioService
+
 	// Setup router
 	log.Println("🔧 Setting up router...")
 	router := server.setupRouter()
@@ -117,6 +145,13 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		}
 	}
 
+	// Write queued audit entries before the PostgreSQL connection closes
+	if s.Audit != nil {
+		if err := s.Audit.Close(ctx); err != nil {
+			log.Printf("⚠️  Error flushing audit log: %v", err)
+		}
+	}
+
 	if s.MongoClient != nil {
 		if err := s.MongoClient.Disconnect(ctx); err != nil {
 			log.Printf("⚠️  Error disconnecting MongoDB: %v", err)
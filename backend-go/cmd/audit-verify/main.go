@@ -0,0 +1,155 @@
+// Package main is the audit log verification command.
+// It recomputes the hash chains of audit_logs and reports the first broken link of each chain,
+// checking chain heads against the signed checkpoints the scheduler anchors in MinIO.
+//
+// Usage:
+//
+//	audit-verify -from 2024-01-01 -to 2024-01-31 -public-key <hex>
+//
+// It exits with status 1 when any chain or checkpoint does not verify.
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/config"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+)
+
+func main() {
+	cfg := config.Load()
+
+	today := time.Now().UTC().Format("2006-01-02")
+	fromFlag := flag.String("from", today, "first chain (UTC day, YYYY-MM-DD) to verify")
+	toFlag := flag.String("to", today, "last chain (UTC day, YYYY-MM-DD) to verify")
+	publicKeyFlag := flag.String("public-key", "", "checkpoint public key (hex); derived from AUDIT_CHECKPOINT_KEY when empty")
+	useCheckpoints := flag.Bool("checkpoints", true, "check chains against the signed checkpoints in MinIO")
+	flag.Parse()
+
+	from, err := time.Parse("2006-01-02", *fromFlag)
+	if err != nil {
+		log.Fatalf("❌ Invalid -from: %v", err)
+	}
+	to, err := time.Parse("2006-01-02", *toFlag)
+	if err != nil || to.Before(from) {
+		log.Fatalf("❌ Invalid -to: must be a day on or after -from")
+	}
+
+	publicKey := audit.NewCheckpointSigner(cfg.AuditCheckpointKey).PublicKey()
+	if *publicKeyFlag != "" {
+		key, err := hex.DecodeString(*publicKeyFlag)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			log.Fatalf("❌ Invalid -public-key: expected %d bytes in hex", ed25519.PublicKeySize)
+		}
+		publicKey = key
+	} else if *useCheckpoints {
+		log.Println("⚠️  No -public-key given, using the key derived from AUDIT_CHECKPOINT_KEY")
+	}
+
+	ctx := context.Background()
+
+	pgClient, err := database.ConnectPostgres(cfg.PostgresDSN)
+	if err != nil {
+		log.Fatalf("❌ Failed to connect to PostgreSQL: %v", err)
+	}
+	defer pgClient.Close()
+	store := audit.NewPostgresStore(pgClient.DB)
+
+	problems := 0
+
+	// Heads recorded by valid checkpoints, per chain
+	anchors := map[string][]audit.ChainHead{}
+	anchorSources := map[audit.ChainHead]string{}
+	if *useCheckpoints {
+		minioService, err := services.NewMinIOService(cfg.MinIOEndpoint, cfg.MinIOAccessKey, cfg.MinIOSecretKey, cfg.MinIOUseSSL)
+		if err != nil {
+			log.Fatalf("❌ Failed to create MinIO client: %v", err)
+		}
+		checkpoints := services.NewAuditCheckpointService(store, minioService, cfg.AuditCheckpointBucket, nil)
+		files, err := checkpoints.Checkpoints(ctx, publicKey, from, to)
+		if err != nil {
+			log.Fatalf("❌ Failed to read checkpoints: %v", err)
+		}
+		for _, file := range files {
+			if file.Err != nil {
+				log.Printf("❌ Checkpoint %s: %v", file.Object, file.Err)
+				problems++
+				continue
+			}
+			for _, head := range file.Checkpoint.Heads {
+				anchors[head.ChainID] = append(anchors[head.ChainID], head)
+				anchorSources[head] = file.Object
+			}
+		}
+		log.Printf("🔏 Read %d checkpoints signed with key %s", len(files), audit.KeyID(publicKey))
+	}
+
+	chainIDs, err := store.Chains(ctx, audit.ChainID(from), audit.ChainID(to))
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	stored := map[string]bool{}
+	for _, chainID := range chainIDs {
+		stored[chainID] = true
+	}
+	for chainID, heads := range anchors {
+		if !stored[chainID] && chainID >= audit.ChainID(from) && chainID <= audit.ChainID(to) {
+			log.Printf("❌ Chain %s is missing but checkpoint %s anchored it at seq %d", chainID, anchorSources[heads[0]], heads[0].Seq)
+			problems++
+		}
+	}
+
+	for _, chainID := range chainIDs {
+		verifier := audit.NewChainVerifier(chainID)
+		for _, head := range anchors[chainID] {
+			verifier.Anchor(head.Seq, head.Hash, anchorSources[head])
+		}
+
+		err := store.ReadChain(ctx, chainID, func(row audit.ChainRow) error {
+			if broken := verifier.Check(row); broken != nil {
+				return broken
+			}
+			return nil
+		})
+		if err == nil {
+			if broken := verifier.Finish(); broken != nil {
+				err = broken
+			}
+		}
+
+		var broken *audit.ChainBreak
+		switch {
+		case errors.As(err, &broken):
+			log.Printf("❌ %v", broken)
+			problems++
+		case err != nil:
+			log.Fatalf("❌ %v", err)
+		default:
+			head := verifier.Head()
+			log.Printf("✅ Chain %s verified: %d rows, %d checkpoint anchors, head %s", chainID, head.Seq, len(anchors[chainID]), head.Hash)
+		}
+	}
+
+	unchained, err := store.UnchainedRows(ctx)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if unchained > 0 {
+		log.Printf("⚠️  %d audit rows were written before hash chaining and cannot be verified", unchained)
+	}
+
+	if problems > 0 {
+		log.Printf("❌ Audit log verification failed: %d problems in %d chains from %s to %s", problems, len(chainIDs), *fromFlag, *toFlag)
+		os.Exit(1)
+	}
+	log.Printf("✅ Audit log verified: %d chains from %s to %s", len(chainIDs), *fromFlag, *toFlag)
+}
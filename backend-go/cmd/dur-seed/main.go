@@ -0,0 +1,114 @@
+// Package main provides a seed script that loads drug utilization review reference tables from CSV
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/config"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+)
+
+func main() {
+	interactionsFile := flag.String("interactions", "", "path to drug interactions CSV (default: scripts/seeds/dur/interactions.csv)")
+	duplicationsFile := flag.String("duplications", "", "path to therapeutic duplications CSV (default: scripts/seeds/dur/duplications.csv)")
+	allergiesFile := flag.String("allergies", "", "path to allergy rules CSV (default: scripts/seeds/dur/allergy_rules.csv)")
+	flag.Parse()
+
+	log.Println("🌱 Starting drug utilization review seed script...")
+
+	if *interactionsFile == "" || *duplicationsFile == "" || *allergiesFile == "" {
+		seedDir := findSeedDir()
+		if *interactionsFile == "" {
+			*interactionsFile = filepath.Join(seedDir, "interactions.csv")
+		}
+		if *duplicationsFile == "" {
+			*duplicationsFile = filepath.Join(seedDir, "duplications.csv")
+		}
+		if *allergiesFile == "" {
+			*allergiesFile = filepath.Join(seedDir, "allergy_rules.csv")
+		}
+	}
+
+	var reference services.DURReference
+
+	log.Printf("📦 Loading drug interactions from %s", *interactionsFile)
+	file, err := os.Open(*interactionsFile)
+	if err != nil {
+		log.Fatalf("Failed to open interactions CSV: %v", err)
+	}
+	reference.Interactions, err = services.ParseDURInteractionsCSV(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to parse interactions CSV: %v", err)
+	}
+
+	log.Printf("📦 Loading therapeutic duplications from %s", *duplicationsFile)
+	file, err = os.Open(*duplicationsFile)
+	if err != nil {
+		log.Fatalf("Failed to open duplications CSV: %v", err)
+	}
+	reference.Duplications, err = services.ParseDURDuplicationsCSV(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to parse duplications CSV: %v", err)
+	}
+
+	log.Printf("📦 Loading allergy rules from %s", *allergiesFile)
+	file, err = os.Open(*allergiesFile)
+	if err != nil {
+		log.Fatalf("Failed to open allergy rules CSV: %v", err)
+	}
+	reference.AllergyRules, err = services.ParseDURAllergyRulesCSV(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to parse allergy rules CSV: %v", err)
+	}
+
+	cfg := config.Load()
+
+	mongoClient, err := database.ConnectMongo(cfg.MongoDBURI, "phil-my-meds")
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	defer mongoClient.Disconnect(ctx)
+
+	if err := mongoClient.CreateIndexes(ctx); err != nil {
+		log.Fatalf("Failed to create indexes: %v", err)
+	}
+
+	if err := services.NewDURService(mongoClient).UpsertReference(ctx, reference); err != nil {
+		log.Fatalf("Failed to seed DUR reference tables: %v", err)
+	}
+	log.Printf("✅ Upserted %d interactions, %d duplications and %d allergy rules",
+		len(reference.Interactions), len(reference.Duplications), len(reference.AllergyRules))
+
+	log.Println("✅ DUR seeding completed successfully!")
+}
+
+// findSeedDir locates scripts/seeds/dur relative to the common working directories
+func findSeedDir() string {
+	seedDirs := []string{
+		"../../scripts/seeds/dur", // From backend-go/cmd/dur-seed
+		"../scripts/seeds/dur",    // From backend-go
+		"scripts/seeds/dur",       // From project root
+		filepath.Join(filepath.Dir(os.Args[0]), "../../../scripts/seeds/dur"), // From compiled binary
+	}
+
+	for _, dir := range seedDirs {
+		if _, err := os.Stat(filepath.Join(dir, "interactions.csv")); err == nil {
+			return dir
+		}
+	}
+
+	log.Fatalf("Could not find seed data directory. Tried: %v\nPlease run from project root or pass -interactions, -duplications and -allergies", seedDirs)
+	return ""
+}
@@ -86,6 +86,22 @@ func main() {
 		log.Fatalf("Failed to seed patients: %v", err)
 	}
 
+	if err := seedCollection(ctx, db, "drugs", filepath.Join(seedDir, "drugs.json")); err != nil {
+		log.Fatalf("Failed to seed drugs: %v", err)
+	}
+
+	if err := seedCollection(ctx, db, "routing_policies", filepath.Join(seedDir, "routing_policies.json")); err != nil {
+		log.Fatalf("Failed to seed routing policies: %v", err)
+	}
+
+	if err := seedCollection(ctx, db, "plan_rules", filepath.Join(seedDir, "plan_rules.json")); err != nil {
+		log.Fatalf("Failed to seed plan rules: %v", err)
+	}
+
+	if err := seedCollection(ctx, db, "manufacturer_programs", filepath.Join(seedDir, "manufacturer_programs.json")); err != nil {
+		log.Fatalf("Failed to seed manufacturer programs: %v", err)
+	}
+
 	log.Println("✅ MongoDB seeding completed successfully!")
 }
 
@@ -0,0 +1,101 @@
+// Package main provides a seed script that loads pharmacy network contracts and plan formularies from CSV
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/config"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+)
+
+func main() {
+	contractsFile := flag.String("contracts", "", "path to pharmacy contracts CSV (default: scripts/seeds/network/pharmacy_contracts.csv)")
+	formularyFile := flag.String("formulary", "", "path to formulary CSV (default: scripts/seeds/network/formularies.csv)")
+	flag.Parse()
+
+	log.Println("🌱 Starting network contract and formulary seed script...")
+
+	if *contractsFile == "" || *formularyFile == "" {
+		seedDir := findSeedDir()
+		if *contractsFile == "" {
+			*contractsFile = filepath.Join(seedDir, "pharmacy_contracts.csv")
+		}
+		if *formularyFile == "" {
+			*formularyFile = filepath.Join(seedDir, "formularies.csv")
+		}
+	}
+
+	cfg := config.Load()
+
+	mongoClient, err := database.ConnectMongo(cfg.MongoDBURI, "phil-my-meds")
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	defer mongoClient.Disconnect(ctx)
+
+	if err := mongoClient.CreateIndexes(ctx); err != nil {
+		log.Fatalf("Failed to create indexes: %v", err)
+	}
+
+	// Pharmacy–payer contracts
+	log.Printf("📦 Loading pharmacy contracts from %s", *contractsFile)
+	file, err := os.Open(*contractsFile)
+	if err != nil {
+		log.Fatalf("Failed to open contracts CSV: %v", err)
+	}
+	contracts, err := services.ParseContractsCSV(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to parse contracts CSV: %v", err)
+	}
+	if err := services.NewNetworkContractService(mongoClient).Upsert(ctx, contracts); err != nil {
+		log.Fatalf("Failed to seed pharmacy contracts: %v", err)
+	}
+	log.Printf("✅ Upserted %d pharmacy contracts", len(contracts))
+
+	// Plan formularies
+	log.Printf("📦 Loading formulary from %s", *formularyFile)
+	file, err = os.Open(*formularyFile)
+	if err != nil {
+		log.Fatalf("Failed to open formulary CSV: %v", err)
+	}
+	entries, err := services.ParseFormularyCSV(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to parse formulary CSV: %v", err)
+	}
+	if err := services.NewFormularyService(mongoClient).Upsert(ctx, entries); err != nil {
+		log.Fatalf("Failed to seed formularies: %v", err)
+	}
+	log.Printf("✅ Upserted %d formulary entries", len(entries))
+
+	log.Println("✅ Network seeding completed successfully!")
+}
+
+// findSeedDir locates scripts/seeds/network relative to the common working directories
+func findSeedDir() string {
+	seedDirs := []string{
+		"../../scripts/seeds/network", // From backend-go/cmd/network-seed
+		"../scripts/seeds/network",    // From backend-go
+		"scripts/seeds/network",       // From project root
+		filepath.Join(filepath.Dir(os.Args[0]), "../../../scripts/seeds/network"), // From compiled binary
+	}
+
+	for _, dir := range seedDirs {
+		if _, err := os.Stat(filepath.Join(dir, "pharmacy_contracts.csv")); err == nil {
+			return dir
+		}
+	}
+
+	log.Fatalf("Could not find seed data directory. Tried: %v\nPlease run from project root or pass -contracts and -formulary", seedDirs)
+	return ""
+}
@@ -6,6 +6,11 @@
 // PostgreSQL job queues have been removed - see migration 000_drop_job_queue_tables.sql
 //
 // This scheduler is for other periodic tasks such as:
+// - Shipment tracking polls (every minute, per TRACKING_POLL_INTERVAL_MINUTES per shipment)
+// - Notification email retries (every minute, with exponential backoff per email)
+// - Audit chain checkpoints (hourly, per AUDIT_CHECKPOINT_INTERVAL_MINUTES)
+// - Audit log partitions ahead of time, and archiving of expired ones to MinIO (daily)
+// - Fulfillment saga step timeouts and compensation retries (every minute)
 // - Prescription expiry checks (daily)
 // - Enrollment reminder emails (hourly)
 // - Report generation (weekly)
@@ -13,11 +18,37 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/config"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/notifications"
+	"github.com/phil-my-meds/backend-gogit/internal/payments"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"github.com/phil-my-meds/backend-gogit/internal/shipping"
+	"github.com/phil-my-meds/backend-gogit/internal/sms"
+	"github.com/phil-my-meds/backend-gogit/internal/workers"
+)
+
+const (
+	// trackingPollBatch bounds the shipments tracked per tick, so a backlog is spread over several ticks
+	trackingPollBatch = 100
+	// notificationRetryBatch bounds the emails retried per tick
+	notificationRetryBatch = 50
+	// sagaSweepBatch bounds the sagas timed out, and those with compensations retried, per tick
+	sagaSweepBatch = 50
+	// decisionResumeBatch bounds the pharmacist decisions whose follow-up is resumed per tick
+	decisionResumeBatch = 50
+	// decisionResumeAfter is how long a decision's own request has to move its prescription on
+	decisionResumeAfter = 2 * time.Minute
 )
 
 func main() {
@@ -25,11 +56,93 @@ func main() {
 	log.Println("ℹ️  Note: This scheduler is for maintenance tasks, NOT job queue polling")
 	log.Println("ℹ️  All worker processing uses Kafka event-driven architecture")
 
-	// TODO: Load configuration from environment
-	// config := config.Load()
+	// Load configuration
+	cfg := config.Load()
+	log.Printf("📋 Configuration loaded (Environment: %s)", cfg.AppEnv)
 
-	// TODO: Initialize database connections
-	// db := database.Connect(config)
+	// Events published outside a handler are recorded as produced by this service
+	events.SetDefaultProducer("scheduler")
+
+	// Connect to MongoDB
+	log.Println("🔌 Connecting to MongoDB...")
+	mongoClient, err := database.ConnectMongo(cfg.MongoDBURI, "phil-my-meds")
+	if err != nil {
+		log.Fatalf("❌ Failed to connect to MongoDB: %v", err)
+	}
+	log.Println("✅ MongoDB connected successfully")
+
+	// Connect to Redis (pharmacy capacity released by saga compensations)
+	log.Println("🔌 Connecting to Redis...")
+	redisClient, err := database.ConnectRedis(cfg.RedisURL)
+	if err != nil {
+		log.Fatalf("❌ Failed to connect to Redis: %v", err)
+	}
+	log.Println("✅ Redis connected successfully")
+
+	// Connect to PostgreSQL (audit logs)
+	log.Println("🔌 Connecting to PostgreSQL...")
+	pgClient, err := database.ConnectPostgres(cfg.PostgresDSN)
+	if err != nil {
+		log.Fatalf("❌ Failed to connect to PostgreSQL: %v", err)
+	}
+	log.Println("✅ PostgreSQL connected successfully")
+	auditStore := audit.NewPostgresStore(pgClient.DB)
+	auditLogger := audit.NewLogger(auditStore, audit.Config{
+		BufferSize:    cfg.AuditBufferSize,
+		BatchSize:     cfg.AuditBatchSize,
+		FlushInterval: time.Duration(cfg.AuditFlushIntervalMS) * time.Millisecond,
+		WriteTimeout:  time.Duration(cfg.AuditWriteTimeoutSeconds) * time.Second,
+	})
+
+	// Connect to MinIO (audit chain checkpoints)
+	minioService, err := services.NewMinIOService(cfg.MinIOEndpoint, cfg.MinIOAccessKey, cfg.MinIOSecretKey, cfg.MinIOUseSSL)
+	if err != nil {
+		log.Fatalf("❌ Failed to create MinIO client: %v", err)
+	}
+	checkpointSigner := audit.NewCheckpointSigner(cfg.AuditCheckpointKey)
+	checkpointService := services.NewAuditCheckpointService(auditStore, minioService, cfg.AuditCheckpointBucket, checkpointSigner)
+	checkpointInterval := time.Duration(cfg.AuditCheckpointIntervalMinutes) * time.Minute
+	auditRetention := services.NewAuditRetentionService(auditStore, minioService, cfg.AuditArchiveBucket, cfg.AuditRetentionMonths, cfg.AuditPartitionsAhead)
+	log.Printf("🔏 Audit checkpoints signed with key %s", audit.KeyID(checkpointSigner.PublicKey()))
+
+	// Initialize Kafka producer (shipment.delivered is published from tracking polls)
+	log.Println("🔌 Initializing Kafka producer...")
+	kafkaProducer := kafka.NewProducer(kafka.NewConfigFromString(cfg.KafkaBrokers, "phil-my-meds-scheduler", "phil-my-meds-scheduler-producer"))
+	log.Println("✅ Kafka producer initialized successfully")
+
+	carrier, err := shipping.NewCarrier(cfg.ShippingCarrier, cfg.ShippoAPIBase, cfg.ShippoAPIToken)
+	if err != nil {
+		log.Fatalf("❌ Failed to configure shipping carrier: %v", err)
+	}
+	trackingService := services.NewShipmentTrackingService(mongoClient, kafkaProducer)
+	trackingStaleAfter := time.Duration(cfg.TrackingPollIntervalMinutes) * time.Minute
+
+	mailer := notifications.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword)
+	smsSender, err := sms.NewSender(cfg.SMSProvider, cfg.TwilioAPIBase, cfg.TwilioAccountSID, cfg.TwilioAuthToken, cfg.SMSFromNumber)
+	if err != nil {
+		log.Fatalf("❌ Failed to configure SMS provider: %v", err)
+	}
+	notificationService := services.NewNotificationService(mongoClient, mailer, smsSender, services.NewCommunicationPreferenceService(mongoClient), services.NotificationConfig{
+		From:                 cfg.SMTPFrom,
+		SMSStatusCallbackURL: cfg.SMSStatusCallbackURL(),
+		MaxAttempts:          cfg.NotificationMaxAttempts,
+	})
+
+	// Sagas whose step misses its deadline are failed and compensated here; dead-lettered steps are failed by the worker
+	paymentProvider, err := payments.NewProvider(cfg.PaymentProvider, cfg.StripeAPIBase, cfg.StripeSecretKey)
+	if err != nil {
+		log.Fatalf("❌ Failed to configure payment provider: %v", err)
+	}
+	sagaTimeouts, err := services.ParseSagaTimeouts(cfg.SagaStepTimeouts)
+	if err != nil {
+		log.Fatalf("❌ Failed to configure saga step timeouts: %v", err)
+	}
+	sagaService := services.NewSagaService(mongoClient, services.SagaConfig{
+		Timeouts:                sagaTimeouts,
+		MaxCompensationAttempts: cfg.SagaCompensationMaxAttempts,
+	})
+	compensator := workers.NewSagaCompensator(mongoClient, kafkaProducer, sagaService, services.NewPharmacyCapacityService(redisClient),
+		paymentProvider, carrier, services.NewRefundService(mongoClient, paymentProvider))
 
 	// TODO: Register scheduled maintenance jobs:
 	// - Prescription expiry checks (daily)
@@ -37,26 +150,122 @@ func main() {
 	// - Report generation (weekly)
 	// - Temporary file cleanup (daily)
 
-	// Example: Simple ticker for demonstration
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Changes made by scheduled jobs are audited as made by the scheduler
+	ctx = audit.WithActor(audit.WithLogger(ctx, auditLogger), audit.Actor{UserID: "system:scheduler"})
+
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
+	checkpointTicker := time.NewTicker(checkpointInterval)
+	defer checkpointTicker.Stop()
+	auditMaintenanceTicker := time.NewTicker(24 * time.Hour)
+	defer auditMaintenanceTicker.Stop()
+
+	// Partitions are checked at startup too, so a scheduler restarted daily still creates them
+	maintainAuditPartitions(ctx, auditRetention)
 
 	// Graceful shutdown handling
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
-	log.Println("✅ Scheduler is running. Press Ctrl+C to stop.")
+	log.Printf("✅ Scheduler is running (tracking poll every %s per shipment). Press Ctrl+C to stop.", trackingStaleAfter)
 
 	for {
 		select {
 		case <-ticker.C:
-			// This runs every minute
-			log.Println("⏰ Scheduler tick - maintenance tasks...")
-			// TODO: Execute scheduled maintenance tasks
+			// Each tick tracks the shipments whose last update is older than the poll interval
+			polled, err := trackingService.Poll(ctx, carrier, trackingStaleAfter, trackingPollBatch)
+			if err != nil {
+				log.Printf("❌ Tracking poll failed: %v", err)
+			} else if polled > 0 {
+				log.Printf("⏰ Tracking poll updated %d shipments", polled)
+			}
+
+			// Failed sends are retried once their backoff has passed, fallbacks sent on their next
+			// channel and texts held for quiet hours sent once they are over
+			sent, err := notificationService.RetryDue(ctx, time.Now(), notificationRetryBatch)
+			if err != nil {
+				log.Printf("❌ Notification retry failed: %v", err)
+			} else if sent > 0 {
+				log.Printf("⏰ Notification retry sent %d notifications", sent)
+			}
+
+			// Steps past their deadline fail their saga, and compensations that failed are retried with backoff
+			timedOut, retried, err := compensator.Sweep(ctx, time.Now(), sagaSweepBatch)
+			if err != nil {
+				log.Printf("❌ Saga sweep failed: %v", err)
+			} else if timedOut > 0 || retried > 0 {
+				log.Printf("⏰ Saga sweep timed out %d sagas and retried compensations of %d", timedOut, retried)
+			}
+
+			// DUR and verification decisions saved without their follow-up move their prescription on here
+			resumed, err := services.ResumeDecidedDURReviews(ctx, mongoClient, kafkaProducer, time.Now().Add(-decisionResumeAfter), decisionResumeBatch)
+			if err != nil {
+				log.Printf("❌ DUR review resume failed: %v", err)
+			} else if resumed > 0 {
+				log.Printf("⏰ Resumed %d decided DUR reviews", resumed)
+			}
+			resumed, err = services.ResumeDecidedVerifications(ctx, mongoClient, kafkaProducer, time.Now().Add(-decisionResumeAfter), decisionResumeBatch)
+			if err != nil {
+				log.Printf("❌ Verification resume failed: %v", err)
+			} else if resumed > 0 {
+				log.Printf("⏰ Resumed %d decided verifications", resumed)
+			}
+		case <-checkpointTicker.C:
+			// Chain heads are anchored outside PostgreSQL, so rows rewritten later no longer match them
+			object, err := checkpointService.Anchor(ctx, time.Now())
+			if err != nil {
+				log.Printf("❌ Audit checkpoint failed: %v", err)
+			} else if object != "" {
+				log.Printf("🔏 Audit checkpoint written to %s/%s", cfg.AuditCheckpointBucket, object)
+			}
+		case <-auditMaintenanceTicker.C:
+			maintainAuditPartitions(ctx, auditRetention)
 		case <-quit:
 			log.Println("🛑 Shutting down Scheduler gracefully...")
-			// TODO: Cleanup resources
+			cancel()
+
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer shutdownCancel()
+			if err := kafkaProducer.Close(); err != nil {
+				log.Printf("⚠️  Error closing Kafka producer: %v", err)
+			}
+			if err := mongoClient.Disconnect(shutdownCtx); err != nil {
+				log.Printf("⚠️  Error disconnecting MongoDB: %v", err)
+			}
+			if err := redisClient.Close(); err != nil {
+				log.Printf("⚠️  Error closing Redis connection: %v", err)
+			}
+			if err := auditLogger.Close(shutdownCtx); err != nil {
+				log.Printf("⚠️  Error flushing audit log: %v", err)
+			}
+			if err := pgClient.Close(); err != nil {
+				log.Printf("⚠️  Error closing PostgreSQL connection: %v", err)
+			}
+			log.Println("✅ Scheduler stopped")
 			return
 		}
 	}
 }
+
+// maintainAuditPartitions creates the audit_logs partitions of the months ahead and archives expired ones
+func maintainAuditPartitions(ctx context.Context, retention *services.AuditRetentionService) {
+	now := time.Now()
+	created, err := retention.CreatePartitions(ctx, now)
+	if err != nil {
+		log.Printf("❌ Audit partition creation failed: %v", err)
+	}
+	for _, partition := range created {
+		log.Printf("🗂️  Created audit log partition %s", partition.Name)
+	}
+
+	archived, err := retention.Archive(ctx, now)
+	for _, archive := range archived {
+		log.Printf("📦 Archived audit log partition %s (%d rows) to %s", archive.Partition, archive.Rows, archive.Object)
+	}
+	if err != nil {
+		log.Printf("❌ Audit partition archiving failed: %v", err)
+	}
+}
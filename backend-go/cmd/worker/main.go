@@ -10,6 +10,13 @@ import (
 	"time"
 
 	"github.com/phil-my-meds/backend-gogit/internal/config"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/notifications"
+	"github.com/phil-my-meds/backend-gogit/internal/payments"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"github.com/phil-my-meds/backend-gogit/internal/shipping"
+	"github.com/phil-my-meds/backend-gogit/internal/sms"
 	"github.com/phil-my-meds/backend-gogit/internal/workers"
 )
 
@@ -20,6 +27,9 @@ func main() {
 	cfg := config.Load()
 	log.Printf("📋 Configuration loaded (Environment: %s)", cfg.AppEnv)
 
+	// Events published outside a handler are recorded as produced by this service
+	events.SetDefaultProducer("worker")
+
 	// Initialize worker with all dependencies
 	worker, err := InitializeWorker(cfg)
 	if err != nil {
@@ -29,34 +39,113 @@ func main() {
 	// Register worker handlers
 	log.Println("📝 Registering worker handlers...")
 
-	// 1. Validation worker - processes prescription intake
-	validationHandler := workers.NewValidationWorker(worker.MongoClient, worker.KafkaProducer)
+	// 1. Validation worker - processes prescription intake, checks plan formulary and runs drug utilization review
+	formularyService := services.NewFormularyService(worker.MongoClient)
+	validationHandler := workers.NewValidationWorker(worker.MongoClient, worker.KafkaProducer, formularyService, services.NewDURService(worker.MongoClient))
 	worker.Registry.Register(validationHandler)
 
 	// 2. Enrollment worker - handles patient enrollment
 	enrollmentHandler := workers.NewEnrollmentWorker(worker.MongoClient, worker.KafkaProducer)
 	worker.Registry.Register(enrollmentHandler)
 
-	// 3. Routing worker - selects pharmacy for prescription
-	routingHandler := workers.NewRoutingWorker(worker.MongoClient, worker.KafkaProducer)
+	// 3. Routing worker - scores in-network pharmacies and selects one for the prescription
+	capacityService := services.NewPharmacyCapacityService(worker.Redis)
+	contractService := services.NewNetworkContractService(worker.MongoClient)
+	scoringService := services.NewPharmacyScoringService(worker.MongoClient, capacityService, contractService)
+	routingPolicyService := services.NewRoutingPolicyService(worker.MongoClient, models.RoutingMode(cfg.RoutingMode), cfg.RoutingTopN)
+	routingHandler := workers.NewRoutingWorker(worker.MongoClient, worker.KafkaProducer, scoringService, routingPolicyService, capacityService)
 	worker.Registry.Register(routingHandler)
 
-	// 4. Adjudication worker - processes insurance adjudication
-	adjudicationHandler := workers.NewAdjudicationWorker(worker.MongoClient, worker.KafkaProducer)
+	// 4. Adjudication worker - waits for pharmacy results, or adjudicates against plan rules in simulator mode
+	adjudicationSimulator := services.NewAdjudicationSimulator(worker.MongoClient, formularyService)
+	programService := services.NewManufacturerProgramService(worker.MongoClient, worker.Redis)
+	priorAuthService := services.NewPriorAuthService(worker.MongoClient)
+	adjudicationHandler := workers.NewAdjudicationWorker(worker.MongoClient, worker.KafkaProducer, worker.Redis, adjudicationSimulator, programService, priorAuthService, models.AdjudicationMode(cfg.AdjudicationMode))
 	worker.Registry.Register(adjudicationHandler)
 
-	// 5. Payment worker - creates payment links
-	paymentHandler := workers.NewPaymentWorker(worker.MongoClient, worker.KafkaProducer)
+	// 5. Prior authorization worker - opens a prior authorization when a claim is rejected for PA (reject code 75)
+	priorAuthHandler := workers.NewPriorAuthWorker(worker.MongoClient, worker.KafkaProducer, priorAuthService)
+	worker.Registry.Register(priorAuthHandler)
+
+	// 6. Payment worker - creates copay checkout links with the payment provider
+	paymentProvider, err := payments.NewProvider(cfg.PaymentProvider, cfg.StripeAPIBase, cfg.StripeSecretKey)
+	if err != nil {
+		log.Fatalf("❌ Failed to configure payment provider: %v", err)
+	}
+	paymentHandler := workers.NewPaymentWorker(worker.MongoClient, worker.KafkaProducer, paymentProvider, workers.PaymentLinkSettings{
+		TTL:        time.Duration(cfg.PaymentLinkTTLHours) * time.Hour,
+		SuccessURL: cfg.PaymentSuccessURL,
+		CancelURL:  cfg.PaymentCancelURL,
+	})
 	worker.Registry.Register(paymentHandler)
 
-	// 6. Shipping worker - creates shipping labels
-	shippingHandler := workers.NewShippingWorker(worker.MongoClient, worker.KafkaProducer)
+	// 7. Verification worker - queues paid prescriptions for a licensed pharmacist's clinical verification before shipping
+	verificationHandler := workers.NewVerificationWorker(worker.MongoClient, services.NewVerificationService(worker.MongoClient))
+	worker.Registry.Register(verificationHandler)
+
+	// 8. Shipping worker - rate shops and buys shipping labels with the carrier once a pharmacist has verified the prescription
+	carrier, err := shipping.NewCarrier(cfg.ShippingCarrier, cfg.ShippoAPIBase, cfg.ShippoAPIToken)
+	if err != nil {
+		log.Fatalf("❌ Failed to configure shipping carrier: %v", err)
+	}
+	minioService, err := services.NewMinIOService(cfg.MinIOEndpoint, cfg.MinIOAccessKey, cfg.MinIOSecretKey, cfg.MinIOUseSSL)
+	if err != nil {
+		log.Fatalf("❌ Failed to configure object storage: %v", err)
+	}
+	shipmentDocuments := services.NewShipmentDocumentService(worker.MongoClient, minioService, cfg.ShippingDocumentsBucket, time.Duration(cfg.DocumentURLTTLMinutes)*time.Minute)
+	shippingHandler := workers.NewShippingWorker(worker.MongoClient, worker.KafkaProducer, carrier, shipmentDocuments, workers.ShippingSettings{
+		MaxTransitDays:  cfg.ShippingMaxTransitDays,
+		Parcel:          shipping.DefaultParcel,
+		ColdChainCutoff: cfg.ColdChainCutoff,
+	})
 	worker.Registry.Register(shippingHandler)
 
-	// 7. Delivery worker - tracks delivery status
-	deliveryHandler := workers.NewDeliveryWorker(worker.MongoClient, worker.KafkaProducer)
+	// 9. Delivery worker - records each new shipment's first tracking status
+	trackingService := services.NewShipmentTrackingService(worker.MongoClient, worker.KafkaProducer)
+	deliveryHandler := workers.NewDeliveryWorker(carrier, trackingService)
 	worker.Registry.Register(deliveryHandler)
 
+	// 10. Shipment replacement worker - reships cold chain shipments reported out of range
+	replacementHandler := workers.NewShipmentReplacementWorker(worker.MongoClient, worker.KafkaProducer, carrier, shippingHandler)
+	worker.Registry.Register(replacementHandler)
+
+	// 11. Notification workers - email or text the patient alongside each topic's other handlers; retries and fallbacks are sent by the scheduler
+	mailer := notifications.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword)
+	smsSender, err := sms.NewSender(cfg.SMSProvider, cfg.TwilioAPIBase, cfg.TwilioAccountSID, cfg.TwilioAuthToken, cfg.SMSFromNumber)
+	if err != nil {
+		log.Fatalf("❌ Failed to configure SMS provider: %v", err)
+	}
+	notificationService := services.NewNotificationService(worker.MongoClient, mailer, smsSender, services.NewCommunicationPreferenceService(worker.MongoClient), services.NotificationConfig{
+		From:                 cfg.SMTPFrom,
+		SMSStatusCallbackURL: cfg.SMSStatusCallbackURL(),
+		MaxAttempts:          cfg.NotificationMaxAttempts,
+	})
+	for _, notificationHandler := range workers.NewNotificationWorkers(worker.MongoClient, notificationService, workers.NotificationSettings{
+		Support: cfg.NotificationSupport,
+	}) {
+		worker.Registry.Register(notificationHandler)
+	}
+
+	// 12. Event log workers - record every event for the correlation trace (GET /api/v1/correlations/{id})
+	for _, eventLogHandler := range workers.NewEventLogWorkers(worker.MongoClient) {
+		worker.Registry.Register(eventLogHandler)
+	}
+
+	// 13. Saga workers - follow each prescription through the steps above and undo them when a step is dead-lettered; timeouts are swept by the scheduler
+	sagaTimeouts, err := services.ParseSagaTimeouts(cfg.SagaStepTimeouts)
+	if err != nil {
+		log.Fatalf("❌ Failed to configure saga step timeouts: %v", err)
+	}
+	sagaService := services.NewSagaService(worker.MongoClient, services.SagaConfig{
+		Timeouts:                sagaTimeouts,
+		MaxCompensationAttempts: cfg.SagaCompensationMaxAttempts,
+	})
+	refundService := services.NewRefundService(worker.MongoClient, paymentProvider)
+	compensator := workers.NewSagaCompensator(worker.MongoClient, worker.KafkaProducer, sagaService, capacityService, paymentProvider, carrier, refundService)
+	for _, sagaHandler := range workers.NewSagaWorkers(sagaService, compensator) {
+		worker.Registry.Register(sagaHandler)
+	}
+
 	registeredTopics := worker.Registry.GetTopics()
 	if len(registeredTopics) == 0 {
 		log.Println("⚠️  Warning: No worker handlers registered. Worker will not process any messages.")
@@ -3,13 +3,17 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
 	"github.com/phil-my-meds/backend-gogit/internal/config"
 	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
 	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
 	"github.com/phil-my-meds/backend-gogit/internal/workers"
 )
 
@@ -22,6 +26,7 @@ type Worker struct {
 	KafkaConsumer kafka.Consumer
 	KafkaProducer kafka.Producer
 	Registry      *workers.Registry
+	Audit         *audit.Logger
 }
 
 // InitializeWorker sets up all database connections and returns a configured worker
@@ -48,6 +53,12 @@ func InitializeWorker(cfg *config.Config) (*Worker, error) {
 	}
 	worker.Postgres = pgClient
 	log.Println("✅ PostgreSQL connected successfully (audit logs only)")
+	worker.Audit = audit.NewLogger(audit.NewPostgresStore(pgClient.DB), audit.Config{
+		BufferSize:    cfg.AuditBufferSize,
+		BatchSize:     cfg.AuditBatchSize,
+		FlushInterval: time.Duration(cfg.AuditFlushIntervalMS) * time.Millisecond,
+		WriteTimeout:  time.Duration(cfg.AuditWriteTimeoutSeconds) * time.Second,
+	})
 
 	// Connect to Redis
 	log.Println("🔌 Connecting to Redis...")
@@ -79,6 +90,12 @@ func InitializeWorker(cfg *config.Config) (*Worker, error) {
 	// Initialize worker registry
 	log.Println("🔧 Initializing worker registry...")
 	worker.Registry = workers.NewRegistry()
+
+	// Every handler claims each event in the processed-event ledger, so redelivered events are not handled twice
+	processedEvents := services.NewProcessedEventService(redisClient,
+		time.Duration(cfg.ProcessedEventRetentionHours)*time.Hour,
+		time.Duration(cfg.ProcessedEventLeaseMinutes)*time.Minute)
+	worker.Registry.Use(workers.Idempotent(processedEvents))
 	log.Println("✅ Worker registry initialized successfully")
 
 	return worker, nil
@@ -155,33 +172,54 @@ func (w *Worker) processBatch(ctx context.Context) {
 // This implements the event flow: consume -> process -> emit
 func (w *Worker) processMessage(ctx context.Context, msg *kafka.Message) {
 	// 8.3.1: Worker consumes Kafka event
-	log.Printf("📨 [8.3.1] Consuming Kafka event: topic=%s, partition=%d, offset=%d, key=%s",
-		msg.Topic, msg.Partition, msg.Offset, string(msg.Key))
+	correlationID := workers.ExtractCorrelationID(msg)
+	log.Printf("📨 [8.3.1] [correlation_id=%s] Consuming Kafka event: topic=%s, partition=%d, offset=%d, key=%s",
+		correlationID, msg.Topic, msg.Partition, msg.Offset, string(msg.Key))
+
+	// Status changes and PHI access made while handling it are audited
+	ctx = audit.WithLogger(ctx, w.Audit)
+
+	// Events published while handling this one record it as their cause
+	if envelope, err := events.ReadEnvelope(msg.Value); err == nil && envelope.EventID != "" {
+		ctx = events.WithCause(ctx, envelope)
+	}
 
-	// Get handler for this topic
-	handler := w.Registry.GetHandler(msg.Topic)
-	if handler == nil {
-		log.Printf("⚠️  Warning: No handler registered for topic: %s. Skipping message.", msg.Topic)
+	// Get the handlers for this topic
+	handlers := w.Registry.GetHandlers(msg.Topic)
+	if len(handlers) == 0 {
+		log.Printf("⚠️  [correlation_id=%s] Warning: No handler registered for topic: %s. Skipping message.", correlationID, msg.Topic)
 		// Send to dead letter queue for unhandled topics
 		if err := workers.PublishToDeadLetterQueue(ctx, w.KafkaProducer, msg, "No handler registered for topic"); err != nil {
-			log.Printf("❌ Failed to send unhandled message to DLQ: %v", err)
+			log.Printf("❌ [correlation_id=%s] Failed to send unhandled message to DLQ: %v", correlationID, err)
 		}
 		return
 	}
 
 	// 8.3.2: Process business logic
-	log.Printf("⚙️  [8.3.2] Processing business logic for topic: %s", msg.Topic)
-	if err := handler.Handle(ctx, msg); err != nil {
-		log.Printf("❌ Error processing message (topic=%s, offset=%d): %v", msg.Topic, msg.Offset, err)
-		// Send failed message to dead letter queue
-		if err := workers.PublishToDeadLetterQueue(ctx, w.KafkaProducer, msg, err.Error()); err != nil {
-			log.Printf("❌ Failed to send failed message to DLQ: %v", err)
+	// Every handler gets the message; one failing does not stop the others
+	log.Printf("⚙️  [8.3.2] [correlation_id=%s] Processing business logic for topic: %s (%d handlers)", correlationID, msg.Topic, len(handlers))
+	failed := false
+	for _, handler := range handlers {
+		handlerCtx := audit.WithActor(events.WithProducer(ctx, workers.HandlerName(handler)), audit.Actor{UserID: "system:" + workers.HandlerName(handler)})
+		if err := handler.Handle(handlerCtx, msg); err != nil {
+			failed = true
+			log.Printf("❌ [correlation_id=%s] Error processing message (topic=%s, offset=%d, handler=%s): %v", correlationID, msg.Topic, msg.Offset, workers.HandlerName(handler), err)
+			// A dead letter that fails is left in the queue rather than dead-lettered again
+			if msg.Topic == kafka.TopicDeadLetterQueue {
+				continue
+			}
+			// Send failed message to dead letter queue
+			if err := workers.PublishToDeadLetterQueue(ctx, w.KafkaProducer, msg, fmt.Sprintf("%s: %v", workers.HandlerName(handler), err)); err != nil {
+				log.Printf("❌ [correlation_id=%s] Failed to send failed message to DLQ: %v", correlationID, err)
+			}
 		}
+	}
+	if failed {
 		return
 	}
 
 	// 8.3.3: Next Kafka event is emitted by the handler (if applicable)
-	log.Printf("✅ [8.3.3] Successfully processed message: topic=%s, offset=%d (next event emitted by handler)", msg.Topic, msg.Offset)
+	log.Printf("✅ [8.3.3] [correlation_id=%s] Successfully processed message: topic=%s, offset=%d (next event emitted by handler)", correlationID, msg.Topic, msg.Offset)
 }
 
 
@@ -201,6 +239,13 @@ func (w *Worker) Shutdown(ctx context.Context) error {
 		}
 	}
 
+	// Write queued audit entries before the PostgreSQL connection closes
+	if w.Audit != nil {
+		if err := w.Audit.Close(ctx); err != nil {
+			log.Printf("⚠️  Error flushing audit log: %v", err)
+		}
+	}
+
 	if w.MongoClient != nil {
 		if err := w.MongoClient.Disconnect(ctx); err != nil {
 			log.Printf("⚠️  Error disconnecting MongoDB: %v", err)
@@ -0,0 +1,179 @@
+// Package audit records the HIPAA audit trail in the audit_logs table
+// Callers describe what happened with an Entry and hand it to Record, which takes the Logger and the
+// acting user, IP address and user agent from the context. Each service puts its Logger on the
+// context of every request, message and scheduled run; the Logger writes entries to PostgreSQL
+// asynchronously in batches. Entries HIPAA requires (PHI access and changes to patient records) are
+// written before Record returns, and an entry that cannot be written fails the operation instead of
+// letting it go unaudited.
+package audit
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// EventType is the kind of audited event, stored in audit_logs.event_type
+type EventType string
+
+const (
+	EventPrescriptionCreated       EventType = "prescription_created"
+	EventPrescriptionStatusChanged EventType = "prescription_status_changed"
+	EventPHIAccessed               EventType = "phi_accessed"
+	EventPriorAuthChanged          EventType = "prior_authorization_changed"
+	EventRefundChanged             EventType = "refund_changed"
+	EventPaymentChanged            EventType = "payment_changed"
+	EventShipmentChanged           EventType = "shipment_changed"
+	EventCommunicationPrefsChanged EventType = "communication_preferences_changed"
+	EventAllergiesChanged          EventType = "allergies_changed"
+	EventDURReviewChanged          EventType = "dur_review_changed"
+	EventVerificationChanged       EventType = "verification_changed"
+)
+
+// EntityType is the kind of record an event is about, stored in audit_logs.entity_type
+type EntityType string
+
+const (
+	EntityPrescription             EntityType = "prescription"
+	EntityPatient                  EntityType = "patient"
+	EntityPriorAuthorization       EntityType = "prior_authorization"
+	EntityPayment                  EntityType = "payment"
+	EntityRefund                   EntityType = "refund"
+	EntityShipment                 EntityType = "shipment"
+	EntityCommunicationPreferences EntityType = "communication_preferences"
+	EntityCorrelation              EntityType = "correlation"
+	EntityAuditLog                 EntityType = "audit_log"
+	EntityDURReview                EntityType = "dur_review"
+	EntityVerification             EntityType = "verification"
+)
+
+// Action is what was done to the entity, stored in audit_logs.action
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionRead   Action = "read"
+	ActionUpdate Action = "update"
+)
+
+// requiredEvents are the events HIPAA requires to be audited: access to and changes of PHI
+// Recording one of them waits for the write and fails if the entry cannot be stored.
+var requiredEvents = map[EventType]bool{
+	EventPrescriptionCreated:       true,
+	EventPrescriptionStatusChanged: true,
+	EventPHIAccessed:               true,
+	EventPriorAuthChanged:          true,
+	EventCommunicationPrefsChanged: true,
+	EventAllergiesChanged:          true,
+	EventDURReviewChanged:          true,
+	EventVerificationChanged:       true,
+}
+
+var (
+	// ErrUnavailable is returned when a required entry could not be written
+	ErrUnavailable = errors.New("audit log unavailable")
+	// ErrClosed is returned when an entry is recorded after the logger was closed
+	ErrClosed = errors.New("audit logger closed")
+)
+
+// Entry is one row of the audit trail
+type Entry struct {
+	EventType  EventType
+	EntityType EntityType
+	EntityID   string
+	UserID     string // the acting user; "system:{component}" for workers and the scheduler
+	Action     Action
+	Details    map[string]interface{}
+	IPAddress  string
+	UserAgent  string
+	CreatedAt  time.Time
+}
+
+// Required reports whether HIPAA requires the event to be audited
+func Required(eventType EventType) bool {
+	return requiredEvents[eventType]
+}
+
+// Actor is who is acting in a context: the signed-in user, pharmacy or service component
+type Actor struct {
+	UserID    string
+	IPAddress string
+	UserAgent string
+}
+
+// loggerKey and actorKey are the context keys of the audit logger and the actor
+type loggerKey struct{}
+type actorKey struct{}
+
+// WithLogger returns a context whose audit entries are written by the logger
+func WithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// WithActor returns a context whose audit entries are recorded as made by the actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// WithUser returns a context whose actor is the given user, keeping the actor's IP address and user agent
+func WithUser(ctx context.Context, userID string) context.Context {
+	actor := ActorFrom(ctx)
+	actor.UserID = userID
+	return WithActor(ctx, actor)
+}
+
+// ActorFrom returns the actor of a context
+func ActorFrom(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+// Record writes entries with the context's logger, filling in the actor from the context
+// Required entries are written before it returns; an error means they were not all stored and the
+// audited operation must not go ahead (or be reported as done). Other entries are queued. Without a
+// logger on the context (tools and tests), nothing is recorded.
+func Record(ctx context.Context, entries ...Entry) error {
+	logger, _ := ctx.Value(loggerKey{}).(*Logger)
+	if logger == nil {
+		return nil
+	}
+
+	actor := ActorFrom(ctx)
+	entries = append([]Entry(nil), entries...)
+	for i := range entries {
+		if entries[i].UserID == "" {
+			entries[i].UserID = actor.UserID
+		}
+		if entries[i].IPAddress == "" {
+			entries[i].IPAddress = actor.IPAddress
+		}
+		if entries[i].UserAgent == "" {
+			entries[i].UserAgent = actor.UserAgent
+		}
+	}
+	return logger.Log(ctx, entries...)
+}
+
+// StatusChange returns the entry for a prescription moving to a new status
+func StatusChange(prescriptionID, status, correlationID string) Entry {
+	return Entry{
+		EventType:  EventPrescriptionStatusChanged,
+		EntityType: EntityPrescription,
+		EntityID:   prescriptionID,
+		Action:     ActionUpdate,
+		Details: map[string]interface{}{
+			"status":         status,
+			"correlation_id": correlationID,
+		},
+	}
+}
+
+// PHIAccess returns the entry for a read of an entity holding PHI
+func PHIAccess(entityType EntityType, entityID string) Entry {
+	return Entry{
+		EventType:  EventPHIAccessed,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     ActionRead,
+	}
+}
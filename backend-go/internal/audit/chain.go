@@ -0,0 +1,153 @@
+// Package audit records the HIPAA audit trail in the audit_logs table
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GenesisHash is the previous hash of the first row of a chain
+var GenesisHash = strings.Repeat("0", 64)
+
+// ChainID returns the hash chain of an entry created at t: its UTC day, e.g. "2024-01-15"
+func ChainID(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// ChainRow is an audit_logs row as stored, with its place in its hash chain
+// Details is the JSON PostgreSQL returns for the stored jsonb, so the hash covers what is stored
+// rather than what was sent; NULL columns are empty. Archives keep Details as that string for the same reason.
+type ChainRow struct {
+	ID         int64     `json:"id"`
+	ChainID    string    `json:"chain_id"`
+	Seq        int64     `json:"seq"`
+	EventType  string    `json:"event_type"`
+	EntityType string    `json:"entity_type"`
+	EntityID   string    `json:"entity_id"`
+	UserID     string    `json:"user_id"`
+	Action     string    `json:"action"`
+	Details    string    `json:"details"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	PrevHash   string    `json:"prev_hash"`
+	Hash       string    `json:"hash"`
+}
+
+// ComputeHash returns the row's hash: SHA-256 (hex) over its content, its place in the chain and PrevHash
+func (r ChainRow) ComputeHash() string {
+	// A JSON array keeps field boundaries unambiguous; marshalling strings and integers cannot fail
+	content, _ := json.Marshal([]interface{}{
+		r.ChainID, r.Seq, r.EventType, r.EntityType, r.EntityID, r.UserID, r.Action,
+		r.Details, r.IPAddress, r.UserAgent, r.CreatedAt.UTC().Format(time.RFC3339Nano), r.PrevHash,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ChainHead is the last row of a chain at some point: its position and hash
+type ChainHead struct {
+	ChainID string `json:"chain_id"`
+	Seq     int64  `json:"seq"`
+	Hash    string `json:"hash"`
+}
+
+// ChainBreak is the first link of a chain that does not verify
+type ChainBreak struct {
+	ChainID string
+	Seq     int64
+	RowID   int64 // 0 when the row is missing
+	Reason  string
+}
+
+func (b *ChainBreak) Error() string {
+	if b.RowID == 0 {
+		return fmt.Sprintf("chain %s breaks at seq %d: %s", b.ChainID, b.Seq, b.Reason)
+	}
+	return fmt.Sprintf("chain %s breaks at seq %d (row %d): %s", b.ChainID, b.Seq, b.RowID, b.Reason)
+}
+
+// ChainVerifier recomputes a chain row by row, in seq order
+// Anchors from signed checkpoints pin the hash of a row, so rewriting the chain from that row on (or
+// dropping its tail) is caught even though the rewritten rows link up.
+type ChainVerifier struct {
+	chainID  string
+	next     int64
+	prevHash string
+	anchors  map[int64]ChainAnchor
+}
+
+// ChainAnchor is a row hash recorded in a checkpoint
+type ChainAnchor struct {
+	Hash   string
+	Source string // the checkpoint it came from, for the report
+}
+
+// NewChainVerifier creates a verifier for a chain, expecting its first row next
+func NewChainVerifier(chainID string) *ChainVerifier {
+	return &ChainVerifier{
+		chainID:  chainID,
+		next:     1,
+		prevHash: GenesisHash,
+		anchors:  map[int64]ChainAnchor{},
+	}
+}
+
+// Anchor pins the hash of the row at seq, as recorded by a checkpoint
+func (v *ChainVerifier) Anchor(seq int64, hash, source string) {
+	v.anchors[seq] = ChainAnchor{Hash: hash, Source: source}
+}
+
+// Check verifies the next row of the chain, returning the break if it does not follow
+func (v *ChainVerifier) Check(row ChainRow) *ChainBreak {
+	broken := func(reason string, args ...interface{}) *ChainBreak {
+		return &ChainBreak{ChainID: v.chainID, Seq: row.Seq, RowID: row.ID, Reason: fmt.Sprintf(reason, args...)}
+	}
+
+	switch {
+	case row.Seq > v.next:
+		return &ChainBreak{ChainID: v.chainID, Seq: v.next, Reason: fmt.Sprintf("row missing (next row found is seq %d)", row.Seq)}
+	case row.Seq < v.next:
+		return broken("seq repeated or out of order (expected %d)", v.next)
+	case row.PrevHash != v.prevHash:
+		return broken("prev_hash does not match the hash of the previous row")
+	case row.ComputeHash() != row.Hash:
+		return broken("hash does not match the row content")
+	}
+	if anchor, ok := v.anchors[row.Seq]; ok && anchor.Hash != row.Hash {
+		return broken("hash differs from checkpoint %s", anchor.Source)
+	}
+
+	v.next++
+	v.prevHash = row.Hash
+	return nil
+}
+
+// Finish returns a break if the chain ends before a row a checkpoint anchored
+func (v *ChainVerifier) Finish() *ChainBreak {
+	var missing []int64
+	for seq := range v.anchors {
+		if seq >= v.next {
+			missing = append(missing, seq)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	return &ChainBreak{
+		ChainID: v.chainID,
+		Seq:     v.next,
+		Reason:  fmt.Sprintf("chain ends at seq %d but checkpoint %s anchored seq %d", v.next-1, v.anchors[missing[0]].Source, missing[0]),
+	}
+}
+
+// Head returns the last verified row of the chain
+func (v *ChainVerifier) Head() ChainHead {
+	return ChainHead{ChainID: v.chainID, Seq: v.next - 1, Hash: v.prevHash}
+}
@@ -0,0 +1,180 @@
+// Package audit provides hash chain and checkpoint tests
+package audit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testChain returns n linked rows of a chain
+func testChain(n int) []ChainRow {
+	at := time.Date(2024, 1, 15, 12, 0, 0, 123456000, time.UTC)
+	rows := make([]ChainRow, n)
+	for i := range rows {
+		rows[i] = ChainRow{
+			ID:         int64(100 + i),
+			ChainID:    "2024-01-15",
+			Seq:        int64(i + 1),
+			EventType:  string(EventPHIAccessed),
+			EntityType: string(EntityPatient),
+			EntityID:   "pt_1",
+			UserID:     "ops-1",
+			Action:     string(ActionRead),
+			Details:    `{"correlation_id": "corr-1"}`,
+			CreatedAt:  at.Add(time.Duration(i) * time.Second),
+		}
+	}
+	LinkChain(GenesisHash, rows)
+	return rows
+}
+
+// verify checks rows with a verifier anchored to the given heads, returning the first break
+func verify(rows []ChainRow, anchors ...ChainHead) *ChainBreak {
+	verifier := NewChainVerifier("2024-01-15")
+	for _, anchor := range anchors {
+		verifier.Anchor(anchor.Seq, anchor.Hash, "checkpoints/test.json")
+	}
+	for _, row := range rows {
+		if broken := verifier.Check(row); broken != nil {
+			return broken
+		}
+	}
+	return verifier.Finish()
+}
+
+// TestChainVerifier_ValidChain tests that an untouched chain verifies up to its head
+func TestChainVerifier_ValidChain(t *testing.T) {
+	rows := testChain(5)
+	if rows[0].PrevHash != GenesisHash || rows[1].PrevHash != rows[0].Hash {
+		t.Fatalf("Expected rows linked from the genesis hash, got %+v", rows[:2])
+	}
+
+	verifier := NewChainVerifier("2024-01-15")
+	verifier.Anchor(3, rows[2].Hash, "checkpoints/test.json")
+	for _, row := range rows {
+		if broken := verifier.Check(row); broken != nil {
+			t.Fatalf("Expected chain to verify, got %v", broken)
+		}
+	}
+	if broken := verifier.Finish(); broken != nil {
+		t.Errorf("Expected chain to verify, got %v", broken)
+	}
+	if head := verifier.Head(); head.Seq != 5 || head.Hash != rows[4].Hash {
+		t.Errorf("Expected head at seq 5, got %+v", head)
+	}
+}
+
+// TestChainVerifier_AlteredRow tests that changing a row's content breaks the chain at that row
+func TestChainVerifier_AlteredRow(t *testing.T) {
+	rows := testChain(5)
+	rows[2].UserID = "ops-2"
+
+	broken := verify(rows)
+	if broken == nil || broken.Seq != 3 || broken.RowID != 102 || !strings.Contains(broken.Reason, "hash does not match") {
+		t.Errorf("Expected break at seq 3 for altered content, got %v", broken)
+	}
+}
+
+// TestChainVerifier_RehashedRow tests that an altered row given a new hash breaks the link to the next row
+func TestChainVerifier_RehashedRow(t *testing.T) {
+	rows := testChain(5)
+	rows[2].Details = `{"correlation_id": "corr-2"}`
+	rows[2].Hash = rows[2].ComputeHash()
+
+	broken := verify(rows)
+	if broken == nil || broken.Seq != 4 || !strings.Contains(broken.Reason, "prev_hash") {
+		t.Errorf("Expected break at seq 4, got %v", broken)
+	}
+}
+
+// TestChainVerifier_DeletedRow tests that a deleted row is reported as missing
+func TestChainVerifier_DeletedRow(t *testing.T) {
+	rows := testChain(5)
+	rows = append(rows[:1], rows[2:]...)
+
+	broken := verify(rows)
+	if broken == nil || broken.Seq != 2 || broken.RowID != 0 || !strings.Contains(broken.Reason, "missing") {
+		t.Errorf("Expected seq 2 missing, got %v", broken)
+	}
+}
+
+// TestChainVerifier_RewrittenChain tests that a chain rewritten and relinked is caught by a checkpoint anchor
+func TestChainVerifier_RewrittenChain(t *testing.T) {
+	rows := testChain(5)
+	anchor := ChainHead{ChainID: "2024-01-15", Seq: 4, Hash: rows[3].Hash}
+
+	rows[1].EntityID = "pt_2"
+	LinkChain(GenesisHash, rows)
+
+	broken := verify(rows, anchor)
+	if broken == nil || broken.Seq != 4 || !strings.Contains(broken.Reason, "checkpoints/test.json") {
+		t.Errorf("Expected break at the anchored seq 4, got %v", broken)
+	}
+}
+
+// TestChainVerifier_TruncatedChain tests that deleting the tail of a chain is caught by a checkpoint anchor
+func TestChainVerifier_TruncatedChain(t *testing.T) {
+	rows := testChain(5)
+	anchor := ChainHead{ChainID: "2024-01-15", Seq: 5, Hash: rows[4].Hash}
+
+	if broken := verify(rows[:3]); broken != nil {
+		t.Fatalf("Expected a truncated chain to verify without anchors, got %v", broken)
+	}
+	broken := verify(rows[:3], anchor)
+	if broken == nil || broken.Seq != 4 || !strings.Contains(broken.Reason, "anchored seq 5") {
+		t.Errorf("Expected truncation after seq 3, got %v", broken)
+	}
+}
+
+// TestCheckpoint_SignAndVerify tests that a signed checkpoint verifies with the signer's public key only
+func TestCheckpoint_SignAndVerify(t *testing.T) {
+	signer := NewCheckpointSigner("test-secret")
+	checkpoint := Checkpoint{
+		CreatedAt: time.Date(2024, 1, 15, 13, 0, 0, 0, time.UTC),
+		Heads:     []ChainHead{{ChainID: "2024-01-15", Seq: 5, Hash: testChain(5)[4].Hash}},
+	}
+
+	data, err := signer.Sign(checkpoint)
+	if err != nil {
+		t.Fatalf("Expected checkpoint to be signed, got %v", err)
+	}
+	verified, err := VerifyCheckpoint(data, signer.PublicKey())
+	if err != nil {
+		t.Fatalf("Expected checkpoint to verify, got %v", err)
+	}
+	if verified.KeyID != KeyID(signer.PublicKey()) || len(verified.Heads) != 1 || verified.Heads[0] != checkpoint.Heads[0] {
+		t.Errorf("Expected the signed heads back, got %+v", verified)
+	}
+
+	other := NewCheckpointSigner("other-secret")
+	if _, err := VerifyCheckpoint(data, other.PublicKey()); !errors.Is(err, ErrInvalidCheckpoint) {
+		t.Errorf("Expected ErrInvalidCheckpoint with another key, got %v", err)
+	}
+}
+
+// TestCheckpoint_Tampered tests that editing a signed checkpoint fails verification
+func TestCheckpoint_Tampered(t *testing.T) {
+	signer := NewCheckpointSigner("test-secret")
+	data, err := signer.Sign(Checkpoint{Heads: []ChainHead{{ChainID: "2024-01-15", Seq: 5, Hash: GenesisHash}}})
+	if err != nil {
+		t.Fatalf("Expected checkpoint to be signed, got %v", err)
+	}
+
+	tampered := strings.Replace(string(data), `"seq":5`, `"seq":3`, 1)
+	if tampered == string(data) {
+		t.Fatalf("Expected the checkpoint to contain the seq, got %s", data)
+	}
+	if _, err := VerifyCheckpoint([]byte(tampered), signer.PublicKey()); !errors.Is(err, ErrInvalidCheckpoint) {
+		t.Errorf("Expected ErrInvalidCheckpoint, got %v", err)
+	}
+}
+
+// TestCheckpointObject tests checkpoint object names sort by time
+func TestCheckpointObject(t *testing.T) {
+	at := time.Date(2024, 1, 15, 13, 4, 5, 0, time.FixedZone("EST", -5*3600))
+	if name := CheckpointObject(at); name != "checkpoints/2024/01/15/20240115T180405Z.json" {
+		t.Errorf("Expected UTC object name, got %s", name)
+	}
+}
@@ -0,0 +1,234 @@
+// Package audit records the HIPAA audit trail in the audit_logs table
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store writes batches of audit entries
+type Store interface {
+	WriteBatch(ctx context.Context, entries []Entry) error
+}
+
+// Config sizes the logger's buffer and batches
+type Config struct {
+	BufferSize    int           // entries queued before optional entries are dropped and required ones wait
+	BatchSize     int           // most entries written at once
+	FlushInterval time.Duration // longest an optional entry waits for its batch to fill
+	WriteTimeout  time.Duration // limit on each batch write
+}
+
+// Config defaults
+const (
+	defaultBufferSize    = 1000
+	defaultBatchSize     = 100
+	defaultFlushInterval = 500 * time.Millisecond
+	defaultWriteTimeout  = 5 * time.Second
+)
+
+// pending is a queued entry; result is set for required entries, whose caller waits for the write
+type pending struct {
+	entry  Entry
+	result chan error
+}
+
+// Logger queues audit entries and writes them to the store in batches from a background goroutine
+// A required entry flushes its batch at once and its caller waits for the result; optional entries
+// are written when the batch fills or the flush interval passes, and are dropped (and counted) when
+// the buffer is full rather than holding up the request.
+type Logger struct {
+	store   Store
+	config  Config
+	queue   chan pending
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Int64
+}
+
+// NewLogger creates a logger writing to the store and starts its writer
+func NewLogger(store Store, config Config) *Logger {
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaultBufferSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = defaultWriteTimeout
+	}
+
+	l := &Logger{
+		store:  store,
+		config: config,
+		queue:  make(chan pending, config.BufferSize),
+		done:   make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Log queues entries, waiting for the writes of those HIPAA requires
+// Entries logged together are written in the same batch where the buffer allows.
+func (l *Logger) Log(ctx context.Context, entries ...Entry) error {
+	l.mu.RLock()
+	if l.closed {
+		l.mu.RUnlock()
+		for _, entry := range entries {
+			if Required(entry.EventType) {
+				return ErrClosed
+			}
+		}
+		return nil
+	}
+
+	var results []chan error
+	for _, entry := range entries {
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now().UTC()
+		}
+
+		if !Required(entry.EventType) {
+			select {
+			case l.queue <- pending{entry: entry}:
+			default:
+				dropped := l.dropped.Add(1)
+				log.Printf("⚠️  Audit buffer full, dropped %s entry for %s %s (%d dropped)", entry.EventType, entry.EntityType, entry.EntityID, dropped)
+			}
+			continue
+		}
+
+		result := make(chan error, 1)
+		select {
+		case l.queue <- pending{entry: entry, result: result}:
+			results = append(results, result)
+		case <-ctx.Done():
+			l.mu.RUnlock()
+			return fmt.Errorf("%w: %s entry not queued: %v", ErrUnavailable, entry.EventType, ctx.Err())
+		}
+	}
+	l.mu.RUnlock()
+
+	for _, result := range results {
+		select {
+		case err := <-result:
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("%w: entry not confirmed: %v", ErrUnavailable, ctx.Err())
+		}
+	}
+	return nil
+}
+
+// Dropped returns the number of optional entries dropped because the buffer was full
+func (l *Logger) Dropped() int64 {
+	return l.dropped.Load()
+}
+
+// Close stops accepting entries and waits until the queued ones are written or the context ends
+func (l *Logger) Close(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("audit entries still queued: %w", ctx.Err())
+	}
+}
+
+// run collects queued entries into batches and writes them until the queue is closed
+func (l *Logger) run() {
+	defer close(l.done)
+
+	batch := make([]pending, 0, l.config.BatchSize)
+	timer := time.NewTimer(l.config.FlushInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case p, ok := <-l.queue:
+			if !ok {
+				l.flush(batch)
+				return
+			}
+			batch = append(batch, p)
+			// A required entry goes out with whatever else is already queued, without waiting
+			if p.result != nil {
+				batch = l.drain(batch)
+			}
+			if p.result != nil || len(batch) >= l.config.BatchSize {
+				l.flush(batch)
+				batch = batch[:0]
+			}
+		case <-timer.C:
+			l.flush(batch)
+			batch = batch[:0]
+			timer.Reset(l.config.FlushInterval)
+		}
+	}
+}
+
+// drain adds entries already waiting in the queue to the batch, up to the batch size
+func (l *Logger) drain(batch []pending) []pending {
+	for len(batch) < l.config.BatchSize {
+		select {
+		case p, ok := <-l.queue:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, p)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// flush writes a batch and reports the result to the callers of its required entries
+// A failed write is retried once; optional entries are then dropped with a log line.
+func (l *Logger) flush(batch []pending) {
+	if len(batch) == 0 {
+		return
+	}
+	entries := make([]Entry, len(batch))
+	for i, p := range batch {
+		entries[i] = p.entry
+	}
+
+	err := l.write(entries)
+	if err != nil {
+		err = l.write(entries)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to write %d audit entries: %v", len(entries), err)
+		err = fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	for _, p := range batch {
+		if p.result != nil {
+			p.result <- err
+		}
+	}
+}
+
+// write stores entries within the write timeout
+func (l *Logger) write(entries []Entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.config.WriteTimeout)
+	defer cancel()
+	return l.store.WriteBatch(ctx, entries)
+}
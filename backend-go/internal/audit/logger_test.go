@@ -0,0 +1,212 @@
+// Package audit provides audit logger tests
+package audit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStore records written batches and fails while err is set
+type fakeStore struct {
+	mu      sync.Mutex
+	batches [][]Entry
+	err     error
+	block   chan struct{}
+}
+
+func (s *fakeStore) WriteBatch(ctx context.Context, entries []Entry) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.batches = append(s.batches, append([]Entry(nil), entries...))
+	return nil
+}
+
+func (s *fakeStore) written() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []Entry
+	for _, batch := range s.batches {
+		entries = append(entries, batch...)
+	}
+	return entries
+}
+
+// TestRecord_RequiredWaitsForWrite tests that a required entry is stored with the context's actor before Record returns
+func TestRecord_RequiredWaitsForWrite(t *testing.T) {
+	store := &fakeStore{}
+	logger := NewLogger(store, Config{FlushInterval: time.Hour})
+	defer logger.Close(context.Background())
+
+	ctx := WithLogger(context.Background(), logger)
+	ctx = WithActor(ctx, Actor{IPAddress: "10.0.0.1", UserAgent: "curl/8"})
+	ctx = WithUser(ctx, "ops-1")
+
+	if err := Record(ctx, PHIAccess(EntityShipment, "shp_1")); err != nil {
+		t.Fatalf("Expected the entry to be written, got %v", err)
+	}
+
+	entries := store.written()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry written before Record returned, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.UserID != "ops-1" || entry.IPAddress != "10.0.0.1" || entry.UserAgent != "curl/8" || entry.CreatedAt.IsZero() {
+		t.Errorf("Expected the actor and time to be filled in, got %+v", entry)
+	}
+}
+
+// TestRecord_RequiredEntriesTogether tests that entries recorded together are written in one batch
+func TestRecord_RequiredEntriesTogether(t *testing.T) {
+	store := &fakeStore{}
+	logger := NewLogger(store, Config{FlushInterval: time.Hour})
+	defer logger.Close(context.Background())
+
+	ctx := WithLogger(context.Background(), logger)
+	if err := Record(ctx, PHIAccess(EntityRefund, "ref_1"), PHIAccess(EntityRefund, "ref_2"), PHIAccess(EntityRefund, "ref_3")); err != nil {
+		t.Fatalf("Expected the entries to be written, got %v", err)
+	}
+
+	if len(store.written()) != 3 {
+		t.Errorf("Expected 3 entries written, got %d", len(store.written()))
+	}
+	if len(store.batches) > 2 {
+		t.Errorf("Expected the entries to share batches, got %d batches", len(store.batches))
+	}
+}
+
+// TestRecord_RequiredFailsClosed tests that a required entry the store cannot write fails the caller
+func TestRecord_RequiredFailsClosed(t *testing.T) {
+	store := &fakeStore{err: errors.New("connection refused")}
+	logger := NewLogger(store, Config{FlushInterval: time.Hour})
+	defer logger.Close(context.Background())
+
+	ctx := WithLogger(context.Background(), logger)
+	err := Record(ctx, StatusChange("rx_1", "paid", "corr-1"))
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+
+	// Optional entries do not fail the caller
+	if err := Record(ctx, Entry{EventType: EventShipmentChanged, EntityType: EntityShipment, EntityID: "shp_1", Action: ActionUpdate}); err != nil {
+		t.Errorf("Expected optional entry to be queued, got %v", err)
+	}
+}
+
+// TestRecord_RequiredTimesOut tests that a required entry fails when the write does not finish in time
+func TestRecord_RequiredTimesOut(t *testing.T) {
+	store := &fakeStore{block: make(chan struct{})}
+	logger := NewLogger(store, Config{FlushInterval: time.Hour})
+	defer func() {
+		close(store.block)
+		logger.Close(context.Background())
+	}()
+
+	ctx, cancel := context.WithTimeout(WithLogger(context.Background(), logger), 50*time.Millisecond)
+	defer cancel()
+	if err := Record(ctx, PHIAccess(EntityRefund, "ref_1")); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+}
+
+// TestLogger_BatchesOptionalEntries tests that optional entries are written in batches of the batch size
+func TestLogger_BatchesOptionalEntries(t *testing.T) {
+	store := &fakeStore{}
+	logger := NewLogger(store, Config{BatchSize: 3, FlushInterval: time.Hour})
+
+	ctx := context.Background()
+	for i := 0; i < 7; i++ {
+		if err := logger.Log(ctx, Entry{EventType: EventPaymentChanged, EntityType: EntityPayment, EntityID: "pay_1", Action: ActionUpdate}); err != nil {
+			t.Fatalf("Expected entry to be queued, got %v", err)
+		}
+	}
+	if err := logger.Close(ctx); err != nil {
+		t.Fatalf("Expected close to flush, got %v", err)
+	}
+
+	if len(store.written()) != 7 {
+		t.Fatalf("Expected 7 entries written by close, got %d", len(store.written()))
+	}
+	for _, batch := range store.batches {
+		if len(batch) > 3 {
+			t.Errorf("Expected batches of at most 3, got %d", len(batch))
+		}
+	}
+}
+
+// TestLogger_FullBufferDropsOptionalEntries tests that a full buffer drops optional entries instead of blocking
+func TestLogger_FullBufferDropsOptionalEntries(t *testing.T) {
+	store := &fakeStore{block: make(chan struct{})}
+	logger := NewLogger(store, Config{BufferSize: 2, BatchSize: 1, FlushInterval: time.Hour})
+
+	ctx := context.Background()
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			logger.Log(ctx, Entry{EventType: EventShipmentChanged, EntityType: EntityShipment, EntityID: "shp_1", Action: ActionUpdate})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected optional entries not to block on a full buffer")
+	}
+	if logger.Dropped() == 0 {
+		t.Error("Expected entries to be dropped")
+	}
+
+	close(store.block)
+	logger.Close(ctx)
+	if err := logger.Log(ctx, PHIAccess(EntityPatient, "pt_1")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after close, got %v", err)
+	}
+}
+
+// TestRecord_WithoutLogger tests that recording without a logger on the context is a no-op
+func TestRecord_WithoutLogger(t *testing.T) {
+	if err := Record(context.Background(), PHIAccess(EntityPatient, "pt_1")); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+// TestInsertQuery tests the multi-row insert numbers entries along the chain and that empty optional columns are NULL
+func TestInsertQuery(t *testing.T) {
+	at := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	query, args, err := insertQuery("2024-01-15", 7, []Entry{
+		StatusChange("rx_1", "paid", "corr-1"),
+		{EventType: EventPHIAccessed, EntityType: EntityPatient, EntityID: "pt_1", UserID: "ops-1", Action: ActionRead, CreatedAt: at},
+	})
+	if err != nil {
+		t.Fatalf("Expected query, got %v", err)
+	}
+
+	if !strings.HasPrefix(query, "INSERT INTO audit_logs (chain_id, seq, event_type, entity_type, entity_id, user_id, action, details, ip_address, user_agent, created_at) VALUES ($1, ") {
+		t.Errorf("Unexpected query: %s", query)
+	}
+	if !strings.Contains(query, "$21, $22) RETURNING id, ") || len(args) != 22 {
+		t.Errorf("Expected 22 parameters and the stored rows returned, got %d: %s", len(args), query)
+	}
+	if args[0] != "2024-01-15" || args[1] != int64(7) || args[12] != int64(8) {
+		t.Errorf("Expected seqs 7 and 8 on chain 2024-01-15, got %v, %v and %v", args[0], args[1], args[12])
+	}
+	if args[5] != nil || args[8] != nil {
+		t.Errorf("Expected empty user and IP to be NULL, got %v and %v", args[5], args[8])
+	}
+	if details, ok := args[7].(string); !ok || !strings.Contains(details, `"correlation_id":"corr-1"`) {
+		t.Errorf("Expected details as JSON, got %v", args[7])
+	}
+	if args[16] != "ops-1" || args[18] != nil {
+		t.Errorf("Expected user ops-1 and no details on the second row, got %v and %v", args[16], args[18])
+	}
+}
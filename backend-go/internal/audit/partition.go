@@ -0,0 +1,179 @@
+// Package audit records the HIPAA audit trail in the audit_logs table
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// partitionPrefix starts the name of each monthly partition, e.g. audit_logs_2024_01
+	partitionPrefix = "audit_logs_"
+	// defaultPartition holds rows no monthly partition covers
+	defaultPartition = "audit_logs_default"
+)
+
+// Partition is a monthly partition of audit_logs, holding rows created from From until To
+type Partition struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+// MonthPartition returns the partition holding rows created in t's UTC month
+func MonthPartition(t time.Time) Partition {
+	t = t.UTC()
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return Partition{
+		Name: partitionPrefix + from.Format("2006_01"),
+		From: from,
+		To:   from.AddDate(0, 1, 0),
+	}
+}
+
+// parsePartition returns the partition with the given table name, or false for other tables
+func parsePartition(name string) (Partition, bool) {
+	month, err := time.Parse("2006_01", strings.TrimPrefix(name, partitionPrefix))
+	if err != nil || !strings.HasPrefix(name, partitionPrefix) {
+		return Partition{}, false
+	}
+	return MonthPartition(month), true
+}
+
+// Partitions returns the monthly partitions of audit_logs, oldest first
+func (s *PostgresStore) Partitions(ctx context.Context) ([]Partition, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'audit_logs'::regclass ORDER BY c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit log partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []Partition
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to list audit log partitions: %w", err)
+		}
+		if partition, ok := parsePartition(name); ok {
+			partitions = append(partitions, partition)
+		}
+	}
+	return partitions, rows.Err()
+}
+
+// EnsurePartitions creates the partitions of t's month and the months after it, up to ahead months later,
+// that do not exist yet, returning the ones it created
+func (s *PostgresStore) EnsurePartitions(ctx context.Context, t time.Time, ahead int) ([]Partition, error) {
+	existing, err := s.Partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	exists := map[string]bool{}
+	for _, partition := range existing {
+		exists[partition.Name] = true
+	}
+
+	var created []Partition
+	month := MonthPartition(t).From
+	for i := 0; i <= ahead; i++ {
+		partition := MonthPartition(month.AddDate(0, i, 0))
+		if exists[partition.Name] {
+			continue
+		}
+		if err := s.createPartition(ctx, partition); err != nil {
+			return created, err
+		}
+		created = append(created, partition)
+	}
+	return created, nil
+}
+
+// createPartition creates a monthly partition, moving the month's rows out of the default partition
+// Attaching a partition fails while the default partition holds rows it would cover, so they are moved into
+// the new table first, with inserts into the default partition held off until it is attached.
+func (s *PostgresStore) createPartition(ctx context.Context, partition Partition) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin creating partition %s: %w", partition.Name, err)
+	}
+	defer tx.Rollback()
+
+	table := pq.QuoteIdentifier(partition.Name)
+	from := pq.QuoteLiteral(partition.From.Format("2006-01-02"))
+	to := pq.QuoteLiteral(partition.To.Format("2006-01-02"))
+	statements := []string{
+		"LOCK TABLE " + defaultPartition + " IN EXCLUSIVE MODE",
+		"CREATE TABLE " + table + " (LIKE audit_logs INCLUDING DEFAULTS INCLUDING CONSTRAINTS)",
+		"CREATE UNIQUE INDEX " + pq.QuoteIdentifier(partition.Name+"_chain") + " ON " + table + " (chain_id, seq)",
+		"WITH moved AS (DELETE FROM " + defaultPartition + " WHERE created_at >= " + from + " AND created_at < " + to + " RETURNING *) " +
+			"INSERT INTO " + table + " SELECT * FROM moved",
+		"ALTER TABLE audit_logs ATTACH PARTITION " + table + " FOR VALUES FROM (" + from + ") TO (" + to + ")",
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", partition.Name, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create partition %s: %w", partition.Name, err)
+	}
+	return nil
+}
+
+// ExportPartition writes a partition's rows to w as NDJSON, one ChainRow per line in chain order,
+// returning the number of rows written
+// Rows keep their chain columns, so archived chains can still be verified.
+func (s *PostgresStore) ExportPartition(ctx context.Context, partition Partition, w io.Writer) (int64, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+chainColumns+" FROM "+pq.QuoteIdentifier(partition.Name)+
+		" ORDER BY chain_id NULLS FIRST, seq, id")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read partition %s: %w", partition.Name, err)
+	}
+	defer rows.Close()
+
+	encoder := json.NewEncoder(w)
+	var count int64
+	for rows.Next() {
+		row, err := scanChainRow(rows)
+		if err != nil {
+			return count, err
+		}
+		if err := encoder.Encode(row); err != nil {
+			return count, fmt.Errorf("failed to export partition %s: %w", partition.Name, err)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("failed to read partition %s: %w", partition.Name, err)
+	}
+	return count, nil
+}
+
+// DropPartition detaches a partition from audit_logs and drops it
+func (s *PostgresStore) DropPartition(ctx context.Context, partition Partition) error {
+	table := pq.QuoteIdentifier(partition.Name)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin dropping partition %s: %w", partition.Name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "ALTER TABLE audit_logs DETACH PARTITION "+table); err != nil {
+		return fmt.Errorf("failed to detach partition %s: %w", partition.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DROP TABLE "+table); err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", partition.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", partition.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+// Package audit records the HIPAA audit trail in the audit_logs table
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// auditColumns are the audit_logs columns written for each entry, in order
+var auditColumns = []string{"chain_id", "seq", "event_type", "entity_type", "entity_id", "user_id", "action", "details", "ip_address", "user_agent", "created_at"}
+
+// chainColumns select a row as a ChainRow, with the values as stored
+const chainColumns = `id, COALESCE(chain_id, ''), COALESCE(seq, 0), event_type, entity_type, entity_id, COALESCE(user_id, ''), action,
+	COALESCE(details::text, ''), COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at,
+	COALESCE(prev_hash, ''), COALESCE(hash, '')`
+
+// PostgresStore writes audit entries to the audit_logs table, hash chaining each row to the one before
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a store writing to the audit_logs table of the database
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// WriteBatch appends the entries to their chains in one transaction, so a batch is stored whole or not at all
+// Each chain is locked while it is extended; chains are locked in order so concurrent writers cannot deadlock.
+func (s *PostgresStore) WriteBatch(ctx context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	chains := map[string][]Entry{}
+	for _, entry := range entries {
+		entry.CreatedAt = entry.CreatedAt.UTC()
+		chainID := ChainID(entry.CreatedAt)
+		chains[chainID] = append(chains[chainID], entry)
+	}
+	chainIDs := make([]string, 0, len(chains))
+	for chainID := range chains {
+		chainIDs = append(chainIDs, chainID)
+	}
+	sort.Strings(chainIDs)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin audit transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, chainID := range chainIDs {
+		if err := appendToChain(ctx, tx, chainID, chains[chainID]); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit audit entries: %w", err)
+	}
+	return nil
+}
+
+// appendToChain inserts entries after the chain's head and links them
+// The hashes are computed over the rows PostgreSQL returns, as jsonb and timestamp columns do not store
+// exactly what was sent, and set in a second statement.
+func appendToChain(ctx context.Context, tx *sql.Tx, chainID string, entries []Entry) error {
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", "audit_logs:"+chainID); err != nil {
+		return fmt.Errorf("failed to lock audit chain %s: %w", chainID, err)
+	}
+
+	// The day's bounds let PostgreSQL skip the other months' partitions
+	from, to, err := chainDay(chainID)
+	if err != nil {
+		return err
+	}
+	head := ChainHead{ChainID: chainID, Hash: GenesisHash}
+	err = tx.QueryRowContext(ctx, `SELECT seq, hash FROM audit_logs
+		WHERE chain_id = $1 AND created_at >= $2 AND created_at < $3 ORDER BY seq DESC LIMIT 1`, chainID, from, to).Scan(&head.Seq, &head.Hash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read head of audit chain %s: %w", chainID, err)
+	}
+
+	query, args, err := insertQuery(chainID, head.Seq+1, entries)
+	if err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entries: %w", err)
+	}
+	var inserted []ChainRow
+	for rows.Next() {
+		row, err := scanChainRow(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		inserted = append(inserted, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read inserted audit entries: %w", err)
+	}
+
+	ids, prevHashes, hashes := LinkChain(head.Hash, inserted)
+	_, err = tx.ExecContext(ctx, `UPDATE audit_logs SET prev_hash = v.prev_hash, hash = v.hash
+		FROM unnest($1::bigint[], $2::text[], $3::text[]) AS v(id, prev_hash, hash)
+		WHERE audit_logs.id = v.id`,
+		pq.Array(ids), pq.Array(prevHashes), pq.Array(hashes))
+	if err != nil {
+		return fmt.Errorf("failed to link audit chain %s: %w", chainID, err)
+	}
+	return nil
+}
+
+// LinkChain sets the previous hash and hash of rows appended after a chain head, in seq order
+// It returns the rows' IDs with their hashes, for the update storing them.
+func LinkChain(headHash string, rows []ChainRow) (ids []int64, prevHashes, hashes []string) {
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Seq < rows[j].Seq })
+	prev := headHash
+	for i := range rows {
+		rows[i].PrevHash = prev
+		rows[i].Hash = rows[i].ComputeHash()
+		prev = rows[i].Hash
+
+		ids = append(ids, rows[i].ID)
+		prevHashes = append(prevHashes, rows[i].PrevHash)
+		hashes = append(hashes, rows[i].Hash)
+	}
+	return ids, prevHashes, hashes
+}
+
+// insertQuery builds the multi-row INSERT for entries appended to a chain from firstSeq, returning the stored rows
+func insertQuery(chainID string, firstSeq int64, entries []Entry) (string, []interface{}, error) {
+	var query strings.Builder
+	query.WriteString("INSERT INTO audit_logs (" + strings.Join(auditColumns, ", ") + ") VALUES ")
+
+	args := make([]interface{}, 0, len(entries)*len(auditColumns))
+	for i, entry := range entries {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString("(")
+		for j := range auditColumns {
+			if j > 0 {
+				query.WriteString(", ")
+			}
+			fmt.Fprintf(&query, "$%d", len(args)+j+1)
+		}
+		query.WriteString(")")
+
+		var details interface{}
+		if len(entry.Details) > 0 {
+			data, err := json.Marshal(entry.Details)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to marshal details of %s entry: %w", entry.EventType, err)
+			}
+			details = string(data)
+		}
+		args = append(args,
+			chainID,
+			firstSeq+int64(i),
+			string(entry.EventType),
+			string(entry.EntityType),
+			entry.EntityID,
+			nullable(entry.UserID),
+			string(entry.Action),
+			details,
+			nullable(entry.IPAddress),
+			nullable(entry.UserAgent),
+			entry.CreatedAt.UTC(),
+		)
+	}
+	query.WriteString(" RETURNING " + chainColumns)
+	return query.String(), args, nil
+}
+
+// ChainHeads returns the last row of each chain from the given chain ID on
+func (s *PostgresStore) ChainHeads(ctx context.Context, fromChainID string) ([]ChainHead, error) {
+	from, _, err := chainDay(fromChainID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT ON (chain_id) chain_id, seq, hash FROM audit_logs
+		WHERE chain_id >= $1 AND created_at >= $2 AND hash IS NOT NULL ORDER BY chain_id, seq DESC`, fromChainID, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit chain heads: %w", err)
+	}
+	defer rows.Close()
+
+	var heads []ChainHead
+	for rows.Next() {
+		var head ChainHead
+		if err := rows.Scan(&head.ChainID, &head.Seq, &head.Hash); err != nil {
+			return nil, fmt.Errorf("failed to read audit chain head: %w", err)
+		}
+		heads = append(heads, head)
+	}
+	return heads, rows.Err()
+}
+
+// Chains returns the IDs of the chains between two chain IDs (inclusive), in order
+func (s *PostgresStore) Chains(ctx context.Context, fromChainID, toChainID string) ([]string, error) {
+	from, _, err := chainDay(fromChainID)
+	if err != nil {
+		return nil, err
+	}
+	_, to, err := chainDay(toChainID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT chain_id FROM audit_logs
+		WHERE chain_id >= $1 AND chain_id <= $2 AND created_at >= $3 AND created_at < $4 ORDER BY chain_id`,
+		fromChainID, toChainID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit chains: %w", err)
+	}
+	defer rows.Close()
+
+	var chainIDs []string
+	for rows.Next() {
+		var chainID string
+		if err := rows.Scan(&chainID); err != nil {
+			return nil, fmt.Errorf("failed to list audit chains: %w", err)
+		}
+		chainIDs = append(chainIDs, chainID)
+	}
+	return chainIDs, rows.Err()
+}
+
+// ReadChain calls fn with each row of a chain in seq order, stopping at the first error fn returns
+func (s *PostgresStore) ReadChain(ctx context.Context, chainID string, fn func(ChainRow) error) error {
+	from, to, err := chainDay(chainID)
+	if err != nil {
+		return err
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT "+chainColumns+` FROM audit_logs
+		WHERE chain_id = $1 AND created_at >= $2 AND created_at < $3 ORDER BY seq`, chainID, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to read audit chain %s: %w", chainID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row, err := scanChainRow(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// UnchainedRows counts rows written before hash chaining, which cannot be verified
+func (s *PostgresStore) UnchainedRows(ctx context.Context) (int64, error) {
+	var count int64
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_logs WHERE chain_id IS NULL").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count unchained audit rows: %w", err)
+	}
+	return count, nil
+}
+
+// chainDay returns the bounds of the created_at times of a chain's rows
+func chainDay(chainID string) (time.Time, time.Time, error) {
+	day, err := time.Parse("2006-01-02", chainID)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid audit chain ID %q", chainID)
+	}
+	return day, day.AddDate(0, 0, 1), nil
+}
+
+// scanChainRow reads a row selected with chainColumns
+func scanChainRow(rows *sql.Rows) (ChainRow, error) {
+	var row ChainRow
+	err := rows.Scan(&row.ID, &row.ChainID, &row.Seq, &row.EventType, &row.EntityType, &row.EntityID, &row.UserID, &row.Action,
+		&row.Details, &row.IPAddress, &row.UserAgent, &row.CreatedAt, &row.PrevHash, &row.Hash)
+	if err != nil {
+		return ChainRow{}, fmt.Errorf("failed to read audit row: %w", err)
+	}
+	return row, nil
+}
+
+// nullable stores an empty string as NULL
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,191 @@
+// Package audit records the HIPAA audit trail in the audit_logs table
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a query cursor was not issued by Query
+var ErrInvalidCursor = errors.New("invalid audit log cursor")
+
+const (
+	defaultQueryLimit = 50
+	maxQueryLimit     = 200
+)
+
+// Filter selects audit log entries; empty fields match everything
+type Filter struct {
+	EntityType string
+	EntityID   string
+	UserID     string
+	EventType  string
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+	Cursor     string    // NextCursor of the previous page
+	Limit      int
+}
+
+// LoggedEntry is an audit log entry as stored
+type LoggedEntry struct {
+	ID         int64           `json:"id"`
+	EventType  string          `json:"event_type"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	UserID     string          `json:"user_id,omitempty"`
+	Action     string          `json:"action"`
+	Details    json.RawMessage `json:"details,omitempty"`
+	IPAddress  string          `json:"ip_address,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ChainID    string          `json:"chain_id,omitempty"`
+	Seq        int64           `json:"seq,omitempty"`
+	Hash       string          `json:"hash,omitempty"`
+}
+
+// Page is a page of audit log entries, newest first
+type Page struct {
+	Entries    []LoggedEntry `json:"entries"`
+	NextCursor string        `json:"next_cursor,omitempty"` // empty on the last page
+}
+
+// Query returns the entries matching the filter, newest first, a page at a time
+// Pages continue from a cursor (the created_at and id of the last entry returned) rather than an offset, so
+// entries written while a caller pages through do not shift the pages.
+func (s *PostgresStore) Query(ctx context.Context, filter Filter) (*Page, error) {
+	query, args, err := filterQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	page := &Page{Entries: []LoggedEntry{}}
+	for rows.Next() {
+		row, err := scanChainRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		entry := LoggedEntry{
+			ID:         row.ID,
+			EventType:  row.EventType,
+			EntityType: row.EntityType,
+			EntityID:   row.EntityID,
+			UserID:     row.UserID,
+			Action:     row.Action,
+			IPAddress:  row.IPAddress,
+			UserAgent:  row.UserAgent,
+			CreatedAt:  row.CreatedAt.UTC(),
+			ChainID:    row.ChainID,
+			Seq:        row.Seq,
+			Hash:       row.Hash,
+		}
+		if row.Details != "" {
+			entry.Details = json.RawMessage(row.Details)
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+
+	// One row past the limit is read to tell whether there is a next page
+	limit := queryLimit(filter.Limit)
+	if len(page.Entries) > limit {
+		page.Entries = page.Entries[:limit]
+		last := page.Entries[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// filterQuery builds the query for a page of entries matching the filter
+func filterQuery(filter Filter) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, values ...interface{}) {
+		for _, value := range values {
+			args = append(args, value)
+			condition = strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1)
+		}
+		conditions = append(conditions, condition)
+	}
+
+	if filter.EntityType != "" {
+		where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		where("entity_id = ?", filter.EntityID)
+	}
+	if filter.UserID != "" {
+		where("user_id = ?", filter.UserID)
+	}
+	if filter.EventType != "" {
+		where("event_type = ?", filter.EventType)
+	}
+	// created_at bounds also let PostgreSQL skip partitions outside the range
+	if !filter.From.IsZero() {
+		where("created_at >= ?", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		where("created_at < ?", filter.To.UTC())
+	}
+	if filter.Cursor != "" {
+		createdAt, id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		where("(created_at, id) < (?::timestamp, ?)", createdAt, id)
+	}
+
+	query := "SELECT " + chainColumns + " FROM audit_logs"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, queryLimit(filter.Limit)+1)
+	query += " ORDER BY created_at DESC, id DESC LIMIT $" + strconv.Itoa(len(args))
+	return query, args, nil
+}
+
+// queryLimit returns the page size for a requested limit
+func queryLimit(limit int) int {
+	if limit <= 0 || limit > maxQueryLimit {
+		return defaultQueryLimit
+	}
+	return limit
+}
+
+// encodeCursor returns the cursor continuing after the entry created at createdAt with the given ID
+func encodeCursor(createdAt time.Time, id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(createdAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(id, 10)))
+}
+
+// decodeCursor returns the created_at and ID a cursor continues after
+func decodeCursor(cursor string) (time.Time, int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	createdAtText, idText, ok := strings.Cut(string(data), "|")
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtText)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(idText, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return createdAt.UTC(), id, nil
+}
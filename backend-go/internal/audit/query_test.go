@@ -0,0 +1,62 @@
+// Package audit provides audit log query and partition tests
+package audit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFilterQuery tests that filters become conditions on numbered parameters, with one row past the page
+func TestFilterQuery(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	query, args, err := filterQuery(Filter{EntityType: "patient", EntityID: "pt_1", EventType: "phi_accessed", From: from, To: to, Limit: 20})
+	if err != nil {
+		t.Fatalf("Expected query, got %v", err)
+	}
+
+	if !strings.Contains(query, "WHERE entity_type = $1 AND entity_id = $2 AND event_type = $3 AND created_at >= $4 AND created_at < $5") {
+		t.Errorf("Unexpected conditions: %s", query)
+	}
+	if !strings.HasSuffix(query, "ORDER BY created_at DESC, id DESC LIMIT $6") || len(args) != 6 || args[5] != 21 {
+		t.Errorf("Expected a limit of 21, got %v: %s", args, query)
+	}
+}
+
+// TestFilterQuery_Cursor tests that a cursor continues after the entry it was issued for
+func TestFilterQuery_Cursor(t *testing.T) {
+	createdAt := time.Date(2024, 1, 15, 12, 30, 0, 123456000, time.UTC)
+	query, args, err := filterQuery(Filter{UserID: "ops-1", Cursor: encodeCursor(createdAt, 42)})
+	if err != nil {
+		t.Fatalf("Expected query, got %v", err)
+	}
+
+	if !strings.Contains(query, "WHERE user_id = $1 AND (created_at, id) < ($2::timestamp, $3)") {
+		t.Errorf("Unexpected conditions: %s", query)
+	}
+	if !args[1].(time.Time).Equal(createdAt) || args[2] != int64(42) || args[3] != defaultQueryLimit+1 {
+		t.Errorf("Expected the cursor's position and the default limit, got %v", args)
+	}
+
+	if _, _, err := filterQuery(Filter{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+// TestMonthPartition tests partition names and bounds, and that only monthly partitions are parsed
+func TestMonthPartition(t *testing.T) {
+	partition := MonthPartition(time.Date(2024, 12, 31, 23, 0, 0, 0, time.FixedZone("EST", -5*3600)))
+	if partition.Name != "audit_logs_2025_01" || !partition.From.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) ||
+		!partition.To.Equal(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected January 2025 in UTC, got %+v", partition)
+	}
+
+	if parsed, ok := parsePartition("audit_logs_2025_01"); !ok || parsed != partition {
+		t.Errorf("Expected the partition back, got %+v", parsed)
+	}
+	if _, ok := parsePartition("audit_logs_default"); ok {
+		t.Error("Expected the default partition not to be a monthly partition")
+	}
+}
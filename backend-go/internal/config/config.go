@@ -3,6 +3,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 )
 
 // Config holds all application configuration
@@ -23,10 +25,84 @@ type Config struct {
 	MinIOEndpoint  string
 	MinIOAccessKey string
 	MinIOSecretKey string
+	MinIOUseSSL    bool
 
 	// SMTP
-	SMTPHost string
-	SMTPPort string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string // empty for relays without authentication, such as MailDev
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Patient notifications
+	NotificationMaxAttempts int    // sends on a channel before falling back to the next one
+	NotificationSupport     string // how patients reach the pharmacy team, shown in every email
+
+	// SMS
+	SMSProvider       string // "twilio" or "fake"
+	TwilioAPIBase     string // Twilio API base URL; a local stub in development
+	TwilioAccountSID  string
+	TwilioAuthToken   string // also signs Twilio webhooks
+	SMSFromNumber     string // E.164 sender number or "MG..." messaging service SID
+	SMSWebhookBaseURL string // public base URL Twilio posts webhooks to, e.g. "https://api.philmymeds.example"
+
+	// Authentication
+	JWTSecret string
+
+	// Pharmacy routing defaults (overridden per drug or sponsor program by routing_policies)
+	RoutingMode string // "auto" or "manual"
+	RoutingTopN int    // selections outside the top N recommendations require an override reason
+
+	// Claims adjudication
+	AdjudicationMode string // "pharmacy" (wait for results callback) or "simulator"
+
+	// Copay payments
+	PaymentProvider      string // "stripe" or "fake"
+	StripeAPIBase        string // Stripe API base URL; a local stripe-mock in development
+	StripeSecretKey      string
+	PaymentLinkTTLHours  int
+	PaymentSuccessURL    string
+	PaymentCancelURL     string
+	PaymentWebhookSecret string // signs provider webhooks (Stripe "whsec_..." endpoint secret)
+
+	// Shipping labels
+	ShippingCarrier        string // "shippo" or "fake"
+	ShippoAPIBase          string // Shippo API base URL; a local stub in development
+	ShippoAPIToken         string
+	ShippingMaxTransitDays int    // delivery deadline used for rate shopping
+	ColdChainCutoff        string // "15:04" pharmacy-local cut-off for refrigerated parcels, unless the pharmacy sets its own
+
+	// Delivery tracking
+	CarrierWebhookSecret        string // signs carrier tracking webhooks
+	TrackingPollIntervalMinutes int    // how often the scheduler polls the carrier for shipments in transit
+
+	// Processed-event ledger (idempotent event handling)
+	ProcessedEventRetentionHours int // how long handled event IDs are remembered; at least the Kafka retention
+	ProcessedEventLeaseMinutes   int // how long a handler's claim lasts before a crashed worker's event is taken over
+
+	// Packing slips and fallback labels
+	ShippingDocumentsBucket string
+	DocumentURLTTLMinutes   int // lifetime of signed document links
+
+	// Audit trail
+	AuditBufferSize          int // entries queued before optional ones are dropped
+	AuditBatchSize           int // most entries inserted at once
+	AuditFlushIntervalMS     int // longest an optional entry waits to be written
+	AuditWriteTimeoutSeconds int // limit on each batch insert
+
+	// Audit chain checkpoints
+	AuditCheckpointBucket          string
+	AuditCheckpointKey             string // secret the checkpoint signing key is derived from
+	AuditCheckpointIntervalMinutes int    // how often the scheduler anchors chain heads
+
+	// Audit partitions and retention
+	AuditPartitionsAhead int // monthly partitions created in advance
+	AuditRetentionMonths int // full months kept in PostgreSQL before archiving to MinIO; 0 keeps everything
+	AuditArchiveBucket   string
+
+	// Fulfillment sagas
+	SagaStepTimeouts            string // overrides of the default step deadlines, e.g. "payment=72h,shipping=30m"
+	SagaCompensationMaxAttempts int    // tries of a compensating action before ops are left to undo it
 }
 
 // Load reads configuration from environment variables
@@ -41,11 +117,74 @@ func Load() *Config {
 		MinIOEndpoint:  getEnv("MINIO_ENDPOINT", "localhost:9000"),
 		MinIOAccessKey: getEnv("MINIO_ACCESS_KEY", "minioadmin"),
 		MinIOSecretKey: getEnv("MINIO_SECRET_KEY", "minioadmin"),
+		MinIOUseSSL:    getEnv("MINIO_USE_SSL", "false") == "true",
 		SMTPHost:       getEnv("SMTP_HOST", "localhost"),
 		SMTPPort:       getEnv("SMTP_PORT", "1025"),
+		SMTPUsername:   getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:   getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:       getEnv("SMTP_FROM", "PhilMyMeds <no-reply@philmymeds.local>"),
+		JWTSecret:      getEnv("JWT_SECRET", "dev-jwt-secret-change-me"),
+		RoutingMode:    getEnv("ROUTING_MODE", "auto"),
+		RoutingTopN:    getEnvInt("ROUTING_TOP_N", 3),
+
+		AdjudicationMode: getEnv("ADJUDICATION_MODE", "pharmacy"),
+
+		NotificationMaxAttempts: getEnvInt("NOTIFICATION_MAX_ATTEMPTS", 5),
+		NotificationSupport:     getEnv("NOTIFICATION_SUPPORT", "PhilMyMeds patient support at support@philmymeds.local"),
+
+		SMSProvider:       getEnv("SMS_PROVIDER", "fake"),
+		TwilioAPIBase:     getEnv("TWILIO_API_BASE", "https://api.twilio.com"),
+		TwilioAccountSID:  getEnv("TWILIO_ACCOUNT_SID", ""),
+		TwilioAuthToken:   getEnv("TWILIO_AUTH_TOKEN", "twilio_dev_change_me"),
+		SMSFromNumber:     getEnv("SMS_FROM_NUMBER", "+15550100000"),
+		SMSWebhookBaseURL: getEnv("SMS_WEBHOOK_BASE_URL", "http://localhost:8080"),
+
+		PaymentProvider:      getEnv("PAYMENT_PROVIDER", "stripe"),
+		StripeAPIBase:        getEnv("STRIPE_API_BASE", "http://localhost:12111"),
+		StripeSecretKey:      getEnv("STRIPE_SECRET_KEY", "sk_test_123"),
+		PaymentLinkTTLHours:  getEnvInt("PAYMENT_LINK_TTL_HOURS", 24),
+		PaymentSuccessURL:    getEnv("PAYMENT_SUCCESS_URL", "http://localhost:5173/payment/success"),
+		PaymentCancelURL:     getEnv("PAYMENT_CANCEL_URL", "http://localhost:5173/payment/cancelled"),
+		PaymentWebhookSecret: getEnv("PAYMENT_WEBHOOK_SECRET", "whsec_dev_change_me"),
+
+		ShippingCarrier:        getEnv("SHIPPING_CARRIER", "fake"),
+		ShippoAPIBase:          getEnv("SHIPPO_API_BASE", "https://api.goshippo.com"),
+		ShippoAPIToken:         getEnv("SHIPPO_API_TOKEN", ""),
+		ShippingMaxTransitDays: getEnvInt("SHIPPING_MAX_TRANSIT_DAYS", 3),
+		ColdChainCutoff:        getEnv("COLD_CHAIN_CUTOFF", "14:00"),
+
+		CarrierWebhookSecret:        getEnv("CARRIER_WEBHOOK_SECRET", "carrier_whsec_dev_change_me"),
+		TrackingPollIntervalMinutes: getEnvInt("TRACKING_POLL_INTERVAL_MINUTES", 30),
+
+		ProcessedEventRetentionHours: getEnvInt("PROCESSED_EVENT_RETENTION_HOURS", 168),
+		ProcessedEventLeaseMinutes:   getEnvInt("PROCESSED_EVENT_LEASE_MINUTES", 5),
+
+		ShippingDocumentsBucket: getEnv("SHIPPING_DOCUMENTS_BUCKET", "shipping-labels"),
+		DocumentURLTTLMinutes:   getEnvInt("DOCUMENT_URL_TTL_MINUTES", 15),
+
+		AuditBufferSize:          getEnvInt("AUDIT_BUFFER_SIZE", 1000),
+		AuditBatchSize:           getEnvInt("AUDIT_BATCH_SIZE", 100),
+		AuditFlushIntervalMS:     getEnvInt("AUDIT_FLUSH_INTERVAL_MS", 500),
+		AuditWriteTimeoutSeconds: getEnvInt("AUDIT_WRITE_TIMEOUT_SECONDS", 5),
+
+		AuditCheckpointBucket:          getEnv("AUDIT_CHECKPOINT_BUCKET", "audit-checkpoints"),
+		AuditCheckpointKey:             getEnv("AUDIT_CHECKPOINT_KEY", "dev-audit-checkpoint-key-change-me"),
+		AuditCheckpointIntervalMinutes: getEnvInt("AUDIT_CHECKPOINT_INTERVAL_MINUTES", 60),
+
+		AuditPartitionsAhead: getEnvInt("AUDIT_PARTITIONS_AHEAD", 3),
+		AuditRetentionMonths: getEnvInt("AUDIT_RETENTION_MONTHS", 12),
+		AuditArchiveBucket:   getEnv("AUDIT_ARCHIVE_BUCKET", "audit-archive"),
+
+		SagaStepTimeouts:            getEnv("SAGA_STEP_TIMEOUTS", ""),
+		SagaCompensationMaxAttempts: getEnvInt("SAGA_COMPENSATION_MAX_ATTEMPTS", 5),
 	}
 }
 
+// SMSStatusCallbackURL is where the SMS provider posts delivery receipts
+func (c *Config) SMSStatusCallbackURL() string {
+	return strings.TrimRight(c.SMSWebhookBaseURL, "/") + "/api/v1/webhooks/sms/status"
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -53,3 +192,13 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt retrieves an integer environment variable or returns a default value
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
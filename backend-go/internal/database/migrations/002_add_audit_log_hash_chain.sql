@@ -0,0 +1,16 @@
+-- Migration: Hash chain audit_logs rows
+-- Each row is hashed over its content and the previous row's hash. Rows are chained per UTC day of
+-- created_at (chain_id 'YYYY-MM-DD'), numbered from 1 by seq, so a changed, removed or inserted row
+-- breaks every later link. Rows written before this migration have no chain.
+
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS chain_id VARCHAR(10);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS seq BIGINT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS prev_hash CHAR(64);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS hash CHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_chain ON audit_logs(chain_id, seq);
+
+COMMENT ON COLUMN audit_logs.chain_id IS 'Hash chain the row belongs to: the UTC day of created_at';
+COMMENT ON COLUMN audit_logs.seq IS 'Position of the row in its chain, from 1';
+COMMENT ON COLUMN audit_logs.prev_hash IS 'Hash of the previous row in the chain; 64 zeros for the first';
+COMMENT ON COLUMN audit_logs.hash IS 'SHA-256 (hex) over the row content and prev_hash';
@@ -0,0 +1,79 @@
+-- Migration: Partition audit_logs by month
+-- audit_logs is recreated partitioned by range of created_at, one partition per calendar month
+-- (audit_logs_YYYY_MM). The scheduler creates partitions ahead of time and archives those past the
+-- retention period to MinIO before dropping them. Rows no partition covers land in audit_logs_default,
+-- which the scheduler empties into the month's partition when it creates it. Existing rows are copied
+-- into partitions for their months.
+
+ALTER TABLE audit_logs RENAME TO audit_logs_unpartitioned;
+ALTER TABLE audit_logs_unpartitioned RENAME CONSTRAINT audit_logs_pkey TO audit_logs_unpartitioned_pkey;
+DROP INDEX IF EXISTS idx_audit_logs_entity;
+DROP INDEX IF EXISTS idx_audit_logs_event_type;
+DROP INDEX IF EXISTS idx_audit_logs_created_at;
+DROP INDEX IF EXISTS idx_audit_logs_user_id;
+DROP INDEX IF EXISTS idx_audit_logs_chain;
+
+CREATE TABLE audit_logs (
+    id BIGINT NOT NULL DEFAULT nextval('audit_logs_id_seq'),
+    event_type VARCHAR(100) NOT NULL,
+    entity_type VARCHAR(100) NOT NULL,
+    entity_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255),
+    action VARCHAR(50) NOT NULL,
+    details JSONB,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    chain_id VARCHAR(10),
+    seq BIGINT,
+    prev_hash CHAR(64),
+    hash CHAR(64),
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+-- The sequence would otherwise be dropped with the old table
+ALTER SEQUENCE audit_logs_id_seq OWNED BY audit_logs.id;
+
+CREATE INDEX idx_audit_logs_entity ON audit_logs(entity_type, entity_id);
+CREATE INDEX idx_audit_logs_event_type ON audit_logs(event_type);
+CREATE INDEX idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX idx_audit_logs_user_id ON audit_logs(user_id);
+
+-- (chain_id, seq) is unique per partition: a chain is one day, so it never spans two partitions
+CREATE TABLE audit_logs_default PARTITION OF audit_logs DEFAULT;
+CREATE UNIQUE INDEX audit_logs_default_chain ON audit_logs_default(chain_id, seq);
+
+DO $$
+DECLARE
+    partition_month DATE;
+    partition_name TEXT;
+BEGIN
+    FOR partition_month IN
+        SELECT DISTINCT date_trunc('month', created_at)::date FROM audit_logs_unpartitioned
+        UNION
+        SELECT date_trunc('month', NOW() AT TIME ZONE 'UTC')::date
+    LOOP
+        partition_name := 'audit_logs_' || to_char(partition_month, 'YYYY_MM');
+        EXECUTE format('CREATE TABLE %I PARTITION OF audit_logs FOR VALUES FROM (%L) TO (%L)',
+            partition_name, partition_month, (partition_month + INTERVAL '1 month')::date);
+        EXECUTE format('CREATE UNIQUE INDEX %I ON %I(chain_id, seq)', partition_name || '_chain', partition_name);
+    END LOOP;
+END $$;
+
+INSERT INTO audit_logs (id, event_type, entity_type, entity_id, user_id, action, details, ip_address, user_agent,
+    created_at, chain_id, seq, prev_hash, hash)
+SELECT id, event_type, entity_type, entity_id, user_id, action, details, ip_address, user_agent,
+    created_at, chain_id, seq, prev_hash, hash
+FROM audit_logs_unpartitioned;
+
+DROP TABLE audit_logs_unpartitioned;
+
+COMMENT ON TABLE audit_logs IS 'Stores audit trail for all important system events, partitioned by month of created_at';
+COMMENT ON COLUMN audit_logs.event_type IS 'Type of event (e.g., prescription_created, patient_enrolled)';
+COMMENT ON COLUMN audit_logs.entity_type IS 'Type of entity affected (e.g., prescription, patient, pharmacy)';
+COMMENT ON COLUMN audit_logs.entity_id IS 'ID of the affected entity';
+COMMENT ON COLUMN audit_logs.details IS 'Additional event details in JSON format';
+COMMENT ON COLUMN audit_logs.chain_id IS 'Hash chain the row belongs to: the UTC day of created_at';
+COMMENT ON COLUMN audit_logs.seq IS 'Position of the row in its chain, from 1';
+COMMENT ON COLUMN audit_logs.prev_hash IS 'Hash of the previous row in the chain; 64 zeros for the first';
+COMMENT ON COLUMN audit_logs.hash IS 'SHA-256 (hex) over the row content and prev_hash';
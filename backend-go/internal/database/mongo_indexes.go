@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -23,6 +24,78 @@ func (mc *MongoClient) CreateIndexes(ctx context.Context) error {
 		return fmt.Errorf("failed to create patient indexes: %w", err)
 	}
 
+	if err := mc.createDrugIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create drug indexes: %w", err)
+	}
+
+	if err := mc.createRoutingPolicyIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create routing policy indexes: %w", err)
+	}
+
+	if err := mc.createPharmacyContractIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create pharmacy contract indexes: %w", err)
+	}
+
+	if err := mc.createFormularyIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create formulary indexes: %w", err)
+	}
+
+	if err := mc.createPlanRuleIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create plan rule indexes: %w", err)
+	}
+
+	if err := mc.createAdjudicationIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create adjudication indexes: %w", err)
+	}
+
+	if err := mc.createManufacturerProgramIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create manufacturer program indexes: %w", err)
+	}
+
+	if err := mc.createPriorAuthorizationIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create prior authorization indexes: %w", err)
+	}
+
+	if err := mc.createDURIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create DUR indexes: %w", err)
+	}
+
+	if err := mc.createVerificationIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create verification indexes: %w", err)
+	}
+
+	if err := mc.createPaymentIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create payment indexes: %w", err)
+	}
+
+	if err := mc.createRefundIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create refund indexes: %w", err)
+	}
+
+	if err := mc.createPaymentWebhookEventIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create payment webhook event indexes: %w", err)
+	}
+
+	if err := mc.createShipmentIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create shipment indexes: %w", err)
+	}
+
+	if err := mc.createNotificationIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create notification indexes: %w", err)
+	}
+
+	if err := mc.createEventLogIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create event log indexes: %w", err)
+	}
+
+	if err := mc.createSagaIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create saga indexes: %w", err)
+	}
+
+	if err := mc.createCorrelationIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create correlation indexes: %w", err)
+	}
+
 	return nil
 }
 
@@ -89,6 +162,11 @@ func (mc *MongoClient) createPatientIndexes(ctx context.Context) error {
 			Keys:    map[string]interface{}{"phone": 1},
 			Options: options.Index().SetName("idx_phone"),
 		},
+		{
+			// STOP and START replies update the consent of every patient texted at the number
+			Keys:    map[string]interface{}{"communication.sms_number": 1},
+			Options: options.Index().SetSparse(true).SetName("idx_communication_sms_number"),
+		},
 		{
 			Keys:    map[string]interface{}{"date_of_birth": 1},
 			Options: options.Index().SetName("idx_date_of_birth"),
@@ -102,3 +180,459 @@ func (mc *MongoClient) createPatientIndexes(ctx context.Context) error {
 	_, err := collection.Indexes().CreateMany(ctx, indexes)
 	return err
 }
+
+// createDrugIndexes creates indexes for the drugs collection
+func (mc *MongoClient) createDrugIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("drugs")
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    map[string]interface{}{"ndc": 1},
+			Options: options.Index().SetUnique(true).SetName("idx_ndc"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createRoutingPolicyIndexes creates indexes for the routing_policies collection
+func (mc *MongoClient) createRoutingPolicyIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("routing_policies")
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "scope", Value: 1}, {Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_scope_key"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createPharmacyContractIndexes creates indexes for the pharmacy_contracts collection
+func (mc *MongoClient) createPharmacyContractIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("pharmacy_contracts")
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "bin", Value: 1},
+				{Key: "pcn", Value: 1},
+				{Key: "group_id", Value: 1},
+				{Key: "pharmacy_ncpdp_id", Value: 1},
+				{Key: "effective_from", Value: 1},
+			},
+			Options: options.Index().SetUnique(true).SetName("idx_plan_pharmacy_effective"),
+		},
+		{
+			Keys:    map[string]interface{}{"pharmacy_ncpdp_id": 1},
+			Options: options.Index().SetName("idx_pharmacy_ncpdp_id"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createFormularyIndexes creates indexes for the formularies collection
+func (mc *MongoClient) createFormularyIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("formularies")
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "bin", Value: 1},
+				{Key: "ndc", Value: 1},
+				{Key: "pcn", Value: 1},
+				{Key: "group_id", Value: 1},
+				{Key: "effective_from", Value: 1},
+			},
+			Options: options.Index().SetUnique(true).SetName("idx_bin_ndc_plan_effective"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createPlanRuleIndexes creates indexes for the plan_rules collection
+func (mc *MongoClient) createPlanRuleIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("plan_rules")
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "bin", Value: 1}, {Key: "pcn", Value: 1}, {Key: "group_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_plan"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createAdjudicationIndexes creates indexes for the adjudications collection
+func (mc *MongoClient) createAdjudicationIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("adjudications")
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    map[string]interface{}{"prescription_id": 1},
+			Options: options.Index().SetName("idx_prescription_id"),
+		},
+		{
+			// Fill history lookups for refill-too-soon checks
+			Keys:    bson.D{{Key: "member_id", Value: 1}, {Key: "ndc", Value: 1}, {Key: "result.adjudicated_at", Value: -1}},
+			Options: options.Index().SetName("idx_member_ndc_adjudicated_at"),
+		},
+		{
+			// Pharmacy result callbacks are idempotent by claim ID
+			Keys:    bson.D{{Key: "pharmacy_id", Value: 1}, {Key: "result.claim_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_pharmacy_claim_id"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createManufacturerProgramIndexes creates indexes for the manufacturer_programs collection
+func (mc *MongoClient) createManufacturerProgramIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("manufacturer_programs")
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    map[string]interface{}{"program_id": 1},
+			Options: options.Index().SetUnique(true).SetName("idx_program_id"),
+		},
+		{
+			Keys:    bson.D{{Key: "ndcs", Value: 1}, {Key: "active", Value: 1}},
+			Options: options.Index().SetName("idx_ndcs_active"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createPriorAuthorizationIndexes creates indexes for the prior_authorizations collection
+func (mc *MongoClient) createPriorAuthorizationIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("prior_authorizations")
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    map[string]interface{}{"prescription_id": 1},
+			Options: options.Index().SetName("idx_prescription_id"),
+		},
+		{
+			// Ops work queue by status
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "updated_at", Value: -1}},
+			Options: options.Index().SetName("idx_status_updated_at"),
+		},
+		{
+			// Approval lookups when adjudicating the member's later fills
+			Keys:    bson.D{{Key: "member_id", Value: 1}, {Key: "ndc", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetName("idx_member_ndc_status"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createDURIndexes creates indexes for the DUR review queue, its reference tables and the
+// prescription lookups drug utilization review makes
+func (mc *MongoClient) createDURIndexes(ctx context.Context) error {
+	collections := map[string][]mongo.IndexModel{
+		"dur_reviews": {
+			{
+				Keys:    bson.D{{Key: "prescription_id", Value: 1}, {Key: "status", Value: 1}},
+				Options: options.Index().SetName("idx_prescription_status"),
+			},
+			{
+				// Pharmacist work queue, most severe and oldest first
+				Keys:    bson.D{{Key: "status", Value: 1}, {Key: "severity_rank", Value: -1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetName("idx_status_severity_created_at"),
+			},
+		},
+		"dur_interactions": {
+			{
+				Keys:    bson.D{{Key: "a.type", Value: 1}, {Key: "a.value", Value: 1}, {Key: "b.type", Value: 1}, {Key: "b.value", Value: 1}},
+				Options: options.Index().SetUnique(true).SetName("idx_a_b"),
+			},
+			{
+				Keys:    bson.D{{Key: "b.value", Value: 1}},
+				Options: options.Index().SetName("idx_b_value"),
+			},
+		},
+		"dur_duplications": {
+			{
+				Keys:    bson.D{{Key: "class", Value: 1}},
+				Options: options.Index().SetUnique(true).SetName("idx_class"),
+			},
+		},
+		"dur_allergy_rules": {
+			{
+				Keys:    bson.D{{Key: "allergen", Value: 1}, {Key: "key.type", Value: 1}, {Key: "key.value", Value: 1}},
+				Options: options.Index().SetUnique(true).SetName("idx_allergen_key"),
+			},
+		},
+		"prescriptions": {
+			{
+				// The patient's other prescriptions, before and after enrollment links the patient record
+				Keys:    bson.D{{Key: "patient.id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("idx_patient_ref_created_at"),
+			},
+			{
+				Keys:    bson.D{{Key: "patient_id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("idx_patient_id_created_at"),
+			},
+		},
+	}
+
+	for name, indexes := range collections {
+		if _, err := mc.GetCollection(name).Indexes().CreateMany(ctx, indexes); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// createVerificationIndexes creates indexes for the verifications collection
+func (mc *MongoClient) createVerificationIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("verifications")
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "prescription_id", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetName("idx_prescription_status"),
+		},
+		{
+			// Pharmacist work queue, oldest first
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("idx_status_created_at"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createPaymentIndexes creates indexes for the payments collection
+func (mc *MongoClient) createPaymentIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("payments")
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "prescription_id", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetName("idx_prescription_status"),
+		},
+		{
+			// Provider webhooks and status polling look payments up by checkout session
+			Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "provider_session_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_provider_session_id"),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "link_expires_at", Value: 1}},
+			Options: options.Index().SetName("idx_status_link_expires_at"),
+		},
+		{
+			// payment_intent.* webhooks only carry the payment intent
+			Keys:    bson.D{{Key: "provider_payment_intent_id", Value: 1}},
+			Options: options.Index().SetSparse(true).SetName("idx_provider_payment_intent_id"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createRefundIndexes creates indexes for the refunds collection
+func (mc *MongoClient) createRefundIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("refunds")
+
+	indexes := []mongo.IndexModel{
+		{
+			// Refundable amounts are computed from a payment's outstanding refunds
+			Keys:    bson.D{{Key: "payment_id", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetName("idx_payment_status"),
+		},
+		{
+			// Approval queue
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "updated_at", Value: -1}},
+			Options: options.Index().SetName("idx_status_updated_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "prescription_id", Value: 1}},
+			Options: options.Index().SetName("idx_prescription_id"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createPaymentWebhookEventIndexes creates indexes for the payment_webhook_events collection
+// Documents are keyed by provider event ID; the TTL outlasts the provider's retry window
+func (mc *MongoClient) createPaymentWebhookEventIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("payment_webhook_events")
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "received_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(30 * 24 * 60 * 60).SetName("idx_received_at_ttl"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createShipmentIndexes creates indexes for the shipments collection
+func (mc *MongoClient) createShipmentIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("shipments")
+
+	indexes := []mongo.IndexModel{
+		{
+			// One live shipment per prescription; voided labels are kept for audit
+			Keys:    bson.D{{Key: "prescription_id", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetName("idx_prescription_status"),
+		},
+		{
+			// Carrier tracking updates are matched by tracking number
+			Keys:    bson.D{{Key: "tracking_number", Value: 1}},
+			Options: options.Index().SetName("idx_tracking_number"),
+		},
+		{
+			// Replacement lookup for cold chain shipments reported out of range
+			Keys:    bson.D{{Key: "replaces_shipment_id", Value: 1}},
+			Options: options.Index().SetSparse(true).SetName("idx_replaces_shipment_id"),
+		},
+		{
+			// Scheduler tracking poll: shipments in transit, least recently tracked first
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "last_tracked_at", Value: 1}},
+			Options: options.Index().SetName("idx_status_last_tracked_at"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createNotificationIndexes creates indexes for the notifications collection
+func (mc *MongoClient) createNotificationIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("notifications")
+
+	indexes := []mongo.IndexModel{
+		{
+			// One notification per event and template, so redelivered events are not sent twice
+			Keys:    bson.D{{Key: "dedupe_key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_dedupe_key"),
+		},
+		{
+			// Scheduler retries: pending and retrying notifications, earliest due first
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+			Options: options.Index().SetName("idx_status_next_attempt_at"),
+		},
+		{
+			// Notification history for a prescription
+			Keys:    bson.D{{Key: "prescription_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_prescription_created_at"),
+		},
+		{
+			// SMS delivery receipts are matched to the notification by provider message ID
+			Keys:    bson.D{{Key: "receipts.provider_message_id", Value: 1}},
+			Options: options.Index().SetSparse(true).SetName("idx_receipts_provider_message_id"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createEventLogIndexes creates indexes for the event_log collection
+func (mc *MongoClient) createEventLogIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("event_log")
+
+	indexes := []mongo.IndexModel{
+		{
+			// One entry per event, so a redelivered event is logged once
+			Keys:    bson.D{{Key: "event_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_event_id"),
+		},
+		{
+			// Correlation trace: every event of an intake request in order
+			Keys:    bson.D{{Key: "correlation_id", Value: 1}, {Key: "occurred_at", Value: 1}},
+			Options: options.Index().SetName("idx_correlation_occurred_at"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// createSagaIndexes creates indexes for the sagas collection
+func (mc *MongoClient) createSagaIndexes(ctx context.Context) error {
+	collection := mc.GetCollection("sagas")
+
+	indexes := []mongo.IndexModel{
+		{
+			// One saga per prescription, so workers racing to start it create one
+			Keys:    bson.D{{Key: "prescription_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_prescription_id"),
+		},
+		{
+			// Scheduler sweep: running sagas past their step's deadline
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "deadline", Value: 1}},
+			Options: options.Index().SetName("idx_status_deadline"),
+		},
+		{
+			// Scheduler sweep: compensations due a retry
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "next_compensation_at", Value: 1}},
+			Options: options.Index().SetName("idx_status_next_compensation_at"),
+		},
+		{
+			// Ops listing by status, most recently updated first
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "updated_at", Value: -1}},
+			Options: options.Index().SetName("idx_status_updated_at"),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// correlatedCollections are the collections whose records carry the correlation ID of the flow that wrote them
+var correlatedCollections = []string{
+	"prescriptions",
+	"adjudications",
+	"prior_authorizations",
+	"dur_reviews",
+	"payments",
+	"verifications",
+	"refunds",
+	"shipments",
+	"notifications",
+	"sagas",
+}
+
+// createCorrelationIndexes indexes correlation_id on every collection the correlation trace reads
+func (mc *MongoClient) createCorrelationIndexes(ctx context.Context) error {
+	for _, name := range correlatedCollections {
+		_, err := mc.GetCollection(name).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "correlation_id", Value: 1}},
+			Options: options.Index().SetSparse(true).SetName("idx_correlation_id"),
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	// A prescription's status changes can continue a flow other than the intake's
+	_, err := mc.GetCollection("prescriptions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "status_history.correlation_id", Value: 1}},
+		Options: options.Index().SetName("idx_status_history_correlation_id"),
+	})
+	return err
+}
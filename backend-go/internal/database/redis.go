@@ -3,12 +3,16 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrKeyNotFound is returned by Get when the key does not exist
+var ErrKeyNotFound = errors.New("key does not exist")
+
 // RedisClient wraps the Redis client
 type RedisClient struct {
 	Client *redis.Client
@@ -54,7 +58,7 @@ func (rc *RedisClient) Close() error {
 func (rc *RedisClient) Get(ctx context.Context, key string) (string, error) {
 	val, err := rc.Client.Get(ctx, key).Result()
 	if err == redis.Nil {
-		return "", fmt.Errorf("key %s does not exist", key)
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, key)
 	}
 	if err != nil {
 		return "", fmt.Errorf("failed to get key %s: %w", key, err)
@@ -123,3 +127,21 @@ func (rc *RedisClient) IncrementBy(ctx context.Context, key string, value int64)
 	}
 	return val, nil
 }
+
+// deleteIfValueScript deletes a key only while it still holds the expected value
+var deleteIfValueScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// DeleteIfValue deletes a key only if it still holds value (atomic operation)
+// Used to release a SetNX lock without releasing one another owner took over after it expired
+func (rc *RedisClient) DeleteIfValue(ctx context.Context, key string, value string) (bool, error) {
+	deleted, err := deleteIfValueScript.Run(ctx, rc.Client, []string{key}, value).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete key %s: %w", key, err)
+	}
+	return deleted > 0, nil
+}
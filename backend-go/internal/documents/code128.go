@@ -0,0 +1,98 @@
+// Package documents renders shipping documents (packing slips and labels) as PDF
+package documents
+
+import (
+	"fmt"
+)
+
+// Code 128 symbol values with special meaning
+const (
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+)
+
+// code128Patterns are the bar/space widths of each symbol value; all but stop span 11 modules
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+// Code128QuietZone is the blank margin, in modules, required either side of the barcode
+const Code128QuietZone = 10
+
+// Code128 encodes data as a Code 128 barcode and returns alternating bar and space widths in modules
+// All-digit data of even length uses code set C (two digits per symbol); anything else uses code set B,
+// which covers printable ASCII.
+func Code128(data string) ([]int, error) {
+	values, err := code128Values(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var widths []int
+	for _, v := range values {
+		for _, c := range code128Patterns[v] {
+			widths = append(widths, int(c-'0'))
+		}
+	}
+	return widths, nil
+}
+
+// code128Values returns the symbol values for data: start code, data, checksum and stop
+func code128Values(data string) ([]int, error) {
+	if data == "" {
+		return nil, fmt.Errorf("code 128: nothing to encode")
+	}
+
+	var values []int
+	if len(data)%2 == 0 && isDigits(data) {
+		values = append(values, code128StartC)
+		for i := 0; i < len(data); i += 2 {
+			values = append(values, int(data[i]-'0')*10+int(data[i+1]-'0'))
+		}
+	} else {
+		values = append(values, code128StartB)
+		for _, r := range data {
+			if r < 32 || r > 126 {
+				return nil, fmt.Errorf("code 128: character %q is not printable ASCII", r)
+			}
+			values = append(values, int(r-32))
+		}
+	}
+
+	checksum := values[0]
+	for i, v := range values[1:] {
+		checksum += (i + 1) * v
+	}
+	values = append(values, checksum%103, code128Stop)
+	return values, nil
+}
+
+// isDigits reports whether s consists only of ASCII digits
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Modules returns the total width of a barcode in modules, excluding quiet zones
+func Modules(widths []int) int {
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	return total
+}
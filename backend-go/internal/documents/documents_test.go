@@ -0,0 +1,161 @@
+// Package documents provides shipping document tests
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCode128 tests symbol values, checksums and bar widths
+func TestCode128(t *testing.T) {
+	for v, pattern := range code128Patterns {
+		want := 11
+		if v == code128Stop {
+			want = 13
+		}
+		sum := 0
+		for _, c := range pattern {
+			sum += int(c - '0')
+		}
+		if sum != want {
+			t.Errorf("Expected pattern %d to span %d modules, got %d", v, want, sum)
+		}
+	}
+
+	values, err := code128Values("PJJ123C")
+	if err != nil {
+		t.Fatalf("Expected code set B encoding, got %v", err)
+	}
+	if values[0] != code128StartB || values[len(values)-2] != 55 || values[len(values)-1] != code128Stop {
+		t.Errorf("Unexpected code set B values: %v", values)
+	}
+
+	// 22-digit USPS tracking numbers pack two digits per symbol
+	values, _ = code128Values("9400100000000000000000")
+	if values[0] != code128StartC || len(values) != 1+11+2 || values[1] != 94 {
+		t.Errorf("Unexpected code set C values: %v", values)
+	}
+
+	widths, _ := Code128("9400100000000000000000")
+	if got := Modules(widths); got != 11+11*11+11+13 {
+		t.Errorf("Expected 156 modules, got %d", got)
+	}
+	// Odd-length digits fall back to code set B
+	if values, _ := code128Values("123"); values[0] != code128StartB {
+		t.Errorf("Expected code set B for odd-length digits, got start %d", values[0])
+	}
+
+	if _, err := Code128(""); err == nil {
+		t.Error("Expected empty data to be rejected")
+	}
+	if _, err := Code128("TRK\x01"); err == nil {
+		t.Error("Expected control characters to be rejected")
+	}
+}
+
+// TestDocument_Bytes tests the PDF structure and cross-reference offsets
+func TestDocument_Bytes(t *testing.T) {
+	doc := NewDocument("Test (1)")
+	page := doc.AddPage(Label4x6Width, Label4x6Height)
+	page.Text(10, 20, HelveticaBold, 12, `Refrigerate 2–8°C (\ok)`)
+	page.Rect(10, 30, 5, 5)
+
+	pdf, err := doc.Bytes()
+	if err != nil {
+		t.Fatalf("Expected PDF, got %v", err)
+	}
+	assertValidPDF(t, pdf)
+
+	if !bytes.Contains(pdf, []byte(`(Refrigerate 2\2268\260C \(\\ok\)) Tj`)) {
+		t.Errorf("Expected escaped WinAnsi text in content stream")
+	}
+	if !bytes.Contains(pdf, []byte("/MediaBox [0 0 288 432]")) {
+		t.Errorf("Expected a 4x6 media box")
+	}
+
+	if _, err := NewDocument("empty").Bytes(); err == nil {
+		t.Error("Expected a document without pages to be rejected")
+	}
+}
+
+// TestRenderShippingDocuments tests the packing slip and 4x6 label
+func TestRenderShippingDocuments(t *testing.T) {
+	date := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	coldChain := &ColdChainNotice{MinTempC: 2, MaxTempC: 8}
+
+	slip, err := RenderPackingSlip(PackingSlip{
+		RxNumber:      "RX-1001",
+		ShipTo:        PostalAddress{Name: "Jane Doe", Street: "1 Main St", City: "Springfield", State: "IL", Zip: "62701"},
+		DrugName:      "Humira 40 mg/0.4 mL Pen",
+		NDC:           "00074-4339-02",
+		Quantity:      2,
+		DaysSupply:    28,
+		Directions:    "Inject 40 mg under the skin every other week. Keep in the refrigerator in the original carton to protect from light.",
+		PharmacyName:  "Downtown Specialty Pharmacy",
+		PharmacyPhone: "(555) 010-2000",
+		ShipmentID:    "65a000000000000000000004",
+		ColdChain:     coldChain,
+		Date:          date,
+	})
+	if err != nil {
+		t.Fatalf("Expected packing slip, got %v", err)
+	}
+	assertValidPDF(t, slip)
+	for _, want := range []string{"(RX-1001)", "(Jane Doe)", "(00074-4339-02)", "REFRIGERATE 2-8\\260C", "(555) 010-2000"} {
+		if !bytes.Contains(slip, []byte(want)) && !bytes.Contains(slip, []byte(strings.NewReplacer("(", "\\(", ")", "\\)").Replace(want))) {
+			t.Errorf("Expected packing slip to contain %q", want)
+		}
+	}
+
+	label, err := RenderShippingLabel(ShippingLabel{
+		From:           PostalAddress{Company: "Downtown Specialty Pharmacy", Street: "200 State St", City: "Chicago", State: "IL", Zip: "60601"},
+		To:             PostalAddress{Name: "Jane Doe", Street: "1 Main St", City: "Springfield", State: "IL", Zip: "62701"},
+		Carrier:        "FedEx",
+		ServiceName:    "Priority Overnight",
+		TrackingNumber: "794600000000",
+		WeightOz:       72,
+		ShipDate:       date,
+		ColdChain:      coldChain,
+	})
+	if err != nil {
+		t.Fatalf("Expected label, got %v", err)
+	}
+	assertValidPDF(t, label)
+	if !bytes.Contains(label, []byte("(TRACKING # 794600000000)")) || !bytes.Contains(label, []byte(" re f")) {
+		t.Error("Expected the label to carry the tracking number and barcode bars")
+	}
+
+	if _, err := RenderShippingLabel(ShippingLabel{}); err == nil {
+		t.Error("Expected a label without a tracking number to be rejected")
+	}
+}
+
+// assertValidPDF checks the header, trailer and that every xref offset points at its object
+func assertValidPDF(t *testing.T, pdf []byte) {
+	t.Helper()
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("Expected PDF header and trailer")
+	}
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if startxref == nil {
+		t.Fatalf("Expected startxref")
+	}
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("Expected startxref to point at the xref table")
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if !bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))) {
+			t.Errorf("Expected object %d at offset %d", i+1, offset)
+		}
+	}
+}
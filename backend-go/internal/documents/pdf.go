@@ -0,0 +1,229 @@
+// Package documents renders shipping documents (packing slips and labels) as PDF
+package documents
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Font is one of the standard PDF fonts, which every viewer provides without embedding
+type Font string
+
+const (
+	Helvetica     Font = "Helvetica"
+	HelveticaBold Font = "Helvetica-Bold"
+	Courier       Font = "Courier"
+)
+
+// fonts are registered on every page as /F1, /F2, ... in this order
+var fonts = []Font{Helvetica, HelveticaBold, Courier}
+
+// Page sizes in points (1/72 inch)
+const (
+	LetterWidth    = 612.0
+	LetterHeight   = 792.0
+	Label4x6Width  = 288.0
+	Label4x6Height = 432.0
+)
+
+// ContentType is the MIME type of rendered documents
+const ContentType = "application/pdf"
+
+// Document is a PDF document built page by page
+type Document struct {
+	Title   string
+	Created time.Time // written as the creation date when set
+	pages   []*Page
+}
+
+// Page is a single PDF page. Coordinates are in points from the top-left corner.
+type Page struct {
+	Width   float64
+	Height  float64
+	content bytes.Buffer
+}
+
+// NewDocument creates an empty document
+func NewDocument(title string) *Document {
+	return &Document{Title: title}
+}
+
+// AddPage appends a page of the given size
+func (d *Document) AddPage(width, height float64) *Page {
+	page := &Page{Width: width, Height: height}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Text draws a single line of text with its baseline at y
+func (p *Page) Text(x, y float64, font Font, size float64, text string) {
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s %s Td (%s) Tj ET\n",
+		fontResource(font), num(size), num(x), num(p.Height-y), escapeText(text))
+}
+
+// Rect fills a rectangle whose top-left corner is at x, y
+func (p *Page) Rect(x, y, width, height float64) {
+	fmt.Fprintf(&p.content, "%s %s %s %s re f\n", num(x), num(p.Height-y-height), num(width), num(height))
+}
+
+// StrokeRect outlines a rectangle whose top-left corner is at x, y
+func (p *Page) StrokeRect(x, y, width, height, lineWidth float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s %s %s re S\n", num(lineWidth), num(x), num(p.Height-y-height), num(width), num(height))
+}
+
+// Line draws a straight line
+func (p *Page) Line(x1, y1, x2, y2, lineWidth float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n", num(lineWidth), num(x1), num(p.Height-y1), num(x2), num(p.Height-y2))
+}
+
+// Barcode draws bars from alternating bar and space widths (in modules), starting with a bar
+func (p *Page) Barcode(x, y, moduleWidth, height float64, widths []int) {
+	for i, w := range widths {
+		if i%2 == 0 {
+			p.Rect(x, y, float64(w)*moduleWidth, height)
+		}
+		x += float64(w) * moduleWidth
+	}
+}
+
+// Bytes serializes the document as PDF 1.4
+func (d *Document) Bytes() ([]byte, error) {
+	if len(d.pages) == 0 {
+		return nil, errors.New("document has no pages")
+	}
+
+	// Object numbers: 1 catalog, 2 page tree, then fonts, then a page and its content stream per page, then info
+	firstFont := 3
+	firstPage := firstFont + len(fonts)
+	info := firstPage + 2*len(d.pages)
+
+	var buf bytes.Buffer
+	offsets := make([]int, info+1)
+	object := func(n int, body string) {
+		offsets[n] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", n, body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	fontRefs := make([]string, len(fonts))
+	for i, font := range fonts {
+		object(firstFont+i, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", font))
+		fontRefs[i] = fmt.Sprintf("/F%d %d 0 R", i+1, firstFont+i)
+	}
+
+	for i, page := range d.pages {
+		pageObj := firstPage + 2*i
+		object(pageObj, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			num(page.Width), num(page.Height), strings.Join(fontRefs, " "), pageObj+1))
+		content := page.content.Bytes()
+		object(pageObj+1, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	infoDict := fmt.Sprintf("<< /Title (%s) /Producer (phil-my-meds)", escapeText(d.Title))
+	if !d.Created.IsZero() {
+		infoDict += fmt.Sprintf(" /CreationDate (D:%s)", d.Created.UTC().Format("20060102150405Z"))
+	}
+	object(info, infoDict+" >>")
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", info+1)
+	for n := 1; n <= info; n++ {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offsets[n])
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", info+1, info, xref)
+
+	return buf.Bytes(), nil
+}
+
+// TextWidth estimates the width of text in points
+// Uses average glyph widths, which is close enough for laying out short lines
+func TextWidth(font Font, size float64, text string) float64 {
+	factor := 0.52
+	switch font {
+	case HelveticaBold:
+		factor = 0.56
+	case Courier:
+		factor = 0.6
+	}
+	return float64(len([]rune(text))) * size * factor
+}
+
+// Wrap splits text into lines no wider than width
+func Wrap(font Font, size, width float64, text string) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && TextWidth(font, size, candidate) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fontResource returns the page resource name of a font
+func fontResource(font Font) string {
+	for i, f := range fonts {
+		if f == font {
+			return "F" + strconv.Itoa(i+1)
+		}
+	}
+	return "F1"
+}
+
+// num formats a coordinate without trailing zeros
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// winAnsi maps the non-Latin-1 characters of WinAnsiEncoding that shipping documents use
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+}
+
+// escapeText encodes text as a PDF literal string body in WinAnsiEncoding
+// Characters the encoding lacks are replaced with '?'
+func escapeText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n' || r == '\t':
+			b.WriteByte(' ')
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			if c, ok := winAnsi[r]; ok {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte('?')
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,270 @@
+// Package documents renders shipping documents (packing slips and labels) as PDF
+package documents
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Document types stored for a shipment
+const (
+	TypePackingSlip = "packing_slip"
+	TypeLabel4x6    = "label_4x6"
+)
+
+// PostalAddress is a name and address printed on a document
+type PostalAddress struct {
+	Name    string
+	Company string
+	Street  string
+	City    string
+	State   string
+	Zip     string
+}
+
+// Lines returns the address as printable lines, skipping empty parts
+func (a PostalAddress) Lines() []string {
+	var lines []string
+	for _, line := range []string{a.Name, a.Company, a.Street} {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	cityLine := strings.TrimSpace(strings.Trim(a.City+", "+a.State, ", ") + " " + a.Zip)
+	if cityLine != "" {
+		lines = append(lines, cityLine)
+	}
+	return lines
+}
+
+// ColdChainNotice is printed on documents for refrigerated shipments
+type ColdChainNotice struct {
+	MinTempC float64
+	MaxTempC float64
+}
+
+// Text returns the storage instruction
+func (c ColdChainNotice) Text() string {
+	return fmt.Sprintf("REFRIGERATE %.0f-%.0f°C ON ARRIVAL - DO NOT FREEZE", c.MinTempC, c.MaxTempC)
+}
+
+// PackingSlip is the content of the packing slip that goes in the box
+type PackingSlip struct {
+	RxNumber   string
+	ShipTo     PostalAddress
+	DrugName   string
+	NDC        string
+	Quantity   int
+	DaysSupply int
+	Directions string
+
+	// Dispensing pharmacy, printed as the pharmacist contact
+	PharmacyName  string
+	PharmacyPhone string
+	PharmacyEmail string
+	PharmacyAddr  PostalAddress
+
+	ShipmentID     string
+	TrackingNumber string
+	ColdChain      *ColdChainNotice
+	Date           time.Time
+}
+
+// ShippingLabel is the content of a 4x6 fallback label
+type ShippingLabel struct {
+	From           PostalAddress
+	To             PostalAddress
+	Carrier        string
+	ServiceName    string
+	TrackingNumber string
+	WeightOz       float64
+	ShipDate       time.Time
+	ColdChain      *ColdChainNotice
+}
+
+// RenderPackingSlip renders a US Letter packing slip
+func RenderPackingSlip(slip PackingSlip) ([]byte, error) {
+	doc := NewDocument("Packing slip " + slip.RxNumber)
+	doc.Created = slip.Date
+	page := doc.AddPage(LetterWidth, LetterHeight)
+
+	const margin = 54.0
+	right := LetterWidth - margin
+
+	// Pharmacy header
+	page.Text(margin, 72, HelveticaBold, 18, slip.PharmacyName)
+	y := 90.0
+	for _, line := range slip.PharmacyAddr.Lines() {
+		page.Text(margin, y, Helvetica, 10, line)
+		y += 13
+	}
+	title := "PACKING SLIP"
+	page.Text(right-TextWidth(HelveticaBold, 16, title), 72, HelveticaBold, 16, title)
+	date := slip.Date.Format("January 2, 2006")
+	page.Text(right-TextWidth(Helvetica, 10, date), 90, Helvetica, 10, date)
+
+	y = maxFloat(y, 110) + 8
+	page.Line(margin, y, right, y, 1)
+	y += 24
+
+	// Ship to
+	page.Text(margin, y, HelveticaBold, 11, "SHIP TO")
+	y += 16
+	for _, line := range slip.ShipTo.Lines() {
+		page.Text(margin, y, Helvetica, 11, line)
+		y += 14
+	}
+	y += 14
+
+	// Prescription
+	page.Text(margin, y, HelveticaBold, 11, "PRESCRIPTION")
+	y += 6
+	page.Line(margin, y, right, y, 0.5)
+	y += 18
+
+	rows := [][2]string{
+		{"Rx number", slip.RxNumber},
+		{"Medication", slip.DrugName},
+		{"NDC", slip.NDC},
+		{"Quantity", fmt.Sprintf("%d", slip.Quantity)},
+	}
+	if slip.DaysSupply > 0 {
+		rows = append(rows, [2]string{"Days supply", fmt.Sprintf("%d", slip.DaysSupply)})
+	}
+	for _, row := range rows {
+		page.Text(margin, y, HelveticaBold, 10, row[0])
+		page.Text(margin+110, y, Helvetica, 10, row[1])
+		y += 16
+	}
+
+	page.Text(margin, y, HelveticaBold, 10, "Directions")
+	directions := Wrap(Helvetica, 10, right-margin-110, slip.Directions)
+	if len(directions) == 0 {
+		directions = []string{"See prescription label"}
+	}
+	for _, line := range directions {
+		page.Text(margin+110, y, Helvetica, 10, line)
+		y += 14
+	}
+	y += 16
+
+	if slip.ColdChain != nil {
+		page.StrokeRect(margin, y, right-margin, 34, 2)
+		page.Text(margin+12, y+22, HelveticaBold, 13, slip.ColdChain.Text())
+		y += 34 + 24
+	}
+
+	// Pharmacist contact
+	page.Text(margin, y, HelveticaBold, 11, "QUESTIONS ABOUT YOUR MEDICATION?")
+	y += 16
+	contact := "Call your pharmacist at " + slip.PharmacyName
+	if slip.PharmacyPhone != "" {
+		contact += " on " + slip.PharmacyPhone
+	}
+	if slip.PharmacyEmail != "" {
+		contact += " or email " + slip.PharmacyEmail
+	}
+	for _, line := range Wrap(Helvetica, 10, right-margin, contact+".") {
+		page.Text(margin, y, Helvetica, 10, line)
+		y += 14
+	}
+
+	// Footer
+	footerY := LetterHeight - margin
+	page.Line(margin, footerY-16, right, footerY-16, 0.5)
+	page.Text(margin, footerY, Helvetica, 8, "Shipment "+slip.ShipmentID)
+	if slip.TrackingNumber != "" {
+		tracking := "Tracking " + slip.TrackingNumber
+		page.Text(right-TextWidth(Helvetica, 8, tracking), footerY, Helvetica, 8, tracking)
+	}
+
+	return doc.Bytes()
+}
+
+// RenderShippingLabel renders a 4x6 label with a Code 128 barcode of the tracking number
+// It is a fallback for when the carrier's own label cannot be printed
+func RenderShippingLabel(label ShippingLabel) ([]byte, error) {
+	bars, err := Code128(label.TrackingNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tracking number: %w", err)
+	}
+
+	doc := NewDocument("Shipping label " + label.TrackingNumber)
+	doc.Created = label.ShipDate
+	page := doc.AddPage(Label4x6Width, Label4x6Height)
+
+	const margin = 14.0
+	right := Label4x6Width - margin
+
+	// From
+	y := 24.0
+	page.Text(margin, y, HelveticaBold, 7, "FROM")
+	y += 10
+	for _, line := range label.From.Lines() {
+		page.Text(margin, y, Helvetica, 7, line)
+		y += 9
+	}
+	if !label.ShipDate.IsZero() {
+		shipDate := "SHIP DATE " + label.ShipDate.Format("01/02/2006")
+		page.Text(right-TextWidth(Helvetica, 7, shipDate), 24, Helvetica, 7, shipDate)
+	}
+	if label.WeightOz > 0 {
+		weight := fmt.Sprintf("WT %.1f OZ", label.WeightOz)
+		page.Text(right-TextWidth(Helvetica, 7, weight), 34, Helvetica, 7, weight)
+	}
+
+	// Service banner
+	y = maxFloat(y, 70) + 4
+	page.StrokeRect(margin, y, right-margin, 28, 2.5)
+	service := strings.ToUpper(strings.TrimSpace(label.Carrier + " " + label.ServiceName))
+	page.Text(margin+8, y+19, HelveticaBold, 13, service)
+	y += 28 + 18
+
+	// Ship to
+	page.Text(margin, y, HelveticaBold, 9, "SHIP TO")
+	y += 16
+	for _, line := range label.To.Lines() {
+		page.Text(margin+8, y, HelveticaBold, 12, line)
+		y += 15
+	}
+	y += 8
+
+	if label.ColdChain != nil {
+		page.StrokeRect(margin, y, right-margin, 26, 2)
+		notice := label.ColdChain.Text()
+		size := 9.0
+		for size > 6 && TextWidth(HelveticaBold, size, notice) > right-margin-12 {
+			size--
+		}
+		page.Text(margin+6, y+17, HelveticaBold, size, notice)
+		y += 26 + 10
+	}
+
+	// Barcode, scaled to the label width inside its quiet zones
+	page.Line(margin, y, right, y, 1)
+	y += 12
+	modules := float64(Modules(bars) + 2*Code128QuietZone)
+	moduleWidth := (right - margin) / modules
+	if moduleWidth > 1.5 {
+		moduleWidth = 1.5
+	}
+	barcodeWidth := float64(Modules(bars)) * moduleWidth
+	x := (Label4x6Width - barcodeWidth) / 2
+	const barHeight = 72.0
+	page.Barcode(x, y, moduleWidth, barHeight, bars)
+	y += barHeight + 14
+
+	tracking := "TRACKING # " + label.TrackingNumber
+	page.Text((Label4x6Width-TextWidth(Courier, 9, tracking))/2, y, Courier, 9, tracking)
+
+	return doc.Bytes()
+}
+
+// maxFloat returns the larger of a and b
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,375 @@
+// Package events defines the versioned contracts of the events published on each Kafka topic
+package events
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+// contract is the current schema version of a topic's event and how to make an empty one
+// Bump the version only for breaking changes (a field removed, renamed or retyped); consumers
+// ignore fields they do not know, so adding one does not need a new version.
+type contract struct {
+	version int
+	new     func() Event
+}
+
+// contracts lists every topic's event contract
+var contracts = map[string]contract{
+	kafka.TopicIntakeReceived:               {1, func() Event { return &IntakeReceived{} }},
+	kafka.TopicValidationCompleted:          {1, func() Event { return &ValidationCompleted{} }},
+	kafka.TopicDURReviewRequested:           {1, func() Event { return &DURReviewRequested{} }},
+	kafka.TopicEnrollmentCompleted:          {1, func() Event { return &EnrollmentCompleted{} }},
+	kafka.TopicEnrollmentLinkCreated:        {1, func() Event { return &EnrollmentLinkCreated{} }},
+	kafka.TopicPharmacySelected:             {1, func() Event { return &PharmacySelected{} }},
+	kafka.TopicAdjudicationCompleted:        {1, func() Event { return &AdjudicationCompleted{} }},
+	kafka.TopicPrescriptionException:        {1, func() Event { return &PrescriptionException{} }},
+	kafka.TopicPriorAuthUpdated:             {1, func() Event { return &PriorAuthUpdated{} }},
+	kafka.TopicPaymentLinkCreated:           {1, func() Event { return &PaymentLinkCreated{} }},
+	kafka.TopicPaymentCompleted:             {1, func() Event { return &PaymentCompleted{} }},
+	kafka.TopicVerificationCompleted:        {1, func() Event { return &VerificationCompleted{} }},
+	kafka.TopicPaymentRefunded:              {1, func() Event { return &PaymentRefunded{} }},
+	kafka.TopicShipmentLabelCreated:         {1, func() Event { return &ShipmentLabelCreated{} }},
+	kafka.TopicShipmentTemperatureExcursion: {1, func() Event { return &ShipmentTemperatureExcursion{} }},
+	kafka.TopicShipmentDelivered:            {1, func() Event { return &ShipmentDelivered{} }},
+	kafka.TopicDeadLetterQueue:              {1, func() Event { return &DeadLetter{} }},
+}
+
+// Version returns the current schema version of a topic's event
+func Version(topic string) (int, error) {
+	c, ok := contracts[topic]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownTopic, topic)
+	}
+	return c.version, nil
+}
+
+// New returns an empty event of a topic's type, ready to Decode into
+func New(topic string) (Event, error) {
+	c, ok := contracts[topic]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTopic, topic)
+	}
+	return c.new(), nil
+}
+
+// Topics returns every topic with an event contract, sorted
+func Topics() []string {
+	topics := make([]string, 0, len(contracts))
+	for topic := range contracts {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// IntakePatient is the patient as written on an intake prescription
+type IntakePatient struct {
+	ID          string `json:"id,omitempty"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	DateOfBirth string `json:"date_of_birth"`
+}
+
+// IntakePrescriber is the prescriber of an intake prescription
+type IntakePrescriber struct {
+	ID        string `json:"id,omitempty"`
+	NPI       string `json:"npi"`
+	DEA       string `json:"dea,omitempty"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// IntakeMedication is the drug prescribed on an intake prescription
+type IntakeMedication struct {
+	NDC      string `json:"ndc"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	Refills  int    `json:"refills"`
+}
+
+// IntakeInsurance is the insurance given on an intake prescription
+type IntakeInsurance struct {
+	BIN      string `json:"bin"`
+	PCN      string `json:"pcn"`
+	GroupID  string `json:"group_id"`
+	MemberID string `json:"member_id"`
+	PlanName string `json:"plan_name"`
+}
+
+// IntakeReceived is published on prescription.intake.received when the API accepts a prescription
+type IntakeReceived struct {
+	Envelope
+	Status      string           `json:"status"`
+	Patient     IntakePatient    `json:"patient"`
+	Prescriber  IntakePrescriber `json:"prescriber"`
+	Medication  IntakeMedication `json:"medication"`
+	Insurance   *IntakeInsurance `json:"insurance,omitempty"`
+	DateWritten string           `json:"date_written"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// Topic returns the Kafka topic of the event
+func (IntakeReceived) Topic() string { return kafka.TopicIntakeReceived }
+
+// ValidationCompleted is published on prescription.validation.completed for a valid prescription
+type ValidationCompleted struct {
+	Envelope
+	PatientID       string    `json:"patient_id"`
+	ValidatedAt     time.Time `json:"validated_at"`
+	ValidationFlags []string  `json:"validation_flags"`
+}
+
+// Topic returns the Kafka topic of the event
+func (ValidationCompleted) Topic() string { return kafka.TopicValidationCompleted }
+
+// DURReviewRequested is published on prescription.dur_review.requested when drug utilization review flags a
+// prescription; it waits for a pharmacist's decision instead of continuing to enrollment
+type DURReviewRequested struct {
+	Envelope
+	ReviewID     string    `json:"review_id"`
+	PatientID    string    `json:"patient_id"`
+	NDC          string    `json:"ndc"`
+	Severity     string    `json:"severity"`
+	FindingTypes []string  `json:"finding_types"`
+	RequestedAt  time.Time `json:"requested_at"`
+}
+
+// Topic returns the Kafka topic of the event
+func (DURReviewRequested) Topic() string { return kafka.TopicDURReviewRequested }
+
+// EnrollmentCompleted is published on patient.enrollment.completed once the patient is enrolled
+type EnrollmentCompleted struct {
+	Envelope
+	PatientID  string    `json:"patient_id"`
+	EnrolledAt time.Time `json:"enrolled_at"`
+}
+
+// Topic returns the Kafka topic of the event
+func (EnrollmentCompleted) Topic() string { return kafka.TopicEnrollmentCompleted }
+
+// EnrollmentLinkCreated is published on patient.enrollment.link.created to invite a patient to enroll
+// It carries the contact details, as the patient has no record yet.
+type EnrollmentLinkCreated struct {
+	Envelope
+	PatientID     string    `json:"patient_id,omitempty"`
+	Email         string    `json:"email"`
+	Phone         string    `json:"phone,omitempty"`
+	SMSConsent    bool      `json:"sms_consent"` // the prescriber recorded the patient's consent to texts
+	EnrollmentURL string    `json:"enrollment_url"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// Topic returns the Kafka topic of the event
+func (EnrollmentLinkCreated) Topic() string { return kafka.TopicEnrollmentLinkCreated }
+
+// PharmacySelected is published on pharmacy.selected when routing or ops picks the dispensing pharmacy
+type PharmacySelected struct {
+	Envelope
+	PatientID       string    `json:"patient_id"`
+	PharmacyID      string    `json:"pharmacy_id"`
+	PharmacyNCPDPID string    `json:"pharmacy_ncpdp_id"`
+	PharmacyName    string    `json:"pharmacy_name"`
+	SelectionMode   string    `json:"selection_mode"`
+	SelectedBy      string    `json:"selected_by"`
+	SelectedAt      time.Time `json:"selected_at"`
+}
+
+// Topic returns the Kafka topic of the event
+func (PharmacySelected) Topic() string { return kafka.TopicPharmacySelected }
+
+// AdjudicationCompleted is published on insurance.adjudication.completed for a paid or partial claim
+// FinalCopay is what the patient is charged after manufacturer programs.
+type AdjudicationCompleted struct {
+	Envelope
+	PatientID          string                `json:"patient_id"`
+	PharmacyID         string                `json:"pharmacy_id"`
+	Source             string                `json:"source"`
+	AdjudicationResult models.ClaimResult    `json:"adjudication_result"`
+	CostBreakdown      *models.CostBreakdown `json:"cost_breakdown,omitempty"`
+	FinalCopay         *float64              `json:"final_copay,omitempty"`
+	ProgramsApplied    []string              `json:"programs_applied"`
+	PatientSavings     float64               `json:"patient_savings"`
+	AdjudicatedAt      time.Time             `json:"adjudicated_at"`
+}
+
+// Topic returns the Kafka topic of the event
+func (AdjudicationCompleted) Topic() string { return kafka.TopicAdjudicationCompleted }
+
+// PrescriptionException is published on prescription.exception when the flow stops for ops to resolve
+type PrescriptionException struct {
+	Envelope
+	ExceptionType string    `json:"exception_type"`
+	Reason        string    `json:"reason"`
+	Codes         []string  `json:"codes,omitempty"`
+	Source        string    `json:"source"`
+	RaisedAt      time.Time `json:"raised_at"`
+}
+
+// Topic returns the Kafka topic of the event
+func (PrescriptionException) Topic() string { return kafka.TopicPrescriptionException }
+
+// PriorAuthUpdated is published on prior_authorization.updated for every prior authorization transition
+type PriorAuthUpdated struct {
+	Envelope
+	PriorAuthorizationID string     `json:"prior_authorization_id"`
+	PatientID            string     `json:"patient_id"`
+	NDC                  string     `json:"ndc"`
+	Status               string     `json:"status"`
+	PreviousStatus       string     `json:"previous_status"`
+	Source               string     `json:"source"`
+	ExpiresAt            *time.Time `json:"expires_at,omitempty"`
+	DenialReason         string     `json:"denial_reason,omitempty"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+// Topic returns the Kafka topic of the event
+func (PriorAuthUpdated) Topic() string { return kafka.TopicPriorAuthUpdated }
+
+// PaymentLinkCreated is published on payment.link.created when the patient has a copay to pay
+type PaymentLinkCreated struct {
+	Envelope
+	PatientID      string                `json:"patient_id"`
+	PaymentID      string                `json:"payment_id"`
+	Provider       string                `json:"provider"`
+	PaymentLinkID  string                `json:"payment_link_id"`
+	PaymentLinkURL string                `json:"payment_link_url"`
+	Amount         float64               `json:"amount"`
+	CostBreakdown  *models.CostBreakdown `json:"cost_breakdown,omitempty"`
+	LinkExpiresAt  time.Time             `json:"link_expires_at"`
+	CreatedAt      time.Time             `json:"created_at"`
+}
+
+// Topic returns the Kafka topic of the event
+func (PaymentLinkCreated) Topic() string { return kafka.TopicPaymentLinkCreated }
+
+// PaymentCompleted is published on payment.completed when the copay is paid or waived
+// A waived copay has status "waived", an amount of 0 and no payment.
+type PaymentCompleted struct {
+	Envelope
+	PatientID       string    `json:"patient_id"`
+	PaymentID       string    `json:"payment_id,omitempty"`
+	Provider        string    `json:"provider,omitempty"`
+	PaymentIntentID string    `json:"payment_intent_id,omitempty"`
+	Amount          float64   `json:"amount"`
+	Currency        string    `json:"currency,omitempty"`
+	Status          string    `json:"status"`
+	CompletedAt     time.Time `json:"completed_at"`
+}
+
+// Topic returns the Kafka topic of the event
+func (PaymentCompleted) Topic() string { return kafka.TopicPaymentCompleted }
+
+// VerificationCompleted is published on prescription.verification.completed when a pharmacist
+// approves a paid prescription for dispensing
+type VerificationCompleted struct {
+	Envelope
+	VerificationID string    `json:"verification_id"`
+	PatientID      string    `json:"patient_id"`
+	PaymentID      string    `json:"payment_id,omitempty"`
+	VerifiedBy     string    `json:"verified_by"`
+	LicenseNumber  string    `json:"license_number"`
+	VerifiedAt     time.Time `json:"verified_at"`
+}
+
+// Topic returns the Kafka topic of the event
+func (VerificationCompleted) Topic() string { return kafka.TopicVerificationCompleted }
+
+// PaymentRefunded is published on payment.refunded when the provider issues a refund
+type PaymentRefunded struct {
+	Envelope
+	RefundID         string     `json:"refund_id"`
+	PaymentID        string     `json:"payment_id"`
+	PatientID        string     `json:"patient_id"`
+	Amount           float64    `json:"amount"`
+	Currency         string     `json:"currency"`
+	Reason           string     `json:"reason"`
+	Provider         string     `json:"provider"`
+	ProviderRefundID string     `json:"provider_refund_id"`
+	AmountRefunded   float64    `json:"amount_refunded"`
+	PaymentStatus    string     `json:"payment_status"`
+	FullyRefunded    bool       `json:"fully_refunded"`
+	ApprovedBy       string     `json:"approved_by"`
+	RefundedAt       *time.Time `json:"refunded_at,omitempty"`
+}
+
+// Topic returns the Kafka topic of the event
+func (PaymentRefunded) Topic() string { return kafka.TopicPaymentRefunded }
+
+// ShipmentLabelCreated is published on shipment.label.created when a label is bought
+// ShipOn and Packaging are set for cold chain shipments, ReplacesShipmentID for replacements.
+type ShipmentLabelCreated struct {
+	Envelope
+	PatientID          string     `json:"patient_id"`
+	ShipmentID         string     `json:"shipment_id"`
+	Carrier            string     `json:"carrier"`
+	ServiceLevel       string     `json:"service_level"`
+	Cost               float64    `json:"cost"`
+	TrackingNumber     string     `json:"tracking_number"`
+	TrackingURL        string     `json:"tracking_url"`
+	LabelURL           string     `json:"label_url"`
+	Documents          int        `json:"documents"`
+	DeliverBy          time.Time  `json:"deliver_by"`
+	ColdChain          bool       `json:"cold_chain"`
+	ShipOn             *time.Time `json:"ship_on,omitempty"`
+	Packaging          string     `json:"packaging,omitempty"`
+	ReplacesShipmentID string     `json:"replaces_shipment_id,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+// Topic returns the Kafka topic of the event
+func (ShipmentLabelCreated) Topic() string { return kafka.TopicShipmentLabelCreated }
+
+// ShipmentTemperatureExcursion is published on shipment.temperature_excursion for a flagged cold chain shipment
+// TemperatureC is nil when only an indicator tripped.
+type ShipmentTemperatureExcursion struct {
+	Envelope
+	ShipmentID     string    `json:"shipment_id"`
+	PatientID      string    `json:"patient_id"`
+	TrackingNumber string    `json:"tracking_number"`
+	Source         string    `json:"source"`
+	ReportedBy     string    `json:"reported_by"`
+	ObservedAt     time.Time `json:"observed_at"`
+	TemperatureC   *float64  `json:"temperature_c,omitempty"`
+	MinTempC       *float64  `json:"min_temp_c,omitempty"`
+	MaxTempC       *float64  `json:"max_temp_c,omitempty"`
+}
+
+// Topic returns the Kafka topic of the event
+func (ShipmentTemperatureExcursion) Topic() string { return kafka.TopicShipmentTemperatureExcursion }
+
+// ShipmentDelivered is published on shipment.delivered once the carrier confirms delivery
+type ShipmentDelivered struct {
+	Envelope
+	ShipmentID     string    `json:"shipment_id"`
+	PatientID      string    `json:"patient_id"`
+	Carrier        string    `json:"carrier"`
+	TrackingNumber string    `json:"tracking_number"`
+	DeliveredAt    time.Time `json:"delivered_at"`
+	ColdChain      bool      `json:"cold_chain"`
+	Details        string    `json:"details,omitempty"`
+	Location       string    `json:"location,omitempty"`
+}
+
+// Topic returns the Kafka topic of the event
+func (ShipmentDelivered) Topic() string { return kafka.TopicShipmentDelivered }
+
+// DeadLetter is published on dead_letter_queue for a message a handler failed to process
+// The envelope's prescription ID is the original message key.
+type DeadLetter struct {
+	Envelope
+	OriginalTopic string    `json:"original_topic"`
+	OriginalKey   string    `json:"original_key"`
+	OriginalValue string    `json:"original_value"`
+	Error         string    `json:"error"`
+	FailedAt      time.Time `json:"failed_at"`
+	Partition     int32     `json:"partition"`
+	Offset        int64     `json:"offset"`
+}
+
+// Topic returns the Kafka topic of the event
+func (DeadLetter) Topic() string { return kafka.TopicDeadLetterQueue }
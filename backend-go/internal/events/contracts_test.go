@@ -0,0 +1,281 @@
+// Package events provides event contract tests
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+)
+
+// envelopeFixture is the envelope of every fixture below
+const envelopeFixture = `"event_id": "6f1c2a8e-1d2b-4c3e-9f40-5a6b7c8d9e0f",
+	"schema_version": 1,
+	"correlation_id": "intake_1709629200",
+	"causation_id": "0b8e4d2c-3a1f-4e5d-8c7b-6a5f4e3d2c1b",
+	"prescription_id": "65f0c1a2b3c4d5e6f7a8b9c0",
+	"producer": "ShippingWorker",
+	"occurred_at": "2024-03-05T09:00:00Z"`
+
+// contractFixtures is a complete event on each topic, as published by schema version 1
+// A fixture that stops decoding, or re-encodes with different fields, is a breaking change: bump the
+// topic's version in contracts and keep consumers able to read the old one.
+var contractFixtures = map[string]string{
+	kafka.TopicIntakeReceived: `{` + envelopeFixture + `,
+	"status": "received",
+	"patient": {"id": "P-100", "first_name": "Jane", "last_name": "Doe", "date_of_birth": "1980-01-15"},
+	"prescriber": {"id": "D-7", "npi": "1234567893", "dea": "AB1234563", "first_name": "Ann", "last_name": "Lee"},
+	"medication": {"ndc": "00002-7510-01", "name": "Insulin Lispro", "quantity": 10, "refills": 2},
+	"insurance": {"bin": "610014", "pcn": "MEDDPRIME", "group_id": "GRP1", "member_id": "M123", "plan_name": "Gold"},
+	"date_written": "2024-03-01",
+	"created_at": "2024-03-05T08:59:58Z"}`,
+	kafka.TopicValidationCompleted: `{` + envelopeFixture + `,
+	"patient_id": "P-100", "validated_at": "2024-03-05T09:00:00Z", "validation_flags": ["pa_required"]}`,
+	kafka.TopicDURReviewRequested: `{` + envelopeFixture + `,
+	"review_id": "DUR-1", "patient_id": "P-100", "ndc": "00002-7510-01", "severity": "major",
+	"finding_types": ["drug_interaction", "allergy"], "requested_at": "2024-03-05T09:00:00Z"}`,
+	kafka.TopicEnrollmentCompleted: `{` + envelopeFixture + `,
+	"patient_id": "P-100", "enrolled_at": "2024-03-05T09:00:00Z"}`,
+	kafka.TopicEnrollmentLinkCreated: `{` + envelopeFixture + `,
+	"patient_id": "P-100", "email": "jane@example.com", "phone": "+15555550100", "sms_consent": true,
+	"enrollment_url": "https://app.example.com/enroll/abc", "expires_at": "2024-03-06T09:00:00Z"}`,
+	kafka.TopicPharmacySelected: `{` + envelopeFixture + `,
+	"patient_id": "P-100", "pharmacy_id": "PH-1", "pharmacy_ncpdp_id": "1234567", "pharmacy_name": "Main St Pharmacy",
+	"selection_mode": "auto", "selected_by": "routing", "selected_at": "2024-03-05T09:00:00Z"}`,
+	kafka.TopicAdjudicationCompleted: `{` + envelopeFixture + `,
+	"patient_id": "P-100", "pharmacy_id": "PH-1", "source": "simulator",
+	"adjudication_result": {"claim_id": "C-1", "status": "paid", "reject_codes": [{"code": "75", "description": "Prior authorization required"}],
+		"message": "approved", "tier": 2, "requested_quantity": 10, "approved_quantity": 10, "days_supply": 30,
+		"ingredient_cost": 100, "dispensing_fee": 2, "total_cost": 102, "insurance_pays": 77, "copay_amount": 25,
+		"adjudicated_at": "2024-03-05T09:00:00Z"},
+	"cost_breakdown": {"total_drug_cost": 102, "insurance_covered": 77, "initial_copay": 25, "manufacturer_discount": 20, "final_patient_copay": 5},
+	"final_copay": 5, "programs_applied": ["Lilly Insulin Value"], "patient_savings": 20, "adjudicated_at": "2024-03-05T09:00:00Z"}`,
+	kafka.TopicPrescriptionException: `{` + envelopeFixture + `,
+	"exception_type": "claim_rejected", "reason": "Prior authorization required", "codes": ["75"],
+	"source": "adjudication", "raised_at": "2024-03-05T09:00:00Z"}`,
+	kafka.TopicPriorAuthUpdated: `{` + envelopeFixture + `,
+	"prior_authorization_id": "PA-1", "patient_id": "P-100", "ndc": "00002-7510-01", "status": "denied",
+	"previous_status": "pending_payer", "source": "claim_reject", "expires_at": "2025-03-05T09:00:00Z",
+	"denial_reason": "step therapy", "updated_at": "2024-03-05T09:00:00Z"}`,
+	kafka.TopicPaymentLinkCreated: `{` + envelopeFixture + `,
+	"patient_id": "P-100", "payment_id": "PAY-1", "provider": "stripe", "payment_link_id": "cs_test_1",
+	"payment_link_url": "https://checkout.example.com/cs_test_1", "amount": 5,
+	"cost_breakdown": {"total_drug_cost": 102, "insurance_covered": 77, "initial_copay": 25, "manufacturer_discount": 20, "final_patient_copay": 5},
+	"link_expires_at": "2024-03-06T09:00:00Z", "created_at": "2024-03-05T09:00:00Z"}`,
+	kafka.TopicPaymentCompleted: `{` + envelopeFixture + `,
+	"patient_id": "P-100", "payment_id": "PAY-1", "provider": "stripe", "payment_intent_id": "pi_1",
+	"amount": 5, "currency": "usd", "status": "paid", "completed_at": "2024-03-05T09:00:00Z"}`,
+	kafka.TopicVerificationCompleted: `{` + envelopeFixture + `,
+	"verification_id": "VER-1", "patient_id": "P-100", "payment_id": "PAY-1", "verified_by": "pharm_1",
+	"license_number": "RPH-12345", "verified_at": "2024-03-05T09:00:00Z"}`,
+	kafka.TopicPaymentRefunded: `{` + envelopeFixture + `,
+	"refund_id": "RF-1", "payment_id": "PAY-1", "patient_id": "P-100", "amount": 5, "currency": "usd",
+	"reason": "prescription_cancelled", "provider": "stripe", "provider_refund_id": "re_1", "amount_refunded": 5,
+	"payment_status": "refunded", "fully_refunded": true, "approved_by": "manager@example.com",
+	"refunded_at": "2024-03-05T09:00:00Z"}`,
+	kafka.TopicShipmentLabelCreated: `{` + envelopeFixture + `,
+	"patient_id": "P-100", "shipment_id": "SH-2", "carrier": "shippo", "service_level": "ups_next_day_air",
+	"cost": 42.5, "tracking_number": "1Z999", "tracking_url": "https://track.example.com/1Z999",
+	"label_url": "https://labels.example.com/1Z999.pdf", "documents": 2, "deliver_by": "2024-03-06T09:00:00Z",
+	"cold_chain": true, "ship_on": "2024-03-05T00:00:00Z", "packaging": "insulated shipper with gel packs",
+	"replaces_shipment_id": "SH-1", "created_at": "2024-03-05T09:00:00Z"}`,
+	kafka.TopicShipmentTemperatureExcursion: `{` + envelopeFixture + `,
+	"shipment_id": "SH-1", "patient_id": "P-100", "tracking_number": "1Z999", "source": "data_logger",
+	"reported_by": "ops@example.com", "observed_at": "2024-03-05T09:00:00Z", "temperature_c": 11.5,
+	"min_temp_c": 2, "max_temp_c": 8}`,
+	kafka.TopicShipmentDelivered: `{` + envelopeFixture + `,
+	"shipment_id": "SH-1", "patient_id": "P-100", "carrier": "shippo", "tracking_number": "1Z999",
+	"delivered_at": "2024-03-06T09:00:00Z", "cold_chain": false, "details": "Left at front door", "location": "Austin, TX"}`,
+	kafka.TopicDeadLetterQueue: `{` + envelopeFixture + `,
+	"original_topic": "payment.completed", "original_key": "65f0c1a2b3c4d5e6f7a8b9c0", "original_value": "{}",
+	"error": "ShippingWorker: carrier unavailable", "failed_at": "2024-03-05T09:00:00Z", "partition": 3, "offset": 42}`,
+}
+
+// TestContracts_CoverEveryTopic tests that every Kafka topic has an event contract
+func TestContracts_CoverEveryTopic(t *testing.T) {
+	topics := []string{
+		kafka.TopicIntakeReceived,
+		kafka.TopicValidationCompleted,
+		kafka.TopicDURReviewRequested,
+		kafka.TopicEnrollmentCompleted,
+		kafka.TopicEnrollmentLinkCreated,
+		kafka.TopicPharmacySelected,
+		kafka.TopicAdjudicationCompleted,
+		kafka.TopicPrescriptionException,
+		kafka.TopicPriorAuthUpdated,
+		kafka.TopicPaymentLinkCreated,
+		kafka.TopicPaymentCompleted,
+		kafka.TopicVerificationCompleted,
+		kafka.TopicPaymentRefunded,
+		kafka.TopicShipmentLabelCreated,
+		kafka.TopicShipmentTemperatureExcursion,
+		kafka.TopicShipmentDelivered,
+		kafka.TopicDeadLetterQueue,
+	}
+	if len(Topics()) != len(topics) {
+		t.Errorf("Expected %d contracts, got %d: %v", len(topics), len(Topics()), Topics())
+	}
+
+	for _, topic := range topics {
+		event, err := New(topic)
+		if err != nil {
+			t.Errorf("%s: expected a contract, got %v", topic, err)
+			continue
+		}
+		if event.Topic() != topic {
+			t.Errorf("%s: expected the event type to publish on its topic, got %s", topic, event.Topic())
+		}
+		if _, ok := contractFixtures[topic]; !ok {
+			t.Errorf("%s: expected a contract fixture", topic)
+		}
+	}
+
+	if _, err := New("prescription.unknown"); !errors.Is(err, ErrUnknownTopic) {
+		t.Errorf("Expected ErrUnknownTopic for an unknown topic, got %v", err)
+	}
+}
+
+// TestContracts_Fixtures tests that each topic's event reads and writes exactly the fields of its fixture
+func TestContracts_Fixtures(t *testing.T) {
+	for topic, fixture := range contractFixtures {
+		event, err := New(topic)
+		if err != nil {
+			t.Fatalf("%s: %v", topic, err)
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(fixture))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(event); err != nil {
+			t.Errorf("%s: expected the fixture to decode without unknown fields, got %v", topic, err)
+			continue
+		}
+
+		encoded, err := Encode(event)
+		if err != nil {
+			t.Errorf("%s: expected the event to encode, got %v", topic, err)
+			continue
+		}
+
+		want, got := fieldPaths(t, []byte(fixture)), fieldPaths(t, encoded)
+		if strings.Join(want, ",") != strings.Join(got, ",") {
+			t.Errorf("%s: expected fields\n%v\ngot\n%v", topic, want, got)
+		}
+	}
+}
+
+// TestDecode_LegacyEvent tests that events published before schema versions decode as version 1
+func TestDecode_LegacyEvent(t *testing.T) {
+	legacy := `{"event_id": "evt-1", "correlation_id": "corr-1", "prescription_id": "rx-1",
+		"timestamp": "2024-03-05T09:00:00Z", "patient_id": "P-100", "enrolled_at": "2024-03-05T09:00:00Z"}`
+
+	var event EnrollmentCompleted
+	if err := Decode([]byte(legacy), &event); err != nil {
+		t.Fatalf("Expected a legacy event to decode, got %v", err)
+	}
+	if event.SchemaVersion != 1 {
+		t.Errorf("Expected schema version 1, got %d", event.SchemaVersion)
+	}
+	if event.PatientID != "P-100" || event.CorrelationID != "corr-1" || event.EnrolledAt.IsZero() {
+		t.Errorf("Unexpected legacy event %+v", event)
+	}
+}
+
+// TestDecode_NewerVersion tests that an event newer than the compiled contract is refused
+func TestDecode_NewerVersion(t *testing.T) {
+	newer := `{"event_id": "evt-1", "schema_version": 2, "prescription_id": "rx-1", "patient_id": "P-100"}`
+
+	var event EnrollmentCompleted
+	if err := Decode([]byte(newer), &event); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+// TestEncode tests that encoding stamps the schema version and requires an event ID
+func TestEncode(t *testing.T) {
+	event := &EnrollmentCompleted{Envelope: NewEnvelope(context.Background(), "corr-1", "rx-1"), PatientID: "P-100"}
+	data, err := Encode(event)
+	if err != nil {
+		t.Fatalf("Expected the event to encode, got %v", err)
+	}
+	if !bytes.Contains(data, []byte(`"schema_version":1`)) {
+		t.Errorf("Expected the schema version in %s", data)
+	}
+
+	envelope, err := ReadEnvelope(data)
+	if err != nil {
+		t.Fatalf("Expected the envelope to read back, got %v", err)
+	}
+	if envelope.EventID != event.EventID || envelope.CorrelationID != "corr-1" || envelope.PrescriptionID != "rx-1" {
+		t.Errorf("Unexpected envelope %+v", envelope)
+	}
+
+	if _, err := Encode(&EnrollmentCompleted{PatientID: "P-100"}); !errors.Is(err, ErrMissingEventID) {
+		t.Errorf("Expected ErrMissingEventID, got %v", err)
+	}
+}
+
+// TestNewEnvelope tests that the producer and cause are taken from the context
+func TestNewEnvelope(t *testing.T) {
+	envelope := NewEnvelope(context.Background(), "corr-1", "rx-1")
+	if envelope.EventID == "" || envelope.OccurredAt.IsZero() {
+		t.Errorf("Expected an event ID and time, got %+v", envelope)
+	}
+	if envelope.Producer != DefaultProducer || envelope.CausationID != "" {
+		t.Errorf("Expected the default producer and no cause, got %+v", envelope)
+	}
+
+	cause := Envelope{EventID: "evt-1", CorrelationID: "corr-1"}
+	ctx := WithCause(WithProducer(context.Background(), "RoutingWorker"), cause)
+	envelope = NewEnvelope(ctx, "corr-1", "rx-1")
+	if envelope.Producer != "RoutingWorker" {
+		t.Errorf("Expected producer RoutingWorker, got %s", envelope.Producer)
+	}
+	if envelope.CausationID != "evt-1" {
+		t.Errorf("Expected causation ID evt-1, got %s", envelope.CausationID)
+	}
+
+	// An empty correlation ID continues the flow of the event being handled
+	envelope = NewEnvelope(ctx, "", "rx-1")
+	if envelope.CorrelationID != "corr-1" {
+		t.Errorf("Expected correlation ID corr-1 from the cause, got %s", envelope.CorrelationID)
+	}
+	if CausationID(ctx) != "evt-1" || CausationID(context.Background()) != "" {
+		t.Errorf("Expected causation ID evt-1 inside a handler and none outside")
+	}
+}
+
+// fieldPaths returns the sorted paths of every field in a JSON document, looking into the first array element
+func fieldPaths(t *testing.T, data []byte) []string {
+	t.Helper()
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Failed to unmarshal %s: %v", data, err)
+	}
+	var paths []string
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				path := key
+				if prefix != "" {
+					path = prefix + "." + key
+				}
+				paths = append(paths, path)
+				walk(path, child)
+			}
+		case []interface{}:
+			if len(v) > 0 {
+				walk(prefix+"[]", v[0])
+			}
+		}
+	}
+	walk("", doc)
+	sort.Strings(paths)
+	return paths
+}
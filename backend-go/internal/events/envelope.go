@@ -0,0 +1,168 @@
+// Package events defines the versioned contracts of the events published on each Kafka topic
+// Every event is a Go struct embedding the shared Envelope. Producers build one with NewEnvelope and
+// publish it with Encode; consumers read it back with Decode, which refuses schema versions newer
+// than the ones compiled in so a breaking change fails loudly instead of decoding to zero values.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultProducer is recorded as the producer of events published by a service that named none
+const DefaultProducer = "backend-go"
+
+var (
+	// ErrUnknownTopic is returned for an event whose topic has no contract
+	ErrUnknownTopic = errors.New("no event contract for topic")
+	// ErrUnsupportedVersion is returned when an event's schema is newer than this build understands
+	ErrUnsupportedVersion = errors.New("unsupported event schema version")
+	// ErrMissingEventID is returned when an event is encoded without an event ID
+	ErrMissingEventID = errors.New("event has no event_id")
+)
+
+// Envelope is the metadata every event carries
+// CorrelationID ties together everything that follows from one intake request; CausationID is the
+// event_id of the event whose handling published this one, empty for events started by an API call
+// or the scheduler.
+type Envelope struct {
+	EventID        string    `json:"event_id"`
+	SchemaVersion  int       `json:"schema_version"`
+	CorrelationID  string    `json:"correlation_id,omitempty"`
+	CausationID    string    `json:"causation_id,omitempty"`
+	PrescriptionID string    `json:"prescription_id,omitempty"`
+	Producer       string    `json:"producer,omitempty"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// Event is a typed event published on a Kafka topic
+type Event interface {
+	// Topic returns the Kafka topic the event is published on
+	Topic() string
+	// Metadata returns the event's envelope
+	Metadata() *Envelope
+}
+
+// Metadata returns the envelope, so every struct embedding it implements Event
+func (e *Envelope) Metadata() *Envelope {
+	return e
+}
+
+// producerKey and causeKey are the context keys of the producer name and the event being handled
+type producerKey struct{}
+type causeKey struct{}
+
+// defaultProducer names the running service; see SetDefaultProducer
+var defaultProducer = DefaultProducer
+
+// SetDefaultProducer names the service recorded as producer when the context does not name one
+// Each command calls it once at startup, before publishing anything.
+func SetDefaultProducer(name string) {
+	defaultProducer = name
+}
+
+// WithProducer returns a context whose events are recorded as published by the named component
+func WithProducer(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, producerKey{}, name)
+}
+
+// WithCause returns a context whose events are recorded as caused by the given event
+func WithCause(ctx context.Context, cause Envelope) context.Context {
+	return context.WithValue(ctx, causeKey{}, cause)
+}
+
+// Cause returns the envelope of the event being handled, if the context carries one
+func Cause(ctx context.Context) (Envelope, bool) {
+	cause, ok := ctx.Value(causeKey{}).(Envelope)
+	return cause, ok
+}
+
+// Producer returns the name of the component publishing from the context, or the service's name
+func Producer(ctx context.Context) string {
+	if producer, ok := ctx.Value(producerKey{}).(string); ok && producer != "" {
+		return producer
+	}
+	return defaultProducer
+}
+
+// CausationID returns the event_id of the event being handled, or "" outside a handler
+func CausationID(ctx context.Context) string {
+	cause, _ := Cause(ctx)
+	return cause.EventID
+}
+
+// NewEnvelope returns the metadata for a new event about a prescription
+// The producer and causation ID come from the context, and an empty correlation ID is inherited
+// from the event being handled; the schema version is stamped by Encode.
+func NewEnvelope(ctx context.Context, correlationID, prescriptionID string) Envelope {
+	envelope := Envelope{
+		EventID:        uuid.New().String(),
+		CorrelationID:  correlationID,
+		PrescriptionID: prescriptionID,
+		Producer:       Producer(ctx),
+		OccurredAt:     time.Now().UTC(),
+	}
+	if cause, ok := Cause(ctx); ok {
+		envelope.CausationID = cause.EventID
+		if envelope.CorrelationID == "" {
+			envelope.CorrelationID = cause.CorrelationID
+		}
+	}
+	return envelope
+}
+
+// Encode stamps an event with its topic's current schema version and marshals it
+func Encode(event Event) ([]byte, error) {
+	version, err := Version(event.Topic())
+	if err != nil {
+		return nil, err
+	}
+	meta := event.Metadata()
+	if meta.EventID == "" {
+		return nil, fmt.Errorf("%w: %s", ErrMissingEventID, event.Topic())
+	}
+	if meta.OccurredAt.IsZero() {
+		meta.OccurredAt = time.Now().UTC()
+	}
+	meta.SchemaVersion = version
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s event: %w", event.Topic(), err)
+	}
+	return data, nil
+}
+
+// Decode unmarshals a message into the typed event for its topic
+// Events published before schema versions were introduced carry none and are read as version 1.
+func Decode(data []byte, event Event) error {
+	version, err := Version(event.Topic())
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, event); err != nil {
+		return fmt.Errorf("failed to unmarshal %s event: %w", event.Topic(), err)
+	}
+	meta := event.Metadata()
+	if meta.SchemaVersion == 0 {
+		meta.SchemaVersion = 1
+	}
+	if meta.SchemaVersion > version {
+		return fmt.Errorf("%w: %s event %s is version %d, this build reads up to %d", ErrUnsupportedVersion, event.Topic(), meta.EventID, meta.SchemaVersion, version)
+	}
+	return nil
+}
+
+// ReadEnvelope returns the envelope of a message without decoding its payload
+func ReadEnvelope(data []byte) (Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return Envelope{}, fmt.Errorf("failed to unmarshal event envelope: %w", err)
+	}
+	return envelope, nil
+}
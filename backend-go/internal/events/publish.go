@@ -0,0 +1,118 @@
+// Package events defines the versioned contracts of the events published on each Kafka topic
+package events
+
+import (
+	"context"
+	"log"
+
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+// Publish encodes a typed event and publishes it on its topic, keyed by prescription ID
+func Publish(ctx context.Context, producer kafka.Producer, event Event) error {
+	meta := event.Metadata()
+	eventBytes, err := Encode(event)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to encode event for topic %s: %v", meta.CorrelationID, event.Topic(), err)
+		return err
+	}
+
+	if err := producer.Publish(ctx, event.Topic(), meta.PrescriptionID, eventBytes); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to publish event to topic %s: %v", meta.CorrelationID, event.Topic(), err)
+		return err
+	}
+
+	log.Printf("✅ [correlation_id=%s] Published event: topic=%s, prescription_id=%s, event_id=%s", meta.CorrelationID, event.Topic(), meta.PrescriptionID, meta.EventID)
+	return nil
+}
+
+// PublishException publishes a prescription.exception event so ops can pick up the prescription
+func PublishException(ctx context.Context, producer kafka.Producer, correlationID, prescriptionID string, exception models.PrescriptionException) error {
+	return Publish(ctx, producer, &PrescriptionException{
+		Envelope:      NewEnvelope(ctx, correlationID, prescriptionID),
+		ExceptionType: exception.Type,
+		Reason:        exception.Reason,
+		Codes:         exception.Codes,
+		Source:        exception.Source,
+		RaisedAt:      exception.RaisedAt,
+	})
+}
+
+// PublishPriorAuthUpdated publishes a prior_authorization.updated event for a status transition
+func PublishPriorAuthUpdated(ctx context.Context, producer kafka.Producer, correlationID string, pa *models.PriorAuthorization, from models.PriorAuthStatus) error {
+	return Publish(ctx, producer, &PriorAuthUpdated{
+		Envelope:             NewEnvelope(ctx, correlationID, pa.PrescriptionID.Hex()),
+		PriorAuthorizationID: pa.ID.Hex(),
+		PatientID:            pa.PatientID,
+		NDC:                  pa.NDC,
+		Status:               string(pa.Status),
+		PreviousStatus:       string(from),
+		Source:               pa.Source,
+		ExpiresAt:            pa.ExpiresAt,
+		DenialReason:         pa.DenialReason,
+		UpdatedAt:            pa.UpdatedAt,
+	})
+}
+
+// PublishPaymentRefunded publishes a payment.refunded event for an issued refund
+func PublishPaymentRefunded(ctx context.Context, producer kafka.Producer, correlationID string, refund *models.Refund, payment *models.Payment) error {
+	return Publish(ctx, producer, &PaymentRefunded{
+		Envelope:         NewEnvelope(ctx, correlationID, refund.PrescriptionID),
+		RefundID:         refund.ID.Hex(),
+		PaymentID:        payment.ID.Hex(),
+		PatientID:        refund.PatientID,
+		Amount:           refund.Amount,
+		Currency:         refund.Currency,
+		Reason:           string(refund.Reason),
+		Provider:         refund.Provider,
+		ProviderRefundID: refund.ProviderRefundID,
+		AmountRefunded:   payment.AmountRefunded,
+		PaymentStatus:    string(payment.Status),
+		FullyRefunded:    payment.Status == models.PaymentRefunded,
+		ApprovedBy:       refund.ApprovedBy,
+		RefundedAt:       refund.CompletedAt,
+	})
+}
+
+// PublishTemperatureExcursion publishes a shipment.temperature_excursion event for a flagged cold chain shipment
+func PublishTemperatureExcursion(ctx context.Context, producer kafka.Producer, correlationID string, shipment *models.Shipment, excursion models.TemperatureExcursion) error {
+	event := &ShipmentTemperatureExcursion{
+		Envelope:       NewEnvelope(ctx, correlationID, shipment.PrescriptionID.Hex()),
+		ShipmentID:     shipment.ID.Hex(),
+		PatientID:      shipment.PatientID,
+		TrackingNumber: shipment.TrackingNumber,
+		Source:         string(excursion.Source),
+		ReportedBy:     excursion.ReportedBy,
+		ObservedAt:     excursion.ObservedAt,
+		TemperatureC:   excursion.TemperatureC,
+	}
+	if shipment.ColdChain != nil {
+		event.MinTempC = &shipment.ColdChain.MinTempC
+		event.MaxTempC = &shipment.ColdChain.MaxTempC
+	}
+
+	return Publish(ctx, producer, event)
+}
+
+// PublishShipmentDelivered publishes shipment.delivered once the carrier confirms delivery
+func PublishShipmentDelivered(ctx context.Context, producer kafka.Producer, correlationID string, shipment *models.Shipment) error {
+	event := &ShipmentDelivered{
+		Envelope:       NewEnvelope(ctx, correlationID, shipment.PrescriptionID.Hex()),
+		ShipmentID:     shipment.ID.Hex(),
+		PatientID:      shipment.PatientID,
+		Carrier:        shipment.Carrier,
+		TrackingNumber: shipment.TrackingNumber,
+		DeliveredAt:    shipment.UpdatedAt,
+		ColdChain:      shipment.ColdChain != nil,
+	}
+	if shipment.DeliveredAt != nil {
+		event.DeliveredAt = *shipment.DeliveredAt
+	}
+	if n := len(shipment.TrackingEvents); n > 0 {
+		event.Details = shipment.TrackingEvents[n-1].Details
+		event.Location = shipment.TrackingEvents[n-1].Location
+	}
+
+	return Publish(ctx, producer, event)
+}
@@ -0,0 +1,329 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AdjudicationHandler handles claim results reported back by the dispensing pharmacy
+type AdjudicationHandler struct {
+	deps          *Dependencies
+	adjudications *services.AdjudicationService
+}
+
+// AdjudicationResultsRequest is the body pharmacies post after billing the primary and secondary claims
+type AdjudicationResultsRequest struct {
+	PrescriptionID       string                `json:"prescription_id"`
+	PrimaryInsurance     PrimaryClaimResults   `json:"primary_insurance"`
+	ManufacturerPrograms []models.ProgramClaim `json:"manufacturer_programs"`
+	CostBreakdown        *models.CostBreakdown `json:"cost_breakdown,omitempty"`
+}
+
+// PrimaryClaimResults is the pharmacy's primary insurance claim response
+type PrimaryClaimResults struct {
+	ClaimID          string   `json:"claim_id"`
+	Status           string   `json:"status"` // approved, paid, partial or rejected
+	Message          string   `json:"message,omitempty"`
+	RejectCodes      []string `json:"reject_codes,omitempty"`
+	Tier             int      `json:"tier,omitempty"`
+	ApprovedQuantity int      `json:"approved_quantity,omitempty"`
+	DaysSupply       int      `json:"days_supply,omitempty"`
+	DrugCost         float64  `json:"drug_cost"`
+	InsurancePaid    float64  `json:"insurance_paid"`
+	PatientCopay     float64  `json:"patient_copay"`
+}
+
+// primaryClaimStatuses maps the statuses pharmacies report to claim statuses
+var primaryClaimStatuses = map[string]models.ClaimStatus{
+	"approved": models.ClaimStatusPaid,
+	"paid":     models.ClaimStatusPaid,
+	"partial":  models.ClaimStatusPartial,
+	"rejected": models.ClaimStatusRejected,
+}
+
+// NewAdjudicationHandler creates a new adjudication handler
+func NewAdjudicationHandler(deps *Dependencies) *AdjudicationHandler {
+	return &AdjudicationHandler{
+		deps:          deps,
+		adjudications: services.NewAdjudicationService(deps.MongoClient, deps.Redis, deps.KafkaProducer),
+	}
+}
+
+// SubmitResults handles POST /api/v1/adjudication/results
+// The authenticated pharmacy must be the one assigned to the prescription. Results are idempotent
+// by claim ID: replaying a claim already recorded finishes its follow-up if that failed, then returns
+// the stored record.
+func (h *AdjudicationHandler) SubmitResults(w http.ResponseWriter, r *http.Request) {
+	pharmacy := middleware.GetPharmacy(r)
+	if pharmacy == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req AdjudicationResultsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := ValidateAdjudicationResults(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	prescriptionID, err := primitive.ObjectIDFromHex(req.PrescriptionID)
+	if err != nil {
+		http.Error(w, "Invalid prescription_id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Replays of a claim already recorded resume it and return the stored result
+	existing, err := h.findClaim(ctx, pharmacy.ID, req.PrimaryInsurance.ClaimID)
+	if err != nil {
+		log.Printf("Error looking up claim %s: %v", req.PrimaryInsurance.ClaimID, err)
+		http.Error(w, "Failed to look up claim", http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		if existing.PrescriptionID != prescriptionID {
+			http.Error(w, "Claim ID already recorded for another prescription", http.StatusConflict)
+			return
+		}
+		correlationID := flowCorrelationID(r, existing.CorrelationID)
+		if _, err := h.adjudications.Resume(ctx, correlationID, models.StatusAwaitingAdjudication, existing); err != nil {
+			log.Printf("[correlation_id=%s] Error resuming adjudication for prescription %s: %v", correlationID, prescriptionID.Hex(), err)
+			http.Error(w, "Failed to record adjudication results", http.StatusInternalServerError)
+			return
+		}
+		writeAdjudicationRecord(w, http.StatusOK, existing)
+		return
+	}
+
+	var prescription models.Prescription
+	err = h.deps.MongoClient.GetCollection("prescriptions").FindOne(ctx, bson.M{"_id": prescriptionID}).Decode(&prescription)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Prescription not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading prescription %s: %v", prescriptionID.Hex(), err)
+		http.Error(w, "Failed to load prescription", http.StatusInternalServerError)
+		return
+	}
+
+	if prescription.PharmacyID != pharmacy.ID {
+		http.Error(w, "Prescription is not assigned to this pharmacy", http.StatusForbidden)
+		return
+	}
+	if prescription.Status != models.StatusAwaitingAdjudication {
+		http.Error(w, fmt.Sprintf("Prescription is %s, not awaiting adjudication", prescription.Status), http.StatusConflict)
+		return
+	}
+
+	record := BuildAdjudicationRecord(&req, &prescription, time.Now())
+	record.PrescriptionID = prescriptionID
+	record.PharmacyID = pharmacy.ID
+
+	correlationID := flowCorrelationID(r, prescription.CorrelationID)
+	err = h.adjudications.Complete(ctx, correlationID, models.StatusAwaitingAdjudication, record)
+	if errors.Is(err, services.ErrDuplicateClaim) {
+		// Lost a race with a concurrent replay of the same claim
+		existing, err = h.findClaim(ctx, pharmacy.ID, req.PrimaryInsurance.ClaimID)
+		if err == nil && existing != nil {
+			writeAdjudicationRecord(w, http.StatusOK, existing)
+			return
+		}
+		http.Error(w, "Claim already recorded", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, services.ErrAdjudicationConflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[correlation_id=%s] Error completing adjudication for prescription %s: %v", correlationID, prescriptionID.Hex(), err)
+		http.Error(w, "Failed to record adjudication results", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[correlation_id=%s] Adjudication results for prescription %s recorded from pharmacy %s (claim %s)", correlationID, prescriptionID.Hex(), pharmacy.NCPDPID, record.Result.ClaimID)
+
+	writeAdjudicationRecord(w, http.StatusCreated, record)
+}
+
+// findClaim returns the adjudication record the pharmacy already reported for a claim ID, or nil
+func (h *AdjudicationHandler) findClaim(ctx context.Context, pharmacyID, claimID string) (*models.AdjudicationRecord, error) {
+	var record models.AdjudicationRecord
+	err := h.deps.MongoClient.GetCollection("adjudications").FindOne(ctx, bson.M{
+		"pharmacy_id":     pharmacyID,
+		"result.claim_id": claimID,
+	}).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// writeAdjudicationRecord writes an adjudication record as the JSON response
+func writeAdjudicationRecord(w http.ResponseWriter, status int, record *models.AdjudicationRecord) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(record)
+}
+
+// ValidateAdjudicationResults checks a results payload is complete and internally consistent
+func ValidateAdjudicationResults(req *AdjudicationResultsRequest) error {
+	req.PrescriptionID = strings.TrimSpace(req.PrescriptionID)
+	req.PrimaryInsurance.ClaimID = strings.TrimSpace(req.PrimaryInsurance.ClaimID)
+	req.PrimaryInsurance.Status = strings.ToLower(strings.TrimSpace(req.PrimaryInsurance.Status))
+
+	primary := req.PrimaryInsurance
+	if req.PrescriptionID == "" {
+		return errors.New("prescription_id is required")
+	}
+	if primary.ClaimID == "" {
+		return errors.New("primary_insurance.claim_id is required")
+	}
+	status, ok := primaryClaimStatuses[primary.Status]
+	if !ok {
+		return fmt.Errorf("primary_insurance.status %q is not one of approved, paid, partial, rejected", primary.Status)
+	}
+	if primary.DrugCost < 0 || primary.InsurancePaid < 0 || primary.PatientCopay < 0 {
+		return errors.New("primary_insurance amounts must not be negative")
+	}
+
+	if status == models.ClaimStatusRejected {
+		if len(primary.RejectCodes) == 0 {
+			return errors.New("primary_insurance.reject_codes is required for a rejected claim")
+		}
+		return nil
+	}
+
+	if !centsEqual(primary.InsurancePaid+primary.PatientCopay, primary.DrugCost) {
+		return errors.New("primary_insurance.insurance_paid and patient_copay must add up to drug_cost")
+	}
+
+	discount := 0.0
+	for _, pc := range req.ManufacturerPrograms {
+		if pc.ProgramID == "" {
+			return errors.New("manufacturer_programs.program_id is required")
+		}
+		if pc.Status != models.ProgramClaimApproved && pc.Status != models.ProgramClaimDenied {
+			return fmt.Errorf("manufacturer program %s status %q is not one of approved, denied", pc.ProgramID, pc.Status)
+		}
+		if pc.DiscountAmount < 0 {
+			return fmt.Errorf("manufacturer program %s discount_amount must not be negative", pc.ProgramID)
+		}
+		if pc.Status == models.ProgramClaimApproved {
+			discount += pc.DiscountAmount
+		}
+	}
+	if discount > primary.PatientCopay+0.005 {
+		return errors.New("manufacturer program discounts exceed the patient copay")
+	}
+
+	if b := req.CostBreakdown; b != nil {
+		if !centsEqual(b.TotalDrugCost, primary.DrugCost) ||
+			!centsEqual(b.InsuranceCovered, primary.InsurancePaid) ||
+			!centsEqual(b.InitialCopay, primary.PatientCopay) {
+			return errors.New("cost_breakdown does not match primary_insurance")
+		}
+		if !centsEqual(b.ManufacturerDiscount, discount) {
+			return errors.New("cost_breakdown.manufacturer_discount does not match manufacturer_programs")
+		}
+		if !centsEqual(b.FinalPatientCopay, b.InitialCopay-b.ManufacturerDiscount) {
+			return errors.New("cost_breakdown.final_patient_copay must equal initial_copay minus manufacturer_discount")
+		}
+	}
+
+	return nil
+}
+
+// BuildAdjudicationRecord converts a validated results payload into an adjudication record
+// The cost breakdown is derived from the claims when the pharmacy does not send one
+func BuildAdjudicationRecord(req *AdjudicationResultsRequest, prescription *models.Prescription, at time.Time) *models.AdjudicationRecord {
+	primary := req.PrimaryInsurance
+	status := primaryClaimStatuses[primary.Status]
+
+	result := models.ClaimResult{
+		ClaimID:           primary.ClaimID,
+		Status:            status,
+		Message:           primary.Message,
+		Tier:              primary.Tier,
+		RequestedQuantity: prescription.Medication.Quantity,
+		ApprovedQuantity:  primary.ApprovedQuantity,
+		DaysSupply:        primary.DaysSupply,
+		TotalCost:         primary.DrugCost,
+		InsurancePays:     primary.InsurancePaid,
+		CopayAmount:       primary.PatientCopay,
+		AdjudicatedAt:     at,
+	}
+	for _, code := range primary.RejectCodes {
+		result.RejectCodes = append(result.RejectCodes, models.NewRejectCode(code))
+	}
+	if result.ApprovedQuantity == 0 && status == models.ClaimStatusPaid {
+		result.ApprovedQuantity = prescription.Medication.Quantity
+	}
+	if result.DaysSupply == 0 {
+		result.DaysSupply = prescription.Medication.DaysSupply
+	}
+	if status == models.ClaimStatusRejected && result.Message == "" {
+		result.Message = "claim rejected by payer"
+	}
+
+	record := &models.AdjudicationRecord{
+		PatientID:            prescription.PatientID,
+		MemberID:             prescription.Insurance.MemberID,
+		NDC:                  prescription.Medication.NDC,
+		Source:               models.AdjudicationSourcePharmacy,
+		Result:               result,
+		ManufacturerPrograms: req.ManufacturerPrograms,
+	}
+	if status == models.ClaimStatusRejected {
+		return record
+	}
+
+	breakdown := req.CostBreakdown
+	if breakdown == nil {
+		discount := 0.0
+		for _, pc := range req.ManufacturerPrograms {
+			if pc.Status == models.ProgramClaimApproved {
+				discount += pc.DiscountAmount
+			}
+		}
+		breakdown = &models.CostBreakdown{
+			TotalDrugCost:        result.TotalCost,
+			InsuranceCovered:     result.InsurancePays,
+			InitialCopay:         result.CopayAmount,
+			ManufacturerDiscount: discount,
+			FinalPatientCopay:    math.Round((result.CopayAmount-discount)*100) / 100,
+		}
+	}
+	record.CostBreakdown = breakdown
+
+	return record
+}
+
+// centsEqual reports whether two dollar amounts are equal to the cent
+func centsEqual(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
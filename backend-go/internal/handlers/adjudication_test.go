@@ -0,0 +1,141 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+func validResultsRequest() *AdjudicationResultsRequest {
+	return &AdjudicationResultsRequest{
+		PrescriptionID: "65a1b2c3d4e5f60718293a4b",
+		PrimaryInsurance: PrimaryClaimResults{
+			ClaimID:       "CLM123",
+			Status:        "approved",
+			DrugCost:      6500,
+			InsurancePaid: 5000,
+			PatientCopay:  1500,
+		},
+		ManufacturerPrograms: []models.ProgramClaim{
+			{ProgramID: "prog_abbvie_humira_2024", ProgramName: "Humira Complete Savings Card", Status: "approved", DiscountAmount: 1495, ReducedCopay: 5},
+		},
+		CostBreakdown: &models.CostBreakdown{
+			TotalDrugCost:        6500,
+			InsuranceCovered:     5000,
+			InitialCopay:         1500,
+			ManufacturerDiscount: 1495,
+			FinalPatientCopay:    5,
+		},
+	}
+}
+
+// TestValidateAdjudicationResults tests validation of pharmacy adjudication result payloads
+func TestValidateAdjudicationResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(req *AdjudicationResultsRequest)
+		wantErr string
+	}{
+		{"valid", func(req *AdjudicationResultsRequest) {}, ""},
+		{"missing claim id", func(req *AdjudicationResultsRequest) { req.PrimaryInsurance.ClaimID = " " }, "claim_id is required"},
+		{"unknown status", func(req *AdjudicationResultsRequest) { req.PrimaryInsurance.Status = "pending" }, "is not one of"},
+		{"amounts do not add up", func(req *AdjudicationResultsRequest) { req.PrimaryInsurance.InsurancePaid = 4000 }, "must add up to drug_cost"},
+		{"breakdown mismatch", func(req *AdjudicationResultsRequest) { req.CostBreakdown.FinalPatientCopay = 50 }, "final_patient_copay"},
+		{"discount mismatch", func(req *AdjudicationResultsRequest) { req.ManufacturerPrograms[0].DiscountAmount = 1000 }, "manufacturer_discount"},
+		{"negative discount", func(req *AdjudicationResultsRequest) {
+			req.CostBreakdown = nil
+			req.ManufacturerPrograms = append(req.ManufacturerPrograms, models.ProgramClaim{ProgramID: "prog_other", Status: "denied", DiscountAmount: -100})
+		}, "must not be negative"},
+		{"no breakdown", func(req *AdjudicationResultsRequest) { req.CostBreakdown = nil }, ""},
+		{"rejected without codes", func(req *AdjudicationResultsRequest) { req.PrimaryInsurance.Status = "rejected" }, "reject_codes is required"},
+		{"rejected with codes", func(req *AdjudicationResultsRequest) {
+			req.PrimaryInsurance.Status = "Rejected"
+			req.PrimaryInsurance.RejectCodes = []string{"75"}
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validResultsRequest()
+			tt.mutate(req)
+			err := ValidateAdjudicationResults(req)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestBuildAdjudicationRecord tests conversion of a results payload into an adjudication record
+func TestBuildAdjudicationRecord(t *testing.T) {
+	prescription := &models.Prescription{
+		PatientID:  "patient_1",
+		Medication: models.MedicationInfo{NDC: "00074433902", Quantity: 2, DaysSupply: 28},
+		Insurance:  models.InsuranceInfo{MemberID: "M123"},
+	}
+	at := time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC)
+
+	req := validResultsRequest()
+	req.CostBreakdown = nil
+	record := BuildAdjudicationRecord(req, prescription, at)
+
+	if record.Source != models.AdjudicationSourcePharmacy {
+		t.Errorf("Expected source %s, got %s", models.AdjudicationSourcePharmacy, record.Source)
+	}
+	if record.Result.Status != models.ClaimStatusPaid || record.Result.ApprovedQuantity != 2 || record.Result.DaysSupply != 28 {
+		t.Errorf("Unexpected claim result: %+v", record.Result)
+	}
+	if record.CostBreakdown == nil || record.CostBreakdown.FinalPatientCopay != 5 || record.CostBreakdown.ManufacturerDiscount != 1495 {
+		t.Errorf("Expected derived breakdown with final copay 5, got %+v", record.CostBreakdown)
+	}
+
+	req = validResultsRequest()
+	req.PrimaryInsurance.Status = "rejected"
+	req.PrimaryInsurance.RejectCodes = []string{"75"}
+	record = BuildAdjudicationRecord(req, prescription, at)
+	if record.Result.Status != models.ClaimStatusRejected || record.CostBreakdown != nil {
+		t.Errorf("Expected rejected claim without breakdown, got %+v", record)
+	}
+	if codes := record.Result.RejectCodeValues(); len(codes) != 1 || codes[0] != "75" {
+		t.Errorf("Expected reject code 75, got %v", codes)
+	}
+}
+
+// TestAdjudicationHandler_SubmitResults_Unauthenticated tests that results require an authenticated pharmacy
+func TestAdjudicationHandler_SubmitResults_Unauthenticated(t *testing.T) {
+	handler := NewAdjudicationHandler(&Dependencies{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/adjudication/results", strings.NewReader(`{}`))
+	rr := httptest.NewRecorder()
+	handler.SubmitResults(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+// TestAdjudicationHandler_SubmitResults_InvalidPayload tests that invalid payloads are rejected before any lookups
+func TestAdjudicationHandler_SubmitResults_InvalidPayload(t *testing.T) {
+	handler := middleware.PharmacyAuthMiddleware(func(ctx context.Context, apiKey string) (*middleware.AuthPharmacy, error) {
+		return &middleware.AuthPharmacy{ID: "pharmacy_1"}, nil
+	})(http.HandlerFunc(NewAdjudicationHandler(&Dependencies{}).SubmitResults))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/adjudication/results", strings.NewReader(`{"prescription_id":"65a1b2c3d4e5f60718293a4b","primary_insurance":{"status":"approved"}}`))
+	req.Header.Set(middleware.PharmacyAPIKeyHeader, "key")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+}
@@ -0,0 +1,32 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+)
+
+// recordAudit audits the request's access to or changes of entities, under the request's correlation ID
+// It responds 503 and returns false when an entry HIPAA requires could not be stored; the handler must
+// then stop without returning the data or reporting the change as done.
+func recordAudit(w http.ResponseWriter, r *http.Request, entries ...audit.Entry) bool {
+	correlationID := middleware.GetCorrelationID(r)
+	for i := range entries {
+		if entries[i].Details == nil {
+			entries[i].Details = map[string]interface{}{}
+		}
+		if _, ok := entries[i].Details["correlation_id"]; !ok && correlationID != "" {
+			entries[i].Details["correlation_id"] = correlationID
+		}
+	}
+
+	if err := audit.Record(r.Context(), entries...); err != nil {
+		log.Printf("[correlation_id=%s] Error auditing %s: %v", correlationID, r.URL.Path, err)
+		http.Error(w, "Audit log unavailable", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+)
+
+// AuditLogHandler lets compliance staff search the audit log
+type AuditLogHandler struct {
+	deps  *Dependencies
+	store *audit.PostgresStore
+}
+
+// NewAuditLogHandler creates a new audit log handler
+func NewAuditLogHandler(deps *Dependencies, store *audit.PostgresStore) *AuditLogHandler {
+	return &AuditLogHandler{
+		deps:  deps,
+		store: store,
+	}
+}
+
+// List handles GET /api/v1/audit?entity_type={type}&entity_id={id}&user_id={id}&event_type={type}&from={time}&to={time}&cursor={cursor}&limit={n}
+// Entries are returned newest first; from (inclusive) and to (exclusive) are RFC 3339 times, and the
+// next page is requested with the next_cursor of the previous one.
+func (h *AuditLogHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		EntityType: strings.TrimSpace(query.Get("entity_type")),
+		EntityID:   strings.TrimSpace(query.Get("entity_id")),
+		UserID:     strings.TrimSpace(query.Get("user_id")),
+		EventType:  strings.TrimSpace(query.Get("event_type")),
+		Cursor:     strings.TrimSpace(query.Get("cursor")),
+	}
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil {
+		filter.Limit = limit
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		http.Error(w, "from must be an RFC 3339 time", http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		http.Error(w, "to must be an RFC 3339 time", http.StatusBadRequest)
+		return
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		http.Error(w, "to must be after from", http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.store.Query(r.Context(), filter)
+	if errors.Is(err, audit.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("[correlation_id=%s] Error querying audit log: %v", middleware.GetCorrelationID(r), err)
+		http.Error(w, "Failed to query audit log", http.StatusInternalServerError)
+		return
+	}
+
+	// Searches of the audit log are audited too, with what was searched for
+	access := audit.PHIAccess(audit.EntityAuditLog, "audit_logs")
+	access.Details = map[string]interface{}{
+		"entity_type": filter.EntityType,
+		"entity_id":   filter.EntityID,
+		"user_id":     filter.UserID,
+		"event_type":  filter.EventType,
+		"from":        query.Get("from"),
+		"to":          query.Get("to"),
+		"entries":     len(page.Entries),
+	}
+	if !recordAudit(w, r, access) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter
+func parseTimeParam(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,34 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestAuditLogHandler_RequestValidation tests query parameter validation before any database access
+func TestAuditLogHandler_RequestValidation(t *testing.T) {
+	handler := NewAuditLogHandler(&Dependencies{}, nil)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{"invalid from", "?from=yesterday", http.StatusBadRequest},
+		{"invalid to", "?to=2024-01-32T00:00:00Z", http.StatusBadRequest},
+		{"to before from", "?from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z", http.StatusBadRequest},
+		{"invalid cursor", "?entity_type=patient&cursor=bm90LWEtY3Vyc29y", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/audit"+tt.query, nil)
+		rr := httptest.NewRecorder()
+		handler.List(rr, req)
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
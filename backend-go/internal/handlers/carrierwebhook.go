@@ -0,0 +1,104 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"github.com/phil-my-meds/backend-gogit/internal/shipping"
+)
+
+// CarrierWebhookHandler handles carrier tracking webhooks
+// Requests are authenticated by signature rather than by user or API key
+type CarrierWebhookHandler struct {
+	deps      *Dependencies
+	tracking  *services.ShipmentTrackingService
+	secret    string
+	tolerance time.Duration
+}
+
+// CarrierWebhookResponse acknowledges a tracking webhook delivery
+type CarrierWebhookResponse struct {
+	Received       bool   `json:"received"`
+	Event          string `json:"event"`
+	Outcome        string `json:"outcome"` // processed, unchanged or ignored
+	TrackingNumber string `json:"tracking_number,omitempty"`
+	ShipmentID     string `json:"shipment_id,omitempty"`
+	Status         string `json:"status,omitempty"`
+}
+
+// NewCarrierWebhookHandler creates a new carrier webhook handler verifying signatures with secret
+func NewCarrierWebhookHandler(deps *Dependencies, tracking *services.ShipmentTrackingService, secret string) *CarrierWebhookHandler {
+	return &CarrierWebhookHandler{
+		deps:      deps,
+		tracking:  tracking,
+		secret:    secret,
+		tolerance: shipping.DefaultWebhookTolerance,
+	}
+}
+
+// Receive handles POST /api/v1/webhooks/carriers
+// Tracking updates are idempotent: an update already in the shipment's history is acknowledged as
+// unchanged. Updates for parcels we did not ship are acknowledged and ignored so they are not retried.
+func (h *CarrierWebhookHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// The signature covers the raw body, so verify before decoding anything
+	if err := shipping.VerifyWebhookSignature(body, r.Header.Get(shipping.SignatureHeader), h.secret, h.tolerance, time.Now()); err != nil {
+		log.Printf("Rejected carrier webhook: %v", err)
+		http.Error(w, "Invalid signature", http.StatusBadRequest)
+		return
+	}
+
+	event, err := shipping.ParseTrackingWebhook(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := CarrierWebhookResponse{Received: true, Event: event.Event, Outcome: "ignored"}
+	if event.Status == nil {
+		writeCarrierWebhookResponse(w, response)
+		return
+	}
+	response.TrackingNumber = event.Status.TrackingNumber
+
+	shipment, update, err := h.tracking.ApplyUpdate(r.Context(),
+		middleware.GetCorrelationID(r), event.Status, models.TrackingSourceWebhook)
+	if errors.Is(err, services.ErrShipmentNotFound) {
+		log.Printf("Ignoring carrier webhook for unknown tracking number %s", event.Status.TrackingNumber)
+		writeCarrierWebhookResponse(w, response)
+		return
+	}
+	if err != nil {
+		log.Printf("Error applying tracking update for %s: %v", event.Status.TrackingNumber, err)
+		http.Error(w, "Failed to apply tracking update", http.StatusInternalServerError)
+		return
+	}
+
+	response.Outcome = "processed"
+	if !update.Recorded {
+		response.Outcome = "unchanged"
+	}
+	response.ShipmentID = shipment.ID.Hex()
+	response.Status = string(shipment.Status)
+	writeCarrierWebhookResponse(w, response)
+}
+
+// writeCarrierWebhookResponse writes a webhook acknowledgement as the JSON response
+func writeCarrierWebhookResponse(w http.ResponseWriter, response CarrierWebhookResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,67 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/shipping"
+)
+
+// TestCarrierWebhookHandler_RejectsUnverifiedRequests tests that unsigned or mis-signed deliveries are rejected before any lookups
+func TestCarrierWebhookHandler_RejectsUnverifiedRequests(t *testing.T) {
+	handler := NewCarrierWebhookHandler(&Dependencies{}, nil, "trk_test")
+	payload := `{"event":"track_updated","data":{"carrier":"usps","tracking_number":"9400","tracking_status":{"status":"DELIVERED"}}}`
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+	}{
+		{"missing signature", payload, ""},
+		{"wrong secret", payload, shipping.SignWebhookPayload([]byte(payload), "trk_other", time.Now())},
+		{"stale timestamp", payload, shipping.SignWebhookPayload([]byte(payload), "trk_test", time.Now().Add(-time.Hour))},
+		{"body changed after signing", strings.Replace(payload, "9400", "9401", 1), shipping.SignWebhookPayload([]byte(payload), "trk_test", time.Now())},
+		{"signed but malformed", `{"data":{}}`, shipping.SignWebhookPayload([]byte(`{"data":{}}`), "trk_test", time.Now())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/carriers", strings.NewReader(tt.body))
+			if tt.signature != "" {
+				req.Header.Set(shipping.SignatureHeader, tt.signature)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.Receive(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+		})
+	}
+}
+
+// TestCarrierWebhookHandler_IgnoresOtherEvents tests that signed events other than tracking updates are acknowledged without lookups
+func TestCarrierWebhookHandler_IgnoresOtherEvents(t *testing.T) {
+	handler := NewCarrierWebhookHandler(&Dependencies{}, nil, "trk_test")
+	payload := `{"event":"transaction_created","data":{"object_id":"txn_1"}}`
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/carriers", strings.NewReader(payload))
+	req.Header.Set(shipping.SignatureHeader, shipping.SignWebhookPayload([]byte(payload), "trk_test", time.Now()))
+	rec := httptest.NewRecorder()
+
+	handler.Receive(rec, req)
+
+	var response CarrierWebhookResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Expected a JSON acknowledgement, got %v", err)
+	}
+	if rec.Code != http.StatusOK || response.Outcome != "ignored" || response.Event != "transaction_created" {
+		t.Errorf("Expected an ignored acknowledgement, got %d %+v", rec.Code, response)
+	}
+}
@@ -0,0 +1,144 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+)
+
+// CommunicationPreferencesHandler handles patient communication preferences and consent for the ops team
+type CommunicationPreferencesHandler struct {
+	deps        *Dependencies
+	preferences *services.CommunicationPreferenceService
+}
+
+// CommunicationPreferencesRequest represents the request body for updating a patient's preferences
+// Consent is "granted", "revoked" or empty for never asked; the source and time are recorded by the server.
+type CommunicationPreferencesRequest struct {
+	EmailConsent models.ConsentStatus                    `json:"email_consent"`
+	SMSConsent   models.ConsentStatus                    `json:"sms_consent"`
+	SMSNumber    string                                  `json:"sms_number,omitempty"`
+	Timezone     string                                  `json:"timezone,omitempty"`
+	QuietHours   *models.QuietHours                      `json:"quiet_hours,omitempty"`
+	Channels     map[string][]models.NotificationChannel `json:"channels,omitempty"`
+}
+
+// CommunicationPreferencesResponse represents a patient's communication preferences
+type CommunicationPreferencesResponse struct {
+	PatientID   string                           `json:"patient_id"`
+	Preferences *models.CommunicationPreferences `json:"preferences"`
+	SMSOptedOut bool                             `json:"sms_opted_out"` // the texting number replied STOP
+}
+
+// NewCommunicationPreferencesHandler creates a new communication preferences handler
+func NewCommunicationPreferencesHandler(deps *Dependencies, preferences *services.CommunicationPreferenceService) *CommunicationPreferencesHandler {
+	return &CommunicationPreferencesHandler{
+		deps:        deps,
+		preferences: preferences,
+	}
+}
+
+// Get handles GET /api/v1/patients/{id}/communication-preferences
+func (h *CommunicationPreferencesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	patient, err := h.preferences.Get(ctx, id)
+	if errors.Is(err, services.ErrPatientNotFound) {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading communication preferences for patient %s: %v", id, err)
+		http.Error(w, "Failed to load communication preferences", http.StatusInternalServerError)
+		return
+	}
+	if !recordAudit(w, r, audit.PHIAccess(audit.EntityCommunicationPreferences, patient.ID.Hex())) {
+		return
+	}
+
+	prefs := patient.Communication
+	if prefs == nil {
+		prefs = &models.CommunicationPreferences{}
+	}
+	h.writeResponse(w, r, id, prefs)
+}
+
+// Update handles PUT /api/v1/patients/{id}/communication-preferences
+// Replaces the preferences, recording consent changes as made by the ops team
+func (h *CommunicationPreferencesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CommunicationPreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	prefs := models.CommunicationPreferences{
+		Email:      models.ChannelConsent{Status: req.EmailConsent},
+		SMS:        models.ChannelConsent{Status: req.SMSConsent},
+		SMSNumber:  req.SMSNumber,
+		Timezone:   req.Timezone,
+		QuietHours: req.QuietHours,
+		Channels:   req.Channels,
+	}
+	if err := services.ValidatePreferences(&prefs); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	updated, err := h.preferences.Update(r.Context(), id, prefs, models.ConsentSourceOps)
+	switch {
+	case errors.Is(err, services.ErrPatientNotFound):
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrInvalidPreferences):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, services.ErrSMSOptedOut):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, audit.ErrUnavailable):
+		log.Printf("Error auditing communication preferences for patient %s: %v", id, err)
+		http.Error(w, "Audit log unavailable", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		log.Printf("Error updating communication preferences for patient %s: %v", id, err)
+		http.Error(w, "Failed to update communication preferences", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Communication preferences for patient %s updated by %s (email %q, sms %q)", id, user.ID, updated.Email.Status, updated.SMS.Status)
+	h.writeResponse(w, r, id, updated)
+}
+
+// writeResponse writes a patient's preferences, with whether their texting number opted out, as the JSON response
+func (h *CommunicationPreferencesHandler) writeResponse(w http.ResponseWriter, r *http.Request, patientID string, prefs *models.CommunicationPreferences) {
+	response := CommunicationPreferencesResponse{PatientID: patientID, Preferences: prefs}
+	if prefs.SMSNumber != "" {
+		optedOut, err := h.preferences.IsOptedOut(r.Context(), prefs.SMSNumber)
+		if err != nil {
+			log.Printf("Error checking SMS opt-out for patient %s: %v", patientID, err)
+			http.Error(w, "Failed to load communication preferences", http.StatusInternalServerError)
+			return
+		}
+		response.SMSOptedOut = optedOut
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
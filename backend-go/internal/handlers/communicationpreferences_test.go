@@ -0,0 +1,52 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+)
+
+// TestCommunicationPreferencesHandler_UpdateValidation tests preference validation before any database access
+func TestCommunicationPreferencesHandler_UpdateValidation(t *testing.T) {
+	handler := NewCommunicationPreferencesHandler(&Dependencies{}, nil)
+	user := &middleware.AuthUser{ID: "ops_1", Role: middleware.RoleOpsManager}
+
+	tests := []struct {
+		name       string
+		body       string
+		user       *middleware.AuthUser
+		wantStatus int
+	}{
+		{"unauthenticated", `{"sms_consent":"granted"}`, nil, http.StatusUnauthorized},
+		{"malformed body", `{`, user, http.StatusBadRequest},
+		{"unknown consent", `{"sms_consent":"yes please"}`, user, http.StatusUnprocessableEntity},
+		{"bad number", `{"sms_consent":"granted","sms_number":"555-01"}`, user, http.StatusUnprocessableEntity},
+		{"unknown timezone", `{"timezone":"Eastern Time"}`, user, http.StatusUnprocessableEntity},
+		{"bad quiet hours", `{"quiet_hours":{"start":"9pm","end":"8am"}}`, user, http.StatusUnprocessableEntity},
+		{"unknown channel", `{"channels":{"payment_link":["pager"]}}`, user, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/patients/65a000000000000000000001/communication-preferences", bytes.NewBufferString(tt.body))
+
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", "65a000000000000000000001")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx)
+		if tt.user != nil {
+			ctx = context.WithValue(ctx, middleware.UserKey{}, tt.user)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.Update(rr, req.WithContext(ctx))
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
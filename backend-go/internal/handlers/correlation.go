@@ -0,0 +1,71 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+)
+
+// CorrelationHandler reconstructs the flow of an intake request for the ops team
+type CorrelationHandler struct {
+	deps         *Dependencies
+	correlations *services.CorrelationService
+}
+
+// NewCorrelationHandler creates a new correlation handler
+func NewCorrelationHandler(deps *Dependencies, correlations *services.CorrelationService) *CorrelationHandler {
+	return &CorrelationHandler{
+		deps:         deps,
+		correlations: correlations,
+	}
+}
+
+// Get handles GET /api/v1/correlations/{id}
+// It returns every event published, prescription status change and record written under the correlation ID
+func (h *CorrelationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(chi.URLParam(r, "id"))
+	if id == "" {
+		http.Error(w, "Correlation ID is required", http.StatusBadRequest)
+		return
+	}
+
+	trace, err := h.correlations.Trace(r.Context(), id)
+	if errors.Is(err, services.ErrCorrelationNotFound) {
+		http.Error(w, "Correlation ID not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[correlation_id=%s] Error tracing correlation ID %s: %v", middleware.GetCorrelationID(r), id, err)
+		http.Error(w, "Failed to trace correlation ID", http.StatusInternalServerError)
+		return
+	}
+	accessed := []audit.Entry{audit.PHIAccess(audit.EntityCorrelation, id)}
+	for _, prescriptionID := range trace.Prescriptions {
+		accessed = append(accessed, audit.PHIAccess(audit.EntityPrescription, prescriptionID))
+	}
+	if !recordAudit(w, r, accessed...) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(trace)
+}
+
+// flowCorrelationID returns the correlation ID an API call continuing an existing flow records its work under
+// That is the one stored on the record being acted on, so the trace of the intake request includes the
+// call; records written before correlation IDs were stored fall back to the request's.
+func flowCorrelationID(r *http.Request, stored string) string {
+	if stored != "" {
+		return stored
+	}
+	return middleware.GetCorrelationID(r)
+}
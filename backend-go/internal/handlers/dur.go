@@ -0,0 +1,256 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+)
+
+// durReviewStatuses are the statuses DUR reviews may be listed by
+var durReviewStatuses = map[models.DURReviewStatus]bool{
+	models.DURReviewPending:  true,
+	models.DURReviewApproved: true,
+	models.DURReviewRejected: true,
+}
+
+// DURHandler handles the pharmacist DUR review queue and the patient allergies it checks against
+type DURHandler struct {
+	deps *Dependencies
+	dur  *services.DURService
+}
+
+// DURDecisionRequest represents the request body for a pharmacist's decision on a DUR review
+type DURDecisionRequest struct {
+	Decision models.DURReviewStatus `json:"decision"` // approved or rejected
+	Note     string                 `json:"note,omitempty"`
+}
+
+// AllergiesRequest represents the request body for replacing a patient's allergies
+type AllergiesRequest struct {
+	Allergies []models.Allergy `json:"allergies"`
+}
+
+// AllergiesResponse represents a patient's recorded allergies
+type AllergiesResponse struct {
+	PatientID string           `json:"patient_id"`
+	Allergies []models.Allergy `json:"allergies"`
+}
+
+// NewDURHandler creates a new DUR handler
+func NewDURHandler(deps *Dependencies, dur *services.DURService) *DURHandler {
+	return &DURHandler{
+		deps: deps,
+		dur:  dur,
+	}
+}
+
+// ListReviews handles GET /api/v1/dur-reviews?status={status}&severity={severity}&prescription_id={id}&limit={n}
+// The queue is listed most severe first, oldest first within a severity
+func (h *DURHandler) ListReviews(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := services.DURReviewFilter{
+		Status:         models.DURReviewStatus(strings.TrimSpace(query.Get("status"))),
+		Severity:       models.DURSeverity(strings.TrimSpace(query.Get("severity"))),
+		PrescriptionID: strings.TrimSpace(query.Get("prescription_id")),
+	}
+	if filter.Status != "" && !durReviewStatuses[filter.Status] {
+		http.Error(w, "status must be one of pending, approved, rejected", http.StatusBadRequest)
+		return
+	}
+	if filter.Severity != "" && filter.Severity.Rank() == 0 {
+		http.Error(w, "severity must be one of contraindicated, major, moderate, minor", http.StatusBadRequest)
+		return
+	}
+	if limit, err := strconv.ParseInt(query.Get("limit"), 10, 64); err == nil {
+		filter.Limit = limit
+	}
+
+	reviews, err := h.dur.ListReviews(r.Context(), filter)
+	if err != nil {
+		log.Printf("[correlation_id=%s] Error listing DUR reviews: %v", middleware.GetCorrelationID(r), err)
+		http.Error(w, "Failed to list DUR reviews", http.StatusInternalServerError)
+		return
+	}
+	accessed := make([]audit.Entry, len(reviews))
+	for i, review := range reviews {
+		accessed[i] = audit.PHIAccess(audit.EntityDURReview, review.ID.Hex())
+	}
+	if !recordAudit(w, r, accessed...) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dur_reviews": reviews,
+	})
+}
+
+// GetReview handles GET /api/v1/dur-reviews/{id}
+func (h *DURHandler) GetReview(w http.ResponseWriter, r *http.Request) {
+	review, err := h.dur.GetReview(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, services.ErrDURReviewNotFound) {
+		http.Error(w, "DUR review not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[correlation_id=%s] Error loading DUR review: %v", middleware.GetCorrelationID(r), err)
+		http.Error(w, "Failed to load DUR review", http.StatusInternalServerError)
+		return
+	}
+	if !recordAudit(w, r, audit.PHIAccess(audit.EntityDURReview, review.ID.Hex())) {
+		return
+	}
+
+	writeDURReview(w, http.StatusOK, review)
+}
+
+// DecideReview handles POST /api/v1/dur-reviews/{id}/decision
+// An approval continues the prescription to enrollment; a rejection, which needs a note, sends it to an exception.
+// Posting the same decision again retries a follow-up that failed.
+func (h *DURHandler) DecideReview(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req DURDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Decision != models.DURReviewApproved && req.Decision != models.DURReviewRejected {
+		http.Error(w, "decision must be approved or rejected", http.StatusBadRequest)
+		return
+	}
+	if req.Decision == models.DURReviewRejected && strings.TrimSpace(req.Note) == "" {
+		http.Error(w, "note is required to reject", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	review, err := h.dur.Decide(ctx, id, services.DURDecision{Status: req.Decision, By: user.ID, Note: req.Note})
+	switch {
+	case errors.Is(err, services.ErrDURReviewNotFound):
+		http.Error(w, "DUR review not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrInvalidDURDecision):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, services.ErrDURReviewConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, audit.ErrUnavailable):
+		log.Printf("Error auditing DUR review %s: %v", id, err)
+		http.Error(w, "Audit log unavailable", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		log.Printf("Error deciding DUR review %s: %v", id, err)
+		http.Error(w, "Failed to decide DUR review", http.StatusInternalServerError)
+		return
+	}
+
+	correlationID := flowCorrelationID(r, review.CorrelationID)
+	moved, err := services.CompleteDURReview(ctx, h.deps.MongoClient, h.deps.KafkaProducer, correlationID, review)
+	if err != nil {
+		log.Printf("[correlation_id=%s] Error completing DUR review %s: %v", correlationID, id, err)
+		http.Error(w, "DUR review decided but follow-up failed", http.StatusInternalServerError)
+		return
+	}
+	if !moved {
+		log.Printf("⚠️  [correlation_id=%s] DUR review %s decided but prescription %s is no longer awaiting it", correlationID, id, review.PrescriptionID.Hex())
+	}
+
+	log.Printf("[correlation_id=%s] DUR review %s %s by %s", correlationID, id, review.Status, user.ID)
+	writeDURReview(w, http.StatusOK, review)
+}
+
+// GetAllergies handles GET /api/v1/patients/{id}/allergies
+func (h *DURHandler) GetAllergies(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	allergies, err := h.dur.Allergies(r.Context(), id)
+	if errors.Is(err, services.ErrPatientNotFound) {
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading allergies for patient %s: %v", id, err)
+		http.Error(w, "Failed to load allergies", http.StatusInternalServerError)
+		return
+	}
+	if !recordAudit(w, r, audit.PHIAccess(audit.EntityPatient, id)) {
+		return
+	}
+
+	writeAllergies(w, id, allergies)
+}
+
+// UpdateAllergies handles PUT /api/v1/patients/{id}/allergies
+// Replaces the patient's allergies; newly added ones are recorded as made by the user
+func (h *DURHandler) UpdateAllergies(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req AllergiesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := services.NormalizeAllergies(req.Allergies); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	allergies, err := h.dur.SetAllergies(r.Context(), id, req.Allergies, user.ID)
+	switch {
+	case errors.Is(err, services.ErrPatientNotFound):
+		http.Error(w, "Patient not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrInvalidAllergies):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, audit.ErrUnavailable):
+		log.Printf("Error auditing allergies for patient %s: %v", id, err)
+		http.Error(w, "Audit log unavailable", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		log.Printf("Error updating allergies for patient %s: %v", id, err)
+		http.Error(w, "Failed to update allergies", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Allergies for patient %s updated by %s (%d recorded)", id, user.ID, len(allergies))
+	writeAllergies(w, id, allergies)
+}
+
+// writeDURReview writes a DUR review as the JSON response
+func writeDURReview(w http.ResponseWriter, status int, review *models.DURReview) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(review)
+}
+
+// writeAllergies writes a patient's allergies as the JSON response
+func writeAllergies(w http.ResponseWriter, patientID string, allergies []models.Allergy) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AllergiesResponse{PatientID: patientID, Allergies: allergies})
+}
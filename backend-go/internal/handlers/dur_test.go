@@ -0,0 +1,109 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+)
+
+// TestDURHandler_DecisionValidation tests decision validation before any database access
+func TestDURHandler_DecisionValidation(t *testing.T) {
+	handler := NewDURHandler(&Dependencies{}, nil)
+	user := &middleware.AuthUser{ID: "pharm_1", Role: middleware.RolePharmacist}
+
+	tests := []struct {
+		name       string
+		body       string
+		user       *middleware.AuthUser
+		wantStatus int
+	}{
+		{"unauthenticated", `{"decision":"approved"}`, nil, http.StatusUnauthorized},
+		{"malformed body", `{`, user, http.StatusBadRequest},
+		{"missing decision", `{}`, user, http.StatusBadRequest},
+		{"pending decision", `{"decision":"pending"}`, user, http.StatusBadRequest},
+		{"rejection without note", `{"decision":"rejected","note":"  "}`, user, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/dur-reviews/65a000000000000000000001/decision", bytes.NewBufferString(tt.body))
+
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", "65a000000000000000000001")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx)
+		if tt.user != nil {
+			ctx = context.WithValue(ctx, middleware.UserKey{}, tt.user)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.DecideReview(rr, req.WithContext(ctx))
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+// TestDURHandler_ListValidation tests query parameter validation before any database access
+func TestDURHandler_ListValidation(t *testing.T) {
+	handler := NewDURHandler(&Dependencies{}, nil)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{"unknown status", "?status=open", http.StatusBadRequest},
+		{"unknown severity", "?status=pending&severity=severe", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/dur-reviews"+tt.query, nil)
+		rr := httptest.NewRecorder()
+		handler.ListReviews(rr, req)
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+// TestDURHandler_UpdateAllergiesValidation tests allergy validation before any database access
+func TestDURHandler_UpdateAllergiesValidation(t *testing.T) {
+	handler := NewDURHandler(&Dependencies{}, nil)
+	user := &middleware.AuthUser{ID: "ops_1", Role: middleware.RoleOpsAgent}
+
+	tests := []struct {
+		name       string
+		body       string
+		user       *middleware.AuthUser
+		wantStatus int
+	}{
+		{"unauthenticated", `{"allergies":[{"allergen":"penicillin"}]}`, nil, http.StatusUnauthorized},
+		{"malformed body", `{`, user, http.StatusBadRequest},
+		{"blank allergen", `{"allergies":[{"allergen":" ","reaction":"hives"}]}`, user, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/patients/65a000000000000000000001/allergies", bytes.NewBufferString(tt.body))
+
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", "65a000000000000000000001")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx)
+		if tt.user != nil {
+			ctx = context.WithValue(ctx, middleware.UserKey{}, tt.user)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.UpdateAllergies(rr, req.WithContext(ctx))
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/payments"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxWebhookBodyBytes bounds the webhook payloads we read before verifying the signature
+const maxWebhookBodyBytes = 1 << 20
+
+// PaymentWebhookHandler handles payment provider webhooks
+// Requests are authenticated by signature rather than by user or API key
+type PaymentWebhookHandler struct {
+	deps      *Dependencies
+	secret    string
+	tolerance time.Duration
+}
+
+// paymentWebhookEvent records a provider event that has been processed, keyed by event ID
+type paymentWebhookEvent struct {
+	ID         string    `bson:"_id"`
+	Type       string    `bson:"type"`
+	PaymentID  string    `bson:"payment_id,omitempty"`
+	Outcome    string    `bson:"outcome"`
+	ReceivedAt time.Time `bson:"received_at"`
+}
+
+// PaymentWebhookResponse acknowledges a webhook delivery
+type PaymentWebhookResponse struct {
+	Received  bool   `json:"received"`
+	EventID   string `json:"event_id"`
+	Outcome   string `json:"outcome"` // processed, ignored or duplicate
+	PaymentID string `json:"payment_id,omitempty"`
+	Status    string `json:"status,omitempty"`
+}
+
+// NewPaymentWebhookHandler creates a new payment webhook handler verifying signatures with secret
+func NewPaymentWebhookHandler(deps *Dependencies, secret string) *PaymentWebhookHandler {
+	return &PaymentWebhookHandler{
+		deps:      deps,
+		secret:    secret,
+		tolerance: payments.DefaultWebhookTolerance,
+	}
+}
+
+// Receive handles POST /api/v1/webhooks/payments
+// Deliveries are idempotent by event ID. Events are only recorded once applied, so a delivery that
+// fails part way is retried by the provider.
+func (h *PaymentWebhookHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// The signature covers the raw body, so verify before decoding anything
+	if err := payments.VerifyWebhookSignature(body, r.Header.Get(payments.SignatureHeader), h.secret, h.tolerance, time.Now()); err != nil {
+		log.Printf("Rejected payment webhook: %v", err)
+		http.Error(w, "Invalid signature", http.StatusBadRequest)
+		return
+	}
+
+	event, err := payments.ParseWebhookEvent(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	eventCollection := h.deps.MongoClient.GetCollection("payment_webhook_events")
+
+	var seen paymentWebhookEvent
+	err = eventCollection.FindOne(ctx, bson.M{"_id": event.ID}).Decode(&seen)
+	if err == nil {
+		writePaymentWebhookResponse(w, PaymentWebhookResponse{Received: true, EventID: event.ID, Outcome: "duplicate", PaymentID: seen.PaymentID})
+		return
+	}
+	if err != mongo.ErrNoDocuments {
+		log.Printf("Error looking up payment webhook event %s: %v", event.ID, err)
+		http.Error(w, "Failed to look up event", http.StatusInternalServerError)
+		return
+	}
+
+	record := paymentWebhookEvent{
+		ID:         event.ID,
+		Type:       event.Type,
+		PaymentID:  event.PaymentID,
+		Outcome:    "ignored",
+		ReceivedAt: time.Now(),
+	}
+	response := PaymentWebhookResponse{Received: true, EventID: event.ID, Outcome: "ignored"}
+
+	switch event.Status {
+	case payments.SessionPaid, payments.SessionFailed, payments.SessionExpired:
+		payment, err := services.SettlePayment(ctx, h.deps.MongoClient, h.deps.Redis, h.deps.KafkaProducer,
+			middleware.GetCorrelationID(r), event)
+		if errors.Is(err, services.ErrPaymentNotFound) {
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error applying payment webhook event %s (%s): %v", event.ID, event.Type, err)
+			http.Error(w, "Failed to apply payment event", http.StatusInternalServerError)
+			return
+		}
+
+		record.PaymentID = payment.ID.Hex()
+		record.Outcome = "processed"
+		response.Outcome = "processed"
+		response.PaymentID = record.PaymentID
+		response.Status = string(payment.Status)
+	}
+
+	if _, err := eventCollection.InsertOne(ctx, record); err != nil && !mongo.IsDuplicateKeyError(err) {
+		// Applying an event twice is safe, so a lost record only costs a repeat on redelivery
+		log.Printf("Error recording payment webhook event %s: %v", event.ID, err)
+	}
+
+	writePaymentWebhookResponse(w, response)
+}
+
+// writePaymentWebhookResponse writes a webhook acknowledgement as the JSON response
+func writePaymentWebhookResponse(w http.ResponseWriter, response PaymentWebhookResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
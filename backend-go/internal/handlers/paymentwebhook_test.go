@@ -0,0 +1,46 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/payments"
+)
+
+// TestPaymentWebhookHandler_RejectsUnverifiedRequests tests that unsigned or mis-signed deliveries are rejected before any lookups
+func TestPaymentWebhookHandler_RejectsUnverifiedRequests(t *testing.T) {
+	handler := NewPaymentWebhookHandler(&Dependencies{}, "whsec_test")
+	payload := `{"id":"evt_1","type":"checkout.session.completed","data":{"object":{"id":"cs_1","payment_status":"paid"}}}`
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+	}{
+		{"missing signature", payload, ""},
+		{"wrong secret", payload, payments.SignWebhookPayload([]byte(payload), "whsec_other", time.Now())},
+		{"stale timestamp", payload, payments.SignWebhookPayload([]byte(payload), "whsec_test", time.Now().Add(-time.Hour))},
+		{"body changed after signing", strings.Replace(payload, "cs_1", "cs_2", 1), payments.SignWebhookPayload([]byte(payload), "whsec_test", time.Now())},
+		{"signed but malformed", `{"type":"checkout.session.completed"}`, payments.SignWebhookPayload([]byte(`{"type":"checkout.session.completed"}`), "whsec_test", time.Now())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/payments", strings.NewReader(tt.body))
+			if tt.signature != "" {
+				req.Header.Set(payments.SignatureHeader, tt.signature)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.Receive(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+		})
+	}
+}
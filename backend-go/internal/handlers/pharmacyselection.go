@@ -0,0 +1,174 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PharmacySelectionHandler handles manual pharmacy selection by the ops team
+type PharmacySelectionHandler struct {
+	deps       *Dependencies
+	policies   *services.RoutingPolicyService
+	selections *services.PharmacySelectionService
+}
+
+// SelectPharmacyRequest represents the request body for manual pharmacy selection
+type SelectPharmacyRequest struct {
+	PharmacyID     string `json:"pharmacy_id"`
+	OverrideReason string `json:"override_reason,omitempty"`
+}
+
+// NewPharmacySelectionHandler creates a new pharmacy selection handler
+func NewPharmacySelectionHandler(deps *Dependencies, policies *services.RoutingPolicyService) *PharmacySelectionHandler {
+	return &PharmacySelectionHandler{
+		deps:       deps,
+		policies:   policies,
+		selections: services.NewPharmacySelectionService(deps.MongoClient, deps.KafkaProducer, services.NewPharmacyCapacityService(deps.Redis)),
+	}
+}
+
+// SelectPharmacy handles POST /api/v1/prescriptions/{id}/pharmacy
+// Ops chooses one of the stored recommendations; choosing outside the top N requires an override reason
+func (h *PharmacySelectionHandler) SelectPharmacy(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	prescriptionID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid prescription ID", http.StatusBadRequest)
+		return
+	}
+
+	var req SelectPharmacyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.OverrideReason = strings.TrimSpace(req.OverrideReason)
+	if req.PharmacyID == "" {
+		http.Error(w, "pharmacy_id is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Load the prescription and its stored recommendations
+	var prescription models.Prescription
+	err = h.deps.MongoClient.GetCollection("prescriptions").FindOne(ctx, bson.M{"_id": prescriptionID}).Decode(&prescription)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Prescription not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading prescription %s: %v", prescriptionID.Hex(), err)
+		http.Error(w, "Failed to load prescription", http.StatusInternalServerError)
+		return
+	}
+
+	// A retry of a selection whose event was not published picks up where it stopped
+	correlationID := flowCorrelationID(r, prescription.CorrelationID)
+	if services.SelectionResumable(&prescription, req.PharmacyID) {
+		h.completeSelection(w, r, correlationID, &prescription, user.ID, *prescription.PharmacySelection)
+		return
+	}
+	if prescription.Status != models.StatusAwaitingRouting {
+		http.Error(w, fmt.Sprintf("Prescription is %s, not awaiting pharmacy selection", prescription.Status), http.StatusConflict)
+		return
+	}
+
+	policy, err := h.policies.Resolve(ctx, prescription.Medication.NDC)
+	if err != nil {
+		log.Printf("Error resolving routing policy for prescription %s: %v", prescriptionID.Hex(), err)
+		http.Error(w, "Failed to resolve routing policy", http.StatusInternalServerError)
+		return
+	}
+
+	if services.RequiresOverride(prescription.PharmacyRecommendations, req.PharmacyID, policy.TopN) && req.OverrideReason == "" {
+		http.Error(w, fmt.Sprintf("override_reason is required when selecting a pharmacy outside the top %d recommendations", policy.TopN), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Load the pharmacy being selected
+	pharmacyOID, err := primitive.ObjectIDFromHex(req.PharmacyID)
+	if err != nil {
+		http.Error(w, "Invalid pharmacy_id", http.StatusBadRequest)
+		return
+	}
+
+	var pharmacy models.Pharmacy
+	err = h.deps.MongoClient.GetCollection("pharmacies").FindOne(ctx, bson.M{"_id": pharmacyOID}).Decode(&pharmacy)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Pharmacy not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading pharmacy %s: %v", req.PharmacyID, err)
+		http.Error(w, "Failed to load pharmacy", http.StatusInternalServerError)
+		return
+	}
+	if !pharmacy.Active {
+		http.Error(w, "Pharmacy is not active", http.StatusUnprocessableEntity)
+		return
+	}
+
+	selection := models.PharmacySelection{
+		PharmacyID:     req.PharmacyID,
+		NCPDPID:        pharmacy.NCPDPID,
+		Name:           pharmacy.Name,
+		Mode:           models.RoutingModeManual,
+		SelectedBy:     user.ID,
+		OverrideReason: req.OverrideReason,
+		SelectedAt:     time.Now(),
+	}
+	if rec := services.FindRecommendation(prescription.PharmacyRecommendations, req.PharmacyID); rec != nil {
+		selection.Rank = rec.Rank
+		selection.Score = rec.Score
+	}
+
+	h.completeSelection(w, r, correlationID, &prescription, user.ID, selection)
+}
+
+// completeSelection reserves capacity, records the selection and publishes pharmacy.selected
+func (h *PharmacySelectionHandler) completeSelection(
+	w http.ResponseWriter,
+	r *http.Request,
+	correlationID string,
+	prescription *models.Prescription,
+	userID string,
+	selection models.PharmacySelection,
+) {
+	prescriptionID := prescription.ID.Hex()
+	selected, err := h.selections.Complete(r.Context(), correlationID, prescription.ID, prescription.PatientID, models.StatusAwaitingRouting, selection)
+	if errors.Is(err, services.ErrSelectionConflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[correlation_id=%s] Error completing pharmacy selection for prescription %s: %v", correlationID, prescriptionID, err)
+		http.Error(w, "Failed to select pharmacy", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[correlation_id=%s] Pharmacy %s selected for prescription %s by %s", correlationID, selected.PharmacyID, prescriptionID, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(selected)
+}
@@ -0,0 +1,50 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+)
+
+// TestPharmacySelectionHandler_RequestValidation tests request validation before any database access
+func TestPharmacySelectionHandler_RequestValidation(t *testing.T) {
+	handler := NewPharmacySelectionHandler(&Dependencies{}, nil)
+	user := &middleware.AuthUser{ID: "ops_user_1", Role: middleware.RoleOpsAgent}
+
+	tests := []struct {
+		name       string
+		id         string
+		body       string
+		user       *middleware.AuthUser
+		wantStatus int
+	}{
+		{"unauthenticated", "65a000000000000000000001", `{"pharmacy_id":"x"}`, nil, http.StatusUnauthorized},
+		{"invalid prescription id", "not-an-id", `{"pharmacy_id":"x"}`, user, http.StatusBadRequest},
+		{"malformed body", "65a000000000000000000001", `{`, user, http.StatusBadRequest},
+		{"missing pharmacy id", "65a000000000000000000001", `{}`, user, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/prescriptions/"+tt.id+"/pharmacy", bytes.NewBufferString(tt.body))
+
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", tt.id)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx)
+		if tt.user != nil {
+			ctx = context.WithValue(ctx, middleware.UserKey{}, tt.user)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.SelectPharmacy(rr, req.WithContext(ctx))
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
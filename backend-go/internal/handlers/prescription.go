@@ -10,10 +10,11 @@ import (
 	"strings"
 	"time"
 
-	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
 	"github.com/phil-my-meds/backend-gogit/internal/middleware"
 	"github.com/phil-my-meds/backend-gogit/internal/models"
-	"github.com/phil-my-meds/backend-gogit/internal/workers"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
 	"github.com/phil-my-meds/backend-gogit/pkg/ncpdp"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -149,7 +150,14 @@ func (h *PrescriptionHandler) Intake(w http.ResponseWriter, r *http.Request) {
 	// Subtask 1.1.9: Insert prescription into MongoDB
 	// Subtask 1.1.10: Set status: "received"
 	now := time.Now()
+	correlationID := middleware.GetCorrelationID(r)
+	if correlationID == "" {
+		// Fallback: generate correlation ID if not found in context
+		correlationID = fmt.Sprintf("intake_%d", now.UnixNano())
+	}
 	prescription.Status = models.StatusReceived
+	prescription.CorrelationID = correlationID
+	prescription.StatusHistory = []models.StatusChange{services.NewStatusChange(ctx, correlationID, models.StatusReceived, now)}
 	prescription.CreatedAt = now
 	prescription.UpdatedAt = now
 	prescription.OriginalPayload = req.Payload
@@ -160,76 +168,80 @@ func (h *PrescriptionHandler) Intake(w http.ResponseWriter, r *http.Request) {
 		prescription.PrescriptionID = fmt.Sprintf("rx_%d_%s_%s", now.Unix(), prescription.Patient.ID, prescription.Medication.NDC)
 	}
 
+	prescription.ID = primitive.NewObjectID()
+	prescriptionID := prescription.ID.Hex()
+
+	// A prescription that cannot be audited is not accepted, so the entry is written before the insert
+	created := audit.Entry{
+		EventType:  audit.EventPrescriptionCreated,
+		EntityType: audit.EntityPrescription,
+		EntityID:   prescriptionID,
+		Action:     audit.ActionCreate,
+		Details: map[string]interface{}{
+			"correlation_id":  correlationID,
+			"prescription_id": prescription.PrescriptionID,
+			"patient_id":      prescription.Patient.ID,
+			"format":          format,
+		},
+	}
+	if !recordAudit(w, r, created) {
+		return
+	}
+
 	// Insert into MongoDB
 	collection := h.deps.MongoClient.GetCollection("prescriptions")
-	result, err := collection.InsertOne(ctx, prescription)
-	if err != nil {
-		log.Printf("Error inserting prescription into MongoDB: %v", err)
+	if _, err := collection.InsertOne(ctx, prescription); err != nil {
+		log.Printf("[correlation_id=%s] Error inserting prescription into MongoDB: %v", correlationID, err)
 		http.Error(w, "Failed to save prescription", http.StatusInternalServerError)
 		return
 	}
 
-	// Get the inserted ID
-	insertedID := result.InsertedID
-	var prescriptionID string
-	if oid, ok := insertedID.(primitive.ObjectID); ok {
-		prescriptionID = oid.Hex()
-	} else {
-		prescriptionID = prescription.PrescriptionID
-	}
-
-	log.Printf("Prescription inserted successfully with ID: %s", prescriptionID)
+	log.Printf("[correlation_id=%s] Prescription inserted successfully with ID: %s", correlationID, prescriptionID)
 
 	// Subtask 1.1.11: Publish Kafka event: prescription.intake.received
 	// Subtask 1.1.12: Structure event payload with prescription metadata
-	correlationID := middleware.GetCorrelationID(r)
-	if correlationID == "" {
-		// Fallback: generate correlation ID if not found in context
-		correlationID = fmt.Sprintf("intake_%d", time.Now().UnixNano())
-	}
-
 	// Build event payload with prescription metadata
-	eventData := map[string]interface{}{
-		"status": prescription.Status,
-		"patient": map[string]interface{}{
-			"id":            prescription.Patient.ID,
-			"first_name":    prescription.Patient.FirstName,
-			"last_name":     prescription.Patient.LastName,
-			"date_of_birth": prescription.Patient.DateOfBirth,
+	event := &events.IntakeReceived{
+		Envelope: events.NewEnvelope(ctx, correlationID, prescriptionID),
+		Status:   string(prescription.Status),
+		Patient: events.IntakePatient{
+			ID:          prescription.Patient.ID,
+			FirstName:   prescription.Patient.FirstName,
+			LastName:    prescription.Patient.LastName,
+			DateOfBirth: prescription.Patient.DateOfBirth,
 		},
-		"prescriber": map[string]interface{}{
-			"id":         prescription.Prescriber.ID,
-			"npi":        prescription.Prescriber.NPI,
-			"dea":        prescription.Prescriber.DEA,
-			"first_name": prescription.Prescriber.FirstName,
-			"last_name":  prescription.Prescriber.LastName,
+		Prescriber: events.IntakePrescriber{
+			ID:        prescription.Prescriber.ID,
+			NPI:       prescription.Prescriber.NPI,
+			DEA:       prescription.Prescriber.DEA,
+			FirstName: prescription.Prescriber.FirstName,
+			LastName:  prescription.Prescriber.LastName,
 		},
-		"medication": map[string]interface{}{
-			"ndc":      prescription.Medication.NDC,
-			"name":     prescription.Medication.Name,
-			"quantity": prescription.Medication.Quantity,
-			"refills":  prescription.Medication.Refills,
+		Medication: events.IntakeMedication{
+			NDC:      prescription.Medication.NDC,
+			Name:     prescription.Medication.Name,
+			Quantity: prescription.Medication.Quantity,
+			Refills:  prescription.Medication.Refills,
 		},
-		"date_written": prescription.DateWritten,
-		"created_at":   prescription.CreatedAt.Format(time.RFC3339),
+		DateWritten: prescription.DateWritten,
+		CreatedAt:   prescription.CreatedAt,
 	}
 
 	// Add insurance information if available
 	if prescription.Insurance.BIN != "" || prescription.Insurance.MemberID != "" {
-		eventData["insurance"] = map[string]interface{}{
-			"bin":       prescription.Insurance.BIN,
-			"pcn":       prescription.Insurance.PCN,
-			"group_id":  prescription.Insurance.GroupID,
-			"member_id": prescription.Insurance.MemberID,
-			"plan_name": prescription.Insurance.PlanName,
+		event.Insurance = &events.IntakeInsurance{
+			BIN:      prescription.Insurance.BIN,
+			PCN:      prescription.Insurance.PCN,
+			GroupID:  prescription.Insurance.GroupID,
+			MemberID: prescription.Insurance.MemberID,
+			PlanName: prescription.Insurance.PlanName,
 		}
 	}
 
-	// Create and publish event
-	event := workers.CreateEvent(correlationID, prescriptionID, eventData)
-	if err := workers.PublishEvent(ctx, h.deps.KafkaProducer, kafka.TopicIntakeReceived, prescriptionID, event); err != nil {
+	// Publish event
+	if err := events.Publish(ctx, h.deps.KafkaProducer, event); err != nil {
 		// Log error but don't fail the request - event publishing is best-effort
-		log.Printf("⚠️  Failed to publish intake event for prescription %s: %v", prescriptionID, err)
+		log.Printf("⚠️  [correlation_id=%s] Failed to publish intake event for prescription %s: %v", correlationID, prescriptionID, err)
 		// Continue processing - the prescription was successfully saved
 	}
 
@@ -0,0 +1,236 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PriorAuthHandler handles prior authorization tracking by the ops team
+type PriorAuthHandler struct {
+	deps       *Dependencies
+	priorAuths *services.PriorAuthService
+}
+
+// CreatePriorAuthRequest represents the request body for opening a prior authorization
+type CreatePriorAuthRequest struct {
+	PrescriptionID string `json:"prescription_id"`
+	Note           string `json:"note,omitempty"`
+}
+
+// UpdatePriorAuthStatusRequest represents the request body for a prior authorization status change
+type UpdatePriorAuthStatusRequest struct {
+	Status         models.PriorAuthStatus `json:"status"`
+	Note           string                 `json:"note,omitempty"`
+	PayerReference string                 `json:"payer_reference,omitempty"`
+	ExpiresAt      *time.Time             `json:"expires_at,omitempty"`
+	DenialReason   string                 `json:"denial_reason,omitempty"`
+	AppealDeadline *time.Time             `json:"appeal_deadline,omitempty"`
+}
+
+// NewPriorAuthHandler creates a new prior authorization handler
+func NewPriorAuthHandler(deps *Dependencies, priorAuths *services.PriorAuthService) *PriorAuthHandler {
+	return &PriorAuthHandler{
+		deps:       deps,
+		priorAuths: priorAuths,
+	}
+}
+
+// Create handles POST /api/v1/prior-authorizations
+// Returns the prescription's open prior authorization if there already is one
+func (h *PriorAuthHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreatePriorAuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	prescriptionID, err := primitive.ObjectIDFromHex(strings.TrimSpace(req.PrescriptionID))
+	if err != nil {
+		http.Error(w, "Invalid prescription_id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	var prescription models.Prescription
+	err = h.deps.MongoClient.GetCollection("prescriptions").FindOne(ctx, bson.M{"_id": prescriptionID}).Decode(&prescription)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Prescription not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading prescription %s: %v", prescriptionID.Hex(), err)
+		http.Error(w, "Failed to load prescription", http.StatusInternalServerError)
+		return
+	}
+
+	pa, created, err := h.priorAuths.Open(ctx, &prescription, models.PriorAuthSourceOps, user.ID, strings.TrimSpace(req.Note))
+	if errors.Is(err, audit.ErrUnavailable) {
+		log.Printf("Error auditing prior authorization for prescription %s: %v", prescriptionID.Hex(), err)
+		http.Error(w, "Audit log unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Printf("Error opening prior authorization for prescription %s: %v", prescriptionID.Hex(), err)
+		http.Error(w, "Failed to open prior authorization", http.StatusInternalServerError)
+		return
+	}
+	if !created {
+		if !recordAudit(w, r, audit.PHIAccess(audit.EntityPriorAuthorization, pa.ID.Hex())) {
+			return
+		}
+		writePriorAuth(w, http.StatusOK, pa)
+		return
+	}
+
+	correlationID := flowCorrelationID(r, pa.CorrelationID)
+	if err := events.PublishPriorAuthUpdated(ctx, h.deps.KafkaProducer, correlationID, pa, ""); err != nil {
+		log.Printf("[correlation_id=%s] Error publishing prior authorization event for %s: %v", correlationID, pa.ID.Hex(), err)
+		http.Error(w, "Failed to publish prior authorization event", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[correlation_id=%s] Prior authorization %s opened for prescription %s by %s", correlationID, pa.ID.Hex(), prescriptionID.Hex(), user.ID)
+	writePriorAuth(w, http.StatusCreated, pa)
+}
+
+// List handles GET /api/v1/prior-authorizations?status={status}&prescription_id={id}&limit={n}
+func (h *PriorAuthHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := services.PriorAuthFilter{
+		Status:         models.PriorAuthStatus(strings.TrimSpace(query.Get("status"))),
+		PrescriptionID: strings.TrimSpace(query.Get("prescription_id")),
+	}
+	if limit, err := strconv.ParseInt(query.Get("limit"), 10, 64); err == nil {
+		filter.Limit = limit
+	}
+
+	pas, err := h.priorAuths.List(r.Context(), filter)
+	if err != nil {
+		log.Printf("Error listing prior authorizations: %v", err)
+		http.Error(w, "Failed to list prior authorizations", http.StatusInternalServerError)
+		return
+	}
+	accessed := make([]audit.Entry, len(pas))
+	for i, pa := range pas {
+		accessed[i] = audit.PHIAccess(audit.EntityPriorAuthorization, pa.ID.Hex())
+	}
+	if !recordAudit(w, r, accessed...) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"prior_authorizations": pas,
+	})
+}
+
+// Get handles GET /api/v1/prior-authorizations/{id}
+func (h *PriorAuthHandler) Get(w http.ResponseWriter, r *http.Request) {
+	pa, err := h.priorAuths.Get(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, services.ErrPriorAuthNotFound) {
+		http.Error(w, "Prior authorization not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading prior authorization: %v", err)
+		http.Error(w, "Failed to load prior authorization", http.StatusInternalServerError)
+		return
+	}
+	if !recordAudit(w, r, audit.PHIAccess(audit.EntityPriorAuthorization, pa.ID.Hex())) {
+		return
+	}
+
+	writePriorAuth(w, http.StatusOK, pa)
+}
+
+// UpdateStatus handles POST /api/v1/prior-authorizations/{id}/status
+// Each transition publishes prior_authorization.updated; an approval re-triggers adjudication
+func (h *PriorAuthHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req UpdatePriorAuthStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Status == "" {
+		http.Error(w, "status is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	pa, from, err := h.priorAuths.Transition(ctx, id, services.PriorAuthTransitionRequest{
+		Status:         req.Status,
+		By:             user.ID,
+		Note:           req.Note,
+		PayerReference: strings.TrimSpace(req.PayerReference),
+		ExpiresAt:      req.ExpiresAt,
+		DenialReason:   req.DenialReason,
+		AppealDeadline: req.AppealDeadline,
+	})
+	switch {
+	case errors.Is(err, services.ErrPriorAuthNotFound):
+		http.Error(w, "Prior authorization not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrInvalidPriorAuthTransition):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, services.ErrPriorAuthConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, audit.ErrUnavailable):
+		log.Printf("Error auditing prior authorization %s: %v", id, err)
+		http.Error(w, "Audit log unavailable", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		log.Printf("Error updating prior authorization %s: %v", id, err)
+		http.Error(w, "Failed to update prior authorization", http.StatusInternalServerError)
+		return
+	}
+
+	correlationID := flowCorrelationID(r, pa.CorrelationID)
+	if err := services.CompletePriorAuthTransition(ctx, h.deps.MongoClient, h.deps.KafkaProducer, correlationID, pa, from); err != nil {
+		log.Printf("[correlation_id=%s] Error completing prior authorization transition for %s: %v", correlationID, id, err)
+		http.Error(w, "Prior authorization updated but follow-up failed", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[correlation_id=%s] Prior authorization %s moved from %s to %s by %s", correlationID, id, from, pa.Status, user.ID)
+	writePriorAuth(w, http.StatusOK, pa)
+}
+
+// writePriorAuth writes a prior authorization as the JSON response
+func writePriorAuth(w http.ResponseWriter, status int, pa *models.PriorAuthorization) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(pa)
+}
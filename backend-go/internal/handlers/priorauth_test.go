@@ -0,0 +1,51 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+)
+
+// TestPriorAuthHandler_RequestValidation tests request validation before any database access
+func TestPriorAuthHandler_RequestValidation(t *testing.T) {
+	handler := NewPriorAuthHandler(&Dependencies{}, nil)
+	user := &middleware.AuthUser{ID: "ops_user_1", Role: middleware.RoleOpsAgent}
+
+	tests := []struct {
+		name       string
+		handle     http.HandlerFunc
+		body       string
+		user       *middleware.AuthUser
+		wantStatus int
+	}{
+		{"create unauthenticated", handler.Create, `{"prescription_id":"65a000000000000000000001"}`, nil, http.StatusUnauthorized},
+		{"create invalid prescription id", handler.Create, `{"prescription_id":"nope"}`, user, http.StatusBadRequest},
+		{"update unauthenticated", handler.UpdateStatus, `{"status":"approved"}`, nil, http.StatusUnauthorized},
+		{"update malformed body", handler.UpdateStatus, `{`, user, http.StatusBadRequest},
+		{"update missing status", handler.UpdateStatus, `{}`, user, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/prior-authorizations", bytes.NewBufferString(tt.body))
+
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", "65a000000000000000000002")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx)
+		if tt.user != nil {
+			ctx = context.WithValue(ctx, middleware.UserKey{}, tt.user)
+		}
+
+		rr := httptest.NewRecorder()
+		tt.handle(rr, req.WithContext(ctx))
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
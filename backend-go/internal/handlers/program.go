@@ -0,0 +1,70 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+)
+
+// ProgramHandler handles manufacturer copay program lookups
+type ProgramHandler struct {
+	deps     *Dependencies
+	programs *services.ManufacturerProgramService
+}
+
+// ProgramLookupResponse is returned to pharmacies so they can bill the secondary claim
+type ProgramLookupResponse struct {
+	ProgramID   string                    `json:"program_id"`
+	ProgramName string                    `json:"program_name"`
+	Credentials models.ProgramCredentials `json:"credentials"`
+	Eligibility models.ProgramEligibility `json:"eligibility"`
+}
+
+// NewProgramHandler creates a new program handler
+func NewProgramHandler(deps *Dependencies, programs *services.ManufacturerProgramService) *ProgramHandler {
+	return &ProgramHandler{
+		deps:     deps,
+		programs: programs,
+	}
+}
+
+// Lookup handles GET /api/v1/programs/lookup?ndc={ndc}&insurance_type={type}
+// Returns the manufacturer program credentials for a drug; insurance_type filters out commercial-only programs
+func (h *ProgramHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	ndc := strings.TrimSpace(r.URL.Query().Get("ndc"))
+	if ndc == "" {
+		http.Error(w, "ndc is required", http.StatusBadRequest)
+		return
+	}
+	insuranceType := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("insurance_type")))
+
+	program, err := h.programs.LookupByNDC(r.Context(), ndc)
+	if err != nil {
+		log.Printf("Error looking up manufacturer program for NDC %s: %v", ndc, err)
+		http.Error(w, "Failed to look up manufacturer program", http.StatusInternalServerError)
+		return
+	}
+	if program == nil {
+		http.Error(w, "No manufacturer program found for NDC", http.StatusNotFound)
+		return
+	}
+
+	if insuranceType != "" && program.Eligibility.CommercialOnly && insuranceType != models.InsuranceTypeCommercial {
+		http.Error(w, "Manufacturer program requires commercial insurance", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ProgramLookupResponse{
+		ProgramID:   program.ProgramID,
+		ProgramName: program.ProgramName,
+		Credentials: program.Credentials,
+		Eligibility: program.Eligibility,
+	})
+}
@@ -0,0 +1,21 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestProgramHandler_Lookup_MissingNDC tests that the ndc query parameter is required
+func TestProgramHandler_Lookup_MissingNDC(t *testing.T) {
+	handler := NewProgramHandler(&Dependencies{}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/programs/lookup?insurance_type=commercial", nil)
+	rr := httptest.NewRecorder()
+	handler.Lookup(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
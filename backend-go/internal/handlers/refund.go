@@ -0,0 +1,294 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+)
+
+// RefundHandler handles refunds of captured copays by the ops team
+type RefundHandler struct {
+	deps    *Dependencies
+	refunds *services.RefundService
+}
+
+// CreateRefundRequest represents the request body for requesting a refund
+type CreateRefundRequest struct {
+	Amount float64             `json:"amount,omitempty"` // omitted or 0 refunds everything still refundable
+	Reason models.RefundReason `json:"reason"`
+	Note   string              `json:"note,omitempty"`
+}
+
+// RefundDecisionRequest represents the request body for approving or rejecting a refund
+type RefundDecisionRequest struct {
+	Note string `json:"note,omitempty"`
+}
+
+// NewRefundHandler creates a new refund handler
+func NewRefundHandler(deps *Dependencies, refunds *services.RefundService) *RefundHandler {
+	return &RefundHandler{
+		deps:    deps,
+		refunds: refunds,
+	}
+}
+
+// Create handles POST /api/v1/payments/{id}/refunds
+// The refund waits for approval by a second ops manager before money moves
+func (h *RefundHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateRefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	paymentID := chi.URLParam(r, "id")
+	refund, err := h.refunds.Request(r.Context(), services.RefundRequest{
+		PaymentID: paymentID,
+		Amount:    req.Amount,
+		Reason:    models.RefundReason(strings.TrimSpace(string(req.Reason))),
+		Note:      req.Note,
+		By:        user.ID,
+	})
+	switch {
+	case errors.Is(err, services.ErrRefundPaymentNotFound):
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrInvalidRefund):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		log.Printf("Error requesting refund for payment %s: %v", paymentID, err)
+		http.Error(w, "Failed to request refund", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Refund %s of $%.2f requested for payment %s by %s (%s)", refund.ID.Hex(), refund.Amount, paymentID, user.ID, refund.Reason)
+	writeRefund(w, http.StatusCreated, refund)
+}
+
+// ListForPayment handles GET /api/v1/payments/{id}/refunds
+// Returns the payment's ledger with the amount still refundable and its refunds
+func (h *RefundHandler) ListForPayment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	paymentID := chi.URLParam(r, "id")
+
+	payment, refundable, err := h.refunds.Refundable(ctx, paymentID)
+	if errors.Is(err, services.ErrRefundPaymentNotFound) {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading payment %s: %v", paymentID, err)
+		http.Error(w, "Failed to load payment", http.StatusInternalServerError)
+		return
+	}
+
+	refunds, err := h.refunds.List(ctx, services.RefundFilter{PaymentID: paymentID, Limit: 200})
+	if err != nil {
+		log.Printf("Error listing refunds for payment %s: %v", paymentID, err)
+		http.Error(w, "Failed to list refunds", http.StatusInternalServerError)
+		return
+	}
+	if !recordAudit(w, r, append(refundAccess(refunds), audit.PHIAccess(audit.EntityPayment, payment.ID.Hex()))...) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"payment_id":      payment.ID.Hex(),
+		"prescription_id": payment.PrescriptionID,
+		"status":          payment.Status,
+		"amount":          payment.Amount,
+		"amount_refunded": payment.AmountRefunded,
+		"refundable":      refundable,
+		"refunds":         refunds,
+	})
+}
+
+// List handles GET /api/v1/refunds?status={status}&payment_id={id}&prescription_id={id}&limit={n}
+func (h *RefundHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := services.RefundFilter{
+		Status:         models.RefundStatus(strings.TrimSpace(query.Get("status"))),
+		PaymentID:      strings.TrimSpace(query.Get("payment_id")),
+		PrescriptionID: strings.TrimSpace(query.Get("prescription_id")),
+	}
+	if limit, err := strconv.ParseInt(query.Get("limit"), 10, 64); err == nil {
+		filter.Limit = limit
+	}
+
+	refunds, err := h.refunds.List(r.Context(), filter)
+	if err != nil {
+		log.Printf("Error listing refunds: %v", err)
+		http.Error(w, "Failed to list refunds", http.StatusInternalServerError)
+		return
+	}
+	if !recordAudit(w, r, refundAccess(refunds)...) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"refunds": refunds,
+	})
+}
+
+// Get handles GET /api/v1/refunds/{id}
+func (h *RefundHandler) Get(w http.ResponseWriter, r *http.Request) {
+	refund, err := h.refunds.Get(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, services.ErrRefundNotFound) {
+		http.Error(w, "Refund not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading refund: %v", err)
+		http.Error(w, "Failed to load refund", http.StatusInternalServerError)
+		return
+	}
+	if !recordAudit(w, r, audit.PHIAccess(audit.EntityRefund, refund.ID.Hex())) {
+		return
+	}
+
+	writeRefund(w, http.StatusOK, refund)
+}
+
+// Approve handles POST /api/v1/refunds/{id}/approve
+// Issues the refund with the payment provider and publishes payment.refunded
+func (h *RefundHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req RefundDecisionRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	refund, payment, err := h.refunds.Approve(ctx, id, user.ID, req.Note)
+	switch {
+	case errors.Is(err, services.ErrRefundNotFound), errors.Is(err, services.ErrRefundPaymentNotFound):
+		http.Error(w, "Refund not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrRefundSelfApproval):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, services.ErrInvalidRefund):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, services.ErrRefundConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, services.ErrRefundProviderFailed):
+		log.Printf("Refund %s failed at the payment provider: %v", id, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	case err != nil:
+		log.Printf("Error approving refund %s: %v", id, err)
+		http.Error(w, "Failed to approve refund", http.StatusInternalServerError)
+		return
+	}
+
+	correlationID := flowCorrelationID(r, refund.CorrelationID)
+	if err := events.PublishPaymentRefunded(ctx, h.deps.KafkaProducer, correlationID, refund, payment); err != nil {
+		log.Printf("[correlation_id=%s] Error publishing payment refunded event for refund %s: %v", correlationID, id, err)
+		http.Error(w, "Refund issued but event publish failed", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[correlation_id=%s] Refund %s of $%.2f approved by %s and issued (%s)", correlationID, id, refund.Amount, user.ID, refund.ProviderRefundID)
+	writeRefund(w, http.StatusOK, refund)
+}
+
+// Reject handles POST /api/v1/refunds/{id}/reject
+func (h *RefundHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req RefundDecisionRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Note) == "" {
+		http.Error(w, "note is required", http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	refund, err := h.refunds.Reject(r.Context(), id, user.ID, req.Note)
+	switch {
+	case errors.Is(err, services.ErrRefundNotFound):
+		http.Error(w, "Refund not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrInvalidRefund):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, services.ErrRefundConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error rejecting refund %s: %v", id, err)
+		http.Error(w, "Failed to reject refund", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Refund %s rejected by %s", id, user.ID)
+	writeRefund(w, http.StatusOK, refund)
+}
+
+// decodeOptionalBody decodes a JSON body into v, treating an empty body as no fields set
+func decodeOptionalBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// refundAccess returns the audit entries for reading refunds
+func refundAccess(refunds []models.Refund) []audit.Entry {
+	entries := make([]audit.Entry, len(refunds))
+	for i, refund := range refunds {
+		entries[i] = audit.PHIAccess(audit.EntityRefund, refund.ID.Hex())
+	}
+	return entries
+}
+
+// writeRefund writes a refund as the JSON response
+func writeRefund(w http.ResponseWriter, status int, refund *models.Refund) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(refund)
+}
@@ -0,0 +1,53 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+)
+
+// TestRefundHandler_RequestValidation tests request validation before any database access
+func TestRefundHandler_RequestValidation(t *testing.T) {
+	handler := NewRefundHandler(&Dependencies{}, nil)
+	user := &middleware.AuthUser{ID: "ops_manager_1", Role: middleware.RoleOpsManager}
+
+	tests := []struct {
+		name       string
+		handle     http.HandlerFunc
+		body       string
+		user       *middleware.AuthUser
+		wantStatus int
+	}{
+		{"create unauthenticated", handler.Create, `{"reason":"prescription_cancelled"}`, nil, http.StatusUnauthorized},
+		{"create malformed body", handler.Create, `{`, user, http.StatusBadRequest},
+		{"create missing reason", handler.Create, `{"amount":5}`, user, http.StatusBadRequest},
+		{"approve unauthenticated", handler.Approve, ``, nil, http.StatusUnauthorized},
+		{"approve malformed body", handler.Approve, `{`, user, http.StatusBadRequest},
+		{"reject unauthenticated", handler.Reject, `{"note":"no"}`, nil, http.StatusUnauthorized},
+		{"reject without note", handler.Reject, ``, user, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/refunds", bytes.NewBufferString(tt.body))
+
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", "65a000000000000000000003")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx)
+		if tt.user != nil {
+			ctx = context.WithValue(ctx, middleware.UserKey{}, tt.user)
+		}
+
+		rr := httptest.NewRecorder()
+		tt.handle(rr, req.WithContext(ctx))
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+)
+
+// sagaStatuses are the statuses sagas may be listed by
+var sagaStatuses = map[models.SagaStatus]bool{
+	models.SagaRunning:            true,
+	models.SagaCompleted:          true,
+	models.SagaCompensating:       true,
+	models.SagaCompensated:        true,
+	models.SagaCompensationFailed: true,
+}
+
+// sagaSteps are the steps sagas may be listed by
+var sagaSteps = map[models.SagaStep]bool{
+	models.SagaStepValidation:   true,
+	models.SagaStepDURReview:    true,
+	models.SagaStepEnrollment:   true,
+	models.SagaStepRouting:      true,
+	models.SagaStepAdjudication: true,
+	models.SagaStepPayment:      true,
+	models.SagaStepVerification: true,
+	models.SagaStepShipping:     true,
+	models.SagaStepDelivery:     true,
+}
+
+// SagaHandler lets the ops team inspect prescriptions' fulfillment sagas
+type SagaHandler struct {
+	deps  *Dependencies
+	sagas *services.SagaService
+}
+
+// NewSagaHandler creates a new saga handler
+func NewSagaHandler(deps *Dependencies, sagas *services.SagaService) *SagaHandler {
+	return &SagaHandler{
+		deps:  deps,
+		sagas: sagas,
+	}
+}
+
+// Get handles GET /api/v1/prescriptions/{id}/saga
+// It returns the prescription's steps with their deadlines, and for a failed saga its compensations
+func (h *SagaHandler) Get(w http.ResponseWriter, r *http.Request) {
+	prescriptionID := strings.TrimSpace(chi.URLParam(r, "id"))
+	if prescriptionID == "" {
+		http.Error(w, "Prescription ID is required", http.StatusBadRequest)
+		return
+	}
+
+	saga, err := h.sagas.Get(r.Context(), prescriptionID)
+	if errors.Is(err, services.ErrSagaNotFound) {
+		http.Error(w, "Saga not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[correlation_id=%s] Error loading saga of prescription %s: %v", middleware.GetCorrelationID(r), prescriptionID, err)
+		http.Error(w, "Failed to load saga", http.StatusInternalServerError)
+		return
+	}
+	if !recordAudit(w, r, audit.PHIAccess(audit.EntityPrescription, prescriptionID)) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(saga)
+}
+
+// List handles GET /api/v1/sagas?status={status}&step={step}&limit={n}
+// Listing compensation_failed sagas finds the prescriptions whose steps ops must undo by hand
+func (h *SagaHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := services.SagaFilter{
+		Status: models.SagaStatus(strings.TrimSpace(query.Get("status"))),
+		Step:   models.SagaStep(strings.TrimSpace(query.Get("step"))),
+	}
+	if filter.Status != "" && !sagaStatuses[filter.Status] {
+		http.Error(w, "status must be one of running, completed, compensating, compensated, compensation_failed", http.StatusBadRequest)
+		return
+	}
+	if filter.Step != "" && !sagaSteps[filter.Step] {
+		http.Error(w, "step must be one of validation, dur_review, enrollment, routing, adjudication, payment, verification, shipping, delivery", http.StatusBadRequest)
+		return
+	}
+	if limit, err := strconv.ParseInt(query.Get("limit"), 10, 64); err == nil {
+		filter.Limit = limit
+	}
+
+	sagas, err := h.sagas.List(r.Context(), filter)
+	if err != nil {
+		log.Printf("[correlation_id=%s] Error listing sagas: %v", middleware.GetCorrelationID(r), err)
+		http.Error(w, "Failed to list sagas", http.StatusInternalServerError)
+		return
+	}
+	accessed := make([]audit.Entry, len(sagas))
+	for i, saga := range sagas {
+		accessed[i] = audit.PHIAccess(audit.EntityPrescription, saga.PrescriptionID)
+	}
+	if !recordAudit(w, r, accessed...) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sagas": sagas,
+	})
+}
@@ -0,0 +1,45 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestSagaHandler_RequestValidation tests query parameter validation before any database access
+func TestSagaHandler_RequestValidation(t *testing.T) {
+	handler := NewSagaHandler(&Dependencies{}, nil)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{"unknown status", "?status=failed", http.StatusBadRequest},
+		{"unknown step", "?status=running&step=dispensing", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/sagas"+tt.query, nil)
+		rr := httptest.NewRecorder()
+		handler.List(rr, req)
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+// TestSagaHandler_GetWithoutID tests that a saga is only looked up for a prescription ID
+func TestSagaHandler_GetWithoutID(t *testing.T) {
+	handler := NewSagaHandler(&Dependencies{}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/prescriptions//saga", nil)
+	rr := httptest.NewRecorder()
+	handler.Get(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
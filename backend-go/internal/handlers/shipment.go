@@ -0,0 +1,199 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+)
+
+// ShipmentHandler handles shipment lookups, shipping documents and cold chain excursion reports from the ops team
+type ShipmentHandler struct {
+	deps      *Dependencies
+	shipments *services.ShipmentService
+	documents *services.ShipmentDocumentService
+}
+
+// TemperatureExcursionRequest represents the request body for reporting a temperature excursion
+type TemperatureExcursionRequest struct {
+	Source          models.ExcursionSource `json:"source"`
+	TemperatureC    *float64               `json:"temperature_c,omitempty"`
+	DurationMinutes int                    `json:"duration_minutes,omitempty"`
+	ObservedAt      string                 `json:"observed_at,omitempty"` // RFC 3339; defaults to now
+	Note            string                 `json:"note,omitempty"`
+}
+
+// TemperatureExcursionResponse represents the response after an excursion is recorded
+type TemperatureExcursionResponse struct {
+	Shipment             *models.Shipment `json:"shipment"`
+	ReplacementRequested bool             `json:"replacement_requested"`
+}
+
+// ShipmentDocumentsResponse represents the signed links to a shipment's documents
+type ShipmentDocumentsResponse struct {
+	ShipmentID string                  `json:"shipment_id"`
+	ExpiresAt  time.Time               `json:"expires_at"`
+	Documents  []services.DocumentLink `json:"documents"`
+}
+
+// NewShipmentHandler creates a new shipment handler
+func NewShipmentHandler(deps *Dependencies, shipments *services.ShipmentService, documents *services.ShipmentDocumentService) *ShipmentHandler {
+	return &ShipmentHandler{
+		deps:      deps,
+		shipments: shipments,
+		documents: documents,
+	}
+}
+
+// Get handles GET /api/v1/shipments/{id}
+func (h *ShipmentHandler) Get(w http.ResponseWriter, r *http.Request) {
+	shipment, err := h.shipments.Get(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, services.ErrShipmentNotFound) {
+		http.Error(w, "Shipment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading shipment: %v", err)
+		http.Error(w, "Failed to load shipment", http.StatusInternalServerError)
+		return
+	}
+	if !recordAudit(w, r, audit.PHIAccess(audit.EntityShipment, shipment.ID.Hex())) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(shipment)
+}
+
+// Documents handles GET /api/v1/shipments/{id}/documents
+// Returns short-lived links to the packing slip and fallback 4x6 label, rendering them if they are missing
+func (h *ShipmentHandler) Documents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	shipment, err := h.shipments.Get(ctx, id)
+	if errors.Is(err, services.ErrShipmentNotFound) {
+		http.Error(w, "Shipment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading shipment %s: %v", id, err)
+		http.Error(w, "Failed to load shipment", http.StatusInternalServerError)
+		return
+	}
+
+	links, expiresAt, err := h.documents.Links(ctx, shipment)
+	if err != nil {
+		log.Printf("Error preparing documents for shipment %s: %v", id, err)
+		http.Error(w, "Failed to prepare shipment documents", http.StatusInternalServerError)
+		return
+	}
+	if !recordAudit(w, r, audit.PHIAccess(audit.EntityShipment, shipment.ID.Hex())) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ShipmentDocumentsResponse{
+		ShipmentID: shipment.ID.Hex(),
+		ExpiresAt:  expiresAt,
+		Documents:  links,
+	})
+}
+
+// ReportExcursion handles POST /api/v1/shipments/{id}/temperature-excursions
+// Flags the cold chain shipment and publishes shipment.temperature_excursion so a replacement is shipped
+func (h *ShipmentHandler) ReportExcursion(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req TemperatureExcursionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Source == "" {
+		http.Error(w, "source is required", http.StatusBadRequest)
+		return
+	}
+
+	excursion := models.TemperatureExcursion{
+		Source:          models.ExcursionSource(strings.TrimSpace(string(req.Source))),
+		TemperatureC:    req.TemperatureC,
+		DurationMinutes: req.DurationMinutes,
+		Note:            req.Note,
+		ReportedBy:      user.ID,
+	}
+	if req.ObservedAt != "" {
+		observedAt, err := time.Parse(time.RFC3339, req.ObservedAt)
+		if err != nil {
+			http.Error(w, "observed_at must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		excursion.ObservedAt = observedAt
+	}
+
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	shipment, err := h.shipments.ReportExcursion(ctx, id, excursion)
+	switch {
+	case errors.Is(err, services.ErrShipmentNotFound):
+		http.Error(w, "Shipment not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrInvalidExcursion):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		log.Printf("Error recording temperature excursion for shipment %s: %v", id, err)
+		http.Error(w, "Failed to record temperature excursion", http.StatusInternalServerError)
+		return
+	}
+
+	// Every report until a replacement exists re-requests one; the replacement worker ships it once
+	correlationID := flowCorrelationID(r, shipment.CorrelationID)
+	excursionReported := audit.Entry{
+		EventType:  audit.EventShipmentChanged,
+		EntityType: audit.EntityShipment,
+		EntityID:   shipment.ID.Hex(),
+		Action:     audit.ActionUpdate,
+		Details: map[string]interface{}{
+			"temperature_excursion": excursion.Source,
+			"correlation_id":        correlationID,
+		},
+	}
+	if !recordAudit(w, r, excursionReported) {
+		return
+	}
+	replacementRequested := shipment.ReplacementShipmentID == nil
+	if replacementRequested {
+		if err := events.PublishTemperatureExcursion(ctx, h.deps.KafkaProducer, correlationID, shipment, excursion); err != nil {
+			log.Printf("[correlation_id=%s] Error publishing temperature excursion event for shipment %s: %v", correlationID, id, err)
+			http.Error(w, "Excursion recorded but event publish failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	log.Printf("[correlation_id=%s] Temperature excursion reported for shipment %s by %s (%s)", correlationID, id, user.ID, excursion.Source)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(TemperatureExcursionResponse{
+		Shipment:             shipment,
+		ReplacementRequested: replacementRequested,
+	})
+}
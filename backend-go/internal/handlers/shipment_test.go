@@ -0,0 +1,49 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+)
+
+// TestShipmentHandler_ExcursionValidation tests excursion report validation before any database access
+func TestShipmentHandler_ExcursionValidation(t *testing.T) {
+	handler := NewShipmentHandler(&Dependencies{}, nil, nil)
+	user := &middleware.AuthUser{ID: "ops_1", Role: middleware.RoleOpsManager}
+
+	tests := []struct {
+		name       string
+		body       string
+		user       *middleware.AuthUser
+		wantStatus int
+	}{
+		{"unauthenticated", `{"source":"data_logger","temperature_c":12}`, nil, http.StatusUnauthorized},
+		{"malformed body", `{`, user, http.StatusBadRequest},
+		{"missing source", `{"temperature_c":12}`, user, http.StatusBadRequest},
+		{"bad observed_at", `{"source":"patient","observed_at":"yesterday"}`, user, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/shipments/65a000000000000000000004/temperature-excursions", bytes.NewBufferString(tt.body))
+
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", "65a000000000000000000004")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx)
+		if tt.user != nil {
+			ctx = context.WithValue(ctx, middleware.UserKey{}, tt.user)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ReportExcursion(rr, req.WithContext(ctx))
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"github.com/phil-my-meds/backend-gogit/internal/sms"
+)
+
+// SMSWebhookHandler handles SMS provider webhooks: delivery receipts and texts patients send us
+// Requests are authenticated by signature rather than by user or API key
+type SMSWebhookHandler struct {
+	deps          *Dependencies
+	notifications *services.NotificationService
+	preferences   *services.CommunicationPreferenceService
+	authToken     string
+	baseURL       string // public base URL the provider was configured with; the signature covers the full URL
+	support       string // how to reach the pharmacy team, sent in reply to HELP
+}
+
+// twimlResponse is the TwiML document returned to the provider, with an optional reply text
+type twimlResponse struct {
+	XMLName xml.Name `xml:"Response"`
+	Message string   `xml:"Message,omitempty"`
+}
+
+// NewSMSWebhookHandler creates a new SMS webhook handler verifying signatures with the provider auth token
+func NewSMSWebhookHandler(deps *Dependencies, notifications *services.NotificationService, preferences *services.CommunicationPreferenceService, authToken, baseURL, support string) *SMSWebhookHandler {
+	return &SMSWebhookHandler{
+		deps:          deps,
+		notifications: notifications,
+		preferences:   preferences,
+		authToken:     authToken,
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		support:       support,
+	}
+}
+
+// Status handles POST /api/v1/webhooks/sms/status
+// Delivery receipts are recorded on their notification; an undelivered text falls back to the next
+// channel. Receipts for messages we have no record of are acknowledged and ignored so they are not retried.
+func (h *SMSWebhookHandler) Status(w http.ResponseWriter, r *http.Request) {
+	params, ok := h.verify(w, r)
+	if !ok {
+		return
+	}
+
+	callback, err := sms.ParseStatusCallback(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	notification, err := h.notifications.ApplyReceipt(r.Context(), callback)
+	if errors.Is(err, services.ErrNotificationNotFound) {
+		log.Printf("Ignoring SMS status %s for unknown message %s", callback.Status, callback.MessageID)
+		writeTwiML(w, twimlResponse{})
+		return
+	}
+	if err != nil {
+		log.Printf("Error applying SMS status %s for message %s: %v", callback.Status, callback.MessageID, err)
+		http.Error(w, "Failed to apply delivery receipt", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("SMS message %s is %s; notification %s is %s on %s", callback.MessageID, callback.Status, notification.ID.Hex(), notification.Status, notification.Channel)
+	writeTwiML(w, twimlResponse{})
+}
+
+// Inbound handles POST /api/v1/webhooks/sms/inbound
+// STOP and its synonyms opt the number out of texts and START opts it back in, whichever patient it
+// belongs to. HELP is answered with how to reach the pharmacy team; other texts are only logged.
+func (h *SMSWebhookHandler) Inbound(w http.ResponseWriter, r *http.Request) {
+	params, ok := h.verify(w, r)
+	if !ok {
+		return
+	}
+
+	inbound, err := sms.ParseInboundMessage(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	phone, err := sms.NormalizePhone(inbound.From)
+	if err != nil {
+		log.Printf("Ignoring inbound SMS %s from unusable number %q", inbound.MessageID, inbound.From)
+		writeTwiML(w, twimlResponse{})
+		return
+	}
+
+	ctx := r.Context()
+	switch inbound.Keyword {
+	case sms.KeywordOptOut:
+		patients, err := h.preferences.OptOut(ctx, phone, models.ConsentSourceKeyword)
+		if err != nil {
+			log.Printf("Error opting out %s: %v", phone, err)
+			http.Error(w, "Failed to record opt-out", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("SMS opt-out from %s; revoked SMS consent for %d patients", phone, patients)
+	case sms.KeywordOptIn:
+		patients, err := h.preferences.OptIn(ctx, phone, models.ConsentSourceKeyword)
+		if err != nil {
+			log.Printf("Error opting in %s: %v", phone, err)
+			http.Error(w, "Failed to record opt-in", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("SMS opt-in from %s; granted SMS consent for %d patients", phone, patients)
+	case sms.KeywordHelp:
+		writeTwiML(w, twimlResponse{Message: "PhilMyMeds: for help contact " + h.support + ". Reply STOP to opt out."})
+		return
+	default:
+		log.Printf("Inbound SMS %s from %s is not a keyword; not handled", inbound.MessageID, phone)
+	}
+	writeTwiML(w, twimlResponse{})
+}
+
+// verify reads the form body and checks the provider signature, writing an error response if it fails
+func (h *SMSWebhookHandler) verify(w http.ResponseWriter, r *http.Request) (url.Values, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	params, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if err := sms.VerifyWebhookSignature(h.baseURL+r.URL.RequestURI(), params, r.Header.Get(sms.SignatureHeader), h.authToken); err != nil {
+		log.Printf("Rejected SMS webhook: %v", err)
+		http.Error(w, "Invalid signature", http.StatusBadRequest)
+		return nil, false
+	}
+	return params, true
+}
+
+// writeTwiML writes a TwiML document as the response
+func writeTwiML(w http.ResponseWriter, response twimlResponse) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,69 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/phil-my-meds/backend-gogit/internal/sms"
+)
+
+// TestSMSWebhookHandler_RejectsUnverifiedRequests tests that unsigned or mis-signed webhooks are rejected before any lookups
+func TestSMSWebhookHandler_RejectsUnverifiedRequests(t *testing.T) {
+	handler := NewSMSWebhookHandler(&Dependencies{}, nil, nil, "twilio_test", "https://api.philmymeds.test/", "support")
+	params := url.Values{"MessageSid": {"SM123"}, "From": {"+15552345678"}, "Body": {"STOP"}}
+	const path = "/api/v1/webhooks/sms/inbound"
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+	}{
+		{"missing signature", params.Encode(), ""},
+		{"wrong token", params.Encode(), sms.SignWebhookRequest("https://api.philmymeds.test"+path, params, "twilio_other")},
+		{"wrong URL", params.Encode(), sms.SignWebhookRequest("https://evil.test"+path, params, "twilio_test")},
+		{"body changed after signing", strings.Replace(params.Encode(), "STOP", "START", 1), sms.SignWebhookRequest("https://api.philmymeds.test"+path, params, "twilio_test")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, receive := range []http.HandlerFunc{handler.Inbound, handler.Status} {
+				req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				if tt.signature != "" {
+					req.Header.Set(sms.SignatureHeader, tt.signature)
+				}
+				rec := httptest.NewRecorder()
+
+				receive(rec, req)
+
+				if rec.Code != http.StatusBadRequest {
+					t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+				}
+			}
+		})
+	}
+}
+
+// TestSMSWebhookHandler_Help tests that a signed HELP text is answered with TwiML without any lookups
+func TestSMSWebhookHandler_Help(t *testing.T) {
+	handler := NewSMSWebhookHandler(&Dependencies{}, nil, nil, "twilio_test", "https://api.philmymeds.test", "support at (555) 010-2000")
+	params := url.Values{"MessageSid": {"SM123"}, "From": {"+15552345678"}, "Body": {" help "}}
+	const path = "/api/v1/webhooks/sms/inbound"
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(params.Encode()))
+	req.Header.Set(sms.SignatureHeader, sms.SignWebhookRequest("https://api.philmymeds.test"+path, params, "twilio_test"))
+	rec := httptest.NewRecorder()
+
+	handler.Inbound(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/xml" {
+		t.Fatalf("Expected a TwiML response, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "<Response><Message>PhilMyMeds: for help contact support at (555) 010-2000.") {
+		t.Errorf("Expected the support contact in the reply, got %s", rec.Body.String())
+	}
+}
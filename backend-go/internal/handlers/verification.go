@@ -0,0 +1,175 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+)
+
+// verificationStatuses are the statuses verifications may be listed by
+var verificationStatuses = map[models.VerificationStatus]bool{
+	models.VerificationPending:              true,
+	models.VerificationApproved:             true,
+	models.VerificationRejected:             true,
+	models.VerificationReturnedToPrescriber: true,
+}
+
+// VerificationHandler handles the queue of paid prescriptions waiting on a pharmacist's clinical verification
+type VerificationHandler struct {
+	deps          *Dependencies
+	verifications *services.VerificationService
+}
+
+// VerificationDecisionRequest represents the request body for a pharmacist's verification decision
+type VerificationDecisionRequest struct {
+	Decision models.VerificationStatus `json:"decision"` // approved, rejected or returned_to_prescriber
+	Reason   string                    `json:"reason,omitempty"`
+}
+
+// NewVerificationHandler creates a new verification handler
+func NewVerificationHandler(deps *Dependencies, verifications *services.VerificationService) *VerificationHandler {
+	return &VerificationHandler{
+		deps:          deps,
+		verifications: verifications,
+	}
+}
+
+// List handles GET /api/v1/verifications?status={status}&prescription_id={id}&limit={n}
+// The queue is listed oldest first
+func (h *VerificationHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := services.VerificationFilter{
+		Status:         models.VerificationStatus(strings.TrimSpace(query.Get("status"))),
+		PrescriptionID: strings.TrimSpace(query.Get("prescription_id")),
+	}
+	if filter.Status != "" && !verificationStatuses[filter.Status] {
+		http.Error(w, "status must be one of pending_verification, approved, rejected, returned_to_prescriber", http.StatusBadRequest)
+		return
+	}
+	if limit, err := strconv.ParseInt(query.Get("limit"), 10, 64); err == nil {
+		filter.Limit = limit
+	}
+
+	verifications, err := h.verifications.List(r.Context(), filter)
+	if err != nil {
+		log.Printf("[correlation_id=%s] Error listing verifications: %v", middleware.GetCorrelationID(r), err)
+		http.Error(w, "Failed to list verifications", http.StatusInternalServerError)
+		return
+	}
+	accessed := make([]audit.Entry, len(verifications))
+	for i, verification := range verifications {
+		accessed[i] = audit.PHIAccess(audit.EntityVerification, verification.ID.Hex())
+	}
+	if !recordAudit(w, r, accessed...) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"verifications": verifications,
+	})
+}
+
+// Get handles GET /api/v1/verifications/{id}
+// Returns the verification with the prescription's sig, DUR findings and original payload
+func (h *VerificationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	detail, err := h.verifications.Detail(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, services.ErrVerificationNotFound) {
+		http.Error(w, "Verification not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[correlation_id=%s] Error loading verification: %v", middleware.GetCorrelationID(r), err)
+		http.Error(w, "Failed to load verification", http.StatusInternalServerError)
+		return
+	}
+	if !recordAudit(w, r, audit.PHIAccess(audit.EntityVerification, detail.ID.Hex())) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(detail)
+}
+
+// Decide handles POST /api/v1/verifications/{id}/decision
+// An approval ships the prescription; a rejection or return to the prescriber, which needs a reason, sends it to an exception.
+// The decision records the pharmacist's name and license number. Posting the same decision again retries a follow-up that failed.
+func (h *VerificationHandler) Decide(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req VerificationDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Decision == models.VerificationPending || !verificationStatuses[req.Decision] {
+		http.Error(w, "decision must be approved, rejected or returned_to_prescriber", http.StatusBadRequest)
+		return
+	}
+	if req.Decision != models.VerificationApproved && strings.TrimSpace(req.Reason) == "" {
+		http.Error(w, "reason is required unless approved", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	verification, err := h.verifications.Decide(ctx, id, services.VerificationDecision{
+		Status:  req.Decision,
+		By:      user.ID,
+		ByName:  user.Name,
+		License: user.License,
+		Reason:  req.Reason,
+	})
+	switch {
+	case errors.Is(err, services.ErrVerificationNotFound):
+		http.Error(w, "Verification not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrInvalidVerificationDecision):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, services.ErrVerificationConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, audit.ErrUnavailable):
+		log.Printf("Error auditing verification %s: %v", id, err)
+		http.Error(w, "Audit log unavailable", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		log.Printf("Error deciding verification %s: %v", id, err)
+		http.Error(w, "Failed to decide verification", http.StatusInternalServerError)
+		return
+	}
+
+	correlationID := flowCorrelationID(r, verification.CorrelationID)
+	moved, err := services.CompleteVerification(ctx, h.deps.MongoClient, h.deps.KafkaProducer, correlationID, verification)
+	if err != nil {
+		log.Printf("[correlation_id=%s] Error completing verification %s: %v", correlationID, id, err)
+		http.Error(w, "Verification decided but follow-up failed", http.StatusInternalServerError)
+		return
+	}
+	if !moved {
+		log.Printf("⚠️  [correlation_id=%s] Verification %s decided but prescription %s is no longer pending verification", correlationID, id, verification.PrescriptionID.Hex())
+	}
+
+	log.Printf("[correlation_id=%s] Verification %s %s by %s (license %s)", correlationID, id, verification.Status, user.ID, verification.LicenseNumber)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(verification)
+}
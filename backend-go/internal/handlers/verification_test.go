@@ -0,0 +1,64 @@
+// Package handlers provides HTTP request handlers tests
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/phil-my-meds/backend-gogit/internal/middleware"
+)
+
+// TestVerificationHandler_DecisionValidation tests decision validation before any database access
+func TestVerificationHandler_DecisionValidation(t *testing.T) {
+	handler := NewVerificationHandler(&Dependencies{}, nil)
+	user := &middleware.AuthUser{ID: "pharm_1", Role: middleware.RolePharmacist, License: "RPH-12345"}
+
+	tests := []struct {
+		name       string
+		body       string
+		user       *middleware.AuthUser
+		wantStatus int
+	}{
+		{"unauthenticated", `{"decision":"approved"}`, nil, http.StatusUnauthorized},
+		{"malformed body", `{`, user, http.StatusBadRequest},
+		{"missing decision", `{}`, user, http.StatusBadRequest},
+		{"pending decision", `{"decision":"pending_verification"}`, user, http.StatusBadRequest},
+		{"rejection without reason", `{"decision":"rejected","reason":"  "}`, user, http.StatusBadRequest},
+		{"return without reason", `{"decision":"returned_to_prescriber"}`, user, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/verifications/65a000000000000000000001/decision", bytes.NewBufferString(tt.body))
+
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", "65a000000000000000000001")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx)
+		if tt.user != nil {
+			ctx = context.WithValue(ctx, middleware.UserKey{}, tt.user)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.Decide(rr, req.WithContext(ctx))
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+// TestVerificationHandler_ListValidation tests query parameter validation before any database access
+func TestVerificationHandler_ListValidation(t *testing.T) {
+	handler := NewVerificationHandler(&Dependencies{}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/verifications?status=pending", nil)
+	rr := httptest.NewRecorder()
+	handler.List(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown status, got %d (%s)", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+}
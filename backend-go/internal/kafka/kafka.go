@@ -18,24 +18,45 @@ const (
 	// TopicValidationCompleted - published when prescription validation is completed
 	TopicValidationCompleted = "prescription.validation.completed"
 
+	// TopicDURReviewRequested - published when drug utilization review sends a prescription to a pharmacist
+	TopicDURReviewRequested = "prescription.dur_review.requested"
+
 	// TopicEnrollmentCompleted - published when patient enrollment is done
 	TopicEnrollmentCompleted = "patient.enrollment.completed"
 
+	// TopicEnrollmentLinkCreated - published when a patient is sent a magic link to enroll
+	TopicEnrollmentLinkCreated = "patient.enrollment.link.created"
+
 	// TopicPharmacySelected - published when a pharmacy is selected for a prescription
 	TopicPharmacySelected = "pharmacy.selected"
 
 	// TopicAdjudicationCompleted - published when insurance adjudication is completed
 	TopicAdjudicationCompleted = "insurance.adjudication.completed"
 
+	// TopicPrescriptionException - published when a prescription leaves the automated flow for ops review
+	TopicPrescriptionException = "prescription.exception"
+
+	// TopicPriorAuthUpdated - published on each prior authorization status transition
+	TopicPriorAuthUpdated = "prior_authorization.updated"
+
 	// TopicPaymentLinkCreated - published when a payment link is created
 	TopicPaymentLinkCreated = "payment.link.created"
 
 	// TopicPaymentCompleted - published when payment is completed
 	TopicPaymentCompleted = "payment.completed"
 
+	// TopicPaymentRefunded - published when a refund of a captured payment is issued
+	TopicPaymentRefunded = "payment.refunded"
+
+	// TopicVerificationCompleted - published when a pharmacist approves a paid prescription for dispensing
+	TopicVerificationCompleted = "prescription.verification.completed"
+
 	// TopicShipmentLabelCreated - published when a shipment label is created
 	TopicShipmentLabelCreated = "shipment.label.created"
 
+	// TopicShipmentTemperatureExcursion - published when a cold chain shipment is reported out of range
+	TopicShipmentTemperatureExcursion = "shipment.temperature_excursion"
+
 	// TopicShipmentDelivered - published when shipment is delivered
 	TopicShipmentDelivered = "shipment.delivered"
 
@@ -0,0 +1,26 @@
+// Package middleware provides HTTP middleware functions
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+)
+
+// AuditMiddleware puts the audit logger and the client's IP address and user agent on the request context
+// Must be used after RealIP; the authentication middleware adds the acting user.
+func AuditMiddleware(logger *audit.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := r.RemoteAddr
+			if host, _, err := net.SplitHostPort(ip); err == nil {
+				ip = host
+			}
+
+			ctx := audit.WithLogger(r.Context(), logger)
+			ctx = audit.WithActor(ctx, audit.Actor{IPAddress: ip, UserAgent: r.UserAgent()})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
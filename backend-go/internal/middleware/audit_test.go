@@ -0,0 +1,29 @@
+// Package middleware provides HTTP middleware tests
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+)
+
+// TestAuditMiddleware tests that the client and the authenticated user are recorded as the audit actor
+func TestAuditMiddleware(t *testing.T) {
+	var actor audit.Actor
+	handler := AuditMiddleware(nil)(AuthMiddleware(testSecret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = audit.ActorFrom(r.Context())
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/refunds", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set("User-Agent", "ops-dashboard/1.0")
+	req.Header.Set("Authorization", "Bearer "+testToken(t, RoleOpsAgent, time.Hour))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if actor.UserID != "ops_user_1" || actor.IPAddress != "203.0.113.7" || actor.UserAgent != "ops-dashboard/1.0" {
+		t.Errorf("Unexpected audit actor: %+v", actor)
+	}
+}
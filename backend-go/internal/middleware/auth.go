@@ -0,0 +1,200 @@
+// Package middleware provides HTTP middleware functions
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+)
+
+// Roles for ops dashboard users
+const (
+	RoleAdmin      = "admin"
+	RoleOpsManager = "ops_manager"
+	RoleOpsAgent   = "ops_agent"
+	RoleCompliance = "compliance" // reviews the audit log; not an ops role
+	RolePharmacist = "pharmacist" // decides drug utilization reviews; not an ops role
+)
+
+// OpsRoles are the roles allowed to act on the operations dashboard
+var OpsRoles = []string{RoleAdmin, RoleOpsManager, RoleOpsAgent}
+
+// ClinicalRoles are the roles allowed to see patients' allergies, the DUR review queue and the verification queue
+var ClinicalRoles = []string{RoleAdmin, RoleOpsManager, RoleOpsAgent, RolePharmacist}
+
+// UserKey is the context key for the authenticated user
+type UserKey struct{}
+
+// AuthUser represents the authenticated user extracted from a JWT
+type AuthUser struct {
+	ID      string
+	Name    string
+	Role    string
+	License string // pharmacist license number, for clinical verification
+}
+
+// Claims represents the JWT claims issued to dashboard users
+type Claims struct {
+	Subject   string `json:"sub"`
+	Name      string `json:"name,omitempty"`
+	Role      string `json:"role"`
+	License   string `json:"license,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var (
+	// ErrInvalidToken is returned when a token is malformed or its signature does not match
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned when a token's exp claim is in the past
+	ErrTokenExpired = errors.New("token expired")
+)
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// SignToken creates an HS256 JWT for the given claims
+func SignToken(secret string, claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + signJWT(secret, unsigned), nil
+}
+
+// ParseToken verifies an HS256 JWT and returns its claims
+func ParseToken(secret, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &h); err != nil || h.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	expected := signJWT(secret, parts[0]+"."+parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.ExpiresAt == 0 || time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+
+	return &claims, nil
+}
+
+func signJWT(secret, unsigned string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// AuthMiddleware validates the Bearer JWT and adds the user to the request context
+func AuthMiddleware(secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			token := strings.TrimPrefix(authHeader, "Bearer ")
+			if authHeader == "" || token == authHeader {
+				http.Error(w, "Missing bearer token", http.StatusUnauthorized)
+				return
+			}
+
+			claims, err := ParseToken(secret, token)
+			if err != nil {
+				http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			user := &AuthUser{
+				ID:      claims.Subject,
+				Name:    claims.Name,
+				Role:    claims.Role,
+				License: claims.License,
+			}
+
+			ctx := context.WithValue(r.Context(), UserKey{}, user)
+			ctx = audit.WithUser(ctx, user.ID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireRole rejects requests whose authenticated user does not have one of the given roles
+// Must be used after AuthMiddleware
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUser(r)
+			if user == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			for _, role := range roles {
+				if user.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+}
+
+// RequireLicensedPharmacist rejects requests whose authenticated user is not a pharmacist with a license on their token
+// Must be used after AuthMiddleware
+func RequireLicensedPharmacist() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUser(r)
+			if user == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if user.Role != RolePharmacist || strings.TrimSpace(user.License) == "" {
+				http.Error(w, "Forbidden: a licensed pharmacist is required", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetUser extracts the authenticated user from the request context
+func GetUser(r *http.Request) *AuthUser {
+	if user, ok := r.Context().Value(UserKey{}).(*AuthUser); ok {
+		return user
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Package middleware provides HTTP middleware tests
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+func testToken(t *testing.T, role string, expiresIn time.Duration) string {
+	token, err := SignToken(testSecret, Claims{
+		Subject:   "ops_user_1",
+		Name:      "Ops User",
+		Role:      role,
+		ExpiresAt: time.Now().Add(expiresIn).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+// TestParseToken tests token signing, verification, expiry and tampering
+func TestParseToken(t *testing.T) {
+	token := testToken(t, RoleOpsAgent, time.Hour)
+
+	claims, err := ParseToken(testSecret, token)
+	if err != nil {
+		t.Fatalf("Expected valid token, got error: %v", err)
+	}
+	if claims.Subject != "ops_user_1" || claims.Role != RoleOpsAgent {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	if _, err := ParseToken("wrong-secret", token); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for wrong secret, got %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + strings.TrimRight(parts[1], "=") + "x." + parts[2]
+	if _, err := ParseToken(testSecret, tampered); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for tampered payload, got %v", err)
+	}
+
+	expired := testToken(t, RoleOpsAgent, -time.Minute)
+	if _, err := ParseToken(testSecret, expired); err != ErrTokenExpired {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+}
+
+// TestAuthMiddleware_RequireRole tests authentication and role checks
+func TestAuthMiddleware_RequireRole(t *testing.T) {
+	handler := AuthMiddleware(testSecret)(RequireRole(OpsRoles...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := GetUser(r); user == nil || user.ID != "ops_user_1" {
+			t.Errorf("Expected authenticated user in context, got %+v", user)
+		}
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		name       string
+		authHeader string
+		wantStatus int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"invalid token", "Bearer not-a-jwt", http.StatusUnauthorized},
+		{"wrong role", "Bearer " + testToken(t, "patient", time.Hour), http.StatusForbidden},
+		{"ops agent", "Bearer " + testToken(t, RoleOpsAgent, time.Hour), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		if tt.authHeader != "" {
+			req.Header.Set("Authorization", tt.authHeader)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.wantStatus, rr.Code)
+		}
+	}
+}
+
+// TestRequireLicensedPharmacist tests that only pharmacists with a license claim pass
+func TestRequireLicensedPharmacist(t *testing.T) {
+	handler := AuthMiddleware(testSecret)(RequireLicensedPharmacist()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := GetUser(r); user == nil || user.License != "RPH-12345" {
+			t.Errorf("Expected the license in context, got %+v", user)
+		}
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	sign := func(role, license string) string {
+		token, err := SignToken(testSecret, Claims{Subject: "pharm_1", Role: role, License: license, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{"ops manager with license", sign(RoleOpsManager, "RPH-12345"), http.StatusForbidden},
+		{"pharmacist without license", sign(RolePharmacist, ""), http.StatusForbidden},
+		{"licensed pharmacist", sign(RolePharmacist, "RPH-12345"), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.wantStatus, rr.Code)
+		}
+	}
+}
@@ -24,7 +24,8 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		// Log request details
 		duration := time.Since(start)
 		log.Printf(
-			"[%s] %s %s %d %v",
+			"[correlation_id=%s] [%s] %s %s %d %v",
+			GetCorrelationID(r),
 			r.Method,
 			r.RequestURI,
 			r.RemoteAddr,
@@ -0,0 +1,67 @@
+// Package middleware provides HTTP middleware functions
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+)
+
+// PharmacyAPIKeyHeader is the header partner pharmacies send their API key in
+const PharmacyAPIKeyHeader = "X-API-Key"
+
+// ErrInvalidAPIKey is returned by a PharmacyAuthenticator when the key matches no active pharmacy
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// PharmacyKey is the context key for the authenticated pharmacy
+type PharmacyKey struct{}
+
+// AuthPharmacy represents the partner pharmacy authenticated by API key
+type AuthPharmacy struct {
+	ID      string // pharmacies collection ObjectID (hex)
+	NCPDPID string
+	Name    string
+}
+
+// PharmacyAuthenticator resolves an API key to a pharmacy, returning ErrInvalidAPIKey if it matches none
+type PharmacyAuthenticator func(ctx context.Context, apiKey string) (*AuthPharmacy, error)
+
+// PharmacyAuthMiddleware authenticates partner pharmacy requests by API key and adds the pharmacy to the request context
+func PharmacyAuthMiddleware(authenticate PharmacyAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey := strings.TrimSpace(r.Header.Get(PharmacyAPIKeyHeader))
+			if apiKey == "" {
+				http.Error(w, "Missing API key", http.StatusUnauthorized)
+				return
+			}
+
+			pharmacy, err := authenticate(r.Context(), apiKey)
+			if errors.Is(err, ErrInvalidAPIKey) {
+				http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				log.Printf("Error authenticating pharmacy API key: %v", err)
+				http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), PharmacyKey{}, pharmacy)
+			ctx = audit.WithUser(ctx, "pharmacy:"+pharmacy.ID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetPharmacy extracts the authenticated pharmacy from the request context
+func GetPharmacy(r *http.Request) *AuthPharmacy {
+	if pharmacy, ok := r.Context().Value(PharmacyKey{}).(*AuthPharmacy); ok {
+		return pharmacy
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Package middleware provides HTTP middleware tests
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testPharmacyAuthenticator(ctx context.Context, apiKey string) (*AuthPharmacy, error) {
+	switch apiKey {
+	case "good-key":
+		return &AuthPharmacy{ID: "pharmacy_1", NCPDPID: "1234567", Name: "Test Pharmacy"}, nil
+	case "broken-key":
+		return nil, errors.New("database unavailable")
+	}
+	return nil, ErrInvalidAPIKey
+}
+
+// TestPharmacyAuthMiddleware tests API key authentication of partner pharmacy requests
+func TestPharmacyAuthMiddleware(t *testing.T) {
+	var got *AuthPharmacy
+	handler := PharmacyAuthMiddleware(testPharmacyAuthenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = GetPharmacy(r)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		apiKey string
+		want   int
+	}{
+		{"missing key", "", http.StatusUnauthorized},
+		{"unknown key", "bad-key", http.StatusUnauthorized},
+		{"lookup error", "broken-key", http.StatusInternalServerError},
+		{"valid key", "good-key", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/adjudication/results", nil)
+			if tt.apiKey != "" {
+				req.Header.Set(PharmacyAPIKeyHeader, tt.apiKey)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, rr.Code)
+			}
+			if tt.want == http.StatusOK && (got == nil || got.NCPDPID != "1234567") {
+				t.Errorf("Expected authenticated pharmacy in context, got %+v", got)
+			}
+		})
+	}
+}
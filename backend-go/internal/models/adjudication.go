@@ -0,0 +1,167 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ClaimStatus represents the outcome of an insurance claim
+type ClaimStatus string
+
+const (
+	ClaimStatusPaid     ClaimStatus = "paid"
+	ClaimStatusPartial  ClaimStatus = "partial" // paid for less than the requested quantity
+	ClaimStatusRejected ClaimStatus = "rejected"
+)
+
+// NCPDP reject codes returned by claim adjudication
+const (
+	RejectMissingDaysSupply     = "19" // M/I Days Supply
+	RejectNonMatchedProduct     = "54" // Non-Matched Product/Service ID Number
+	RejectPatientNotCovered     = "65" // Patient Is Not Covered
+	RejectProductNotCovered     = "70" // Product/Service Not Covered
+	RejectPriorAuthRequired     = "75" // Prior Authorization Required
+	RejectPlanLimitsExceeded    = "76" // Plan Limitations Exceeded
+	RejectRefillTooSoon         = "79" // Refill Too Soon
+	RejectMissingQuantityFilled = "E7" // M/I Quantity Dispensed
+)
+
+// RejectCodeDescriptions maps NCPDP reject codes to their standard descriptions
+var RejectCodeDescriptions = map[string]string{
+	RejectMissingDaysSupply:     "M/I Days Supply",
+	RejectNonMatchedProduct:     "Non-Matched Product/Service ID Number",
+	RejectPatientNotCovered:     "Patient Is Not Covered",
+	RejectProductNotCovered:     "Product/Service Not Covered",
+	RejectPriorAuthRequired:     "Prior Authorization Required",
+	RejectPlanLimitsExceeded:    "Plan Limitations Exceeded",
+	RejectRefillTooSoon:         "Refill Too Soon",
+	RejectMissingQuantityFilled: "M/I Quantity Dispensed",
+}
+
+// RejectCode is an NCPDP reject code with its description
+type RejectCode struct {
+	Code        string `bson:"code" json:"code"`
+	Description string `bson:"description" json:"description"`
+}
+
+// NewRejectCode builds a RejectCode with its standard description
+func NewRejectCode(code string) RejectCode {
+	return RejectCode{Code: code, Description: RejectCodeDescriptions[code]}
+}
+
+// PlanRule holds the adjudication rules for a pharmacy benefit plan
+type PlanRule struct {
+	Plan          PlanKey     `bson:",inline" json:"plan"`
+	Name          string      `bson:"name,omitempty" json:"name,omitempty"`
+	InsuranceType string      `bson:"insurance_type,omitempty" json:"insurance_type,omitempty"` // commercial, medicare, medicaid
+	DispensingFee float64     `bson:"dispensing_fee" json:"dispensing_fee"`
+	TierCopays    []TierCopay `bson:"tier_copays" json:"tier_copays"`
+
+	// Maximum days supply per fill (0 = no limit)
+	MaxDaysSupply int `bson:"max_days_supply,omitempty" json:"max_days_supply,omitempty"`
+
+	// Fraction of the previous fill's days supply that must elapse before a refill (e.g. 0.75)
+	RefillTooSoonPercent float64 `bson:"refill_too_soon_percent,omitempty" json:"refill_too_soon_percent,omitempty"`
+
+	// Pay up to the formulary quantity limit instead of rejecting over-limit quantities
+	AllowPartialFill bool `bson:"allow_partial_fill" json:"allow_partial_fill"`
+}
+
+// TierCopay describes patient cost share for a formulary tier
+type TierCopay struct {
+	Tier               int     `bson:"tier" json:"tier"`
+	Copay              float64 `bson:"copay" json:"copay"`                                                 // flat amount
+	CoinsurancePercent float64 `bson:"coinsurance_percent,omitempty" json:"coinsurance_percent,omitempty"` // fraction of total cost (0.0 - 1.0)
+	MaxCopay           float64 `bson:"max_copay,omitempty" json:"max_copay,omitempty"`                     // cap on patient cost share (0 = none)
+}
+
+// ClaimResult is the adjudicated response for an insurance claim
+// JSON field names are consumed by the payment worker (copay_amount)
+type ClaimResult struct {
+	ClaimID     string       `bson:"claim_id" json:"claim_id"`
+	Status      ClaimStatus  `bson:"status" json:"status"`
+	RejectCodes []RejectCode `bson:"reject_codes,omitempty" json:"reject_codes,omitempty"`
+	Message     string       `bson:"message,omitempty" json:"message,omitempty"`
+
+	Tier              int `bson:"tier,omitempty" json:"tier,omitempty"`
+	RequestedQuantity int `bson:"requested_quantity" json:"requested_quantity"`
+	ApprovedQuantity  int `bson:"approved_quantity" json:"approved_quantity"`
+	DaysSupply        int `bson:"days_supply" json:"days_supply"`
+
+	IngredientCost float64 `bson:"ingredient_cost" json:"ingredient_cost"`
+	DispensingFee  float64 `bson:"dispensing_fee" json:"dispensing_fee"`
+	TotalCost      float64 `bson:"total_cost" json:"total_cost"`
+	InsurancePays  float64 `bson:"insurance_pays" json:"insurance_pays"`
+	CopayAmount    float64 `bson:"copay_amount" json:"copay_amount"`
+
+	AdjudicatedAt time.Time `bson:"adjudicated_at" json:"adjudicated_at"`
+}
+
+// RejectCodeValues returns the bare reject codes of a claim
+func (c *ClaimResult) RejectCodeValues() []string {
+	codes := make([]string, 0, len(c.RejectCodes))
+	for _, rc := range c.RejectCodes {
+		codes = append(codes, rc.Code)
+	}
+	return codes
+}
+
+// AdjudicationMode controls whether claims are adjudicated by the pharmacy or the simulator
+type AdjudicationMode string
+
+const (
+	AdjudicationModePharmacy  AdjudicationMode = "pharmacy"  // wait for the pharmacy to report results via callback
+	AdjudicationModeSimulator AdjudicationMode = "simulator" // adjudicate in-process against plan rules
+)
+
+// Adjudication result sources
+const (
+	AdjudicationSourcePharmacy  = "pharmacy"  // reported by the dispensing pharmacy via the results callback
+	AdjudicationSourceSimulator = "simulator" // produced by the adjudication simulator
+)
+
+// AdjudicationRecord is a document in the adjudications collection
+type AdjudicationRecord struct {
+	ID                   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PrescriptionID       primitive.ObjectID `bson:"prescription_id" json:"prescription_id"`
+	PatientID            string             `bson:"patient_id,omitempty" json:"patient_id,omitempty"`
+	PharmacyID           string             `bson:"pharmacy_id" json:"pharmacy_id"`
+	MemberID             string             `bson:"member_id,omitempty" json:"member_id,omitempty"`
+	NDC                  string             `bson:"ndc" json:"ndc"`
+	Source               string             `bson:"source" json:"source"`
+	Result               ClaimResult        `bson:"result" json:"primary_insurance"`
+	ManufacturerPrograms []ProgramClaim     `bson:"manufacturer_programs" json:"manufacturer_programs"`
+	CostBreakdown        *CostBreakdown     `bson:"cost_breakdown,omitempty" json:"cost_breakdown,omitempty"`
+
+	// Request flow that wrote the record: the intake's correlation ID and the event being handled
+	CorrelationID string `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+	CausationID   string `bson:"causation_id,omitempty" json:"causation_id,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Exception types raised on prescriptions
+const (
+	ExceptionClaimRejected        = "claim_rejected"
+	ExceptionPaymentFailed        = "payment_failed"
+	ExceptionPaymentExpired       = "payment_expired"
+	ExceptionAddressInvalid       = "address_invalid"
+	ExceptionNoShippingRate       = "no_shipping_rate"
+	ExceptionStepFailed           = "step_failed"            // a worker gave up on the prescription; raised by the saga
+	ExceptionStepTimedOut         = "step_timed_out"         // a step missed its saga deadline
+	ExceptionDURRejected          = "dur_rejected"           // a pharmacist rejected the prescription's DUR findings
+	ExceptionVerificationRejected = "verification_rejected"  // a pharmacist rejected the prescription at clinical verification
+	ExceptionReturnedToPrescriber = "returned_to_prescriber" // a pharmacist sent the prescription back to the prescriber at clinical verification
+)
+
+// PrescriptionException records why a prescription left the automated flow for ops review
+type PrescriptionException struct {
+	Type     string    `bson:"type" json:"type"`
+	Reason   string    `bson:"reason" json:"reason"`
+	Codes    []string  `bson:"codes,omitempty" json:"codes,omitempty"`
+	Source   string    `bson:"source" json:"source"`
+	RaisedAt time.Time `bson:"raised_at" json:"raised_at"`
+}
@@ -0,0 +1,62 @@
+// Package models provides data models for the application
+package models
+
+import "time"
+
+// ConsentStatus is a patient's consent to be contacted on a channel
+type ConsentStatus string
+
+const (
+	ConsentUnknown ConsentStatus = ""        // never asked
+	ConsentGranted ConsentStatus = "granted" // the patient agreed to messages on the channel
+	ConsentRevoked ConsentStatus = "revoked" // the patient opted out, e.g. by replying STOP
+)
+
+// ConsentSource is where a consent change came from
+type ConsentSource string
+
+const (
+	ConsentSourcePatient  ConsentSource = "patient"     // the patient, through the app or enrollment
+	ConsentSourceOps      ConsentSource = "ops"         // recorded by the ops team, e.g. on a call
+	ConsentSourceKeyword  ConsentSource = "sms_keyword" // a STOP or START reply
+	ConsentSourceProvider ConsentSource = "provider"    // the SMS provider reported the number unsubscribed
+)
+
+// ChannelConsent is a patient's consent for one channel
+type ChannelConsent struct {
+	Status    ConsentStatus `bson:"status" json:"status"`
+	Source    ConsentSource `bson:"source,omitempty" json:"source,omitempty"`
+	UpdatedAt *time.Time    `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// QuietHours is the daily window, in the patient's timezone, in which they are not texted
+// Times are "15:04"; a window with Start after End runs overnight.
+type QuietHours struct {
+	Start string `bson:"start" json:"start"`
+	End   string `bson:"end" json:"end"`
+}
+
+// CommunicationPreferences is how a patient wants to be contacted
+type CommunicationPreferences struct {
+	Email ChannelConsent `bson:"email" json:"email"`
+	SMS   ChannelConsent `bson:"sms" json:"sms"`
+
+	// E.164 number texts go to; defaults to the patient's phone when consent is granted
+	SMSNumber string `bson:"sms_number,omitempty" json:"sms_number,omitempty"`
+
+	Timezone   string      `bson:"timezone,omitempty" json:"timezone,omitempty"` // IANA name, e.g. "America/Chicago"
+	QuietHours *QuietHours `bson:"quiet_hours,omitempty" json:"quiet_hours,omitempty"`
+
+	// Channel order per message type (notification template), overriding the defaults
+	Channels map[string][]NotificationChannel `bson:"channels,omitempty" json:"channels,omitempty"`
+
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// SMSOptOut is a document in the sms_opt_outs collection: a number that replied STOP
+// It applies whether or not the number belongs to a patient record, and is cleared by START.
+type SMSOptOut struct {
+	Phone      string        `bson:"_id" json:"phone"`
+	Source     ConsentSource `bson:"source" json:"source"`
+	OptedOutAt time.Time     `bson:"opted_out_at" json:"opted_out_at"`
+}
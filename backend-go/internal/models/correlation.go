@@ -0,0 +1,51 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"time"
+)
+
+// TimelineEntryKind is what a correlation timeline entry records
+type TimelineEntryKind string
+
+const (
+	TimelineEvent        TimelineEntryKind = "event"         // an event published on a Kafka topic
+	TimelineStatusChange TimelineEntryKind = "status_change" // a prescription moving to a new status
+	TimelineRecord       TimelineEntryKind = "record"        // a record created in a collection
+)
+
+// TraceRecord is a record written under a correlation ID, e.g. a payment or shipment
+type TraceRecord struct {
+	Collection     string    `json:"collection"`
+	ID             string    `json:"id"`
+	PrescriptionID string    `json:"prescription_id,omitempty"`
+	Status         string    `json:"status,omitempty"`
+	CorrelationID  string    `json:"correlation_id"`
+	CausationID    string    `json:"causation_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TimelineEntry is one step of a correlation trace
+// Depth counts the events between the step and the start of the flow, following causation IDs.
+type TimelineEntry struct {
+	At             time.Time         `json:"at"`
+	Kind           TimelineEntryKind `json:"kind"`
+	Topic          string            `json:"topic,omitempty"`
+	Collection     string            `json:"collection,omitempty"`
+	ID             string            `json:"id"`
+	PrescriptionID string            `json:"prescription_id,omitempty"`
+	Status         string            `json:"status,omitempty"`
+	Producer       string            `json:"producer,omitempty"`
+	CausationID    string            `json:"causation_id,omitempty"`
+	Depth          int               `json:"depth"`
+}
+
+// CorrelationTrace is everything that happened under one correlation ID: the events published, the
+// prescription status changes and the records written, merged into one timeline
+type CorrelationTrace struct {
+	CorrelationID string          `json:"correlation_id"`
+	Events        []EventLogEntry `json:"events"`
+	Records       []TraceRecord   `json:"records"`
+	Timeline      []TimelineEntry `json:"timeline"`
+	Prescriptions []string        `json:"prescription_ids"`
+}
@@ -0,0 +1,74 @@
+// Package models provides data models for the application
+package models
+
+import "strings"
+
+// ServiceColdChainStorage is the pharmacy service needed to stock refrigerated drugs
+const ServiceColdChainStorage = "cold_chain_storage"
+
+// Refrigerated storage range most refrigerated drugs are labelled for
+const (
+	RefrigeratedMinTempC = 2.0
+	RefrigeratedMaxTempC = 8.0
+)
+
+// Drug represents reference data for a medication, keyed by NDC
+type Drug struct {
+	NDC         string `bson:"ndc" json:"ndc"`
+	Name        string `bson:"name" json:"name"`
+	GenericName string `bson:"generic_name,omitempty" json:"generic_name,omitempty"`
+
+	// Active ingredients and therapeutic classes (lowercase), matched against the DUR reference tables
+	Ingredients []string `bson:"ingredients,omitempty" json:"ingredients,omitempty"`
+	Classes     []string `bson:"therapeutic_classes,omitempty" json:"therapeutic_classes,omitempty"`
+
+	// Sponsor (manufacturer hub) program the drug is dispensed under, if any
+	ProgramID string `bson:"program_id,omitempty" json:"program_id,omitempty"`
+
+	// Ingredient cost per dispensed unit, used to price claims
+	UnitCost float64 `bson:"unit_cost,omitempty" json:"unit_cost,omitempty"`
+
+	// Pharmacy services required to dispense the drug (e.g. "specialty_dispensing")
+	RequiredServices []string `bson:"required_services,omitempty" json:"required_services,omitempty"`
+
+	// Storage conditions; refrigerated drugs ship cold chain (range defaults to 2-8°C)
+	Refrigerated    bool    `bson:"refrigerated,omitempty" json:"refrigerated,omitempty"`
+	StorageMinTempC float64 `bson:"storage_min_temp_c,omitempty" json:"storage_min_temp_c,omitempty"`
+	StorageMaxTempC float64 `bson:"storage_max_temp_c,omitempty" json:"storage_max_temp_c,omitempty"`
+}
+
+// StorageRange returns the drug's storage temperature range and whether it must be refrigerated
+// Drugs that need cold chain storage at the pharmacy are refrigerated even without explicit storage data
+func (d *Drug) StorageRange() (minC, maxC float64, refrigerated bool) {
+	refrigerated = d.Refrigerated
+	for _, service := range d.RequiredServices {
+		if service == ServiceColdChainStorage {
+			refrigerated = true
+		}
+	}
+	if !refrigerated {
+		return 0, 0, false
+	}
+
+	minC, maxC = d.StorageMinTempC, d.StorageMaxTempC
+	if minC == 0 && maxC == 0 {
+		minC, maxC = RefrigeratedMinTempC, RefrigeratedMaxTempC
+	}
+	return minC, maxC, true
+}
+
+// DURKeys returns the ingredients and therapeutic classes drug utilization review matches the drug by
+func (d *Drug) DURKeys() []DURKey {
+	keys := make([]DURKey, 0, len(d.Ingredients)+len(d.Classes))
+	for _, ingredient := range d.Ingredients {
+		if ingredient = strings.ToLower(strings.TrimSpace(ingredient)); ingredient != "" {
+			keys = append(keys, DURKey{Type: DURKeyIngredient, Value: ingredient})
+		}
+	}
+	for _, class := range d.Classes {
+		if class = strings.ToLower(strings.TrimSpace(class)); class != "" {
+			keys = append(keys, DURKey{Type: DURKeyClass, Value: class})
+		}
+	}
+	return keys
+}
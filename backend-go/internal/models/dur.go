@@ -0,0 +1,160 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DURSeverity is how serious a drug utilization review finding is
+type DURSeverity string
+
+const (
+	DURSeverityContraindicated DURSeverity = "contraindicated" // must not be dispensed
+	DURSeverityMajor           DURSeverity = "major"           // may be life-threatening; needs prescriber intervention
+	DURSeverityModerate        DURSeverity = "moderate"        // may need a dose change or monitoring
+	DURSeverityMinor           DURSeverity = "minor"           // limited clinical effect
+)
+
+// Rank orders severities from minor (1) to contraindicated (4); unknown severities rank 0
+func (s DURSeverity) Rank() int {
+	switch s {
+	case DURSeverityContraindicated:
+		return 4
+	case DURSeverityMajor:
+		return 3
+	case DURSeverityModerate:
+		return 2
+	case DURSeverityMinor:
+		return 1
+	}
+	return 0
+}
+
+// DURFindingType is the kind of problem a drug utilization review found
+type DURFindingType string
+
+const (
+	DURDrugInteraction        DURFindingType = "drug_interaction"        // interacts with another active prescription
+	DURTherapeuticDuplication DURFindingType = "therapeutic_duplication" // same ingredient or class as another active prescription
+	DURAllergyConflict        DURFindingType = "allergy"                 // conflicts with a recorded allergy
+)
+
+// DURKeyType is what a reference table row matches a drug by
+type DURKeyType string
+
+const (
+	DURKeyIngredient DURKeyType = "ingredient"
+	DURKeyClass      DURKeyType = "class"
+)
+
+// DURKey matches drugs by one of their ingredients or therapeutic classes (lowercase, e.g. class "nsaid")
+type DURKey struct {
+	Type  DURKeyType `bson:"type" json:"type"`
+	Value string     `bson:"value" json:"value"`
+}
+
+// String returns the key as "type:value"
+func (k DURKey) String() string {
+	return string(k.Type) + ":" + k.Value
+}
+
+// DURInteraction is a row of the dur_interactions reference table: drugs matching A interact with drugs matching B
+type DURInteraction struct {
+	A           DURKey      `bson:"a" json:"a"`
+	B           DURKey      `bson:"b" json:"b"`
+	Severity    DURSeverity `bson:"severity" json:"severity"`
+	Description string      `bson:"description" json:"description"`
+}
+
+// DURDuplication is a row of the dur_duplications reference table: two active drugs in the class duplicate therapy
+// Two drugs sharing an ingredient always duplicate therapy, whether or not their class is listed.
+type DURDuplication struct {
+	Class       string      `bson:"class" json:"class"`
+	Severity    DURSeverity `bson:"severity" json:"severity"`
+	Description string      `bson:"description" json:"description"`
+}
+
+// DURAllergyRule is a row of the dur_allergy_rules reference table: patients allergic to Allergen react to drugs matching Key
+// A drug whose ingredient or class is the allergen itself always conflicts, whether or not a rule is listed.
+type DURAllergyRule struct {
+	Allergen    string      `bson:"allergen" json:"allergen"`
+	Key         DURKey      `bson:"key" json:"key"`
+	Severity    DURSeverity `bson:"severity" json:"severity"`
+	Description string      `bson:"description" json:"description"`
+}
+
+// DURFinding is one problem found by drug utilization review
+type DURFinding struct {
+	Type        DURFindingType `bson:"type" json:"type"`
+	Severity    DURSeverity    `bson:"severity" json:"severity"`
+	Description string         `bson:"description" json:"description"`
+	// Ingredient or class of the prescribed drug that matched, as "type:value"
+	Key string `bson:"key" json:"key"`
+	// Active prescription the drug interacts with or duplicates
+	OtherPrescriptionID string `bson:"other_prescription_id,omitempty" json:"other_prescription_id,omitempty"`
+	OtherDrug           string `bson:"other_drug,omitempty" json:"other_drug,omitempty"`
+	// Recorded allergy the drug conflicts with
+	Allergen string `bson:"allergen,omitempty" json:"allergen,omitempty"`
+}
+
+// DURStatus is the outcome of a drug utilization review
+type DURStatus string
+
+const (
+	DURStatusClear   DURStatus = "clear"   // nothing found
+	DURStatusFlagged DURStatus = "flagged" // findings sent to pharmacist review
+	DURStatusUnknown DURStatus = "unknown" // the drug has no ingredient or class data to check
+)
+
+// DURCheck is the drug utilization review recorded on a prescription during validation
+type DURCheck struct {
+	Status   DURStatus    `bson:"status" json:"status"`
+	Severity DURSeverity  `bson:"severity,omitempty" json:"severity,omitempty"` // most severe finding
+	Findings []DURFinding `bson:"findings,omitempty" json:"findings,omitempty"`
+	// Active prescriptions and allergies the drug was checked against
+	ActivePrescriptions int       `bson:"active_prescriptions" json:"active_prescriptions"`
+	Allergies           int       `bson:"allergies" json:"allergies"`
+	ReviewID            string    `bson:"review_id,omitempty" json:"review_id,omitempty"`
+	CheckedAt           time.Time `bson:"checked_at" json:"checked_at"`
+}
+
+// DURReviewStatus is the status of a pharmacist review of DUR findings
+type DURReviewStatus string
+
+const (
+	DURReviewPending  DURReviewStatus = "pending"
+	DURReviewApproved DURReviewStatus = "approved" // the pharmacist cleared the prescription to continue
+	DURReviewRejected DURReviewStatus = "rejected" // the prescription went to an exception for the prescriber
+)
+
+// DURReview is a document in the dur_reviews collection: a flagged prescription waiting for a pharmacist
+type DURReview struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PrescriptionID primitive.ObjectID `bson:"prescription_id" json:"prescription_id"`
+	PatientID      string             `bson:"patient_id,omitempty" json:"patient_id,omitempty"`
+	NDC            string             `bson:"ndc" json:"ndc"`
+	DrugName       string             `bson:"drug_name,omitempty" json:"drug_name,omitempty"`
+	Status         DURReviewStatus    `bson:"status" json:"status"`
+
+	// Most severe finding; the queue is worked most severe first
+	Severity     DURSeverity  `bson:"severity" json:"severity"`
+	SeverityRank int          `bson:"severity_rank" json:"-"`
+	Findings     []DURFinding `bson:"findings" json:"findings"`
+
+	// Pharmacist decision
+	DecidedBy string     `bson:"decided_by,omitempty" json:"decided_by,omitempty"`
+	DecidedAt *time.Time `bson:"decided_at,omitempty" json:"decided_at,omitempty"`
+	Note      string     `bson:"note,omitempty" json:"note,omitempty"`
+
+	// When the prescription was moved on after the decision; the scheduler resumes decisions without it
+	CompletedAt *time.Time `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+
+	// Request flow that wrote the record: the intake's correlation ID and the event being handled
+	CorrelationID string `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+	CausationID   string `bson:"causation_id,omitempty" json:"causation_id,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,28 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EventLogEntry is a document in the event_log collection: one event published on a Kafka topic
+// The envelope fields are stored as columns and the rest of the event as its payload.
+type EventLogEntry struct {
+	ID             primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	EventID        string                 `bson:"event_id" json:"event_id"`
+	Topic          string                 `bson:"topic" json:"topic"`
+	SchemaVersion  int                    `bson:"schema_version" json:"schema_version"`
+	CorrelationID  string                 `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+	CausationID    string                 `bson:"causation_id,omitempty" json:"causation_id,omitempty"`
+	PrescriptionID string                 `bson:"prescription_id,omitempty" json:"prescription_id,omitempty"`
+	Producer       string                 `bson:"producer,omitempty" json:"producer,omitempty"`
+	OccurredAt     time.Time              `bson:"occurred_at" json:"occurred_at"`
+	Payload        map[string]interface{} `bson:"payload,omitempty" json:"payload,omitempty"`
+
+	// Where the event was read from
+	Partition  int32     `bson:"partition" json:"partition"`
+	Offset     int64     `bson:"offset" json:"offset"`
+	RecordedAt time.Time `bson:"recorded_at" json:"recorded_at"`
+}
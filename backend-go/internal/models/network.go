@@ -0,0 +1,90 @@
+// Package models provides data models for the application
+package models
+
+import "time"
+
+// Network tiers for pharmacy-payer contracts
+const (
+	NetworkTierPreferred = "preferred"
+	NetworkTierStandard  = "standard"
+)
+
+// Formulary check statuses recorded on a prescription
+const (
+	FormularyStatusCovered    = "covered"
+	FormularyStatusNotCovered = "not_covered"
+	FormularyStatusUnknown    = "unknown"
+)
+
+// PlanKey identifies a pharmacy benefit plan. Empty PCN or GroupID on reference
+// data acts as a wildcard matching any value.
+type PlanKey struct {
+	BIN     string `bson:"bin" json:"bin"`
+	PCN     string `bson:"pcn" json:"pcn"`
+	GroupID string `bson:"group_id" json:"group_id"`
+}
+
+// NetworkContract records that a pharmacy is in network for a plan during a date range
+type NetworkContract struct {
+	PharmacyNCPDPID string     `bson:"pharmacy_ncpdp_id" json:"pharmacy_ncpdp_id"`
+	Plan            PlanKey    `bson:",inline" json:"plan"`
+	NetworkTier     string     `bson:"network_tier" json:"network_tier"`
+	EffectiveFrom   time.Time  `bson:"effective_from" json:"effective_from"`
+	EffectiveTo     *time.Time `bson:"effective_to,omitempty" json:"effective_to,omitempty"` // nil = open-ended
+}
+
+// FormularyEntry describes how a plan covers a drug
+type FormularyEntry struct {
+	Plan              PlanKey    `bson:",inline" json:"plan"`
+	NDC               string     `bson:"ndc" json:"ndc"`
+	Covered           bool       `bson:"covered" json:"covered"`
+	Tier              int        `bson:"tier" json:"tier"`
+	PARequired        bool       `bson:"pa_required" json:"pa_required"`
+	QuantityLimit     int        `bson:"quantity_limit,omitempty" json:"quantity_limit,omitempty"`           // max units per fill (0 = none)
+	QuantityLimitDays int        `bson:"quantity_limit_days,omitempty" json:"quantity_limit_days,omitempty"` // days supply the limit applies to
+	EffectiveFrom     time.Time  `bson:"effective_from" json:"effective_from"`
+	EffectiveTo       *time.Time `bson:"effective_to,omitempty" json:"effective_to,omitempty"`
+}
+
+// FormularyCheck is the formulary result recorded on a prescription during validation
+type FormularyCheck struct {
+	Status        string    `bson:"status" json:"status"`
+	Tier          int       `bson:"tier,omitempty" json:"tier,omitempty"`
+	PARequired    bool      `bson:"pa_required" json:"pa_required"`
+	QuantityLimit int       `bson:"quantity_limit,omitempty" json:"quantity_limit,omitempty"`
+	CheckedAt     time.Time `bson:"checked_at" json:"checked_at"`
+}
+
+// MatchesPlan reports whether reference data keyed by ref applies to the given plan
+func (ref PlanKey) MatchesPlan(plan PlanKey) bool {
+	if ref.BIN != plan.BIN {
+		return false
+	}
+	if ref.PCN != "" && ref.PCN != plan.PCN {
+		return false
+	}
+	if ref.GroupID != "" && ref.GroupID != plan.GroupID {
+		return false
+	}
+	return true
+}
+
+// Specificity ranks how precisely ref identifies a plan (higher is more specific)
+func (ref PlanKey) Specificity() int {
+	n := 0
+	if ref.PCN != "" {
+		n++
+	}
+	if ref.GroupID != "" {
+		n += 2
+	}
+	return n
+}
+
+// ActiveAt reports whether an effective date range includes t
+func ActiveAt(from time.Time, to *time.Time, t time.Time) bool {
+	if t.Before(from) {
+		return false
+	}
+	return to == nil || t.Before(*to)
+}
@@ -0,0 +1,105 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NotificationChannel is how a notification reaches the patient
+type NotificationChannel string
+
+const (
+	NotificationEmail NotificationChannel = "email"
+	NotificationSMS   NotificationChannel = "sms"
+)
+
+// NotificationStatus is the delivery status of a notification
+type NotificationStatus string
+
+const (
+	NotificationPending   NotificationStatus = "pending"   // recorded, first send not attempted yet
+	NotificationSent      NotificationStatus = "sent"      // accepted by the mail server or SMS provider
+	NotificationDelivered NotificationStatus = "delivered" // the SMS provider confirmed delivery
+	NotificationRetrying  NotificationStatus = "retrying"  // a send failed; retried at next_attempt_at
+	NotificationFailed    NotificationStatus = "failed"    // gave up on every channel
+)
+
+// NotificationRoute is a channel and address a notification can be sent to
+type NotificationRoute struct {
+	Channel   NotificationChannel `bson:"channel" json:"channel"`
+	Recipient string              `bson:"recipient" json:"recipient"`
+	// Texts wait until the patient's quiet hours are over
+	QuietHours *QuietHours `bson:"quiet_hours,omitempty" json:"quiet_hours,omitempty"`
+	Timezone   string      `bson:"timezone,omitempty" json:"timezone,omitempty"`
+}
+
+// DeliveryReceiptStatus is what a delivery receipt reports
+type DeliveryReceiptStatus string
+
+const (
+	ReceiptAccepted    DeliveryReceiptStatus = "accepted"    // the mail server or SMS provider took the message
+	ReceiptSendFailed  DeliveryReceiptStatus = "send_failed" // the send attempt failed
+	ReceiptSkipped     DeliveryReceiptStatus = "skipped"     // not sent on the channel, e.g. the patient opted out
+	ReceiptQueued      DeliveryReceiptStatus = "queued"      // provider callbacks from here on
+	ReceiptSent        DeliveryReceiptStatus = "sent"
+	ReceiptDelivered   DeliveryReceiptStatus = "delivered"
+	ReceiptUndelivered DeliveryReceiptStatus = "undelivered"
+	ReceiptFailed      DeliveryReceiptStatus = "failed"
+)
+
+// DeliveryReceipt is one step in delivering a notification: a send attempt or a provider callback
+type DeliveryReceipt struct {
+	Channel           NotificationChannel   `bson:"channel" json:"channel"`
+	Recipient         string                `bson:"recipient" json:"recipient"`
+	Status            DeliveryReceiptStatus `bson:"status" json:"status"`
+	ProviderMessageID string                `bson:"provider_message_id,omitempty" json:"provider_message_id,omitempty"`
+	Error             string                `bson:"error,omitempty" json:"error,omitempty"`
+	At                time.Time             `bson:"at" json:"at"`
+}
+
+// Notification is a document in the notifications collection
+// It holds the rendered message for every planned channel so retries and fallbacks send exactly what was
+// first rendered. Channel and Recipient are the route being tried; Fallbacks are tried in order after it.
+type Notification struct {
+	ID primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	// Event and template the notification was rendered for; unique, so a redelivered event is not sent twice
+	DedupeKey       string              `bson:"dedupe_key" json:"dedupe_key"`
+	EventID         string              `bson:"event_id" json:"event_id"`
+	Topic           string              `bson:"topic" json:"topic"`
+	Channel         NotificationChannel `bson:"channel" json:"channel"`
+	Template        string              `bson:"template" json:"template"`
+	TemplateVersion string              `bson:"template_version" json:"template_version"`
+
+	PrescriptionID string `bson:"prescription_id,omitempty" json:"prescription_id,omitempty"`
+	PatientID      string `bson:"patient_id,omitempty" json:"patient_id,omitempty"`
+	Recipient      string `bson:"recipient" json:"recipient"`
+	// Quiet hours of the current route, copied from it
+	QuietHours *QuietHours         `bson:"quiet_hours,omitempty" json:"quiet_hours,omitempty"`
+	Timezone   string              `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	Fallbacks  []NotificationRoute `bson:"fallbacks,omitempty" json:"fallbacks,omitempty"`
+
+	Subject  string `bson:"subject" json:"subject"`
+	TextBody string `bson:"text_body" json:"-"`
+	HTMLBody string `bson:"html_body" json:"-"`
+	SMSBody  string `bson:"sms_body,omitempty" json:"-"`
+
+	Status        NotificationStatus `bson:"status" json:"status"`
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	LastError     string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	NextAttemptAt *time.Time         `bson:"next_attempt_at,omitempty" json:"next_attempt_at,omitempty"`
+	SentAt        *time.Time         `bson:"sent_at,omitempty" json:"sent_at,omitempty"`
+	DeliveredAt   *time.Time         `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+
+	// Provider ID of the current text message, matched against delivery receipts
+	ProviderMessageID string            `bson:"provider_message_id,omitempty" json:"provider_message_id,omitempty"`
+	Receipts          []DeliveryReceipt `bson:"receipts,omitempty" json:"receipts,omitempty"`
+
+	// Request flow that wrote the record: the intake's correlation ID and the event being handled
+	CorrelationID string `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+	CausationID   string `bson:"causation_id,omitempty" json:"causation_id,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,51 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Patient is a document in the patients collection
+type Patient struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email       string             `bson:"email" json:"email"`
+	Phone       string             `bson:"phone,omitempty" json:"phone,omitempty"`
+	Name        PatientName        `bson:"name" json:"name"`
+	DateOfBirth string             `bson:"date_of_birth" json:"date_of_birth"`
+
+	EnrollmentStatus string     `bson:"enrollment_status,omitempty" json:"enrollment_status,omitempty"`
+	Enrolled         bool       `bson:"enrolled" json:"enrolled"`
+	EnrolledAt       *time.Time `bson:"enrolled_at,omitempty" json:"enrolled_at,omitempty"`
+
+	Communication *CommunicationPreferences `bson:"communication,omitempty" json:"communication,omitempty"`
+
+	// Drug allergies checked by drug utilization review
+	Allergies []Allergy `bson:"allergies,omitempty" json:"allergies,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Allergy is a recorded drug allergy
+// The allergen is an ingredient or therapeutic class (lowercase, e.g. "penicillins"), as used by the DUR reference tables.
+type Allergy struct {
+	Allergen   string    `bson:"allergen" json:"allergen"`
+	Reaction   string    `bson:"reaction,omitempty" json:"reaction,omitempty"`
+	RecordedBy string    `bson:"recorded_by,omitempty" json:"recorded_by,omitempty"`
+	RecordedAt time.Time `bson:"recorded_at" json:"recorded_at"`
+}
+
+// PatientName is a patient's legal name
+type PatientName struct {
+	First  string `bson:"first" json:"first"`
+	Middle string `bson:"middle,omitempty" json:"middle,omitempty"`
+	Last   string `bson:"last" json:"last"`
+}
+
+// Full returns the first and last name
+func (n PatientName) Full() string {
+	return strings.TrimSpace(n.First + " " + n.Last)
+}
@@ -0,0 +1,63 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PaymentStatus is the status of a copay payment
+type PaymentStatus string
+
+const (
+	PaymentPending           PaymentStatus = "pending"            // checkout link sent, awaiting the patient
+	PaymentPaid              PaymentStatus = "paid"               // payment captured
+	PaymentFailed            PaymentStatus = "failed"             // payment declined
+	PaymentExpired           PaymentStatus = "expired"            // checkout link expired unpaid
+	PaymentPartiallyRefunded PaymentStatus = "partially_refunded" // part of the captured amount refunded
+	PaymentRefunded          PaymentStatus = "refunded"           // payment fully refunded
+)
+
+// Payment is a document in the payments collection
+type Payment struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PrescriptionID string             `bson:"prescription_id" json:"prescription_id"`
+	PatientID      string             `bson:"patient_id,omitempty" json:"patient_id,omitempty"`
+	Status         PaymentStatus      `bson:"status" json:"status"`
+
+	// Amount charged and how it was arrived at
+	Amount         float64        `bson:"amount" json:"amount"`
+	AmountRefunded float64        `bson:"amount_refunded" json:"amount_refunded"`
+	Currency       string         `bson:"currency" json:"currency"`
+	Breakdown      *CostBreakdown `bson:"breakdown,omitempty" json:"breakdown,omitempty"`
+
+	// Provider checkout link
+	Provider                string    `bson:"provider" json:"provider"`
+	ProviderSessionID       string    `bson:"provider_session_id" json:"provider_session_id"`
+	ProviderPaymentIntentID string    `bson:"provider_payment_intent_id,omitempty" json:"provider_payment_intent_id,omitempty"`
+	PaymentLinkURL          string    `bson:"payment_link_url" json:"payment_link_url"`
+	LinkExpiresAt           time.Time `bson:"link_expires_at" json:"link_expires_at"`
+
+	PaidAt        *time.Time `bson:"paid_at,omitempty" json:"paid_at,omitempty"`
+	FailureReason string     `bson:"failure_reason,omitempty" json:"failure_reason,omitempty"`
+
+	// Request flow that wrote the record: the intake's correlation ID and the event being handled
+	CorrelationID string `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+	CausationID   string `bson:"causation_id,omitempty" json:"causation_id,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// PaymentReceipt is the receipt cached for a captured payment
+type PaymentReceipt struct {
+	PaymentID       string    `json:"payment_id"`
+	PrescriptionID  string    `json:"prescription_id"`
+	PatientID       string    `json:"patient_id,omitempty"`
+	Amount          float64   `json:"amount"`
+	Currency        string    `json:"currency"`
+	Provider        string    `json:"provider"`
+	PaymentIntentID string    `json:"payment_intent_id,omitempty"`
+	PaidAt          time.Time `json:"paid_at"`
+}
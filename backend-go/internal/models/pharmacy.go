@@ -0,0 +1,78 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Pharmacy represents a partner pharmacy document in MongoDB
+type Pharmacy struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	NCPDPID  string             `bson:"ncpdp_id" json:"ncpdp_id"`
+	Name     string             `bson:"name" json:"name"`
+	Address  PharmacyAddress    `bson:"address" json:"address"`
+	Location *GeoPoint          `bson:"location,omitempty" json:"location,omitempty"`
+	Phone    string             `bson:"phone,omitempty" json:"phone,omitempty"`
+	Email    string             `bson:"email,omitempty" json:"email,omitempty"`
+
+	// Opening hours keyed by lowercase weekday, e.g. "monday": "8:00 AM - 9:00 PM"
+	Hours map[string]string `bson:"hours,omitempty" json:"hours,omitempty"`
+	// IANA timezone used to evaluate opening hours (server local time if empty)
+	Timezone string `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	// Local "15:04" time after which refrigerated parcels wait for the next ship day
+	ColdChainCutoff string `bson:"cold_chain_cutoff,omitempty" json:"cold_chain_cutoff,omitempty"`
+
+	Capacity PharmacyCapacity `bson:"capacity" json:"capacity"`
+	Services []string         `bson:"services,omitempty" json:"services,omitempty"`
+
+	Active bool `bson:"active" json:"active"`
+
+	// SHA-256 hash of the pharmacy's API key for partner callbacks
+	APIKeyHash string `bson:"api_key_hash,omitempty" json:"-"`
+
+	// Populated by $geoNear when ranking pharmacies against a patient location
+	DistanceMeters *float64 `bson:"distance_meters,omitempty" json:"-"`
+}
+
+// PharmacyAddress represents a pharmacy's street address
+type PharmacyAddress struct {
+	Street string `bson:"street,omitempty" json:"street,omitempty"`
+	City   string `bson:"city,omitempty" json:"city,omitempty"`
+	State  string `bson:"state,omitempty" json:"state,omitempty"`
+	Zip    string `bson:"zip,omitempty" json:"zip,omitempty"`
+}
+
+// PharmacyCapacity contains the static daily capacity of a pharmacy
+type PharmacyCapacity struct {
+	MaxPrescriptionsPerDay int `bson:"max_prescriptions_per_day" json:"max_prescriptions_per_day"`
+	CurrentDailyCount      int `bson:"current_daily_count" json:"current_daily_count"`
+}
+
+// GeoPoint is a GeoJSON point ([longitude, latitude])
+type GeoPoint struct {
+	Type        string    `bson:"type" json:"type"`
+	Coordinates []float64 `bson:"coordinates" json:"coordinates"`
+}
+
+// PharmacyRecommendation is a ranked pharmacy stored on a prescription
+type PharmacyRecommendation struct {
+	Rank          int           `bson:"rank" json:"rank"`
+	PharmacyID    string        `bson:"pharmacy_id" json:"pharmacy_id"`
+	NCPDPID       string        `bson:"ncpdp_id" json:"ncpdp_id"`
+	Name          string        `bson:"name" json:"name"`
+	Score         float64       `bson:"score" json:"score"`
+	DistanceMiles *float64      `bson:"distance_miles,omitempty" json:"distance_miles,omitempty"`
+	Factors       []ScoreFactor `bson:"factors" json:"factors"`
+	GeneratedAt   time.Time     `bson:"generated_at" json:"generated_at"`
+}
+
+// ScoreFactor is one weighted component of a pharmacy score
+type ScoreFactor struct {
+	Name     string  `bson:"name" json:"name"`
+	Weight   float64 `bson:"weight" json:"weight"`
+	Score    float64 `bson:"score" json:"score"`       // 0.0 - 1.0
+	Weighted float64 `bson:"weighted" json:"weighted"` // weight * score
+	Detail   string  `bson:"detail,omitempty" json:"detail,omitempty"`
+}
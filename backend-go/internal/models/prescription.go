@@ -11,13 +11,25 @@ import (
 type PrescriptionStatus string
 
 const (
-	StatusReceived           PrescriptionStatus = "received"
-	StatusValidated          PrescriptionStatus = "validated"
-	StatusValidationFailed   PrescriptionStatus = "validation_failed"
-	StatusAwaitingEnrollment PrescriptionStatus = "awaiting_enrollment"
-	StatusAwaitingRouting    PrescriptionStatus = "awaiting_routing"
-	StatusRouted             PrescriptionStatus = "routed"
-	StatusFulfilled          PrescriptionStatus = "fulfilled"
+	StatusReceived             PrescriptionStatus = "received"
+	StatusValidated            PrescriptionStatus = "validated"
+	StatusValidationFailed     PrescriptionStatus = "validation_failed"
+	StatusAwaitingDURReview    PrescriptionStatus = "awaiting_dur_review"
+	StatusAwaitingEnrollment   PrescriptionStatus = "awaiting_enrollment"
+	StatusEnrolled             PrescriptionStatus = "enrolled"
+	StatusAwaitingRouting      PrescriptionStatus = "awaiting_routing"
+	StatusRouted               PrescriptionStatus = "routed"
+	StatusPharmacySelected     PrescriptionStatus = "pharmacy_selected"
+	StatusAwaitingAdjudication PrescriptionStatus = "awaiting_adjudication"
+	StatusAdjudicated          PrescriptionStatus = "adjudicated"
+	StatusException            PrescriptionStatus = "exception"
+	StatusAwaitingPayment      PrescriptionStatus = "awaiting_payment"
+	StatusPaymentWaived        PrescriptionStatus = "payment_waived"
+	StatusPaid                 PrescriptionStatus = "paid"
+	StatusPendingVerification  PrescriptionStatus = "pending_verification"
+	StatusVerified             PrescriptionStatus = "verified"
+	StatusShipped              PrescriptionStatus = "shipped"
+	StatusFulfilled            PrescriptionStatus = "fulfilled"
 )
 
 // Prescription represents a prescription document in MongoDB
@@ -29,6 +41,9 @@ type Prescription struct {
 	// Patient information
 	Patient PatientInfo `bson:"patient" json:"patient"`
 
+	// Enrolled patient record ID (patients collection), set once enrollment completes
+	PatientID string `bson:"patient_id,omitempty" json:"patient_id,omitempty"`
+
 	// Prescriber information
 	Prescriber PrescriberInfo `bson:"prescriber" json:"prescriber"`
 
@@ -38,12 +53,35 @@ type Prescription struct {
 	// Insurance information
 	Insurance InsuranceInfo `bson:"insurance,omitempty" json:"insurance,omitempty"`
 
+	// Pharmacy routing
+	PharmacyID              string                   `bson:"pharmacy_id,omitempty" json:"pharmacy_id,omitempty"`
+	PharmacyRecommendations []PharmacyRecommendation `bson:"pharmacy_recommendations,omitempty" json:"pharmacy_recommendations,omitempty"`
+	PharmacySelection       *PharmacySelection       `bson:"pharmacy_selection,omitempty" json:"pharmacy_selection,omitempty"`
+
+	// Latest insurance claim result, patient cost breakdown and the exception raised if the flow stopped
+	Adjudication  *ClaimResult           `bson:"adjudication,omitempty" json:"adjudication,omitempty"`
+	CostBreakdown *CostBreakdown         `bson:"cost_breakdown,omitempty" json:"cost_breakdown,omitempty"`
+	Exception     *PrescriptionException `bson:"exception,omitempty" json:"exception,omitempty"`
+
 	// Validation errors (if any)
 	ValidationErrors []string `bson:"validation_errors,omitempty" json:"validation_errors,omitempty"`
 
+	// Non-blocking validation warnings (e.g. non-formulary drug) and the formulary result
+	ValidationFlags []string        `bson:"validation_flags,omitempty" json:"validation_flags,omitempty"`
+	FormularyCheck  *FormularyCheck `bson:"formulary_check,omitempty" json:"formulary_check,omitempty"`
+
+	// Drug utilization review against the patient's active prescriptions and allergies
+	DURCheck *DURCheck `bson:"dur_check,omitempty" json:"dur_check,omitempty"`
+
 	// Date written (from prescription)
 	DateWritten string `bson:"date_written,omitempty" json:"date_written,omitempty"`
 
+	// Correlation ID of the intake request; later stages started by webhooks, ops or the scheduler reuse it
+	CorrelationID string `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+
+	// Every status the prescription moved through, oldest first
+	StatusHistory []StatusChange `bson:"status_history,omitempty" json:"status_history,omitempty"`
+
 	// Metadata
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
@@ -52,6 +90,16 @@ type Prescription struct {
 	OriginalPayload string `bson:"original_payload,omitempty" json:"original_payload,omitempty"`
 }
 
+// StatusChange records a prescription moving to a status
+// CausationID is the event whose handler made the change, empty for changes made by an API call or the scheduler.
+type StatusChange struct {
+	Status        PrescriptionStatus `bson:"status" json:"status"`
+	CorrelationID string             `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+	CausationID   string             `bson:"causation_id,omitempty" json:"causation_id,omitempty"`
+	Producer      string             `bson:"producer,omitempty" json:"producer,omitempty"`
+	At            time.Time          `bson:"at" json:"at"`
+}
+
 // PatientInfo contains patient demographic information
 type PatientInfo struct {
 	ID          string  `bson:"id,omitempty" json:"id,omitempty"`
@@ -79,6 +127,7 @@ type MedicationInfo struct {
 	Name       string `bson:"name" json:"name"`
 	Quantity   int    `bson:"quantity" json:"quantity"`
 	Refills    int    `bson:"refills,omitempty" json:"refills,omitempty"`
+	DaysSupply int    `bson:"days_supply,omitempty" json:"days_supply,omitempty"`
 	Dosage     string `bson:"dosage,omitempty" json:"dosage,omitempty"`
 	Directions string `bson:"directions,omitempty" json:"directions,omitempty"`
 }
@@ -92,12 +141,20 @@ type InsuranceInfo struct {
 	PlanName string `bson:"plan_name,omitempty" json:"plan_name,omitempty"`
 }
 
+// Plan returns the benefit plan key (BIN/PCN/group) for the insurance
+func (i InsuranceInfo) Plan() PlanKey {
+	return PlanKey{BIN: i.BIN, PCN: i.PCN, GroupID: i.GroupID}
+}
+
 // Address represents a physical address
 type Address struct {
 	Street  string `bson:"street,omitempty" json:"street,omitempty"`
 	City    string `bson:"city,omitempty" json:"city,omitempty"`
 	State   string `bson:"state,omitempty" json:"state,omitempty"`
 	ZipCode string `bson:"zip_code,omitempty" json:"zip_code,omitempty"`
+
+	// Geocoded location of the address (optional)
+	Location *GeoPoint `bson:"location,omitempty" json:"location,omitempty"`
 }
 
 // IntakeRequest represents the request body for prescription intake
@@ -0,0 +1,64 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PriorAuthStatus is the lifecycle status of a prior authorization
+type PriorAuthStatus string
+
+const (
+	PriorAuthRequested    PriorAuthStatus = "requested"               // opened, not yet sent to the prescriber
+	PriorAuthSubmitted    PriorAuthStatus = "submitted_to_prescriber" // prescriber asked to complete the PA request
+	PriorAuthPendingPayer PriorAuthStatus = "pending_payer"           // PA request with the payer for review
+	PriorAuthApproved     PriorAuthStatus = "approved"                // approved until ExpiresAt
+	PriorAuthDenied       PriorAuthStatus = "denied"                  // denied; may be appealed
+	PriorAuthAppealed     PriorAuthStatus = "appealed"                // denial appealed, awaiting payer decision
+	PriorAuthCancelled    PriorAuthStatus = "cancelled"               // no longer needed
+)
+
+// Prior authorization sources
+const (
+	PriorAuthSourceOps         = "ops"          // opened by ops from the dashboard
+	PriorAuthSourceClaimReject = "claim_reject" // opened automatically on a reject code 75 claim
+)
+
+// PriorAuthorization is a document in the prior_authorizations collection
+type PriorAuthorization struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PrescriptionID primitive.ObjectID `bson:"prescription_id" json:"prescription_id"`
+	PatientID      string             `bson:"patient_id,omitempty" json:"patient_id,omitempty"`
+	PrescriberNPI  string             `bson:"prescriber_npi,omitempty" json:"prescriber_npi,omitempty"`
+	Plan           PlanKey            `bson:"plan" json:"plan"`
+	MemberID       string             `bson:"member_id,omitempty" json:"member_id,omitempty"`
+	NDC            string             `bson:"ndc" json:"ndc"`
+	Status         PriorAuthStatus    `bson:"status" json:"status"`
+	Source         string             `bson:"source" json:"source"` // ops or claim_reject
+
+	// Payer decision
+	PayerReference string     `bson:"payer_reference,omitempty" json:"payer_reference,omitempty"`
+	ExpiresAt      *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	DenialReason   string     `bson:"denial_reason,omitempty" json:"denial_reason,omitempty"`
+	AppealDeadline *time.Time `bson:"appeal_deadline,omitempty" json:"appeal_deadline,omitempty"`
+
+	History []PriorAuthTransition `bson:"history" json:"history"`
+
+	// Request flow that wrote the record: the intake's correlation ID and the event being handled
+	CorrelationID string `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+	CausationID   string `bson:"causation_id,omitempty" json:"causation_id,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// PriorAuthTransition records one status change of a prior authorization
+type PriorAuthTransition struct {
+	From PriorAuthStatus `bson:"from,omitempty" json:"from,omitempty"`
+	To   PriorAuthStatus `bson:"to" json:"to"`
+	By   string          `bson:"by" json:"by"`
+	Note string          `bson:"note,omitempty" json:"note,omitempty"`
+	At   time.Time       `bson:"at" json:"at"`
+}
@@ -0,0 +1,60 @@
+// Package models provides data models for the application
+package models
+
+// Insurance types used for manufacturer program eligibility
+const (
+	InsuranceTypeCommercial = "commercial"
+	InsuranceTypeMedicare   = "medicare"
+	InsuranceTypeMedicaid   = "medicaid"
+)
+
+// Manufacturer program claim statuses
+const (
+	ProgramClaimApproved = "approved"
+	ProgramClaimDenied   = "denied"
+)
+
+// ManufacturerProgram is a manufacturer copay assistance program from the manufacturer_programs catalog
+type ManufacturerProgram struct {
+	ProgramID    string             `bson:"program_id" json:"program_id"`
+	ProgramName  string             `bson:"program_name" json:"program_name"`
+	Manufacturer string             `bson:"manufacturer,omitempty" json:"manufacturer,omitempty"`
+	NDCs         []string           `bson:"ndcs" json:"ndcs,omitempty"`
+	Credentials  ProgramCredentials `bson:"credentials" json:"credentials"`
+	Eligibility  ProgramEligibility `bson:"eligibility" json:"eligibility"`
+	Active       bool               `bson:"active" json:"active"`
+}
+
+// ProgramCredentials are the BIN/PCN/group the pharmacy bills the secondary claim to
+type ProgramCredentials struct {
+	BIN     string `bson:"bin" json:"bin"`
+	PCN     string `bson:"pcn" json:"pcn"`
+	GroupID string `bson:"group_id" json:"group_id"`
+}
+
+// ProgramEligibility holds the program's eligibility and benefit rules
+type ProgramEligibility struct {
+	CommercialOnly   bool    `bson:"commercial_only" json:"commercial_only"`
+	TargetCopay      float64 `bson:"target_copay" json:"target_copay"`                                 // copay the patient pays after the program
+	MaxAnnualBenefit float64 `bson:"max_annual_benefit,omitempty" json:"max_annual_benefit,omitempty"` // per member per calendar year (0 = unlimited)
+}
+
+// ProgramClaim is the result of a secondary claim to a manufacturer program
+type ProgramClaim struct {
+	ProgramID      string  `bson:"program_id" json:"program_id"`
+	ProgramName    string  `bson:"program_name" json:"program_name"`
+	ClaimID        string  `bson:"claim_id,omitempty" json:"claim_id,omitempty"`
+	Status         string  `bson:"status" json:"status"`
+	Message        string  `bson:"message,omitempty" json:"message,omitempty"`
+	DiscountAmount float64 `bson:"discount_amount" json:"discount_amount"`
+	ReducedCopay   float64 `bson:"reduced_copay" json:"reduced_copay"`
+}
+
+// CostBreakdown is the full patient cost after primary and secondary claims
+type CostBreakdown struct {
+	TotalDrugCost        float64 `bson:"total_drug_cost" json:"total_drug_cost"`
+	InsuranceCovered     float64 `bson:"insurance_covered" json:"insurance_covered"`
+	InitialCopay         float64 `bson:"initial_copay" json:"initial_copay"`
+	ManufacturerDiscount float64 `bson:"manufacturer_discount" json:"manufacturer_discount"`
+	FinalPatientCopay    float64 `bson:"final_patient_copay" json:"final_patient_copay"`
+}
@@ -0,0 +1,68 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefundStatus is the status of a refund of a captured copay
+type RefundStatus string
+
+const (
+	RefundRequested RefundStatus = "requested" // awaiting approval by an ops manager
+	RefundRejected  RefundStatus = "rejected"  // declined by the approver
+	RefundApproved  RefundStatus = "approved"  // approved, being issued with the payment provider
+	RefundSucceeded RefundStatus = "succeeded" // issued by the payment provider
+	RefundFailed    RefundStatus = "failed"    // the payment provider refused the refund
+)
+
+// RefundReason is why money is being returned to the patient
+type RefundReason string
+
+const (
+	RefundReasonCancelled     RefundReason = "prescription_cancelled"
+	RefundReasonReadjudicated RefundReason = "readjudicated" // re-adjudicated to a lower copay
+	RefundReasonUndeliverable RefundReason = "undeliverable"
+	RefundReasonOther         RefundReason = "other"
+)
+
+// Refund is a document in the refunds collection
+type Refund struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PaymentID      primitive.ObjectID `bson:"payment_id" json:"payment_id"`
+	PrescriptionID string             `bson:"prescription_id" json:"prescription_id"`
+	PatientID      string             `bson:"patient_id,omitempty" json:"patient_id,omitempty"`
+	Amount         float64            `bson:"amount" json:"amount"`
+	Currency       string             `bson:"currency" json:"currency"`
+	Reason         RefundReason       `bson:"reason" json:"reason"`
+	Status         RefundStatus       `bson:"status" json:"status"`
+
+	RequestedBy string `bson:"requested_by" json:"requested_by"`
+	ApprovedBy  string `bson:"approved_by,omitempty" json:"approved_by,omitempty"`
+
+	// Provider refund, once issued
+	Provider         string `bson:"provider" json:"provider"`
+	ProviderRefundID string `bson:"provider_refund_id,omitempty" json:"provider_refund_id,omitempty"`
+	FailureReason    string `bson:"failure_reason,omitempty" json:"failure_reason,omitempty"`
+
+	History     []RefundTransition `bson:"history" json:"history"`
+	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+
+	// Request flow that wrote the record: the intake's correlation ID and the event being handled
+	CorrelationID string `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+	CausationID   string `bson:"causation_id,omitempty" json:"causation_id,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// RefundTransition is one entry in a refund's audit trail
+type RefundTransition struct {
+	From RefundStatus `bson:"from,omitempty" json:"from,omitempty"`
+	To   RefundStatus `bson:"to" json:"to"`
+	By   string       `bson:"by" json:"by"`
+	Note string       `bson:"note,omitempty" json:"note,omitempty"`
+	At   time.Time    `bson:"at" json:"at"`
+}
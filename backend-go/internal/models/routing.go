@@ -0,0 +1,40 @@
+// Package models provides data models for the application
+package models
+
+import "time"
+
+// RoutingMode controls whether routing auto-selects a pharmacy or waits for ops
+type RoutingMode string
+
+const (
+	RoutingModeAuto   RoutingMode = "auto"
+	RoutingModeManual RoutingMode = "manual"
+)
+
+// Routing policy scopes, in order of precedence
+const (
+	RoutingScopeProgram = "program"
+	RoutingScopeDrug    = "drug"
+)
+
+// RoutingPolicy configures pharmacy selection for a drug or sponsor program
+type RoutingPolicy struct {
+	Scope string      `bson:"scope" json:"scope"` // "program" or "drug"
+	Key   string      `bson:"key" json:"key"`     // sponsor program ID or NDC
+	Mode  RoutingMode `bson:"mode" json:"mode"`
+	// Selections outside the top N recommendations require an override reason
+	TopN int `bson:"top_n,omitempty" json:"top_n,omitempty"`
+}
+
+// PharmacySelection records how a pharmacy was chosen for a prescription
+type PharmacySelection struct {
+	PharmacyID     string      `bson:"pharmacy_id" json:"pharmacy_id"`
+	NCPDPID        string      `bson:"ncpdp_id" json:"ncpdp_id"`
+	Name           string      `bson:"name" json:"name"`
+	Mode           RoutingMode `bson:"mode" json:"mode"`
+	Rank           int         `bson:"rank,omitempty" json:"rank,omitempty"` // 0 if not among the recommendations
+	Score          float64     `bson:"score,omitempty" json:"score,omitempty"`
+	SelectedBy     string      `bson:"selected_by" json:"selected_by"`
+	OverrideReason string      `bson:"override_reason,omitempty" json:"override_reason,omitempty"`
+	SelectedAt     time.Time   `bson:"selected_at" json:"selected_at"`
+}
@@ -0,0 +1,124 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SagaStep is a step of a prescription's fulfillment, performed by one worker
+type SagaStep string
+
+const (
+	SagaStepValidation   SagaStep = "validation"
+	SagaStepDURReview    SagaStep = "dur_review" // a pharmacist deciding drug utilization review findings
+	SagaStepEnrollment   SagaStep = "enrollment"
+	SagaStepRouting      SagaStep = "routing"
+	SagaStepAdjudication SagaStep = "adjudication"
+	SagaStepPayment      SagaStep = "payment"
+	SagaStepVerification SagaStep = "verification" // a pharmacist's clinical verification before dispensing
+	SagaStepShipping     SagaStep = "shipping"
+	SagaStepDelivery     SagaStep = "delivery"
+)
+
+// SagaStatus is the status of a prescription's fulfillment saga
+type SagaStatus string
+
+const (
+	SagaRunning            SagaStatus = "running"
+	SagaCompleted          SagaStatus = "completed"           // the prescription was delivered
+	SagaCompensating       SagaStatus = "compensating"        // a step failed or timed out; undoing the earlier steps
+	SagaCompensated        SagaStatus = "compensated"         // every compensation done or not needed
+	SagaCompensationFailed SagaStatus = "compensation_failed" // a compensation gave up; ops must undo it by hand
+)
+
+// SagaStepStatus is the status of one step of a saga
+type SagaStepStatus string
+
+const (
+	SagaStepRunning   SagaStepStatus = "running"
+	SagaStepCompleted SagaStepStatus = "completed"
+	SagaStepFailed    SagaStepStatus = "failed"    // its worker failed and the message went to the DLQ
+	SagaStepTimedOut  SagaStepStatus = "timed_out" // it did not complete before its deadline
+)
+
+// SagaCompensationAction undoes what an earlier step did
+type SagaCompensationAction string
+
+const (
+	CompensationVoidLabel       SagaCompensationAction = "void_label"        // void a label not yet picked up
+	CompensationRefund          SagaCompensationAction = "refund"            // refund a captured copay
+	CompensationVoidPaymentLink SagaCompensationAction = "void_payment_link" // expire an unpaid checkout link
+	CompensationReleaseCapacity SagaCompensationAction = "release_capacity"  // release the capacity reserved at the pharmacy
+)
+
+// SagaCompensationStatus is the status of a compensating action
+type SagaCompensationStatus string
+
+const (
+	CompensationPending SagaCompensationStatus = "pending" // not run yet, or failed and due a retry
+	CompensationDone    SagaCompensationStatus = "done"
+	CompensationSkipped SagaCompensationStatus = "skipped" // nothing left to undo
+	CompensationFailed  SagaCompensationStatus = "failed"  // gave up after the maximum attempts
+)
+
+// Saga is a document in the sagas collection, one per prescription
+// It follows the prescription through the workers and holds what compensations need to undo its steps.
+type Saga struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PrescriptionID string             `bson:"prescription_id" json:"prescription_id"`
+	Status         SagaStatus         `bson:"status" json:"status"`
+	CurrentStep    SagaStep           `bson:"current_step" json:"current_step"`
+	Deadline       *time.Time         `bson:"deadline,omitempty" json:"deadline,omitempty"` // of the current step, while running
+	Steps          []SagaStepState    `bson:"steps" json:"steps"`
+
+	// Resources held by completed steps
+	PharmacyID string `bson:"pharmacy_id,omitempty" json:"pharmacy_id,omitempty"`
+	PaymentID  string `bson:"payment_id,omitempty" json:"payment_id,omitempty"`
+	ShipmentID string `bson:"shipment_id,omitempty" json:"shipment_id,omitempty"`
+
+	Failure            *SagaFailure       `bson:"failure,omitempty" json:"failure,omitempty"`
+	Compensations      []SagaCompensation `bson:"compensations,omitempty" json:"compensations,omitempty"`
+	NextCompensationAt *time.Time         `bson:"next_compensation_at,omitempty" json:"next_compensation_at,omitempty"`
+
+	// Version guards against concurrent updates from several workers
+	Version int64 `bson:"version" json:"-"`
+
+	CorrelationID string    `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+	CreatedAt     time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// SagaStepState records one step of a saga
+type SagaStepState struct {
+	Step        SagaStep       `bson:"step" json:"step"`
+	Status      SagaStepStatus `bson:"status" json:"status"`
+	StartedAt   time.Time      `bson:"started_at" json:"started_at"`
+	Deadline    *time.Time     `bson:"deadline,omitempty" json:"deadline,omitempty"`
+	CompletedAt *time.Time     `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	// Events that started and completed the step
+	StartedBy   string `bson:"started_by,omitempty" json:"started_by,omitempty"`
+	CompletedBy string `bson:"completed_by,omitempty" json:"completed_by,omitempty"`
+	// Latest prescription exception raised during the step
+	Exception *PrescriptionException `bson:"exception,omitempty" json:"exception,omitempty"`
+	Error     string                 `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// SagaFailure records the step that failed a saga
+type SagaFailure struct {
+	Step     SagaStep  `bson:"step" json:"step"`
+	Reason   string    `bson:"reason" json:"reason"`
+	TimedOut bool      `bson:"timed_out" json:"timed_out"`
+	At       time.Time `bson:"at" json:"at"`
+}
+
+// SagaCompensation is one compensating action of a failed saga
+type SagaCompensation struct {
+	Action      SagaCompensationAction `bson:"action" json:"action"`
+	Status      SagaCompensationStatus `bson:"status" json:"status"`
+	Attempts    int                    `bson:"attempts" json:"attempts"`
+	Detail      string                 `bson:"detail,omitempty" json:"detail,omitempty"` // what was undone, or why nothing was
+	Error       string                 `bson:"error,omitempty" json:"error,omitempty"`
+	CompletedAt *time.Time             `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
@@ -0,0 +1,144 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ShipmentStatus is the status of a prescription shipment
+type ShipmentStatus string
+
+const (
+	ShipmentLabelCreated   ShipmentStatus = "label_created"
+	ShipmentInTransit      ShipmentStatus = "in_transit"
+	ShipmentOutForDelivery ShipmentStatus = "out_for_delivery"
+	ShipmentDelivered      ShipmentStatus = "delivered"
+	ShipmentException      ShipmentStatus = "exception" // delayed, damaged, lost or a failed delivery attempt
+	ShipmentReturned       ShipmentStatus = "returned"
+	ShipmentVoided         ShipmentStatus = "voided" // label cancelled before pickup
+)
+
+// TrackingSource is how a tracking update reached us
+type TrackingSource string
+
+const (
+	TrackingSourceWebhook TrackingSource = "webhook" // pushed by the carrier integration
+	TrackingSourcePoll    TrackingSource = "poll"    // pulled by the scheduler
+)
+
+// Shipment is a document in the shipments collection
+type Shipment struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PrescriptionID primitive.ObjectID `bson:"prescription_id" json:"prescription_id"`
+	PatientID      string             `bson:"patient_id,omitempty" json:"patient_id,omitempty"`
+	PharmacyID     string             `bson:"pharmacy_id,omitempty" json:"pharmacy_id,omitempty"`
+	Status         ShipmentStatus     `bson:"status" json:"status"`
+
+	// Carrier integration and the service level bought
+	Provider      string  `bson:"provider" json:"provider"`
+	Carrier       string  `bson:"carrier" json:"carrier"`
+	ServiceLevel  string  `bson:"service_level" json:"service_level"`
+	ServiceName   string  `bson:"service_name,omitempty" json:"service_name,omitempty"`
+	Cost          float64 `bson:"cost" json:"cost"`
+	Currency      string  `bson:"currency" json:"currency"`
+	EstimatedDays int     `bson:"estimated_days,omitempty" json:"estimated_days,omitempty"`
+
+	// Purchased label
+	RateID         string `bson:"rate_id" json:"rate_id"`
+	LabelID        string `bson:"label_id" json:"label_id"` // carrier label object reference, used to void
+	TrackingNumber string `bson:"tracking_number" json:"tracking_number"`
+	TrackingURL    string `bson:"tracking_url,omitempty" json:"tracking_url,omitempty"`
+	LabelURL       string `bson:"label_url" json:"label_url"`
+
+	Parcel    ShipmentParcel `bson:"parcel" json:"parcel"`
+	ShipTo    Address        `bson:"ship_to" json:"ship_to"`
+	DeliverBy time.Time      `bson:"deliver_by" json:"deliver_by"`
+
+	// Cold chain handling for refrigerated drugs
+	ColdChain *ShipmentColdChain `bson:"cold_chain,omitempty" json:"cold_chain,omitempty"`
+
+	// Replacement workflow: a shipment compromised in transit is replaced by a new one
+	ReplacesShipmentID    *primitive.ObjectID `bson:"replaces_shipment_id,omitempty" json:"replaces_shipment_id,omitempty"`
+	ReplacementShipmentID *primitive.ObjectID `bson:"replacement_shipment_id,omitempty" json:"replacement_shipment_id,omitempty"`
+
+	// Packing slip and fallback label PDFs in object storage
+	Documents []ShipmentDocument `bson:"documents,omitempty" json:"documents,omitempty"`
+
+	// Carrier tracking history, oldest first
+	TrackingEvents []TrackingEvent `bson:"tracking_events,omitempty" json:"tracking_events,omitempty"`
+	LastTrackedAt  *time.Time      `bson:"last_tracked_at,omitempty" json:"last_tracked_at,omitempty"` // last webhook or poll
+	DeliveredAt    *time.Time      `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+	// Set once shipment.delivered is published, so a retried delivery update publishes it again only until then
+	DeliveryPublishedAt *time.Time `bson:"delivery_published_at,omitempty" json:"delivery_published_at,omitempty"`
+
+	// Request flow that wrote the record: the intake's correlation ID and the event being handled
+	CorrelationID string `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+	CausationID   string `bson:"causation_id,omitempty" json:"causation_id,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// ShipmentParcel is the parcel shipped, in inches and ounces
+type ShipmentParcel struct {
+	LengthIn float64 `bson:"length_in" json:"length_in"`
+	WidthIn  float64 `bson:"width_in" json:"width_in"`
+	HeightIn float64 `bson:"height_in" json:"height_in"`
+	WeightOz float64 `bson:"weight_oz" json:"weight_oz"`
+}
+
+// ShipmentDocument is a rendered document stored in object storage; links are signed on request
+type ShipmentDocument struct {
+	Type        string    `bson:"type" json:"type"` // packing_slip, label_4x6
+	Bucket      string    `bson:"bucket" json:"bucket"`
+	ObjectName  string    `bson:"object_name" json:"object_name"`
+	ContentType string    `bson:"content_type" json:"content_type"`
+	Size        int64     `bson:"size" json:"size"`
+	CreatedAt   time.Time `bson:"created_at" json:"created_at"`
+}
+
+// TrackingEvent is one carrier tracking update for a shipment
+type TrackingEvent struct {
+	Status       ShipmentStatus `bson:"status" json:"status"`
+	CarrierState string         `bson:"carrier_state" json:"carrier_state"` // carrier-neutral tracking state
+	Details      string         `bson:"details,omitempty" json:"details,omitempty"`
+	Location     string         `bson:"location,omitempty" json:"location,omitempty"`
+	OccurredAt   time.Time      `bson:"occurred_at" json:"occurred_at"`
+	Source       TrackingSource `bson:"source" json:"source"`
+	ReceivedAt   time.Time      `bson:"received_at" json:"received_at"`
+}
+
+// ShipmentColdChain is the cold chain requirement a refrigerated shipment carries
+type ShipmentColdChain struct {
+	MinTempC  float64   `bson:"min_temp_c" json:"min_temp_c"`
+	MaxTempC  float64   `bson:"max_temp_c" json:"max_temp_c"`
+	Packaging string    `bson:"packaging" json:"packaging"`
+	ShipOn    time.Time `bson:"ship_on" json:"ship_on"` // after the pharmacy cut-off and weekend rules
+
+	// Set by the first temperature excursion report; the shipment is then replaced
+	ExcursionFlagged bool                   `bson:"excursion_flagged" json:"excursion_flagged"`
+	Excursions       []TemperatureExcursion `bson:"excursions,omitempty" json:"excursions,omitempty"`
+}
+
+// ExcursionSource is who reported a temperature excursion
+type ExcursionSource string
+
+const (
+	ExcursionSourceDataLogger ExcursionSource = "data_logger" // logger packed in the shipper
+	ExcursionSourceCarrier    ExcursionSource = "carrier"
+	ExcursionSourcePharmacy   ExcursionSource = "pharmacy"
+	ExcursionSourcePatient    ExcursionSource = "patient" // e.g. gel packs thawed on arrival
+)
+
+// TemperatureExcursion is a report that a refrigerated shipment left its storage range
+type TemperatureExcursion struct {
+	Source          ExcursionSource `bson:"source" json:"source"`
+	TemperatureC    *float64        `bson:"temperature_c,omitempty" json:"temperature_c,omitempty"` // nil when only an indicator tripped
+	DurationMinutes int             `bson:"duration_minutes,omitempty" json:"duration_minutes,omitempty"`
+	ObservedAt      time.Time       `bson:"observed_at" json:"observed_at"`
+	Note            string          `bson:"note,omitempty" json:"note,omitempty"`
+	ReportedBy      string          `bson:"reported_by" json:"reported_by"`
+	ReportedAt      time.Time       `bson:"reported_at" json:"reported_at"`
+}
@@ -0,0 +1,58 @@
+// Package models provides data models for the application
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// VerificationStatus is the status of a pharmacist's clinical verification of a prescription
+type VerificationStatus string
+
+const (
+	VerificationPending              VerificationStatus = "pending_verification"
+	VerificationApproved             VerificationStatus = "approved"               // cleared for dispensing; the prescription ships
+	VerificationRejected             VerificationStatus = "rejected"               // must not be dispensed
+	VerificationReturnedToPrescriber VerificationStatus = "returned_to_prescriber" // the prescriber must clarify or change it
+)
+
+// Verification is a document in the verifications collection: a paid prescription waiting for a
+// pharmacist's clinical check before it is dispensed
+type Verification struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PrescriptionID primitive.ObjectID `bson:"prescription_id" json:"prescription_id"`
+	PatientID      string             `bson:"patient_id,omitempty" json:"patient_id,omitempty"`
+	PaymentID      string             `bson:"payment_id,omitempty" json:"payment_id,omitempty"`
+	NDC            string             `bson:"ndc" json:"ndc"`
+	DrugName       string             `bson:"drug_name,omitempty" json:"drug_name,omitempty"`
+	Status         VerificationStatus `bson:"status" json:"status"`
+
+	// Pharmacist decision, with the license they verified under
+	DecidedBy     string     `bson:"decided_by,omitempty" json:"decided_by,omitempty"`
+	DecidedByName string     `bson:"decided_by_name,omitempty" json:"decided_by_name,omitempty"`
+	LicenseNumber string     `bson:"license_number,omitempty" json:"license_number,omitempty"`
+	DecidedAt     *time.Time `bson:"decided_at,omitempty" json:"decided_at,omitempty"`
+	Reason        string     `bson:"reason,omitempty" json:"reason,omitempty"` // required unless approved
+
+	// When the prescription was moved on after the decision; the scheduler resumes decisions without it
+	CompletedAt *time.Time `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+
+	// Request flow that wrote the record: the intake's correlation ID and the event being handled
+	CorrelationID string `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+	CausationID   string `bson:"causation_id,omitempty" json:"causation_id,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// VerificationDetail is what a pharmacist reviews: the verification with the prescription's sig,
+// DUR findings and the payload it was received as
+type VerificationDetail struct {
+	Verification
+	Medication      MedicationInfo `json:"medication"` // the sig is in directions
+	ValidationFlags []string       `json:"validation_flags,omitempty"`
+	DURCheck        *DURCheck      `json:"dur_check,omitempty"`
+	DURReview       *DURReview     `json:"dur_review,omitempty"`
+	OriginalPayload string         `json:"original_payload,omitempty"`
+}
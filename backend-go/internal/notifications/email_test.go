@@ -0,0 +1,131 @@
+// Package notifications provides notification tests
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBuildMessage tests the multipart/alternative message layout
+func TestBuildMessage(t *testing.T) {
+	raw, err := BuildMessage(Email{
+		From:    "PhilMyMeds <no-reply@phil-my-meds.test>",
+		To:      "jane@example.com",
+		Subject: "Your copay is ready – $5.00",
+		Text:    "Hi Jane,\nPay here: https://pay.test/" + strings.Repeat("x", 100),
+		HTML:    "<p>Hi Jane,</p>",
+		Headers: map[string]string{"X-Notification-ID": "n1\r\nBcc: evil@example.com"},
+	}, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Expected a message, got %v", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("Expected a parseable message, got %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Your copay is ready – $5.00" {
+		t.Errorf("Expected the encoded subject to round trip, got %q", subject)
+	}
+	if msg.Header.Get("Bcc") != "" || msg.Header.Get("X-Notification-ID") != "n1Bcc: evil@example.com" {
+		t.Errorf("Expected header values to be stripped of line breaks, got %v", msg.Header)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Expected multipart/alternative, got %s (%v)", mediaType, err)
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Expected a MIME part, got %v", err)
+		}
+		body, _ := io.ReadAll(quotedprintable.NewReader(part))
+		parts = append(parts, part.Header.Get("Content-Type")+"|"+string(body))
+	}
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "text/plain") || !strings.Contains(parts[0], strings.Repeat("x", 100)) || !strings.HasPrefix(parts[1], "text/html") {
+		t.Errorf("Expected text then HTML parts, got %v", parts)
+	}
+}
+
+// TestSMTPMailer_Send tests the SMTP conversation against a minimal server
+func TestSMTPMailer_Send(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var commands []string
+		conn.Write([]byte("220 test ESMTP\r\n"))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimRight(line, "\r\n")
+			commands = append(commands, line)
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				conn.Write([]byte("250 test\r\n"))
+			case line == "DATA":
+				conn.Write([]byte("354 go ahead\r\n"))
+				for {
+					dataLine, err := r.ReadString('\n')
+					if err != nil || dataLine == ".\r\n" {
+						break
+					}
+				}
+				conn.Write([]byte("250 queued\r\n"))
+			case line == "QUIT":
+				conn.Write([]byte("221 bye\r\n"))
+				received <- commands
+				return
+			default:
+				conn.Write([]byte("250 ok\r\n"))
+			}
+		}
+		received <- commands
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	mailer := NewSMTPMailer(host, port, "", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = mailer.Send(ctx, Email{From: "PhilMyMeds <no-reply@phil-my-meds.test>", To: "Jane Doe <jane@example.com>", Subject: "Hi", Text: "Hello"})
+	if err != nil {
+		t.Fatalf("Expected the email to be sent, got %v", err)
+	}
+	commands := strings.Join(<-received, "\n")
+	if !strings.Contains(commands, "MAIL FROM:<no-reply@phil-my-meds.test>") || !strings.Contains(commands, "RCPT TO:<jane@example.com>") {
+		t.Errorf("Unexpected SMTP conversation:\n%s", commands)
+	}
+
+	if err := mailer.Send(ctx, Email{From: "no-reply@phil-my-meds.test", To: "not an address"}); err == nil || !strings.Contains(err.Error(), ErrInvalidRecipient.Error()) {
+		t.Errorf("Expected ErrInvalidRecipient, got %v", err)
+	}
+}
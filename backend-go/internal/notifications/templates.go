@@ -0,0 +1,161 @@
+// Package notifications renders patient notifications and sends them by email; texts go through package sms
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Template names, one per kind of notification
+const (
+	TemplatePaymentLink       = "payment_link"
+	TemplateShipmentShipped   = "shipment_shipped"
+	TemplateShipmentDelivered = "shipment_delivered"
+	TemplateEnrollmentLink    = "enrollment_link"
+)
+
+// layoutVersion is the version of the shared HTML layout the current templates use
+const layoutVersion = "v1"
+
+// CurrentVersions is the template version rendered for new notifications
+// Older versions stay in templates/ so a notification can be re-rendered as it was first sent.
+var CurrentVersions = map[string]string{
+	TemplatePaymentLink:       "v1",
+	TemplateShipmentShipped:   "v1",
+	TemplateShipmentDelivered: "v1",
+	TemplateEnrollmentLink:    "v1",
+}
+
+//go:embed templates/*.html templates/*.txt
+var templateFS embed.FS
+
+// Data is what a notification template renders; only the section for the template is set
+type Data struct {
+	Subject     string // set by Render for the HTML title
+	PatientName string
+	DrugName    string
+	RxNumber    string
+	Support     string // how to reach the pharmacy team
+
+	Payment    *PaymentDetails
+	Shipment   *ShipmentDetails
+	Enrollment *EnrollmentDetails
+}
+
+// PaymentDetails is the copay a payment link collects
+type PaymentDetails struct {
+	Amount    float64
+	URL       string
+	ExpiresAt time.Time
+	Breakdown *CostBreakdown
+}
+
+// CostBreakdown explains how the copay was arrived at
+type CostBreakdown struct {
+	TotalDrugCost        float64
+	InsuranceCovered     float64
+	ManufacturerDiscount float64
+}
+
+// ShipmentDetails is the parcel a shipping notification is about
+type ShipmentDetails struct {
+	Carrier        string
+	Service        string
+	TrackingNumber string
+	TrackingURL    string
+	DeliverBy      time.Time
+	DeliveredAt    time.Time
+	Details        string // carrier delivery details, e.g. "Front door"
+	ColdChain      bool
+}
+
+// EnrollmentDetails is the magic link a patient enrolls with
+type EnrollmentDetails struct {
+	URL       string
+	ExpiresAt time.Time
+}
+
+// Message is a rendered notification
+type Message struct {
+	Template string
+	Version  string
+	Subject  string
+	Text     string
+	HTML     string
+	SMS      string // empty when the template has no text message version
+}
+
+// buttonData is the argument of the shared "button" HTML template
+type buttonData struct {
+	URL   string
+	Label string
+}
+
+// templateFuncs are available to text and HTML templates
+var templateFuncs = map[string]interface{}{
+	"money": func(amount float64) string { return fmt.Sprintf("$%.2f", amount) },
+	"date":  func(t time.Time) string { return t.Format("Monday, January 2") },
+	"datetime": func(t time.Time) string {
+		return t.Format("January 2 at 3:04 PM MST")
+	},
+	"button": func(url, label string) buttonData { return buttonData{URL: url, Label: label} },
+}
+
+// Render renders the current version of a template
+func Render(name string, data Data) (*Message, error) {
+	version, ok := CurrentVersions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown notification template %q", name)
+	}
+	return RenderVersion(name, version, data)
+}
+
+// RenderVersion renders a specific version of a template
+// The text template defines the subject in a "subject" block and the text message in an "sms" block.
+func RenderVersion(name, version string, data Data) (*Message, error) {
+	base := name + "." + version
+
+	textTmpl, err := texttemplate.New(base+".txt").Funcs(templateFuncs).ParseFS(templateFS, "templates/"+base+".txt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load text template %s: %w", base, err)
+	}
+	var subject, text bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render subject of %s: %w", base, err)
+	}
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("failed to render text of %s: %w", base, err)
+	}
+
+	var smsText bytes.Buffer
+	if textTmpl.Lookup("sms") != nil {
+		if err := textTmpl.ExecuteTemplate(&smsText, "sms", data); err != nil {
+			return nil, fmt.Errorf("failed to render text message of %s: %w", base, err)
+		}
+	}
+
+	data.Subject = strings.TrimSpace(subject.String())
+	htmlTmpl, err := htmltemplate.New(base+".html").Funcs(templateFuncs).
+		ParseFS(templateFS, "templates/layout."+layoutVersion+".html", "templates/"+base+".html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load HTML template %s: %w", base, err)
+	}
+	var html bytes.Buffer
+	if err := htmlTmpl.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("failed to render HTML of %s: %w", base, err)
+	}
+
+	return &Message{
+		Template: name,
+		Version:  version,
+		Subject:  data.Subject,
+		Text:     strings.TrimSpace(text.String()) + "\n",
+		HTML:     html.String(),
+		SMS:      strings.Join(strings.Fields(smsText.String()), " "),
+	}, nil
+}
@@ -0,0 +1,99 @@
+// Package notifications provides notification tests
+package notifications
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func templateData() Data {
+	return Data{
+		PatientName: "Jane <Doe>",
+		DrugName:    "Humira 40 mg/0.4 mL Pen",
+		RxNumber:    "RX-1001",
+		Support:     "Downtown Specialty Pharmacy on (555) 010-2000",
+	}
+}
+
+// TestRender tests that every current template renders a subject, text and HTML body and a text message
+func TestRender(t *testing.T) {
+	expires := time.Date(2024, 3, 6, 17, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		data     func(Data) Data
+		subject  string
+		contains []string
+	}{
+		{
+			name: TemplatePaymentLink,
+			data: func(d Data) Data {
+				d.Payment = &PaymentDetails{Amount: 5, URL: "https://pay.test/cs_1", ExpiresAt: expires,
+					Breakdown: &CostBreakdown{TotalDrugCost: 6500, InsuranceCovered: 6400, ManufacturerDiscount: 95}}
+				return d
+			},
+			subject:  "Your copay for Humira 40 mg/0.4 mL Pen is ready",
+			contains: []string{"$6500.00", "-$95.00", "$5.00", "https://pay.test/cs_1", "March 6 at 5:00 PM UTC"},
+		},
+		{
+			name: TemplateShipmentShipped,
+			data: func(d Data) Data {
+				d.Shipment = &ShipmentDetails{Carrier: "FedEx", Service: "Priority Overnight", TrackingNumber: "794600000000",
+					TrackingURL: "https://track.test/794600000000", DeliverBy: expires, ColdChain: true}
+				return d
+			},
+			subject:  "Your Humira 40 mg/0.4 mL Pen has shipped",
+			contains: []string{"FedEx Priority Overnight", "794600000000", "https://track.test/794600000000", "refrigerator"},
+		},
+		{
+			name: TemplateShipmentDelivered,
+			data: func(d Data) Data {
+				d.Shipment = &ShipmentDetails{Carrier: "FedEx", TrackingNumber: "794600000000", DeliveredAt: expires, Details: "Front door"}
+				return d
+			},
+			subject:  "Your Humira 40 mg/0.4 mL Pen was delivered",
+			contains: []string{"March 6 at 5:00 PM UTC", "Front door"},
+		},
+		{
+			name: TemplateEnrollmentLink,
+			data: func(d Data) Data {
+				d.Enrollment = &EnrollmentDetails{URL: "https://app.test/enroll?token=abc", ExpiresAt: expires}
+				return d
+			},
+			subject:  "Finish signing up to receive your Humira 40 mg/0.4 mL Pen",
+			contains: []string{"https://app.test/enroll?token=abc", "only be used once"},
+		},
+	}
+
+	for _, tt := range tests {
+		msg, err := Render(tt.name, tt.data(templateData()))
+		if err != nil {
+			t.Fatalf("%s: expected a rendered message, got %v", tt.name, err)
+		}
+		if msg.Subject != tt.subject || msg.Version != CurrentVersions[tt.name] {
+			t.Errorf("%s: expected subject %q at %s, got %q at %s", tt.name, tt.subject, CurrentVersions[tt.name], msg.Subject, msg.Version)
+		}
+		for _, want := range tt.contains {
+			if !strings.Contains(msg.Text, want) || !strings.Contains(msg.HTML, want) {
+				t.Errorf("%s: expected text and HTML to contain %q", tt.name, want)
+			}
+		}
+		if !strings.Contains(msg.Text, "Hi Jane <Doe>,") || !strings.Contains(msg.HTML, "Hi Jane &lt;Doe&gt;,") {
+			t.Errorf("%s: expected the name as-is in text and escaped in HTML", tt.name)
+		}
+		if !strings.Contains(msg.HTML, "<title>"+tt.subject+"</title>") {
+			t.Errorf("%s: expected the subject as the HTML title", tt.name)
+		}
+		if !strings.HasPrefix(msg.SMS, "PhilMyMeds: ") || !strings.HasSuffix(msg.SMS, "Reply STOP to opt out.") || strings.Contains(msg.SMS, "\n") {
+			t.Errorf("%s: expected a one-line text message with opt-out instructions, got %q", tt.name, msg.SMS)
+		}
+		if len(msg.SMS) > 320 {
+			t.Errorf("%s: expected a text message of at most two segments, got %d characters", tt.name, len(msg.SMS))
+		}
+	}
+
+	if _, err := Render("unknown", templateData()); err == nil {
+		t.Error("Expected an unknown template to be rejected")
+	}
+}
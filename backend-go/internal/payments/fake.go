@@ -0,0 +1,201 @@
+// Package payments provides payment provider integrations for copay collection
+package payments
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultFakeCheckoutBase is where fake checkout links point
+const defaultFakeCheckoutBase = "https://pay.phil-my-meds.test/checkout/"
+
+// FakeProvider is an in-memory PaymentProvider for tests and local runs without a Stripe mock
+// Tests drive sessions to a final state with Complete, Fail and Expire
+type FakeProvider struct {
+	mu           sync.Mutex
+	checkoutBase string
+	sessions     map[string]*fakeSession
+	idempotency  map[string]string // idempotency key -> session or refund ID
+	refunds      map[string]*Refund
+}
+
+// fakeSession is a checkout session held by the fake provider
+type fakeSession struct {
+	session        CheckoutSession
+	amount         float64
+	amountRefunded float64
+	paidAt         *time.Time
+}
+
+// NewFakeProvider creates an in-memory payment provider; checkoutBase defaults to a .test domain
+func NewFakeProvider(checkoutBase string) *FakeProvider {
+	if checkoutBase == "" {
+		checkoutBase = defaultFakeCheckoutBase
+	}
+	return &FakeProvider{
+		checkoutBase: checkoutBase,
+		sessions:     make(map[string]*fakeSession),
+		idempotency:  make(map[string]string),
+		refunds:      make(map[string]*Refund),
+	}
+}
+
+// Name returns the provider name
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+// CreateCheckoutLink creates an open checkout session; repeated idempotency keys return the same session
+func (p *FakeProvider) CreateCheckoutLink(ctx context.Context, req CheckoutRequest) (*CheckoutSession, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("fake: amount must be positive, got %.2f", req.Amount)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.idempotency[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		session := p.sessions[id].session
+		return &session, nil
+	}
+
+	id := "cs_test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	expiresAt := req.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(24 * time.Hour)
+	}
+
+	s := &fakeSession{
+		session: CheckoutSession{
+			ID:              id,
+			PaymentIntentID: "pi_test_" + strings.ReplaceAll(uuid.New().String(), "-", "")[:24],
+			URL:             p.checkoutBase + id,
+			Status:          SessionOpen,
+			ExpiresAt:       expiresAt,
+		},
+		amount: req.Amount,
+	}
+	p.sessions[id] = s
+	if req.IdempotencyKey != "" {
+		p.idempotency[req.IdempotencyKey] = id
+	}
+
+	session := s.session
+	return &session, nil
+}
+
+// RetrieveStatus returns the session's current status
+func (p *FakeProvider) RetrieveStatus(ctx context.Context, sessionID string) (*PaymentState, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.sessions[sessionID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	return &PaymentState{
+		SessionID:       s.session.ID,
+		PaymentIntentID: s.session.PaymentIntentID,
+		Status:          s.session.Status,
+		AmountTotal:     s.amount,
+		AmountRefunded:  s.amountRefunded,
+		PaidAt:          s.paidAt,
+	}, nil
+}
+
+// Refund refunds all or part of a paid session's payment intent
+func (p *FakeProvider) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.idempotency[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		refund := *p.refunds[id]
+		return &refund, nil
+	}
+
+	var s *fakeSession
+	for _, candidate := range p.sessions {
+		if candidate.session.PaymentIntentID == req.PaymentIntentID {
+			s = candidate
+			break
+		}
+	}
+	if s == nil || s.paidAt == nil {
+		return nil, fmt.Errorf("fake: payment intent %s has no captured payment", req.PaymentIntentID)
+	}
+
+	remaining := s.amount - s.amountRefunded
+	amount := req.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	if ToCents(amount) <= 0 || ToCents(amount) > ToCents(remaining) {
+		return nil, fmt.Errorf("fake: refund of %.2f exceeds remaining %.2f", amount, remaining)
+	}
+
+	s.amountRefunded = FromCents(ToCents(s.amountRefunded) + ToCents(amount))
+	if ToCents(s.amountRefunded) >= ToCents(s.amount) {
+		s.session.Status = SessionRefunded
+	}
+
+	refund := &Refund{
+		ID:              "re_test_" + strings.ReplaceAll(uuid.New().String(), "-", "")[:24],
+		PaymentIntentID: req.PaymentIntentID,
+		Amount:          amount,
+		Status:          "succeeded",
+	}
+	p.refunds[refund.ID] = refund
+	if req.IdempotencyKey != "" {
+		p.idempotency[req.IdempotencyKey] = refund.ID
+	}
+
+	result := *refund
+	return &result, nil
+}
+
+// ExpireCheckoutLink expires an open session, as Expire does
+func (p *FakeProvider) ExpireCheckoutLink(ctx context.Context, sessionID string) error {
+	return p.settle(sessionID, SessionExpired)
+}
+
+// Complete marks an open session as paid
+func (p *FakeProvider) Complete(sessionID string) error {
+	return p.settle(sessionID, SessionPaid)
+}
+
+// Fail marks an open session's payment as declined
+func (p *FakeProvider) Fail(sessionID string) error {
+	return p.settle(sessionID, SessionFailed)
+}
+
+// Expire marks an open session as expired
+func (p *FakeProvider) Expire(sessionID string) error {
+	return p.settle(sessionID, SessionExpired)
+}
+
+// settle moves an open session to a final status
+func (p *FakeProvider) settle(sessionID string, status SessionStatus) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.sessions[sessionID]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if s.session.Status != SessionOpen {
+		return fmt.Errorf("fake: session %s is %s, not open", sessionID, s.session.Status)
+	}
+
+	s.session.Status = status
+	if status == SessionPaid {
+		now := time.Now()
+		s.paidAt = &now
+	}
+	return nil
+}
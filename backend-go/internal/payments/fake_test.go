@@ -0,0 +1,125 @@
+// Package payments provides payment provider tests
+package payments
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestFakeProvider_Lifecycle tests checkout creation, idempotency, completion and refunds
+func TestFakeProvider_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	var provider PaymentProvider = NewFakeProvider("")
+	fake := provider.(*FakeProvider)
+
+	req := CheckoutRequest{
+		PaymentID:      "pay_1",
+		PrescriptionID: "rx_1",
+		Amount:         25.50,
+		ExpiresAt:      time.Now().Add(time.Hour),
+		IdempotencyKey: "checkout-rx_1",
+	}
+
+	session, err := provider.CreateCheckoutLink(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected checkout link, got %v", err)
+	}
+	if session.Status != SessionOpen || session.URL == "" || session.PaymentIntentID == "" {
+		t.Errorf("Unexpected session: %+v", session)
+	}
+
+	again, err := provider.CreateCheckoutLink(ctx, req)
+	if err != nil || again.ID != session.ID {
+		t.Errorf("Expected idempotent session %s, got %+v (%v)", session.ID, again, err)
+	}
+
+	if _, err := provider.Refund(ctx, RefundRequest{PaymentIntentID: session.PaymentIntentID}); err == nil {
+		t.Error("Expected refund of an unpaid session to fail")
+	}
+
+	if err := fake.Complete(session.ID); err != nil {
+		t.Fatalf("Expected to complete session, got %v", err)
+	}
+	if err := fake.Expire(session.ID); err == nil {
+		t.Error("Expected expiring a paid session to fail")
+	}
+
+	state, err := provider.RetrieveStatus(ctx, session.ID)
+	if err != nil || state.Status != SessionPaid || state.PaidAt == nil || state.AmountTotal != 25.50 {
+		t.Errorf("Expected paid state, got %+v (%v)", state, err)
+	}
+
+	refund, err := provider.Refund(ctx, RefundRequest{PaymentIntentID: session.PaymentIntentID, Amount: 10})
+	if err != nil || refund.Amount != 10 {
+		t.Fatalf("Expected partial refund of 10, got %+v (%v)", refund, err)
+	}
+	if _, err := provider.Refund(ctx, RefundRequest{PaymentIntentID: session.PaymentIntentID, Amount: 20}); err == nil {
+		t.Error("Expected refund beyond the remaining amount to fail")
+	}
+
+	refund, err = provider.Refund(ctx, RefundRequest{PaymentIntentID: session.PaymentIntentID})
+	if err != nil || refund.Amount != 15.50 {
+		t.Fatalf("Expected refund of the remaining 15.50, got %+v (%v)", refund, err)
+	}
+
+	state, _ = provider.RetrieveStatus(ctx, session.ID)
+	if state.Status != SessionRefunded || state.AmountRefunded != 25.50 {
+		t.Errorf("Expected fully refunded state, got %+v", state)
+	}
+
+	if _, err := provider.RetrieveStatus(ctx, "cs_missing"); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+// TestToCents tests dollar to cent conversion rounding
+func TestToCents(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   int64
+	}{
+		{5, 500},
+		{19.99, 1999},
+		{0.1 + 0.2, 30},
+	}
+
+	for _, tt := range tests {
+		if got := ToCents(tt.amount); got != tt.want {
+			t.Errorf("ToCents(%v): expected %d, got %d", tt.amount, tt.want, got)
+		}
+	}
+}
+
+// TestCheckoutIdempotencyKey tests that a second payment event for a prescription gets a fresh session
+func TestCheckoutIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakeProvider("")
+	checkout := func(eventID, claimID string, amount float64) *CheckoutSession {
+		session, err := provider.CreateCheckoutLink(ctx, CheckoutRequest{
+			PrescriptionID: "rx_1",
+			Amount:         amount,
+			IdempotencyKey: CheckoutIdempotencyKey("rx_1", eventID, claimID, amount),
+		})
+		if err != nil {
+			t.Fatalf("Expected checkout link, got %v", err)
+		}
+		return session
+	}
+
+	first := checkout("evt_1", "clm_1", 25.50)
+	if retried := checkout("evt_1", "clm_1", 25.50); retried.ID != first.ID {
+		t.Errorf("Expected a retried event to reuse session %s, got %s", first.ID, retried.ID)
+	}
+	if second := checkout("evt_2", "clm_2", 12.00); second.ID == first.ID {
+		t.Errorf("Expected a second payment event to get a fresh session, got %s again", first.ID)
+	}
+
+	legacy := checkout("", "clm_1", 25.50)
+	if again := checkout("", "clm_1", 25.50); again.ID != legacy.ID {
+		t.Errorf("Expected the same claim and amount to reuse session %s, got %s", legacy.ID, again.ID)
+	}
+	if rebilled := checkout("", "clm_1", 30.00); rebilled.ID == legacy.ID {
+		t.Errorf("Expected a new amount for the claim to get a fresh session, got %s again", legacy.ID)
+	}
+}
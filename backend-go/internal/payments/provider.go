@@ -0,0 +1,125 @@
+// Package payments provides payment provider integrations for copay collection
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Provider names
+const (
+	ProviderStripe = "stripe"
+	ProviderFake   = "fake"
+)
+
+// SessionStatus is the provider-neutral status of a checkout session
+type SessionStatus string
+
+const (
+	SessionOpen     SessionStatus = "open"     // link issued, not yet paid
+	SessionPaid     SessionStatus = "paid"     // payment captured
+	SessionFailed   SessionStatus = "failed"   // payment attempted and declined
+	SessionExpired  SessionStatus = "expired"  // link expired before payment
+	SessionRefunded SessionStatus = "refunded" // payment fully refunded
+)
+
+// ErrSessionNotFound is returned when the provider has no checkout session with the given ID
+var ErrSessionNotFound = errors.New("checkout session not found")
+
+// CheckoutRequest describes the copay checkout link to create
+type CheckoutRequest struct {
+	PaymentID      string // our payments record ID, sent as the client reference
+	PrescriptionID string
+	PatientID      string
+	Amount         float64 // dollars
+	Currency       string  // ISO currency code, lowercase (default "usd")
+	Description    string
+	SuccessURL     string
+	CancelURL      string
+	ExpiresAt      time.Time
+	IdempotencyKey string
+}
+
+// CheckoutSession is a checkout link created by the provider
+type CheckoutSession struct {
+	ID              string
+	PaymentIntentID string
+	URL             string
+	Status          SessionStatus
+	ExpiresAt       time.Time
+}
+
+// PaymentState is the provider's current view of a checkout session
+type PaymentState struct {
+	SessionID       string
+	PaymentIntentID string
+	Status          SessionStatus
+	AmountTotal     float64
+	AmountRefunded  float64
+	PaidAt          *time.Time
+}
+
+// RefundRequest describes a full or partial refund of a captured payment
+type RefundRequest struct {
+	PaymentIntentID string
+	Amount          float64 // dollars; 0 refunds the remaining captured amount
+	Reason          string
+	IdempotencyKey  string
+}
+
+// Refund is a refund issued by the provider
+type Refund struct {
+	ID              string
+	PaymentIntentID string
+	Amount          float64
+	Status          string // pending, succeeded, failed
+}
+
+// PaymentProvider creates and expires checkout links, reports their status and refunds captured payments
+type PaymentProvider interface {
+	// Name returns the provider name stored on payment records
+	Name() string
+	// CreateCheckoutLink creates a hosted checkout link for the copay
+	CreateCheckoutLink(ctx context.Context, req CheckoutRequest) (*CheckoutSession, error)
+	// RetrieveStatus returns the current status of a checkout session
+	RetrieveStatus(ctx context.Context, sessionID string) (*PaymentState, error)
+	// ExpireCheckoutLink expires an open checkout session so the link can no longer be paid
+	ExpireCheckoutLink(ctx context.Context, sessionID string) error
+	// Refund refunds all or part of a captured payment
+	Refund(ctx context.Context, req RefundRequest) (*Refund, error)
+}
+
+// NewProvider returns the configured payment provider
+// The Stripe provider targets apiBase, which may point at a local Stripe mock server
+func NewProvider(name, apiBase, secretKey string) (PaymentProvider, error) {
+	switch name {
+	case ProviderStripe:
+		return NewStripeProvider(apiBase, secretKey), nil
+	case ProviderFake:
+		return NewFakeProvider(""), nil
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", name)
+}
+
+// CheckoutIdempotencyKey returns the idempotency key of the checkout link a payment event creates
+func CheckoutIdempotencyKey(prescriptionID, eventID, claimID string, amount float64) string {
+	// Retries of the event reuse the key; a later event for the prescription gets a fresh session
+	if eventID != "" {
+		return "checkout-" + prescriptionID + "-" + eventID
+	}
+	// Events without an ID are keyed by their claim and amount
+	return fmt.Sprintf("checkout-%s-%s-%d", prescriptionID, claimID, ToCents(amount))
+}
+
+// ToCents converts a dollar amount to integer cents
+func ToCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// FromCents converts integer cents to a dollar amount
+func FromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
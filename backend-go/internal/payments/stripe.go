@@ -0,0 +1,288 @@
+// Package payments provides payment provider integrations for copay collection
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStripeAPIBase is the Stripe API; point STRIPE_API_BASE at a local mock in development
+const DefaultStripeAPIBase = "https://api.stripe.com"
+
+// StripeProvider is a PaymentProvider backed by Stripe Checkout
+// It speaks the Stripe REST API directly, so it also works against stripe-mock
+type StripeProvider struct {
+	apiBase    string
+	secretKey  string
+	httpClient *http.Client
+}
+
+// StripeError is an error response from the Stripe API
+type StripeError struct {
+	StatusCode int    `json:"-"`
+	Type       string `json:"type"`
+	Code       string `json:"code,omitempty"`
+	Message    string `json:"message"`
+}
+
+func (e *StripeError) Error() string {
+	return fmt.Sprintf("stripe: %s (%d %s)", e.Message, e.StatusCode, e.Type)
+}
+
+// stripeCheckoutSession is the subset of a Stripe Checkout Session the provider reads
+type stripeCheckoutSession struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Status        string `json:"status"`         // open, complete, expired
+	PaymentStatus string `json:"payment_status"` // paid, unpaid, no_payment_required
+	// payment_intent is an ID string unless expanded, and null until the patient starts paying
+	PaymentIntent     json.RawMessage   `json:"payment_intent"`
+	AmountTotal       int64             `json:"amount_total"`
+	ExpiresAt         int64             `json:"expires_at"`
+	ClientReferenceID string            `json:"client_reference_id"`
+	Metadata          map[string]string `json:"metadata"`
+}
+
+// stripePaymentIntent is the subset of an expanded Stripe PaymentIntent the provider reads
+type stripePaymentIntent struct {
+	ID               string            `json:"id"`
+	Status           string            `json:"status"`
+	Amount           int64             `json:"amount"`
+	AmountReceived   int64             `json:"amount_received"`
+	Metadata         map[string]string `json:"metadata"`
+	LastPaymentError *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+	LatestCharge *struct {
+		AmountRefunded int64 `json:"amount_refunded"`
+		Created        int64 `json:"created"`
+	} `json:"latest_charge"`
+}
+
+// paymentIntent decodes the session's payment intent, expanded or not; nil if there is none yet
+func (s *stripeCheckoutSession) paymentIntent() *stripePaymentIntent {
+	raw := strings.TrimSpace(string(s.PaymentIntent))
+	if raw == "" || raw == "null" {
+		return nil
+	}
+
+	var pi stripePaymentIntent
+	if strings.HasPrefix(raw, `"`) {
+		if err := json.Unmarshal(s.PaymentIntent, &pi.ID); err != nil {
+			return nil
+		}
+		return &pi
+	}
+	if err := json.Unmarshal(s.PaymentIntent, &pi); err != nil {
+		return nil
+	}
+	return &pi
+}
+
+// stripeRefund is the subset of a Stripe Refund the provider reads
+type stripeRefund struct {
+	ID            string `json:"id"`
+	Amount        int64  `json:"amount"`
+	Status        string `json:"status"`
+	PaymentIntent string `json:"payment_intent"`
+}
+
+// NewStripeProvider creates a Stripe payment provider
+func NewStripeProvider(apiBase, secretKey string) *StripeProvider {
+	if apiBase == "" {
+		apiBase = DefaultStripeAPIBase
+	}
+	return &StripeProvider{
+		apiBase:    strings.TrimRight(apiBase, "/"),
+		secretKey:  secretKey,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Name returns the provider name
+func (p *StripeProvider) Name() string {
+	return ProviderStripe
+}
+
+// CreateCheckoutLink creates a Stripe Checkout Session in payment mode for the copay
+func (p *StripeProvider) CreateCheckoutLink(ctx context.Context, req CheckoutRequest) (*CheckoutSession, error) {
+	currency := req.Currency
+	if currency == "" {
+		currency = "usd"
+	}
+
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("client_reference_id", req.PaymentID)
+	form.Set("success_url", req.SuccessURL)
+	form.Set("cancel_url", req.CancelURL)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", currency)
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(ToCents(req.Amount), 10))
+	form.Set("line_items[0][price_data][product_data][name]", req.Description)
+	form.Set("metadata[payment_id]", req.PaymentID)
+	form.Set("metadata[prescription_id]", req.PrescriptionID)
+	form.Set("payment_intent_data[metadata][payment_id]", req.PaymentID)
+	form.Set("payment_intent_data[metadata][prescription_id]", req.PrescriptionID)
+	if req.PatientID != "" {
+		form.Set("metadata[patient_id]", req.PatientID)
+	}
+	if !req.ExpiresAt.IsZero() {
+		form.Set("expires_at", strconv.FormatInt(req.ExpiresAt.Unix(), 10))
+	}
+
+	var session stripeCheckoutSession
+	if err := p.do(ctx, http.MethodPost, "/v1/checkout/sessions", form, req.IdempotencyKey, &session); err != nil {
+		return nil, err
+	}
+
+	result := &CheckoutSession{
+		ID:        session.ID,
+		URL:       session.URL,
+		Status:    stripeSessionStatus(&session),
+		ExpiresAt: time.Unix(session.ExpiresAt, 0),
+	}
+	if pi := session.paymentIntent(); pi != nil {
+		result.PaymentIntentID = pi.ID
+	}
+	return result, nil
+}
+
+// RetrieveStatus retrieves a Checkout Session with its payment intent and latest charge expanded
+func (p *StripeProvider) RetrieveStatus(ctx context.Context, sessionID string) (*PaymentState, error) {
+	path := "/v1/checkout/sessions/" + url.PathEscape(sessionID) +
+		"?expand[]=payment_intent&expand[]=payment_intent.latest_charge"
+
+	var session stripeCheckoutSession
+	if err := p.do(ctx, http.MethodGet, path, nil, "", &session); err != nil {
+		if se, ok := err.(*StripeError); ok && se.StatusCode == http.StatusNotFound {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	state := &PaymentState{
+		SessionID:   session.ID,
+		Status:      stripeSessionStatus(&session),
+		AmountTotal: FromCents(session.AmountTotal),
+	}
+	if pi := session.paymentIntent(); pi != nil {
+		state.PaymentIntentID = pi.ID
+		if charge := pi.LatestCharge; charge != nil {
+			state.AmountRefunded = FromCents(charge.AmountRefunded)
+			if state.Status == SessionPaid {
+				paidAt := time.Unix(charge.Created, 0)
+				state.PaidAt = &paidAt
+			}
+			if charge.AmountRefunded > 0 && charge.AmountRefunded >= pi.AmountReceived {
+				state.Status = SessionRefunded
+			}
+		}
+	}
+	return state, nil
+}
+
+// ExpireCheckoutLink expires an open Checkout Session; Stripe refuses sessions that are already complete or expired
+func (p *StripeProvider) ExpireCheckoutLink(ctx context.Context, sessionID string) error {
+	var session stripeCheckoutSession
+	if err := p.do(ctx, http.MethodPost, "/v1/checkout/sessions/"+url.PathEscape(sessionID)+"/expire", nil, "", &session); err != nil {
+		if se, ok := err.(*StripeError); ok && se.StatusCode == http.StatusNotFound {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Refund refunds a captured payment intent
+func (p *StripeProvider) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	form := url.Values{}
+	form.Set("payment_intent", req.PaymentIntentID)
+	if req.Amount > 0 {
+		form.Set("amount", strconv.FormatInt(ToCents(req.Amount), 10))
+	}
+	if req.Reason != "" {
+		form.Set("metadata[reason]", req.Reason)
+	}
+
+	var refund stripeRefund
+	if err := p.do(ctx, http.MethodPost, "/v1/refunds", form, req.IdempotencyKey, &refund); err != nil {
+		return nil, err
+	}
+
+	return &Refund{
+		ID:              refund.ID,
+		PaymentIntentID: refund.PaymentIntent,
+		Amount:          FromCents(refund.Amount),
+		Status:          refund.Status,
+	}, nil
+}
+
+// do sends a Stripe API request and decodes the JSON response into out
+func (p *StripeProvider) do(ctx context.Context, method, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.apiBase+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to build stripe request: %w", err)
+	}
+	req.SetBasicAuth(p.secretKey, "")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("stripe request %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read stripe response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		var envelope struct {
+			Error StripeError `json:"error"`
+		}
+		if err := json.Unmarshal(data, &envelope); err != nil || envelope.Error.Message == "" {
+			envelope.Error.Message = strings.TrimSpace(string(data))
+		}
+		envelope.Error.StatusCode = resp.StatusCode
+		return &envelope.Error
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode stripe response: %w", err)
+	}
+	return nil
+}
+
+// stripeSessionStatus maps a Stripe Checkout Session to a provider-neutral status
+func stripeSessionStatus(session *stripeCheckoutSession) SessionStatus {
+	switch {
+	case session.PaymentStatus == "paid":
+		return SessionPaid
+	case session.Status == "expired":
+		return SessionExpired
+	}
+	if pi := session.paymentIntent(); pi != nil && pi.Status == "canceled" {
+		return SessionFailed
+	}
+	return SessionOpen
+}
@@ -0,0 +1,154 @@
+// Package payments provides payment provider tests
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestStripeProvider_CreateCheckoutLink tests the Checkout Session request encoding and response parsing
+func TestStripeProvider_CreateCheckoutLink(t *testing.T) {
+	expiresAt := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/checkout/sessions" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if user, _, ok := r.BasicAuth(); !ok || user != "sk_test_123" {
+			t.Errorf("Expected secret key as basic auth user, got %q", user)
+		}
+		if got := r.Header.Get("Idempotency-Key"); got != "checkout-rx_1" {
+			t.Errorf("Expected idempotency key, got %q", got)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("Failed to parse form: %v", err)
+		}
+		if got := r.PostForm.Get("line_items[0][price_data][unit_amount]"); got != "1999" {
+			t.Errorf("Expected unit_amount 1999, got %q", got)
+		}
+		if got := r.PostForm.Get("client_reference_id"); got != "pay_1" {
+			t.Errorf("Expected client_reference_id pay_1, got %q", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"cs_test_abc","url":"https://checkout.stripe.com/c/pay/cs_test_abc","status":"open","payment_status":"unpaid","payment_intent":null,"amount_total":1999,"expires_at":` +
+			strconv.FormatInt(expiresAt.Unix(), 10) + `}`))
+	}))
+	defer server.Close()
+
+	provider := NewStripeProvider(server.URL, "sk_test_123")
+	session, err := provider.CreateCheckoutLink(context.Background(), CheckoutRequest{
+		PaymentID:      "pay_1",
+		PrescriptionID: "rx_1",
+		Amount:         19.99,
+		Description:    "Prescription copay",
+		SuccessURL:     "https://example.com/success",
+		CancelURL:      "https://example.com/cancel",
+		ExpiresAt:      expiresAt,
+		IdempotencyKey: "checkout-rx_1",
+	})
+	if err != nil {
+		t.Fatalf("Expected checkout session, got %v", err)
+	}
+	if session.ID != "cs_test_abc" || session.Status != SessionOpen || !session.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Unexpected session: %+v", session)
+	}
+}
+
+// TestStripeProvider_RetrieveStatus tests status mapping for paid, refunded and missing sessions
+func TestStripeProvider_RetrieveStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/checkout/sessions/cs_paid":
+			w.Write([]byte(`{"id":"cs_paid","status":"complete","payment_status":"paid","amount_total":500,
+				"payment_intent":{"id":"pi_1","status":"succeeded","amount_received":500,"latest_charge":{"amount_refunded":0,"created":1705330800}}}`))
+		case "/v1/checkout/sessions/cs_refunded":
+			w.Write([]byte(`{"id":"cs_refunded","status":"complete","payment_status":"paid","amount_total":500,
+				"payment_intent":{"id":"pi_2","status":"succeeded","amount_received":500,"latest_charge":{"amount_refunded":500,"created":1705330800}}}`))
+		case "/v1/checkout/sessions/cs_expired":
+			w.Write([]byte(`{"id":"cs_expired","status":"expired","payment_status":"unpaid","amount_total":500,"payment_intent":"pi_3"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"type":"invalid_request_error","code":"resource_missing","message":"No such checkout.session"}}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := NewStripeProvider(server.URL, "sk_test_123")
+	ctx := context.Background()
+
+	state, err := provider.RetrieveStatus(ctx, "cs_paid")
+	if err != nil || state.Status != SessionPaid || state.PaymentIntentID != "pi_1" || state.PaidAt == nil || state.AmountTotal != 5 {
+		t.Errorf("Expected paid state, got %+v (%v)", state, err)
+	}
+
+	state, err = provider.RetrieveStatus(ctx, "cs_refunded")
+	if err != nil || state.Status != SessionRefunded || state.AmountRefunded != 5 {
+		t.Errorf("Expected refunded state, got %+v (%v)", state, err)
+	}
+
+	state, err = provider.RetrieveStatus(ctx, "cs_expired")
+	if err != nil || state.Status != SessionExpired || state.PaymentIntentID != "pi_3" {
+		t.Errorf("Expected expired state, got %+v (%v)", state, err)
+	}
+
+	if _, err := provider.RetrieveStatus(ctx, "cs_missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+// TestStripeProvider_RefundError tests that Stripe API errors are surfaced as StripeError
+func TestStripeProvider_RefundError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"type":"invalid_request_error","code":"charge_already_refunded","message":"Charge has already been refunded."}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewStripeProvider(server.URL, "sk_test_123").Refund(context.Background(), RefundRequest{PaymentIntentID: "pi_1"})
+	var stripeErr *StripeError
+	if !errors.As(err, &stripeErr) || stripeErr.Code != "charge_already_refunded" || stripeErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected StripeError charge_already_refunded, got %v", err)
+	}
+}
+
+// TestStripeProvider_ExpireCheckoutLink tests that a session is expired through its expire endpoint
+func TestStripeProvider_ExpireCheckoutLink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/checkout/sessions/cs_open/expire":
+			w.Write([]byte(`{"id":"cs_open","status":"expired","payment_status":"unpaid","amount_total":500,"payment_intent":null}`))
+		case "/v1/checkout/sessions/cs_paid/expire":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"type":"invalid_request_error","message":"Only Checkout Sessions with a status in [\"open\"] can be expired."}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"type":"invalid_request_error","code":"resource_missing","message":"No such checkout.session"}}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := NewStripeProvider(server.URL, "sk_test_123")
+	ctx := context.Background()
+
+	if err := provider.ExpireCheckoutLink(ctx, "cs_open"); err != nil {
+		t.Errorf("Expected the open session to expire, got %v", err)
+	}
+	var stripeErr *StripeError
+	if err := provider.ExpireCheckoutLink(ctx, "cs_paid"); !errors.As(err, &stripeErr) || stripeErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a StripeError for a paid session, got %v", err)
+	}
+	if err := provider.ExpireCheckoutLink(ctx, "cs_missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
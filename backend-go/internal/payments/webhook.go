@@ -0,0 +1,182 @@
+// Package payments provides payment provider integrations for copay collection
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the webhook signature, in Stripe's "t=<unix>,v1=<hex hmac>" scheme
+const SignatureHeader = "Stripe-Signature"
+
+// DefaultWebhookTolerance is how far a signed timestamp may drift from now before the event is rejected
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// Webhook verification errors
+var (
+	ErrMissingSignature = errors.New("missing webhook signature")
+	ErrInvalidSignature = errors.New("webhook signature does not match payload")
+	ErrSignatureExpired = errors.New("webhook timestamp outside tolerance")
+)
+
+// WebhookEvent is a provider-neutral view of a payment webhook event
+// Status is the session outcome the event reports; it is empty for event types we do not act on
+type WebhookEvent struct {
+	ID              string
+	Type            string
+	Created         time.Time
+	Status          SessionStatus
+	SessionID       string
+	PaymentIntentID string
+	PaymentID       string // our payments record ID, from the checkout metadata
+	PrescriptionID  string
+	Amount          float64
+	FailureReason   string
+}
+
+// stripeEvent is the envelope of a Stripe webhook event
+type stripeEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// VerifyWebhookSignature checks the signature header against an HMAC-SHA256 of "<timestamp>.<payload>"
+// The header may carry several v1 signatures while a secret is being rolled; any match is accepted.
+func VerifyWebhookSignature(payload []byte, header, secret string, tolerance time.Duration, now time.Time) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return ErrMissingSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 {
+		drift := now.Sub(time.Unix(unix, 0))
+		if drift > tolerance || drift < -tolerance {
+			return ErrSignatureExpired
+		}
+	}
+
+	expected := computeSignature(payload, timestamp, secret)
+	for _, sig := range signatures {
+		decoded, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// SignWebhookPayload returns the signature header for a payload, as the provider would send it
+func SignWebhookPayload(payload []byte, secret string, timestamp time.Time) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(computeSignature(payload, t, secret))
+}
+
+// computeSignature returns the HMAC-SHA256 of "<timestamp>.<payload>"
+func computeSignature(payload []byte, timestamp, secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// ParseWebhookEvent decodes a Stripe webhook event into a WebhookEvent
+// Checkout session events carry the session; payment_intent.payment_failed carries the payment intent.
+func ParseWebhookEvent(payload []byte) (*WebhookEvent, error) {
+	var envelope stripeEvent
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook event: %w", err)
+	}
+	if envelope.ID == "" || envelope.Type == "" {
+		return nil, errors.New("webhook event is missing id or type")
+	}
+
+	event := &WebhookEvent{
+		ID:      envelope.ID,
+		Type:    envelope.Type,
+		Created: time.Unix(envelope.Created, 0),
+	}
+
+	switch {
+	case strings.HasPrefix(envelope.Type, "checkout.session."):
+		var session stripeCheckoutSession
+		if err := json.Unmarshal(envelope.Data.Object, &session); err != nil {
+			return nil, fmt.Errorf("failed to decode checkout session: %w", err)
+		}
+		event.SessionID = session.ID
+		event.PaymentID = session.Metadata["payment_id"]
+		if event.PaymentID == "" {
+			event.PaymentID = session.ClientReferenceID
+		}
+		event.PrescriptionID = session.Metadata["prescription_id"]
+		event.Amount = FromCents(session.AmountTotal)
+		if pi := session.paymentIntent(); pi != nil {
+			event.PaymentIntentID = pi.ID
+		}
+
+		switch envelope.Type {
+		case "checkout.session.completed":
+			// Delayed payment methods complete the session unpaid and settle later
+			if session.PaymentStatus == "paid" {
+				event.Status = SessionPaid
+			}
+		case "checkout.session.async_payment_succeeded":
+			event.Status = SessionPaid
+		case "checkout.session.async_payment_failed":
+			event.Status = SessionFailed
+			event.FailureReason = "Delayed payment failed"
+		case "checkout.session.expired":
+			event.Status = SessionExpired
+			event.FailureReason = "Payment link expired before payment"
+		}
+
+	case envelope.Type == "payment_intent.payment_failed":
+		var pi stripePaymentIntent
+		if err := json.Unmarshal(envelope.Data.Object, &pi); err != nil {
+			return nil, fmt.Errorf("failed to decode payment intent: %w", err)
+		}
+		event.Status = SessionFailed
+		event.PaymentIntentID = pi.ID
+		event.PaymentID = pi.Metadata["payment_id"]
+		event.PrescriptionID = pi.Metadata["prescription_id"]
+		event.Amount = FromCents(pi.Amount)
+		event.FailureReason = "Payment declined"
+		if pi.LastPaymentError != nil && pi.LastPaymentError.Message != "" {
+			event.FailureReason = pi.LastPaymentError.Message
+		}
+	}
+
+	return event, nil
+}
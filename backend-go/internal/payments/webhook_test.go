@@ -0,0 +1,107 @@
+// Package payments provides payment provider tests
+package payments
+
+import (
+	"testing"
+	"time"
+)
+
+// TestVerifyWebhookSignature tests signature, tolerance and secret rotation handling
+func TestVerifyWebhookSignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"checkout.session.completed"}`)
+	secret := "whsec_test"
+	now := time.Unix(1700000000, 0)
+	valid := SignWebhookPayload(payload, secret, now)
+
+	tests := []struct {
+		name    string
+		payload []byte
+		header  string
+		now     time.Time
+		want    error
+	}{
+		{"valid", payload, valid, now, nil},
+		{"within tolerance", payload, valid, now.Add(4 * time.Minute), nil},
+		{"too old", payload, valid, now.Add(6 * time.Minute), ErrSignatureExpired},
+		{"from the future", payload, valid, now.Add(-6 * time.Minute), ErrSignatureExpired},
+		{"tampered payload", []byte(`{"id":"evt_2","type":"checkout.session.completed"}`), valid, now, ErrInvalidSignature},
+		{"wrong secret", payload, SignWebhookPayload(payload, "whsec_other", now), now, ErrInvalidSignature},
+		{"rolled secret", payload, SignWebhookPayload(payload, "whsec_old", now) + ",v1=" + valid[len("t=1700000000,v1="):], now, nil},
+		{"missing header", payload, "", now, ErrMissingSignature},
+		{"no v1 signature", payload, "t=1700000000,v0=abc", now, ErrMissingSignature},
+		{"bad timestamp", payload, "t=abc,v1=00", now, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.payload, tt.header, secret, DefaultWebhookTolerance, tt.now)
+			if err != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// TestParseWebhookEvent tests mapping Stripe events to payment outcomes
+func TestParseWebhookEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    string
+		wantStatus SessionStatus
+		wantPI     string
+		wantReason string
+	}{
+		{
+			name:       "checkout completed and paid",
+			payload:    `{"id":"evt_1","type":"checkout.session.completed","created":1700000000,"data":{"object":{"id":"cs_1","payment_status":"paid","payment_intent":"pi_1","amount_total":2550,"client_reference_id":"pay_1","metadata":{"payment_id":"pay_1","prescription_id":"rx_1"}}}}`,
+			wantStatus: SessionPaid,
+			wantPI:     "pi_1",
+		},
+		{
+			name:    "checkout completed awaiting delayed payment",
+			payload: `{"id":"evt_2","type":"checkout.session.completed","data":{"object":{"id":"cs_1","payment_status":"unpaid","client_reference_id":"pay_1"}}}`,
+		},
+		{
+			name:       "checkout expired",
+			payload:    `{"id":"evt_3","type":"checkout.session.expired","data":{"object":{"id":"cs_1","payment_intent":null,"metadata":{"payment_id":"pay_1","prescription_id":"rx_1"}}}}`,
+			wantStatus: SessionExpired,
+			wantReason: "Payment link expired before payment",
+		},
+		{
+			name:       "payment intent failed",
+			payload:    `{"id":"evt_4","type":"payment_intent.payment_failed","data":{"object":{"id":"pi_1","amount":2550,"metadata":{"payment_id":"pay_1","prescription_id":"rx_1"},"last_payment_error":{"code":"card_declined","message":"Your card was declined."}}}}`,
+			wantStatus: SessionFailed,
+			wantPI:     "pi_1",
+			wantReason: "Your card was declined.",
+		},
+		{
+			name:    "unhandled type",
+			payload: `{"id":"evt_5","type":"charge.succeeded","data":{"object":{"id":"ch_1"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := ParseWebhookEvent([]byte(tt.payload))
+			if err != nil {
+				t.Fatalf("Expected event, got %v", err)
+			}
+			if event.Status != tt.wantStatus {
+				t.Errorf("Expected status %q, got %q", tt.wantStatus, event.Status)
+			}
+			if event.PaymentIntentID != tt.wantPI {
+				t.Errorf("Expected payment intent %q, got %q", tt.wantPI, event.PaymentIntentID)
+			}
+			if event.FailureReason != tt.wantReason {
+				t.Errorf("Expected reason %q, got %q", tt.wantReason, event.FailureReason)
+			}
+			if event.Status != "" && event.PaymentID != "pay_1" {
+				t.Errorf("Expected payment ID pay_1, got %q", event.PaymentID)
+			}
+		})
+	}
+
+	if _, err := ParseWebhookEvent([]byte(`{"type":"checkout.session.completed"}`)); err == nil {
+		t.Error("Expected an error for an event without an id")
+	}
+}
@@ -0,0 +1,265 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// adjudicationCacheTTL is how long adjudication results are cached in Redis
+const adjudicationCacheTTL = 30 * time.Minute
+
+var (
+	// ErrAdjudicationConflict is returned when the prescription is no longer in the expected status
+	ErrAdjudicationConflict = errors.New("prescription is not awaiting adjudication")
+	// ErrDuplicateClaim is returned when the pharmacy has already reported results for the claim ID
+	ErrDuplicateClaim = errors.New("adjudication results already recorded for claim")
+)
+
+// AdjudicationService records claim results, from the simulator or the dispensing pharmacy, and
+// moves the prescription on to payment or to an exception
+type AdjudicationService struct {
+	mongoClient *database.MongoClient
+	redis       *database.RedisClient
+	producer    kafka.Producer
+}
+
+// NewAdjudicationService creates a new adjudication service
+func NewAdjudicationService(mongoClient *database.MongoClient, redis *database.RedisClient, producer kafka.Producer) *AdjudicationService {
+	return &AdjudicationService{
+		mongoClient: mongoClient,
+		redis:       redis,
+		producer:    producer,
+	}
+}
+
+// Complete stores the adjudication record, moves the prescription on from fromStatus and publishes the outcome
+func (s *AdjudicationService) Complete(ctx context.Context, correlationID string, fromStatus models.PrescriptionStatus, record *models.AdjudicationRecord) error {
+	now := time.Now()
+	record.CorrelationID = correlationID
+	record.CausationID = events.CausationID(ctx)
+	record.CreatedAt = now
+	record.UpdatedAt = now
+	if record.ManufacturerPrograms == nil {
+		record.ManufacturerPrograms = []models.ProgramClaim{}
+	}
+
+	// Store adjudication result in MongoDB
+	adjudications := s.mongoClient.GetCollection("adjudications")
+	inserted, err := adjudications.InsertOne(ctx, record)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateClaim
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store adjudication result: %w", err)
+	}
+	if id, ok := inserted.InsertedID.(primitive.ObjectID); ok {
+		record.ID = id
+	}
+
+	// The record is removed again if the prescription is no longer in fromStatus
+	moved, err := s.advance(ctx, correlationID, fromStatus, record)
+	if err == nil && !moved {
+		err = ErrAdjudicationConflict
+	}
+	if err != nil {
+		if _, delErr := adjudications.DeleteOne(ctx, bson.M{"_id": record.ID}); delErr != nil {
+			log.Printf("⚠️  [correlation_id=%s] Failed to remove adjudication record %s: %v", correlationID, record.ID.Hex(), delErr)
+		}
+		return err
+	}
+	return s.publish(ctx, correlationID, record)
+}
+
+// Resume finishes a recorded adjudication whose follow-up did not complete, returning false if there was nothing to resume
+func (s *AdjudicationService) Resume(ctx context.Context, correlationID string, fromStatus models.PrescriptionStatus, record *models.AdjudicationRecord) (bool, error) {
+	var rx models.Prescription
+	err := s.mongoClient.GetCollection("prescriptions").FindOne(ctx, bson.M{"_id": record.PrescriptionID}).Decode(&rx)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load prescription: %w", err)
+	}
+
+	switch AdjudicationProgressOf(&rx, fromStatus, record) {
+	case AdjudicationPending:
+		moved, err := s.advance(ctx, correlationID, fromStatus, record)
+		if err != nil || !moved {
+			return false, err
+		}
+	case AdjudicationUnpublished:
+	default:
+		return false, nil
+	}
+
+	log.Printf("🔁 [correlation_id=%s] Resuming adjudication of claim %s for prescription %s", correlationID, record.Result.ClaimID, record.PrescriptionID.Hex())
+	if err := s.publish(ctx, correlationID, record); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Latest returns the most recent adjudication record of a prescription, or nil if it has none
+func (s *AdjudicationService) Latest(ctx context.Context, prescriptionID primitive.ObjectID) (*models.AdjudicationRecord, error) {
+	var record models.AdjudicationRecord
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	err := s.mongoClient.GetCollection("adjudications").FindOne(ctx, bson.M{"prescription_id": prescriptionID}, opts).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load adjudication of prescription %s: %w", prescriptionID.Hex(), err)
+	}
+	return &record, nil
+}
+
+// advance moves the prescription from fromStatus to the record's outcome
+// Returns false if the prescription is no longer in fromStatus.
+func (s *AdjudicationService) advance(ctx context.Context, correlationID string, fromStatus models.PrescriptionStatus, record *models.AdjudicationRecord) (bool, error) {
+	result := &record.Result
+	status := models.StatusAdjudicated
+	set := bson.M{
+		"adjudication":   result,
+		"cost_breakdown": record.CostBreakdown,
+	}
+	if exception := AdjudicationException(record); exception != nil {
+		status = models.StatusException
+		set = bson.M{
+			"adjudication": result,
+			"exception":    exception,
+		}
+	}
+
+	filter := bson.M{"_id": record.PrescriptionID, "status": fromStatus}
+	updated, err := ChangePrescriptionStatus(ctx, s.mongoClient.GetCollection("prescriptions"), correlationID, filter, status, set)
+	if err != nil {
+		return false, err
+	}
+	return updated != nil, nil
+}
+
+// publish caches the recorded result and publishes its outcome
+func (s *AdjudicationService) publish(ctx context.Context, correlationID string, record *models.AdjudicationRecord) error {
+	result := &record.Result
+	prescriptionID := record.PrescriptionID.Hex()
+
+	// Cache the result for the payment and status lookups that follow
+	if s.redis != nil {
+		if data, err := json.Marshal(record); err == nil {
+			if err := s.redis.Set(ctx, AdjudicationCacheKey(prescriptionID), string(data), adjudicationCacheTTL); err != nil {
+				log.Printf("⚠️  [correlation_id=%s] Failed to cache adjudication result for prescription %s: %v", correlationID, prescriptionID, err)
+			}
+		}
+	}
+
+	if exception := AdjudicationException(record); exception != nil {
+		if err := events.PublishException(ctx, s.producer, correlationID, prescriptionID, *exception); err != nil {
+			return fmt.Errorf("failed to publish prescription exception event: %w", err)
+		}
+
+		log.Printf("⚠️  [correlation_id=%s] Claim %s rejected for prescription %s: %v %s", correlationID, result.ClaimID, prescriptionID, exception.Codes, result.Message)
+		return nil
+	}
+
+	// Emit adjudication completed event
+	breakdown := record.CostBreakdown
+	programsApplied := []string{}
+	for _, pc := range record.ManufacturerPrograms {
+		if pc.Status == models.ProgramClaimApproved {
+			programsApplied = append(programsApplied, pc.ProgramName)
+		}
+	}
+
+	adjudicationEvent := &events.AdjudicationCompleted{
+		Envelope:           events.NewEnvelope(ctx, correlationID, prescriptionID),
+		PatientID:          record.PatientID,
+		PharmacyID:         record.PharmacyID,
+		Source:             record.Source,
+		AdjudicationResult: *result,
+		CostBreakdown:      breakdown,
+		FinalCopay:         &breakdown.FinalPatientCopay,
+		ProgramsApplied:    programsApplied,
+		PatientSavings:     breakdown.ManufacturerDiscount,
+		AdjudicatedAt:      result.AdjudicatedAt,
+	}
+
+	if err := events.Publish(ctx, s.producer, adjudicationEvent); err != nil {
+		return fmt.Errorf("failed to publish adjudication completed event: %w", err)
+	}
+
+	log.Printf("✅ [correlation_id=%s] Adjudication %s for prescription: %s (copay $%.2f, final $%.2f)", correlationID, result.Status, prescriptionID, breakdown.InitialCopay, breakdown.FinalPatientCopay)
+	return nil
+}
+
+// AdjudicationProgress describes how far a recorded adjudication got with its prescription
+type AdjudicationProgress int
+
+const (
+	// AdjudicationPending means the prescription is still waiting for the record to move it
+	AdjudicationPending AdjudicationProgress = iota
+	// AdjudicationUnpublished means the record moved the prescription, which has not moved on since
+	AdjudicationUnpublished
+	// AdjudicationSuperseded means the prescription has moved on, or was adjudicated again
+	AdjudicationSuperseded
+)
+
+// AdjudicationProgressOf reports how far a recorded adjudication got with its prescription
+// A prescription waiting in fromStatus that already carries the record's claim was sent back to be
+// adjudicated again (after a prior authorization), so the old record must not move it.
+func AdjudicationProgressOf(rx *models.Prescription, fromStatus models.PrescriptionStatus, record *models.AdjudicationRecord) AdjudicationProgress {
+	sameClaim := rx.Adjudication != nil && rx.Adjudication.ClaimID == record.Result.ClaimID
+	outcome := models.StatusAdjudicated
+	if AdjudicationException(record) != nil {
+		outcome = models.StatusException
+	}
+
+	switch {
+	case rx.Status == fromStatus && !sameClaim:
+		return AdjudicationPending
+	case rx.Status == outcome && sameClaim:
+		if outcome == models.StatusException && (rx.Exception == nil || rx.Exception.Type != models.ExceptionClaimRejected) {
+			return AdjudicationSuperseded
+		}
+		return AdjudicationUnpublished
+	default:
+		return AdjudicationSuperseded
+	}
+}
+
+// AdjudicationException returns the exception a rejected claim raises, or nil for a paid or partial claim
+func AdjudicationException(record *models.AdjudicationRecord) *models.PrescriptionException {
+	result := &record.Result
+	if result.Status != models.ClaimStatusRejected {
+		return nil
+	}
+	raisedAt := record.CreatedAt
+	if raisedAt.IsZero() {
+		raisedAt = time.Now()
+	}
+	return &models.PrescriptionException{
+		Type:     models.ExceptionClaimRejected,
+		Reason:   result.Message,
+		Codes:    result.RejectCodeValues(),
+		Source:   "adjudication",
+		RaisedAt: raisedAt,
+	}
+}
+
+// AdjudicationCacheKey returns the Redis key the adjudication result for a prescription is cached under
+func AdjudicationCacheKey(prescriptionID string) string {
+	return "adjudication:" + prescriptionID
+}
@@ -0,0 +1,38 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"testing"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+// TestAdjudicationProgressOf tests how far a replayed claim got with its prescription
+func TestAdjudicationProgressOf(t *testing.T) {
+	paid := &models.AdjudicationRecord{Result: models.ClaimResult{ClaimID: "CLM1", Status: models.ClaimStatusPaid}}
+	rejected := &models.AdjudicationRecord{Result: models.ClaimResult{ClaimID: "CLM1", Status: models.ClaimStatusRejected}}
+	claim := &models.ClaimResult{ClaimID: "CLM1"}
+	earlier := &models.ClaimResult{ClaimID: "CLM0"}
+	claimRejected := &models.PrescriptionException{Type: models.ExceptionClaimRejected}
+
+	tests := []struct {
+		name   string
+		rx     *models.Prescription
+		record *models.AdjudicationRecord
+		want   AdjudicationProgress
+	}{
+		{"still awaiting", &models.Prescription{Status: models.StatusAwaitingAdjudication}, paid, AdjudicationPending},
+		{"awaiting after an earlier claim", &models.Prescription{Status: models.StatusAwaitingAdjudication, Adjudication: earlier}, paid, AdjudicationPending},
+		{"adjudicated by the claim", &models.Prescription{Status: models.StatusAdjudicated, Adjudication: claim}, paid, AdjudicationUnpublished},
+		{"rejected by the claim", &models.Prescription{Status: models.StatusException, Adjudication: claim, Exception: claimRejected}, rejected, AdjudicationUnpublished},
+		{"sent back after the claim", &models.Prescription{Status: models.StatusAwaitingAdjudication, Adjudication: claim}, rejected, AdjudicationSuperseded},
+		{"other exception since", &models.Prescription{Status: models.StatusException, Adjudication: claim, Exception: &models.PrescriptionException{Type: models.ExceptionPaymentFailed}}, rejected, AdjudicationSuperseded},
+		{"moved on to payment", &models.Prescription{Status: models.StatusAwaitingPayment, Adjudication: claim}, paid, AdjudicationSuperseded},
+	}
+
+	for _, tt := range tests {
+		if got := AdjudicationProgressOf(tt.rx, models.StatusAwaitingAdjudication, tt.record); got != tt.want {
+			t.Errorf("%s: expected progress %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClaimRequest describes a pharmacy claim submitted for adjudication
+type ClaimRequest struct {
+	PrescriptionID    string
+	Plan              models.PlanKey
+	MemberID          string
+	NDC               string
+	Quantity          int
+	DaysSupply        int
+	PriorAuthApproved bool // an approved prior authorization is on file for the drug
+	At                time.Time
+}
+
+// PriorFill is the most recent paid claim for the same member and drug
+type PriorFill struct {
+	FilledAt   time.Time
+	DaysSupply int
+}
+
+// ClaimInput collects the reference data used to adjudicate a single claim
+type ClaimInput struct {
+	Request   ClaimRequest
+	Rule      *models.PlanRule       // nil if the plan is unknown
+	Formulary *models.FormularyEntry // nil if the drug is not on the plan formulary
+	Drug      *models.Drug           // nil if the NDC is unknown
+	LastFill  *PriorFill             // nil if the drug has not been filled before
+}
+
+// AdjudicationSimulator adjudicates claims against configured plan rules and formularies
+// It stands in for a real PBM switch in development and testing
+type AdjudicationSimulator struct {
+	mongoClient *database.MongoClient
+	formulary   *FormularyService
+}
+
+// NewAdjudicationSimulator creates a new adjudication simulator
+func NewAdjudicationSimulator(mongoClient *database.MongoClient, formulary *FormularyService) *AdjudicationSimulator {
+	return &AdjudicationSimulator{
+		mongoClient: mongoClient,
+		formulary:   formulary,
+	}
+}
+
+// Adjudicate loads the plan rule, formulary entry, drug pricing and fill history for a claim and adjudicates it
+func (s *AdjudicationSimulator) Adjudicate(ctx context.Context, req ClaimRequest) (*models.ClaimResult, error) {
+	if req.At.IsZero() {
+		req.At = time.Now()
+	}
+	input := ClaimInput{Request: req}
+
+	rule, err := s.PlanRule(ctx, req.Plan)
+	if err != nil {
+		return nil, err
+	}
+	input.Rule = rule
+
+	if req.Plan.BIN != "" && req.NDC != "" {
+		entry, err := s.formulary.Lookup(ctx, req.Plan, req.NDC, req.At)
+		if err != nil {
+			return nil, err
+		}
+		input.Formulary = entry
+	}
+
+	var drug models.Drug
+	err = s.mongoClient.GetCollection("drugs").FindOne(ctx, bson.M{"ndc": req.NDC}).Decode(&drug)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to look up drug %s: %w", req.NDC, err)
+	}
+	if err == nil {
+		input.Drug = &drug
+	}
+
+	lastFill, err := s.lastFill(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	input.LastFill = lastFill
+
+	result := SimulateClaim(input)
+	return &result, nil
+}
+
+// PlanRule loads the most specific plan rule matching the plan, returning nil if none exists
+func (s *AdjudicationSimulator) PlanRule(ctx context.Context, plan models.PlanKey) (*models.PlanRule, error) {
+	if plan.BIN == "" {
+		return nil, nil
+	}
+
+	cursor, err := s.mongoClient.GetCollection("plan_rules").Find(ctx, bson.M{"bin": plan.BIN})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query plan rules: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rules []models.PlanRule
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, fmt.Errorf("failed to decode plan rules: %w", err)
+	}
+
+	var best *models.PlanRule
+	for i := range rules {
+		if !rules[i].Plan.MatchesPlan(plan) {
+			continue
+		}
+		if best == nil || rules[i].Plan.Specificity() > best.Plan.Specificity() {
+			best = &rules[i]
+		}
+	}
+	return best, nil
+}
+
+// lastFill finds the member's most recent paid claim for the drug on another prescription
+func (s *AdjudicationSimulator) lastFill(ctx context.Context, req ClaimRequest) (*PriorFill, error) {
+	if req.MemberID == "" || req.NDC == "" {
+		return nil, nil
+	}
+
+	// Exclude claims for this prescription so redelivered events are not rejected as refills
+	prescriptionID, _ := primitive.ObjectIDFromHex(req.PrescriptionID)
+	filter := bson.M{
+		"member_id":       req.MemberID,
+		"ndc":             req.NDC,
+		"prescription_id": bson.M{"$ne": prescriptionID},
+		"result.status":   bson.M{"$in": []models.ClaimStatus{models.ClaimStatusPaid, models.ClaimStatusPartial}},
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "result.adjudicated_at", Value: -1}})
+
+	var doc struct {
+		Result models.ClaimResult `bson:"result"`
+	}
+	err := s.mongoClient.GetCollection("adjudications").FindOne(ctx, filter, opts).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load claim history: %w", err)
+	}
+
+	return &PriorFill{FilledAt: doc.Result.AdjudicatedAt, DaysSupply: doc.Result.DaysSupply}, nil
+}
+
+// SimulateClaim adjudicates a claim against plan rules, returning a paid, partial or rejected result
+// Reject checks follow NCPDP order: request errors and eligibility stop adjudication immediately,
+// while coverage limits (PA, days supply, refill too soon, quantity) are all reported together
+func SimulateClaim(input ClaimInput) models.ClaimResult {
+	req := input.Request
+	result := models.ClaimResult{
+		ClaimID:           "CLM-" + strings.ToUpper(uuid.New().String()[:8]),
+		RequestedQuantity: req.Quantity,
+		DaysSupply:        req.DaysSupply,
+		AdjudicatedAt:     req.At,
+	}
+
+	reject := func(message string, codes ...string) models.ClaimResult {
+		result.Status = models.ClaimStatusRejected
+		result.Message = message
+		for _, code := range codes {
+			result.RejectCodes = append(result.RejectCodes, models.NewRejectCode(code))
+		}
+		return result
+	}
+
+	// Request errors
+	if req.Quantity <= 0 {
+		return reject("quantity dispensed must be greater than zero", models.RejectMissingQuantityFilled)
+	}
+	if req.DaysSupply <= 0 {
+		return reject("days supply must be greater than zero", models.RejectMissingDaysSupply)
+	}
+
+	// Eligibility and product
+	if input.Rule == nil {
+		return reject(fmt.Sprintf("no plan found for BIN %s PCN %s", req.Plan.BIN, req.Plan.PCN), models.RejectPatientNotCovered)
+	}
+	if input.Drug == nil {
+		return reject(fmt.Sprintf("NDC %s not found", req.NDC), models.RejectNonMatchedProduct)
+	}
+	if input.Formulary == nil || !input.Formulary.Covered {
+		return reject(fmt.Sprintf("NDC %s is not covered by the plan", req.NDC), models.RejectProductNotCovered)
+	}
+	result.Tier = input.Formulary.Tier
+
+	// Coverage limits
+	var codes, messages []string
+	if input.Formulary.PARequired && !req.PriorAuthApproved {
+		codes = append(codes, models.RejectPriorAuthRequired)
+		messages = append(messages, "prior authorization required")
+	}
+	if input.Rule.MaxDaysSupply > 0 && req.DaysSupply > input.Rule.MaxDaysSupply {
+		codes = append(codes, models.RejectPlanLimitsExceeded)
+		messages = append(messages, fmt.Sprintf("days supply %d exceeds plan maximum of %d", req.DaysSupply, input.Rule.MaxDaysSupply))
+	}
+	if next, tooSoon := refillTooSoon(input.LastFill, input.Rule.RefillTooSoonPercent, req.At); tooSoon {
+		codes = append(codes, models.RejectRefillTooSoon)
+		messages = append(messages, "refill too soon, next fill date "+next.Format("2006-01-02"))
+	}
+
+	approvedQuantity := req.Quantity
+	if limit := input.Formulary.QuantityLimit; limit > 0 && req.Quantity > limit {
+		if input.Rule.AllowPartialFill {
+			approvedQuantity = limit
+		} else if !containsString(codes, models.RejectPlanLimitsExceeded) {
+			codes = append(codes, models.RejectPlanLimitsExceeded)
+			messages = append(messages, fmt.Sprintf("quantity %d exceeds plan limit of %d", req.Quantity, limit))
+		}
+	}
+
+	if len(codes) > 0 {
+		return reject(strings.Join(messages, "; "), codes...)
+	}
+
+	// Pricing
+	result.ApprovedQuantity = approvedQuantity
+	result.IngredientCost = roundCents(input.Drug.UnitCost * float64(approvedQuantity))
+	result.DispensingFee = roundCents(input.Rule.DispensingFee)
+	result.TotalCost = roundCents(result.IngredientCost + result.DispensingFee)
+	result.CopayAmount = patientCostShare(input.Rule.TierCopays, input.Formulary.Tier, result.TotalCost)
+	result.InsurancePays = roundCents(result.TotalCost - result.CopayAmount)
+
+	if approvedQuantity < req.Quantity {
+		result.Status = models.ClaimStatusPartial
+		result.Message = fmt.Sprintf("paid %d of %d units (plan quantity limit)", approvedQuantity, req.Quantity)
+	} else {
+		result.Status = models.ClaimStatusPaid
+	}
+
+	return result
+}
+
+// refillTooSoon reports whether a refill at the given time is before the plan allows, and the earliest fill date
+func refillTooSoon(last *PriorFill, percent float64, at time.Time) (time.Time, bool) {
+	if last == nil || last.DaysSupply <= 0 || percent <= 0 {
+		return time.Time{}, false
+	}
+
+	wait := time.Duration(math.Ceil(float64(last.DaysSupply)*percent)) * 24 * time.Hour
+	next := last.FilledAt.Add(wait)
+	return next, at.Before(next)
+}
+
+// patientCostShare computes the copay for a tier: flat copay plus coinsurance, capped at MaxCopay and the total cost
+func patientCostShare(tiers []models.TierCopay, tier int, totalCost float64) float64 {
+	for _, t := range tiers {
+		if t.Tier != tier {
+			continue
+		}
+
+		copay := t.Copay + t.CoinsurancePercent*totalCost
+		if t.MaxCopay > 0 {
+			copay = math.Min(copay, t.MaxCopay)
+		}
+		return roundCents(math.Min(copay, totalCost))
+	}
+
+	// Tier without configured cost share: patient pays nothing beyond the plan
+	return 0
+}
+
+func containsString(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
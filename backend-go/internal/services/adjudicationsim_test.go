@@ -0,0 +1,175 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+func testClaimInput() ClaimInput {
+	return ClaimInput{
+		Request: ClaimRequest{
+			Plan:       models.PlanKey{BIN: "123456", PCN: "ABC"},
+			MemberID:   "BC123456789",
+			NDC:        "00074-4339-02",
+			Quantity:   2,
+			DaysSupply: 28,
+			At:         time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		},
+		Rule: &models.PlanRule{
+			DispensingFee: 1.75,
+			TierCopays: []models.TierCopay{
+				{Tier: 1, Copay: 10},
+				{Tier: 5, CoinsurancePercent: 0.30, MaxCopay: 1500},
+			},
+			MaxDaysSupply:        90,
+			RefillTooSoonPercent: 0.75,
+		},
+		Formulary: &models.FormularyEntry{Covered: true, Tier: 5, QuantityLimit: 2},
+		Drug:      &models.Drug{NDC: "00074-4339-02", UnitCost: 3450},
+	}
+}
+
+// TestSimulateClaim_Paid tests pricing of a paid claim
+func TestSimulateClaim_Paid(t *testing.T) {
+	result := SimulateClaim(testClaimInput())
+
+	if result.Status != models.ClaimStatusPaid {
+		t.Fatalf("Expected status paid, got %s (%s)", result.Status, result.Message)
+	}
+	if result.IngredientCost != 6900 {
+		t.Errorf("Expected ingredient cost 6900, got %.2f", result.IngredientCost)
+	}
+	if result.TotalCost != 6901.75 {
+		t.Errorf("Expected total cost 6901.75, got %.2f", result.TotalCost)
+	}
+	// 30% coinsurance capped at $1500
+	if result.CopayAmount != 1500 {
+		t.Errorf("Expected copay 1500, got %.2f", result.CopayAmount)
+	}
+	if result.InsurancePays != 5401.75 {
+		t.Errorf("Expected insurance pays 5401.75, got %.2f", result.InsurancePays)
+	}
+	if result.ClaimID == "" {
+		t.Errorf("Expected claim ID to be set")
+	}
+}
+
+// TestSimulateClaim_Rejections tests NCPDP reject codes
+func TestSimulateClaim_Rejections(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*ClaimInput)
+		want   []string
+	}{
+		{"missing quantity", func(in *ClaimInput) { in.Request.Quantity = 0 }, []string{models.RejectMissingQuantityFilled}},
+		{"missing days supply", func(in *ClaimInput) { in.Request.DaysSupply = 0 }, []string{models.RejectMissingDaysSupply}},
+		{"unknown plan", func(in *ClaimInput) { in.Rule = nil }, []string{models.RejectPatientNotCovered}},
+		{"unknown drug", func(in *ClaimInput) { in.Drug = nil }, []string{models.RejectNonMatchedProduct}},
+		{"not on formulary", func(in *ClaimInput) { in.Formulary = nil }, []string{models.RejectProductNotCovered}},
+		{"excluded", func(in *ClaimInput) { in.Formulary.Covered = false }, []string{models.RejectProductNotCovered}},
+		{"prior auth", func(in *ClaimInput) { in.Formulary.PARequired = true }, []string{models.RejectPriorAuthRequired}},
+		{"days supply limit", func(in *ClaimInput) { in.Request.DaysSupply = 120 }, []string{models.RejectPlanLimitsExceeded}},
+		{"quantity limit", func(in *ClaimInput) { in.Request.Quantity = 4 }, []string{models.RejectPlanLimitsExceeded}},
+		{"refill too soon", func(in *ClaimInput) {
+			in.LastFill = &PriorFill{FilledAt: in.Request.At.AddDate(0, 0, -10), DaysSupply: 28}
+		}, []string{models.RejectRefillTooSoon}},
+		{"multiple", func(in *ClaimInput) {
+			in.Formulary.PARequired = true
+			in.LastFill = &PriorFill{FilledAt: in.Request.At.AddDate(0, 0, -10), DaysSupply: 28}
+		}, []string{models.RejectPriorAuthRequired, models.RejectRefillTooSoon}},
+	}
+
+	for _, tt := range tests {
+		input := testClaimInput()
+		tt.modify(&input)
+
+		result := SimulateClaim(input)
+		if result.Status != models.ClaimStatusRejected {
+			t.Errorf("%s: expected rejected, got %s", tt.name, result.Status)
+			continue
+		}
+
+		got := result.RejectCodeValues()
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected codes %v, got %v", tt.name, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected codes %v, got %v", tt.name, tt.want, got)
+				break
+			}
+		}
+		if result.CopayAmount != 0 || result.InsurancePays != 0 {
+			t.Errorf("%s: expected no payment on rejected claim", tt.name)
+		}
+	}
+}
+
+// TestSimulateClaim_PriorAuthApproved tests that an approved PA clears the 75 reject
+func TestSimulateClaim_PriorAuthApproved(t *testing.T) {
+	input := testClaimInput()
+	input.Formulary.PARequired = true
+	input.Request.PriorAuthApproved = true
+
+	if result := SimulateClaim(input); result.Status != models.ClaimStatusPaid {
+		t.Errorf("Expected paid with approved PA, got %s %v", result.Status, result.RejectCodeValues())
+	}
+}
+
+// TestSimulateClaim_RefillAfterThreshold tests that a refill after 75% of the days supply is paid
+func TestSimulateClaim_RefillAfterThreshold(t *testing.T) {
+	input := testClaimInput()
+	input.LastFill = &PriorFill{FilledAt: input.Request.At.AddDate(0, 0, -21), DaysSupply: 28}
+
+	if result := SimulateClaim(input); result.Status != models.ClaimStatusPaid {
+		t.Errorf("Expected paid refill, got %s %v", result.Status, result.RejectCodeValues())
+	}
+}
+
+// TestSimulateClaim_Partial tests partial approval up to the quantity limit
+func TestSimulateClaim_Partial(t *testing.T) {
+	input := testClaimInput()
+	input.Rule.AllowPartialFill = true
+	input.Request.Quantity = 3
+
+	result := SimulateClaim(input)
+	if result.Status != models.ClaimStatusPartial {
+		t.Fatalf("Expected status partial, got %s (%s)", result.Status, result.Message)
+	}
+	if result.RequestedQuantity != 3 || result.ApprovedQuantity != 2 {
+		t.Errorf("Expected 2 of 3 units approved, got %d of %d", result.ApprovedQuantity, result.RequestedQuantity)
+	}
+	if result.IngredientCost != 6900 {
+		t.Errorf("Expected ingredient cost for approved quantity 6900, got %.2f", result.IngredientCost)
+	}
+}
+
+// TestPatientCostShare tests flat copays, coinsurance caps and the total cost cap
+func TestPatientCostShare(t *testing.T) {
+	tiers := []models.TierCopay{
+		{Tier: 1, Copay: 10},
+		{Tier: 4, CoinsurancePercent: 0.25, MaxCopay: 500},
+	}
+
+	tests := []struct {
+		tier  int
+		total float64
+		want  float64
+	}{
+		{1, 50, 10},
+		{1, 4.5, 4.5},
+		{4, 1000, 250},
+		{4, 10000, 500},
+		{3, 100, 0},
+	}
+
+	for _, tt := range tests {
+		if got := patientCostShare(tiers, tt.tier, tt.total); got != tt.want {
+			t.Errorf("patientCostShare(tier %d, %.2f): expected %.2f, got %.2f", tt.tier, tt.total, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package services provides business logic services
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+)
+
+// CheckpointStorage stores and reads audit checkpoint files
+// MinIOService implements it
+type CheckpointStorage interface {
+	Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, opts *UploadOptions) (*ObjectInfo, error)
+	List(ctx context.Context, bucket, prefix string, recursive bool) ([]ObjectInfo, error)
+	GetObject(ctx context.Context, bucket, objectName string) (io.ReadCloser, error)
+}
+
+// AuditChainHeads reads the heads of the audit log's hash chains
+// audit.PostgresStore implements it
+type AuditChainHeads interface {
+	ChainHeads(ctx context.Context, fromChainID string) ([]audit.ChainHead, error)
+}
+
+// CheckpointFile is a checkpoint file read back from storage
+// Err is set, and Checkpoint nil, when the file could not be read or does not verify.
+type CheckpointFile struct {
+	Object     string
+	Checkpoint *audit.Checkpoint
+	Err        error
+}
+
+// AuditCheckpointService anchors the audit log's chain heads in object storage as signed checkpoints
+// Rows rewritten in PostgreSQL after a checkpoint no longer match the heads it recorded.
+type AuditCheckpointService struct {
+	chains  AuditChainHeads
+	storage CheckpointStorage
+	bucket  string
+	signer  *audit.CheckpointSigner
+}
+
+// NewAuditCheckpointService creates a new audit checkpoint service
+func NewAuditCheckpointService(chains AuditChainHeads, storage CheckpointStorage, bucket string, signer *audit.CheckpointSigner) *AuditCheckpointService {
+	return &AuditCheckpointService{
+		chains:  chains,
+		storage: storage,
+		bucket:  bucket,
+		signer:  signer,
+	}
+}
+
+// Anchor writes a signed checkpoint of the heads of today's and yesterday's chains, returning its object name
+// Yesterday's chain is included because entries created just before midnight can be written after it.
+// Nothing is written when there are no chained rows.
+func (s *AuditCheckpointService) Anchor(ctx context.Context, now time.Time) (string, error) {
+	heads, err := s.chains.ChainHeads(ctx, audit.ChainID(now.Add(-24*time.Hour)))
+	if err != nil {
+		return "", err
+	}
+	if len(heads) == 0 {
+		return "", nil
+	}
+
+	data, err := s.signer.Sign(audit.Checkpoint{CreatedAt: now.UTC(), Heads: heads})
+	if err != nil {
+		return "", err
+	}
+	object := audit.CheckpointObject(now)
+	_, err = s.storage.Upload(ctx, s.bucket, object, bytes.NewReader(data), int64(len(data)), &UploadOptions{ContentType: "application/json"})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload audit checkpoint %s: %w", object, err)
+	}
+	return object, nil
+}
+
+// Checkpoints reads and verifies the checkpoints that can anchor the chains of the days from and to (inclusive)
+// Those were written on those days or the day after, and are returned in the order they were written.
+func (s *AuditCheckpointService) Checkpoints(ctx context.Context, key ed25519.PublicKey, from, to time.Time) ([]CheckpointFile, error) {
+	objects, err := s.storage.List(ctx, s.bucket, "checkpoints/", true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit checkpoints: %w", err)
+	}
+
+	// Object names sort by the time they were written
+	first := checkpointDayPrefix(from)
+	last := checkpointDayPrefix(to.Add(48 * time.Hour))
+	var names []string
+	for _, object := range objects {
+		if object.ObjectName >= first && object.ObjectName < last && strings.HasSuffix(object.ObjectName, ".json") {
+			names = append(names, object.ObjectName)
+		}
+	}
+	sort.Strings(names)
+
+	files := make([]CheckpointFile, 0, len(names))
+	for _, name := range names {
+		file := CheckpointFile{Object: name}
+		data, err := s.read(ctx, name)
+		if err == nil {
+			file.Checkpoint, err = audit.VerifyCheckpoint(data, key)
+		}
+		file.Err = err
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// read returns the content of a checkpoint object
+func (s *AuditCheckpointService) read(ctx context.Context, object string) ([]byte, error) {
+	reader, err := s.storage.GetObject(ctx, s.bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit checkpoint %s: %w", object, err)
+	}
+	return data, nil
+}
+
+// checkpointDayPrefix is the object name prefix of the checkpoints written on t's UTC day
+func checkpointDayPrefix(t time.Time) string {
+	return "checkpoints/" + t.UTC().Format("2006/01/02") + "/"
+}
@@ -0,0 +1,140 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+)
+
+// memoryStorage keeps uploaded objects in memory
+type memoryStorage struct {
+	objects map[string][]byte
+}
+
+func (s *memoryStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, opts *UploadOptions) (*ObjectInfo, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	s.objects[objectName] = data
+	return &ObjectInfo{Bucket: bucket, ObjectName: objectName, Size: int64(len(data))}, nil
+}
+
+func (s *memoryStorage) List(ctx context.Context, bucket, prefix string, recursive bool) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for name, data := range s.objects {
+		if strings.HasPrefix(name, prefix) {
+			objects = append(objects, ObjectInfo{Bucket: bucket, ObjectName: name, Size: int64(len(data))})
+		}
+	}
+	return objects, nil
+}
+
+func (s *memoryStorage) GetObject(ctx context.Context, bucket, objectName string) (io.ReadCloser, error) {
+	data, ok := s.objects[objectName]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return io.NopCloser(strings.NewReader(string(data))), nil
+}
+
+func (s *memoryStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (*ObjectInfo, error) {
+	data, ok := s.objects[objectName]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return &ObjectInfo{Bucket: bucket, ObjectName: objectName, Size: int64(len(data))}, nil
+}
+
+// fixedChainHeads returns the heads of chains from the requested chain on
+type fixedChainHeads []audit.ChainHead
+
+func (h fixedChainHeads) ChainHeads(ctx context.Context, fromChainID string) ([]audit.ChainHead, error) {
+	var heads []audit.ChainHead
+	for _, head := range h {
+		if head.ChainID >= fromChainID {
+			heads = append(heads, head)
+		}
+	}
+	return heads, nil
+}
+
+// TestAuditCheckpointService_Anchor tests that today's and yesterday's chain heads are signed and stored
+func TestAuditCheckpointService_Anchor(t *testing.T) {
+	storage := &memoryStorage{objects: map[string][]byte{}}
+	signer := audit.NewCheckpointSigner("test-secret")
+	chains := fixedChainHeads{
+		{ChainID: "2024-01-13", Seq: 40, Hash: strings.Repeat("a", 64)},
+		{ChainID: "2024-01-14", Seq: 12, Hash: strings.Repeat("b", 64)},
+		{ChainID: "2024-01-15", Seq: 3, Hash: strings.Repeat("c", 64)},
+	}
+	service := NewAuditCheckpointService(chains, storage, "audit-checkpoints", signer)
+
+	now := time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC)
+	object, err := service.Anchor(context.Background(), now)
+	if err != nil {
+		t.Fatalf("Expected checkpoint to be written, got %v", err)
+	}
+	if object != "checkpoints/2024/01/15/20240115T093000Z.json" {
+		t.Errorf("Unexpected checkpoint object %s", object)
+	}
+
+	checkpoint, err := audit.VerifyCheckpoint(storage.objects[object], signer.PublicKey())
+	if err != nil {
+		t.Fatalf("Expected a verifiable checkpoint, got %v", err)
+	}
+	if len(checkpoint.Heads) != 2 || checkpoint.Heads[0].ChainID != "2024-01-14" || checkpoint.Heads[1].Seq != 3 {
+		t.Errorf("Expected the heads of the last two chains, got %+v", checkpoint.Heads)
+	}
+}
+
+// TestAuditCheckpointService_AnchorWithoutChains tests that no checkpoint is written before any row is chained
+func TestAuditCheckpointService_AnchorWithoutChains(t *testing.T) {
+	storage := &memoryStorage{objects: map[string][]byte{}}
+	service := NewAuditCheckpointService(fixedChainHeads{}, storage, "audit-checkpoints", audit.NewCheckpointSigner("test-secret"))
+
+	object, err := service.Anchor(context.Background(), time.Now())
+	if err != nil || object != "" || len(storage.objects) != 0 {
+		t.Errorf("Expected nothing written, got %q, %v and %d objects", object, err, len(storage.objects))
+	}
+}
+
+// TestAuditCheckpointService_Checkpoints tests that checkpoints covering the days are read in order and verified
+func TestAuditCheckpointService_Checkpoints(t *testing.T) {
+	storage := &memoryStorage{objects: map[string][]byte{}}
+	signer := audit.NewCheckpointSigner("test-secret")
+	chains := fixedChainHeads{{ChainID: "2024-01-15", Seq: 3, Hash: strings.Repeat("c", 64)}}
+	service := NewAuditCheckpointService(chains, storage, "audit-checkpoints", signer)
+
+	ctx := context.Background()
+	day := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{day.Add(-time.Hour), day.Add(10 * time.Hour), day.Add(25 * time.Hour), day.Add(49 * time.Hour)} {
+		if _, err := service.Anchor(ctx, at); err != nil {
+			t.Fatalf("Expected checkpoint to be written, got %v", err)
+		}
+	}
+	storage.objects["checkpoints/2024/01/15/20240115T230000Z.json"] = []byte(`{"checkpoint":{},"signature":"AAAA"}`)
+
+	files, err := service.Checkpoints(ctx, signer.PublicKey(), day, day)
+	if err != nil {
+		t.Fatalf("Expected checkpoints, got %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Expected the checkpoints of the day and the day after, got %+v", files)
+	}
+	if files[0].Object != "checkpoints/2024/01/15/20240115T100000Z.json" || files[0].Err != nil || files[0].Checkpoint == nil {
+		t.Errorf("Expected the first checkpoint verified, got %+v", files[0])
+	}
+	if !errors.Is(files[1].Err, audit.ErrInvalidCheckpoint) || files[1].Checkpoint != nil {
+		t.Errorf("Expected the forged checkpoint to fail verification, got %+v", files[1])
+	}
+	if files[2].Object != "checkpoints/2024/01/16/20240116T010000Z.json" || files[2].Err != nil {
+		t.Errorf("Expected the next day's checkpoint verified, got %+v", files[2])
+	}
+}
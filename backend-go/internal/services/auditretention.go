@@ -0,0 +1,177 @@
+// Package services provides business logic services
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+)
+
+// AuditPartitions manages the monthly partitions of audit_logs
+// audit.PostgresStore implements it
+type AuditPartitions interface {
+	Partitions(ctx context.Context) ([]audit.Partition, error)
+	EnsurePartitions(ctx context.Context, t time.Time, ahead int) ([]audit.Partition, error)
+	ExportPartition(ctx context.Context, partition audit.Partition, w io.Writer) (int64, error)
+	DropPartition(ctx context.Context, partition audit.Partition) error
+}
+
+// ArchiveStorage stores audit log archives and checks what was stored
+// MinIOService implements it
+type ArchiveStorage interface {
+	Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, opts *UploadOptions) (*ObjectInfo, error)
+	GetObjectInfo(ctx context.Context, bucket, objectName string) (*ObjectInfo, error)
+}
+
+// AuditArchive describes an archived partition; it is stored next to the archive as its manifest
+type AuditArchive struct {
+	Partition  string    `json:"partition"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Object     string    `json:"object"`
+	Rows       int64     `json:"rows"`
+	Size       int64     `json:"size"`   // compressed bytes
+	SHA256     string    `json:"sha256"` // of the compressed archive
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+// AuditRetentionService creates audit_logs partitions ahead of time and archives expired ones to object storage
+type AuditRetentionService struct {
+	partitions      AuditPartitions
+	storage         ArchiveStorage
+	bucket          string
+	retentionMonths int
+	aheadMonths     int
+}
+
+// NewAuditRetentionService creates a new audit retention service
+// Partitions are kept in PostgreSQL for retentionMonths full months (0 keeps them all) and created
+// aheadMonths months in advance.
+func NewAuditRetentionService(partitions AuditPartitions, storage ArchiveStorage, bucket string, retentionMonths, aheadMonths int) *AuditRetentionService {
+	return &AuditRetentionService{
+		partitions:      partitions,
+		storage:         storage,
+		bucket:          bucket,
+		retentionMonths: retentionMonths,
+		aheadMonths:     aheadMonths,
+	}
+}
+
+// CreatePartitions creates the partitions of this month and the months ahead that do not exist yet
+func (s *AuditRetentionService) CreatePartitions(ctx context.Context, now time.Time) ([]audit.Partition, error) {
+	return s.partitions.EnsurePartitions(ctx, now, s.aheadMonths)
+}
+
+// Archive moves the partitions that ended more than the retention period ago to object storage
+// Each is written as gzipped NDJSON with a manifest, and only dropped once the stored archive is checked.
+// It stops at the first failure, returning the partitions archived so far.
+func (s *AuditRetentionService) Archive(ctx context.Context, now time.Time) ([]AuditArchive, error) {
+	if s.retentionMonths <= 0 {
+		return nil, nil
+	}
+	partitions, err := s.partitions.Partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := audit.MonthPartition(now).From.AddDate(0, -s.retentionMonths, 0)
+	var archived []AuditArchive
+	for _, partition := range partitions {
+		if partition.To.After(cutoff) {
+			continue
+		}
+		archive, err := s.archivePartition(ctx, partition, now)
+		if err != nil {
+			return archived, err
+		}
+		archived = append(archived, *archive)
+	}
+	return archived, nil
+}
+
+// archivePartition uploads a partition's rows and manifest, then drops the partition
+func (s *AuditRetentionService) archivePartition(ctx context.Context, partition audit.Partition, now time.Time) (*AuditArchive, error) {
+	archive := &AuditArchive{
+		Partition:  partition.Name,
+		From:       partition.From,
+		To:         partition.To,
+		Object:     AuditArchiveObject(partition),
+		ArchivedAt: now.UTC(),
+	}
+
+	// Rows are compressed as they are read and streamed to storage, so a month is never held in memory
+	reader, writer := io.Pipe()
+	hash := sha256.New()
+	counter := &countingWriter{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		gz := gzip.NewWriter(io.MultiWriter(writer, hash, counter))
+		rows, err := s.partitions.ExportPartition(ctx, partition, gz)
+		if err == nil {
+			err = gz.Close()
+		}
+		archive.Rows = rows
+		writer.CloseWithError(err)
+	}()
+	_, err := s.storage.Upload(ctx, s.bucket, archive.Object, reader, -1, &UploadOptions{ContentType: "application/gzip"})
+	// Stops the export if the upload failed part way, and waits for it to finish writing
+	reader.CloseWithError(err)
+	<-done
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive partition %s: %w", partition.Name, err)
+	}
+	archive.Size = counter.n
+	archive.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	info, err := s.storage.GetObjectInfo(ctx, s.bucket, archive.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check archive of partition %s: %w", partition.Name, err)
+	}
+	if info.Size != archive.Size {
+		return nil, fmt.Errorf("archive of partition %s is %d bytes, expected %d", partition.Name, info.Size, archive.Size)
+	}
+
+	manifest, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest of partition %s: %w", partition.Name, err)
+	}
+	manifestObject := AuditArchiveManifestObject(partition)
+	_, err = s.storage.Upload(ctx, s.bucket, manifestObject, bytes.NewReader(manifest), int64(len(manifest)), &UploadOptions{ContentType: "application/json"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload manifest of partition %s: %w", partition.Name, err)
+	}
+
+	if err := s.partitions.DropPartition(ctx, partition); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// AuditArchiveObject returns the object name of a partition's archive, e.g. "audit_logs/2024/audit_logs_2024_01.ndjson.gz"
+func AuditArchiveObject(partition audit.Partition) string {
+	return fmt.Sprintf("audit_logs/%d/%s.ndjson.gz", partition.From.Year(), partition.Name)
+}
+
+// AuditArchiveManifestObject returns the object name of a partition archive's manifest
+func AuditArchiveManifestObject(partition audit.Partition) string {
+	return fmt.Sprintf("audit_logs/%d/%s.manifest.json", partition.From.Year(), partition.Name)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
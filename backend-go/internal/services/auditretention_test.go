@@ -0,0 +1,166 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+)
+
+// fakePartitions holds monthly partitions of a few rows each
+type fakePartitions struct {
+	partitions []audit.Partition
+	dropped    []string
+}
+
+func (p *fakePartitions) Partitions(ctx context.Context) ([]audit.Partition, error) {
+	return p.partitions, nil
+}
+
+func (p *fakePartitions) EnsurePartitions(ctx context.Context, t time.Time, ahead int) ([]audit.Partition, error) {
+	var created []audit.Partition
+	for i := 0; i <= ahead; i++ {
+		partition := audit.MonthPartition(t.AddDate(0, i, 0))
+		if !p.has(partition.Name) {
+			p.partitions = append(p.partitions, partition)
+			created = append(created, partition)
+		}
+	}
+	return created, nil
+}
+
+func (p *fakePartitions) ExportPartition(ctx context.Context, partition audit.Partition, w io.Writer) (int64, error) {
+	encoder := json.NewEncoder(w)
+	for i := int64(1); i <= 3; i++ {
+		row := audit.ChainRow{ID: i, ChainID: audit.ChainID(partition.From), Seq: i, EventType: "phi_accessed", CreatedAt: partition.From}
+		if err := encoder.Encode(row); err != nil {
+			return i - 1, err
+		}
+	}
+	return 3, nil
+}
+
+func (p *fakePartitions) DropPartition(ctx context.Context, partition audit.Partition) error {
+	p.dropped = append(p.dropped, partition.Name)
+	return nil
+}
+
+func (p *fakePartitions) has(name string) bool {
+	for _, partition := range p.partitions {
+		if partition.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// failingStorage refuses uploads
+type failingStorage struct {
+	memoryStorage
+}
+
+func (s *failingStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, opts *UploadOptions) (*ObjectInfo, error) {
+	return nil, errors.New("connection refused")
+}
+
+func monthPartitions(months ...string) []audit.Partition {
+	var partitions []audit.Partition
+	for _, month := range months {
+		t, err := time.Parse("2006-01", month)
+		if err != nil {
+			panic(fmt.Sprintf("bad month %s", month))
+		}
+		partitions = append(partitions, audit.MonthPartition(t))
+	}
+	return partitions
+}
+
+// TestAuditRetentionService_CreatePartitions tests that missing partitions are created for the months ahead
+func TestAuditRetentionService_CreatePartitions(t *testing.T) {
+	partitions := &fakePartitions{partitions: monthPartitions("2024-12", "2025-01")}
+	service := NewAuditRetentionService(partitions, &memoryStorage{objects: map[string][]byte{}}, "audit-archive", 12, 3)
+
+	created, err := service.CreatePartitions(context.Background(), time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Expected partitions to be created, got %v", err)
+	}
+	if len(created) != 3 || created[0].Name != "audit_logs_2025_02" || created[2].Name != "audit_logs_2025_04" {
+		t.Errorf("Expected February to April created, got %+v", created)
+	}
+}
+
+// TestAuditRetentionService_Archive tests that partitions past retention are archived as gzipped NDJSON, then dropped
+func TestAuditRetentionService_Archive(t *testing.T) {
+	partitions := &fakePartitions{partitions: monthPartitions("2023-11", "2023-12", "2024-01", "2025-01")}
+	storage := &memoryStorage{objects: map[string][]byte{}}
+	service := NewAuditRetentionService(partitions, storage, "audit-archive", 12, 3)
+
+	archived, err := service.Archive(context.Background(), time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Expected partitions to be archived, got %v", err)
+	}
+	if len(archived) != 2 || len(partitions.dropped) != 2 || partitions.dropped[1] != "audit_logs_2023_12" {
+		t.Fatalf("Expected November and December 2023 archived and dropped, got %+v and %v", archived, partitions.dropped)
+	}
+
+	archive := archived[0]
+	if archive.Object != "audit_logs/2023/audit_logs_2023_11.ndjson.gz" || archive.Rows != 3 || archive.Size != int64(len(storage.objects[archive.Object])) {
+		t.Errorf("Unexpected archive %+v", archive)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(storage.objects[archive.Object]))
+	if err != nil {
+		t.Fatalf("Expected a gzipped archive, got %v", err)
+	}
+	scanner := bufio.NewScanner(gz)
+	var rows []audit.ChainRow
+	for scanner.Scan() {
+		var row audit.ChainRow
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("Expected NDJSON rows, got %v", err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 3 || rows[2].Seq != 3 || rows[0].ChainID != "2023-11-01" {
+		t.Errorf("Expected the partition's rows, got %+v", rows)
+	}
+
+	var manifest AuditArchive
+	if err := json.Unmarshal(storage.objects["audit_logs/2023/audit_logs_2023_11.manifest.json"], &manifest); err != nil {
+		t.Fatalf("Expected a manifest, got %v", err)
+	}
+	if manifest.Rows != 3 || manifest.SHA256 != archive.SHA256 || len(manifest.SHA256) != 64 {
+		t.Errorf("Expected the manifest to describe the archive, got %+v", manifest)
+	}
+}
+
+// TestAuditRetentionService_ArchiveFailureKeepsPartition tests that a partition is not dropped when its upload fails
+func TestAuditRetentionService_ArchiveFailureKeepsPartition(t *testing.T) {
+	partitions := &fakePartitions{partitions: monthPartitions("2023-11", "2025-01")}
+	storage := &failingStorage{memoryStorage{objects: map[string][]byte{}}}
+	service := NewAuditRetentionService(partitions, storage, "audit-archive", 12, 3)
+
+	archived, err := service.Archive(context.Background(), time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC))
+	if err == nil || len(archived) != 0 || len(partitions.dropped) != 0 {
+		t.Errorf("Expected the failure reported and nothing dropped, got %v, %+v and %v", err, archived, partitions.dropped)
+	}
+}
+
+// TestAuditRetentionService_ArchiveDisabled tests that a retention of 0 months keeps every partition
+func TestAuditRetentionService_ArchiveDisabled(t *testing.T) {
+	partitions := &fakePartitions{partitions: monthPartitions("2020-01", "2025-01")}
+	service := NewAuditRetentionService(partitions, &memoryStorage{objects: map[string][]byte{}}, "audit-archive", 0, 3)
+
+	archived, err := service.Archive(context.Background(), time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(archived) != 0 || len(partitions.dropped) != 0 {
+		t.Errorf("Expected nothing archived, got %v, %+v and %v", err, archived, partitions.dropped)
+	}
+}
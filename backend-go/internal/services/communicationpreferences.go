@@ -0,0 +1,402 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	_ "time/tzdata" // quiet hours need patient timezones even in images without a zoneinfo database
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/notifications"
+	"github.com/phil-my-meds/backend-gogit/internal/sms"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultTimezone is used for quiet hours when the patient has not set a timezone
+const DefaultTimezone = "America/New_York"
+
+// DefaultQuietHours keeps texts to 8am-9pm in the patient's timezone unless they choose otherwise
+var DefaultQuietHours = models.QuietHours{Start: "21:00", End: "08:00"}
+
+// DefaultNotificationChannels is the channel order for each message type (notification template)
+// Time-sensitive messages go by text first; the rest by email first. Either falls back to the other.
+var DefaultNotificationChannels = map[string][]models.NotificationChannel{
+	notifications.TemplatePaymentLink:       {models.NotificationSMS, models.NotificationEmail},
+	notifications.TemplateShipmentShipped:   {models.NotificationEmail, models.NotificationSMS},
+	notifications.TemplateShipmentDelivered: {models.NotificationSMS, models.NotificationEmail},
+	notifications.TemplateEnrollmentLink:    {models.NotificationSMS, models.NotificationEmail},
+}
+
+var (
+	// ErrPatientNotFound is returned when no patient has the requested ID
+	ErrPatientNotFound = errors.New("patient not found")
+	// ErrInvalidPreferences is returned when communication preferences fail validation
+	ErrInvalidPreferences = errors.New("invalid communication preferences")
+	// ErrSMSOptedOut is returned when SMS consent is granted for a number that replied STOP
+	ErrSMSOptedOut = errors.New("number has opted out of texts; the patient must reply START to opt back in")
+)
+
+// PatientContact is where a notification can reach the patient
+type PatientContact struct {
+	Name  string
+	Email string
+	Phone string
+}
+
+// CommunicationPreferenceService manages patient communication preferences, consent and SMS opt-outs
+type CommunicationPreferenceService struct {
+	mongoClient *database.MongoClient
+}
+
+// NewCommunicationPreferenceService creates a new communication preference service
+func NewCommunicationPreferenceService(mongoClient *database.MongoClient) *CommunicationPreferenceService {
+	return &CommunicationPreferenceService{
+		mongoClient: mongoClient,
+	}
+}
+
+// ChannelAllowed reports whether the patient may be contacted on a channel
+// Transactional email is sent unless the patient opted out; texts need the patient's consent.
+func ChannelAllowed(channel models.NotificationChannel, prefs *models.CommunicationPreferences) bool {
+	switch channel {
+	case models.NotificationEmail:
+		return prefs == nil || prefs.Email.Status != models.ConsentRevoked
+	case models.NotificationSMS:
+		return prefs != nil && prefs.SMS.Status == models.ConsentGranted
+	}
+	return false
+}
+
+// NotificationRoutes returns the channels to try for a message type, in order
+// Channels the patient has not consented to, or has no address for, are left out.
+func NotificationRoutes(template string, contact PatientContact, prefs *models.CommunicationPreferences) []models.NotificationRoute {
+	order := DefaultNotificationChannels[template]
+	if prefs != nil && len(prefs.Channels[template]) > 0 {
+		order = prefs.Channels[template]
+	}
+
+	var routes []models.NotificationRoute
+	for _, channel := range order {
+		if !ChannelAllowed(channel, prefs) {
+			continue
+		}
+		switch channel {
+		case models.NotificationEmail:
+			if contact.Email == "" {
+				continue
+			}
+			routes = append(routes, models.NotificationRoute{
+				Channel:   channel,
+				Recipient: (&mail.Address{Name: contact.Name, Address: contact.Email}).String(),
+			})
+		case models.NotificationSMS:
+			number := prefs.SMSNumber
+			if number == "" {
+				number = contact.Phone
+			}
+			phone, err := sms.NormalizePhone(number)
+			if err != nil {
+				continue
+			}
+			quiet := DefaultQuietHours
+			if prefs.QuietHours != nil {
+				quiet = *prefs.QuietHours
+			}
+			timezone := prefs.Timezone
+			if timezone == "" {
+				timezone = DefaultTimezone
+			}
+			routes = append(routes, models.NotificationRoute{
+				Channel:    channel,
+				Recipient:  phone,
+				QuietHours: &quiet,
+				Timezone:   timezone,
+			})
+		}
+	}
+	return routes
+}
+
+// QuietHoursEnd returns when a message may be sent: now, or the end of the quiet hours now falls in
+// An unknown timezone is treated as DefaultTimezone.
+func QuietHoursEnd(quiet *models.QuietHours, timezone string, now time.Time) time.Time {
+	if quiet == nil {
+		return now
+	}
+	start, errStart := parseClock(quiet.Start)
+	end, errEnd := parseClock(quiet.End)
+	if errStart != nil || errEnd != nil || start == end {
+		return now
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		loc, err = time.LoadLocation(DefaultTimezone)
+		if err != nil {
+			loc = time.UTC
+		}
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	endOn := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), end/60, end%60, 0, 0, loc)
+	}
+
+	if start < end {
+		if minute >= start && minute < end {
+			return endOn(local)
+		}
+		return now
+	}
+	// Overnight window, e.g. 21:00-08:00
+	if minute >= start {
+		return endOn(local.AddDate(0, 0, 1))
+	}
+	if minute < end {
+		return endOn(local)
+	}
+	return now
+}
+
+// ValidatePreferences checks communication preferences before they are saved
+func ValidatePreferences(prefs *models.CommunicationPreferences) error {
+	for channel, consent := range map[string]models.ConsentStatus{"email": prefs.Email.Status, "sms": prefs.SMS.Status} {
+		switch consent {
+		case models.ConsentUnknown, models.ConsentGranted, models.ConsentRevoked:
+		default:
+			return fmt.Errorf("%w: %s consent must be granted or revoked", ErrInvalidPreferences, channel)
+		}
+	}
+	if prefs.SMSNumber != "" {
+		if _, err := sms.NormalizePhone(prefs.SMSNumber); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPreferences, err)
+		}
+	}
+	if prefs.Timezone != "" {
+		if _, err := time.LoadLocation(prefs.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidPreferences, prefs.Timezone)
+		}
+	}
+	if prefs.QuietHours != nil {
+		if _, err := parseClock(prefs.QuietHours.Start); err != nil {
+			return fmt.Errorf("%w: quiet hours start %v", ErrInvalidPreferences, err)
+		}
+		if _, err := parseClock(prefs.QuietHours.End); err != nil {
+			return fmt.Errorf("%w: quiet hours end %v", ErrInvalidPreferences, err)
+		}
+	}
+	for template, channels := range prefs.Channels {
+		if _, ok := DefaultNotificationChannels[template]; !ok {
+			return fmt.Errorf("%w: unknown message type %q", ErrInvalidPreferences, template)
+		}
+		seen := map[models.NotificationChannel]bool{}
+		for _, channel := range channels {
+			if channel != models.NotificationEmail && channel != models.NotificationSMS {
+				return fmt.Errorf("%w: unknown channel %q for %s", ErrInvalidPreferences, channel, template)
+			}
+			if seen[channel] {
+				return fmt.Errorf("%w: channel %q listed twice for %s", ErrInvalidPreferences, channel, template)
+			}
+			seen[channel] = true
+		}
+	}
+	return nil
+}
+
+// Get returns a patient with their communication preferences
+func (s *CommunicationPreferenceService) Get(ctx context.Context, patientID string) (*models.Patient, error) {
+	objectID, err := primitive.ObjectIDFromHex(patientID)
+	if err != nil {
+		return nil, ErrPatientNotFound
+	}
+	var patient models.Patient
+	err = s.mongoClient.GetCollection("patients").FindOne(ctx, bson.M{"_id": objectID}).Decode(&patient)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPatientNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load patient: %w", err)
+	}
+	return &patient, nil
+}
+
+// Update replaces a patient's communication preferences
+// Consent changes are stamped with source and time; unchanged consent keeps its history. Granting SMS
+// consent needs a number, from the preferences or the patient record, that has not replied STOP.
+func (s *CommunicationPreferenceService) Update(ctx context.Context, patientID string, prefs models.CommunicationPreferences, source models.ConsentSource) (*models.CommunicationPreferences, error) {
+	if err := ValidatePreferences(&prefs); err != nil {
+		return nil, err
+	}
+	patient, err := s.Get(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var previous models.CommunicationPreferences
+	if patient.Communication != nil {
+		previous = *patient.Communication
+	}
+	prefs.Email = stampConsent(previous.Email, prefs.Email.Status, source, now)
+	prefs.SMS = stampConsent(previous.SMS, prefs.SMS.Status, source, now)
+
+	if prefs.SMS.Status == models.ConsentGranted {
+		number := prefs.SMSNumber
+		if number == "" {
+			number = patient.Phone
+		}
+		phone, err := sms.NormalizePhone(number)
+		if err != nil {
+			return nil, fmt.Errorf("%w: SMS consent needs a valid phone number", ErrInvalidPreferences)
+		}
+		optedOut, err := s.IsOptedOut(ctx, phone)
+		if err != nil {
+			return nil, err
+		}
+		if optedOut {
+			return nil, ErrSMSOptedOut
+		}
+		prefs.SMSNumber = phone
+	} else if prefs.SMSNumber != "" {
+		prefs.SMSNumber, _ = sms.NormalizePhone(prefs.SMSNumber)
+	}
+	prefs.UpdatedAt = now
+
+	if err := recordPreferencesChange(ctx, patient.ID.Hex(), source, prefs.Email.Status, prefs.SMS.Status); err != nil {
+		return nil, err
+	}
+	_, err = s.mongoClient.GetCollection("patients").UpdateOne(ctx,
+		bson.M{"_id": patient.ID},
+		bson.M{"$set": bson.M{"communication": prefs, "updated_at": now}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save communication preferences: %w", err)
+	}
+	return &prefs, nil
+}
+
+// IsOptedOut reports whether an E.164 number has replied STOP
+func (s *CommunicationPreferenceService) IsOptedOut(ctx context.Context, phone string) (bool, error) {
+	err := s.mongoClient.GetCollection("sms_opt_outs").FindOne(ctx, bson.M{"_id": phone}).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check SMS opt-out for %s: %w", phone, err)
+	}
+	return true, nil
+}
+
+// OptOut records that a number no longer wants texts and revokes SMS consent of the patients using it
+// It returns the number of patients whose consent was revoked.
+func (s *CommunicationPreferenceService) OptOut(ctx context.Context, phone string, source models.ConsentSource) (int, error) {
+	now := time.Now()
+	_, err := s.mongoClient.GetCollection("sms_opt_outs").UpdateOne(ctx,
+		bson.M{"_id": phone},
+		bson.M{"$setOnInsert": bson.M{"source": source, "opted_out_at": now}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record SMS opt-out for %s: %w", phone, err)
+	}
+	return s.setSMSConsent(ctx, phone, models.ConsentRevoked, source, now)
+}
+
+// OptIn clears a number's opt-out and grants SMS consent to the patients using it, after a START reply
+// It returns the number of patients whose consent was granted.
+func (s *CommunicationPreferenceService) OptIn(ctx context.Context, phone string, source models.ConsentSource) (int, error) {
+	if _, err := s.mongoClient.GetCollection("sms_opt_outs").DeleteOne(ctx, bson.M{"_id": phone}); err != nil {
+		return 0, fmt.Errorf("failed to clear SMS opt-out for %s: %w", phone, err)
+	}
+	return s.setSMSConsent(ctx, phone, models.ConsentGranted, source, time.Now())
+}
+
+// setSMSConsent updates SMS consent of the patients texted at a number
+// The patients are looked up first so each change can be audited under the patient it belongs to.
+func (s *CommunicationPreferenceService) setSMSConsent(ctx context.Context, phone string, status models.ConsentStatus, source models.ConsentSource, now time.Time) (int, error) {
+	collection := s.mongoClient.GetCollection("patients")
+	filter := bson.M{"communication.sms_number": phone, "communication.sms.status": bson.M{"$ne": status}}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find patients texted at %s: %w", phone, err)
+	}
+	var patients []models.Patient
+	if err := cursor.All(ctx, &patients); err != nil {
+		return 0, fmt.Errorf("failed to decode patients texted at %s: %w", phone, err)
+	}
+	if len(patients) == 0 {
+		return 0, nil
+	}
+	ids := make([]primitive.ObjectID, len(patients))
+	for i, patient := range patients {
+		ids[i] = patient.ID
+	}
+
+	for _, id := range ids {
+		if err := recordPreferencesChange(ctx, id.Hex(), source, "", status); err != nil {
+			return 0, err
+		}
+	}
+
+	filter["_id"] = bson.M{"$in": ids}
+	result, err := collection.UpdateMany(ctx, filter,
+		bson.M{"$set": bson.M{
+			"communication.sms":        models.ChannelConsent{Status: status, Source: source, UpdatedAt: &now},
+			"communication.updated_at": now,
+			"updated_at":               now,
+		}},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update SMS consent for %s: %w", phone, err)
+	}
+	return int(result.ModifiedCount), nil
+}
+
+// recordPreferencesChange audits a change of a patient's communication preferences or consent
+// Consent changes must be audited, so the entry is written first and an error means the change is not made.
+func recordPreferencesChange(ctx context.Context, patientID string, source models.ConsentSource, email, sms models.ConsentStatus) error {
+	details := map[string]interface{}{"source": source}
+	if email != "" {
+		details["email_consent"] = email
+	}
+	if sms != "" {
+		details["sms_consent"] = sms
+	}
+	err := audit.Record(ctx, audit.Entry{
+		EventType:  audit.EventCommunicationPrefsChanged,
+		EntityType: audit.EntityCommunicationPreferences,
+		EntityID:   patientID,
+		Action:     audit.ActionUpdate,
+		Details:    details,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to audit communication preferences of patient %s: %w", patientID, err)
+	}
+	return nil
+}
+
+// stampConsent records the source and time of a consent change, keeping them when nothing changed
+func stampConsent(previous models.ChannelConsent, status models.ConsentStatus, source models.ConsentSource, now time.Time) models.ChannelConsent {
+	if status == previous.Status {
+		return previous
+	}
+	return models.ChannelConsent{Status: status, Source: source, UpdatedAt: &now}
+}
+
+// parseClock parses a "15:04" time of day into minutes after midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a 24-hour HH:MM time", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,152 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/notifications"
+)
+
+// TestChannelAllowed tests that email is opt-out and texts are opt-in
+func TestChannelAllowed(t *testing.T) {
+	granted := &models.CommunicationPreferences{SMS: models.ChannelConsent{Status: models.ConsentGranted}}
+	revoked := &models.CommunicationPreferences{
+		Email: models.ChannelConsent{Status: models.ConsentRevoked},
+		SMS:   models.ChannelConsent{Status: models.ConsentRevoked},
+	}
+
+	tests := []struct {
+		name     string
+		channel  models.NotificationChannel
+		prefs    *models.CommunicationPreferences
+		expected bool
+	}{
+		{"email without preferences", models.NotificationEmail, nil, true},
+		{"email with consent never asked", models.NotificationEmail, granted, true},
+		{"email revoked", models.NotificationEmail, revoked, false},
+		{"sms without preferences", models.NotificationSMS, nil, false},
+		{"sms never asked", models.NotificationSMS, &models.CommunicationPreferences{}, false},
+		{"sms granted", models.NotificationSMS, granted, true},
+		{"sms revoked", models.NotificationSMS, revoked, false},
+	}
+
+	for _, tt := range tests {
+		if got := ChannelAllowed(tt.channel, tt.prefs); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+// TestNotificationRoutes tests the channel order per message type and the routes left out
+func TestNotificationRoutes(t *testing.T) {
+	contact := PatientContact{Name: "Jane", Email: "jane@example.com", Phone: "(555) 234-5678"}
+	granted := &models.CommunicationPreferences{SMS: models.ChannelConsent{Status: models.ConsentGranted}, Timezone: "America/Denver"}
+
+	routes := NotificationRoutes(notifications.TemplatePaymentLink, contact, granted)
+	if len(routes) != 2 || routes[0].Channel != models.NotificationSMS || routes[1].Channel != models.NotificationEmail {
+		t.Fatalf("Expected payment links by text then email, got %+v", routes)
+	}
+	if routes[0].Recipient != "+15552345678" || routes[0].Timezone != "America/Denver" || *routes[0].QuietHours != DefaultQuietHours {
+		t.Errorf("Expected the text to go to the normalized phone with default quiet hours, got %+v", routes[0])
+	}
+	if routes[1].Recipient != `"Jane" <jane@example.com>` || routes[1].QuietHours != nil {
+		t.Errorf("Expected the email to go to the named address without quiet hours, got %+v", routes[1])
+	}
+
+	routes = NotificationRoutes(notifications.TemplateShipmentShipped, contact, granted)
+	if len(routes) != 2 || routes[0].Channel != models.NotificationEmail {
+		t.Errorf("Expected shipping notices by email first, got %+v", routes)
+	}
+
+	routes = NotificationRoutes(notifications.TemplatePaymentLink, contact, nil)
+	if len(routes) != 1 || routes[0].Channel != models.NotificationEmail {
+		t.Errorf("Expected only email without SMS consent, got %+v", routes)
+	}
+
+	custom := &models.CommunicationPreferences{
+		SMS:        models.ChannelConsent{Status: models.ConsentGranted},
+		SMSNumber:  "+15559876543",
+		QuietHours: &models.QuietHours{Start: "22:00", End: "07:00"},
+		Channels:   map[string][]models.NotificationChannel{notifications.TemplateShipmentShipped: {models.NotificationSMS}},
+	}
+	routes = NotificationRoutes(notifications.TemplateShipmentShipped, contact, custom)
+	if len(routes) != 1 || routes[0].Recipient != "+15559876543" || routes[0].QuietHours.Start != "22:00" || routes[0].Timezone != DefaultTimezone {
+		t.Errorf("Expected the patient's channel order, number and quiet hours, got %+v", routes)
+	}
+
+	routes = NotificationRoutes(notifications.TemplatePaymentLink, PatientContact{Phone: "12"}, granted)
+	if len(routes) != 0 {
+		t.Errorf("Expected no routes without a usable email or phone, got %+v", routes)
+	}
+}
+
+// TestQuietHoursEnd tests holding texts until quiet hours end in the patient's timezone
+func TestQuietHoursEnd(t *testing.T) {
+	overnight := &models.QuietHours{Start: "21:00", End: "08:00"}
+	daytime := &models.QuietHours{Start: "12:00", End: "13:30"}
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	tests := []struct {
+		name     string
+		quiet    *models.QuietHours
+		timezone string
+		now      time.Time
+		expected time.Time
+	}{
+		{"no quiet hours", nil, "America/New_York", time.Date(2024, 3, 5, 3, 0, 0, 0, newYork), time.Date(2024, 3, 5, 3, 0, 0, 0, newYork)},
+		{"daytime outside window", overnight, "America/New_York", time.Date(2024, 3, 5, 14, 0, 0, 0, newYork), time.Date(2024, 3, 5, 14, 0, 0, 0, newYork)},
+		{"late evening", overnight, "America/New_York", time.Date(2024, 3, 5, 22, 30, 0, 0, newYork), time.Date(2024, 3, 6, 8, 0, 0, 0, newYork)},
+		{"early morning", overnight, "America/New_York", time.Date(2024, 3, 5, 6, 0, 0, 0, newYork), time.Date(2024, 3, 5, 8, 0, 0, 0, newYork)},
+		{"window start", overnight, "America/New_York", time.Date(2024, 3, 5, 21, 0, 0, 0, newYork), time.Date(2024, 3, 6, 8, 0, 0, 0, newYork)},
+		{"window end", overnight, "America/New_York", time.Date(2024, 3, 5, 8, 0, 0, 0, newYork), time.Date(2024, 3, 5, 8, 0, 0, 0, newYork)},
+		{"daytime window", daytime, "America/New_York", time.Date(2024, 3, 5, 12, 15, 0, 0, newYork), time.Date(2024, 3, 5, 13, 30, 0, 0, newYork)},
+		// 02:00 UTC is 18:00 in Los Angeles, outside its quiet hours
+		{"patient timezone", overnight, "America/Los_Angeles", time.Date(2024, 3, 6, 2, 0, 0, 0, time.UTC), time.Date(2024, 3, 6, 2, 0, 0, 0, time.UTC)},
+		{"unknown timezone", overnight, "Mars/Olympus", time.Date(2024, 3, 5, 23, 0, 0, 0, newYork), time.Date(2024, 3, 6, 8, 0, 0, 0, newYork)},
+	}
+
+	for _, tt := range tests {
+		if got := QuietHoursEnd(tt.quiet, tt.timezone, tt.now); !got.Equal(tt.expected) {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, got)
+		}
+	}
+}
+
+// TestValidatePreferences tests communication preference validation
+func TestValidatePreferences(t *testing.T) {
+	tests := []struct {
+		name  string
+		prefs models.CommunicationPreferences
+		valid bool
+	}{
+		{"empty", models.CommunicationPreferences{}, true},
+		{"full", models.CommunicationPreferences{
+			Email:      models.ChannelConsent{Status: models.ConsentGranted},
+			SMS:        models.ChannelConsent{Status: models.ConsentGranted},
+			SMSNumber:  "555-234-5678",
+			Timezone:   "America/Chicago",
+			QuietHours: &models.QuietHours{Start: "22:00", End: "07:30"},
+			Channels:   map[string][]models.NotificationChannel{notifications.TemplatePaymentLink: {models.NotificationEmail}},
+		}, true},
+		{"unknown consent", models.CommunicationPreferences{SMS: models.ChannelConsent{Status: "maybe"}}, false},
+		{"bad number", models.CommunicationPreferences{SMSNumber: "12345"}, false},
+		{"unknown timezone", models.CommunicationPreferences{Timezone: "Eastern"}, false},
+		{"bad quiet hours", models.CommunicationPreferences{QuietHours: &models.QuietHours{Start: "9pm", End: "08:00"}}, false},
+		{"unknown message type", models.CommunicationPreferences{Channels: map[string][]models.NotificationChannel{"newsletter": {models.NotificationEmail}}}, false},
+		{"unknown channel", models.CommunicationPreferences{Channels: map[string][]models.NotificationChannel{notifications.TemplatePaymentLink: {"fax"}}}, false},
+		{"repeated channel", models.CommunicationPreferences{Channels: map[string][]models.NotificationChannel{notifications.TemplatePaymentLink: {models.NotificationSMS, models.NotificationSMS}}}, false},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePreferences(&tt.prefs)
+		if tt.valid && err != nil {
+			t.Errorf("%s: expected valid, got %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidPreferences) {
+			t.Errorf("%s: expected ErrInvalidPreferences, got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrCorrelationNotFound is returned when nothing was recorded under a correlation ID
+var ErrCorrelationNotFound = errors.New("nothing recorded for correlation ID")
+
+// maxTraceDocuments bounds how many documents a trace reads from each collection
+const maxTraceDocuments = 1000
+
+// traceCollections are the collections whose records a correlation trace lists, besides prescriptions
+var traceCollections = []string{
+	"adjudications",
+	"prior_authorizations",
+	"dur_reviews",
+	"payments",
+	"verifications",
+	"refunds",
+	"shipments",
+	"notifications",
+	"sagas",
+}
+
+// CorrelationService reconstructs what happened under a correlation ID
+type CorrelationService struct {
+	mongoClient *database.MongoClient
+}
+
+// NewCorrelationService creates a new correlation service
+func NewCorrelationService(mongoClient *database.MongoClient) *CorrelationService {
+	return &CorrelationService{mongoClient: mongoClient}
+}
+
+// traceDocument holds the fields a trace reads from any correlated record
+// prescription_id is a string on some collections and an ObjectID on others.
+type traceDocument struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	PrescriptionID bson.RawValue      `bson:"prescription_id"`
+	Status         string             `bson:"status"`
+	CorrelationID  string             `bson:"correlation_id"`
+	CausationID    string             `bson:"causation_id"`
+	CreatedAt      time.Time          `bson:"created_at"`
+}
+
+// Trace returns the events, prescription status changes and records of a correlation ID as one timeline
+func (s *CorrelationService) Trace(ctx context.Context, correlationID string) (*models.CorrelationTrace, error) {
+	findOpts := options.Find().SetLimit(maxTraceDocuments)
+
+	cursor, err := s.mongoClient.GetCollection("event_log").Find(ctx, bson.M{"correlation_id": correlationID},
+		options.Find().SetSort(bson.D{{Key: "occurred_at", Value: 1}}).SetLimit(maxTraceDocuments))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find events: %w", err)
+	}
+	eventLog := []models.EventLogEntry{}
+	if err := cursor.All(ctx, &eventLog); err != nil {
+		return nil, fmt.Errorf("failed to decode events: %w", err)
+	}
+
+	cursor, err = s.mongoClient.GetCollection("prescriptions").Find(ctx, bson.M{"$or": []bson.M{
+		{"correlation_id": correlationID},
+		{"status_history.correlation_id": correlationID},
+	}}, findOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find prescriptions: %w", err)
+	}
+	prescriptions := []models.Prescription{}
+	if err := cursor.All(ctx, &prescriptions); err != nil {
+		return nil, fmt.Errorf("failed to decode prescriptions: %w", err)
+	}
+
+	records := []models.TraceRecord{}
+	for _, collection := range traceCollections {
+		cursor, err := s.mongoClient.GetCollection(collection).Find(ctx, bson.M{"correlation_id": correlationID}, findOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find %s: %w", collection, err)
+		}
+		var docs []traceDocument
+		if err := cursor.All(ctx, &docs); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", collection, err)
+		}
+		for _, doc := range docs {
+			records = append(records, models.TraceRecord{
+				Collection:     collection,
+				ID:             doc.ID.Hex(),
+				PrescriptionID: rawPrescriptionID(doc.PrescriptionID),
+				Status:         doc.Status,
+				CorrelationID:  doc.CorrelationID,
+				CausationID:    doc.CausationID,
+				CreatedAt:      doc.CreatedAt,
+			})
+		}
+	}
+
+	if len(eventLog) == 0 && len(prescriptions) == 0 && len(records) == 0 {
+		return nil, ErrCorrelationNotFound
+	}
+	return BuildCorrelationTimeline(correlationID, eventLog, prescriptions, records), nil
+}
+
+// rawPrescriptionID returns a prescription ID stored as a string or an ObjectID as a hex string
+func rawPrescriptionID(value bson.RawValue) string {
+	switch value.Type {
+	case bsontype.String:
+		return value.StringValue()
+	case bsontype.ObjectID:
+		return value.ObjectID().Hex()
+	}
+	return ""
+}
+
+// BuildCorrelationTimeline merges a correlation ID's events, the status changes recorded under it and
+// its records into one timeline, ordered by time. Each entry's depth follows causation IDs back through
+// the events to the one that started the flow; an entry whose cause was not logged starts at 0.
+func BuildCorrelationTimeline(
+	correlationID string,
+	eventLog []models.EventLogEntry,
+	prescriptions []models.Prescription,
+	records []models.TraceRecord,
+) *models.CorrelationTrace {
+	causes := make(map[string]string, len(eventLog))
+	for _, event := range eventLog {
+		causes[event.EventID] = event.CausationID
+	}
+	eventDepth := func(eventID string) int {
+		depth := 0
+		seen := map[string]bool{eventID: true}
+		for id := eventID; ; depth++ {
+			cause := causes[id]
+			// seen guards against a causation cycle in corrupt data
+			if _, logged := causes[cause]; !logged || seen[cause] {
+				return depth
+			}
+			seen[cause] = true
+			id = cause
+		}
+	}
+	causedDepth := func(causationID string) int {
+		if _, ok := causes[causationID]; ok {
+			return eventDepth(causationID) + 1
+		}
+		return 0
+	}
+
+	trace := &models.CorrelationTrace{
+		CorrelationID: correlationID,
+		Events:        eventLog,
+		Records:       records,
+		Timeline:      []models.TimelineEntry{},
+		Prescriptions: []string{},
+	}
+	if trace.Events == nil {
+		trace.Events = []models.EventLogEntry{}
+	}
+	if trace.Records == nil {
+		trace.Records = []models.TraceRecord{}
+	}
+
+	prescriptionIDs := map[string]bool{}
+	for _, event := range eventLog {
+		trace.Timeline = append(trace.Timeline, models.TimelineEntry{
+			At:             event.OccurredAt,
+			Kind:           models.TimelineEvent,
+			Topic:          event.Topic,
+			ID:             event.EventID,
+			PrescriptionID: event.PrescriptionID,
+			Producer:       event.Producer,
+			CausationID:    event.CausationID,
+			Depth:          eventDepth(event.EventID),
+		})
+		if event.PrescriptionID != "" {
+			prescriptionIDs[event.PrescriptionID] = true
+		}
+	}
+	for _, prescription := range prescriptions {
+		prescriptionIDs[prescription.ID.Hex()] = true
+		for _, change := range prescription.StatusHistory {
+			if change.CorrelationID != correlationID {
+				continue
+			}
+			trace.Timeline = append(trace.Timeline, models.TimelineEntry{
+				At:             change.At,
+				Kind:           models.TimelineStatusChange,
+				Collection:     "prescriptions",
+				ID:             prescription.ID.Hex(),
+				PrescriptionID: prescription.ID.Hex(),
+				Status:         string(change.Status),
+				Producer:       change.Producer,
+				CausationID:    change.CausationID,
+				Depth:          causedDepth(change.CausationID),
+			})
+		}
+	}
+	for _, record := range records {
+		trace.Timeline = append(trace.Timeline, models.TimelineEntry{
+			At:             record.CreatedAt,
+			Kind:           models.TimelineRecord,
+			Collection:     record.Collection,
+			ID:             record.ID,
+			PrescriptionID: record.PrescriptionID,
+			Status:         record.Status,
+			CausationID:    record.CausationID,
+			Depth:          causedDepth(record.CausationID),
+		})
+		if record.PrescriptionID != "" {
+			prescriptionIDs[record.PrescriptionID] = true
+		}
+	}
+
+	// Entries at the same instant keep causes ahead of what they caused
+	sort.SliceStable(trace.Timeline, func(i, j int) bool {
+		a, b := trace.Timeline[i], trace.Timeline[j]
+		if !a.At.Equal(b.At) {
+			return a.At.Before(b.At)
+		}
+		return a.Depth < b.Depth
+	})
+
+	for id := range prescriptionIDs {
+		trace.Prescriptions = append(trace.Prescriptions, id)
+	}
+	sort.Strings(trace.Prescriptions)
+	return trace
+}
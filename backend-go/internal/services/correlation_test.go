@@ -0,0 +1,105 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestPrescriptionStatusUpdate tests that a status change sets the status and records who caused it
+func TestPrescriptionStatusUpdate(t *testing.T) {
+	ctx := events.WithCause(events.WithProducer(context.Background(), "PaymentWorker"), events.Envelope{EventID: "evt-1", CorrelationID: "corr-1"})
+
+	update := PrescriptionStatusUpdate(ctx, "corr-1", models.StatusAwaitingPayment, bson.M{"pharmacy_id": "ph-1"})
+
+	set, ok := update["$set"].(bson.M)
+	if !ok {
+		t.Fatalf("Expected a $set, got %+v", update)
+	}
+	if set["status"] != models.StatusAwaitingPayment || set["pharmacy_id"] != "ph-1" || set["updated_at"] == nil {
+		t.Errorf("Expected status, updated_at and the given fields to be set, got %+v", set)
+	}
+
+	push, ok := update["$push"].(bson.M)
+	if !ok {
+		t.Fatalf("Expected a $push, got %+v", update)
+	}
+	change, ok := push["status_history"].(models.StatusChange)
+	if !ok {
+		t.Fatalf("Expected a status change to be pushed, got %+v", push)
+	}
+	if change.Status != models.StatusAwaitingPayment || change.CorrelationID != "corr-1" || change.CausationID != "evt-1" || change.Producer != "PaymentWorker" {
+		t.Errorf("Unexpected status change: %+v", change)
+	}
+}
+
+// TestBuildCorrelationTimeline tests that events, status changes and records are merged in order with causation depth
+func TestBuildCorrelationTimeline(t *testing.T) {
+	start := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	prescriptionID := primitive.NewObjectID()
+	rx := prescriptionID.Hex()
+
+	eventLog := []models.EventLogEntry{
+		{EventID: "evt-intake", Topic: "prescription.intake.received", CorrelationID: "corr-1", PrescriptionID: rx, Producer: "api", OccurredAt: start},
+		{EventID: "evt-validated", Topic: "prescription.validation.completed", CorrelationID: "corr-1", CausationID: "evt-intake", PrescriptionID: rx, Producer: "ValidationWorker", OccurredAt: start.Add(2 * time.Second)},
+		{EventID: "evt-paid", Topic: "payment.completed", CorrelationID: "corr-1", CausationID: "evt-validated", PrescriptionID: rx, Producer: "api", OccurredAt: start.Add(4 * time.Second)},
+	}
+	prescriptions := []models.Prescription{{
+		ID: prescriptionID,
+		StatusHistory: []models.StatusChange{
+			{Status: models.StatusReceived, CorrelationID: "corr-1", Producer: "api", At: start},
+			{Status: models.StatusValidated, CorrelationID: "corr-1", CausationID: "evt-intake", Producer: "ValidationWorker", At: start.Add(time.Second)},
+			{Status: models.StatusException, CorrelationID: "corr-other", At: start.Add(3 * time.Second)},
+		},
+	}}
+	records := []models.TraceRecord{
+		{Collection: "payments", ID: "pay-1", PrescriptionID: rx, Status: "paid", CorrelationID: "corr-1", CausationID: "evt-validated", CreatedAt: start.Add(3 * time.Second)},
+	}
+
+	trace := BuildCorrelationTimeline("corr-1", eventLog, prescriptions, records)
+
+	expected := []struct {
+		kind  models.TimelineEntryKind
+		id    string
+		depth int
+	}{
+		{models.TimelineEvent, "evt-intake", 0},
+		{models.TimelineStatusChange, rx, 0},
+		{models.TimelineStatusChange, rx, 1},
+		{models.TimelineEvent, "evt-validated", 1},
+		{models.TimelineRecord, "pay-1", 2},
+		{models.TimelineEvent, "evt-paid", 2},
+	}
+	if len(trace.Timeline) != len(expected) {
+		t.Fatalf("Expected %d timeline entries, got %d: %+v", len(expected), len(trace.Timeline), trace.Timeline)
+	}
+	for i, want := range expected {
+		got := trace.Timeline[i]
+		if got.Kind != want.kind || got.ID != want.id || got.Depth != want.depth {
+			t.Errorf("Entry %d: expected %s %s at depth %d, got %s %s at depth %d", i, want.kind, want.id, want.depth, got.Kind, got.ID, got.Depth)
+		}
+	}
+	if len(trace.Prescriptions) != 1 || trace.Prescriptions[0] != rx {
+		t.Errorf("Expected prescription %s, got %v", rx, trace.Prescriptions)
+	}
+}
+
+// TestBuildCorrelationTimeline_CausationCycle tests that corrupt causation IDs do not loop forever
+func TestBuildCorrelationTimeline_CausationCycle(t *testing.T) {
+	at := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	eventLog := []models.EventLogEntry{
+		{EventID: "a", CausationID: "b", OccurredAt: at},
+		{EventID: "b", CausationID: "a", OccurredAt: at},
+	}
+
+	trace := BuildCorrelationTimeline("corr-1", eventLog, nil, nil)
+	if len(trace.Timeline) != 2 || trace.Records == nil || len(trace.Prescriptions) != 0 {
+		t.Errorf("Unexpected trace: %+v", trace)
+	}
+}
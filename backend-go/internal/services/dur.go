@@ -0,0 +1,823 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrDURReviewNotFound is returned when no DUR review has the requested ID
+	ErrDURReviewNotFound = errors.New("DUR review not found")
+	// ErrDURReviewConflict is returned when the DUR review was decided concurrently
+	ErrDURReviewConflict = errors.New("DUR review decided concurrently")
+	// ErrInvalidDURDecision is returned when a decision is not allowed or is missing details
+	ErrInvalidDURDecision = errors.New("invalid DUR review decision")
+	// ErrInvalidAllergies is returned when recorded allergies fail validation
+	ErrInvalidAllergies = errors.New("invalid allergies")
+)
+
+const (
+	// durLookbackDays bounds how far back the patient's prescriptions are read to find the active ones
+	durLookbackDays = 365
+	// defaultDURDaysSupply is assumed for prescriptions written without a days supply
+	defaultDURDaysSupply = 30
+)
+
+// DURDrug is a drug drug utilization review compares: the prescribed one or one of the patient's active prescriptions
+type DURDrug struct {
+	PrescriptionID string
+	Name           string
+	Keys           []models.DURKey
+}
+
+// DURReference is the part of the reference tables that applies to the drugs and allergies being checked
+type DURReference struct {
+	Interactions []models.DURInteraction
+	Duplications []models.DURDuplication
+	AllergyRules []models.DURAllergyRule
+}
+
+// DURReviewFilter narrows a DUR review listing
+type DURReviewFilter struct {
+	Status         models.DURReviewStatus
+	Severity       models.DURSeverity
+	PrescriptionID string
+	Limit          int64
+}
+
+// DURDecision is a pharmacist's decision on a DUR review
+type DURDecision struct {
+	Status models.DURReviewStatus // approved or rejected
+	By     string
+	Note   string // required to reject
+}
+
+// DURService runs drug utilization review and tracks the pharmacist review queue
+type DURService struct {
+	mongoClient *database.MongoClient
+}
+
+// NewDURService creates a new DUR service
+func NewDURService(mongoClient *database.MongoClient) *DURService {
+	return &DURService{
+		mongoClient: mongoClient,
+	}
+}
+
+// Check reviews a prescription's drug against the patient's active prescriptions and recorded allergies
+// A drug with no ingredient or class data cannot be checked and gets status unknown.
+func (s *DURService) Check(ctx context.Context, rx *models.Prescription, now time.Time) (*models.DURCheck, error) {
+	drugs, err := s.drugs(ctx, []string{rx.Medication.NDC})
+	if err != nil {
+		return nil, err
+	}
+	drug, ok := drugs[rx.Medication.NDC]
+	if !ok || len(drug.DURKeys()) == 0 {
+		return &models.DURCheck{Status: models.DURStatusUnknown, CheckedAt: now}, nil
+	}
+	prescribed := DURDrug{PrescriptionID: rx.ID.Hex(), Name: durDrugName(rx.Medication, drug), Keys: drug.DURKeys()}
+
+	patientID := durPatientID(rx)
+	active, err := s.activeDrugs(ctx, rx, patientID, now)
+	if err != nil {
+		return nil, err
+	}
+	allergies, err := s.Allergies(ctx, patientID)
+	if err != nil && !errors.Is(err, ErrPatientNotFound) {
+		return nil, err
+	}
+
+	reference, err := s.reference(ctx, prescribed, allergies)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := EvaluateDUR(prescribed, active, allergies, reference)
+	return NewDURCheck(findings, len(active), len(allergies), now), nil
+}
+
+// drugs returns the drug reference data of the NDCs, keyed by NDC; unknown NDCs are left out
+func (s *DURService) drugs(ctx context.Context, ndcs []string) (map[string]*models.Drug, error) {
+	cursor, err := s.mongoClient.GetCollection("drugs").Find(ctx, bson.M{"ndc": bson.M{"$in": ndcs}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query drugs: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var drugs []models.Drug
+	if err := cursor.All(ctx, &drugs); err != nil {
+		return nil, fmt.Errorf("failed to decode drugs: %w", err)
+	}
+	byNDC := make(map[string]*models.Drug, len(drugs))
+	for i := range drugs {
+		byNDC[drugs[i].NDC] = &drugs[i]
+	}
+	return byNDC, nil
+}
+
+// activeDrugs returns the drugs of the patient's other active prescriptions that have ingredient or class data
+func (s *DURService) activeDrugs(ctx context.Context, rx *models.Prescription, patientID string, now time.Time) ([]DURDrug, error) {
+	if patientID == "" {
+		return []DURDrug{}, nil
+	}
+
+	cursor, err := s.mongoClient.GetCollection("prescriptions").Find(ctx, bson.M{
+		"_id":            bson.M{"$ne": rx.ID},
+		"$or":            bson.A{bson.M{"patient_id": patientID}, bson.M{"patient.id": patientID}},
+		"status":         bson.M{"$ne": models.StatusValidationFailed},
+		"exception.type": bson.M{"$ne": models.ExceptionDURRejected},
+		"created_at":     bson.M{"$gte": now.AddDate(0, 0, -durLookbackDays)},
+	}, options.Find().SetProjection(bson.M{"original_payload": 0, "status_history": 0}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prescriptions of patient %s: %w", patientID, err)
+	}
+	defer cursor.Close(ctx)
+
+	var prescriptions []models.Prescription
+	if err := cursor.All(ctx, &prescriptions); err != nil {
+		return nil, fmt.Errorf("failed to decode prescriptions of patient %s: %w", patientID, err)
+	}
+	prescriptions = ActiveDURPrescriptions(prescriptions, now)
+
+	ndcs := make([]string, 0, len(prescriptions))
+	for _, p := range prescriptions {
+		ndcs = append(ndcs, p.Medication.NDC)
+	}
+	drugs, err := s.drugs(ctx, ndcs)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]DURDrug, 0, len(prescriptions))
+	for _, p := range prescriptions {
+		drug, ok := drugs[p.Medication.NDC]
+		if !ok || len(drug.DURKeys()) == 0 {
+			continue
+		}
+		active = append(active, DURDrug{PrescriptionID: p.ID.Hex(), Name: durDrugName(p.Medication, drug), Keys: drug.DURKeys()})
+	}
+	return active, nil
+}
+
+// reference loads the reference table rows that could match the prescribed drug or the patient's allergies
+func (s *DURService) reference(ctx context.Context, prescribed DURDrug, allergies []models.Allergy) (DURReference, error) {
+	values := make([]string, 0, len(prescribed.Keys))
+	classes := []string{}
+	for _, key := range prescribed.Keys {
+		values = append(values, key.Value)
+		if key.Type == models.DURKeyClass {
+			classes = append(classes, key.Value)
+		}
+	}
+	allergens := make([]string, 0, len(allergies))
+	for _, allergy := range allergies {
+		allergens = append(allergens, allergy.Allergen)
+	}
+
+	var reference DURReference
+	err := s.findAll(ctx, "dur_interactions", bson.M{"$or": bson.A{
+		bson.M{"a.value": bson.M{"$in": values}},
+		bson.M{"b.value": bson.M{"$in": values}},
+	}}, &reference.Interactions)
+	if err != nil {
+		return DURReference{}, err
+	}
+	if err := s.findAll(ctx, "dur_duplications", bson.M{"class": bson.M{"$in": classes}}, &reference.Duplications); err != nil {
+		return DURReference{}, err
+	}
+	if len(allergens) > 0 {
+		if err := s.findAll(ctx, "dur_allergy_rules", bson.M{"allergen": bson.M{"$in": allergens}}, &reference.AllergyRules); err != nil {
+			return DURReference{}, err
+		}
+	}
+	return reference, nil
+}
+
+// findAll decodes every document of a collection matching a query into results
+func (s *DURService) findAll(ctx context.Context, collection string, query bson.M, results interface{}) error {
+	cursor, err := s.mongoClient.GetCollection(collection).Find(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", collection, err)
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, results); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", collection, err)
+	}
+	return nil
+}
+
+// UpsertReference inserts or replaces reference table rows keyed by what they match
+func (s *DURService) UpsertReference(ctx context.Context, reference DURReference) error {
+	upsert := options.Replace().SetUpsert(true)
+
+	interactions := s.mongoClient.GetCollection("dur_interactions")
+	for _, row := range reference.Interactions {
+		filter := bson.M{"a.type": row.A.Type, "a.value": row.A.Value, "b.type": row.B.Type, "b.value": row.B.Value}
+		if _, err := interactions.ReplaceOne(ctx, filter, row, upsert); err != nil {
+			return fmt.Errorf("failed to upsert interaction %s/%s: %w", row.A, row.B, err)
+		}
+	}
+
+	duplications := s.mongoClient.GetCollection("dur_duplications")
+	for _, row := range reference.Duplications {
+		if _, err := duplications.ReplaceOne(ctx, bson.M{"class": row.Class}, row, upsert); err != nil {
+			return fmt.Errorf("failed to upsert duplication of class %s: %w", row.Class, err)
+		}
+	}
+
+	allergyRules := s.mongoClient.GetCollection("dur_allergy_rules")
+	for _, row := range reference.AllergyRules {
+		filter := bson.M{"allergen": row.Allergen, "key.type": row.Key.Type, "key.value": row.Key.Value}
+		if _, err := allergyRules.ReplaceOne(ctx, filter, row, upsert); err != nil {
+			return fmt.Errorf("failed to upsert allergy rule %s/%s: %w", row.Allergen, row.Key, err)
+		}
+	}
+	return nil
+}
+
+// Allergies returns a patient's recorded allergies
+func (s *DURService) Allergies(ctx context.Context, patientID string) ([]models.Allergy, error) {
+	patient, err := s.patient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+	if patient.Allergies == nil {
+		return []models.Allergy{}, nil
+	}
+	return patient.Allergies, nil
+}
+
+// SetAllergies replaces a patient's recorded allergies
+// Allergies already on record keep who recorded them and when; new ones are recorded as made by the user.
+func (s *DURService) SetAllergies(ctx context.Context, patientID string, allergies []models.Allergy, by string) ([]models.Allergy, error) {
+	allergies, err := NormalizeAllergies(allergies)
+	if err != nil {
+		return nil, err
+	}
+	patient, err := s.patient(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	allergies = StampAllergies(patient.Allergies, allergies, by, now)
+	allergens := make([]string, len(allergies))
+	for i, allergy := range allergies {
+		allergens[i] = allergy.Allergen
+	}
+	err = audit.Record(ctx, audit.Entry{
+		EventType:  audit.EventAllergiesChanged,
+		EntityType: audit.EntityPatient,
+		EntityID:   patient.ID.Hex(),
+		Action:     audit.ActionUpdate,
+		Details:    map[string]interface{}{"allergens": allergens},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to audit allergies of patient %s: %w", patientID, err)
+	}
+
+	_, err = s.mongoClient.GetCollection("patients").UpdateOne(ctx, bson.M{"_id": patient.ID},
+		bson.M{"$set": bson.M{"allergies": allergies, "updated_at": now}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update allergies of patient %s: %w", patientID, err)
+	}
+	return allergies, nil
+}
+
+// patient loads a patient by ID
+func (s *DURService) patient(ctx context.Context, patientID string) (*models.Patient, error) {
+	objectID, err := primitive.ObjectIDFromHex(patientID)
+	if err != nil {
+		return nil, ErrPatientNotFound
+	}
+	var patient models.Patient
+	err = s.mongoClient.GetCollection("patients").FindOne(ctx, bson.M{"_id": objectID}).Decode(&patient)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPatientNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load patient: %w", err)
+	}
+	return &patient, nil
+}
+
+// OpenReview returns the prescription's pending DUR review, opening one for the check's findings if there is none
+func (s *DURService) OpenReview(ctx context.Context, rx *models.Prescription, check *models.DURCheck) (*models.DURReview, bool, error) {
+	collection := s.mongoClient.GetCollection("dur_reviews")
+
+	var existing models.DURReview
+	err := collection.FindOne(ctx, bson.M{"prescription_id": rx.ID, "status": models.DURReviewPending}).Decode(&existing)
+	if err == nil {
+		return &existing, false, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, fmt.Errorf("failed to look up pending DUR review: %w", err)
+	}
+
+	review := NewDURReview(rx, check, time.Now())
+	review.CausationID = events.CausationID(ctx)
+	review.ID = primitive.NewObjectID()
+	if err := recordDURReviewChange(ctx, review, audit.ActionCreate); err != nil {
+		return nil, false, err
+	}
+	if _, err := collection.InsertOne(ctx, review); err != nil {
+		return nil, false, fmt.Errorf("failed to create DUR review: %w", err)
+	}
+	return review, true, nil
+}
+
+// GetReview returns a DUR review by ID
+func (s *DURService) GetReview(ctx context.Context, id string) (*models.DURReview, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrDURReviewNotFound
+	}
+
+	var review models.DURReview
+	err = s.mongoClient.GetCollection("dur_reviews").FindOne(ctx, bson.M{"_id": oid}).Decode(&review)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrDURReviewNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load DUR review %s: %w", id, err)
+	}
+	return &review, nil
+}
+
+// ListReviews returns DUR reviews matching the filter, most severe first and oldest first within a severity
+func (s *DURService) ListReviews(ctx context.Context, filter DURReviewFilter) ([]models.DURReview, error) {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Severity != "" {
+		query["severity"] = filter.Severity
+	}
+	if filter.PrescriptionID != "" {
+		oid, err := primitive.ObjectIDFromHex(filter.PrescriptionID)
+		if err != nil {
+			return []models.DURReview{}, nil
+		}
+		query["prescription_id"] = oid
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "severity_rank", Value: -1}, {Key: "created_at", Value: 1}}).SetLimit(filter.Limit)
+	cursor, err := s.mongoClient.GetCollection("dur_reviews").Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list DUR reviews: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	reviews := []models.DURReview{}
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, fmt.Errorf("failed to decode DUR reviews: %w", err)
+	}
+	return reviews, nil
+}
+
+// Decide records a pharmacist's decision on a pending DUR review
+// The same decision posted again by the same pharmacist returns the review as decided, so its
+// follow-up can be retried. Returns ErrDURReviewConflict if the review was decided since it was loaded.
+func (s *DURService) Decide(ctx context.Context, id string, decision DURDecision) (*models.DURReview, error) {
+	review, err := s.GetReview(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if DURDecisionRepeats(review, decision) {
+		return review, nil
+	}
+	if err := ApplyDURDecision(review, decision, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := recordDURReviewChange(ctx, review, audit.ActionUpdate); err != nil {
+		return nil, err
+	}
+	result, err := s.mongoClient.GetCollection("dur_reviews").ReplaceOne(ctx, bson.M{"_id": review.ID, "status": models.DURReviewPending}, review)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update DUR review %s: %w", id, err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrDURReviewConflict
+	}
+	return review, nil
+}
+
+// recordDURReviewChange audits a DUR review being opened or decided
+// The entry is required, so it is written before the change and an error means the change is not made.
+func recordDURReviewChange(ctx context.Context, review *models.DURReview, action audit.Action) error {
+	details := map[string]interface{}{
+		"prescription_id": review.PrescriptionID.Hex(),
+		"patient_id":      review.PatientID,
+		"status":          review.Status,
+		"severity":        review.Severity,
+		"correlation_id":  review.CorrelationID,
+	}
+	err := audit.Record(ctx, audit.Entry{
+		EventType:  audit.EventDURReviewChanged,
+		EntityType: audit.EntityDURReview,
+		EntityID:   review.ID.Hex(),
+		Action:     action,
+		Details:    details,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to audit DUR review %s: %w", review.ID.Hex(), err)
+	}
+	return nil
+}
+
+// durPatientID returns the patient record a prescription belongs to
+func durPatientID(rx *models.Prescription) string {
+	if rx.PatientID != "" {
+		return rx.PatientID
+	}
+	return rx.Patient.ID
+}
+
+// durDrugName names a drug in findings: the drug reference name, or the prescribed name without one
+func durDrugName(medication models.MedicationInfo, drug *models.Drug) string {
+	if drug != nil && drug.Name != "" {
+		return drug.Name
+	}
+	if medication.Name != "" {
+		return medication.Name
+	}
+	return medication.NDC
+}
+
+// ActiveDURPrescriptions filters prescriptions to those the patient may still be taking
+// A prescription covers its days supply for the fill and each refill from when it was written; a
+// prescription without a days supply is assumed to cover 30 days per fill.
+func ActiveDURPrescriptions(prescriptions []models.Prescription, now time.Time) []models.Prescription {
+	active := make([]models.Prescription, 0, len(prescriptions))
+	for _, p := range prescriptions {
+		if p.Status == models.StatusValidationFailed {
+			continue
+		}
+		if p.Exception != nil && p.Exception.Type == models.ExceptionDURRejected {
+			continue
+		}
+		daysSupply := p.Medication.DaysSupply
+		if daysSupply <= 0 {
+			daysSupply = defaultDURDaysSupply
+		}
+		fills := p.Medication.Refills + 1
+		if p.CreatedAt.AddDate(0, 0, daysSupply*fills).After(now) {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+// EvaluateDUR checks a prescribed drug against the patient's active drugs and allergies
+// Findings come from the reference tables, plus two that need no table: a drug sharing an ingredient
+// with an active one duplicates therapy (major), and a drug whose ingredient or class is a recorded
+// allergen is contraindicated. Findings are ordered most severe first.
+func EvaluateDUR(prescribed DURDrug, active []DURDrug, allergies []models.Allergy, reference DURReference) []models.DURFinding {
+	findings := []models.DURFinding{}
+	seen := map[string]bool{}
+	add := func(finding models.DURFinding) {
+		id := strings.Join([]string{string(finding.Type), finding.Key, finding.OtherPrescriptionID, finding.Allergen, finding.Description}, "|")
+		if !seen[id] {
+			seen[id] = true
+			findings = append(findings, finding)
+		}
+	}
+	prescribedKeys := durKeySet(prescribed.Keys)
+
+	for _, other := range active {
+		otherKeys := durKeySet(other.Keys)
+
+		for _, row := range reference.Interactions {
+			var matched models.DURKey
+			switch {
+			case prescribedKeys[row.A] && otherKeys[row.B]:
+				matched = row.A
+			case prescribedKeys[row.B] && otherKeys[row.A]:
+				matched = row.B
+			default:
+				continue
+			}
+			add(models.DURFinding{
+				Type:                models.DURDrugInteraction,
+				Severity:            row.Severity,
+				Description:         row.Description,
+				Key:                 matched.String(),
+				OtherPrescriptionID: other.PrescriptionID,
+				OtherDrug:           other.Name,
+			})
+		}
+
+		for _, key := range prescribed.Keys {
+			if key.Type == models.DURKeyIngredient && otherKeys[key] {
+				add(models.DURFinding{
+					Type:                models.DURTherapeuticDuplication,
+					Severity:            models.DURSeverityMajor,
+					Description:         fmt.Sprintf("%s is also an ingredient of %s", key.Value, other.Name),
+					Key:                 key.String(),
+					OtherPrescriptionID: other.PrescriptionID,
+					OtherDrug:           other.Name,
+				})
+			}
+		}
+
+		for _, row := range reference.Duplications {
+			class := models.DURKey{Type: models.DURKeyClass, Value: row.Class}
+			if prescribedKeys[class] && otherKeys[class] {
+				add(models.DURFinding{
+					Type:                models.DURTherapeuticDuplication,
+					Severity:            row.Severity,
+					Description:         row.Description,
+					Key:                 class.String(),
+					OtherPrescriptionID: other.PrescriptionID,
+					OtherDrug:           other.Name,
+				})
+			}
+		}
+	}
+
+	for _, allergy := range allergies {
+		allergen := normalizeDURValue(allergy.Allergen)
+		for _, key := range prescribed.Keys {
+			if key.Value == allergen {
+				add(models.DURFinding{
+					Type:        models.DURAllergyConflict,
+					Severity:    models.DURSeverityContraindicated,
+					Description: fmt.Sprintf("Patient is allergic to %s", allergen),
+					Key:         key.String(),
+					Allergen:    allergen,
+				})
+			}
+		}
+		for _, row := range reference.AllergyRules {
+			if row.Allergen == allergen && prescribedKeys[row.Key] {
+				add(models.DURFinding{
+					Type:        models.DURAllergyConflict,
+					Severity:    row.Severity,
+					Description: row.Description,
+					Key:         row.Key.String(),
+					Allergen:    allergen,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity.Rank() > findings[j].Severity.Rank()
+	})
+	return findings
+}
+
+// NewDURCheck builds the DUR check recorded on a prescription from its findings
+func NewDURCheck(findings []models.DURFinding, activePrescriptions, allergies int, at time.Time) *models.DURCheck {
+	check := &models.DURCheck{
+		Status:              models.DURStatusClear,
+		ActivePrescriptions: activePrescriptions,
+		Allergies:           allergies,
+		CheckedAt:           at,
+	}
+	if len(findings) == 0 {
+		return check
+	}
+
+	check.Status = models.DURStatusFlagged
+	check.Findings = findings
+	for _, finding := range findings {
+		if finding.Severity.Rank() > check.Severity.Rank() {
+			check.Severity = finding.Severity
+		}
+	}
+	return check
+}
+
+// DURValidationFlags returns the validation flags of a DUR check's findings
+func DURValidationFlags(check *models.DURCheck) []string {
+	flags := make([]string, 0, len(check.Findings))
+	for _, finding := range check.Findings {
+		flags = append(flags, fmt.Sprintf("%s: %s (%s)", finding.Type, finding.Description, finding.Severity))
+	}
+	return flags
+}
+
+// NewDURReview builds a pending DUR review of a prescription's findings
+// It continues the prescription's correlation ID.
+func NewDURReview(rx *models.Prescription, check *models.DURCheck, at time.Time) *models.DURReview {
+	return &models.DURReview{
+		PrescriptionID: rx.ID,
+		PatientID:      durPatientID(rx),
+		NDC:            rx.Medication.NDC,
+		DrugName:       rx.Medication.Name,
+		Status:         models.DURReviewPending,
+		Severity:       check.Severity,
+		SeverityRank:   check.Severity.Rank(),
+		Findings:       check.Findings,
+		CorrelationID:  rx.CorrelationID,
+		CreatedAt:      at,
+		UpdatedAt:      at,
+	}
+}
+
+// ApplyDURDecision validates a pharmacist's decision and applies it to a pending review
+func ApplyDURDecision(review *models.DURReview, decision DURDecision, at time.Time) error {
+	if review.Status != models.DURReviewPending {
+		return fmt.Errorf("%w: review is already %s", ErrInvalidDURDecision, review.Status)
+	}
+	note := strings.TrimSpace(decision.Note)
+	switch decision.Status {
+	case models.DURReviewApproved:
+	case models.DURReviewRejected:
+		if note == "" {
+			return fmt.Errorf("%w: note is required to reject", ErrInvalidDURDecision)
+		}
+	default:
+		return fmt.Errorf("%w: decision must be approved or rejected", ErrInvalidDURDecision)
+	}
+
+	review.Status = decision.Status
+	review.DecidedBy = decision.By
+	review.DecidedAt = &at
+	review.Note = note
+	review.UpdatedAt = at
+	return nil
+}
+
+// NormalizeAllergies validates recorded allergies, lowercasing allergens and dropping duplicates
+func NormalizeAllergies(allergies []models.Allergy) ([]models.Allergy, error) {
+	normalized := make([]models.Allergy, 0, len(allergies))
+	seen := map[string]bool{}
+	for i, allergy := range allergies {
+		allergy.Allergen = normalizeDURValue(allergy.Allergen)
+		allergy.Reaction = strings.TrimSpace(allergy.Reaction)
+		if allergy.Allergen == "" {
+			return nil, fmt.Errorf("%w: allergies[%d].allergen is required", ErrInvalidAllergies, i)
+		}
+		if len(allergy.Allergen) > 100 || len(allergy.Reaction) > 200 {
+			return nil, fmt.Errorf("%w: allergies[%d] is too long", ErrInvalidAllergies, i)
+		}
+		if seen[allergy.Allergen] {
+			continue
+		}
+		seen[allergy.Allergen] = true
+		normalized = append(normalized, allergy)
+	}
+	return normalized, nil
+}
+
+// StampAllergies records who recorded each allergy and when, keeping both for allergens already on record
+func StampAllergies(previous, allergies []models.Allergy, by string, at time.Time) []models.Allergy {
+	recorded := make(map[string]models.Allergy, len(previous))
+	for _, allergy := range previous {
+		recorded[allergy.Allergen] = allergy
+	}
+	for i := range allergies {
+		if existing, ok := recorded[allergies[i].Allergen]; ok {
+			allergies[i].RecordedBy = existing.RecordedBy
+			allergies[i].RecordedAt = existing.RecordedAt
+			continue
+		}
+		allergies[i].RecordedBy = by
+		allergies[i].RecordedAt = at
+	}
+	return allergies
+}
+
+// durKeySet returns the keys as a set
+func durKeySet(keys []models.DURKey) map[models.DURKey]bool {
+	set := make(map[models.DURKey]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+	return set
+}
+
+// normalizeDURValue lowercases and trims an ingredient, class or allergen name
+func normalizeDURValue(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// ParseDURInteractionsCSV parses drug–drug interactions from CSV with the header
+// a_type,a,b_type,b,severity,description
+func ParseDURInteractionsCSV(r io.Reader) ([]models.DURInteraction, error) {
+	rows, err := readCSVRows(r, "a_type", "a", "b_type", "b", "severity", "description")
+	if err != nil {
+		return nil, err
+	}
+
+	interactions := make([]models.DURInteraction, 0, len(rows))
+	for i, row := range rows {
+		line := i + 2 // header is line 1
+
+		a, err := parseDURKey(row["a_type"], row["a"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: a: %w", line, err)
+		}
+		b, err := parseDURKey(row["b_type"], row["b"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: b: %w", line, err)
+		}
+		severity, err := parseDURSeverity(row["severity"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		interactions = append(interactions, models.DURInteraction{A: a, B: b, Severity: severity, Description: row["description"]})
+	}
+
+	return interactions, nil
+}
+
+// ParseDURDuplicationsCSV parses therapeutic duplication classes from CSV with the header
+// class,severity,description
+func ParseDURDuplicationsCSV(r io.Reader) ([]models.DURDuplication, error) {
+	rows, err := readCSVRows(r, "class", "severity", "description")
+	if err != nil {
+		return nil, err
+	}
+
+	duplications := make([]models.DURDuplication, 0, len(rows))
+	for i, row := range rows {
+		line := i + 2 // header is line 1
+
+		severity, err := parseDURSeverity(row["severity"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		duplications = append(duplications, models.DURDuplication{
+			Class:       normalizeDURValue(row["class"]),
+			Severity:    severity,
+			Description: row["description"],
+		})
+	}
+
+	return duplications, nil
+}
+
+// ParseDURAllergyRulesCSV parses allergy cross-sensitivities from CSV with the header
+// allergen,key_type,key,severity,description
+func ParseDURAllergyRulesCSV(r io.Reader) ([]models.DURAllergyRule, error) {
+	rows, err := readCSVRows(r, "allergen", "key_type", "key", "severity", "description")
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]models.DURAllergyRule, 0, len(rows))
+	for i, row := range rows {
+		line := i + 2 // header is line 1
+
+		key, err := parseDURKey(row["key_type"], row["key"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		severity, err := parseDURSeverity(row["severity"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		rules = append(rules, models.DURAllergyRule{
+			Allergen:    normalizeDURValue(row["allergen"]),
+			Key:         key,
+			Severity:    severity,
+			Description: row["description"],
+		})
+	}
+
+	return rules, nil
+}
+
+// parseDURKey parses an ingredient or class key column pair
+func parseDURKey(keyType, value string) (models.DURKey, error) {
+	key := models.DURKey{Type: models.DURKeyType(strings.ToLower(keyType)), Value: normalizeDURValue(value)}
+	if key.Type != models.DURKeyIngredient && key.Type != models.DURKeyClass {
+		return models.DURKey{}, fmt.Errorf("unknown key type %q (want ingredient or class)", keyType)
+	}
+	return key, nil
+}
+
+// parseDURSeverity parses a severity column
+func parseDURSeverity(value string) (models.DURSeverity, error) {
+	severity := models.DURSeverity(strings.ToLower(value))
+	if severity.Rank() == 0 {
+		return "", fmt.Errorf("unknown severity %q", value)
+	}
+	return severity, nil
+}
@@ -0,0 +1,225 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+// durIngredient returns an ingredient key
+func durIngredient(value string) models.DURKey {
+	return models.DURKey{Type: models.DURKeyIngredient, Value: value}
+}
+
+// durClass returns a class key
+func durClass(value string) models.DURKey {
+	return models.DURKey{Type: models.DURKeyClass, Value: value}
+}
+
+// TestEvaluateDUR tests interactions, duplications and allergy conflicts, ordered most severe first
+func TestEvaluateDUR(t *testing.T) {
+	prescribed := DURDrug{PrescriptionID: "rx_new", Name: "Ibuprofen", Keys: []models.DURKey{durIngredient("ibuprofen"), durClass("nsaid")}}
+	active := []DURDrug{
+		{PrescriptionID: "rx_1", Name: "Warfarin", Keys: []models.DURKey{durIngredient("warfarin"), durClass("anticoagulant")}},
+		{PrescriptionID: "rx_2", Name: "Naproxen", Keys: []models.DURKey{durIngredient("naproxen"), durClass("nsaid")}},
+		{PrescriptionID: "rx_3", Name: "Advil PM", Keys: []models.DURKey{durIngredient("ibuprofen"), durIngredient("diphenhydramine")}},
+	}
+	reference := DURReference{
+		// listed in the other direction, so matching must be symmetric
+		Interactions: []models.DURInteraction{{A: durClass("anticoagulant"), B: durClass("nsaid"), Severity: models.DURSeverityMajor, Description: "bleeding risk"}},
+		Duplications: []models.DURDuplication{{Class: "nsaid", Severity: models.DURSeverityModerate, Description: "two NSAIDs"}},
+		AllergyRules: []models.DURAllergyRule{{Allergen: "aspirin", Key: durClass("nsaid"), Severity: models.DURSeverityMajor, Description: "NSAID cross-sensitivity"}},
+	}
+	allergies := []models.Allergy{{Allergen: "Aspirin"}, {Allergen: "ibuprofen"}}
+
+	findings := EvaluateDUR(prescribed, active, allergies, reference)
+
+	type key struct {
+		Type  models.DURFindingType
+		Other string
+		Key   string
+	}
+	got := map[key]models.DURSeverity{}
+	for _, f := range findings {
+		got[key{f.Type, f.OtherPrescriptionID + f.Allergen, f.Key}] = f.Severity
+	}
+	want := map[key]models.DURSeverity{
+		{models.DURDrugInteraction, "rx_1", "class:nsaid"}:                 models.DURSeverityMajor,
+		{models.DURTherapeuticDuplication, "rx_2", "class:nsaid"}:          models.DURSeverityModerate,
+		{models.DURTherapeuticDuplication, "rx_3", "ingredient:ibuprofen"}: models.DURSeverityMajor,
+		{models.DURAllergyConflict, "aspirin", "class:nsaid"}:              models.DURSeverityMajor,
+		{models.DURAllergyConflict, "ibuprofen", "ingredient:ibuprofen"}:   models.DURSeverityContraindicated,
+	}
+	if len(got) != len(want) || len(findings) != len(want) {
+		t.Fatalf("Expected %d findings, got %d: %+v", len(want), len(findings), findings)
+	}
+	for k, severity := range want {
+		if got[k] != severity {
+			t.Errorf("Expected %v to be %s, got %q", k, severity, got[k])
+		}
+	}
+
+	for i := 1; i < len(findings); i++ {
+		if findings[i].Severity.Rank() > findings[i-1].Severity.Rank() {
+			t.Errorf("Expected findings ordered most severe first, got %s after %s", findings[i].Severity, findings[i-1].Severity)
+		}
+	}
+}
+
+// TestEvaluateDUR_Clear tests that unrelated drugs and no allergies give no findings
+func TestEvaluateDUR_Clear(t *testing.T) {
+	prescribed := DURDrug{Name: "Amoxicillin", Keys: []models.DURKey{durIngredient("amoxicillin"), durClass("penicillin")}}
+	active := []DURDrug{{PrescriptionID: "rx_1", Name: "Lisinopril", Keys: []models.DURKey{durIngredient("lisinopril"), durClass("ace inhibitor")}}}
+	reference := DURReference{
+		Interactions: []models.DURInteraction{{A: durClass("anticoagulant"), B: durClass("nsaid"), Severity: models.DURSeverityMajor}},
+	}
+
+	findings := EvaluateDUR(prescribed, active, nil, reference)
+	if len(findings) != 0 {
+		t.Errorf("Expected no findings, got %+v", findings)
+	}
+
+	check := NewDURCheck(findings, len(active), 0, time.Now())
+	if check.Status != models.DURStatusClear || check.Severity != "" {
+		t.Errorf("Expected a clear check, got %+v", check)
+	}
+}
+
+// TestNewDURCheck tests that a check with findings is flagged at its most severe finding
+func TestNewDURCheck(t *testing.T) {
+	findings := []models.DURFinding{
+		{Type: models.DURTherapeuticDuplication, Severity: models.DURSeverityModerate, Description: "two NSAIDs"},
+		{Type: models.DURDrugInteraction, Severity: models.DURSeverityMajor, Description: "bleeding risk"},
+	}
+
+	check := NewDURCheck(findings, 2, 1, time.Now())
+	if check.Status != models.DURStatusFlagged || check.Severity != models.DURSeverityMajor {
+		t.Errorf("Expected a major flagged check, got %+v", check)
+	}
+
+	flags := DURValidationFlags(check)
+	if len(flags) != 2 || flags[1] != "drug_interaction: bleeding risk (major)" {
+		t.Errorf("Unexpected validation flags: %v", flags)
+	}
+}
+
+// TestActiveDURPrescriptions tests that prescriptions stay active for their days supply across refills
+func TestActiveDURPrescriptions(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	prescriptions := []models.Prescription{
+		{CorrelationID: "covered", CreatedAt: now.AddDate(0, 0, -20), Medication: models.MedicationInfo{DaysSupply: 30}},
+		{CorrelationID: "ran_out", CreatedAt: now.AddDate(0, 0, -40), Medication: models.MedicationInfo{DaysSupply: 30}},
+		{CorrelationID: "refilled", CreatedAt: now.AddDate(0, 0, -80), Medication: models.MedicationInfo{DaysSupply: 30, Refills: 2}},
+		{CorrelationID: "default_supply", CreatedAt: now.AddDate(0, 0, -10)},
+		{CorrelationID: "failed", CreatedAt: now.AddDate(0, 0, -1), Status: models.StatusValidationFailed},
+		{CorrelationID: "rejected", CreatedAt: now.AddDate(0, 0, -1), Exception: &models.PrescriptionException{Type: models.ExceptionDURRejected}},
+	}
+
+	active := ActiveDURPrescriptions(prescriptions, now)
+	var got []string
+	for _, p := range active {
+		got = append(got, p.CorrelationID)
+	}
+	if strings.Join(got, ",") != "covered,refilled,default_supply" {
+		t.Errorf("Expected covered, refilled and default_supply to be active, got %v", got)
+	}
+}
+
+// TestApplyDURDecision tests that decisions need a pending review and a note to reject
+func TestApplyDURDecision(t *testing.T) {
+	now := time.Now()
+
+	review := &models.DURReview{Status: models.DURReviewPending}
+	if err := ApplyDURDecision(review, DURDecision{Status: models.DURReviewRejected, By: "pharm_1"}, now); !errors.Is(err, ErrInvalidDURDecision) {
+		t.Errorf("Expected rejection without note to be invalid, got %v", err)
+	}
+	if err := ApplyDURDecision(review, DURDecision{Status: models.DURReviewPending, By: "pharm_1"}, now); !errors.Is(err, ErrInvalidDURDecision) {
+		t.Errorf("Expected pending decision to be invalid, got %v", err)
+	}
+
+	if err := ApplyDURDecision(review, DURDecision{Status: models.DURReviewRejected, By: "pharm_1", Note: " bleeding risk "}, now); err != nil {
+		t.Fatalf("Expected rejection, got %v", err)
+	}
+	if review.Status != models.DURReviewRejected || review.DecidedBy != "pharm_1" || review.Note != "bleeding risk" || review.DecidedAt == nil {
+		t.Errorf("Unexpected decided review: %+v", review)
+	}
+
+	if err := ApplyDURDecision(review, DURDecision{Status: models.DURReviewApproved, By: "pharm_2"}, now); !errors.Is(err, ErrInvalidDURDecision) {
+		t.Errorf("Expected deciding a decided review to be invalid, got %v", err)
+	}
+}
+
+// TestNormalizeAllergies tests allergen normalization, deduplication and validation
+func TestNormalizeAllergies(t *testing.T) {
+	allergies, err := NormalizeAllergies([]models.Allergy{{Allergen: " Penicillin ", Reaction: "hives"}, {Allergen: "penicillin"}, {Allergen: "Sulfa"}})
+	if err != nil {
+		t.Fatalf("Expected allergies to be valid, got %v", err)
+	}
+	if len(allergies) != 2 || allergies[0].Allergen != "penicillin" || allergies[0].Reaction != "hives" || allergies[1].Allergen != "sulfa" {
+		t.Errorf("Unexpected normalized allergies: %+v", allergies)
+	}
+
+	if _, err := NormalizeAllergies([]models.Allergy{{Allergen: " "}}); !errors.Is(err, ErrInvalidAllergies) {
+		t.Errorf("Expected blank allergen to be invalid, got %v", err)
+	}
+}
+
+// TestStampAllergies tests that allergies on record keep who recorded them
+func TestStampAllergies(t *testing.T) {
+	then := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := then.AddDate(0, 3, 0)
+	previous := []models.Allergy{{Allergen: "penicillin", RecordedBy: "agent_1", RecordedAt: then}}
+
+	allergies := StampAllergies(previous, []models.Allergy{{Allergen: "penicillin"}, {Allergen: "sulfa"}}, "pharm_1", now)
+	if allergies[0].RecordedBy != "agent_1" || !allergies[0].RecordedAt.Equal(then) {
+		t.Errorf("Expected penicillin to keep its recorder, got %+v", allergies[0])
+	}
+	if allergies[1].RecordedBy != "pharm_1" || !allergies[1].RecordedAt.Equal(now) {
+		t.Errorf("Expected sulfa to be recorded by pharm_1, got %+v", allergies[1])
+	}
+}
+
+// TestParseDURInteractionsCSV tests parsing interactions and rejecting unknown key types and severities
+func TestParseDURInteractionsCSV(t *testing.T) {
+	interactions, err := ParseDURInteractionsCSV(strings.NewReader(
+		"a_type,a,b_type,b,severity,description\n" +
+			"# comments are skipped\n" +
+			"class,Anticoagulant,class,NSAID,Major,Increased bleeding risk\n"))
+	if err != nil {
+		t.Fatalf("Expected interactions to parse, got %v", err)
+	}
+	if len(interactions) != 1 || interactions[0].A != durClass("anticoagulant") || interactions[0].B != durClass("nsaid") ||
+		interactions[0].Severity != models.DURSeverityMajor {
+		t.Errorf("Unexpected interactions: %+v", interactions)
+	}
+
+	_, err = ParseDURInteractionsCSV(strings.NewReader("a_type,a,b_type,b,severity,description\nbrand,x,class,y,major,z\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected unknown key type on line 2 to fail, got %v", err)
+	}
+	_, err = ParseDURInteractionsCSV(strings.NewReader("a_type,a,b_type,b,severity,description\nclass,x,class,y,severe,z\n"))
+	if err == nil {
+		t.Error("Expected unknown severity to fail")
+	}
+}
+
+// TestParseDURDuplicationsAndAllergyRulesCSV tests parsing duplication classes and allergy rules
+func TestParseDURDuplicationsAndAllergyRulesCSV(t *testing.T) {
+	duplications, err := ParseDURDuplicationsCSV(strings.NewReader("class,severity,description\nStatin,moderate,Two statins\n"))
+	if err != nil || len(duplications) != 1 || duplications[0].Class != "statin" {
+		t.Errorf("Unexpected duplications: %+v (%v)", duplications, err)
+	}
+
+	rules, err := ParseDURAllergyRulesCSV(strings.NewReader("allergen,key_type,key,severity,description\nPenicillin,class,Cephalosporin,moderate,Cross-sensitivity\n"))
+	if err != nil || len(rules) != 1 || rules[0].Allergen != "penicillin" || rules[0].Key != durClass("cephalosporin") {
+		t.Errorf("Unexpected allergy rules: %+v (%v)", rules, err)
+	}
+
+	if _, err := ParseDURAllergyRulesCSV(strings.NewReader("allergen,key,severity\npenicillin,x,major\n")); err == nil {
+		t.Error("Expected missing key_type column to fail")
+	}
+}
@@ -0,0 +1,180 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CompleteDURReview moves the prescription held for a decided DUR review on, returning false if it no longer waits on it
+func CompleteDURReview(
+	ctx context.Context,
+	mongoClient *database.MongoClient,
+	producer kafka.Producer,
+	correlationID string,
+	review *models.DURReview,
+) (bool, error) {
+	if review.CompletedAt != nil {
+		return false, nil
+	}
+
+	collection := mongoClient.GetCollection("prescriptions")
+	var rx models.Prescription
+	err := collection.FindOne(ctx, bson.M{"_id": review.PrescriptionID}).Decode(&rx)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load prescription: %w", err)
+	}
+
+	status, exception := durReviewOutcome(review)
+	prescriptionID := review.PrescriptionID.Hex()
+	switch {
+	case rx.Status == models.StatusAwaitingDURReview:
+		var fields bson.M
+		if exception != nil {
+			fields = bson.M{"exception": exception}
+		}
+		filter := bson.M{"_id": review.PrescriptionID, "status": models.StatusAwaitingDURReview}
+		updated, err := ChangePrescriptionStatus(ctx, collection, correlationID, filter, status, fields)
+		if err != nil {
+			return false, err
+		}
+		if updated == nil {
+			return false, nil
+		}
+	// A decision whose follow-up failed part way has its event published again
+	case DURReviewResumable(&rx, review):
+		log.Printf("🔁 [correlation_id=%s] Prescription %s already moved by DUR review %s, publishing its event again", correlationID, prescriptionID, review.ID.Hex())
+		if rx.Exception != nil {
+			exception = rx.Exception
+		}
+	default:
+		markDURReviewCompleted(ctx, mongoClient, correlationID, review)
+		return false, nil
+	}
+
+	if exception != nil {
+		if err := events.PublishException(ctx, producer, correlationID, prescriptionID, *exception); err != nil {
+			return false, fmt.Errorf("failed to publish exception event: %w", err)
+		}
+		markDURReviewCompleted(ctx, mongoClient, correlationID, review)
+		log.Printf("⛔ [correlation_id=%s] DUR review %s rejected prescription %s", correlationID, review.ID.Hex(), prescriptionID)
+		return true, nil
+	}
+
+	err = events.Publish(ctx, producer, &events.ValidationCompleted{
+		Envelope:        events.NewEnvelope(ctx, correlationID, prescriptionID),
+		PatientID:       rx.Patient.ID,
+		ValidatedAt:     time.Now(),
+		ValidationFlags: rx.ValidationFlags,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to publish validation completed event: %w", err)
+	}
+	markDURReviewCompleted(ctx, mongoClient, correlationID, review)
+	log.Printf("✅ [correlation_id=%s] DUR review %s approved prescription %s", correlationID, review.ID.Hex(), prescriptionID)
+	return true, nil
+}
+
+// ResumeDecidedDURReviews completes DUR reviews decided before a cutoff that were never marked completed
+func ResumeDecidedDURReviews(ctx context.Context, mongoClient *database.MongoClient, producer kafka.Producer, decidedBefore time.Time, limit int) (int, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "decided_at", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := mongoClient.GetCollection("dur_reviews").Find(ctx, bson.M{
+		"status":       bson.M{"$ne": models.DURReviewPending},
+		"decided_at":   bson.M{"$lt": decidedBefore},
+		"completed_at": bson.M{"$exists": false},
+	}, opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find uncompleted DUR reviews: %w", err)
+	}
+	var reviews []models.DURReview
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return 0, fmt.Errorf("failed to decode uncompleted DUR reviews: %w", err)
+	}
+
+	resumed := 0
+	for i := range reviews {
+		review := &reviews[i]
+		moved, err := CompleteDURReview(ctx, mongoClient, producer, review.CorrelationID, review)
+		if err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to resume DUR review %s: %v", review.CorrelationID, review.ID.Hex(), err)
+			continue
+		}
+		if moved {
+			resumed++
+		}
+	}
+	return resumed, nil
+}
+
+// DURReviewResumable reports whether a prescription was moved by a decided DUR review and has not moved on since
+func DURReviewResumable(rx *models.Prescription, review *models.DURReview) bool {
+	switch review.Status {
+	case models.DURReviewApproved:
+		return rx.Status == models.StatusValidated
+	case models.DURReviewRejected:
+		return rx.Status == models.StatusException && rx.Exception != nil && rx.Exception.Type == models.ExceptionDURRejected
+	default:
+		return false
+	}
+}
+
+// DURDecisionRepeats reports whether a decision repeats the one already recorded on a review
+// The same pharmacist posting the same decision again is retrying a decision whose follow-up failed.
+func DURDecisionRepeats(review *models.DURReview, decision DURDecision) bool {
+	return review.Status != models.DURReviewPending &&
+		review.Status == decision.Status &&
+		review.DecidedBy == decision.By
+}
+
+// DURFindingTypes returns the distinct finding types of a review, used as the codes of its exception
+func DURFindingTypes(review *models.DURReview) []string {
+	types := []string{}
+	seen := map[models.DURFindingType]bool{}
+	for _, finding := range review.Findings {
+		if !seen[finding.Type] {
+			seen[finding.Type] = true
+			types = append(types, string(finding.Type))
+		}
+	}
+	return types
+}
+
+// durReviewOutcome returns the status a decided review moves its prescription to, with the exception a rejection raises
+func durReviewOutcome(review *models.DURReview) (models.PrescriptionStatus, *models.PrescriptionException) {
+	if review.Status != models.DURReviewRejected {
+		return models.StatusValidated, nil
+	}
+	return models.StatusException, &models.PrescriptionException{
+		Type:     models.ExceptionDURRejected,
+		Reason:   review.Note,
+		Codes:    DURFindingTypes(review),
+		Source:   "dur_review",
+		RaisedAt: time.Now(),
+	}
+}
+
+// markDURReviewCompleted records that nothing is left to do for a decided review
+// The event is already out, so a failure only means the scheduler looks at the review again.
+func markDURReviewCompleted(ctx context.Context, mongoClient *database.MongoClient, correlationID string, review *models.DURReview) {
+	now := time.Now()
+	_, err := mongoClient.GetCollection("dur_reviews").UpdateOne(ctx, bson.M{"_id": review.ID},
+		bson.M{"$set": bson.M{"completed_at": now}})
+	if err != nil {
+		log.Printf("⚠️  [correlation_id=%s] Failed to mark DUR review %s completed: %v", correlationID, review.ID.Hex(), err)
+		return
+	}
+	review.CompletedAt = &now
+}
@@ -0,0 +1,51 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"testing"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+// TestDURDecisionRepeats tests which decisions posted again retry the follow-up instead of failing
+func TestDURDecisionRepeats(t *testing.T) {
+	decided := &models.DURReview{Status: models.DURReviewApproved, DecidedBy: "pharm_1"}
+
+	if !DURDecisionRepeats(decided, DURDecision{Status: models.DURReviewApproved, By: "pharm_1"}) {
+		t.Error("Expected the same decision by the same pharmacist to repeat")
+	}
+	if DURDecisionRepeats(decided, DURDecision{Status: models.DURReviewRejected, By: "pharm_1", Note: "n"}) {
+		t.Error("Expected a different decision not to repeat")
+	}
+	if DURDecisionRepeats(decided, DURDecision{Status: models.DURReviewApproved, By: "pharm_2"}) {
+		t.Error("Expected a decision by another pharmacist not to repeat")
+	}
+	if DURDecisionRepeats(&models.DURReview{Status: models.DURReviewPending}, DURDecision{Status: models.DURReviewPending}) {
+		t.Error("Expected a pending review not to repeat")
+	}
+}
+
+// TestDURReviewResumable tests which prescriptions have a decided review's event published again
+func TestDURReviewResumable(t *testing.T) {
+	approved := &models.DURReview{Status: models.DURReviewApproved}
+	rejected := &models.DURReview{Status: models.DURReviewRejected}
+
+	tests := []struct {
+		name   string
+		rx     *models.Prescription
+		review *models.DURReview
+		want   bool
+	}{
+		{"approved and validated", &models.Prescription{Status: models.StatusValidated}, approved, true},
+		{"approved and enrolled since", &models.Prescription{Status: models.StatusEnrolled}, approved, false},
+		{"rejected to exception", &models.Prescription{Status: models.StatusException, Exception: &models.PrescriptionException{Type: models.ExceptionDURRejected}}, rejected, true},
+		{"rejected but other exception", &models.Prescription{Status: models.StatusException, Exception: &models.PrescriptionException{Type: models.ExceptionStepTimedOut}}, rejected, false},
+		{"pending review", &models.Prescription{Status: models.StatusAwaitingDURReview}, &models.DURReview{Status: models.DURReviewPending}, false},
+	}
+
+	for _, tt := range tests {
+		if got := DURReviewResumable(tt.rx, tt.review); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
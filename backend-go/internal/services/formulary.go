@@ -0,0 +1,175 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FormularyService looks up plan formulary coverage for drugs
+type FormularyService struct {
+	mongoClient *database.MongoClient
+}
+
+// NewFormularyService creates a new formulary service
+func NewFormularyService(mongoClient *database.MongoClient) *FormularyService {
+	return &FormularyService{
+		mongoClient: mongoClient,
+	}
+}
+
+// Lookup returns the formulary entry for a drug under a plan at the given time, or nil if the drug is not listed
+func (s *FormularyService) Lookup(ctx context.Context, plan models.PlanKey, ndc string, at time.Time) (*models.FormularyEntry, error) {
+	cursor, err := s.mongoClient.GetCollection("formularies").Find(ctx, bson.M{"bin": plan.BIN, "ndc": ndc})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query formulary: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var entries []models.FormularyEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode formulary entries: %w", err)
+	}
+
+	return SelectFormularyEntry(entries, plan, at), nil
+}
+
+// Check looks up a drug and evaluates the prescribed quantity against the plan formulary
+func (s *FormularyService) Check(ctx context.Context, plan models.PlanKey, medication models.MedicationInfo, at time.Time) (models.FormularyCheck, []string, error) {
+	entry, err := s.Lookup(ctx, plan, medication.NDC, at)
+	if err != nil {
+		return models.FormularyCheck{}, nil, err
+	}
+
+	check, flags := EvaluateFormulary(entry, medication, at)
+	return check, flags, nil
+}
+
+// HasPlan reports whether any formulary data is loaded for the plan's BIN
+func (s *FormularyService) HasPlan(ctx context.Context, plan models.PlanKey) (bool, error) {
+	count, err := s.mongoClient.GetCollection("formularies").CountDocuments(ctx, bson.M{"bin": plan.BIN}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, fmt.Errorf("failed to query formulary: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Upsert inserts or replaces formulary entries keyed by plan, NDC and effective date
+func (s *FormularyService) Upsert(ctx context.Context, entries []models.FormularyEntry) error {
+	collection := s.mongoClient.GetCollection("formularies")
+	for _, e := range entries {
+		filter := bson.M{
+			"bin":            e.Plan.BIN,
+			"pcn":            e.Plan.PCN,
+			"group_id":       e.Plan.GroupID,
+			"ndc":            e.NDC,
+			"effective_from": e.EffectiveFrom,
+		}
+		if _, err := collection.ReplaceOne(ctx, filter, e, options.Replace().SetUpsert(true)); err != nil {
+			return fmt.Errorf("failed to upsert formulary entry for NDC %s: %w", e.NDC, err)
+		}
+	}
+	return nil
+}
+
+// SelectFormularyEntry returns the most specific entry matching the plan and in effect at the given time
+func SelectFormularyEntry(entries []models.FormularyEntry, plan models.PlanKey, at time.Time) *models.FormularyEntry {
+	var best *models.FormularyEntry
+	for i := range entries {
+		e := &entries[i]
+		if !e.Plan.MatchesPlan(plan) || !models.ActiveAt(e.EffectiveFrom, e.EffectiveTo, at) {
+			continue
+		}
+		if best == nil || e.Plan.Specificity() > best.Plan.Specificity() {
+			best = e
+		}
+	}
+	return best
+}
+
+// EvaluateFormulary builds the formulary check for a prescription and the validation flags it raises.
+// A nil entry means the drug is not on the plan's formulary.
+func EvaluateFormulary(entry *models.FormularyEntry, medication models.MedicationInfo, at time.Time) (models.FormularyCheck, []string) {
+	check := models.FormularyCheck{CheckedAt: at}
+
+	if entry == nil || !entry.Covered {
+		check.Status = models.FormularyStatusNotCovered
+		return check, []string{fmt.Sprintf("non_formulary: NDC %s is not covered by the plan formulary", medication.NDC)}
+	}
+
+	check.Status = models.FormularyStatusCovered
+	check.Tier = entry.Tier
+	check.PARequired = entry.PARequired
+	check.QuantityLimit = entry.QuantityLimit
+
+	var flags []string
+	if entry.PARequired {
+		flags = append(flags, fmt.Sprintf("prior_authorization_required: NDC %s requires prior authorization", medication.NDC))
+	}
+	if entry.QuantityLimit > 0 && medication.Quantity > entry.QuantityLimit {
+		flags = append(flags, fmt.Sprintf("quantity_limit_exceeded: quantity %d exceeds plan limit of %d", medication.Quantity, entry.QuantityLimit))
+	}
+
+	return check, flags
+}
+
+// ParseFormularyCSV parses formulary entries from CSV with the header
+// bin,pcn,group_id,ndc,covered,tier,pa_required,quantity_limit,quantity_limit_days,effective_from,effective_to
+func ParseFormularyCSV(r io.Reader) ([]models.FormularyEntry, error) {
+	rows, err := readCSVRows(r, "bin", "ndc", "covered", "effective_from")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.FormularyEntry, 0, len(rows))
+	for i, row := range rows {
+		line := i + 2 // header is line 1
+
+		from, to, err := parseEffectiveDates(row)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		covered, err := parseCSVBool(row["covered"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: covered: %w", line, err)
+		}
+		paRequired, err := parseCSVBool(row["pa_required"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: pa_required: %w", line, err)
+		}
+		tier, err := parseCSVInt(row["tier"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: tier: %w", line, err)
+		}
+		quantityLimit, err := parseCSVInt(row["quantity_limit"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: quantity_limit: %w", line, err)
+		}
+		quantityLimitDays, err := parseCSVInt(row["quantity_limit_days"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: quantity_limit_days: %w", line, err)
+		}
+
+		entries = append(entries, models.FormularyEntry{
+			Plan:              models.PlanKey{BIN: row["bin"], PCN: row["pcn"], GroupID: row["group_id"]},
+			NDC:               row["ndc"],
+			Covered:           covered,
+			Tier:              tier,
+			PARequired:        paRequired,
+			QuantityLimit:     quantityLimit,
+			QuantityLimitDays: quantityLimitDays,
+			EffectiveFrom:     from,
+			EffectiveTo:       to,
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,85 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+// TestSelectFormularyEntry tests that the most specific active entry is chosen
+func TestSelectFormularyEntry(t *testing.T) {
+	jan2024 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []models.FormularyEntry{
+		{Plan: models.PlanKey{BIN: "123456"}, NDC: "1", Covered: true, Tier: 3, EffectiveFrom: jan2024},
+		{Plan: models.PlanKey{BIN: "123456", PCN: "ABC"}, NDC: "1", Covered: true, Tier: 2, EffectiveFrom: jan2024},
+		{Plan: models.PlanKey{BIN: "123456", PCN: "ABC", GroupID: "OTHER"}, NDC: "1", Covered: false, EffectiveFrom: jan2024},
+	}
+
+	at := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	entry := SelectFormularyEntry(entries, models.PlanKey{BIN: "123456", PCN: "ABC", GroupID: "GRP1"}, at)
+	if entry == nil || entry.Tier != 2 {
+		t.Errorf("Expected PCN-specific tier 2 entry, got %+v", entry)
+	}
+
+	if entry := SelectFormularyEntry(entries, models.PlanKey{BIN: "123456"}, jan2024.AddDate(0, 0, -1)); entry != nil {
+		t.Errorf("Expected no entry before effective date, got %+v", entry)
+	}
+}
+
+// TestEvaluateFormulary tests non-formulary, PA and quantity limit flags
+func TestEvaluateFormulary(t *testing.T) {
+	now := time.Now()
+	medication := models.MedicationInfo{NDC: "00074-4339-02", Quantity: 4}
+
+	check, flags := EvaluateFormulary(nil, medication, now)
+	if check.Status != models.FormularyStatusNotCovered {
+		t.Errorf("Expected status %s, got %s", models.FormularyStatusNotCovered, check.Status)
+	}
+	if len(flags) != 1 || !strings.HasPrefix(flags[0], "non_formulary") {
+		t.Errorf("Expected non_formulary flag, got %v", flags)
+	}
+
+	check, flags = EvaluateFormulary(&models.FormularyEntry{Covered: true, Tier: 5, PARequired: true, QuantityLimit: 2}, medication, now)
+	if check.Status != models.FormularyStatusCovered || check.Tier != 5 || !check.PARequired {
+		t.Errorf("Unexpected check: %+v", check)
+	}
+	if len(flags) != 2 {
+		t.Errorf("Expected PA and quantity limit flags, got %v", flags)
+	}
+
+	_, flags = EvaluateFormulary(&models.FormularyEntry{Covered: true, Tier: 1, QuantityLimit: 90}, medication, now)
+	if len(flags) != 0 {
+		t.Errorf("Expected no flags, got %v", flags)
+	}
+}
+
+// TestParseFormularyCSV tests CSV parsing of formulary entries
+func TestParseFormularyCSV(t *testing.T) {
+	csvData := `bin,pcn,group_id,ndc,covered,tier,pa_required,quantity_limit,quantity_limit_days,effective_from,effective_to
+123456,ABC,,00074-4339-02,yes,5,yes,2,28,2024-01-01,
+123456,ABC,,58406-0032-04,no,,,,,2024-01-01,
+`
+
+	entries, err := ParseFormularyCSV(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+
+	first := entries[0]
+	if !first.Covered || first.Tier != 5 || !first.PARequired || first.QuantityLimit != 2 || first.QuantityLimitDays != 28 {
+		t.Errorf("Unexpected first entry: %+v", first)
+	}
+	if entries[1].Covered {
+		t.Errorf("Expected second entry to be not covered")
+	}
+
+	if _, err := ParseFormularyCSV(strings.NewReader("bin,ndc,covered,effective_from\n123456,1,maybe,2024-01-01\n")); err == nil {
+		t.Errorf("Expected error for invalid covered value")
+	}
+}
@@ -0,0 +1,205 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// programCacheTTL is how long program lookups by NDC are cached in Redis
+const programCacheTTL = time.Hour
+
+// ManufacturerProgramService looks up manufacturer copay programs and adjudicates secondary claims
+type ManufacturerProgramService struct {
+	mongoClient *database.MongoClient
+	redis       *database.RedisClient
+}
+
+// SecondaryClaimRequest describes the primary claim outcome a secondary claim is run against
+type SecondaryClaimRequest struct {
+	PrescriptionID string
+	MemberID       string
+	NDC            string
+	InsuranceType  string
+	PrimaryCopay   float64
+	At             time.Time
+}
+
+// NewManufacturerProgramService creates a new manufacturer program service
+func NewManufacturerProgramService(mongoClient *database.MongoClient, redis *database.RedisClient) *ManufacturerProgramService {
+	return &ManufacturerProgramService{
+		mongoClient: mongoClient,
+		redis:       redis,
+	}
+}
+
+// LookupByNDC returns the active program covering a drug, or nil if there is none
+// Lookups are cache-aside in Redis at programs:ndc:{ndc} for one hour
+func (s *ManufacturerProgramService) LookupByNDC(ctx context.Context, ndc string) (*models.ManufacturerProgram, error) {
+	key := fmt.Sprintf("programs:ndc:%s", ndc)
+
+	if s.redis != nil {
+		if val, err := s.redis.Get(ctx, key); err == nil {
+			var program models.ManufacturerProgram
+			if err := json.Unmarshal([]byte(val), &program); err == nil {
+				return &program, nil
+			}
+		}
+	}
+
+	var program models.ManufacturerProgram
+	err := s.mongoClient.GetCollection("manufacturer_programs").FindOne(ctx, bson.M{"ndcs": ndc, "active": true}).Decode(&program)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up manufacturer program for NDC %s: %w", ndc, err)
+	}
+
+	if s.redis != nil {
+		if data, err := json.Marshal(program); err == nil {
+			if err := s.redis.Set(ctx, key, string(data), programCacheTTL); err != nil {
+				log.Printf("⚠️  Failed to cache manufacturer program for NDC %s: %v", ndc, err)
+			}
+		}
+	}
+
+	return &program, nil
+}
+
+// ApplySecondary runs a secondary claim against the drug's manufacturer program
+// Returns nil when no program covers the drug
+func (s *ManufacturerProgramService) ApplySecondary(ctx context.Context, req SecondaryClaimRequest) (*models.ProgramClaim, error) {
+	program, err := s.LookupByNDC(ctx, req.NDC)
+	if err != nil || program == nil {
+		return nil, err
+	}
+
+	if req.At.IsZero() {
+		req.At = time.Now()
+	}
+
+	used, err := s.benefitUsed(ctx, program.ProgramID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	claim := AdjudicateProgram(program, req.InsuranceType, req.PrimaryCopay, used)
+	return &claim, nil
+}
+
+// benefitUsed sums the member's approved discounts for the program in the current calendar year
+func (s *ManufacturerProgramService) benefitUsed(ctx context.Context, programID string, req SecondaryClaimRequest) (float64, error) {
+	if req.MemberID == "" {
+		return 0, nil
+	}
+
+	yearStart := time.Date(req.At.Year(), 1, 1, 0, 0, 0, 0, req.At.Location())
+	prescriptionID, _ := primitive.ObjectIDFromHex(req.PrescriptionID)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"member_id":             req.MemberID,
+			"prescription_id":       bson.M{"$ne": prescriptionID},
+			"result.adjudicated_at": bson.M{"$gte": yearStart},
+		}}},
+		{{Key: "$unwind", Value: "$manufacturer_programs"}},
+		{{Key: "$match", Value: bson.M{
+			"manufacturer_programs.program_id": programID,
+			"manufacturer_programs.status":     models.ProgramClaimApproved,
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   nil,
+			"total": bson.M{"$sum": "$manufacturer_programs.discount_amount"},
+		}}},
+	}
+
+	cursor, err := s.mongoClient.GetCollection("adjudications").Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum program benefit used: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Total float64 `bson:"total"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return 0, fmt.Errorf("failed to decode program benefit used: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return rows[0].Total, nil
+}
+
+// AdjudicateProgram applies a program's eligibility rules to the primary copay
+// The discount brings the copay down to the target copay, limited by the remaining annual benefit
+func AdjudicateProgram(program *models.ManufacturerProgram, insuranceType string, primaryCopay, benefitUsed float64) models.ProgramClaim {
+	claim := models.ProgramClaim{
+		ProgramID:    program.ProgramID,
+		ProgramName:  program.ProgramName,
+		ReducedCopay: primaryCopay,
+	}
+
+	deny := func(message string) models.ProgramClaim {
+		claim.Status = models.ProgramClaimDenied
+		claim.Message = message
+		return claim
+	}
+
+	rules := program.Eligibility
+	if rules.CommercialOnly && insuranceType != models.InsuranceTypeCommercial {
+		if insuranceType == "" {
+			insuranceType = "unknown"
+		}
+		return deny(fmt.Sprintf("program requires commercial insurance (patient has %s)", insuranceType))
+	}
+
+	if primaryCopay <= rules.TargetCopay {
+		return deny("primary copay is already at or below the program target copay")
+	}
+
+	discount := primaryCopay - rules.TargetCopay
+	if rules.MaxAnnualBenefit > 0 {
+		remaining := rules.MaxAnnualBenefit - benefitUsed
+		if remaining <= 0 {
+			return deny("annual program benefit exhausted")
+		}
+		discount = math.Min(discount, remaining)
+	}
+
+	claim.ClaimID = "PRG-" + strings.ToUpper(uuid.New().String()[:8])
+	claim.Status = models.ProgramClaimApproved
+	claim.DiscountAmount = roundCents(discount)
+	claim.ReducedCopay = roundCents(primaryCopay - claim.DiscountAmount)
+	return claim
+}
+
+// BuildCostBreakdown combines the primary claim and optional program claim into the patient cost breakdown
+func BuildCostBreakdown(primary *models.ClaimResult, program *models.ProgramClaim) models.CostBreakdown {
+	breakdown := models.CostBreakdown{
+		TotalDrugCost:     primary.TotalCost,
+		InsuranceCovered:  primary.InsurancePays,
+		InitialCopay:      primary.CopayAmount,
+		FinalPatientCopay: primary.CopayAmount,
+	}
+
+	if program != nil && program.Status == models.ProgramClaimApproved {
+		breakdown.ManufacturerDiscount = program.DiscountAmount
+		breakdown.FinalPatientCopay = program.ReducedCopay
+	}
+
+	return breakdown
+}
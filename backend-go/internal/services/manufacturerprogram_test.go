@@ -0,0 +1,80 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"testing"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+func testProgram() *models.ManufacturerProgram {
+	return &models.ManufacturerProgram{
+		ProgramID:   "prog_abbvie_humira_2024",
+		ProgramName: "Humira Complete Savings Card",
+		Eligibility: models.ProgramEligibility{
+			CommercialOnly:   true,
+			TargetCopay:      5,
+			MaxAnnualBenefit: 14000,
+		},
+	}
+}
+
+// TestAdjudicateProgram tests manufacturer program eligibility rules
+func TestAdjudicateProgram(t *testing.T) {
+	tests := []struct {
+		name          string
+		insuranceType string
+		primaryCopay  float64
+		benefitUsed   float64
+		wantStatus    string
+		wantDiscount  float64
+		wantCopay     float64
+	}{
+		{"commercial", models.InsuranceTypeCommercial, 1500, 0, models.ProgramClaimApproved, 1495, 5},
+		{"medicare excluded", models.InsuranceTypeMedicare, 1500, 0, models.ProgramClaimDenied, 0, 1500},
+		{"unknown insurance excluded", "", 1500, 0, models.ProgramClaimDenied, 0, 1500},
+		{"copay at target", models.InsuranceTypeCommercial, 5, 0, models.ProgramClaimDenied, 0, 5},
+		{"partial remaining benefit", models.InsuranceTypeCommercial, 1500, 13000, models.ProgramClaimApproved, 1000, 500},
+		{"benefit exhausted", models.InsuranceTypeCommercial, 1500, 14000, models.ProgramClaimDenied, 0, 1500},
+	}
+
+	for _, tt := range tests {
+		claim := AdjudicateProgram(testProgram(), tt.insuranceType, tt.primaryCopay, tt.benefitUsed)
+		if claim.Status != tt.wantStatus {
+			t.Errorf("%s: expected status %s, got %s (%s)", tt.name, tt.wantStatus, claim.Status, claim.Message)
+		}
+		if claim.DiscountAmount != tt.wantDiscount {
+			t.Errorf("%s: expected discount %.2f, got %.2f", tt.name, tt.wantDiscount, claim.DiscountAmount)
+		}
+		if claim.ReducedCopay != tt.wantCopay {
+			t.Errorf("%s: expected reduced copay %.2f, got %.2f", tt.name, tt.wantCopay, claim.ReducedCopay)
+		}
+	}
+}
+
+// TestBuildCostBreakdown tests the combined primary and secondary cost breakdown
+func TestBuildCostBreakdown(t *testing.T) {
+	primary := &models.ClaimResult{TotalCost: 6500, InsurancePays: 5000, CopayAmount: 1500}
+
+	breakdown := BuildCostBreakdown(primary, &models.ProgramClaim{Status: models.ProgramClaimApproved, DiscountAmount: 1495, ReducedCopay: 5})
+	want := models.CostBreakdown{
+		TotalDrugCost:        6500,
+		InsuranceCovered:     5000,
+		InitialCopay:         1500,
+		ManufacturerDiscount: 1495,
+		FinalPatientCopay:    5,
+	}
+	if breakdown != want {
+		t.Errorf("Expected %+v, got %+v", want, breakdown)
+	}
+
+	breakdown = BuildCostBreakdown(primary, &models.ProgramClaim{Status: models.ProgramClaimDenied, ReducedCopay: 1500})
+	if breakdown.ManufacturerDiscount != 0 || breakdown.FinalPatientCopay != 1500 {
+		t.Errorf("Expected no discount for denied program claim, got %+v", breakdown)
+	}
+
+	breakdown = BuildCostBreakdown(primary, nil)
+	if breakdown.FinalPatientCopay != 1500 {
+		t.Errorf("Expected final copay 1500 without program, got %.2f", breakdown.FinalPatientCopay)
+	}
+}
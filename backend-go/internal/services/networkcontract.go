@@ -0,0 +1,207 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NetworkContractService manages pharmacy–payer network contracts
+type NetworkContractService struct {
+	mongoClient *database.MongoClient
+}
+
+// NewNetworkContractService creates a new network contract service
+func NewNetworkContractService(mongoClient *database.MongoClient) *NetworkContractService {
+	return &NetworkContractService{
+		mongoClient: mongoClient,
+	}
+}
+
+// InNetworkPharmacies returns the contracts active for a plan at the given time, keyed by pharmacy NCPDP ID.
+// When several contracts match a pharmacy, the most specific (group over PCN over BIN-only) wins.
+func (s *NetworkContractService) InNetworkPharmacies(ctx context.Context, plan models.PlanKey, at time.Time) (map[string]models.NetworkContract, error) {
+	cursor, err := s.mongoClient.GetCollection("pharmacy_contracts").Find(ctx, bson.M{"bin": plan.BIN})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pharmacy contracts: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var contracts []models.NetworkContract
+	if err := cursor.All(ctx, &contracts); err != nil {
+		return nil, fmt.Errorf("failed to decode pharmacy contracts: %w", err)
+	}
+
+	return ActiveContracts(contracts, plan, at), nil
+}
+
+// Upsert inserts or replaces contracts keyed by pharmacy, plan and effective date
+func (s *NetworkContractService) Upsert(ctx context.Context, contracts []models.NetworkContract) error {
+	collection := s.mongoClient.GetCollection("pharmacy_contracts")
+	for _, c := range contracts {
+		filter := bson.M{
+			"pharmacy_ncpdp_id": c.PharmacyNCPDPID,
+			"bin":               c.Plan.BIN,
+			"pcn":               c.Plan.PCN,
+			"group_id":          c.Plan.GroupID,
+			"effective_from":    c.EffectiveFrom,
+		}
+		if _, err := collection.ReplaceOne(ctx, filter, c, options.Replace().SetUpsert(true)); err != nil {
+			return fmt.Errorf("failed to upsert contract for pharmacy %s: %w", c.PharmacyNCPDPID, err)
+		}
+	}
+	return nil
+}
+
+// ActiveContracts filters contracts to those matching the plan and in effect at the given time,
+// keeping the most specific contract per pharmacy
+func ActiveContracts(contracts []models.NetworkContract, plan models.PlanKey, at time.Time) map[string]models.NetworkContract {
+	active := make(map[string]models.NetworkContract)
+	for _, c := range contracts {
+		if !c.Plan.MatchesPlan(plan) || !models.ActiveAt(c.EffectiveFrom, c.EffectiveTo, at) {
+			continue
+		}
+		if existing, ok := active[c.PharmacyNCPDPID]; ok && existing.Plan.Specificity() >= c.Plan.Specificity() {
+			continue
+		}
+		active[c.PharmacyNCPDPID] = c
+	}
+	return active
+}
+
+// ParseContractsCSV parses pharmacy contracts from CSV with the header
+// pharmacy_ncpdp_id,bin,pcn,group_id,network_tier,effective_from,effective_to
+func ParseContractsCSV(r io.Reader) ([]models.NetworkContract, error) {
+	rows, err := readCSVRows(r, "pharmacy_ncpdp_id", "bin", "effective_from")
+	if err != nil {
+		return nil, err
+	}
+
+	contracts := make([]models.NetworkContract, 0, len(rows))
+	for i, row := range rows {
+		line := i + 2 // header is line 1
+
+		from, to, err := parseEffectiveDates(row)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		tier := row["network_tier"]
+		switch tier {
+		case "":
+			tier = models.NetworkTierStandard
+		case models.NetworkTierPreferred, models.NetworkTierStandard:
+		default:
+			return nil, fmt.Errorf("line %d: unknown network_tier %q", line, tier)
+		}
+
+		contracts = append(contracts, models.NetworkContract{
+			PharmacyNCPDPID: row["pharmacy_ncpdp_id"],
+			Plan:            models.PlanKey{BIN: row["bin"], PCN: row["pcn"], GroupID: row["group_id"]},
+			NetworkTier:     tier,
+			EffectiveFrom:   from,
+			EffectiveTo:     to,
+		})
+	}
+
+	return contracts, nil
+}
+
+// readCSVRows reads a CSV with a header row into one map per record, checking required columns are set
+func readCSVRows(r io.Reader, required ...string) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	var rows []map[string]string
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv: %w", err)
+		}
+
+		row := make(map[string]string, len(header))
+		for i, col := range header {
+			if i < len(record) {
+				row[col] = strings.TrimSpace(record[i])
+			}
+		}
+		for _, col := range required {
+			if row[col] == "" {
+				return nil, fmt.Errorf("line %d: %s is required", line, col)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parseEffectiveDates parses the effective_from / effective_to (optional) columns as YYYY-MM-DD
+func parseEffectiveDates(row map[string]string) (time.Time, *time.Time, error) {
+	from, err := time.Parse("2006-01-02", row["effective_from"])
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("invalid effective_from %q", row["effective_from"])
+	}
+
+	if row["effective_to"] == "" {
+		return from, nil, nil
+	}
+	to, err := time.Parse("2006-01-02", row["effective_to"])
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("invalid effective_to %q", row["effective_to"])
+	}
+	if !to.After(from) {
+		return time.Time{}, nil, fmt.Errorf("effective_to %s is not after effective_from %s", row["effective_to"], row["effective_from"])
+	}
+
+	return from, &to, nil
+}
+
+// parseCSVBool parses yes/no style flags, treating an empty value as false
+func parseCSVBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "", "n", "no", "false", "0":
+		return false, nil
+	case "y", "yes", "true", "1":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
+
+// parseCSVInt parses an optional integer column, treating an empty value as 0
+func parseCSVInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return n, nil
+}
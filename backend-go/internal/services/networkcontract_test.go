@@ -0,0 +1,78 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+// TestActiveContracts tests plan matching, effective dates and specificity
+func TestActiveContracts(t *testing.T) {
+	jan2023 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	jan2024 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	contracts := []models.NetworkContract{
+		{PharmacyNCPDPID: "A", Plan: models.PlanKey{BIN: "123456"}, NetworkTier: models.NetworkTierStandard, EffectiveFrom: jan2023},
+		{PharmacyNCPDPID: "A", Plan: models.PlanKey{BIN: "123456", PCN: "ABC", GroupID: "GRP1"}, NetworkTier: models.NetworkTierPreferred, EffectiveFrom: jan2023},
+		{PharmacyNCPDPID: "B", Plan: models.PlanKey{BIN: "123456", PCN: "ABC"}, EffectiveFrom: jan2023, EffectiveTo: &jan2024},
+		{PharmacyNCPDPID: "C", Plan: models.PlanKey{BIN: "123456", PCN: "OTHER"}, EffectiveFrom: jan2023},
+		{PharmacyNCPDPID: "D", Plan: models.PlanKey{BIN: "654321"}, EffectiveFrom: jan2023},
+	}
+
+	plan := models.PlanKey{BIN: "123456", PCN: "ABC", GroupID: "GRP1"}
+
+	active := ActiveContracts(contracts, plan, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	if len(active) != 1 {
+		t.Fatalf("Expected 1 in-network pharmacy, got %d: %+v", len(active), active)
+	}
+	if active["A"].NetworkTier != models.NetworkTierPreferred {
+		t.Errorf("Expected group-specific preferred contract for A, got %s", active["A"].NetworkTier)
+	}
+
+	active = ActiveContracts(contracts, plan, time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC))
+	if _, ok := active["B"]; !ok {
+		t.Errorf("Expected B to be in network before its contract ended")
+	}
+}
+
+// TestParseContractsCSV tests CSV parsing of pharmacy contracts
+func TestParseContractsCSV(t *testing.T) {
+	csvData := `pharmacy_ncpdp_id,bin,pcn,group_id,network_tier,effective_from,effective_to
+# comment rows are ignored
+1234567,123456,ABC,,preferred,2024-01-01,
+2345678,123456,,GRP1,,2024-01-01,2025-01-01
+`
+
+	contracts, err := ParseContractsCSV(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(contracts) != 2 {
+		t.Fatalf("Expected 2 contracts, got %d", len(contracts))
+	}
+
+	if contracts[0].NetworkTier != models.NetworkTierPreferred || contracts[0].EffectiveTo != nil {
+		t.Errorf("Unexpected first contract: %+v", contracts[0])
+	}
+	if contracts[1].NetworkTier != models.NetworkTierStandard {
+		t.Errorf("Expected default tier standard, got %s", contracts[1].NetworkTier)
+	}
+	if contracts[1].Plan.GroupID != "GRP1" || contracts[1].EffectiveTo == nil {
+		t.Errorf("Unexpected second contract: %+v", contracts[1])
+	}
+
+	invalid := []string{
+		"pharmacy_ncpdp_id,bin,effective_from\n,123456,2024-01-01\n",
+		"pharmacy_ncpdp_id,bin,effective_from\n1234567,123456,01/01/2024\n",
+		"pharmacy_ncpdp_id,bin,network_tier,effective_from\n1234567,123456,gold,2024-01-01\n",
+		"pharmacy_ncpdp_id,bin,effective_from,effective_to\n1234567,123456,2024-01-01,2023-01-01\n",
+	}
+	for _, data := range invalid {
+		if _, err := ParseContractsCSV(strings.NewReader(data)); err == nil {
+			t.Errorf("Expected error for CSV %q", data)
+		}
+	}
+}
@@ -0,0 +1,506 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/notifications"
+	"github.com/phil-my-meds/backend-gogit/internal/sms"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultNotificationMaxAttempts is how many times a notification is sent on a channel before falling back
+const DefaultNotificationMaxAttempts = 5
+
+const (
+	// notificationRetryBase is the delay before the first retry; each further retry doubles it
+	notificationRetryBase = 1 * time.Minute
+	// notificationRetryMax caps the delay between retries
+	notificationRetryMax = 1 * time.Hour
+	// notificationClaimLease keeps a retry from picking up a notification while a send is in flight
+	notificationClaimLease = 2 * notifications.DefaultSMTPTimeout
+)
+
+var (
+	// ErrNotificationNotFound is returned when no notification matches a delivery receipt
+	ErrNotificationNotFound = errors.New("notification not found")
+	// ErrNoNotificationRoute is returned when a notification has no channel it can be sent on
+	ErrNoNotificationRoute = errors.New("no channel to reach the patient")
+)
+
+// activeNotificationStatuses are the statuses of notifications still waiting to be sent
+var activeNotificationStatuses = []models.NotificationStatus{models.NotificationPending, models.NotificationRetrying}
+
+// NotificationConfig configures how notifications are sent
+type NotificationConfig struct {
+	From                 string // email sender
+	SMSStatusCallbackURL string // where the SMS provider posts delivery receipts; empty for none
+	MaxAttempts          int    // sends per channel before falling back to the next one
+}
+
+// NotificationService records patient notifications and sends them by email or text, retrying failed
+// sends and falling back across channels
+type NotificationService struct {
+	mongoClient *database.MongoClient
+	mailer      notifications.Mailer
+	sms         sms.Sender
+	preferences *CommunicationPreferenceService
+	config      NotificationConfig
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(mongoClient *database.MongoClient, mailer notifications.Mailer, smsSender sms.Sender, preferences *CommunicationPreferenceService, config NotificationConfig) *NotificationService {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultNotificationMaxAttempts
+	}
+	return &NotificationService{
+		mongoClient: mongoClient,
+		mailer:      mailer,
+		sms:         smsSender,
+		preferences: preferences,
+		config:      config,
+	}
+}
+
+// NotificationDedupeKey identifies the notification a template renders for an event
+func NotificationDedupeKey(eventID, template string) string {
+	return eventID + ":" + template
+}
+
+// NotificationRetryDelay is the wait after a failed send, doubling from one minute up to an hour
+func NotificationRetryDelay(attempts int) time.Duration {
+	delay := notificationRetryBase
+	for i := 1; i < attempts && delay < notificationRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > notificationRetryMax {
+		delay = notificationRetryMax
+	}
+	return delay
+}
+
+// NotificationOutcome returns the status and next attempt time of a notification after a send attempt
+// A successful send is final; a failed one is retried until maxAttempts sends have been made, unless
+// the address is unusable and no retry could succeed. A failed status gives up on the channel.
+func NotificationOutcome(attempts, maxAttempts int, sendErr error, now time.Time) (models.NotificationStatus, *time.Time) {
+	if sendErr == nil {
+		return models.NotificationSent, nil
+	}
+	if attempts >= maxAttempts || IsPermanentSendError(sendErr) {
+		return models.NotificationFailed, nil
+	}
+	next := now.Add(NotificationRetryDelay(attempts))
+	return models.NotificationRetrying, &next
+}
+
+// IsPermanentSendError reports whether a send failed because of the address rather than the server
+func IsPermanentSendError(err error) bool {
+	return errors.Is(err, notifications.ErrInvalidRecipient) || errors.Is(err, sms.ErrInvalidNumber) || errors.Is(err, sms.ErrUnsubscribed)
+}
+
+// ReceiptStatus maps an SMS provider delivery status to a delivery receipt status
+func ReceiptStatus(status sms.DeliveryStatus) models.DeliveryReceiptStatus {
+	switch status {
+	case sms.StatusSent:
+		return models.ReceiptSent
+	case sms.StatusDelivered:
+		return models.ReceiptDelivered
+	case sms.StatusUndelivered:
+		return models.ReceiptUndelivered
+	case sms.StatusFailed:
+		return models.ReceiptFailed
+	}
+	return models.ReceiptQueued
+}
+
+// Send records a rendered notification and makes the first send attempt on the first route
+// The other routes are fallbacks, tried in order when a channel fails. The record is unique per dedupe
+// key, so a redelivered event returns the existing notification and only sends it if it is still waiting
+// for its first attempt. A failed send is not an error: the notification is left for RetryDue. It returns
+// the notification as it stands after the attempt.
+func (s *NotificationService) Send(ctx context.Context, notification *models.Notification, routes []models.NotificationRoute) (*models.Notification, error) {
+	if len(routes) == 0 {
+		return nil, ErrNoNotificationRoute
+	}
+	collection := s.mongoClient.GetCollection("notifications")
+
+	now := time.Now()
+	useRoute(notification, routes[0])
+	notification.Fallbacks = routes[1:]
+	notification.ID = primitive.NewObjectID()
+	notification.Status = models.NotificationPending
+	notification.Attempts = 0
+	notification.NextAttemptAt = &now
+	notification.CreatedAt = now
+	notification.UpdatedAt = now
+
+	if _, err := collection.InsertOne(ctx, notification); err != nil {
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("failed to record notification: %w", err)
+		}
+		var existing models.Notification
+		if err := collection.FindOne(ctx, bson.M{"dedupe_key": notification.DedupeKey}).Decode(&existing); err != nil {
+			return nil, fmt.Errorf("failed to load notification %s: %w", notification.DedupeKey, err)
+		}
+		// Attempted notifications are left to RetryDue, which waits out an in-flight send's claim
+		if existing.Status != models.NotificationPending || existing.Attempts > 0 || len(existing.Receipts) > 0 {
+			return &existing, nil
+		}
+		notification = &existing
+	}
+
+	return s.attempt(ctx, notification)
+}
+
+// RetryDue sends up to limit notifications whose next attempt is due, oldest first
+// This covers retries, fallbacks and texts held for quiet hours. It returns the number of notifications
+// sent successfully.
+func (s *NotificationService) RetryDue(ctx context.Context, now time.Time, limit int) (int, error) {
+	filter := bson.M{
+		"status":          bson.M{"$in": activeNotificationStatuses},
+		"next_attempt_at": bson.M{"$lte": now},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).SetLimit(int64(limit))
+
+	cursor, err := s.mongoClient.GetCollection("notifications").Find(ctx, filter, opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find notifications due for retry: %w", err)
+	}
+	var due []models.Notification
+	if err := cursor.All(ctx, &due); err != nil {
+		return 0, fmt.Errorf("failed to decode notifications due for retry: %w", err)
+	}
+
+	sent := 0
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		result, err := s.attempt(ctx, &due[i])
+		if err != nil {
+			return sent, err
+		}
+		if result.Status == models.NotificationSent {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// ApplyReceipt records an SMS provider delivery receipt on the notification it is for
+// A delivered receipt marks the notification delivered; an undelivered or failed one falls back to the
+// next channel, which RetryDue sends. Receipts for a text the notification has since moved on from only
+// add to its history, and a repeated receipt is ignored.
+func (s *NotificationService) ApplyReceipt(ctx context.Context, callback *sms.StatusCallback) (*models.Notification, error) {
+	collection := s.mongoClient.GetCollection("notifications")
+
+	var notification models.Notification
+	err := collection.FindOne(ctx, bson.M{"receipts.provider_message_id": callback.MessageID}).Decode(&notification)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotificationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notification for message %s: %w", callback.MessageID, err)
+	}
+
+	now := time.Now()
+	receipt := models.DeliveryReceipt{
+		Channel:           models.NotificationSMS,
+		Recipient:         callback.To,
+		Status:            ReceiptStatus(callback.Status),
+		ProviderMessageID: callback.MessageID,
+		At:                now,
+	}
+	if callback.ErrorCode != "" || callback.ErrorMessage != "" {
+		receipt.Error = strings.TrimSpace(callback.ErrorCode + " " + callback.ErrorMessage)
+	}
+	for _, existing := range notification.Receipts {
+		if existing.ProviderMessageID == receipt.ProviderMessageID && existing.Status == receipt.Status {
+			return &notification, nil
+		}
+	}
+
+	current := bson.M{"provider_message_id": callback.MessageID, "status": models.NotificationSent}
+	isCurrent := notification.ProviderMessageID == callback.MessageID && notification.Status == models.NotificationSent
+
+	if isCurrent && (callback.Status == sms.StatusUndelivered || callback.Status == sms.StatusFailed) {
+		result, err := s.fallback(ctx, &notification, current, receipt, now)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			// A concurrent receipt moved the notification on first
+			return &notification, nil
+		}
+		return result, nil
+	}
+
+	filter := bson.M{"_id": notification.ID}
+	update := bson.M{"$push": bson.M{"receipts": receipt}, "$set": bson.M{"updated_at": now}}
+	if isCurrent && callback.Status == sms.StatusDelivered {
+		filter = bson.M{"_id": notification.ID, "provider_message_id": callback.MessageID, "status": models.NotificationSent}
+		update["$set"] = bson.M{"status": models.NotificationDelivered, "delivered_at": now, "updated_at": now}
+	}
+	err = collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&notification)
+	if err == mongo.ErrNoDocuments {
+		return &notification, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record receipt for message %s: %w", callback.MessageID, err)
+	}
+	return &notification, nil
+}
+
+// attempt sends a notification on its current route and records the outcome
+// Texts are held until the patient's quiet hours are over, and skipped when the number opted out or the
+// patient withdrew consent since the message was queued. The send is claimed first: the claim counts
+// the attempt and pushes next_attempt_at past the send timeout, so the worker and the scheduler never
+// send the same notification at once. If another process claimed it first the notification is returned
+// unchanged. When a channel fails for good the next route is tried straight away.
+func (s *NotificationService) attempt(ctx context.Context, notification *models.Notification) (*models.Notification, error) {
+	collection := s.mongoClient.GetCollection("notifications")
+	current := bson.M{
+		"attempts": notification.Attempts,
+		"channel":  notification.Channel,
+		"status":   bson.M{"$in": activeNotificationStatuses},
+	}
+
+	now := time.Now()
+	if notification.Channel == models.NotificationSMS {
+		reason, err := s.smsBlocked(ctx, notification)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			receipt := models.DeliveryReceipt{Channel: notification.Channel, Recipient: notification.Recipient, Status: models.ReceiptSkipped, Error: reason, At: now}
+			return s.fallbackAndSend(ctx, notification, current, receipt, now)
+		}
+
+		// Holding for quiet hours is not an attempt
+		if sendAt := QuietHoursEnd(notification.QuietHours, notification.Timezone, now); sendAt.After(now) {
+			var held models.Notification
+			err := collection.FindOneAndUpdate(ctx, withID(notification.ID, current),
+				bson.M{"$set": bson.M{"next_attempt_at": sendAt, "updated_at": now}},
+				options.FindOneAndUpdate().SetReturnDocument(options.After),
+			).Decode(&held)
+			if err == mongo.ErrNoDocuments {
+				return notification, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to hold notification %s for quiet hours: %w", notification.ID.Hex(), err)
+			}
+			return &held, nil
+		}
+	}
+
+	claimUpdate := bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{"next_attempt_at": now.Add(notificationClaimLease), "updated_at": now},
+	}
+	var claimed models.Notification
+	err := collection.FindOneAndUpdate(ctx, withID(notification.ID, current), claimUpdate, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&claimed)
+	if err == mongo.ErrNoDocuments {
+		return notification, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim notification %s: %w", notification.ID.Hex(), err)
+	}
+
+	providerMessageID, sendErr := s.deliver(ctx, &claimed)
+
+	// Record the outcome even if the caller's context ended during the send
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	now = time.Now()
+	receipt := models.DeliveryReceipt{
+		Channel:           claimed.Channel,
+		Recipient:         claimed.Recipient,
+		Status:            models.ReceiptAccepted,
+		ProviderMessageID: providerMessageID,
+		At:                now,
+	}
+	if sendErr != nil {
+		receipt.Status = models.ReceiptSendFailed
+		receipt.Error = sendErr.Error()
+	}
+
+	// The provider knows the number replied STOP even if we missed the inbound message
+	if errors.Is(sendErr, sms.ErrUnsubscribed) && s.preferences != nil {
+		if _, err := s.preferences.OptOut(recordCtx, claimed.Recipient, models.ConsentSourceProvider); err != nil {
+			return nil, err
+		}
+	}
+
+	status, next := NotificationOutcome(claimed.Attempts, s.config.MaxAttempts, sendErr, now)
+	if status == models.NotificationFailed && len(claimed.Fallbacks) > 0 {
+		return s.fallbackAndSend(recordCtx, &claimed, bson.M{"attempts": claimed.Attempts, "channel": claimed.Channel}, receipt, now)
+	}
+
+	set := bson.M{"status": status, "updated_at": now}
+	unset := bson.M{}
+	if next != nil {
+		set["next_attempt_at"] = *next
+	} else {
+		unset["next_attempt_at"] = ""
+	}
+	if sendErr != nil {
+		set["last_error"] = sendErr.Error()
+	} else {
+		set["sent_at"] = now
+		if providerMessageID != "" {
+			set["provider_message_id"] = providerMessageID
+		}
+	}
+	update := bson.M{"$set": set, "$push": bson.M{"receipts": receipt}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	if err := collection.FindOneAndUpdate(recordCtx, bson.M{"_id": claimed.ID}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&claimed); err != nil {
+		return nil, fmt.Errorf("failed to record outcome of notification %s: %w", claimed.ID.Hex(), err)
+	}
+	return &claimed, nil
+}
+
+// deliver sends a claimed notification on its channel; it returns the provider message ID of a text
+func (s *NotificationService) deliver(ctx context.Context, notification *models.Notification) (string, error) {
+	switch notification.Channel {
+	case models.NotificationEmail:
+		if s.mailer == nil {
+			return "", errors.New("no mailer configured")
+		}
+		return "", s.mailer.Send(ctx, notifications.Email{
+			From:    s.config.From,
+			To:      notification.Recipient,
+			Subject: notification.Subject,
+			Text:    notification.TextBody,
+			HTML:    notification.HTMLBody,
+			Headers: map[string]string{"X-Notification-ID": notification.ID.Hex()},
+		})
+	case models.NotificationSMS:
+		if s.sms == nil {
+			return "", errors.New("no SMS provider configured")
+		}
+		receipt, err := s.sms.Send(ctx, sms.Message{
+			To:             notification.Recipient,
+			Body:           notification.SMSBody,
+			StatusCallback: s.config.SMSStatusCallbackURL,
+		})
+		if err != nil {
+			return "", err
+		}
+		return receipt.MessageID, nil
+	}
+	return "", fmt.Errorf("unknown notification channel %q", notification.Channel)
+}
+
+// smsBlocked returns why a notification may not be texted, or "" if it may
+func (s *NotificationService) smsBlocked(ctx context.Context, notification *models.Notification) (string, error) {
+	if notification.SMSBody == "" {
+		return "template has no text message", nil
+	}
+	if s.preferences == nil {
+		return "", nil
+	}
+	optedOut, err := s.preferences.IsOptedOut(ctx, notification.Recipient)
+	if err != nil {
+		return "", err
+	}
+	if optedOut {
+		return "number opted out of texts", nil
+	}
+	if notification.PatientID == "" {
+		return "", nil
+	}
+	patient, err := s.preferences.Get(ctx, notification.PatientID)
+	if errors.Is(err, ErrPatientNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !ChannelAllowed(models.NotificationSMS, patient.Communication) {
+		return "patient has not consented to texts", nil
+	}
+	return "", nil
+}
+
+// fallbackAndSend moves a notification to its next route and attempts it straight away
+func (s *NotificationService) fallbackAndSend(ctx context.Context, notification *models.Notification, current bson.M, receipt models.DeliveryReceipt, now time.Time) (*models.Notification, error) {
+	next, err := s.fallback(ctx, notification, current, receipt, now)
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		return notification, nil
+	}
+	if next.Status == models.NotificationFailed {
+		return next, nil
+	}
+	return s.attempt(ctx, next)
+}
+
+// fallback records a receipt and moves a notification to its next route, or marks it failed when no
+// route is left. It returns nil if the notification no longer matches current, i.e. another process
+// changed it first.
+func (s *NotificationService) fallback(ctx context.Context, notification *models.Notification, current bson.M, receipt models.DeliveryReceipt, now time.Time) (*models.Notification, error) {
+	set := bson.M{"updated_at": now}
+	unset := bson.M{"provider_message_id": ""}
+	if receipt.Error != "" {
+		set["last_error"] = receipt.Error
+	}
+	if len(notification.Fallbacks) == 0 {
+		set["status"] = models.NotificationFailed
+		unset["next_attempt_at"] = ""
+	} else {
+		route := notification.Fallbacks[0]
+		set["channel"] = route.Channel
+		set["recipient"] = route.Recipient
+		set["quiet_hours"] = route.QuietHours
+		set["timezone"] = route.Timezone
+		set["fallbacks"] = notification.Fallbacks[1:]
+		set["status"] = models.NotificationPending
+		set["attempts"] = 0
+		set["next_attempt_at"] = now
+	}
+
+	var updated models.Notification
+	err := s.mongoClient.GetCollection("notifications").FindOneAndUpdate(ctx, withID(notification.ID, current),
+		bson.M{"$set": set, "$unset": unset, "$push": bson.M{"receipts": receipt}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fall back notification %s: %w", notification.ID.Hex(), err)
+	}
+	return &updated, nil
+}
+
+// useRoute makes a route the notification's current channel and address
+func useRoute(notification *models.Notification, route models.NotificationRoute) {
+	notification.Channel = route.Channel
+	notification.Recipient = route.Recipient
+	notification.QuietHours = route.QuietHours
+	notification.Timezone = route.Timezone
+}
+
+// withID returns a filter matching a notification by ID and the given fields
+func withID(id primitive.ObjectID, fields bson.M) bson.M {
+	filter := bson.M{"_id": id}
+	for key, value := range fields {
+		filter[key] = value
+	}
+	return filter
+}
@@ -0,0 +1,93 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/notifications"
+	"github.com/phil-my-meds/backend-gogit/internal/sms"
+)
+
+// TestNotificationRetryDelay tests the exponential retry backoff and its cap
+func TestNotificationRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 1 * time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, 1 * time.Hour},
+		{20, 1 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := NotificationRetryDelay(tt.attempts); got != tt.expected {
+			t.Errorf("attempt %d: expected %s, got %s", tt.attempts, tt.expected, got)
+		}
+	}
+}
+
+// TestNotificationOutcome tests the status a notification moves to after a send attempt
+func TestNotificationOutcome(t *testing.T) {
+	now := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	sendErr := errors.New("connection refused")
+
+	status, next := NotificationOutcome(1, 5, nil, now)
+	if status != models.NotificationSent || next != nil {
+		t.Errorf("Expected a successful send to be final, got %s %v", status, next)
+	}
+
+	status, next = NotificationOutcome(2, 5, sendErr, now)
+	if status != models.NotificationRetrying || next == nil || !next.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("Expected a retry in 2m, got %s %v", status, next)
+	}
+
+	status, next = NotificationOutcome(5, 5, sendErr, now)
+	if status != models.NotificationFailed || next != nil {
+		t.Errorf("Expected the last attempt to fail the notification, got %s %v", status, next)
+	}
+
+	status, next = NotificationOutcome(1, 5, fmt.Errorf("%w: %q", notifications.ErrInvalidRecipient, "jane"), now)
+	if status != models.NotificationFailed || next != nil {
+		t.Errorf("Expected an invalid recipient to fail without retrying, got %s %v", status, next)
+	}
+
+	for _, err := range []error{sms.ErrInvalidNumber, &sms.TwilioError{StatusCode: 400, Code: 21610, Message: "unsubscribed"}} {
+		status, next = NotificationOutcome(1, 5, err, now)
+		if status != models.NotificationFailed || next != nil {
+			t.Errorf("Expected %v to fail the text without retrying, got %s %v", err, status, next)
+		}
+	}
+}
+
+// TestReceiptStatus tests mapping provider delivery statuses to receipt statuses
+func TestReceiptStatus(t *testing.T) {
+	tests := map[sms.DeliveryStatus]models.DeliveryReceiptStatus{
+		sms.StatusQueued:      models.ReceiptQueued,
+		sms.StatusSent:        models.ReceiptSent,
+		sms.StatusDelivered:   models.ReceiptDelivered,
+		sms.StatusUndelivered: models.ReceiptUndelivered,
+		sms.StatusFailed:      models.ReceiptFailed,
+	}
+	for status, expected := range tests {
+		if got := ReceiptStatus(status); got != expected {
+			t.Errorf("%s: expected %s, got %s", status, expected, got)
+		}
+	}
+}
+
+// TestNotificationDedupeKey tests that each template sent for an event has its own key
+func TestNotificationDedupeKey(t *testing.T) {
+	if NotificationDedupeKey("evt-1", "payment_link") == NotificationDedupeKey("evt-1", "shipment_shipped") {
+		t.Error("Expected different templates for one event to have different keys")
+	}
+	if NotificationDedupeKey("evt-1", "payment_link") != "evt-1:payment_link" {
+		t.Errorf("Unexpected dedupe key %s", NotificationDedupeKey("evt-1", "payment_link"))
+	}
+}
@@ -0,0 +1,255 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/payments"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// paymentReceiptCacheTTL is how long payment receipts are cached in Redis
+const paymentReceiptCacheTTL = 24 * time.Hour
+
+// ErrPaymentNotFound is returned when a payment event does not match any payment record
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// SettlePayment applies a provider payment outcome to the payment record and its prescription
+func SettlePayment(
+	ctx context.Context,
+	mongoClient *database.MongoClient,
+	redis *database.RedisClient,
+	producer kafka.Producer,
+	correlationID string,
+	event *payments.WebhookEvent,
+) (*models.Payment, error) {
+	to, from, ok := paymentTransition(event.Status)
+	if !ok {
+		return nil, fmt.Errorf("unsupported payment outcome %q", event.Status)
+	}
+
+	lookup := paymentEventFilter(event)
+	if lookup == nil {
+		return nil, ErrPaymentNotFound
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"status":     to,
+		"updated_at": now,
+	}
+	if event.PaymentIntentID != "" {
+		set["provider_payment_intent_id"] = event.PaymentIntentID
+	}
+	if to == models.PaymentPaid {
+		paidAt := event.Created
+		if paidAt.Unix() <= 0 {
+			paidAt = now
+		}
+		set["paid_at"] = paidAt
+	} else {
+		set["failure_reason"] = event.FailureReason
+	}
+
+	// The outcome's own status is allowed too, so a delivery that failed part way repeats the
+	// prescription update and the publish when it is retried
+	filter := bson.M{"status": bson.M{"$in": append(from, to)}}
+	for k, v := range lookup {
+		filter[k] = v
+	}
+
+	paymentCollection := mongoClient.GetCollection("payments")
+	var payment models.Payment
+	err := paymentCollection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&payment)
+	if err == mongo.ErrNoDocuments {
+		// Either not ours, or already in a status this outcome cannot follow (e.g. paid then expired)
+		if err := paymentCollection.FindOne(ctx, lookup).Decode(&payment); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, ErrPaymentNotFound
+			}
+			return nil, fmt.Errorf("failed to load payment: %w", err)
+		}
+		log.Printf("ℹ️  [correlation_id=%s] Payment %s is %s, ignoring %s outcome", correlationID, payment.ID.Hex(), payment.Status, event.Status)
+		return &payment, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update payment: %w", err)
+	}
+
+	// The outcome continues the flow that created the payment, not the webhook delivery
+	if payment.CorrelationID != "" {
+		correlationID = payment.CorrelationID
+	}
+	if err := RecordPaymentChange(ctx, correlationID, &payment, audit.ActionUpdate); err != nil {
+		return nil, err
+	}
+
+	prescriptionID, err := primitive.ObjectIDFromHex(payment.PrescriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("payment %s has invalid prescription ID %q", payment.ID.Hex(), payment.PrescriptionID)
+	}
+
+	// Only prescriptions still waiting on this payment (or in a payment exception) move on
+	prescriptionFilter := bson.M{
+		"_id": prescriptionID,
+		"$or": []bson.M{
+			{"status": bson.M{"$in": []models.PrescriptionStatus{models.StatusAwaitingPayment, models.StatusPaid}}},
+			{"status": models.StatusException, "exception.type": bson.M{"$in": []string{models.ExceptionPaymentFailed, models.ExceptionPaymentExpired}}},
+		},
+	}
+
+	if to != models.PaymentPaid {
+		return &payment, raisePaymentException(ctx, mongoClient, producer, correlationID, prescriptionFilter, &payment, now)
+	}
+
+	updated, err := ChangePrescriptionStatus(ctx, mongoClient.GetCollection("prescriptions"), correlationID, prescriptionFilter,
+		models.StatusPaid, nil, "exception")
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		log.Printf("⚠️  [correlation_id=%s] Payment %s captured but prescription %s is no longer awaiting payment", correlationID, payment.ID.Hex(), payment.PrescriptionID)
+		return &payment, nil
+	}
+
+	// The receipt is cached at payment_receipt:{payment_id} for the status lookups that follow
+	receipt := models.PaymentReceipt{
+		PaymentID:       payment.ID.Hex(),
+		PrescriptionID:  payment.PrescriptionID,
+		PatientID:       payment.PatientID,
+		Amount:          payment.Amount,
+		Currency:        payment.Currency,
+		Provider:        payment.Provider,
+		PaymentIntentID: payment.ProviderPaymentIntentID,
+		PaidAt:          *payment.PaidAt,
+	}
+	if redis != nil {
+		if data, err := json.Marshal(receipt); err == nil {
+			if err := redis.Set(ctx, PaymentReceiptCacheKey(receipt.PaymentID), string(data), paymentReceiptCacheTTL); err != nil {
+				log.Printf("⚠️  [correlation_id=%s] Failed to cache receipt for payment %s: %v", correlationID, receipt.PaymentID, err)
+			}
+		}
+	}
+
+	// Emit payment completed event for pharmacist verification
+	paymentEvent := &events.PaymentCompleted{
+		Envelope:        events.NewEnvelope(ctx, correlationID, payment.PrescriptionID),
+		PatientID:       payment.PatientID,
+		PaymentID:       receipt.PaymentID,
+		Provider:        payment.Provider,
+		PaymentIntentID: payment.ProviderPaymentIntentID,
+		Amount:          payment.Amount,
+		Currency:        payment.Currency,
+		Status:          string(models.PaymentPaid),
+		CompletedAt:     receipt.PaidAt,
+	}
+
+	if err := events.Publish(ctx, producer, paymentEvent); err != nil {
+		return nil, fmt.Errorf("failed to publish payment completed event: %w", err)
+	}
+
+	log.Printf("✅ [correlation_id=%s] Payment %s captured for prescription: %s ($%.2f)", correlationID, receipt.PaymentID, payment.PrescriptionID, payment.Amount)
+	return &payment, nil
+}
+
+// raisePaymentException moves the prescription to exception for a failed or expired payment
+func raisePaymentException(
+	ctx context.Context,
+	mongoClient *database.MongoClient,
+	producer kafka.Producer,
+	correlationID string,
+	prescriptionFilter bson.M,
+	payment *models.Payment,
+	now time.Time,
+) error {
+	exceptionType := models.ExceptionPaymentFailed
+	if payment.Status == models.PaymentExpired {
+		exceptionType = models.ExceptionPaymentExpired
+	}
+	exception := models.PrescriptionException{
+		Type:     exceptionType,
+		Reason:   payment.FailureReason,
+		Source:   "payment",
+		RaisedAt: now,
+	}
+
+	updated, err := ChangePrescriptionStatus(ctx, mongoClient.GetCollection("prescriptions"), correlationID, prescriptionFilter,
+		models.StatusException, bson.M{"exception": exception})
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		log.Printf("⚠️  [correlation_id=%s] Payment %s %s but prescription %s is no longer awaiting payment", correlationID, payment.ID.Hex(), payment.Status, payment.PrescriptionID)
+		return nil
+	}
+
+	if err := events.PublishException(ctx, producer, correlationID, payment.PrescriptionID, exception); err != nil {
+		return fmt.Errorf("failed to publish prescription exception event: %w", err)
+	}
+
+	log.Printf("⚠️  [correlation_id=%s] Payment %s %s for prescription %s: %s", correlationID, payment.ID.Hex(), payment.Status, payment.PrescriptionID, payment.FailureReason)
+	return nil
+}
+
+// RecordPaymentChange audits a payment being created or changing status
+func RecordPaymentChange(ctx context.Context, correlationID string, payment *models.Payment, action audit.Action) error {
+	return audit.Record(ctx, audit.Entry{
+		EventType:  audit.EventPaymentChanged,
+		EntityType: audit.EntityPayment,
+		EntityID:   payment.ID.Hex(),
+		Action:     action,
+		Details: map[string]interface{}{
+			"prescription_id": payment.PrescriptionID,
+			"status":          payment.Status,
+			"amount":          payment.Amount,
+			"correlation_id":  correlationID,
+		},
+	})
+}
+
+// paymentTransition returns the payment status a provider outcome moves to and the statuses it may move from
+// A declined attempt can still be followed by a successful one on the same checkout link.
+func paymentTransition(status payments.SessionStatus) (models.PaymentStatus, []models.PaymentStatus, bool) {
+	switch status {
+	case payments.SessionPaid:
+		return models.PaymentPaid, []models.PaymentStatus{models.PaymentPending, models.PaymentFailed}, true
+	case payments.SessionFailed:
+		return models.PaymentFailed, []models.PaymentStatus{models.PaymentPending}, true
+	case payments.SessionExpired:
+		return models.PaymentExpired, []models.PaymentStatus{models.PaymentPending, models.PaymentFailed}, true
+	}
+	return "", nil, false
+}
+
+// paymentEventFilter selects the payment a provider event refers to, preferring our own payment ID
+func paymentEventFilter(event *payments.WebhookEvent) bson.M {
+	if id, err := primitive.ObjectIDFromHex(event.PaymentID); err == nil {
+		return bson.M{"_id": id}
+	}
+	if event.SessionID != "" {
+		return bson.M{"provider_session_id": event.SessionID}
+	}
+	if event.PaymentIntentID != "" {
+		return bson.M{"provider_payment_intent_id": event.PaymentIntentID}
+	}
+	return nil
+}
+
+// PaymentReceiptCacheKey returns the Redis key the receipt for a payment is cached under
+func PaymentReceiptCacheKey(paymentID string) string {
+	return "payment_receipt:" + paymentID
+}
@@ -0,0 +1,49 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PharmacyAuthService authenticates partner pharmacies by API key
+// Only the SHA-256 hash of each key is stored, on the pharmacy document as api_key_hash
+type PharmacyAuthService struct {
+	mongoClient *database.MongoClient
+}
+
+// NewPharmacyAuthService creates a new pharmacy auth service
+func NewPharmacyAuthService(mongoClient *database.MongoClient) *PharmacyAuthService {
+	return &PharmacyAuthService{
+		mongoClient: mongoClient,
+	}
+}
+
+// Authenticate returns the active pharmacy owning the API key, or nil if the key matches none
+func (s *PharmacyAuthService) Authenticate(ctx context.Context, apiKey string) (*models.Pharmacy, error) {
+	var pharmacy models.Pharmacy
+	err := s.mongoClient.GetCollection("pharmacies").FindOne(ctx, bson.M{
+		"api_key_hash": HashAPIKey(apiKey),
+		"active":       true,
+	}).Decode(&pharmacy)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up pharmacy by api key: %w", err)
+	}
+	return &pharmacy, nil
+}
+
+// HashAPIKey returns the hex SHA-256 hash stored for an API key
+func HashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,409 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Scoring factor names stored in the recommendation breakdown
+const (
+	FactorDistance = "distance"
+	FactorNetwork  = "insurance_network"
+	FactorCapacity = "capacity"
+	FactorServices = "services"
+	FactorHours    = "opening_hours"
+)
+
+const metersPerMile = 1609.344
+
+// ScoringWeights holds the relative weight of each pharmacy scoring factor
+type ScoringWeights struct {
+	Distance float64
+	Network  float64
+	Capacity float64
+	Services float64
+	Hours    float64
+}
+
+// DefaultScoringWeights returns the weights used by the routing worker
+// Distance 30%, insurance network 25%, capacity 20%, services 15%, opening hours 10%
+func DefaultScoringWeights() ScoringWeights {
+	return ScoringWeights{
+		Distance: 0.30,
+		Network:  0.25,
+		Capacity: 0.20,
+		Services: 0.15,
+		Hours:    0.10,
+	}
+}
+
+// PharmacyScoringService ranks partner pharmacies for a prescription
+type PharmacyScoringService struct {
+	mongoClient        *database.MongoClient
+	capacity           *PharmacyCapacityService
+	contracts          *NetworkContractService
+	weights            ScoringWeights
+	distanceScoreMiles float64 // distance at which the distance factor reaches 0; farther pharmacies are still ranked
+}
+
+// RecommendationRequest describes the prescription being routed
+type RecommendationRequest struct {
+	PatientLocation *models.GeoPoint
+	Plan            models.PlanKey // empty BIN = no insurance, network is not enforced
+	NDC             string
+	Limit           int // number of recommendations to return (default 5)
+}
+
+// PharmacyScoreInput collects the facts used to score a single pharmacy
+type PharmacyScoreInput struct {
+	Pharmacy         *models.Pharmacy
+	DistanceMiles    *float64 // nil if the patient location is unknown
+	Utilization      *float64 // nil if capacity is unknown
+	Insured          bool
+	Contract         *models.NetworkContract // active contract for the plan, nil if out of network
+	RequiredServices []string
+	At               time.Time
+}
+
+// NewPharmacyScoringService creates a new pharmacy scoring service
+func NewPharmacyScoringService(mongoClient *database.MongoClient, capacity *PharmacyCapacityService, contracts *NetworkContractService) *PharmacyScoringService {
+	return &PharmacyScoringService{
+		mongoClient:        mongoClient,
+		capacity:           capacity,
+		contracts:          contracts,
+		weights:            DefaultScoringWeights(),
+		distanceScoreMiles: 50,
+	}
+}
+
+// Recommend scores all active in-network pharmacies and returns the top ranked recommendations
+// Pharmacies without an active contract for the prescription's plan are excluded
+func (s *PharmacyScoringService) Recommend(ctx context.Context, req RecommendationRequest) ([]models.PharmacyRecommendation, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 5
+	}
+
+	pharmacies, err := s.findCandidates(ctx, req.PatientLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	requiredServices, err := s.requiredServices(ctx, req.NDC)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	insured := req.Plan.BIN != ""
+
+	var contracts map[string]models.NetworkContract
+	if insured {
+		contracts, err = s.contracts.InNetworkPharmacies(ctx, req.Plan, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	recommendations := make([]models.PharmacyRecommendation, 0, len(pharmacies))
+	for i := range pharmacies {
+		pharmacy := &pharmacies[i]
+
+		var contract *models.NetworkContract
+		if insured {
+			c, ok := contracts[pharmacy.NCPDPID]
+			if !ok {
+				continue // out of network
+			}
+			contract = &c
+		}
+
+		var distanceMiles *float64
+		if pharmacy.DistanceMeters != nil {
+			miles := *pharmacy.DistanceMeters / metersPerMile
+			distanceMiles = &miles
+		}
+
+		input := PharmacyScoreInput{
+			Pharmacy:         pharmacy,
+			DistanceMiles:    distanceMiles,
+			Utilization:      s.utilization(ctx, pharmacy),
+			Insured:          insured,
+			Contract:         contract,
+			RequiredServices: requiredServices,
+			At:               now,
+		}
+
+		score, factors := ScorePharmacy(input, s.weights, s.distanceScoreMiles)
+		recommendations = append(recommendations, models.PharmacyRecommendation{
+			PharmacyID:    pharmacy.ID.Hex(),
+			NCPDPID:       pharmacy.NCPDPID,
+			Name:          pharmacy.Name,
+			Score:         score,
+			DistanceMiles: distanceMiles,
+			Factors:       factors,
+			GeneratedAt:   now,
+		})
+	}
+
+	RankRecommendations(recommendations)
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+
+	return recommendations, nil
+}
+
+// findCandidates loads active pharmacies, ordered by distance when the patient location is known
+// There is no distance cutoff: mail-order and specialty pharmacies far from the patient are often the
+// only in-network option, so distance lowers their score instead of excluding them.
+func (s *PharmacyScoringService) findCandidates(ctx context.Context, location *models.GeoPoint) ([]models.Pharmacy, error) {
+	collection := s.mongoClient.GetCollection("pharmacies")
+
+	var cursor *mongo.Cursor
+	var err error
+	if location != nil && len(location.Coordinates) == 2 {
+		// $geoNear uses the idx_location_2dsphere index on pharmacies.location
+		pipeline := mongo.Pipeline{
+			{{Key: "$geoNear", Value: bson.D{
+				{Key: "near", Value: bson.D{
+					{Key: "type", Value: "Point"},
+					{Key: "coordinates", Value: location.Coordinates},
+				}},
+				{Key: "distanceField", Value: "distance_meters"},
+				{Key: "query", Value: bson.M{"active": true}},
+				{Key: "spherical", Value: true},
+			}}},
+		}
+		cursor, err = collection.Aggregate(ctx, pipeline)
+	} else {
+		cursor, err = collection.Find(ctx, bson.M{"active": true})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pharmacies: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var pharmacies []models.Pharmacy
+	if err := cursor.All(ctx, &pharmacies); err != nil {
+		return nil, fmt.Errorf("failed to decode pharmacies: %w", err)
+	}
+
+	return pharmacies, nil
+}
+
+// requiredServices returns the pharmacy services the drug needs, if the drug is known
+func (s *PharmacyScoringService) requiredServices(ctx context.Context, ndc string) ([]string, error) {
+	if ndc == "" {
+		return nil, nil
+	}
+
+	var drug models.Drug
+	err := s.mongoClient.GetCollection("drugs").FindOne(ctx, bson.M{"ndc": ndc}).Decode(&drug)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up drug %s: %w", ndc, err)
+	}
+
+	return drug.RequiredServices, nil
+}
+
+// utilization returns the pharmacy's current utilization from Redis,
+// falling back to the capacity stored on the pharmacy document
+func (s *PharmacyScoringService) utilization(ctx context.Context, pharmacy *models.Pharmacy) *float64 {
+	if s.capacity != nil {
+		if data, err := s.capacity.GetCapacity(ctx, pharmacy.ID.Hex()); err == nil {
+			return &data.Utilization
+		}
+	}
+
+	if pharmacy.Capacity.MaxPrescriptionsPerDay > 0 {
+		u := float64(pharmacy.Capacity.CurrentDailyCount) / float64(pharmacy.Capacity.MaxPrescriptionsPerDay)
+		return &u
+	}
+
+	return nil
+}
+
+// ScorePharmacy computes the weighted score (0.0 - 1.0) and per-factor breakdown for a pharmacy
+func ScorePharmacy(input PharmacyScoreInput, weights ScoringWeights, maxDistanceMiles float64) (float64, []models.ScoreFactor) {
+	factors := []models.ScoreFactor{
+		scoreDistance(input.DistanceMiles, maxDistanceMiles, weights.Distance),
+		scoreNetwork(input.Insured, input.Contract, weights.Network),
+		scoreCapacity(input.Utilization, weights.Capacity),
+		scoreServices(input.Pharmacy.Services, input.RequiredServices, weights.Services),
+		scoreHours(input.Pharmacy, input.At, weights.Hours),
+	}
+
+	total := 0.0
+	totalWeight := 0.0
+	for _, f := range factors {
+		total += f.Weighted
+		totalWeight += f.Weight
+	}
+	if totalWeight > 0 {
+		total = total / totalWeight
+	}
+
+	return roundScore(total), factors
+}
+
+// RankRecommendations sorts recommendations by score (then distance) and assigns ranks
+func RankRecommendations(recommendations []models.PharmacyRecommendation) {
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		a, b := recommendations[i], recommendations[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.DistanceMiles != nil && b.DistanceMiles != nil {
+			return *a.DistanceMiles < *b.DistanceMiles
+		}
+		return a.DistanceMiles != nil
+	})
+
+	for i := range recommendations {
+		recommendations[i].Rank = i + 1
+	}
+}
+
+func newFactor(name string, weight, score float64, detail string) models.ScoreFactor {
+	score = roundScore(score)
+	return models.ScoreFactor{
+		Name:     name,
+		Weight:   weight,
+		Score:    score,
+		Weighted: roundScore(weight * score),
+		Detail:   detail,
+	}
+}
+
+func scoreDistance(distanceMiles *float64, maxDistanceMiles, weight float64) models.ScoreFactor {
+	if distanceMiles == nil || maxDistanceMiles <= 0 {
+		return newFactor(FactorDistance, weight, 0.5, "patient location unknown")
+	}
+
+	score := math.Max(0, 1-*distanceMiles/maxDistanceMiles)
+	return newFactor(FactorDistance, weight, score, fmt.Sprintf("%.1f miles", *distanceMiles))
+}
+
+func scoreNetwork(insured bool, contract *models.NetworkContract, weight float64) models.ScoreFactor {
+	if !insured {
+		return newFactor(FactorNetwork, weight, 0.5, "no insurance BIN on prescription")
+	}
+	if contract == nil {
+		return newFactor(FactorNetwork, weight, 0, "out of network")
+	}
+	if contract.NetworkTier == models.NetworkTierPreferred {
+		return newFactor(FactorNetwork, weight, 1, fmt.Sprintf("preferred network for BIN %s", contract.Plan.BIN))
+	}
+	return newFactor(FactorNetwork, weight, 0.75, fmt.Sprintf("standard network for BIN %s", contract.Plan.BIN))
+}
+
+func scoreCapacity(utilization *float64, weight float64) models.ScoreFactor {
+	if utilization == nil {
+		return newFactor(FactorCapacity, weight, 0.5, "capacity unknown")
+	}
+
+	score := math.Max(0, math.Min(1, 1-*utilization))
+	return newFactor(FactorCapacity, weight, score, fmt.Sprintf("%.0f%% utilized", *utilization*100))
+}
+
+func scoreServices(offered, required []string, weight float64) models.ScoreFactor {
+	if len(required) == 0 {
+		return newFactor(FactorServices, weight, 1, "no special services required")
+	}
+
+	available := make(map[string]bool, len(offered))
+	for _, s := range offered {
+		available[s] = true
+	}
+
+	var missing []string
+	for _, s := range required {
+		if !available[s] {
+			missing = append(missing, s)
+		}
+	}
+
+	score := float64(len(required)-len(missing)) / float64(len(required))
+	if len(missing) == 0 {
+		return newFactor(FactorServices, weight, score, "all required services offered")
+	}
+	return newFactor(FactorServices, weight, score, "missing: "+strings.Join(missing, ", "))
+}
+
+func scoreHours(pharmacy *models.Pharmacy, at time.Time, weight float64) models.ScoreFactor {
+	open, known := IsPharmacyOpen(pharmacy, at)
+	switch {
+	case !known:
+		return newFactor(FactorHours, weight, 0.5, "opening hours unknown")
+	case open:
+		return newFactor(FactorHours, weight, 1, "open now")
+	default:
+		return newFactor(FactorHours, weight, 0, "closed now")
+	}
+}
+
+// IsPharmacyOpen reports whether the pharmacy is open at the given time.
+// known is false when the pharmacy has no parsable hours for that day.
+func IsPharmacyOpen(pharmacy *models.Pharmacy, at time.Time) (open bool, known bool) {
+	if len(pharmacy.Hours) == 0 {
+		return false, false
+	}
+
+	if pharmacy.Timezone != "" {
+		if loc, err := time.LoadLocation(pharmacy.Timezone); err == nil {
+			at = at.In(loc)
+		}
+	}
+
+	day := strings.ToLower(at.Weekday().String())
+	hours, ok := pharmacy.Hours[day]
+	if !ok {
+		return false, false
+	}
+
+	hours = strings.TrimSpace(strings.ToLower(hours))
+	switch {
+	case hours == "closed":
+		return false, true
+	case strings.Contains(hours, "24 hours"):
+		return true, true
+	}
+
+	parts := strings.Split(hours, "-")
+	if len(parts) != 2 {
+		return false, false
+	}
+	openAt, err1 := time.Parse("3:04 PM", strings.ToUpper(strings.TrimSpace(parts[0])))
+	closeAt, err2 := time.Parse("3:04 PM", strings.ToUpper(strings.TrimSpace(parts[1])))
+	if err1 != nil || err2 != nil {
+		return false, false
+	}
+
+	minute := at.Hour()*60 + at.Minute()
+	openMinute := openAt.Hour()*60 + openAt.Minute()
+	closeMinute := closeAt.Hour()*60 + closeAt.Minute()
+
+	if closeMinute <= openMinute {
+		// Overnight hours, e.g. "10:00 PM - 6:00 AM"
+		return minute >= openMinute || minute < closeMinute, true
+	}
+	return minute >= openMinute && minute < closeMinute, true
+}
+
+func roundScore(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
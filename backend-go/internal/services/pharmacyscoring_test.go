@@ -0,0 +1,212 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func testPharmacy() *models.Pharmacy {
+	return &models.Pharmacy{
+		Name: "Test Pharmacy",
+		Hours: map[string]string{
+			"monday": "8:00 AM - 9:00 PM",
+			"sunday": "Closed",
+		},
+		Services: []string{"prescription_filling", "specialty_dispensing"},
+	}
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// TestScorePharmacy_BestCase tests that a nearby, in-network, open pharmacy scores near 1
+func TestScorePharmacy_BestCase(t *testing.T) {
+	monday := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	score, factors := ScorePharmacy(PharmacyScoreInput{
+		Pharmacy:      testPharmacy(),
+		DistanceMiles: floatPtr(0),
+		Utilization:   floatPtr(0),
+		Insured:       true,
+		Contract: &models.NetworkContract{
+			Plan:        models.PlanKey{BIN: "123456", PCN: "ABC"},
+			NetworkTier: models.NetworkTierPreferred,
+		},
+		RequiredServices: []string{"specialty_dispensing"},
+		At:               monday,
+	}, DefaultScoringWeights(), 50)
+
+	if score != 1 {
+		t.Errorf("Expected score 1, got %.4f", score)
+	}
+
+	if len(factors) != 5 {
+		t.Fatalf("Expected 5 factors, got %d", len(factors))
+	}
+
+	for _, f := range factors {
+		if f.Score != 1 {
+			t.Errorf("Expected factor %s to score 1, got %.4f (%s)", f.Name, f.Score, f.Detail)
+		}
+	}
+}
+
+// TestScorePharmacy_Breakdown tests individual factor scores
+func TestScorePharmacy_Breakdown(t *testing.T) {
+	sunday := time.Date(2024, 1, 14, 10, 0, 0, 0, time.UTC)
+
+	_, factors := ScorePharmacy(PharmacyScoreInput{
+		Pharmacy:         testPharmacy(),
+		DistanceMiles:    floatPtr(25),
+		Utilization:      floatPtr(0.75),
+		Insured:          true,
+		RequiredServices: []string{"specialty_dispensing", "cold_chain_storage"},
+		At:               sunday,
+	}, DefaultScoringWeights(), 50)
+
+	expected := map[string]float64{
+		FactorDistance: 0.5,
+		FactorNetwork:  0,
+		FactorCapacity: 0.25,
+		FactorServices: 0.5,
+		FactorHours:    0,
+	}
+
+	for _, f := range factors {
+		want, ok := expected[f.Name]
+		if !ok {
+			t.Errorf("Unexpected factor %s", f.Name)
+			continue
+		}
+		if f.Score != want {
+			t.Errorf("Expected %s score %.4f, got %.4f (%s)", f.Name, want, f.Score, f.Detail)
+		}
+		if f.Weighted != roundScore(f.Weight*f.Score) {
+			t.Errorf("Expected %s weighted %.4f, got %.4f", f.Name, f.Weight*f.Score, f.Weighted)
+		}
+	}
+}
+
+// TestScorePharmacy_UnknownFactors tests that missing data scores neutrally
+func TestScorePharmacy_UnknownFactors(t *testing.T) {
+	pharmacy := &models.Pharmacy{Name: "Bare Pharmacy"}
+
+	score, _ := ScorePharmacy(PharmacyScoreInput{
+		Pharmacy: pharmacy,
+		At:       time.Now(),
+	}, DefaultScoringWeights(), 50)
+
+	// Distance, network, capacity and hours are unknown (0.5), no services required (1.0)
+	weights := DefaultScoringWeights()
+	want := roundScore(0.5*(weights.Distance+weights.Network+weights.Capacity+weights.Hours) + weights.Services)
+	if score != want {
+		t.Errorf("Expected score %.4f, got %.4f", want, score)
+	}
+}
+
+// TestIsPharmacyOpen tests opening hours parsing
+func TestIsPharmacyOpen(t *testing.T) {
+	pharmacy := &models.Pharmacy{
+		Hours: map[string]string{
+			"monday":   "8:00 AM - 9:00 PM",
+			"tuesday":  "10:00 PM - 6:00 AM",
+			"saturday": "Open 24 hours",
+			"sunday":   "Closed",
+		},
+	}
+
+	tests := []struct {
+		name      string
+		at        time.Time
+		wantOpen  bool
+		wantKnown bool
+	}{
+		{"monday morning", time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC), true, true},
+		{"monday before open", time.Date(2024, 1, 15, 7, 59, 0, 0, time.UTC), false, true},
+		{"monday at close", time.Date(2024, 1, 15, 21, 0, 0, 0, time.UTC), false, true},
+		{"tuesday overnight", time.Date(2024, 1, 16, 23, 30, 0, 0, time.UTC), true, true},
+		{"tuesday midday", time.Date(2024, 1, 16, 12, 0, 0, 0, time.UTC), false, true},
+		{"wednesday missing", time.Date(2024, 1, 17, 12, 0, 0, 0, time.UTC), false, false},
+		{"saturday 24 hours", time.Date(2024, 1, 20, 3, 0, 0, 0, time.UTC), true, true},
+		{"sunday closed", time.Date(2024, 1, 21, 12, 0, 0, 0, time.UTC), false, true},
+	}
+
+	for _, tt := range tests {
+		open, known := IsPharmacyOpen(pharmacy, tt.at)
+		if open != tt.wantOpen || known != tt.wantKnown {
+			t.Errorf("%s: expected open=%v known=%v, got open=%v known=%v", tt.name, tt.wantOpen, tt.wantKnown, open, known)
+		}
+	}
+}
+
+// TestRankRecommendations tests ordering by score then distance
+func TestRankRecommendations(t *testing.T) {
+	recs := []models.PharmacyRecommendation{
+		{PharmacyID: "far", Score: 0.8, DistanceMiles: floatPtr(10)},
+		{PharmacyID: "low", Score: 0.4, DistanceMiles: floatPtr(1)},
+		{PharmacyID: "near", Score: 0.8, DistanceMiles: floatPtr(2)},
+		{PharmacyID: "best", Score: 0.9},
+	}
+
+	RankRecommendations(recs)
+
+	expectedOrder := []string{"best", "near", "far", "low"}
+	for i, id := range expectedOrder {
+		if recs[i].PharmacyID != id {
+			t.Errorf("Expected %s at position %d, got %s", id, i, recs[i].PharmacyID)
+		}
+		if recs[i].Rank != i+1 {
+			t.Errorf("Expected rank %d for %s, got %d", i+1, id, recs[i].Rank)
+		}
+	}
+}
+
+// TestPharmacyScoringService_RecommendFarPharmacy tests that a pharmacy far from the patient is ranked, not excluded
+func TestPharmacyScoringService_RecommendFarPharmacy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	mongoClient := getTestMongoClient(t)
+	ctx := context.Background()
+	defer mongoClient.Disconnect(ctx)
+	defer mongoClient.Database.Drop(ctx)
+
+	pharmacies := mongoClient.GetCollection("pharmacies")
+	if _, err := pharmacies.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "location", Value: "2dsphere"}}}); err != nil {
+		t.Fatalf("Failed to create location index: %v", err)
+	}
+	_, err := pharmacies.InsertMany(ctx, []interface{}{
+		models.Pharmacy{NCPDPID: "1000001", Name: "Corner Pharmacy", Active: true,
+			Location: &models.GeoPoint{Type: "Point", Coordinates: []float64{-87.6298, 41.8781}}},
+		models.Pharmacy{NCPDPID: "1000002", Name: "Specialty Mail Order", Active: true,
+			Location: &models.GeoPoint{Type: "Point", Coordinates: []float64{-96.7970, 32.7767}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert pharmacies: %v", err)
+	}
+
+	service := NewPharmacyScoringService(mongoClient, nil, nil)
+	recommendations, err := service.Recommend(ctx, RecommendationRequest{
+		PatientLocation: &models.GeoPoint{Type: "Point", Coordinates: []float64{-87.6298, 41.8781}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to recommend pharmacies: %v", err)
+	}
+	if len(recommendations) != 2 {
+		t.Fatalf("Expected both pharmacies ranked, got %d", len(recommendations))
+	}
+	if recommendations[0].NCPDPID != "1000001" || recommendations[1].NCPDPID != "1000002" {
+		t.Errorf("Expected the nearby pharmacy ranked above the one 800 miles away, got %s then %s", recommendations[0].NCPDPID, recommendations[1].NCPDPID)
+	}
+	if far := recommendations[1]; far.DistanceMiles == nil || *far.DistanceMiles < 500 {
+		t.Errorf("Expected the far pharmacy's distance to be recorded, got %v", far.DistanceMiles)
+	}
+}
@@ -0,0 +1,132 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrSelectionConflict is returned when the prescription is no longer in the expected status
+var ErrSelectionConflict = errors.New("prescription is not awaiting pharmacy selection")
+
+// PharmacySelectionService records the pharmacy chosen for a prescription, by the routing
+// worker or by ops, and publishes pharmacy.selected
+type PharmacySelectionService struct {
+	mongoClient *database.MongoClient
+	producer    kafka.Producer
+	capacity    *PharmacyCapacityService
+}
+
+// NewPharmacySelectionService creates a new pharmacy selection service
+func NewPharmacySelectionService(mongoClient *database.MongoClient, producer kafka.Producer, capacity *PharmacyCapacityService) *PharmacySelectionService {
+	return &PharmacySelectionService{
+		mongoClient: mongoClient,
+		producer:    producer,
+		capacity:    capacity,
+	}
+}
+
+// Complete reserves capacity at the selected pharmacy, records the selection and publishes pharmacy.selected
+func (s *PharmacySelectionService) Complete(
+	ctx context.Context,
+	correlationID string,
+	prescriptionID primitive.ObjectID,
+	patientID string,
+	fromStatus models.PrescriptionStatus,
+	selection models.PharmacySelection,
+) (*models.PharmacySelection, error) {
+	collection := s.mongoClient.GetCollection("prescriptions")
+	var rx models.Prescription
+	err := collection.FindOne(ctx, bson.M{"_id": prescriptionID}).Decode(&rx)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrSelectionConflict
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prescription: %w", err)
+	}
+	// A retry of a selection whose event was not published publishes it again without reserving twice
+	if SelectionResumable(&rx, selection.PharmacyID) {
+		return s.Resume(ctx, correlationID, &rx)
+	}
+	if rx.Status != fromStatus {
+		return nil, ErrSelectionConflict
+	}
+
+	// Reserve capacity at the selected pharmacy
+	current, utilization, err := s.capacity.IncrementCapacity(ctx, selection.PharmacyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve pharmacy capacity: %w", err)
+	}
+	log.Printf("📈 [correlation_id=%s] Reserved capacity at pharmacy %s (%d today, %.0f%% utilized)", correlationID, selection.PharmacyID, current, utilization*100)
+
+	filter := bson.M{"_id": prescriptionID, "status": fromStatus}
+	updated, err := ChangePrescriptionStatus(ctx, collection, correlationID, filter, models.StatusPharmacySelected, bson.M{
+		"pharmacy_id":        selection.PharmacyID,
+		"pharmacy_selection": selection,
+	})
+	if err == nil && updated == nil {
+		err = ErrSelectionConflict
+	}
+	// A selection that was not recorded releases its reservation
+	if err != nil {
+		if _, _, releaseErr := s.capacity.DecrementCapacity(ctx, selection.PharmacyID); releaseErr != nil {
+			log.Printf("⚠️  [correlation_id=%s] Failed to release capacity at pharmacy %s: %v", correlationID, selection.PharmacyID, releaseErr)
+		}
+		return nil, err
+	}
+
+	if err := PublishPharmacySelected(ctx, s.producer, correlationID, prescriptionID, patientID, selection); err != nil {
+		return nil, err
+	}
+	return &selection, nil
+}
+
+// Resume publishes pharmacy.selected again for a prescription still waiting in pharmacy_selected
+func (s *PharmacySelectionService) Resume(ctx context.Context, correlationID string, rx *models.Prescription) (*models.PharmacySelection, error) {
+	if rx.Status != models.StatusPharmacySelected || rx.PharmacySelection == nil {
+		return nil, ErrSelectionConflict
+	}
+	log.Printf("🔁 [correlation_id=%s] Prescription %s already selected pharmacy %s, publishing pharmacy.selected again", correlationID, rx.ID.Hex(), rx.PharmacySelection.PharmacyID)
+	if err := PublishPharmacySelected(ctx, s.producer, correlationID, rx.ID, rx.PatientID, *rx.PharmacySelection); err != nil {
+		return nil, err
+	}
+	return rx.PharmacySelection, nil
+}
+
+// SelectionResumable reports whether a prescription records a selection of the pharmacy not yet taken up by adjudication
+func SelectionResumable(rx *models.Prescription, pharmacyID string) bool {
+	return rx.Status == models.StatusPharmacySelected &&
+		rx.PharmacySelection != nil &&
+		rx.PharmacySelection.PharmacyID == pharmacyID
+}
+
+// PublishPharmacySelected publishes pharmacy.selected, which starts adjudication
+func PublishPharmacySelected(
+	ctx context.Context,
+	producer kafka.Producer,
+	correlationID string,
+	prescriptionID primitive.ObjectID,
+	patientID string,
+	selection models.PharmacySelection,
+) error {
+	return events.Publish(ctx, producer, &events.PharmacySelected{
+		Envelope:        events.NewEnvelope(ctx, correlationID, prescriptionID.Hex()),
+		PatientID:       patientID,
+		PharmacyID:      selection.PharmacyID,
+		PharmacyNCPDPID: selection.NCPDPID,
+		PharmacyName:    selection.Name,
+		SelectionMode:   string(selection.Mode),
+		SelectedBy:      selection.SelectedBy,
+		SelectedAt:      selection.SelectedAt,
+	})
+}
@@ -0,0 +1,32 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"testing"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+// TestSelectionResumable tests which repeated selections publish the stored one again
+func TestSelectionResumable(t *testing.T) {
+	selected := &models.PharmacySelection{PharmacyID: "pharm_1"}
+
+	tests := []struct {
+		name       string
+		rx         *models.Prescription
+		pharmacyID string
+		want       bool
+	}{
+		{"same pharmacy still selected", &models.Prescription{Status: models.StatusPharmacySelected, PharmacySelection: selected}, "pharm_1", true},
+		{"other pharmacy", &models.Prescription{Status: models.StatusPharmacySelected, PharmacySelection: selected}, "pharm_2", false},
+		{"no selection recorded", &models.Prescription{Status: models.StatusPharmacySelected}, "pharm_1", false},
+		{"awaiting routing", &models.Prescription{Status: models.StatusAwaitingRouting}, "pharm_1", false},
+		{"already adjudicating", &models.Prescription{Status: models.StatusAwaitingAdjudication, PharmacySelection: selected}, "pharm_1", false},
+	}
+
+	for _, tt := range tests {
+		if got := SelectionResumable(tt.rx, tt.pharmacyID); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PrescriptionStatusUpdate returns the Mongo update moving a prescription to a status
+// It sets the status and updated_at along with the given fields, and appends the change to the
+// prescription's status_history with the correlation ID, the event being handled and the producer
+// from the context, so the flow can be reconstructed by correlation ID.
+func PrescriptionStatusUpdate(ctx context.Context, correlationID string, status models.PrescriptionStatus, set bson.M) bson.M {
+	now := time.Now()
+	fields := bson.M{
+		"status":     status,
+		"updated_at": now,
+	}
+	for field, value := range set {
+		fields[field] = value
+	}
+
+	return bson.M{
+		"$set":  fields,
+		"$push": bson.M{"status_history": NewStatusChange(ctx, correlationID, status, now)},
+	}
+}
+
+// NewStatusChange returns the status history entry for a prescription moving to a status
+func NewStatusChange(ctx context.Context, correlationID string, status models.PrescriptionStatus, at time.Time) models.StatusChange {
+	return models.StatusChange{
+		Status:        status,
+		CorrelationID: correlationID,
+		CausationID:   events.CausationID(ctx),
+		Producer:      events.Producer(ctx),
+		At:            at,
+	}
+}
+
+// ChangePrescriptionStatus moves the prescription matching filter to a status, returning it as it was or nil if none matches
+func ChangePrescriptionStatus(
+	ctx context.Context,
+	prescriptions *mongo.Collection,
+	correlationID string,
+	filter bson.M,
+	status models.PrescriptionStatus,
+	set bson.M,
+	unset ...string,
+) (*models.Prescription, error) {
+	var rx models.Prescription
+	err := prescriptions.FindOne(ctx, filter).Decode(&rx)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prescription: %w", err)
+	}
+	// The audit entry is required, so a change that cannot be audited is not made
+	if err := recordStatusChange(ctx, correlationID, rx.ID.Hex(), status); err != nil {
+		return nil, err
+	}
+
+	update := PrescriptionStatusUpdate(ctx, correlationID, status, set)
+	if len(unset) > 0 {
+		fields := bson.M{}
+		for _, field := range unset {
+			fields[field] = ""
+		}
+		update["$unset"] = fields
+	}
+	matched := bson.M{"_id": rx.ID}
+	for field, value := range filter {
+		matched[field] = value
+	}
+	result, err := prescriptions.UpdateOne(ctx, matched, update)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update prescription status: %w", err)
+	}
+	// A prescription that stopped matching after the audit leaves an entry for a change that was not made
+	if result.MatchedCount == 0 {
+		return nil, nil
+	}
+	return &rx, nil
+}
+
+// recordStatusChange audits a prescription moving to a status, before the change is written
+func recordStatusChange(ctx context.Context, correlationID, prescriptionID string, status models.PrescriptionStatus) error {
+	if err := audit.Record(ctx, audit.StatusChange(prescriptionID, string(status), correlationID)); err != nil {
+		return fmt.Errorf("failed to audit prescription %s moving to %s: %w", prescriptionID, status, err)
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrPriorAuthNotFound is returned when no prior authorization has the requested ID
+	ErrPriorAuthNotFound = errors.New("prior authorization not found")
+	// ErrPriorAuthConflict is returned when the prior authorization changed status concurrently
+	ErrPriorAuthConflict = errors.New("prior authorization status changed concurrently")
+	// ErrInvalidPriorAuthTransition is returned when a status change is not allowed or is missing details
+	ErrInvalidPriorAuthTransition = errors.New("invalid prior authorization transition")
+)
+
+// priorAuthTransitions lists the statuses each prior authorization status may move to
+var priorAuthTransitions = map[models.PriorAuthStatus][]models.PriorAuthStatus{
+	models.PriorAuthRequested:    {models.PriorAuthSubmitted, models.PriorAuthCancelled},
+	models.PriorAuthSubmitted:    {models.PriorAuthPendingPayer, models.PriorAuthCancelled},
+	models.PriorAuthPendingPayer: {models.PriorAuthApproved, models.PriorAuthDenied, models.PriorAuthCancelled},
+	models.PriorAuthDenied:       {models.PriorAuthAppealed, models.PriorAuthCancelled},
+	models.PriorAuthAppealed:     {models.PriorAuthApproved, models.PriorAuthDenied, models.PriorAuthCancelled},
+}
+
+// PriorAuthTransitionRequest is a requested status change and the payer details that go with it
+type PriorAuthTransitionRequest struct {
+	Status         models.PriorAuthStatus
+	By             string
+	Note           string
+	PayerReference string
+	ExpiresAt      *time.Time // required for approved
+	DenialReason   string     // required for denied
+	AppealDeadline *time.Time // optional for denied
+}
+
+// PriorAuthFilter narrows a prior authorization listing
+type PriorAuthFilter struct {
+	Status         models.PriorAuthStatus
+	PrescriptionID string
+	Limit          int64
+}
+
+// PriorAuthService tracks prior authorizations through their lifecycle
+type PriorAuthService struct {
+	mongoClient *database.MongoClient
+}
+
+// NewPriorAuthService creates a new prior authorization service
+func NewPriorAuthService(mongoClient *database.MongoClient) *PriorAuthService {
+	return &PriorAuthService{
+		mongoClient: mongoClient,
+	}
+}
+
+// Open returns the prescription's open prior authorization, creating one in requested status if there is none
+// An unexpired approval or a denial that may still be appealed counts as open
+func (s *PriorAuthService) Open(ctx context.Context, prescription *models.Prescription, source, by, note string) (*models.PriorAuthorization, bool, error) {
+	collection := s.mongoClient.GetCollection("prior_authorizations")
+	now := time.Now()
+
+	var existing models.PriorAuthorization
+	err := collection.FindOne(ctx, bson.M{
+		"prescription_id": prescription.ID,
+		"status":          bson.M{"$ne": models.PriorAuthCancelled},
+		"$or": bson.A{
+			bson.M{"status": bson.M{"$ne": models.PriorAuthApproved}},
+			bson.M{"expires_at": bson.M{"$gt": now}},
+		},
+	}).Decode(&existing)
+	if err == nil {
+		return &existing, false, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, fmt.Errorf("failed to look up open prior authorization: %w", err)
+	}
+
+	pa := NewPriorAuthorization(prescription, source, by, note, now)
+	pa.CausationID = events.CausationID(ctx)
+	if cause, ok := events.Cause(ctx); ok && pa.CorrelationID == "" {
+		pa.CorrelationID = cause.CorrelationID
+	}
+	pa.ID = primitive.NewObjectID()
+	if err := recordPriorAuthChange(ctx, pa, audit.ActionCreate, ""); err != nil {
+		return nil, false, err
+	}
+	if _, err := collection.InsertOne(ctx, pa); err != nil {
+		return nil, false, fmt.Errorf("failed to create prior authorization: %w", err)
+	}
+	return pa, true, nil
+}
+
+// Get returns a prior authorization by ID
+func (s *PriorAuthService) Get(ctx context.Context, id string) (*models.PriorAuthorization, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrPriorAuthNotFound
+	}
+
+	var pa models.PriorAuthorization
+	err = s.mongoClient.GetCollection("prior_authorizations").FindOne(ctx, bson.M{"_id": oid}).Decode(&pa)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPriorAuthNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prior authorization %s: %w", id, err)
+	}
+	return &pa, nil
+}
+
+// List returns prior authorizations matching the filter, most recently updated first
+func (s *PriorAuthService) List(ctx context.Context, filter PriorAuthFilter) ([]models.PriorAuthorization, error) {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.PrescriptionID != "" {
+		oid, err := primitive.ObjectIDFromHex(filter.PrescriptionID)
+		if err != nil {
+			return []models.PriorAuthorization{}, nil
+		}
+		query["prescription_id"] = oid
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}).SetLimit(filter.Limit)
+	cursor, err := s.mongoClient.GetCollection("prior_authorizations").Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prior authorizations: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	pas := []models.PriorAuthorization{}
+	if err := cursor.All(ctx, &pas); err != nil {
+		return nil, fmt.Errorf("failed to decode prior authorizations: %w", err)
+	}
+	return pas, nil
+}
+
+// Transition moves a prior authorization to a new status and returns it with the status it left
+// Returns ErrPriorAuthConflict if the status changed since it was loaded
+func (s *PriorAuthService) Transition(ctx context.Context, id string, req PriorAuthTransitionRequest) (*models.PriorAuthorization, models.PriorAuthStatus, error) {
+	pa, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	from := pa.Status
+	if err := ApplyPriorAuthTransition(pa, req, time.Now()); err != nil {
+		return nil, "", err
+	}
+
+	if err := recordPriorAuthChange(ctx, pa, audit.ActionUpdate, from); err != nil {
+		return nil, "", err
+	}
+	result, err := s.mongoClient.GetCollection("prior_authorizations").ReplaceOne(ctx, bson.M{"_id": pa.ID, "status": from}, pa)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to update prior authorization %s: %w", id, err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, "", ErrPriorAuthConflict
+	}
+	return pa, from, nil
+}
+
+// recordPriorAuthChange audits a prior authorization being opened or changing status
+// The entry is required, so it is written before the change and an error means the change is not made.
+func recordPriorAuthChange(ctx context.Context, pa *models.PriorAuthorization, action audit.Action, from models.PriorAuthStatus) error {
+	details := map[string]interface{}{
+		"prescription_id": pa.PrescriptionID.Hex(),
+		"patient_id":      pa.PatientID,
+		"status":          pa.Status,
+		"correlation_id":  pa.CorrelationID,
+	}
+	if from != "" {
+		details["from_status"] = from
+	}
+	err := audit.Record(ctx, audit.Entry{
+		EventType:  audit.EventPriorAuthChanged,
+		EntityType: audit.EntityPriorAuthorization,
+		EntityID:   pa.ID.Hex(),
+		Action:     action,
+		Details:    details,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to audit prior authorization %s: %w", pa.ID.Hex(), err)
+	}
+	return nil
+}
+
+// ActiveApproval returns an unexpired approved prior authorization covering the claim, or nil
+// Approvals apply to the prescription they were opened for and to the member's later fills of the same drug
+func (s *PriorAuthService) ActiveApproval(ctx context.Context, prescriptionID, memberID, ndc string, at time.Time) (*models.PriorAuthorization, error) {
+	scope := bson.A{}
+	if oid, err := primitive.ObjectIDFromHex(prescriptionID); err == nil {
+		scope = append(scope, bson.M{"prescription_id": oid})
+	}
+	if memberID != "" && ndc != "" {
+		scope = append(scope, bson.M{"member_id": memberID, "ndc": ndc})
+	}
+	if len(scope) == 0 {
+		return nil, nil
+	}
+
+	var pa models.PriorAuthorization
+	err := s.mongoClient.GetCollection("prior_authorizations").FindOne(ctx, bson.M{
+		"$or":        scope,
+		"status":     models.PriorAuthApproved,
+		"expires_at": bson.M{"$gt": at},
+	}).Decode(&pa)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up prior authorization approval: %w", err)
+	}
+	return &pa, nil
+}
+
+// NewPriorAuthorization builds a prior authorization in requested status for a prescription
+// It continues the prescription's correlation ID.
+func NewPriorAuthorization(prescription *models.Prescription, source, by, note string, at time.Time) *models.PriorAuthorization {
+	return &models.PriorAuthorization{
+		PrescriptionID: prescription.ID,
+		PatientID:      prescription.PatientID,
+		PrescriberNPI:  prescription.Prescriber.NPI,
+		Plan:           prescription.Insurance.Plan(),
+		MemberID:       prescription.Insurance.MemberID,
+		NDC:            prescription.Medication.NDC,
+		Status:         models.PriorAuthRequested,
+		Source:         source,
+		History: []models.PriorAuthTransition{
+			{To: models.PriorAuthRequested, By: by, Note: note, At: at},
+		},
+		CorrelationID: prescription.CorrelationID,
+		CreatedAt:     at,
+		UpdatedAt:     at,
+	}
+}
+
+// CanTransitionPriorAuth reports whether a prior authorization may move from one status to another
+func CanTransitionPriorAuth(from, to models.PriorAuthStatus) bool {
+	for _, next := range priorAuthTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyPriorAuthTransition validates a status change and applies it to the prior authorization
+func ApplyPriorAuthTransition(pa *models.PriorAuthorization, req PriorAuthTransitionRequest, at time.Time) error {
+	if !CanTransitionPriorAuth(pa.Status, req.Status) {
+		return fmt.Errorf("%w: cannot move from %s to %s", ErrInvalidPriorAuthTransition, pa.Status, req.Status)
+	}
+
+	switch req.Status {
+	case models.PriorAuthApproved:
+		if req.ExpiresAt == nil || !req.ExpiresAt.After(at) {
+			return fmt.Errorf("%w: expires_at in the future is required to approve", ErrInvalidPriorAuthTransition)
+		}
+		pa.ExpiresAt = req.ExpiresAt
+		pa.DenialReason = ""
+		pa.AppealDeadline = nil
+	case models.PriorAuthDenied:
+		if strings.TrimSpace(req.DenialReason) == "" {
+			return fmt.Errorf("%w: denial_reason is required to deny", ErrInvalidPriorAuthTransition)
+		}
+		pa.DenialReason = strings.TrimSpace(req.DenialReason)
+		pa.AppealDeadline = req.AppealDeadline
+	case models.PriorAuthAppealed:
+		if pa.AppealDeadline != nil && at.After(*pa.AppealDeadline) {
+			return fmt.Errorf("%w: appeal deadline has passed", ErrInvalidPriorAuthTransition)
+		}
+	}
+
+	if req.PayerReference != "" {
+		pa.PayerReference = req.PayerReference
+	}
+
+	pa.History = append(pa.History, models.PriorAuthTransition{
+		From: pa.Status,
+		To:   req.Status,
+		By:   req.By,
+		Note: strings.TrimSpace(req.Note),
+		At:   at,
+	})
+	pa.Status = req.Status
+	pa.UpdatedAt = at
+	return nil
+}
+
+// CompletePriorAuthTransition publishes prior_authorization.updated for a status change and, when
+// the prior authorization was approved, re-triggers adjudication of the prescription waiting on it
+func CompletePriorAuthTransition(
+	ctx context.Context,
+	mongoClient *database.MongoClient,
+	producer kafka.Producer,
+	correlationID string,
+	pa *models.PriorAuthorization,
+	from models.PriorAuthStatus,
+) error {
+	if err := events.PublishPriorAuthUpdated(ctx, producer, correlationID, pa, from); err != nil {
+		return fmt.Errorf("failed to publish prior authorization event: %w", err)
+	}
+
+	if pa.Status != models.PriorAuthApproved {
+		return nil
+	}
+
+	retriggered, err := RetriggerAdjudication(ctx, mongoClient, producer, correlationID, pa.PrescriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to re-trigger adjudication: %w", err)
+	}
+	if retriggered {
+		log.Printf("🔁 [correlation_id=%s] Prior authorization %s approved, re-adjudicating prescription %s", correlationID, pa.ID.Hex(), pa.PrescriptionID.Hex())
+	}
+	return nil
+}
+
+// RetriggerAdjudication moves a prescription whose claim was rejected back to pharmacy_selected and
+// republishes pharmacy.selected so the adjudication worker runs the claim again.
+// Returns false if the prescription is not waiting in a claim-rejected exception.
+func RetriggerAdjudication(
+	ctx context.Context,
+	mongoClient *database.MongoClient,
+	producer kafka.Producer,
+	correlationID string,
+	prescriptionID primitive.ObjectID,
+) (bool, error) {
+	filter := bson.M{
+		"_id":                prescriptionID,
+		"status":             models.StatusException,
+		"exception.type":     models.ExceptionClaimRejected,
+		"pharmacy_selection": bson.M{"$exists": true},
+	}
+	prescription, err := ChangePrescriptionStatus(ctx, mongoClient.GetCollection("prescriptions"), correlationID, filter,
+		models.StatusPharmacySelected, nil, "exception")
+	if err != nil || prescription == nil {
+		return false, err
+	}
+	if err := PublishPharmacySelected(ctx, producer, correlationID, prescriptionID, prescription.PatientID, *prescription.PharmacySelection); err != nil {
+		return false, err
+	}
+	return true, nil
+}
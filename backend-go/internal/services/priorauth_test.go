@@ -0,0 +1,95 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+// TestCanTransitionPriorAuth tests the allowed prior authorization lifecycle transitions
+func TestCanTransitionPriorAuth(t *testing.T) {
+	tests := []struct {
+		from, to models.PriorAuthStatus
+		want     bool
+	}{
+		{models.PriorAuthRequested, models.PriorAuthSubmitted, true},
+		{models.PriorAuthSubmitted, models.PriorAuthPendingPayer, true},
+		{models.PriorAuthPendingPayer, models.PriorAuthApproved, true},
+		{models.PriorAuthPendingPayer, models.PriorAuthDenied, true},
+		{models.PriorAuthDenied, models.PriorAuthAppealed, true},
+		{models.PriorAuthAppealed, models.PriorAuthApproved, true},
+		{models.PriorAuthRequested, models.PriorAuthApproved, false},
+		{models.PriorAuthApproved, models.PriorAuthDenied, false},
+		{models.PriorAuthCancelled, models.PriorAuthRequested, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransitionPriorAuth(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransitionPriorAuth(%s, %s): expected %v, got %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
+
+// TestApplyPriorAuthTransition tests approval expiry, denial reasons, appeals and history
+func TestApplyPriorAuthTransition(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	prescription := &models.Prescription{
+		PatientID:  "patient_1",
+		Prescriber: models.PrescriberInfo{NPI: "1234567890"},
+		Medication: models.MedicationInfo{NDC: "00074433902"},
+		Insurance:  models.InsuranceInfo{BIN: "123456", MemberID: "M123"},
+	}
+
+	pa := NewPriorAuthorization(prescription, models.PriorAuthSourceClaimReject, "system", "claim rejected", now)
+	if pa.Status != models.PriorAuthRequested || len(pa.History) != 1 || pa.Plan.BIN != "123456" {
+		t.Fatalf("Unexpected new prior authorization: %+v", pa)
+	}
+
+	err := ApplyPriorAuthTransition(pa, PriorAuthTransitionRequest{Status: models.PriorAuthApproved, By: "ops"}, now)
+	if !errors.Is(err, ErrInvalidPriorAuthTransition) {
+		t.Errorf("Expected requested -> approved to be rejected, got %v", err)
+	}
+
+	for _, status := range []models.PriorAuthStatus{models.PriorAuthSubmitted, models.PriorAuthPendingPayer} {
+		if err := ApplyPriorAuthTransition(pa, PriorAuthTransitionRequest{Status: status, By: "ops"}, now); err != nil {
+			t.Fatalf("Expected transition to %s, got %v", status, err)
+		}
+	}
+
+	if err := ApplyPriorAuthTransition(pa, PriorAuthTransitionRequest{Status: models.PriorAuthDenied, By: "ops"}, now); !errors.Is(err, ErrInvalidPriorAuthTransition) {
+		t.Errorf("Expected denial without reason to be rejected, got %v", err)
+	}
+
+	deadline := now.Add(24 * time.Hour)
+	err = ApplyPriorAuthTransition(pa, PriorAuthTransitionRequest{Status: models.PriorAuthDenied, By: "ops", DenialReason: "step therapy not met", AppealDeadline: &deadline}, now)
+	if err != nil || pa.DenialReason != "step therapy not met" {
+		t.Fatalf("Expected denial, got %v (%+v)", err, pa)
+	}
+
+	if err := ApplyPriorAuthTransition(pa, PriorAuthTransitionRequest{Status: models.PriorAuthAppealed, By: "ops"}, now.Add(48*time.Hour)); !errors.Is(err, ErrInvalidPriorAuthTransition) {
+		t.Errorf("Expected appeal after the deadline to be rejected, got %v", err)
+	}
+	if err := ApplyPriorAuthTransition(pa, PriorAuthTransitionRequest{Status: models.PriorAuthAppealed, By: "ops"}, now); err != nil {
+		t.Fatalf("Expected appeal, got %v", err)
+	}
+
+	past := now.Add(-time.Hour)
+	if err := ApplyPriorAuthTransition(pa, PriorAuthTransitionRequest{Status: models.PriorAuthApproved, By: "ops", ExpiresAt: &past}, now); !errors.Is(err, ErrInvalidPriorAuthTransition) {
+		t.Errorf("Expected approval with a past expiry to be rejected, got %v", err)
+	}
+
+	expires := now.AddDate(1, 0, 0)
+	err = ApplyPriorAuthTransition(pa, PriorAuthTransitionRequest{Status: models.PriorAuthApproved, By: "ops", ExpiresAt: &expires, PayerReference: "PA-998877"}, now)
+	if err != nil {
+		t.Fatalf("Expected approval, got %v", err)
+	}
+	if pa.Status != models.PriorAuthApproved || pa.DenialReason != "" || pa.PayerReference != "PA-998877" {
+		t.Errorf("Unexpected approved prior authorization: %+v", pa)
+	}
+	if len(pa.History) != 6 || pa.History[5].From != models.PriorAuthAppealed {
+		t.Errorf("Expected 6 history entries ending appealed -> approved, got %+v", pa.History)
+	}
+}
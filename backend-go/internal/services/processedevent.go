@@ -0,0 +1,247 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+)
+
+const (
+	// DefaultProcessedEventRetention is how long handled events are remembered; Kafka keeps messages for 7 days
+	DefaultProcessedEventRetention = 7 * 24 * time.Hour
+	// DefaultProcessedEventLease is how long a claim keeps other consumers off an event before it is
+	// presumed abandoned by a crashed worker
+	DefaultProcessedEventLease = 5 * time.Minute
+	// maxClaimRetryInterval caps the backoff between attempts to claim an event another consumer holds
+	maxClaimRetryInterval = 30 * time.Second
+)
+
+var (
+	// ErrEventAlreadyProcessed is returned when a handler already completed an event
+	ErrEventAlreadyProcessed = errors.New("event already processed")
+	// ErrEventInProgress is returned when another consumer holds the claim on an event
+	ErrEventInProgress = errors.New("event is being processed by another consumer")
+	// ErrEventClaimLost is returned when a claim expired and another consumer took the event over
+	ErrEventClaimLost = errors.New("claim on event expired and was taken over")
+)
+
+// ProcessedEventStatus is where a handler is with an event
+type ProcessedEventStatus string
+
+const (
+	ProcessedEventProcessing ProcessedEventStatus = "processing" // claimed; the handler is running or crashed
+	ProcessedEventCompleted  ProcessedEventStatus = "completed"  // the handler succeeded; redeliveries are skipped
+	ProcessedEventFailed     ProcessedEventStatus = "failed"     // the handler returned an error; a redelivery resumes it
+)
+
+// ProcessedEvent is a handler's ledger entry for one event
+// Outcome holds the steps the handler recorded, e.g. the ID of a label it bought, so a retry after a
+// failure or crash picks up where the last attempt stopped instead of repeating side effects.
+type ProcessedEvent struct {
+	Handler     string               `json:"handler"`
+	Topic       string               `json:"topic"`
+	EventID     string               `json:"event_id"`
+	Status      ProcessedEventStatus `json:"status"`
+	Attempts    int                  `json:"attempts"`
+	Outcome     map[string]string    `json:"outcome,omitempty"`
+	LastError   string               `json:"last_error,omitempty"`
+	ClaimedAt   time.Time            `json:"claimed_at"`
+	CompletedAt *time.Time           `json:"completed_at,omitempty"`
+}
+
+// ProcessedEventService is the ledger of events each handler has processed, kept in Redis
+// A handler claims an event with SETNX on a lease key before running; the ledger entry records the
+// outcome and outlives the lease for the retention period.
+type ProcessedEventService struct {
+	redis     *database.RedisClient
+	retention time.Duration
+	lease     time.Duration
+}
+
+// EventClaim is a handler's hold on an event while it processes it
+type EventClaim struct {
+	service *ProcessedEventService
+	owner   string
+	event   ProcessedEvent
+}
+
+// NewProcessedEventService creates a new processed event ledger
+func NewProcessedEventService(redis *database.RedisClient, retention, lease time.Duration) *ProcessedEventService {
+	if retention <= 0 {
+		retention = DefaultProcessedEventRetention
+	}
+	if lease <= 0 {
+		lease = DefaultProcessedEventLease
+	}
+	return &ProcessedEventService{
+		redis:     redis,
+		retention: retention,
+		lease:     lease,
+	}
+}
+
+// ProcessedEventKey is the Redis key of a handler's ledger entry for an event
+// Handlers of the same type can consume several topics, so the topic is part of the key.
+func ProcessedEventKey(topic, handler, eventID string) string {
+	return fmt.Sprintf("processed_event:%s:%s:%s", topic, handler, eventID)
+}
+
+// processedEventLockKey is the Redis key of the lease on an event
+func processedEventLockKey(topic, handler, eventID string) string {
+	return ProcessedEventKey(topic, handler, eventID) + ":lock"
+}
+
+// Claim takes the lease on an event for a handler
+// It returns ErrEventAlreadyProcessed when the handler completed the event before, and ErrEventInProgress
+// while another consumer's lease is live. A claim on an event that failed or was abandoned resumes it
+// with the outcome recorded so far.
+func (s *ProcessedEventService) Claim(ctx context.Context, topic, handler, eventID string) (*EventClaim, error) {
+	owner := uuid.New().String()
+	lockKey := processedEventLockKey(topic, handler, eventID)
+
+	acquired, err := s.redis.SetNX(ctx, lockKey, owner, s.lease)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.Get(ctx, topic, handler, eventID)
+	if err != nil {
+		if acquired {
+			s.redis.DeleteIfValue(ctx, lockKey, owner)
+		}
+		return nil, err
+	}
+	if existing != nil && existing.Status == ProcessedEventCompleted {
+		if acquired {
+			s.redis.DeleteIfValue(ctx, lockKey, owner)
+		}
+		return nil, ErrEventAlreadyProcessed
+	}
+	if !acquired {
+		return nil, ErrEventInProgress
+	}
+
+	event := ProcessedEvent{Handler: handler, Topic: topic, EventID: eventID}
+	if existing != nil {
+		event = *existing
+	}
+	event.Status = ProcessedEventProcessing
+	event.Attempts++
+	event.ClaimedAt = time.Now()
+
+	claim := &EventClaim{service: s, owner: owner, event: event}
+	if err := claim.save(ctx); err != nil {
+		s.redis.DeleteIfValue(ctx, lockKey, owner)
+		return nil, err
+	}
+	return claim, nil
+}
+
+// AwaitClaim claims an event, waiting with backoff while another consumer's lease is live
+// A crashed holder's lease expires, so the wait ends by the lease duration unless ctx ends first.
+func (s *ProcessedEventService) AwaitClaim(ctx context.Context, topic, handler, eventID string) (*EventClaim, error) {
+	wait := min(time.Second, s.lease/10)
+	for {
+		claim, err := s.Claim(ctx, topic, handler, eventID)
+		if !errors.Is(err, ErrEventInProgress) {
+			return claim, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up waiting for the claim on event %s: %w", eventID, ctx.Err())
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxClaimRetryInterval, s.lease)
+	}
+}
+
+// Get returns a handler's ledger entry for an event, or nil if it has none
+func (s *ProcessedEventService) Get(ctx context.Context, topic, handler, eventID string) (*ProcessedEvent, error) {
+	val, err := s.redis.Get(ctx, ProcessedEventKey(topic, handler, eventID))
+	if errors.Is(err, database.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load processed event %s: %w", eventID, err)
+	}
+	var event ProcessedEvent
+	if err := json.Unmarshal([]byte(val), &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal processed event %s: %w", eventID, err)
+	}
+	return &event, nil
+}
+
+// Event returns the ledger entry as claimed
+func (c *EventClaim) Event() ProcessedEvent {
+	return c.event
+}
+
+// Outcome returns the value a previous attempt recorded for a step
+func (c *EventClaim) Outcome(step string) (string, bool) {
+	value, ok := c.event.Outcome[step]
+	return value, ok
+}
+
+// Record saves the outcome of a step, so a retry can skip it
+func (c *EventClaim) Record(ctx context.Context, step, value string) error {
+	if c.event.Outcome == nil {
+		c.event.Outcome = make(map[string]string)
+	}
+	c.event.Outcome[step] = value
+	return c.save(ctx)
+}
+
+// Complete marks the event processed and releases the lease
+func (c *EventClaim) Complete(ctx context.Context) error {
+	now := time.Now()
+	c.event.Status = ProcessedEventCompleted
+	c.event.CompletedAt = &now
+	c.event.LastError = ""
+	return c.release(ctx)
+}
+
+// Fail records the handler's error and releases the lease so a redelivery can resume the event
+func (c *EventClaim) Fail(ctx context.Context, handlerErr error) error {
+	c.event.Status = ProcessedEventFailed
+	c.event.LastError = handlerErr.Error()
+	return c.release(ctx)
+}
+
+// save writes the ledger entry while the claim still holds the lease
+func (c *EventClaim) save(ctx context.Context) error {
+	lockKey := processedEventLockKey(c.event.Topic, c.event.Handler, c.event.EventID)
+	holder, err := c.service.redis.Get(ctx, lockKey)
+	if err != nil && !errors.Is(err, database.ErrKeyNotFound) {
+		return fmt.Errorf("failed to check claim on event %s: %w", c.event.EventID, err)
+	}
+	if holder != c.owner {
+		return ErrEventClaimLost
+	}
+
+	data, err := json.Marshal(c.event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal processed event %s: %w", c.event.EventID, err)
+	}
+	if err := c.service.redis.Set(ctx, ProcessedEventKey(c.event.Topic, c.event.Handler, c.event.EventID), string(data), c.service.retention); err != nil {
+		return fmt.Errorf("failed to save processed event %s: %w", c.event.EventID, err)
+	}
+	return nil
+}
+
+// release saves the final ledger entry and gives up the lease
+func (c *EventClaim) release(ctx context.Context) error {
+	if err := c.save(ctx); err != nil {
+		return err
+	}
+	lockKey := processedEventLockKey(c.event.Topic, c.event.Handler, c.event.EventID)
+	if _, err := c.service.redis.DeleteIfValue(ctx, lockKey, c.owner); err != nil {
+		return fmt.Errorf("failed to release claim on event %s: %w", c.event.EventID, err)
+	}
+	return nil
+}
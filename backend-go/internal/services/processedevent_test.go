@@ -0,0 +1,156 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestProcessedEventKey tests that ledger entries are kept per topic, handler and event
+func TestProcessedEventKey(t *testing.T) {
+	key := ProcessedEventKey("payment.completed", "ShippingWorker", "evt-1")
+	if key != "processed_event:payment.completed:ShippingWorker:evt-1" {
+		t.Errorf("Unexpected key %s", key)
+	}
+	if key == ProcessedEventKey("payment.completed", "NotificationWorker", "evt-1") {
+		t.Error("Expected handlers of one topic to have separate entries")
+	}
+	if ProcessedEventKey("shipment.delivered", "NotificationWorker", "evt-1") == ProcessedEventKey("payment.link.created", "NotificationWorker", "evt-1") {
+		t.Error("Expected a handler on several topics to have separate entries per topic")
+	}
+}
+
+// TestProcessedEventService_Claim tests claiming, resuming and skipping events
+func TestProcessedEventService_Claim(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	redisClient := getTestRedisClient(t)
+	defer redisClient.Close()
+
+	service := NewProcessedEventService(redisClient, time.Minute, time.Minute)
+	ctx := context.Background()
+	topic, handler, eventID := "payment.completed", "ShippingWorker", uuid.New().String()
+	defer redisClient.Delete(ctx, ProcessedEventKey(topic, handler, eventID), ProcessedEventKey(topic, handler, eventID)+":lock")
+
+	claim, err := service.Claim(ctx, topic, handler, eventID)
+	if err != nil {
+		t.Fatalf("Failed to claim event: %v", err)
+	}
+	if _, err := service.Claim(ctx, topic, handler, eventID); !errors.Is(err, ErrEventInProgress) {
+		t.Errorf("Expected a second claim to find the event in progress, got %v", err)
+	}
+	if err := claim.Record(ctx, "shipment_id", "65a000000000000000000001"); err != nil {
+		t.Fatalf("Failed to record outcome: %v", err)
+	}
+	if err := claim.Fail(ctx, errors.New("carrier timeout")); err != nil {
+		t.Fatalf("Failed to record failure: %v", err)
+	}
+
+	// A redelivery after the failure resumes with the recorded step
+	claim, err = service.Claim(ctx, topic, handler, eventID)
+	if err != nil {
+		t.Fatalf("Failed to reclaim failed event: %v", err)
+	}
+	if id, ok := claim.Outcome("shipment_id"); !ok || id != "65a000000000000000000001" {
+		t.Errorf("Expected the recorded shipment on resume, got %q %v", id, ok)
+	}
+	if claim.Event().Attempts != 2 {
+		t.Errorf("Expected attempt 2, got %d", claim.Event().Attempts)
+	}
+	if err := claim.Complete(ctx); err != nil {
+		t.Fatalf("Failed to complete event: %v", err)
+	}
+
+	if _, err := service.Claim(ctx, topic, handler, eventID); !errors.Is(err, ErrEventAlreadyProcessed) {
+		t.Errorf("Expected a completed event to be skipped, got %v", err)
+	}
+	event, err := service.Get(ctx, topic, handler, eventID)
+	if err != nil || event == nil || event.Status != ProcessedEventCompleted || event.CompletedAt == nil {
+		t.Errorf("Expected a completed ledger entry, got %+v %v", event, err)
+	}
+}
+
+// TestProcessedEventService_ClaimAfterLeaseExpires tests that an event abandoned by a crashed worker is taken over
+func TestProcessedEventService_ClaimAfterLeaseExpires(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	redisClient := getTestRedisClient(t)
+	defer redisClient.Close()
+
+	service := NewProcessedEventService(redisClient, time.Minute, time.Second)
+	ctx := context.Background()
+	topic, handler, eventID := "insurance.adjudication.completed", "PaymentWorker", uuid.New().String()
+	defer redisClient.Delete(ctx, ProcessedEventKey(topic, handler, eventID), ProcessedEventKey(topic, handler, eventID)+":lock")
+
+	abandoned, err := service.Claim(ctx, topic, handler, eventID)
+	if err != nil {
+		t.Fatalf("Failed to claim event: %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	claim, err := service.Claim(ctx, topic, handler, eventID)
+	if err != nil {
+		t.Fatalf("Expected the expired claim to be taken over, got %v", err)
+	}
+	if err := abandoned.Record(ctx, "payment_id", "late"); !errors.Is(err, ErrEventClaimLost) {
+		t.Errorf("Expected the abandoned claim to be rejected, got %v", err)
+	}
+	if err := claim.Complete(ctx); err != nil {
+		t.Errorf("Failed to complete event: %v", err)
+	}
+}
+
+// TestProcessedEventService_AwaitClaim tests that a redelivery waits out a live lease instead of failing
+func TestProcessedEventService_AwaitClaim(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	redisClient := getTestRedisClient(t)
+	defer redisClient.Close()
+
+	service := NewProcessedEventService(redisClient, time.Minute, time.Second)
+	ctx := context.Background()
+	topic, handler, eventID := "payment.completed", "VerificationWorker", uuid.New().String()
+	defer redisClient.Delete(ctx, ProcessedEventKey(topic, handler, eventID), ProcessedEventKey(topic, handler, eventID)+":lock")
+
+	// A crashed worker's lease expires and the waiting redelivery resumes the event
+	if _, err := service.Claim(ctx, topic, handler, eventID); err != nil {
+		t.Fatalf("Failed to claim event: %v", err)
+	}
+	claim, err := service.AwaitClaim(ctx, topic, handler, eventID)
+	if err != nil {
+		t.Fatalf("Expected the redelivery to claim the event once the lease expired, got %v", err)
+	}
+	if claim.Event().Attempts != 2 {
+		t.Errorf("Expected attempt 2, got %d", claim.Event().Attempts)
+	}
+
+	// A holder that completes the event while the redelivery waits has it skipped
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		claim.Complete(ctx)
+	}()
+	if _, err := service.AwaitClaim(ctx, topic, handler, eventID); !errors.Is(err, ErrEventAlreadyProcessed) {
+		t.Errorf("Expected the completed event to be skipped, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	other := uuid.New().String()
+	defer redisClient.Delete(ctx, ProcessedEventKey(topic, handler, other), ProcessedEventKey(topic, handler, other)+":lock")
+	if _, err := service.Claim(ctx, topic, handler, other); err != nil {
+		t.Fatalf("Failed to claim event: %v", err)
+	}
+	if _, err := service.AwaitClaim(canceled, topic, handler, other); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+}
@@ -0,0 +1,501 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/payments"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrRefundNotFound is returned when no refund has the requested ID
+	ErrRefundNotFound = errors.New("refund not found")
+	// ErrRefundPaymentNotFound is returned when the payment to refund does not exist
+	ErrRefundPaymentNotFound = errors.New("payment not found")
+	// ErrRefundConflict is returned when the refund changed status concurrently
+	ErrRefundConflict = errors.New("refund status changed concurrently")
+	// ErrInvalidRefund is returned when a refund request or status change is not allowed
+	ErrInvalidRefund = errors.New("invalid refund")
+	// ErrRefundSelfApproval is returned when the requester tries to approve their own refund
+	ErrRefundSelfApproval = errors.New("refunds must be approved by someone other than the requester")
+	// ErrRefundProviderFailed is returned when the payment provider refuses an approved refund
+	ErrRefundProviderFailed = errors.New("payment provider refused the refund")
+)
+
+// refundTransitions lists the statuses each refund status may move to
+var refundTransitions = map[models.RefundStatus][]models.RefundStatus{
+	models.RefundRequested: {models.RefundApproved, models.RefundRejected},
+	models.RefundApproved:  {models.RefundSucceeded, models.RefundFailed},
+}
+
+// refundReasons are the reasons a refund may be requested for
+var refundReasons = map[models.RefundReason]bool{
+	models.RefundReasonCancelled:     true,
+	models.RefundReasonReadjudicated: true,
+	models.RefundReasonUndeliverable: true,
+	models.RefundReasonOther:         true,
+}
+
+// refundablePaymentStatuses are the payment statuses that still hold captured money
+var refundablePaymentStatuses = map[models.PaymentStatus]bool{
+	models.PaymentPaid:              true,
+	models.PaymentPartiallyRefunded: true,
+}
+
+// RefundRequest is a request to return all or part of a captured payment
+type RefundRequest struct {
+	PaymentID string
+	Amount    float64 // dollars; 0 refunds everything still refundable
+	Reason    models.RefundReason
+	Note      string
+	By        string
+}
+
+// RefundFilter narrows a refund listing
+type RefundFilter struct {
+	Status         models.RefundStatus
+	PaymentID      string
+	PrescriptionID string
+	Limit          int64
+}
+
+// RefundService requests, approves and issues refunds of captured copays
+type RefundService struct {
+	mongoClient *database.MongoClient
+	provider    payments.PaymentProvider
+}
+
+// NewRefundService creates a new refund service
+func NewRefundService(mongoClient *database.MongoClient, provider payments.PaymentProvider) *RefundService {
+	return &RefundService{
+		mongoClient: mongoClient,
+		provider:    provider,
+	}
+}
+
+// Refundable returns the payment and how much of it can still be refunded
+// Refunds already requested or approved count against the refundable amount
+func (s *RefundService) Refundable(ctx context.Context, paymentID string) (*models.Payment, float64, error) {
+	payment, err := s.getPayment(ctx, paymentID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	outstanding, err := s.outstanding(ctx, payment.ID, primitive.NilObjectID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return payment, RefundableAmount(payment, outstanding), nil
+}
+
+// Request records a refund awaiting approval
+func (s *RefundService) Request(ctx context.Context, req RefundRequest) (*models.Refund, error) {
+	payment, refundable, err := s.Refundable(ctx, req.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	refund, err := NewRefund(payment, req, refundable, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.mongoClient.GetCollection("refunds").InsertOne(ctx, refund)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refund: %w", err)
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		refund.ID = id
+	}
+	if err := recordRefundChange(ctx, refund, audit.ActionCreate, ""); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// Approve approves a requested refund and issues it with the payment provider, returning the refund and
+// the payment's updated ledger. If the provider refuses, the refund is marked failed and
+// ErrRefundProviderFailed returned alongside it.
+func (s *RefundService) Approve(ctx context.Context, id, by, note string) (*models.Refund, *models.Payment, error) {
+	refund, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if refund.Status == models.RefundRequested && refund.RequestedBy == by {
+		return nil, nil, ErrRefundSelfApproval
+	}
+
+	// Re-check against the ledger: other refunds may have been issued since this one was requested
+	payment, err := s.getPayment(ctx, refund.PaymentID.Hex())
+	if err != nil {
+		return nil, nil, err
+	}
+	outstanding, err := s.outstanding(ctx, payment.ID, refund.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if refundable := RefundableAmount(payment, outstanding); payments.ToCents(refund.Amount) > payments.ToCents(refundable) {
+		return nil, nil, fmt.Errorf("%w: only %.2f of the payment is still refundable", ErrInvalidRefund, refundable)
+	}
+
+	now := time.Now()
+	if err := ApplyRefundTransition(refund, models.RefundApproved, by, note, now); err != nil {
+		return nil, nil, err
+	}
+	refund.ApprovedBy = by
+	if err := s.save(ctx, refund, models.RefundRequested); err != nil {
+		return nil, nil, err
+	}
+	return s.issue(ctx, refund, payment)
+}
+
+// Issue requests a refund and issues it straight away, for refunds the system makes itself such as
+// undoing a failed fulfillment; there is no second person to approve them. The refund's history
+// still records it as requested and approved by req.By.
+func (s *RefundService) Issue(ctx context.Context, req RefundRequest) (*models.Refund, *models.Payment, error) {
+	refund, err := s.Request(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	payment, err := s.getPayment(ctx, req.PaymentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := ApplyRefundTransition(refund, models.RefundApproved, req.By, req.Note, time.Now()); err != nil {
+		return nil, nil, err
+	}
+	refund.ApprovedBy = req.By
+	if err := s.save(ctx, refund, models.RefundRequested); err != nil {
+		return nil, nil, err
+	}
+	return s.issue(ctx, refund, payment)
+}
+
+// issue refunds an approved refund with the payment provider and records it on the payment's ledger
+func (s *RefundService) issue(ctx context.Context, refund *models.Refund, payment *models.Payment) (*models.Refund, *models.Payment, error) {
+	issued, providerErr := s.provider.Refund(ctx, payments.RefundRequest{
+		PaymentIntentID: payment.ProviderPaymentIntentID,
+		Amount:          refund.Amount,
+		Reason:          string(refund.Reason),
+		IdempotencyKey:  "refund-" + refund.ID.Hex(),
+	})
+
+	now := time.Now()
+	if providerErr != nil {
+		refund.FailureReason = providerErr.Error()
+		if err := ApplyRefundTransition(refund, models.RefundFailed, "system", "", now); err != nil {
+			return nil, nil, err
+		}
+		if err := s.save(ctx, refund, models.RefundApproved); err != nil {
+			return nil, nil, err
+		}
+		return refund, payment, fmt.Errorf("%w: %v", ErrRefundProviderFailed, providerErr)
+	}
+
+	refund.ProviderRefundID = issued.ID
+	refund.CompletedAt = &now
+	if err := ApplyRefundTransition(refund, models.RefundSucceeded, "system", "", now); err != nil {
+		return nil, nil, err
+	}
+	if err := s.save(ctx, refund, models.RefundApproved); err != nil {
+		return nil, nil, err
+	}
+
+	payment, err := s.recordRefund(ctx, payment.ID, refund.Amount, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	return refund, payment, nil
+}
+
+// Reject declines a requested refund
+func (s *RefundService) Reject(ctx context.Context, id, by, note string) (*models.Refund, error) {
+	refund, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(note) == "" {
+		return nil, fmt.Errorf("%w: a note is required to reject", ErrInvalidRefund)
+	}
+
+	if err := ApplyRefundTransition(refund, models.RefundRejected, by, note, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.save(ctx, refund, models.RefundRequested); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// Get returns a refund by ID
+func (s *RefundService) Get(ctx context.Context, id string) (*models.Refund, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrRefundNotFound
+	}
+
+	var refund models.Refund
+	err = s.mongoClient.GetCollection("refunds").FindOne(ctx, bson.M{"_id": oid}).Decode(&refund)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRefundNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load refund %s: %w", id, err)
+	}
+	return &refund, nil
+}
+
+// List returns refunds matching the filter, most recently updated first
+func (s *RefundService) List(ctx context.Context, filter RefundFilter) ([]models.Refund, error) {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.PaymentID != "" {
+		oid, err := primitive.ObjectIDFromHex(filter.PaymentID)
+		if err != nil {
+			return []models.Refund{}, nil
+		}
+		query["payment_id"] = oid
+	}
+	if filter.PrescriptionID != "" {
+		query["prescription_id"] = filter.PrescriptionID
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}).SetLimit(filter.Limit)
+	cursor, err := s.mongoClient.GetCollection("refunds").Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refunds: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	refunds := []models.Refund{}
+	if err := cursor.All(ctx, &refunds); err != nil {
+		return nil, fmt.Errorf("failed to decode refunds: %w", err)
+	}
+	return refunds, nil
+}
+
+// getPayment loads a payment by ID
+func (s *RefundService) getPayment(ctx context.Context, id string) (*models.Payment, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrRefundPaymentNotFound
+	}
+
+	var payment models.Payment
+	err = s.mongoClient.GetCollection("payments").FindOne(ctx, bson.M{"_id": oid}).Decode(&payment)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRefundPaymentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load payment %s: %w", id, err)
+	}
+	return &payment, nil
+}
+
+// outstanding returns the payment's requested and approved refunds, other than the excluded one
+func (s *RefundService) outstanding(ctx context.Context, paymentID, exclude primitive.ObjectID) ([]models.Refund, error) {
+	query := bson.M{
+		"payment_id": paymentID,
+		"status":     bson.M{"$in": []models.RefundStatus{models.RefundRequested, models.RefundApproved}},
+	}
+	if !exclude.IsZero() {
+		query["_id"] = bson.M{"$ne": exclude}
+	}
+
+	cursor, err := s.mongoClient.GetCollection("refunds").Find(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load outstanding refunds: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	refunds := []models.Refund{}
+	if err := cursor.All(ctx, &refunds); err != nil {
+		return nil, fmt.Errorf("failed to decode outstanding refunds: %w", err)
+	}
+	return refunds, nil
+}
+
+// save replaces the refund if it is still in the status it was loaded in
+func (s *RefundService) save(ctx context.Context, refund *models.Refund, from models.RefundStatus) error {
+	result, err := s.mongoClient.GetCollection("refunds").ReplaceOne(ctx, bson.M{"_id": refund.ID, "status": from}, refund)
+	if err != nil {
+		return fmt.Errorf("failed to update refund %s: %w", refund.ID.Hex(), err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrRefundConflict
+	}
+	return recordRefundChange(ctx, refund, audit.ActionUpdate, from)
+}
+
+// recordRefundChange audits a refund being requested or changing status
+func recordRefundChange(ctx context.Context, refund *models.Refund, action audit.Action, from models.RefundStatus) error {
+	details := map[string]interface{}{
+		"payment_id":      refund.PaymentID.Hex(),
+		"prescription_id": refund.PrescriptionID,
+		"amount":          refund.Amount,
+		"status":          refund.Status,
+		"correlation_id":  refund.CorrelationID,
+	}
+	if from != "" {
+		details["from_status"] = from
+	}
+	return audit.Record(ctx, audit.Entry{
+		EventType:  audit.EventRefundChanged,
+		EntityType: audit.EntityRefund,
+		EntityID:   refund.ID.Hex(),
+		Action:     action,
+		Details:    details,
+	})
+}
+
+// recordRefund adds an issued refund to the payment's ledger and returns the updated payment
+func (s *RefundService) recordRefund(ctx context.Context, paymentID primitive.ObjectID, amount float64, at time.Time) (*models.Payment, error) {
+	collection := s.mongoClient.GetCollection("payments")
+
+	var payment models.Payment
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": paymentID},
+		bson.M{"$inc": bson.M{"amount_refunded": amount}, "$set": bson.M{"updated_at": at}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&payment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record refund on payment %s: %w", paymentID.Hex(), err)
+	}
+
+	payment.AmountRefunded = payments.FromCents(payments.ToCents(payment.AmountRefunded))
+	payment.Status = models.PaymentPartiallyRefunded
+	if payments.ToCents(payment.AmountRefunded) >= payments.ToCents(payment.Amount) {
+		payment.Status = models.PaymentRefunded
+	}
+
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": paymentID}, bson.M{"$set": bson.M{
+		"status":          payment.Status,
+		"amount_refunded": payment.AmountRefunded,
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update payment %s status: %w", paymentID.Hex(), err)
+	}
+	err = audit.Record(ctx, audit.Entry{
+		EventType:  audit.EventPaymentChanged,
+		EntityType: audit.EntityPayment,
+		EntityID:   paymentID.Hex(),
+		Action:     audit.ActionUpdate,
+		Details: map[string]interface{}{
+			"status":          payment.Status,
+			"amount_refunded": payment.AmountRefunded,
+			"correlation_id":  payment.CorrelationID,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// RefundableAmount is what remains of a captured payment after issued and outstanding refunds
+func RefundableAmount(payment *models.Payment, outstanding []models.Refund) float64 {
+	if !refundablePaymentStatuses[payment.Status] {
+		return 0
+	}
+
+	remaining := payments.ToCents(payment.Amount) - payments.ToCents(payment.AmountRefunded)
+	for _, refund := range outstanding {
+		remaining -= payments.ToCents(refund.Amount)
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	return payments.FromCents(remaining)
+}
+
+// NewRefund validates a refund request against what is still refundable and builds the refund
+// The refund continues the payment's correlation ID.
+func NewRefund(payment *models.Payment, req RefundRequest, refundable float64, at time.Time) (*models.Refund, error) {
+	if !refundReasons[req.Reason] {
+		return nil, fmt.Errorf("%w: reason %q is not one of prescription_cancelled, readjudicated, undeliverable, other", ErrInvalidRefund, req.Reason)
+	}
+	note := strings.TrimSpace(req.Note)
+	if req.Reason == models.RefundReasonOther && note == "" {
+		return nil, fmt.Errorf("%w: a note is required for reason other", ErrInvalidRefund)
+	}
+	if !refundablePaymentStatuses[payment.Status] {
+		return nil, fmt.Errorf("%w: payment is %s", ErrInvalidRefund, payment.Status)
+	}
+	if req.Amount < 0 {
+		return nil, fmt.Errorf("%w: amount must not be negative", ErrInvalidRefund)
+	}
+
+	amount := req.Amount
+	if amount == 0 {
+		amount = refundable
+	}
+	if payments.ToCents(amount) <= 0 {
+		return nil, fmt.Errorf("%w: nothing left to refund", ErrInvalidRefund)
+	}
+	if payments.ToCents(amount) > payments.ToCents(refundable) {
+		return nil, fmt.Errorf("%w: amount %.2f exceeds the refundable %.2f", ErrInvalidRefund, amount, refundable)
+	}
+
+	return &models.Refund{
+		PaymentID:      payment.ID,
+		PrescriptionID: payment.PrescriptionID,
+		PatientID:      payment.PatientID,
+		Amount:         payments.FromCents(payments.ToCents(amount)),
+		Currency:       payment.Currency,
+		Reason:         req.Reason,
+		Status:         models.RefundRequested,
+		RequestedBy:    req.By,
+		Provider:       payment.Provider,
+		History: []models.RefundTransition{
+			{To: models.RefundRequested, By: req.By, Note: note, At: at},
+		},
+		CorrelationID: payment.CorrelationID,
+		CreatedAt:     at,
+		UpdatedAt:     at,
+	}, nil
+}
+
+// CanTransitionRefund reports whether a refund may move from one status to another
+func CanTransitionRefund(from, to models.RefundStatus) bool {
+	for _, next := range refundTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyRefundTransition validates a status change and records it in the refund's audit trail
+func ApplyRefundTransition(refund *models.Refund, to models.RefundStatus, by, note string, at time.Time) error {
+	if !CanTransitionRefund(refund.Status, to) {
+		return fmt.Errorf("%w: cannot move from %s to %s", ErrInvalidRefund, refund.Status, to)
+	}
+
+	refund.History = append(refund.History, models.RefundTransition{
+		From: refund.Status,
+		To:   to,
+		By:   by,
+		Note: strings.TrimSpace(note),
+		At:   at,
+	})
+	refund.Status = to
+	refund.UpdatedAt = at
+	return nil
+}
@@ -0,0 +1,124 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func paidPayment(amount, refunded float64) *models.Payment {
+	status := models.PaymentPaid
+	if refunded > 0 {
+		status = models.PaymentPartiallyRefunded
+	}
+	return &models.Payment{
+		ID:             primitive.NewObjectID(),
+		PrescriptionID: "65a000000000000000000001",
+		PatientID:      "patient_1",
+		Status:         status,
+		Amount:         amount,
+		AmountRefunded: refunded,
+		Currency:       "usd",
+		Provider:       "fake",
+	}
+}
+
+// TestRefundableAmount tests the refundable amount computed from the payment ledger
+func TestRefundableAmount(t *testing.T) {
+	tests := []struct {
+		name        string
+		payment     *models.Payment
+		outstanding []models.Refund
+		want        float64
+	}{
+		{"untouched payment", paidPayment(25.50, 0), nil, 25.50},
+		{"partially refunded", paidPayment(25.50, 10.20), nil, 15.30},
+		{"outstanding requests count", paidPayment(25.50, 10), []models.Refund{{Amount: 5.25}, {Amount: 0.25}}, 10},
+		{"over-committed floors at zero", paidPayment(10, 0), []models.Refund{{Amount: 8}, {Amount: 8}}, 0},
+		{"unpaid payment", &models.Payment{Status: models.PaymentPending, Amount: 25}, nil, 0},
+		{"fully refunded", &models.Payment{Status: models.PaymentRefunded, Amount: 25, AmountRefunded: 25}, nil, 0},
+	}
+
+	for _, tt := range tests {
+		if got := RefundableAmount(tt.payment, tt.outstanding); got != tt.want {
+			t.Errorf("%s: expected %.2f, got %.2f", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestNewRefund tests refund request validation
+func TestNewRefund(t *testing.T) {
+	now := time.Now()
+	payment := paidPayment(40, 0)
+
+	tests := []struct {
+		name       string
+		req        RefundRequest
+		refundable float64
+		wantAmount float64
+		wantErr    bool
+	}{
+		{"full refund by default", RefundRequest{Reason: models.RefundReasonCancelled, By: "ops_1"}, 40, 40, false},
+		{"partial refund", RefundRequest{Amount: 12.5, Reason: models.RefundReasonReadjudicated, By: "ops_1"}, 40, 12.5, false},
+		{"exceeds refundable", RefundRequest{Amount: 30, Reason: models.RefundReasonUndeliverable, By: "ops_1"}, 20, 0, true},
+		{"nothing left", RefundRequest{Reason: models.RefundReasonCancelled, By: "ops_1"}, 0, 0, true},
+		{"negative amount", RefundRequest{Amount: -1, Reason: models.RefundReasonCancelled, By: "ops_1"}, 40, 0, true},
+		{"unknown reason", RefundRequest{Reason: "goodwill", By: "ops_1"}, 40, 0, true},
+		{"other without note", RefundRequest{Reason: models.RefundReasonOther, By: "ops_1"}, 40, 0, true},
+		{"other with note", RefundRequest{Reason: models.RefundReasonOther, Note: "duplicate charge", By: "ops_1"}, 40, 40, false},
+	}
+
+	for _, tt := range tests {
+		refund, err := NewRefund(payment, tt.req, tt.refundable, now)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidRefund) {
+				t.Errorf("%s: expected ErrInvalidRefund, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if refund.Amount != tt.wantAmount || refund.Status != models.RefundRequested || refund.PaymentID != payment.ID {
+			t.Errorf("%s: unexpected refund %+v", tt.name, refund)
+		}
+		if len(refund.History) != 1 || refund.History[0].By != "ops_1" {
+			t.Errorf("%s: expected requested entry in the audit trail, got %+v", tt.name, refund.History)
+		}
+	}
+
+	if _, err := NewRefund(&models.Payment{Status: models.PaymentPending}, RefundRequest{Reason: models.RefundReasonCancelled}, 10, now); !errors.Is(err, ErrInvalidRefund) {
+		t.Errorf("Expected ErrInvalidRefund for an unpaid payment, got %v", err)
+	}
+}
+
+// TestApplyRefundTransition tests the refund status machine and audit trail
+func TestApplyRefundTransition(t *testing.T) {
+	now := time.Now()
+	refund := &models.Refund{Status: models.RefundRequested}
+
+	if err := ApplyRefundTransition(refund, models.RefundSucceeded, "ops_2", "", now); !errors.Is(err, ErrInvalidRefund) {
+		t.Errorf("Expected requested -> succeeded to be rejected, got %v", err)
+	}
+	if err := ApplyRefundTransition(refund, models.RefundApproved, "ops_2", " looks right ", now); err != nil {
+		t.Fatalf("Expected requested -> approved, got %v", err)
+	}
+	if err := ApplyRefundTransition(refund, models.RefundSucceeded, "system", "", now); err != nil {
+		t.Fatalf("Expected approved -> succeeded, got %v", err)
+	}
+	if err := ApplyRefundTransition(refund, models.RefundRejected, "ops_2", "", now); !errors.Is(err, ErrInvalidRefund) {
+		t.Errorf("Expected succeeded to be final, got %v", err)
+	}
+
+	if refund.Status != models.RefundSucceeded || len(refund.History) != 2 {
+		t.Fatalf("Expected succeeded with two transitions, got %s %+v", refund.Status, refund.History)
+	}
+	if refund.History[0].From != models.RefundRequested || refund.History[0].Note != "looks right" {
+		t.Errorf("Unexpected first transition: %+v", refund.History[0])
+	}
+}
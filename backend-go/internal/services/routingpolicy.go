@@ -0,0 +1,114 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RoutingPolicyService resolves auto vs manual pharmacy selection per drug or sponsor program
+type RoutingPolicyService struct {
+	mongoClient   *database.MongoClient
+	defaultPolicy models.RoutingPolicy
+}
+
+// NewRoutingPolicyService creates a new routing policy service
+// defaultMode and defaultTopN apply when no policy matches the drug or its sponsor program
+func NewRoutingPolicyService(mongoClient *database.MongoClient, defaultMode models.RoutingMode, defaultTopN int) *RoutingPolicyService {
+	if defaultMode != models.RoutingModeManual {
+		defaultMode = models.RoutingModeAuto
+	}
+	if defaultTopN <= 0 {
+		defaultTopN = 3
+	}
+
+	return &RoutingPolicyService{
+		mongoClient: mongoClient,
+		defaultPolicy: models.RoutingPolicy{
+			Scope: "default",
+			Mode:  defaultMode,
+			TopN:  defaultTopN,
+		},
+	}
+}
+
+// Resolve returns the routing policy for a drug
+// Sponsor program policies take precedence over drug policies, which take precedence over the default
+func (s *RoutingPolicyService) Resolve(ctx context.Context, ndc string) (*models.RoutingPolicy, error) {
+	if ndc == "" {
+		return s.withDefaults(nil), nil
+	}
+
+	var drug models.Drug
+	err := s.mongoClient.GetCollection("drugs").FindOne(ctx, bson.M{"ndc": ndc}).Decode(&drug)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to look up drug %s: %w", ndc, err)
+	}
+
+	if drug.ProgramID != "" {
+		policy, err := s.find(ctx, models.RoutingScopeProgram, drug.ProgramID)
+		if err != nil {
+			return nil, err
+		}
+		if policy != nil {
+			return s.withDefaults(policy), nil
+		}
+	}
+
+	policy, err := s.find(ctx, models.RoutingScopeDrug, ndc)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.withDefaults(policy), nil
+}
+
+// find loads a single routing policy, returning nil if none exists
+func (s *RoutingPolicyService) find(ctx context.Context, scope, key string) (*models.RoutingPolicy, error) {
+	var policy models.RoutingPolicy
+	err := s.mongoClient.GetCollection("routing_policies").FindOne(ctx, bson.M{"scope": scope, "key": key}).Decode(&policy)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load routing policy %s:%s: %w", scope, key, err)
+	}
+	return &policy, nil
+}
+
+// withDefaults fills unset policy fields from the default policy
+func (s *RoutingPolicyService) withDefaults(policy *models.RoutingPolicy) *models.RoutingPolicy {
+	if policy == nil {
+		p := s.defaultPolicy
+		return &p
+	}
+	if policy.Mode != models.RoutingModeManual {
+		policy.Mode = models.RoutingModeAuto
+	}
+	if policy.TopN <= 0 {
+		policy.TopN = s.defaultPolicy.TopN
+	}
+	return policy
+}
+
+// FindRecommendation returns the stored recommendation for a pharmacy, or nil if it was not recommended
+func FindRecommendation(recommendations []models.PharmacyRecommendation, pharmacyID string) *models.PharmacyRecommendation {
+	for i := range recommendations {
+		if recommendations[i].PharmacyID == pharmacyID {
+			return &recommendations[i]
+		}
+	}
+	return nil
+}
+
+// RequiresOverride reports whether selecting the pharmacy needs an override reason,
+// i.e. it is not among the top N recommendations
+func RequiresOverride(recommendations []models.PharmacyRecommendation, pharmacyID string, topN int) bool {
+	rec := FindRecommendation(recommendations, pharmacyID)
+	return rec == nil || rec.Rank <= 0 || rec.Rank > topN
+}
@@ -0,0 +1,40 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"testing"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+// TestRequiresOverride tests the top-N override rule for manual pharmacy selection
+func TestRequiresOverride(t *testing.T) {
+	recs := []models.PharmacyRecommendation{
+		{Rank: 1, PharmacyID: "a"},
+		{Rank: 2, PharmacyID: "b"},
+		{Rank: 3, PharmacyID: "c"},
+		{Rank: 4, PharmacyID: "d"},
+	}
+
+	tests := []struct {
+		pharmacyID string
+		topN       int
+		want       bool
+	}{
+		{"a", 3, false},
+		{"c", 3, false},
+		{"d", 3, true},
+		{"d", 5, false},
+		{"unknown", 5, true},
+	}
+
+	for _, tt := range tests {
+		if got := RequiresOverride(recs, tt.pharmacyID, tt.topN); got != tt.want {
+			t.Errorf("RequiresOverride(%s, top %d): expected %v, got %v", tt.pharmacyID, tt.topN, tt.want, got)
+		}
+	}
+
+	if rec := FindRecommendation(recs, "b"); rec == nil || rec.Rank != 2 {
+		t.Errorf("Expected to find recommendation b at rank 2, got %+v", rec)
+	}
+}
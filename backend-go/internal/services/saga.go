@@ -0,0 +1,571 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrSagaNotFound is returned when a prescription has no saga
+	ErrSagaNotFound = errors.New("saga not found")
+	// ErrSagaConflict is returned when a saga kept changing concurrently while being updated
+	ErrSagaConflict = errors.New("saga changed concurrently")
+)
+
+const (
+	// sagaUpdateAttempts bounds the retries of an update that lost a race with another worker
+	sagaUpdateAttempts = 3
+	// defaultCompensationAttempts is how often a compensation is tried before ops are left to undo it
+	defaultCompensationAttempts = 5
+)
+
+// DefaultSagaTimeouts are the step deadlines used unless SAGA_STEP_TIMEOUTS overrides them
+// DUR review, routing, adjudication, payment and verification wait on pharmacists, ops, the pharmacy and the patient; delivery
+// has no deadline, as a label in the carrier's hands cannot be undone. A prescription rejected at verification is refunded
+// when the verification deadline passes, unless ops have moved it on.
+var DefaultSagaTimeouts = map[models.SagaStep]time.Duration{
+	models.SagaStepValidation:   15 * time.Minute,
+	models.SagaStepDURReview:    24 * time.Hour,
+	models.SagaStepEnrollment:   15 * time.Minute,
+	models.SagaStepRouting:      24 * time.Hour,
+	models.SagaStepAdjudication: 72 * time.Hour,
+	models.SagaStepPayment:      48 * time.Hour,
+	models.SagaStepVerification: 24 * time.Hour,
+	models.SagaStepShipping:     1 * time.Hour,
+}
+
+// sagaStep is a step of the fulfillment saga and the topics that start and complete it
+// An optional step is only part of sagas whose event started it, so the events completing it
+// complete it only when it is there.
+type sagaStep struct {
+	step        models.SagaStep
+	startedBy   string
+	completedBy []string
+	optional    bool
+}
+
+// sagaSteps are the steps of the fulfillment saga in order; each step is started by the event completing the previous one
+// DUR review only runs for prescriptions whose drug utilization review found something.
+var sagaSteps = []sagaStep{
+	{step: models.SagaStepValidation, startedBy: kafka.TopicIntakeReceived, completedBy: []string{kafka.TopicValidationCompleted, kafka.TopicDURReviewRequested}},
+	{step: models.SagaStepDURReview, startedBy: kafka.TopicDURReviewRequested, completedBy: []string{kafka.TopicValidationCompleted}, optional: true},
+	{step: models.SagaStepEnrollment, startedBy: kafka.TopicValidationCompleted, completedBy: []string{kafka.TopicEnrollmentCompleted}},
+	{step: models.SagaStepRouting, startedBy: kafka.TopicEnrollmentCompleted, completedBy: []string{kafka.TopicPharmacySelected}},
+	{step: models.SagaStepAdjudication, startedBy: kafka.TopicPharmacySelected, completedBy: []string{kafka.TopicAdjudicationCompleted}},
+	{step: models.SagaStepPayment, startedBy: kafka.TopicAdjudicationCompleted, completedBy: []string{kafka.TopicPaymentCompleted}},
+	{step: models.SagaStepVerification, startedBy: kafka.TopicPaymentCompleted, completedBy: []string{kafka.TopicVerificationCompleted}},
+	{step: models.SagaStepShipping, startedBy: kafka.TopicVerificationCompleted, completedBy: []string{kafka.TopicShipmentLabelCreated}},
+	{step: models.SagaStepDelivery, startedBy: kafka.TopicShipmentLabelCreated, completedBy: []string{kafka.TopicShipmentDelivered}},
+}
+
+// completes reports whether a topic's event completes the step
+func (s sagaStep) completes(topic string) bool {
+	for _, completedBy := range s.completedBy {
+		if completedBy == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// SagaTopics returns the topics sagas follow: those that start and complete steps, payment links and exceptions
+func SagaTopics() []string {
+	topics := []string{}
+	seen := map[string]bool{}
+	for _, step := range sagaSteps {
+		for _, topic := range append([]string{step.startedBy}, step.completedBy...) {
+			if !seen[topic] {
+				seen[topic] = true
+				topics = append(topics, topic)
+			}
+		}
+	}
+	return append(topics, kafka.TopicPaymentLinkCreated, kafka.TopicPrescriptionException)
+}
+
+// SagaStepStartedBy returns the step a topic's event starts, if any
+func SagaStepStartedBy(topic string) (models.SagaStep, bool) {
+	for _, step := range sagaSteps {
+		if step.startedBy == topic {
+			return step.step, true
+		}
+	}
+	return "", false
+}
+
+// sagaStepIndex returns the position of a step in the saga, or -1 for none
+func sagaStepIndex(step models.SagaStep) int {
+	for i, s := range sagaSteps {
+		if s.step == step {
+			return i
+		}
+	}
+	return -1
+}
+
+// SagaEvent is what a saga records of a pipeline event
+type SagaEvent struct {
+	Topic          string
+	EventID        string
+	PrescriptionID string
+	CorrelationID  string
+	OccurredAt     time.Time
+	// Resources the event reports, which compensations may have to release
+	PharmacyID string
+	PaymentID  string
+	ShipmentID string
+	// Exception raised on the prescription, for prescription.exception
+	Exception *models.PrescriptionException
+}
+
+// CompensationResult is the outcome of running a compensating action
+type CompensationResult struct {
+	Status models.SagaCompensationStatus // done or skipped, when Err is nil
+	Detail string
+	Err    error
+}
+
+// SagaFilter narrows a saga listing
+type SagaFilter struct {
+	Status models.SagaStatus
+	Step   models.SagaStep
+	Limit  int64
+}
+
+// SagaConfig configures step deadlines and compensation retries
+type SagaConfig struct {
+	Timeouts                map[models.SagaStep]time.Duration // a step without one has no deadline
+	MaxCompensationAttempts int
+}
+
+// SagaService tracks each prescription's fulfillment saga in the sagas collection
+type SagaService struct {
+	mongoClient *database.MongoClient
+	config      SagaConfig
+}
+
+// NewSagaService creates a new saga service
+// Timeouts default to DefaultSagaTimeouts and compensations are tried 5 times.
+func NewSagaService(mongoClient *database.MongoClient, config SagaConfig) *SagaService {
+	if config.Timeouts == nil {
+		config.Timeouts = DefaultSagaTimeouts
+	}
+	if config.MaxCompensationAttempts <= 0 {
+		config.MaxCompensationAttempts = defaultCompensationAttempts
+	}
+	return &SagaService{
+		mongoClient: mongoClient,
+		config:      config,
+	}
+}
+
+// Record applies a pipeline event to the prescription's saga, starting the saga if it has none
+// It returns whether the event changed the saga; events for a saga that is no longer running change nothing.
+func (s *SagaService) Record(ctx context.Context, event SagaEvent) (*models.Saga, bool, error) {
+	return s.update(ctx, event.PrescriptionID, event.CorrelationID, func(saga *models.Saga) bool {
+		return ApplySagaEvent(saga, event, s.config.Timeouts)
+	})
+}
+
+// Fail fails a running saga at a step and plans its compensations
+// It returns whether the saga was failed by this call, so the failure is only reported once.
+func (s *SagaService) Fail(ctx context.Context, prescriptionID, correlationID string, failure models.SagaFailure) (*models.Saga, bool, error) {
+	return s.update(ctx, prescriptionID, correlationID, func(saga *models.Saga) bool {
+		return FailSaga(saga, failure)
+	})
+}
+
+// RecordCompensation records the outcome of one of a saga's compensations
+func (s *SagaService) RecordCompensation(ctx context.Context, prescriptionID string, action models.SagaCompensationAction, result CompensationResult, now time.Time) (*models.Saga, error) {
+	saga, _, err := s.update(ctx, prescriptionID, "", func(saga *models.Saga) bool {
+		return ApplyCompensationResult(saga, action, result, now, s.config.MaxCompensationAttempts)
+	})
+	return saga, err
+}
+
+// Get returns a prescription's saga
+func (s *SagaService) Get(ctx context.Context, prescriptionID string) (*models.Saga, error) {
+	var saga models.Saga
+	err := s.mongoClient.GetCollection("sagas").FindOne(ctx, bson.M{"prescription_id": prescriptionID}).Decode(&saga)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrSagaNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saga of prescription %s: %w", prescriptionID, err)
+	}
+	return &saga, nil
+}
+
+// List returns sagas matching the filter, most recently updated first
+func (s *SagaService) List(ctx context.Context, filter SagaFilter) ([]models.Saga, error) {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Step != "" {
+		query["current_step"] = filter.Step
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}).SetLimit(filter.Limit)
+	return s.find(ctx, query, opts)
+}
+
+// TimedOut returns running sagas whose current step is past its deadline, longest overdue first
+func (s *SagaService) TimedOut(ctx context.Context, now time.Time, limit int64) ([]models.Saga, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "deadline", Value: 1}}).SetLimit(limit)
+	return s.find(ctx, bson.M{"status": models.SagaRunning, "deadline": bson.M{"$lte": now}}, opts)
+}
+
+// CompensationsDue returns compensating sagas whose pending compensations are due to be tried
+func (s *SagaService) CompensationsDue(ctx context.Context, now time.Time, limit int64) ([]models.Saga, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "next_compensation_at", Value: 1}}).SetLimit(limit)
+	return s.find(ctx, bson.M{"status": models.SagaCompensating, "next_compensation_at": bson.M{"$lte": now}}, opts)
+}
+
+// find returns the sagas matching a query
+func (s *SagaService) find(ctx context.Context, query bson.M, opts *options.FindOptions) ([]models.Saga, error) {
+	cursor, err := s.mongoClient.GetCollection("sagas").Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find sagas: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	sagas := []models.Saga{}
+	if err := cursor.All(ctx, &sagas); err != nil {
+		return nil, fmt.Errorf("failed to decode sagas: %w", err)
+	}
+	return sagas, nil
+}
+
+// update applies a change to a prescription's saga, creating it if it does not exist
+// The saga is replaced only if its version is unchanged since it was read; a lost race reads it again.
+func (s *SagaService) update(ctx context.Context, prescriptionID, correlationID string, change func(*models.Saga) bool) (*models.Saga, bool, error) {
+	collection := s.mongoClient.GetCollection("sagas")
+	for attempt := 0; attempt < sagaUpdateAttempts; attempt++ {
+		now := time.Now()
+		var saga models.Saga
+		err := collection.FindOne(ctx, bson.M{"prescription_id": prescriptionID}).Decode(&saga)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, false, fmt.Errorf("failed to load saga of prescription %s: %w", prescriptionID, err)
+		}
+		created := err == mongo.ErrNoDocuments
+		if created {
+			saga = models.Saga{
+				ID:             primitive.NewObjectID(),
+				PrescriptionID: prescriptionID,
+				Status:         models.SagaRunning,
+				Steps:          []models.SagaStepState{},
+				CorrelationID:  correlationID,
+				CreatedAt:      now,
+			}
+		}
+
+		if !change(&saga) {
+			if created {
+				return nil, false, ErrSagaNotFound
+			}
+			return &saga, false, nil
+		}
+		saga.UpdatedAt = now
+
+		if created {
+			saga.Version = 1
+			if _, err := collection.InsertOne(ctx, saga); err != nil {
+				if mongo.IsDuplicateKeyError(err) {
+					continue
+				}
+				return nil, false, fmt.Errorf("failed to create saga of prescription %s: %w", prescriptionID, err)
+			}
+			return &saga, true, nil
+		}
+
+		version := saga.Version
+		saga.Version++
+		result, err := collection.ReplaceOne(ctx, bson.M{"_id": saga.ID, "version": version}, saga)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to update saga of prescription %s: %w", prescriptionID, err)
+		}
+		if result.MatchedCount == 1 {
+			return &saga, true, nil
+		}
+	}
+	return nil, false, ErrSagaConflict
+}
+
+// ApplySagaEvent records a pipeline event on a running saga and reports whether it changed
+// An event completes the step it ends and starts the one it begins. Steps may be seen out of order,
+// as each topic is consumed separately; an event restarting a running step (a claim adjudicated again
+// after prior authorization, a replacement label) resets its deadline.
+func ApplySagaEvent(saga *models.Saga, event SagaEvent, timeouts map[models.SagaStep]time.Duration) bool {
+	if saga.Status != models.SagaRunning {
+		return false
+	}
+	at := event.OccurredAt
+
+	changed := false
+	if event.PharmacyID != "" && event.PharmacyID != saga.PharmacyID {
+		saga.PharmacyID = event.PharmacyID
+		changed = true
+	}
+	if event.PaymentID != "" && event.PaymentID != saga.PaymentID {
+		saga.PaymentID = event.PaymentID
+		changed = true
+	}
+	if event.ShipmentID != "" && event.ShipmentID != saga.ShipmentID {
+		saga.ShipmentID = event.ShipmentID
+		changed = true
+	}
+
+	if event.Exception != nil {
+		if state := sagaStepState(saga, saga.CurrentStep); state != nil {
+			exception := *event.Exception
+			state.Exception = &exception
+			changed = true
+		}
+	}
+
+	for _, step := range sagaSteps {
+		if step.completes(event.Topic) {
+			state := sagaStepState(saga, step.step)
+			if state == nil && !step.optional {
+				state = addSagaStep(saga, step.step, at, event.EventID)
+			}
+			if state != nil && state.Status == models.SagaStepRunning {
+				state.Status = models.SagaStepCompleted
+				state.CompletedAt = &at
+				state.CompletedBy = event.EventID
+				state.Deadline = nil
+				changed = true
+			}
+		}
+		if step.startedBy == event.Topic {
+			state := sagaStepState(saga, step.step)
+			if state == nil {
+				state = addSagaStep(saga, step.step, at, event.EventID)
+				changed = true
+			} else if state.Status == models.SagaStepRunning && event.EventID != state.StartedBy {
+				state.StartedAt = at
+				state.StartedBy = event.EventID
+				changed = true
+			}
+			if state.Status == models.SagaStepRunning {
+				state.Deadline = nil
+				if timeout := timeouts[step.step]; timeout > 0 {
+					deadline := state.StartedAt.Add(timeout)
+					state.Deadline = &deadline
+				}
+			}
+			if sagaStepIndex(step.step) > sagaStepIndex(saga.CurrentStep) {
+				saga.CurrentStep = step.step
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return false
+	}
+
+	sort.SliceStable(saga.Steps, func(i, j int) bool {
+		return sagaStepIndex(saga.Steps[i].Step) < sagaStepIndex(saga.Steps[j].Step)
+	})
+	if saga.CorrelationID == "" {
+		saga.CorrelationID = event.CorrelationID
+	}
+
+	last := sagaSteps[len(sagaSteps)-1].step
+	if state := sagaStepState(saga, last); state != nil && state.Status == models.SagaStepCompleted {
+		saga.Status = models.SagaCompleted
+		saga.CurrentStep = last
+	}
+	saga.Deadline = nil
+	if state := sagaStepState(saga, saga.CurrentStep); saga.Status == models.SagaRunning && state != nil && state.Status == models.SagaStepRunning {
+		saga.Deadline = state.Deadline
+	}
+	return true
+}
+
+// FailSaga fails a running saga at a step and plans the compensations of the resources it holds
+// A step that already completed does not fail the saga. A saga with nothing to compensate is
+// compensated straight away.
+func FailSaga(saga *models.Saga, failure models.SagaFailure) bool {
+	if !SagaCanFail(saga, failure.Step) {
+		return false
+	}
+	state := sagaStepState(saga, failure.Step)
+	if state == nil {
+		state = addSagaStep(saga, failure.Step, failure.At, "")
+		sort.SliceStable(saga.Steps, func(i, j int) bool {
+			return sagaStepIndex(saga.Steps[i].Step) < sagaStepIndex(saga.Steps[j].Step)
+		})
+		state = sagaStepState(saga, failure.Step)
+	}
+
+	state.Status = models.SagaStepFailed
+	if failure.TimedOut {
+		state.Status = models.SagaStepTimedOut
+	}
+	state.Error = failure.Reason
+	state.Deadline = nil
+
+	saga.Failure = &failure
+	saga.Deadline = nil
+	saga.Compensations = PlanCompensations(saga)
+	if len(saga.Compensations) == 0 {
+		saga.Status = models.SagaCompensated
+		return true
+	}
+	saga.Status = models.SagaCompensating
+	next := failure.At
+	saga.NextCompensationAt = &next
+	return true
+}
+
+// SagaCanFail reports whether a step can still fail a saga: the saga is running and the step has not completed
+func SagaCanFail(saga *models.Saga, step models.SagaStep) bool {
+	if saga.Status != models.SagaRunning {
+		return false
+	}
+	state := sagaStepState(saga, step)
+	return state == nil || state.Status != models.SagaStepCompleted
+}
+
+// PlanCompensations lists the compensations of the resources a saga holds, latest first
+// Each checks at run time whether there is still something to undo, so a payment is planned both to
+// have its link expired and to be refunded: an open link is expired before it can be paid, and a
+// captured copay is refunded.
+func PlanCompensations(saga *models.Saga) []models.SagaCompensation {
+	var actions []models.SagaCompensationAction
+	if saga.ShipmentID != "" {
+		actions = append(actions, models.CompensationVoidLabel)
+	}
+	if saga.PaymentID != "" {
+		actions = append(actions, models.CompensationVoidPaymentLink, models.CompensationRefund)
+	}
+	if saga.PharmacyID != "" {
+		actions = append(actions, models.CompensationReleaseCapacity)
+	}
+
+	compensations := make([]models.SagaCompensation, 0, len(actions))
+	for _, action := range actions {
+		compensations = append(compensations, models.SagaCompensation{Action: action, Status: models.CompensationPending})
+	}
+	return compensations
+}
+
+// ApplyCompensationResult records a compensation's outcome and settles the saga once none is pending
+// A failed compensation is retried with the notification backoff until maxAttempts tries, then given up,
+// which leaves the saga compensation_failed for ops.
+func ApplyCompensationResult(saga *models.Saga, action models.SagaCompensationAction, result CompensationResult, now time.Time, maxAttempts int) bool {
+	var compensation *models.SagaCompensation
+	for i := range saga.Compensations {
+		if saga.Compensations[i].Action == action {
+			compensation = &saga.Compensations[i]
+		}
+	}
+	if compensation == nil || compensation.Status != models.CompensationPending {
+		return false
+	}
+
+	compensation.Attempts++
+	if result.Err != nil {
+		compensation.Error = result.Err.Error()
+		if compensation.Attempts >= maxAttempts {
+			compensation.Status = models.CompensationFailed
+		} else {
+			next := now.Add(NotificationRetryDelay(compensation.Attempts))
+			if saga.NextCompensationAt == nil || next.After(*saga.NextCompensationAt) {
+				saga.NextCompensationAt = &next
+			}
+		}
+	} else {
+		compensation.Status = result.Status
+		compensation.Detail = strings.TrimSpace(result.Detail)
+		compensation.Error = ""
+		compensation.CompletedAt = &now
+	}
+
+	pending, failed := false, false
+	for _, c := range saga.Compensations {
+		switch c.Status {
+		case models.CompensationPending:
+			pending = true
+		case models.CompensationFailed:
+			failed = true
+		}
+	}
+	switch {
+	case pending:
+		saga.Status = models.SagaCompensating
+	case failed:
+		saga.Status = models.SagaCompensationFailed
+		saga.NextCompensationAt = nil
+	default:
+		saga.Status = models.SagaCompensated
+		saga.NextCompensationAt = nil
+	}
+	return true
+}
+
+// ParseSagaTimeouts parses step deadlines such as "routing=48h,payment=72h" over DefaultSagaTimeouts
+// A duration of 0 removes the step's deadline.
+func ParseSagaTimeouts(spec string) (map[models.SagaStep]time.Duration, error) {
+	timeouts := make(map[models.SagaStep]time.Duration, len(DefaultSagaTimeouts))
+	for step, timeout := range DefaultSagaTimeouts {
+		timeouts[step] = timeout
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		step := models.SagaStep(strings.TrimSpace(name))
+		if !ok || sagaStepIndex(step) < 0 {
+			return nil, fmt.Errorf("invalid saga step timeout %q: expected step=duration with a step of validation, dur_review, enrollment, routing, adjudication, payment, verification, shipping or delivery", pair)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid saga step timeout %q: expected a duration such as 30m or 48h", pair)
+		}
+		timeouts[step] = timeout
+	}
+	return timeouts, nil
+}
+
+// sagaStepState returns a saga's record of a step, or nil if the step has not been seen
+func sagaStepState(saga *models.Saga, step models.SagaStep) *models.SagaStepState {
+	for i := range saga.Steps {
+		if saga.Steps[i].Step == step {
+			return &saga.Steps[i]
+		}
+	}
+	return nil
+}
+
+// addSagaStep records a step as started and returns its record
+func addSagaStep(saga *models.Saga, step models.SagaStep, at time.Time, eventID string) *models.SagaStepState {
+	saga.Steps = append(saga.Steps, models.SagaStepState{
+		Step:      step,
+		Status:    models.SagaStepRunning,
+		StartedAt: at,
+		StartedBy: eventID,
+	})
+	return &saga.Steps[len(saga.Steps)-1]
+}
@@ -0,0 +1,235 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+func runningSaga() *models.Saga {
+	return &models.Saga{PrescriptionID: "rx_1", Status: models.SagaRunning, Steps: []models.SagaStepState{}}
+}
+
+// TestApplySagaEvent tests that events complete their step and start the next with its deadline
+func TestApplySagaEvent(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	saga := runningSaga()
+
+	if !ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicIntakeReceived, EventID: "evt_1", CorrelationID: "corr_1", OccurredAt: start}, DefaultSagaTimeouts) {
+		t.Fatal("Expected intake to start the saga")
+	}
+	if saga.CurrentStep != models.SagaStepValidation || saga.Deadline == nil || !saga.Deadline.Equal(start.Add(15*time.Minute)) {
+		t.Errorf("Expected validation due in 15 minutes, got %s due %v", saga.CurrentStep, saga.Deadline)
+	}
+	if saga.CorrelationID != "corr_1" {
+		t.Errorf("Expected the correlation ID recorded, got %q", saga.CorrelationID)
+	}
+
+	validated := start.Add(time.Minute)
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicValidationCompleted, EventID: "evt_2", OccurredAt: validated}, DefaultSagaTimeouts)
+	if saga.Steps[0].Status != models.SagaStepCompleted || saga.Steps[0].CompletedBy != "evt_2" || saga.Steps[0].Deadline != nil {
+		t.Errorf("Expected validation completed, got %+v", saga.Steps[0])
+	}
+	if saga.CurrentStep != models.SagaStepEnrollment || !saga.Deadline.Equal(validated.Add(15*time.Minute)) {
+		t.Errorf("Expected enrollment current, got %s due %v", saga.CurrentStep, saga.Deadline)
+	}
+
+	if ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicValidationCompleted, EventID: "evt_2", OccurredAt: validated}, DefaultSagaTimeouts) {
+		t.Error("Expected a redelivered event not to change the saga")
+	}
+}
+
+// TestApplySagaEvent_DURReview tests that a DUR review runs between validation and enrollment only when requested
+func TestApplySagaEvent_DURReview(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	saga := runningSaga()
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicIntakeReceived, EventID: "evt_1", OccurredAt: start}, DefaultSagaTimeouts)
+
+	flagged := start.Add(time.Minute)
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicDURReviewRequested, EventID: "evt_2", OccurredAt: flagged}, DefaultSagaTimeouts)
+	if saga.Steps[0].Status != models.SagaStepCompleted || saga.CurrentStep != models.SagaStepDURReview || !saga.Deadline.Equal(flagged.Add(24*time.Hour)) {
+		t.Errorf("Expected validation completed and DUR review due in 24 hours, got %s due %v (%+v)", saga.CurrentStep, saga.Deadline, saga.Steps)
+	}
+
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicValidationCompleted, EventID: "evt_3", OccurredAt: flagged.Add(time.Hour)}, DefaultSagaTimeouts)
+	if len(saga.Steps) != 3 || saga.Steps[1].Step != models.SagaStepDURReview || saga.Steps[1].Status != models.SagaStepCompleted || saga.CurrentStep != models.SagaStepEnrollment {
+		t.Errorf("Expected DUR review completed and enrollment current, got %s (%+v)", saga.CurrentStep, saga.Steps)
+	}
+
+	unflagged := runningSaga()
+	ApplySagaEvent(unflagged, SagaEvent{Topic: kafka.TopicValidationCompleted, EventID: "evt_4", OccurredAt: start}, DefaultSagaTimeouts)
+	if sagaStepState(unflagged, models.SagaStepDURReview) != nil {
+		t.Errorf("Expected no DUR review step without a request, got %+v", unflagged.Steps)
+	}
+}
+
+// TestApplySagaEvent_Verification tests that a paid prescription waits on pharmacist verification before shipping
+func TestApplySagaEvent_Verification(t *testing.T) {
+	paid := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	saga := runningSaga()
+
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicPaymentCompleted, EventID: "evt_1", PaymentID: "pay_1", OccurredAt: paid}, DefaultSagaTimeouts)
+	if saga.CurrentStep != models.SagaStepVerification || !saga.Deadline.Equal(paid.Add(24*time.Hour)) || saga.PaymentID != "pay_1" {
+		t.Errorf("Expected verification due in 24 hours with the payment recorded, got %s due %v (%q)", saga.CurrentStep, saga.Deadline, saga.PaymentID)
+	}
+
+	verified := paid.Add(2 * time.Hour)
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicVerificationCompleted, EventID: "evt_2", OccurredAt: verified}, DefaultSagaTimeouts)
+	if state := sagaStepState(saga, models.SagaStepVerification); state == nil || state.Status != models.SagaStepCompleted {
+		t.Errorf("Expected verification completed, got %+v", saga.Steps)
+	}
+	if saga.CurrentStep != models.SagaStepShipping || !saga.Deadline.Equal(verified.Add(time.Hour)) {
+		t.Errorf("Expected shipping due an hour after verification, got %s due %v", saga.CurrentStep, saga.Deadline)
+	}
+}
+
+// TestApplySagaEvent_OutOfOrder tests that a late event for an earlier step neither moves the saga back nor loses the step
+func TestApplySagaEvent_OutOfOrder(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	saga := runningSaga()
+
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicPharmacySelected, PharmacyID: "ph_1", OccurredAt: at}, DefaultSagaTimeouts)
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicEnrollmentCompleted, OccurredAt: at.Add(-time.Minute)}, DefaultSagaTimeouts)
+
+	if saga.CurrentStep != models.SagaStepAdjudication || saga.PharmacyID != "ph_1" {
+		t.Errorf("Expected adjudication current with the pharmacy recorded, got %s and %q", saga.CurrentStep, saga.PharmacyID)
+	}
+	if len(saga.Steps) != 3 || saga.Steps[0].Step != models.SagaStepEnrollment || saga.Steps[1].Step != models.SagaStepRouting || saga.Steps[1].Status != models.SagaStepCompleted {
+		t.Errorf("Expected enrollment and routing completed, then adjudication, got %+v", saga.Steps)
+	}
+}
+
+// TestApplySagaEvent_Delivered tests that delivery completes the saga without a deadline
+func TestApplySagaEvent_Delivered(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	saga := runningSaga()
+
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicShipmentLabelCreated, ShipmentID: "shp_1", OccurredAt: at}, DefaultSagaTimeouts)
+	if saga.CurrentStep != models.SagaStepDelivery || saga.Deadline != nil {
+		t.Errorf("Expected delivery current without a deadline, got %s due %v", saga.CurrentStep, saga.Deadline)
+	}
+
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicShipmentDelivered, OccurredAt: at.Add(48 * time.Hour)}, DefaultSagaTimeouts)
+	if saga.Status != models.SagaCompleted {
+		t.Errorf("Expected the saga completed, got %s", saga.Status)
+	}
+	if ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicPaymentCompleted, OccurredAt: at}, DefaultSagaTimeouts) {
+		t.Error("Expected a completed saga to ignore events")
+	}
+}
+
+// TestApplySagaEvent_Exception tests that an exception is attached to the current step
+func TestApplySagaEvent_Exception(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	saga := runningSaga()
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicPharmacySelected, OccurredAt: at}, DefaultSagaTimeouts)
+
+	exception := &models.PrescriptionException{Type: models.ExceptionClaimRejected, Reason: "Refill too soon"}
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicPrescriptionException, Exception: exception, OccurredAt: at}, DefaultSagaTimeouts)
+
+	state := sagaStepState(saga, models.SagaStepAdjudication)
+	if state == nil || state.Exception == nil || state.Exception.Reason != "Refill too soon" || state.Status != models.SagaStepRunning {
+		t.Errorf("Expected the exception on the running adjudication step, got %+v", state)
+	}
+}
+
+// TestFailSaga tests that a failure plans compensations for the resources held, latest first
+func TestFailSaga(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	saga := runningSaga()
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicPharmacySelected, PharmacyID: "ph_1", OccurredAt: at}, DefaultSagaTimeouts)
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicPaymentLinkCreated, PaymentID: "pay_1", OccurredAt: at}, DefaultSagaTimeouts)
+
+	if !FailSaga(saga, models.SagaFailure{Step: models.SagaStepPayment, Reason: "deadline passed", TimedOut: true, At: at}) {
+		t.Fatal("Expected the saga failed")
+	}
+	if saga.Status != models.SagaCompensating || saga.Deadline != nil || saga.NextCompensationAt == nil {
+		t.Errorf("Expected the saga compensating now, got %s, %v and %v", saga.Status, saga.Deadline, saga.NextCompensationAt)
+	}
+	state := sagaStepState(saga, models.SagaStepPayment)
+	if state == nil || state.Status != models.SagaStepTimedOut || state.Error != "deadline passed" {
+		t.Errorf("Expected the payment step timed out, got %+v", state)
+	}
+
+	want := []models.SagaCompensationAction{models.CompensationVoidPaymentLink, models.CompensationRefund, models.CompensationReleaseCapacity}
+	if len(saga.Compensations) != len(want) {
+		t.Fatalf("Expected %d compensations, got %+v", len(want), saga.Compensations)
+	}
+	for i, action := range want {
+		if saga.Compensations[i].Action != action || saga.Compensations[i].Status != models.CompensationPending {
+			t.Errorf("Expected %s pending at %d, got %+v", action, i, saga.Compensations[i])
+		}
+	}
+
+	if FailSaga(saga, models.SagaFailure{Step: models.SagaStepPayment, At: at}) {
+		t.Error("Expected a saga to fail only once")
+	}
+}
+
+// TestFailSaga_CompletedStep tests that a failure of a completed step does not fail the saga, and that nothing to undo compensates at once
+func TestFailSaga_CompletedStep(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	saga := runningSaga()
+	ApplySagaEvent(saga, SagaEvent{Topic: kafka.TopicValidationCompleted, OccurredAt: at}, DefaultSagaTimeouts)
+
+	if FailSaga(saga, models.SagaFailure{Step: models.SagaStepValidation, At: at}) {
+		t.Error("Expected a completed step not to fail the saga")
+	}
+	if !FailSaga(saga, models.SagaFailure{Step: models.SagaStepEnrollment, Reason: "enrollment failed", At: at}) || saga.Status != models.SagaCompensated {
+		t.Errorf("Expected the saga compensated with nothing to undo, got %s", saga.Status)
+	}
+}
+
+// TestApplyCompensationResult tests retries with backoff, giving up after the maximum attempts and settling the saga
+func TestApplyCompensationResult(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	saga := runningSaga()
+	saga.PaymentID = "pay_1"
+	FailSaga(saga, models.SagaFailure{Step: models.SagaStepShipping, At: at})
+
+	ApplyCompensationResult(saga, models.CompensationVoidPaymentLink, CompensationResult{Status: models.CompensationSkipped, Detail: "link already paid"}, at, 2)
+	ApplyCompensationResult(saga, models.CompensationRefund, CompensationResult{Err: errors.New("provider unavailable")}, at, 2)
+	if saga.Status != models.SagaCompensating || !saga.NextCompensationAt.Equal(at.Add(time.Minute)) {
+		t.Errorf("Expected a retry in a minute, got %s at %v", saga.Status, saga.NextCompensationAt)
+	}
+
+	ApplyCompensationResult(saga, models.CompensationRefund, CompensationResult{Err: errors.New("provider unavailable")}, at.Add(time.Minute), 2)
+	if saga.Status != models.SagaCompensationFailed || saga.Compensations[1].Status != models.CompensationFailed || saga.Compensations[1].Attempts != 2 {
+		t.Errorf("Expected the refund given up, got %s and %+v", saga.Status, saga.Compensations[1])
+	}
+	if ApplyCompensationResult(saga, models.CompensationRefund, CompensationResult{Status: models.CompensationDone}, at, 2) {
+		t.Error("Expected a settled compensation not to change")
+	}
+
+	saga = runningSaga()
+	saga.ShipmentID = "shp_1"
+	FailSaga(saga, models.SagaFailure{Step: models.SagaStepDelivery, At: at})
+	ApplyCompensationResult(saga, models.CompensationVoidLabel, CompensationResult{Status: models.CompensationDone, Detail: "label voided"}, at, 2)
+	if saga.Status != models.SagaCompensated || saga.NextCompensationAt != nil || saga.Compensations[0].CompletedAt == nil {
+		t.Errorf("Expected the saga compensated, got %s and %+v", saga.Status, saga.Compensations[0])
+	}
+}
+
+// TestParseSagaTimeouts tests that overrides merge over the defaults and bad specs are rejected
+func TestParseSagaTimeouts(t *testing.T) {
+	timeouts, err := ParseSagaTimeouts(" payment=72h, shipping=0 ")
+	if err != nil {
+		t.Fatalf("Expected timeouts, got %v", err)
+	}
+	if timeouts[models.SagaStepPayment] != 72*time.Hour || timeouts[models.SagaStepShipping] != 0 || timeouts[models.SagaStepRouting] != 24*time.Hour {
+		t.Errorf("Expected the overrides merged over the defaults, got %v", timeouts)
+	}
+	if DefaultSagaTimeouts[models.SagaStepPayment] != 48*time.Hour {
+		t.Error("Expected the defaults left unchanged")
+	}
+
+	for _, spec := range []string{"payment", "dispensing=1h", "payment=soon", "payment=-1h"} {
+		if _, err := ParseSagaTimeouts(spec); err == nil {
+			t.Errorf("Expected %q rejected", spec)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrShipmentNotFound is returned when no shipment has the requested ID
+	ErrShipmentNotFound = errors.New("shipment not found")
+	// ErrInvalidExcursion is returned when a temperature excursion report is not accepted
+	ErrInvalidExcursion = errors.New("invalid temperature excursion")
+)
+
+// excursionSources are the parties that may report a temperature excursion
+var excursionSources = map[models.ExcursionSource]bool{
+	models.ExcursionSourceDataLogger: true,
+	models.ExcursionSourceCarrier:    true,
+	models.ExcursionSourcePharmacy:   true,
+	models.ExcursionSourcePatient:    true,
+}
+
+// ShipmentService reads shipments and records temperature excursions on cold chain shipments
+type ShipmentService struct {
+	mongoClient *database.MongoClient
+}
+
+// NewShipmentService creates a new shipment service
+func NewShipmentService(mongoClient *database.MongoClient) *ShipmentService {
+	return &ShipmentService{
+		mongoClient: mongoClient,
+	}
+}
+
+// Get returns a shipment by ID
+func (s *ShipmentService) Get(ctx context.Context, id string) (*models.Shipment, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrShipmentNotFound
+	}
+
+	var shipment models.Shipment
+	err = s.mongoClient.GetCollection("shipments").FindOne(ctx, bson.M{"_id": oid}).Decode(&shipment)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shipment %s: %w", id, err)
+	}
+	return &shipment, nil
+}
+
+// ReportExcursion records a temperature excursion on a cold chain shipment and flags it
+// The returned shipment reflects the report; callers request a replacement while it has none
+func (s *ShipmentService) ReportExcursion(ctx context.Context, id string, excursion models.TemperatureExcursion) (*models.Shipment, error) {
+	shipment, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	excursion.ReportedAt = now
+	if excursion.ObservedAt.IsZero() {
+		excursion.ObservedAt = now
+	}
+	if err := ValidateExcursion(shipment, excursion, now); err != nil {
+		return nil, err
+	}
+
+	var updated models.Shipment
+	err = s.mongoClient.GetCollection("shipments").FindOneAndUpdate(ctx,
+		bson.M{"_id": shipment.ID, "cold_chain": bson.M{"$ne": nil}},
+		bson.M{
+			"$push": bson.M{"cold_chain.excursions": excursion},
+			"$set": bson.M{
+				"cold_chain.excursion_flagged": true,
+				"updated_at":                   now,
+			},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record temperature excursion: %w", err)
+	}
+	return &updated, nil
+}
+
+// ChangeShipment audits and applies a change to the shipment matching filter, returning it as it was or nil if none matches
+func ChangeShipment(ctx context.Context, shipments *mongo.Collection, correlationID, reason string, filter, set bson.M) (*models.Shipment, error) {
+	var shipment models.Shipment
+	err := shipments.FindOne(ctx, filter).Decode(&shipment)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shipment: %w", err)
+	}
+	if err := recordShipmentChange(ctx, &shipment, correlationID, reason, set); err != nil {
+		return nil, err
+	}
+
+	fields := bson.M{"updated_at": time.Now()}
+	for field, value := range set {
+		fields[field] = value
+	}
+	matched := bson.M{"_id": shipment.ID}
+	for field, value := range filter {
+		matched[field] = value
+	}
+	result, err := shipments.UpdateOne(ctx, matched, bson.M{"$set": fields})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update shipment %s: %w", shipment.ID.Hex(), err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, nil
+	}
+	return &shipment, nil
+}
+
+// recordShipmentChange audits a change to a shipment
+// The entry is required, so it is written before the change and an error means the change is not made.
+func recordShipmentChange(ctx context.Context, shipment *models.Shipment, correlationID, reason string, set bson.M) error {
+	details := map[string]interface{}{
+		"from_status":    shipment.Status,
+		"reason":         reason,
+		"correlation_id": correlationID,
+	}
+	for field, value := range set {
+		details[field] = value
+	}
+	err := audit.Record(ctx, audit.Entry{
+		EventType:  audit.EventShipmentChanged,
+		EntityType: audit.EntityShipment,
+		EntityID:   shipment.ID.Hex(),
+		Action:     audit.ActionUpdate,
+		Details:    details,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to audit shipment %s: %w", shipment.ID.Hex(), err)
+	}
+	return nil
+}
+
+// ValidateExcursion checks a temperature excursion report against the shipment's cold chain requirement
+// A reported temperature must be outside the storage range; reports without one (a tripped indicator) are accepted
+func ValidateExcursion(shipment *models.Shipment, excursion models.TemperatureExcursion, now time.Time) error {
+	if shipment.ColdChain == nil {
+		return fmt.Errorf("%w: shipment %s is not a cold chain shipment", ErrInvalidExcursion, shipment.ID.Hex())
+	}
+	if shipment.Status == models.ShipmentVoided {
+		return fmt.Errorf("%w: shipment %s was voided before pickup", ErrInvalidExcursion, shipment.ID.Hex())
+	}
+	if !excursionSources[excursion.Source] {
+		return fmt.Errorf("%w: unknown source %q", ErrInvalidExcursion, excursion.Source)
+	}
+	if strings.TrimSpace(excursion.ReportedBy) == "" {
+		return fmt.Errorf("%w: reporter is required", ErrInvalidExcursion)
+	}
+	if excursion.DurationMinutes < 0 {
+		return fmt.Errorf("%w: duration must not be negative", ErrInvalidExcursion)
+	}
+	if excursion.ObservedAt.After(now) {
+		return fmt.Errorf("%w: observed_at is in the future", ErrInvalidExcursion)
+	}
+
+	if excursion.TemperatureC != nil {
+		tempC := *excursion.TemperatureC
+		if tempC >= shipment.ColdChain.MinTempC && tempC <= shipment.ColdChain.MaxTempC {
+			return fmt.Errorf("%w: %.1f°C is within the %.0f-%.0f°C storage range", ErrInvalidExcursion,
+				tempC, shipment.ColdChain.MinTempC, shipment.ColdChain.MaxTempC)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestValidateExcursion tests which temperature excursion reports flag a cold chain shipment
+func TestValidateExcursion(t *testing.T) {
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	coldChain := &models.Shipment{
+		ID:        primitive.NewObjectID(),
+		Status:    models.ShipmentInTransit,
+		ColdChain: &models.ShipmentColdChain{MinTempC: 2, MaxTempC: 8, Packaging: "insulated_shipper_gel_packs"},
+	}
+	temp := func(c float64) *float64 { return &c }
+
+	tests := []struct {
+		name      string
+		shipment  *models.Shipment
+		excursion models.TemperatureExcursion
+		valid     bool
+	}{
+		{"logger above range", coldChain, models.TemperatureExcursion{Source: models.ExcursionSourceDataLogger, TemperatureC: temp(11.5), DurationMinutes: 95, ObservedAt: now.Add(-time.Hour), ReportedBy: "ops_1"}, true},
+		{"frozen in transit", coldChain, models.TemperatureExcursion{Source: models.ExcursionSourceCarrier, TemperatureC: temp(-0.5), ObservedAt: now, ReportedBy: "ops_1"}, true},
+		{"tripped indicator without a reading", coldChain, models.TemperatureExcursion{Source: models.ExcursionSourcePatient, ObservedAt: now, ReportedBy: "ops_1"}, true},
+		{"reading within range", coldChain, models.TemperatureExcursion{Source: models.ExcursionSourceDataLogger, TemperatureC: temp(8), ObservedAt: now, ReportedBy: "ops_1"}, false},
+		{"unknown source", coldChain, models.TemperatureExcursion{Source: "neighbour", ObservedAt: now, ReportedBy: "ops_1"}, false},
+		{"observed in the future", coldChain, models.TemperatureExcursion{Source: models.ExcursionSourcePharmacy, ObservedAt: now.Add(time.Hour), ReportedBy: "ops_1"}, false},
+		{"missing reporter", coldChain, models.TemperatureExcursion{Source: models.ExcursionSourcePharmacy, ObservedAt: now}, false},
+		{"room temperature shipment", &models.Shipment{ID: primitive.NewObjectID(), Status: models.ShipmentInTransit}, models.TemperatureExcursion{Source: models.ExcursionSourcePatient, ObservedAt: now, ReportedBy: "ops_1"}, false},
+		{"voided shipment", &models.Shipment{ID: primitive.NewObjectID(), Status: models.ShipmentVoided, ColdChain: coldChain.ColdChain}, models.TemperatureExcursion{Source: models.ExcursionSourcePharmacy, ObservedAt: now, ReportedBy: "ops_1"}, false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateExcursion(tt.shipment, tt.excursion, now)
+		if tt.valid && err != nil {
+			t.Errorf("%s: expected valid, got %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidExcursion) {
+			t.Errorf("%s: expected ErrInvalidExcursion, got %v", tt.name, err)
+		}
+	}
+}
+
+// TestDrug_StorageRange tests refrigeration from explicit storage data and from required pharmacy services
+func TestDrug_StorageRange(t *testing.T) {
+	tests := []struct {
+		name         string
+		drug         models.Drug
+		refrigerated bool
+		minC, maxC   float64
+	}{
+		{"room temperature", models.Drug{NDC: "00093-5057-01"}, false, 0, 0},
+		{"refrigerated defaults to 2-8", models.Drug{Refrigerated: true}, true, 2, 8},
+		{"explicit range", models.Drug{Refrigerated: true, StorageMinTempC: -25, StorageMaxTempC: -15}, true, -25, -15},
+		{"cold chain storage service", models.Drug{RequiredServices: []string{"specialty_dispensing", models.ServiceColdChainStorage}}, true, 2, 8},
+	}
+
+	for _, tt := range tests {
+		minC, maxC, refrigerated := tt.drug.StorageRange()
+		if refrigerated != tt.refrigerated || minC != tt.minC || maxC != tt.maxC {
+			t.Errorf("%s: expected (%.0f, %.0f, %v), got (%.0f, %.0f, %v)", tt.name, tt.minC, tt.maxC, tt.refrigerated, minC, maxC, refrigerated)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Package services provides business logic services
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/documents"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultDocumentURLTTL is how long signed document links stay valid
+const DefaultDocumentURLTTL = 15 * time.Minute
+
+// DocumentStorage stores rendered documents and signs short-lived links to them
+// MinIOService implements it
+type DocumentStorage interface {
+	Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, opts *UploadOptions) (*ObjectInfo, error)
+	GenerateSignedURL(ctx context.Context, bucket, objectName string, expiry time.Duration) (string, error)
+}
+
+// DocumentLink is a signed, short-lived link to a shipment document
+type DocumentLink struct {
+	Type        string `json:"type"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url"`
+}
+
+// ShipmentDocumentService renders packing slips and fallback labels and stores them in object storage
+type ShipmentDocumentService struct {
+	mongoClient *database.MongoClient
+	storage     DocumentStorage
+	bucket      string
+	urlTTL      time.Duration
+}
+
+// NewShipmentDocumentService creates a new shipment document service
+func NewShipmentDocumentService(mongoClient *database.MongoClient, storage DocumentStorage, bucket string, urlTTL time.Duration) *ShipmentDocumentService {
+	if urlTTL <= 0 {
+		urlTTL = DefaultDocumentURLTTL
+	}
+	return &ShipmentDocumentService{
+		mongoClient: mongoClient,
+		storage:     storage,
+		bucket:      bucket,
+		urlTTL:      urlTTL,
+	}
+}
+
+// Generate renders the shipment's packing slip and 4x6 label, uploads them and records them on the shipment
+func (s *ShipmentDocumentService) Generate(ctx context.Context, shipment *models.Shipment) ([]models.ShipmentDocument, error) {
+	var prescription models.Prescription
+	err := s.mongoClient.GetCollection("prescriptions").FindOne(ctx, bson.M{"_id": shipment.PrescriptionID}).Decode(&prescription)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prescription %s: %w", shipment.PrescriptionID.Hex(), err)
+	}
+
+	// The pharmacy only adds contact details, so a missing one is not fatal
+	var pharmacy models.Pharmacy
+	if oid, err := primitive.ObjectIDFromHex(shipment.PharmacyID); err == nil {
+		err = s.mongoClient.GetCollection("pharmacies").FindOne(ctx, bson.M{"_id": oid}).Decode(&pharmacy)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("failed to load pharmacy %s: %w", shipment.PharmacyID, err)
+		}
+	}
+
+	now := time.Now()
+	slip, err := documents.RenderPackingSlip(BuildPackingSlip(shipment, &prescription, &pharmacy, now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render packing slip: %w", err)
+	}
+	label, err := documents.RenderShippingLabel(BuildShippingLabel(shipment, &prescription, &pharmacy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render shipping label: %w", err)
+	}
+
+	var stored []models.ShipmentDocument
+	for _, doc := range []struct {
+		docType string
+		content []byte
+	}{
+		{documents.TypePackingSlip, slip},
+		{documents.TypeLabel4x6, label},
+	} {
+		objectName := ShipmentDocumentObject(shipment.PrescriptionID.Hex(), shipment.ID.Hex(), doc.docType)
+		info, err := s.storage.Upload(ctx, s.bucket, objectName, bytes.NewReader(doc.content), int64(len(doc.content)), &UploadOptions{
+			ContentType: documents.ContentType,
+			Metadata: map[string]string{
+				"prescription-id": shipment.PrescriptionID.Hex(),
+				"shipment-id":     shipment.ID.Hex(),
+				"document-type":   doc.docType,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to store %s: %w", doc.docType, err)
+		}
+		stored = append(stored, models.ShipmentDocument{
+			Type:        doc.docType,
+			Bucket:      s.bucket,
+			ObjectName:  objectName,
+			ContentType: documents.ContentType,
+			Size:        info.Size,
+			CreatedAt:   now,
+		})
+	}
+
+	_, err = s.mongoClient.GetCollection("shipments").UpdateOne(ctx, bson.M{"_id": shipment.ID}, bson.M{
+		"$set": bson.M{"documents": stored, "updated_at": now},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record shipment documents: %w", err)
+	}
+	shipment.Documents = stored
+	return stored, nil
+}
+
+// Links returns signed links to the shipment's documents, rendering them first if they were never stored
+func (s *ShipmentDocumentService) Links(ctx context.Context, shipment *models.Shipment) ([]DocumentLink, time.Time, error) {
+	stored := shipment.Documents
+	if len(stored) == 0 {
+		var err error
+		if stored, err = s.Generate(ctx, shipment); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	expiresAt := time.Now().Add(s.urlTTL)
+	links := make([]DocumentLink, 0, len(stored))
+	for _, doc := range stored {
+		url, err := s.storage.GenerateSignedURL(ctx, doc.Bucket, doc.ObjectName, s.urlTTL)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to sign %s link: %w", doc.Type, err)
+		}
+		links = append(links, DocumentLink{
+			Type:        doc.Type,
+			ContentType: doc.ContentType,
+			Size:        doc.Size,
+			URL:         url,
+		})
+	}
+	return links, expiresAt, nil
+}
+
+// ShipmentDocumentObject returns the object name of a shipment document, under a per-prescription prefix
+func ShipmentDocumentObject(prescriptionID, shipmentID, docType string) string {
+	return fmt.Sprintf("prescriptions/%s/shipments/%s/%s.pdf", prescriptionID, shipmentID, strings.ReplaceAll(docType, "_", "-"))
+}
+
+// BuildPackingSlip assembles the packing slip content for a shipment
+func BuildPackingSlip(shipment *models.Shipment, prescription *models.Prescription, pharmacy *models.Pharmacy, now time.Time) documents.PackingSlip {
+	rxNumber := prescription.PrescriptionID
+	if rxNumber == "" {
+		rxNumber = prescription.ID.Hex()
+	}
+	return documents.PackingSlip{
+		RxNumber:       rxNumber,
+		ShipTo:         shipToAddress(shipment, prescription),
+		DrugName:       prescription.Medication.Name,
+		NDC:            prescription.Medication.NDC,
+		Quantity:       prescription.Medication.Quantity,
+		DaysSupply:     prescription.Medication.DaysSupply,
+		Directions:     prescription.Medication.Directions,
+		PharmacyName:   pharmacy.Name,
+		PharmacyPhone:  pharmacy.Phone,
+		PharmacyEmail:  pharmacy.Email,
+		PharmacyAddr:   pharmacyPostalAddress(pharmacy),
+		ShipmentID:     shipment.ID.Hex(),
+		TrackingNumber: shipment.TrackingNumber,
+		ColdChain:      coldChainNotice(shipment),
+		Date:           now,
+	}
+}
+
+// BuildShippingLabel assembles the fallback 4x6 label content for a shipment
+func BuildShippingLabel(shipment *models.Shipment, prescription *models.Prescription, pharmacy *models.Pharmacy) documents.ShippingLabel {
+	shipDate := shipment.CreatedAt
+	if shipment.ColdChain != nil {
+		shipDate = shipment.ColdChain.ShipOn
+	}
+	from := pharmacyPostalAddress(pharmacy)
+	from.Company = pharmacy.Name
+	return documents.ShippingLabel{
+		From:           from,
+		To:             shipToAddress(shipment, prescription),
+		Carrier:        shipment.Carrier,
+		ServiceName:    shipment.ServiceName,
+		TrackingNumber: shipment.TrackingNumber,
+		WeightOz:       shipment.Parcel.WeightOz,
+		ShipDate:       shipDate,
+		ColdChain:      coldChainNotice(shipment),
+	}
+}
+
+// shipToAddress is the address the label was bought for, falling back to the prescription's
+func shipToAddress(shipment *models.Shipment, prescription *models.Prescription) documents.PostalAddress {
+	address := shipment.ShipTo
+	if address.Street == "" {
+		address = prescription.Patient.Address
+	}
+	return documents.PostalAddress{
+		Name:   strings.TrimSpace(prescription.Patient.FirstName + " " + prescription.Patient.LastName),
+		Street: address.Street,
+		City:   address.City,
+		State:  address.State,
+		Zip:    address.ZipCode,
+	}
+}
+
+// pharmacyPostalAddress returns the pharmacy's street address
+func pharmacyPostalAddress(pharmacy *models.Pharmacy) documents.PostalAddress {
+	return documents.PostalAddress{
+		Street: pharmacy.Address.Street,
+		City:   pharmacy.Address.City,
+		State:  pharmacy.Address.State,
+		Zip:    pharmacy.Address.Zip,
+	}
+}
+
+// coldChainNotice returns the refrigeration notice for cold chain shipments
+func coldChainNotice(shipment *models.Shipment) *documents.ColdChainNotice {
+	if shipment.ColdChain == nil {
+		return nil
+	}
+	return &documents.ColdChainNotice{MinTempC: shipment.ColdChain.MinTempC, MaxTempC: shipment.ColdChain.MaxTempC}
+}
@@ -0,0 +1,123 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// signingStorage signs fake links and refuses uploads
+type signingStorage struct {
+	signed []string
+}
+
+func (s *signingStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, opts *UploadOptions) (*ObjectInfo, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+
+func (s *signingStorage) GenerateSignedURL(ctx context.Context, bucket, objectName string, expiry time.Duration) (string, error) {
+	s.signed = append(s.signed, objectName)
+	return "http://minio.test/" + bucket + "/" + objectName + "?X-Amz-Expires=" + expiry.String(), nil
+}
+
+func documentShipment() (*models.Shipment, *models.Prescription, *models.Pharmacy) {
+	prescription := &models.Prescription{
+		ID:             primitive.NewObjectID(),
+		PrescriptionID: "RX-1001",
+		Patient: models.PatientInfo{
+			FirstName: "Jane",
+			LastName:  "Doe",
+			Address:   models.Address{Street: "1 Main St", City: "Springfield", State: "IL", ZipCode: "62701"},
+		},
+		Medication: models.MedicationInfo{NDC: "00074-4339-02", Name: "Humira 40 mg/0.4 mL Pen", Quantity: 2, DaysSupply: 28, Directions: "Inject every other week"},
+	}
+	pharmacy := &models.Pharmacy{
+		ID:      primitive.NewObjectID(),
+		Name:    "Downtown Specialty Pharmacy",
+		Phone:   "(555) 010-2000",
+		Address: models.PharmacyAddress{Street: "200 State St", City: "Chicago", State: "IL", Zip: "60601"},
+	}
+	shipOn := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	shipment := &models.Shipment{
+		ID:             primitive.NewObjectID(),
+		PrescriptionID: prescription.ID,
+		PharmacyID:     pharmacy.ID.Hex(),
+		Carrier:        "FedEx",
+		ServiceName:    "Priority Overnight",
+		TrackingNumber: "794600000000",
+		Parcel:         models.ShipmentParcel{WeightOz: 72},
+		ShipTo:         models.Address{Street: "1 MAIN ST", City: "SPRINGFIELD", State: "IL", ZipCode: "62701-1234"},
+		ColdChain:      &models.ShipmentColdChain{MinTempC: 2, MaxTempC: 8, ShipOn: shipOn},
+		CreatedAt:      shipOn.Add(-20 * time.Hour),
+	}
+	return shipment, prescription, pharmacy
+}
+
+// TestBuildShipmentDocuments tests packing slip and label content assembled from the shipment
+func TestBuildShipmentDocuments(t *testing.T) {
+	shipment, prescription, pharmacy := documentShipment()
+
+	slip := BuildPackingSlip(shipment, prescription, pharmacy, time.Now())
+	if slip.RxNumber != "RX-1001" || slip.Quantity != 2 || slip.Directions != "Inject every other week" {
+		t.Errorf("Unexpected packing slip prescription details: %+v", slip)
+	}
+	if slip.ShipTo.Name != "Jane Doe" || slip.ShipTo.Zip != "62701-1234" {
+		t.Errorf("Expected the validated ship-to address, got %+v", slip.ShipTo)
+	}
+	if slip.PharmacyPhone != "(555) 010-2000" || slip.ColdChain == nil {
+		t.Errorf("Expected pharmacist contact and cold chain notice, got %+v", slip)
+	}
+
+	label := BuildShippingLabel(shipment, prescription, pharmacy)
+	if label.From.Company != pharmacy.Name || label.TrackingNumber != "794600000000" || label.WeightOz != 72 {
+		t.Errorf("Unexpected label: %+v", label)
+	}
+	if !label.ShipDate.Equal(shipment.ColdChain.ShipOn) {
+		t.Errorf("Expected cold chain labels to carry the ship-on date, got %s", label.ShipDate)
+	}
+
+	shipment.ColdChain = nil
+	shipment.ShipTo = models.Address{}
+	label = BuildShippingLabel(shipment, prescription, pharmacy)
+	if label.ColdChain != nil || !label.ShipDate.Equal(shipment.CreatedAt) || label.To.Zip != "62701" {
+		t.Errorf("Expected a room temperature label to the prescription address, got %+v", label)
+	}
+}
+
+// TestShipmentDocumentService_Links tests signing stored documents under the per-prescription prefix
+func TestShipmentDocumentService_Links(t *testing.T) {
+	shipment, prescription, _ := documentShipment()
+	storage := &signingStorage{}
+	service := NewShipmentDocumentService(nil, storage, "shipping-labels", 0)
+
+	objectName := ShipmentDocumentObject(prescription.ID.Hex(), shipment.ID.Hex(), "packing_slip")
+	if !strings.HasPrefix(objectName, "prescriptions/"+prescription.ID.Hex()+"/") || !strings.HasSuffix(objectName, "/packing-slip.pdf") {
+		t.Errorf("Unexpected object name %s", objectName)
+	}
+
+	shipment.Documents = []models.ShipmentDocument{
+		{Type: "packing_slip", Bucket: "shipping-labels", ObjectName: objectName, ContentType: "application/pdf", Size: 2048},
+		{Type: "label_4x6", Bucket: "shipping-labels", ObjectName: ShipmentDocumentObject(prescription.ID.Hex(), shipment.ID.Hex(), "label_4x6"), ContentType: "application/pdf", Size: 1024},
+	}
+
+	before := time.Now()
+	links, expiresAt, err := service.Links(context.Background(), shipment)
+	if err != nil {
+		t.Fatalf("Expected links, got %v", err)
+	}
+	if len(links) != 2 || links[0].Type != "packing_slip" || !strings.Contains(links[0].URL, "X-Amz-Expires=15m0s") {
+		t.Errorf("Unexpected links: %+v", links)
+	}
+	if expiresAt.Before(before.Add(DefaultDocumentURLTTL)) {
+		t.Errorf("Expected links to expire after %s, got %s", DefaultDocumentURLTTL, expiresAt)
+	}
+	if len(storage.signed) != 2 {
+		t.Errorf("Expected two signed objects, got %v", storage.signed)
+	}
+}
@@ -0,0 +1,349 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/shipping"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TrackingPollWindow is how long after the label is bought a shipment that never reaches a final status is still polled
+const TrackingPollWindow = 30 * 24 * time.Hour
+
+// trackingUpdateAttempts bounds retries when a webhook and a poll update the same shipment at once
+const trackingUpdateAttempts = 3
+
+// trackedStatuses are the shipment statuses still waiting on the carrier
+var trackedStatuses = []models.ShipmentStatus{
+	models.ShipmentLabelCreated,
+	models.ShipmentInTransit,
+	models.ShipmentOutForDelivery,
+	models.ShipmentException,
+}
+
+// TrackingUpdate describes what a tracking update changed on a shipment
+type TrackingUpdate struct {
+	Recorded       bool                  // a new event was added to the history
+	StatusChanged  bool                  // the shipment moved to a new status
+	PreviousStatus models.ShipmentStatus // status before the update
+}
+
+// ShipmentTrackingService records carrier tracking updates on shipments and moves their status
+type ShipmentTrackingService struct {
+	mongoClient *database.MongoClient
+	producer    kafka.Producer
+}
+
+// NewShipmentTrackingService creates a new shipment tracking service
+func NewShipmentTrackingService(mongoClient *database.MongoClient, producer kafka.Producer) *ShipmentTrackingService {
+	return &ShipmentTrackingService{
+		mongoClient: mongoClient,
+		producer:    producer,
+	}
+}
+
+// Apply records a carrier tracking status on the shipment with its tracking number
+// Updates are idempotent: an event already in the history is not recorded again, and the
+// status only moves forward from events at least as recent as the latest one recorded.
+func (s *ShipmentTrackingService) Apply(ctx context.Context, status *shipping.TrackingStatus, source models.TrackingSource) (*models.Shipment, TrackingUpdate, error) {
+	collection := s.mongoClient.GetCollection("shipments")
+	lookup := bson.M{"tracking_number": status.TrackingNumber, "status": bson.M{"$ne": models.ShipmentVoided}}
+	findOpts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	for attempt := 0; attempt < trackingUpdateAttempts; attempt++ {
+		var shipment models.Shipment
+		err := collection.FindOne(ctx, lookup, findOpts).Decode(&shipment)
+		if err == mongo.ErrNoDocuments {
+			return nil, TrackingUpdate{}, ErrShipmentNotFound
+		}
+		if err != nil {
+			return nil, TrackingUpdate{}, fmt.Errorf("failed to load shipment for tracking number %s: %w", status.TrackingNumber, err)
+		}
+
+		now := time.Now()
+		update := TrackingUpdate{PreviousStatus: shipment.Status}
+		lastUpdated := shipment.UpdatedAt
+		if to, ok := ShipmentStatusForTracking(status.State); ok {
+			event := models.TrackingEvent{
+				Status:       to,
+				CarrierState: string(status.State),
+				Details:      status.Details,
+				Location:     status.Location,
+				OccurredAt:   status.UpdatedAt,
+				Source:       source,
+				ReceivedAt:   now,
+			}
+			if event.OccurredAt.IsZero() {
+				event.OccurredAt = now
+			}
+			update.Recorded, update.StatusChanged = ApplyTrackingEvent(&shipment, event)
+		}
+
+		set := bson.M{"last_tracked_at": now}
+		if update.Recorded {
+			set["tracking_events"] = shipment.TrackingEvents
+			set["status"] = shipment.Status
+			set["updated_at"] = now
+			if shipment.DeliveredAt != nil {
+				set["delivered_at"] = shipment.DeliveredAt
+			}
+		}
+
+		// Only write over the shipment we read; a concurrent update means starting again from the stored history
+		result, err := collection.UpdateOne(ctx, bson.M{"_id": shipment.ID, "updated_at": lastUpdated}, bson.M{"$set": set})
+		if err != nil {
+			return nil, TrackingUpdate{}, fmt.Errorf("failed to record tracking for shipment %s: %w", shipment.ID.Hex(), err)
+		}
+		if result.MatchedCount == 0 {
+			continue
+		}
+
+		shipment.LastTrackedAt = &now
+		if update.StatusChanged {
+			err := audit.Record(ctx, audit.Entry{
+				EventType:  audit.EventShipmentChanged,
+				EntityType: audit.EntityShipment,
+				EntityID:   shipment.ID.Hex(),
+				Action:     audit.ActionUpdate,
+				Details: map[string]interface{}{
+					"status":          shipment.Status,
+					"from_status":     update.PreviousStatus,
+					"tracking_source": source,
+					"correlation_id":  shipment.CorrelationID,
+				},
+			})
+			if err != nil {
+				return nil, TrackingUpdate{}, err
+			}
+		}
+		if update.Recorded {
+			shipment.UpdatedAt = now
+		}
+		return &shipment, update, nil
+	}
+	return nil, TrackingUpdate{}, fmt.Errorf("tracking number %s was updated concurrently %d times", status.TrackingNumber, trackingUpdateAttempts)
+}
+
+// ApplyUpdate records a carrier tracking status and, once the shipment is delivered, fulfils the prescription
+func (s *ShipmentTrackingService) ApplyUpdate(
+	ctx context.Context,
+	correlationID string,
+	status *shipping.TrackingStatus,
+	source models.TrackingSource,
+) (*models.Shipment, TrackingUpdate, error) {
+	shipment, update, err := s.Apply(ctx, status, source)
+	if err != nil {
+		return nil, update, err
+	}
+	// Tracking continues the flow that shipped the parcel, not the webhook delivery or poll
+	if shipment.CorrelationID != "" {
+		correlationID = shipment.CorrelationID
+	}
+
+	if update.StatusChanged {
+		log.Printf("📦 [correlation_id=%s] Shipment %s is now %s (was %s, tracking %s via %s)",
+			correlationID, shipment.ID.Hex(), shipment.Status, update.PreviousStatus, shipment.TrackingNumber, source)
+		if shipment.Status == models.ShipmentException || shipment.Status == models.ShipmentReturned {
+			log.Printf("⚠️  [correlation_id=%s] Shipment %s for prescription %s needs attention: %s",
+				correlationID, shipment.ID.Hex(), shipment.PrescriptionID.Hex(), status.Details)
+		}
+	}
+
+	// The publish is recorded on the shipment, so a delivery update that failed part way repeats it
+	// when the carrier redelivers the webhook or the next tracking poll runs
+	if shipment.Status != models.ShipmentDelivered || shipment.DeliveryPublishedAt != nil {
+		return shipment, update, nil
+	}
+	// A shipment replaced after a cold chain excursion does not fulfil the prescription; its replacement does
+	if shipment.ReplacementShipmentID != nil {
+		log.Printf("ℹ️  [correlation_id=%s] Shipment %s was delivered but replaced by %s, not fulfilling", correlationID, shipment.ID.Hex(), shipment.ReplacementShipmentID.Hex())
+		return shipment, update, nil
+	}
+
+	fulfilled, err := s.MarkFulfilled(ctx, shipment)
+	if err != nil {
+		return nil, update, err
+	}
+	if !fulfilled {
+		log.Printf("ℹ️  [correlation_id=%s] Prescription %s was not awaiting delivery, status unchanged", correlationID, shipment.PrescriptionID.Hex())
+	}
+
+	if err := events.PublishShipmentDelivered(ctx, s.producer, correlationID, shipment); err != nil {
+		return nil, update, fmt.Errorf("failed to publish shipment delivered event: %w", err)
+	}
+	if err := s.MarkDeliveryPublished(ctx, shipment); err != nil {
+		// Only costs a repeat publish on the next update for this parcel
+		log.Printf("⚠️  [correlation_id=%s] %v", correlationID, err)
+	}
+
+	log.Printf("✅ [correlation_id=%s] Shipment %s delivered for prescription: %s", correlationID, shipment.ID.Hex(), shipment.PrescriptionID.Hex())
+	return shipment, update, nil
+}
+
+// Poll applies the carrier's status of up to limit shipments not tracked within staleAfter, returning the number polled
+func (s *ShipmentTrackingService) Poll(ctx context.Context, carrier shipping.Carrier, staleAfter time.Duration, limit int) (int, error) {
+	due, err := s.DueForPolling(ctx, time.Now().Add(-staleAfter), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	polled := 0
+	for _, shipment := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		// Shipments stored before correlation IDs were recorded get one for this poll
+		correlationID := shipment.CorrelationID
+		if correlationID == "" {
+			correlationID = uuid.New().String()
+		}
+
+		// A parcel the carrier cannot track right now is tried again on the next poll
+		status, err := carrier.Track(ctx, shipment.Carrier, shipment.TrackingNumber)
+		if err != nil {
+			log.Printf("⚠️  [correlation_id=%s] Failed to track shipment %s (%s): %v", correlationID, shipment.ID.Hex(), shipment.TrackingNumber, err)
+			continue
+		}
+		if _, _, err := s.ApplyUpdate(ctx, correlationID, status, models.TrackingSourcePoll); err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to apply tracking for shipment %s: %v", correlationID, shipment.ID.Hex(), err)
+			continue
+		}
+		polled++
+	}
+	return polled, nil
+}
+
+// DueForPolling returns shipments that have not been tracked since staleBefore, least recently tracked first
+// These are shipments still in transit, and delivered ones whose shipment.delivered publish did not complete.
+func (s *ShipmentTrackingService) DueForPolling(ctx context.Context, staleBefore time.Time, limit int) ([]models.Shipment, error) {
+	filter := bson.M{
+		"tracking_number": bson.M{"$ne": ""},
+		"created_at":      bson.M{"$gte": staleBefore.Add(-TrackingPollWindow)},
+		"$and": []bson.M{
+			{"$or": []bson.M{
+				{"status": bson.M{"$in": trackedStatuses}},
+				{
+					"status":                  models.ShipmentDelivered,
+					"delivery_published_at":   bson.M{"$exists": false},
+					"replacement_shipment_id": bson.M{"$exists": false},
+				},
+			}},
+			{"$or": []bson.M{
+				{"last_tracked_at": bson.M{"$exists": false}},
+				{"last_tracked_at": bson.M{"$lt": staleBefore}},
+			}},
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "last_tracked_at", Value: 1}}).SetLimit(int64(limit))
+
+	cursor, err := s.mongoClient.GetCollection("shipments").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find shipments to track: %w", err)
+	}
+	var shipments []models.Shipment
+	if err := cursor.All(ctx, &shipments); err != nil {
+		return nil, fmt.Errorf("failed to decode shipments to track: %w", err)
+	}
+	return shipments, nil
+}
+
+// MarkFulfilled moves the shipment's prescription from shipped to fulfilled
+// The change is recorded under the shipment's correlation ID. It returns false when the
+// prescription had already moved on.
+func (s *ShipmentTrackingService) MarkFulfilled(ctx context.Context, shipment *models.Shipment) (bool, error) {
+	rx, err := ChangePrescriptionStatus(ctx, s.mongoClient.GetCollection("prescriptions"), shipment.CorrelationID,
+		bson.M{"_id": shipment.PrescriptionID, "status": models.StatusShipped}, models.StatusFulfilled, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark prescription %s fulfilled: %w", shipment.PrescriptionID.Hex(), err)
+	}
+	return rx != nil, nil
+}
+
+// MarkDeliveryPublished records that shipment.delivered was published for the shipment
+func (s *ShipmentTrackingService) MarkDeliveryPublished(ctx context.Context, shipment *models.Shipment) error {
+	now := time.Now()
+	_, err := s.mongoClient.GetCollection("shipments").UpdateOne(ctx,
+		bson.M{"_id": shipment.ID},
+		bson.M{"$set": bson.M{"delivery_published_at": now}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record delivery publish for shipment %s: %w", shipment.ID.Hex(), err)
+	}
+	shipment.DeliveryPublishedAt = &now
+	return nil
+}
+
+// ShipmentStatusForTracking maps a carrier tracking state to a shipment status
+// Unknown states are not recorded
+func ShipmentStatusForTracking(state shipping.TrackingState) (models.ShipmentStatus, bool) {
+	switch state {
+	case shipping.TrackingPreTransit:
+		return models.ShipmentLabelCreated, true
+	case shipping.TrackingInTransit:
+		return models.ShipmentInTransit, true
+	case shipping.TrackingOutForDelivery:
+		return models.ShipmentOutForDelivery, true
+	case shipping.TrackingDelivered:
+		return models.ShipmentDelivered, true
+	case shipping.TrackingException:
+		return models.ShipmentException, true
+	case shipping.TrackingReturned:
+		return models.ShipmentReturned, true
+	}
+	return "", false
+}
+
+// IsFinalShipmentStatus reports whether a shipment status no longer changes with tracking
+func IsFinalShipmentStatus(status models.ShipmentStatus) bool {
+	switch status {
+	case models.ShipmentDelivered, models.ShipmentReturned, models.ShipmentVoided:
+		return true
+	}
+	return false
+}
+
+// ApplyTrackingEvent adds a tracking event to the shipment's history and moves its status
+// It returns whether the event was new and whether the status changed. Duplicate events are
+// ignored, events are kept in the order they occurred, and an event older than the latest
+// recorded one, a late pre-transit scan, or an event after a final status is kept in the history without moving the status.
+func ApplyTrackingEvent(shipment *models.Shipment, event models.TrackingEvent) (recorded, statusChanged bool) {
+	var latest time.Time
+	for _, existing := range shipment.TrackingEvents {
+		if existing.CarrierState == event.CarrierState && existing.Details == event.Details && existing.OccurredAt.Equal(event.OccurredAt) {
+			return false, false
+		}
+		if existing.OccurredAt.After(latest) {
+			latest = existing.OccurredAt
+		}
+	}
+
+	shipment.TrackingEvents = append(shipment.TrackingEvents, event)
+	sort.SliceStable(shipment.TrackingEvents, func(i, j int) bool {
+		return shipment.TrackingEvents[i].OccurredAt.Before(shipment.TrackingEvents[j].OccurredAt)
+	})
+
+	if IsFinalShipmentStatus(shipment.Status) || event.OccurredAt.Before(latest) ||
+		shipment.Status == event.Status || event.Status == models.ShipmentLabelCreated {
+		return true, false
+	}
+
+	shipment.Status = event.Status
+	if event.Status == models.ShipmentDelivered {
+		deliveredAt := event.OccurredAt
+		shipment.DeliveredAt = &deliveredAt
+	}
+	return true, true
+}
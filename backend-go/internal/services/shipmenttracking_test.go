@@ -0,0 +1,85 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/shipping"
+)
+
+// TestShipmentStatusForTracking tests mapping carrier tracking states to shipment statuses
+func TestShipmentStatusForTracking(t *testing.T) {
+	tests := []struct {
+		state  shipping.TrackingState
+		want   models.ShipmentStatus
+		wantOK bool
+	}{
+		{shipping.TrackingPreTransit, models.ShipmentLabelCreated, true},
+		{shipping.TrackingInTransit, models.ShipmentInTransit, true},
+		{shipping.TrackingOutForDelivery, models.ShipmentOutForDelivery, true},
+		{shipping.TrackingDelivered, models.ShipmentDelivered, true},
+		{shipping.TrackingException, models.ShipmentException, true},
+		{shipping.TrackingReturned, models.ShipmentReturned, true},
+		{shipping.TrackingUnknown, "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ShipmentStatusForTracking(tt.state)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: expected %q (%v), got %q (%v)", tt.state, tt.want, tt.wantOK, got, ok)
+		}
+	}
+}
+
+// TestApplyTrackingEvent tests recording tracking history and moving the shipment status
+func TestApplyTrackingEvent(t *testing.T) {
+	base := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	event := func(status models.ShipmentStatus, state shipping.TrackingState, at time.Duration) models.TrackingEvent {
+		return models.TrackingEvent{Status: status, CarrierState: string(state), OccurredAt: base.Add(at), Source: models.TrackingSourcePoll}
+	}
+	shipment := &models.Shipment{Status: models.ShipmentLabelCreated}
+
+	recorded, changed := ApplyTrackingEvent(shipment, event(models.ShipmentInTransit, shipping.TrackingInTransit, time.Hour))
+	if !recorded || !changed || shipment.Status != models.ShipmentInTransit {
+		t.Errorf("Expected the shipment to move to in_transit, got %s (%v, %v)", shipment.Status, recorded, changed)
+	}
+
+	recorded, changed = ApplyTrackingEvent(shipment, event(models.ShipmentInTransit, shipping.TrackingInTransit, time.Hour))
+	if recorded || changed || len(shipment.TrackingEvents) != 1 {
+		t.Errorf("Expected a duplicate event to be ignored, got %d events (%v, %v)", len(shipment.TrackingEvents), recorded, changed)
+	}
+
+	// A late pre-transit scan is history only
+	recorded, changed = ApplyTrackingEvent(shipment, event(models.ShipmentLabelCreated, shipping.TrackingPreTransit, 0))
+	if !recorded || changed || shipment.Status != models.ShipmentInTransit {
+		t.Errorf("Expected an older event to be kept without moving the status, got %s (%v, %v)", shipment.Status, recorded, changed)
+	}
+	if shipment.TrackingEvents[0].CarrierState != string(shipping.TrackingPreTransit) {
+		t.Errorf("Expected history in the order events occurred, got %+v", shipment.TrackingEvents)
+	}
+
+	ApplyTrackingEvent(shipment, event(models.ShipmentOutForDelivery, shipping.TrackingOutForDelivery, 20*time.Hour))
+	recorded, changed = ApplyTrackingEvent(shipment, event(models.ShipmentDelivered, shipping.TrackingDelivered, 24*time.Hour))
+	if !recorded || !changed || shipment.Status != models.ShipmentDelivered {
+		t.Errorf("Expected the shipment to be delivered, got %s", shipment.Status)
+	}
+	if shipment.DeliveredAt == nil || !shipment.DeliveredAt.Equal(base.Add(24*time.Hour)) {
+		t.Errorf("Expected delivered_at from the carrier scan, got %v", shipment.DeliveredAt)
+	}
+
+	// Delivered is final
+	recorded, changed = ApplyTrackingEvent(shipment, event(models.ShipmentException, shipping.TrackingException, 30*time.Hour))
+	if !recorded || changed || shipment.Status != models.ShipmentDelivered {
+		t.Errorf("Expected a delivered shipment to stay delivered, got %s (%v, %v)", shipment.Status, recorded, changed)
+	}
+	if len(shipment.TrackingEvents) != 5 {
+		t.Errorf("Expected 5 events in the history, got %d", len(shipment.TrackingEvents))
+	}
+
+	exception := &models.Shipment{Status: models.ShipmentException}
+	if _, changed := ApplyTrackingEvent(exception, event(models.ShipmentInTransit, shipping.TrackingInTransit, time.Hour)); !changed || exception.Status != models.ShipmentInTransit {
+		t.Errorf("Expected a shipment to recover from an exception, got %s", exception.Status)
+	}
+}
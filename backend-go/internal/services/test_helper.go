@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/phil-my-meds/backend-gogit/internal/database"
 )
 
@@ -22,3 +23,16 @@ func getTestRedisClient(t *testing.T) *database.RedisClient {
 	return client
 }
 
+// getTestMongoClient returns a MongoDB client on a database of its own for testing
+func getTestMongoClient(t *testing.T) *database.MongoClient {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		uri = "mongodb://localhost:27017"
+	}
+
+	client, err := database.ConnectMongo(uri, "phil-my-meds-test-"+uuid.New().String()[:8])
+	if err != nil {
+		t.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	return client
+}
@@ -0,0 +1,270 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrVerificationNotFound is returned when no verification has the requested ID
+	ErrVerificationNotFound = errors.New("verification not found")
+	// ErrVerificationConflict is returned when the verification was decided concurrently
+	ErrVerificationConflict = errors.New("verification decided concurrently")
+	// ErrInvalidVerificationDecision is returned when a decision is not allowed or is missing details
+	ErrInvalidVerificationDecision = errors.New("invalid verification decision")
+)
+
+// VerificationFilter narrows a verification listing
+type VerificationFilter struct {
+	Status         models.VerificationStatus
+	PrescriptionID string
+	Limit          int64
+}
+
+// VerificationDecision is a pharmacist's decision on a verification
+type VerificationDecision struct {
+	Status  models.VerificationStatus // approved, rejected or returned_to_prescriber
+	By      string
+	ByName  string
+	License string // the pharmacist's license number, recorded with the decision
+	Reason  string // required unless approved
+}
+
+// VerificationService tracks the queue of paid prescriptions waiting on a pharmacist's clinical verification
+type VerificationService struct {
+	mongoClient *database.MongoClient
+}
+
+// NewVerificationService creates a new verification service
+func NewVerificationService(mongoClient *database.MongoClient) *VerificationService {
+	return &VerificationService{
+		mongoClient: mongoClient,
+	}
+}
+
+// Open returns the prescription's pending verification, opening one if there is none
+func (s *VerificationService) Open(ctx context.Context, rx *models.Prescription, paymentID string) (*models.Verification, bool, error) {
+	collection := s.mongoClient.GetCollection("verifications")
+
+	var existing models.Verification
+	err := collection.FindOne(ctx, bson.M{"prescription_id": rx.ID, "status": models.VerificationPending}).Decode(&existing)
+	if err == nil {
+		return &existing, false, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, fmt.Errorf("failed to look up pending verification: %w", err)
+	}
+
+	verification := NewVerification(rx, paymentID, time.Now())
+	verification.CausationID = events.CausationID(ctx)
+	verification.ID = primitive.NewObjectID()
+	if err := recordVerificationChange(ctx, verification, audit.ActionCreate); err != nil {
+		return nil, false, err
+	}
+	if _, err := collection.InsertOne(ctx, verification); err != nil {
+		return nil, false, fmt.Errorf("failed to create verification: %w", err)
+	}
+	return verification, true, nil
+}
+
+// Get returns a verification by ID
+func (s *VerificationService) Get(ctx context.Context, id string) (*models.Verification, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrVerificationNotFound
+	}
+
+	var verification models.Verification
+	err = s.mongoClient.GetCollection("verifications").FindOne(ctx, bson.M{"_id": oid}).Decode(&verification)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrVerificationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load verification %s: %w", id, err)
+	}
+	return &verification, nil
+}
+
+// Detail returns a verification with what the pharmacist checks: the prescription's sig, its validation
+// flags and DUR findings, and the payload it was received as
+func (s *VerificationService) Detail(ctx context.Context, id string) (*models.VerificationDetail, error) {
+	verification, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var rx models.Prescription
+	opts := options.FindOne().SetProjection(bson.M{"medication": 1, "validation_flags": 1, "dur_check": 1, "original_payload": 1})
+	err = s.mongoClient.GetCollection("prescriptions").FindOne(ctx, bson.M{"_id": verification.PrescriptionID}, opts).Decode(&rx)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to load prescription %s: %w", verification.PrescriptionID.Hex(), err)
+	}
+
+	detail := &models.VerificationDetail{
+		Verification:    *verification,
+		Medication:      rx.Medication,
+		ValidationFlags: rx.ValidationFlags,
+		DURCheck:        rx.DURCheck,
+		OriginalPayload: rx.OriginalPayload,
+	}
+	if rx.DURCheck == nil || rx.DURCheck.ReviewID == "" {
+		return detail, nil
+	}
+
+	reviewID, err := primitive.ObjectIDFromHex(rx.DURCheck.ReviewID)
+	if err != nil {
+		return detail, nil
+	}
+	var review models.DURReview
+	err = s.mongoClient.GetCollection("dur_reviews").FindOne(ctx, bson.M{"_id": reviewID}).Decode(&review)
+	if err == nil {
+		detail.DURReview = &review
+	} else if err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to load DUR review %s: %w", rx.DURCheck.ReviewID, err)
+	}
+	return detail, nil
+}
+
+// List returns verifications matching the filter, oldest first
+func (s *VerificationService) List(ctx context.Context, filter VerificationFilter) ([]models.Verification, error) {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.PrescriptionID != "" {
+		oid, err := primitive.ObjectIDFromHex(filter.PrescriptionID)
+		if err != nil {
+			return []models.Verification{}, nil
+		}
+		query["prescription_id"] = oid
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(filter.Limit)
+	cursor, err := s.mongoClient.GetCollection("verifications").Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list verifications: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	verifications := []models.Verification{}
+	if err := cursor.All(ctx, &verifications); err != nil {
+		return nil, fmt.Errorf("failed to decode verifications: %w", err)
+	}
+	return verifications, nil
+}
+
+// Decide records a pharmacist's decision on a pending verification
+// The same decision posted again by the same pharmacist returns the verification as decided, so its
+// follow-up can be retried. Returns ErrVerificationConflict if the verification was decided since it was loaded.
+func (s *VerificationService) Decide(ctx context.Context, id string, decision VerificationDecision) (*models.Verification, error) {
+	verification, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if VerificationDecisionRepeats(verification, decision) {
+		return verification, nil
+	}
+	if err := ApplyVerificationDecision(verification, decision, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := recordVerificationChange(ctx, verification, audit.ActionUpdate); err != nil {
+		return nil, err
+	}
+	result, err := s.mongoClient.GetCollection("verifications").ReplaceOne(ctx, bson.M{"_id": verification.ID, "status": models.VerificationPending}, verification)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update verification %s: %w", id, err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrVerificationConflict
+	}
+	return verification, nil
+}
+
+// recordVerificationChange audits a verification being opened or decided
+// The entry is required, so it is written before the change and an error means the change is not made.
+func recordVerificationChange(ctx context.Context, verification *models.Verification, action audit.Action) error {
+	details := map[string]interface{}{
+		"prescription_id": verification.PrescriptionID.Hex(),
+		"patient_id":      verification.PatientID,
+		"status":          verification.Status,
+		"correlation_id":  verification.CorrelationID,
+	}
+	if verification.DecidedBy != "" {
+		details["decided_by"] = verification.DecidedBy
+		details["license_number"] = verification.LicenseNumber
+	}
+	err := audit.Record(ctx, audit.Entry{
+		EventType:  audit.EventVerificationChanged,
+		EntityType: audit.EntityVerification,
+		EntityID:   verification.ID.Hex(),
+		Action:     action,
+		Details:    details,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to audit verification %s: %w", verification.ID.Hex(), err)
+	}
+	return nil
+}
+
+// NewVerification builds the pending verification of a paid prescription
+func NewVerification(rx *models.Prescription, paymentID string, at time.Time) *models.Verification {
+	return &models.Verification{
+		PrescriptionID: rx.ID,
+		PatientID:      durPatientID(rx),
+		PaymentID:      paymentID,
+		NDC:            rx.Medication.NDC,
+		DrugName:       rx.Medication.Name,
+		Status:         models.VerificationPending,
+		CorrelationID:  rx.CorrelationID,
+		CreatedAt:      at,
+		UpdatedAt:      at,
+	}
+}
+
+// ApplyVerificationDecision validates a pharmacist's decision and applies it to a pending verification
+// Only a pharmacist with a license number may decide; rejecting or returning to the prescriber needs a reason.
+func ApplyVerificationDecision(verification *models.Verification, decision VerificationDecision, at time.Time) error {
+	if verification.Status != models.VerificationPending {
+		return fmt.Errorf("%w: verification is already %s", ErrInvalidVerificationDecision, verification.Status)
+	}
+	license := strings.TrimSpace(decision.License)
+	if license == "" {
+		return fmt.Errorf("%w: a pharmacist license number is required", ErrInvalidVerificationDecision)
+	}
+	reason := strings.TrimSpace(decision.Reason)
+	switch decision.Status {
+	case models.VerificationApproved:
+	case models.VerificationRejected, models.VerificationReturnedToPrescriber:
+		if reason == "" {
+			return fmt.Errorf("%w: reason is required unless approved", ErrInvalidVerificationDecision)
+		}
+	default:
+		return fmt.Errorf("%w: decision must be approved, rejected or returned_to_prescriber", ErrInvalidVerificationDecision)
+	}
+
+	verification.Status = decision.Status
+	verification.DecidedBy = decision.By
+	verification.DecidedByName = decision.ByName
+	verification.LicenseNumber = license
+	verification.DecidedAt = &at
+	verification.Reason = reason
+	verification.UpdatedAt = at
+	return nil
+}
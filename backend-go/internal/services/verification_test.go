@@ -0,0 +1,61 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestNewVerification tests that a paid prescription's verification starts pending with its payment and drug
+func TestNewVerification(t *testing.T) {
+	now := time.Now()
+	rx := &models.Prescription{
+		ID:            primitive.NewObjectID(),
+		Patient:       models.PatientInfo{ID: "P-100"},
+		Medication:    models.MedicationInfo{NDC: "00093-7153-98", Name: "Simvastatin"},
+		CorrelationID: "corr_1",
+	}
+
+	verification := NewVerification(rx, "pay_1", now)
+	if verification.Status != models.VerificationPending || verification.PrescriptionID != rx.ID || verification.PaymentID != "pay_1" {
+		t.Errorf("Unexpected verification: %+v", verification)
+	}
+	if verification.PatientID != "P-100" || verification.NDC != "00093-7153-98" || verification.CorrelationID != "corr_1" {
+		t.Errorf("Expected the prescription's patient, drug and correlation ID, got %+v", verification)
+	}
+}
+
+// TestApplyVerificationDecision tests that decisions need a pending verification, a license and a reason unless approved
+func TestApplyVerificationDecision(t *testing.T) {
+	now := time.Now()
+
+	verification := &models.Verification{Status: models.VerificationPending}
+	if err := ApplyVerificationDecision(verification, VerificationDecision{Status: models.VerificationApproved, By: "pharm_1"}, now); !errors.Is(err, ErrInvalidVerificationDecision) {
+		t.Errorf("Expected a decision without a license to be invalid, got %v", err)
+	}
+	if err := ApplyVerificationDecision(verification, VerificationDecision{Status: models.VerificationReturnedToPrescriber, By: "pharm_1", License: "RPH-1"}, now); !errors.Is(err, ErrInvalidVerificationDecision) {
+		t.Errorf("Expected returning to the prescriber without a reason to be invalid, got %v", err)
+	}
+	if err := ApplyVerificationDecision(verification, VerificationDecision{Status: models.VerificationPending, By: "pharm_1", License: "RPH-1"}, now); !errors.Is(err, ErrInvalidVerificationDecision) {
+		t.Errorf("Expected a pending decision to be invalid, got %v", err)
+	}
+
+	decision := VerificationDecision{Status: models.VerificationRejected, By: "pharm_1", ByName: "Dana Lee", License: " RPH-1 ", Reason: " wrong strength "}
+	if err := ApplyVerificationDecision(verification, decision, now); err != nil {
+		t.Fatalf("Expected rejection, got %v", err)
+	}
+	if verification.Status != models.VerificationRejected || verification.DecidedBy != "pharm_1" || verification.DecidedByName != "Dana Lee" {
+		t.Errorf("Unexpected decided verification: %+v", verification)
+	}
+	if verification.LicenseNumber != "RPH-1" || verification.Reason != "wrong strength" || verification.DecidedAt == nil {
+		t.Errorf("Expected the license and reason recorded, got %+v", verification)
+	}
+
+	if err := ApplyVerificationDecision(verification, VerificationDecision{Status: models.VerificationApproved, By: "pharm_2", License: "RPH-2"}, now); !errors.Is(err, ErrInvalidVerificationDecision) {
+		t.Errorf("Expected deciding a decided verification to be invalid, got %v", err)
+	}
+}
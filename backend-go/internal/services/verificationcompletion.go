@@ -0,0 +1,174 @@
+// Package services provides business logic services
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CompleteVerification moves the prescription held for a decided verification on, returning false if it no longer waits on it
+func CompleteVerification(
+	ctx context.Context,
+	mongoClient *database.MongoClient,
+	producer kafka.Producer,
+	correlationID string,
+	verification *models.Verification,
+) (bool, error) {
+	if verification.CompletedAt != nil {
+		return false, nil
+	}
+
+	collection := mongoClient.GetCollection("prescriptions")
+	var rx models.Prescription
+	err := collection.FindOne(ctx, bson.M{"_id": verification.PrescriptionID}).Decode(&rx)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load prescription: %w", err)
+	}
+
+	status, exception := verificationOutcome(verification)
+	prescriptionID := verification.PrescriptionID.Hex()
+	switch {
+	case rx.Status == models.StatusPendingVerification:
+		var fields bson.M
+		if exception != nil {
+			fields = bson.M{"exception": exception}
+		}
+		filter := bson.M{"_id": verification.PrescriptionID, "status": models.StatusPendingVerification}
+		updated, err := ChangePrescriptionStatus(ctx, collection, correlationID, filter, status, fields)
+		if err != nil {
+			return false, err
+		}
+		if updated == nil {
+			return false, nil
+		}
+	// A decision whose follow-up failed part way has its event published again
+	case VerificationResumable(&rx, verification):
+		log.Printf("🔁 [correlation_id=%s] Prescription %s already moved by verification %s, publishing its event again", correlationID, prescriptionID, verification.ID.Hex())
+		if rx.Exception != nil {
+			exception = rx.Exception
+		}
+	default:
+		markVerificationCompleted(ctx, mongoClient, correlationID, verification)
+		return false, nil
+	}
+
+	if exception != nil {
+		if err := events.PublishException(ctx, producer, correlationID, prescriptionID, *exception); err != nil {
+			return false, fmt.Errorf("failed to publish exception event: %w", err)
+		}
+		markVerificationCompleted(ctx, mongoClient, correlationID, verification)
+		log.Printf("⛔ [correlation_id=%s] Verification %s %s prescription %s", correlationID, verification.ID.Hex(), verification.Status, prescriptionID)
+		return true, nil
+	}
+
+	err = events.Publish(ctx, producer, &events.VerificationCompleted{
+		Envelope:       events.NewEnvelope(ctx, correlationID, prescriptionID),
+		VerificationID: verification.ID.Hex(),
+		PatientID:      verification.PatientID,
+		PaymentID:      verification.PaymentID,
+		VerifiedBy:     verification.DecidedBy,
+		LicenseNumber:  verification.LicenseNumber,
+		VerifiedAt:     *verification.DecidedAt,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to publish verification completed event: %w", err)
+	}
+	markVerificationCompleted(ctx, mongoClient, correlationID, verification)
+	log.Printf("✅ [correlation_id=%s] Verification %s approved prescription %s", correlationID, verification.ID.Hex(), prescriptionID)
+	return true, nil
+}
+
+// ResumeDecidedVerifications completes verifications decided before a cutoff that were never marked completed
+func ResumeDecidedVerifications(ctx context.Context, mongoClient *database.MongoClient, producer kafka.Producer, decidedBefore time.Time, limit int) (int, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "decided_at", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := mongoClient.GetCollection("verifications").Find(ctx, bson.M{
+		"status":       bson.M{"$ne": models.VerificationPending},
+		"decided_at":   bson.M{"$lt": decidedBefore},
+		"completed_at": bson.M{"$exists": false},
+	}, opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find uncompleted verifications: %w", err)
+	}
+	var verifications []models.Verification
+	if err := cursor.All(ctx, &verifications); err != nil {
+		return 0, fmt.Errorf("failed to decode uncompleted verifications: %w", err)
+	}
+
+	resumed := 0
+	for i := range verifications {
+		verification := &verifications[i]
+		moved, err := CompleteVerification(ctx, mongoClient, producer, verification.CorrelationID, verification)
+		if err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to resume verification %s: %v", verification.CorrelationID, verification.ID.Hex(), err)
+			continue
+		}
+		if moved {
+			resumed++
+		}
+	}
+	return resumed, nil
+}
+
+// VerificationResumable reports whether a prescription was moved by a decided verification and has not moved on since
+func VerificationResumable(rx *models.Prescription, verification *models.Verification) bool {
+	if verification.Status == models.VerificationPending {
+		return false
+	}
+	status, exception := verificationOutcome(verification)
+	if exception == nil {
+		return rx.Status == status
+	}
+	return rx.Status == status && rx.Exception != nil && rx.Exception.Type == exception.Type
+}
+
+// VerificationDecisionRepeats reports whether a decision repeats the one already recorded on a verification
+// The same pharmacist posting the same decision again is retrying a decision whose follow-up failed.
+func VerificationDecisionRepeats(verification *models.Verification, decision VerificationDecision) bool {
+	return verification.Status != models.VerificationPending &&
+		verification.Status == decision.Status &&
+		verification.DecidedBy == decision.By
+}
+
+// verificationOutcome returns the status a decided verification moves its prescription to, with the
+// exception a rejection or return to the prescriber raises
+func verificationOutcome(verification *models.Verification) (models.PrescriptionStatus, *models.PrescriptionException) {
+	if verification.Status == models.VerificationApproved {
+		return models.StatusVerified, nil
+	}
+	exceptionType := models.ExceptionVerificationRejected
+	if verification.Status == models.VerificationReturnedToPrescriber {
+		exceptionType = models.ExceptionReturnedToPrescriber
+	}
+	return models.StatusException, &models.PrescriptionException{
+		Type:     exceptionType,
+		Reason:   verification.Reason,
+		Source:   "verification",
+		RaisedAt: time.Now(),
+	}
+}
+
+// markVerificationCompleted records that nothing is left to do for a decided verification
+// The event is already out, so a failure only means the scheduler looks at the verification again.
+func markVerificationCompleted(ctx context.Context, mongoClient *database.MongoClient, correlationID string, verification *models.Verification) {
+	now := time.Now()
+	_, err := mongoClient.GetCollection("verifications").UpdateOne(ctx, bson.M{"_id": verification.ID},
+		bson.M{"$set": bson.M{"completed_at": now}})
+	if err != nil {
+		log.Printf("⚠️  [correlation_id=%s] Failed to mark verification %s completed: %v", correlationID, verification.ID.Hex(), err)
+		return
+	}
+	verification.CompletedAt = &now
+}
@@ -0,0 +1,55 @@
+// Package services provides service layer tests
+package services
+
+import (
+	"testing"
+
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+)
+
+// TestVerificationDecisionRepeats tests which decisions posted again retry the follow-up instead of failing
+func TestVerificationDecisionRepeats(t *testing.T) {
+	decided := &models.Verification{Status: models.VerificationApproved, DecidedBy: "pharm_1"}
+
+	if !VerificationDecisionRepeats(decided, VerificationDecision{Status: models.VerificationApproved, By: "pharm_1"}) {
+		t.Error("Expected the same decision by the same pharmacist to repeat")
+	}
+	if VerificationDecisionRepeats(decided, VerificationDecision{Status: models.VerificationRejected, By: "pharm_1", Reason: "r"}) {
+		t.Error("Expected a different decision not to repeat")
+	}
+	if VerificationDecisionRepeats(decided, VerificationDecision{Status: models.VerificationApproved, By: "pharm_2"}) {
+		t.Error("Expected a decision by another pharmacist not to repeat")
+	}
+	if VerificationDecisionRepeats(&models.Verification{Status: models.VerificationPending}, VerificationDecision{Status: models.VerificationPending}) {
+		t.Error("Expected a pending verification not to repeat")
+	}
+}
+
+// TestVerificationResumable tests which prescriptions have a decided verification's event published again
+func TestVerificationResumable(t *testing.T) {
+	approved := &models.Verification{Status: models.VerificationApproved}
+	rejected := &models.Verification{Status: models.VerificationRejected}
+	returned := &models.Verification{Status: models.VerificationReturnedToPrescriber}
+	rejectedException := &models.Prescription{Status: models.StatusException, Exception: &models.PrescriptionException{Type: models.ExceptionVerificationRejected}}
+	returnedException := &models.Prescription{Status: models.StatusException, Exception: &models.PrescriptionException{Type: models.ExceptionReturnedToPrescriber}}
+
+	tests := []struct {
+		name         string
+		rx           *models.Prescription
+		verification *models.Verification
+		want         bool
+	}{
+		{"approved and verified", &models.Prescription{Status: models.StatusVerified}, approved, true},
+		{"approved and shipped since", &models.Prescription{Status: models.StatusShipped}, approved, false},
+		{"rejected to exception", rejectedException, rejected, true},
+		{"returned to exception", returnedException, returned, true},
+		{"returned but rejection exception", rejectedException, returned, false},
+		{"pending verification", &models.Prescription{Status: models.StatusPendingVerification}, &models.Verification{Status: models.VerificationPending}, false},
+	}
+
+	for _, tt := range tests {
+		if got := VerificationResumable(tt.rx, tt.verification); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Package shipping provides shipping carrier integrations for prescription delivery
+package shipping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Carrier integration names
+const (
+	CarrierShippo = "shippo"
+	CarrierFake   = "fake"
+)
+
+// TrackingState is the carrier-neutral status of a tracked parcel
+type TrackingState string
+
+const (
+	TrackingPreTransit     TrackingState = "pre_transit" // label created, not yet scanned by the carrier
+	TrackingInTransit      TrackingState = "in_transit"
+	TrackingOutForDelivery TrackingState = "out_for_delivery"
+	TrackingDelivered      TrackingState = "delivered"
+	TrackingReturned       TrackingState = "returned"  // returned to sender
+	TrackingException      TrackingState = "exception" // delayed, damaged, lost or a failed delivery attempt
+	TrackingUnknown        TrackingState = "unknown"
+)
+
+var (
+	// ErrLabelNotFound is returned when the carrier has no label with the given ID
+	ErrLabelNotFound = errors.New("shipping label not found")
+	// ErrNoEligibleRate is returned when no rate meets the delivery deadline and parcel constraints
+	ErrNoEligibleRate = errors.New("no shipping rate meets the delivery deadline and parcel constraints")
+)
+
+// Address is a shipping address
+type Address struct {
+	Name    string
+	Company string
+	Street1 string
+	Street2 string
+	City    string
+	State   string
+	Zip     string
+	Country string // ISO country code (default "US")
+	Phone   string
+	Email   string
+}
+
+// AddressValidation is the carrier's verdict on an address
+type AddressValidation struct {
+	Valid     bool
+	Messages  []string
+	Suggested *Address // corrected address, when the carrier offers one
+}
+
+// Parcel is a package's dimensions in inches and weight in ounces
+type Parcel struct {
+	LengthIn float64
+	WidthIn  float64
+	HeightIn float64
+	WeightOz float64
+}
+
+// LengthPlusGirth is the longest side plus twice the sum of the other two, as carriers measure it
+func (p Parcel) LengthPlusGirth() float64 {
+	sides := []float64{p.LengthIn, p.WidthIn, p.HeightIn}
+	sort.Float64s(sides)
+	return sides[2] + 2*(sides[0]+sides[1])
+}
+
+// DefaultParcel is the standard box a single prescription ships in
+var DefaultParcel = Parcel{LengthIn: 8, WidthIn: 6, HeightIn: 4, WeightOz: 16}
+
+// RateRequest asks the carrier for rates to ship a parcel
+type RateRequest struct {
+	From     Address
+	To       Address
+	Parcel   Parcel
+	ShipDate time.Time // when the parcel will be handed over; zero for today
+}
+
+// Rate is a priced service level offered for a shipment
+type Rate struct {
+	ID            string
+	Carrier       string // e.g. USPS, UPS, FedEx
+	ServiceLevel  string // carrier service token, e.g. usps_priority
+	ServiceName   string
+	Amount        float64
+	Currency      string
+	EstimatedDays int // 0 if the carrier gives no estimate
+}
+
+// LabelRequest buys a label for a previously quoted rate
+type LabelRequest struct {
+	RateID         string
+	IdempotencyKey string
+}
+
+// Label is a purchased shipping label
+type Label struct {
+	ID             string // carrier label (transaction) object reference
+	RateID         string
+	TrackingNumber string
+	TrackingURL    string
+	LabelURL       string
+}
+
+// TrackingStatus is the carrier's current view of a parcel
+type TrackingStatus struct {
+	Carrier        string
+	TrackingNumber string
+	State          TrackingState
+	Details        string
+	Location       string
+	UpdatedAt      time.Time
+	ETA            *time.Time
+}
+
+// Carrier validates addresses, quotes rates, buys and voids labels and tracks parcels
+type Carrier interface {
+	// Name returns the integration name stored on shipments
+	Name() string
+	// ValidateAddress checks a delivery address is deliverable
+	ValidateAddress(ctx context.Context, address Address) (*AddressValidation, error)
+	// GetRates quotes the service levels available for a parcel
+	GetRates(ctx context.Context, req RateRequest) ([]Rate, error)
+	// BuyLabel purchases a label for a quoted rate
+	BuyLabel(ctx context.Context, req LabelRequest) (*Label, error)
+	// VoidLabel cancels an unused label so it is not billed
+	VoidLabel(ctx context.Context, labelID string) error
+	// Track returns the tracking status of a parcel
+	Track(ctx context.Context, carrier, trackingNumber string) (*TrackingStatus, error)
+}
+
+// NewCarrier returns the configured shipping carrier
+// The Shippo carrier targets apiBase, which may point at a local stub server
+func NewCarrier(name, apiBase, token string) (Carrier, error) {
+	switch name {
+	case CarrierShippo:
+		return NewShippoCarrier(apiBase, token), nil
+	case CarrierFake:
+		return NewFakeCarrier(), nil
+	}
+	return nil, fmt.Errorf("unknown shipping carrier %q", name)
+}
+
+// ParcelLimits are the largest parcel a service level accepts
+type ParcelLimits struct {
+	MaxWeightOz        float64
+	MaxLengthPlusGirth float64
+}
+
+// defaultParcelLimits apply to service levels without their own entry
+var defaultParcelLimits = ParcelLimits{MaxWeightOz: 70 * 16, MaxLengthPlusGirth: 108}
+
+// ServiceLimits are the parcel limits of known service levels; others get the default
+var ServiceLimits = map[string]ParcelLimits{
+	"usps_first":               {MaxWeightOz: 15.99, MaxLengthPlusGirth: 108},
+	"usps_ground_advantage":    {MaxWeightOz: 70 * 16, MaxLengthPlusGirth: 130},
+	"ups_ground":               {MaxWeightOz: 150 * 16, MaxLengthPlusGirth: 165},
+	"ups_next_day_air":         {MaxWeightOz: 150 * 16, MaxLengthPlusGirth: 165},
+	"fedex_ground":             {MaxWeightOz: 150 * 16, MaxLengthPlusGirth: 165},
+	"fedex_priority_overnight": {MaxWeightOz: 150 * 16, MaxLengthPlusGirth: 165},
+}
+
+// Fits reports whether the parcel is within the service level's limits
+func (p Parcel) Fits(serviceLevel string) bool {
+	limits, ok := ServiceLimits[serviceLevel]
+	if !ok {
+		limits = defaultParcelLimits
+	}
+	return p.WeightOz <= limits.MaxWeightOz && p.LengthPlusGirth() <= limits.MaxLengthPlusGirth
+}
+
+// SelectRate returns the cheapest rate that arrives by the deadline and accepts the parcel
+// Rates without a transit estimate are only eligible when there is no deadline. Ties go to the faster service.
+func SelectRate(rates []Rate, parcel Parcel, deadline, now time.Time) (*Rate, error) {
+	var best *Rate
+	for i := range rates {
+		rate := &rates[i]
+		if !parcel.Fits(rate.ServiceLevel) {
+			continue
+		}
+		if !deadline.IsZero() {
+			if rate.EstimatedDays <= 0 || now.AddDate(0, 0, rate.EstimatedDays).After(deadline) {
+				continue
+			}
+		}
+		if best == nil || rate.Amount < best.Amount ||
+			(rate.Amount == best.Amount && rate.EstimatedDays < best.EstimatedDays) {
+			best = rate
+		}
+	}
+	if best == nil {
+		return nil, ErrNoEligibleRate
+	}
+	selected := *best
+	return &selected, nil
+}
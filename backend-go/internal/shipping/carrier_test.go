@@ -0,0 +1,70 @@
+// Package shipping provides shipping carrier tests
+package shipping
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParcel_Fits tests parcel limits per service level
+func TestParcel_Fits(t *testing.T) {
+	tests := []struct {
+		name    string
+		parcel  Parcel
+		service string
+		want    bool
+	}{
+		{"default box by first class", Parcel{8, 6, 4, 15}, "usps_first", true},
+		{"too heavy for first class", DefaultParcel, "usps_first", false},
+		{"default box by priority", DefaultParcel, "usps_priority", true},
+		{"oversized for unknown service", Parcel{40, 20, 20, 100}, "usps_priority", false},
+		{"oversized fits ups ground", Parcel{40, 20, 20, 100}, "ups_ground", true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.parcel.Fits(tt.service); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestSelectRate tests picking the cheapest rate that meets the deadline and parcel constraints
+func TestSelectRate(t *testing.T) {
+	now := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	rates := []Rate{
+		{ID: "first", ServiceLevel: "usps_first", Amount: 4.50, EstimatedDays: 3},
+		{ID: "ground", ServiceLevel: "usps_ground_advantage", Amount: 6.10, EstimatedDays: 5},
+		{ID: "priority", ServiceLevel: "usps_priority", Amount: 9.85, EstimatedDays: 2},
+		{ID: "ups", ServiceLevel: "ups_ground", Amount: 9.85, EstimatedDays: 4},
+		{ID: "overnight", ServiceLevel: "fedex_priority_overnight", Amount: 38.40, EstimatedDays: 1},
+		{ID: "no_estimate", ServiceLevel: "usps_media", Amount: 3.00},
+	}
+
+	tests := []struct {
+		name     string
+		parcel   Parcel
+		deadline time.Time
+		want     string
+	}{
+		{"light parcel within 3 days", Parcel{8, 6, 4, 10}, now.AddDate(0, 0, 3), "first"},
+		{"heavy parcel within 3 days", DefaultParcel, now.AddDate(0, 0, 3), "priority"},
+		{"tie goes to the faster service", DefaultParcel, now.AddDate(0, 0, 4), "priority"},
+		{"next day", DefaultParcel, now.AddDate(0, 0, 1), "overnight"},
+		{"no deadline takes unestimated rates", DefaultParcel, time.Time{}, "no_estimate"},
+	}
+
+	for _, tt := range tests {
+		rate, err := SelectRate(rates, tt.parcel, tt.deadline, now)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if rate.ID != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, rate.ID)
+		}
+	}
+
+	if _, err := SelectRate(rates, DefaultParcel, now.Add(12*time.Hour), now); err != ErrNoEligibleRate {
+		t.Errorf("Expected ErrNoEligibleRate for a same-day deadline, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+// Package shipping provides shipping carrier integrations for prescription delivery
+package shipping
+
+import (
+	"fmt"
+	"time"
+)
+
+// Packaging used for refrigerated parcels
+const (
+	PackagingStandard          = "standard"
+	PackagingInsulatedGelPacks = "insulated_shipper_gel_packs" // validated to hold 2-8°C for 48 hours
+)
+
+// DefaultColdChainCutoff is the local time after which refrigerated parcels wait for the next ship day
+const DefaultColdChainCutoff = "14:00"
+
+// ColdChainParcel is the insulated shipper with gel packs a refrigerated prescription ships in
+var ColdChainParcel = Parcel{LengthIn: 12, WidthIn: 10, HeightIn: 10, WeightOz: 72}
+
+// ColdChain is the handling a refrigerated parcel needs
+type ColdChain struct {
+	MinTempC       float64
+	MaxTempC       float64
+	Packaging      string
+	Parcel         Parcel
+	MaxTransitDays int // refrigerated parcels go overnight only
+}
+
+// NewColdChain returns the packaging and service constraints for a drug stored between minC and maxC
+func NewColdChain(minC, maxC float64) ColdChain {
+	return ColdChain{
+		MinTempC:       minC,
+		MaxTempC:       maxC,
+		Packaging:      PackagingInsulatedGelPacks,
+		Parcel:         ColdChainParcel,
+		MaxTransitDays: 1,
+	}
+}
+
+// InRange reports whether a temperature is within the storage range
+func (c ColdChain) InRange(tempC float64) bool {
+	return tempC >= c.MinTempC && tempC <= c.MaxTempC
+}
+
+// ColdChainShipDate returns when a refrigerated parcel can leave the pharmacy
+// It ships today if now is before the cut-off ("15:04" in loc), otherwise on the next day, and
+// only Monday to Thursday so that overnight delivery never lands on a weekend. A ship date
+// after today is returned as the start of that day in loc.
+func ColdChainShipDate(now time.Time, cutoff string, loc *time.Location) (time.Time, error) {
+	if cutoff == "" {
+		cutoff = DefaultColdChainCutoff
+	}
+	at, err := time.Parse("15:04", cutoff)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cold chain cut-off %q: %w", cutoff, err)
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+
+	local := now.In(loc)
+	cutoffToday := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, loc)
+
+	shipDate := local
+	if !local.Before(cutoffToday) {
+		shipDate = time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+	}
+	for !coldChainShipDay(shipDate.Weekday()) {
+		shipDate = time.Date(shipDate.Year(), shipDate.Month(), shipDate.Day()+1, 0, 0, 0, 0, loc)
+	}
+	return shipDate, nil
+}
+
+// coldChainShipDay reports whether an overnight parcel shipped that day arrives on a weekday
+func coldChainShipDay(day time.Weekday) bool {
+	return day >= time.Monday && day <= time.Thursday
+}
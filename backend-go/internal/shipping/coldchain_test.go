@@ -0,0 +1,63 @@
+// Package shipping provides shipping carrier tests
+package shipping
+
+import (
+	"testing"
+	"time"
+)
+
+// TestColdChainShipDate tests the cut-off and the no-weekend-arrival rule
+func TestColdChainShipDate(t *testing.T) {
+	loc, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"monday morning ships today", time.Date(2024, 3, 4, 9, 30, 0, 0, loc), time.Date(2024, 3, 4, 9, 30, 0, 0, loc)},
+		{"monday after cut-off ships tuesday", time.Date(2024, 3, 4, 14, 0, 0, 0, loc), time.Date(2024, 3, 5, 0, 0, 0, 0, loc)},
+		{"thursday after cut-off waits for monday", time.Date(2024, 3, 7, 16, 0, 0, 0, loc), time.Date(2024, 3, 11, 0, 0, 0, 0, loc)},
+		{"friday morning waits for monday", time.Date(2024, 3, 8, 8, 0, 0, 0, loc), time.Date(2024, 3, 11, 0, 0, 0, 0, loc)},
+		{"sunday waits for monday", time.Date(2024, 3, 10, 8, 0, 0, 0, loc), time.Date(2024, 3, 11, 0, 0, 0, 0, loc)},
+		{"cut-off is in pharmacy time", time.Date(2024, 3, 4, 20, 30, 0, 0, time.UTC), time.Date(2024, 3, 5, 0, 0, 0, 0, loc)},
+	}
+
+	for _, tt := range tests {
+		got, err := ColdChainShipDate(tt.now, "14:00", loc)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+
+	if _, err := ColdChainShipDate(time.Now(), "2pm", loc); err == nil {
+		t.Error("Expected an invalid cut-off to return an error")
+	}
+}
+
+// TestColdChain_RateSelection tests that refrigerated parcels only go overnight in insulated packaging
+func TestColdChain_RateSelection(t *testing.T) {
+	cc := NewColdChain(2, 8)
+	if cc.Packaging != PackagingInsulatedGelPacks || cc.MaxTransitDays != 1 {
+		t.Fatalf("Unexpected cold chain handling: %+v", cc)
+	}
+	if !cc.InRange(2) || !cc.InRange(8) || cc.InRange(9.5) || cc.InRange(-1) {
+		t.Error("Expected 2-8°C inclusive to be the storage range")
+	}
+
+	shipDate := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	rate, err := SelectRate(fakeServices, cc.Parcel, shipDate.AddDate(0, 0, cc.MaxTransitDays), shipDate)
+	if err != nil {
+		t.Fatalf("Expected an overnight rate, got %v", err)
+	}
+	if rate.ServiceLevel != "fedex_priority_overnight" {
+		t.Errorf("Expected fedex_priority_overnight, got %s", rate.ServiceLevel)
+	}
+}
@@ -0,0 +1,179 @@
+// Package shipping provides shipping carrier integrations for prescription delivery
+package shipping
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeLabelBase is where fake labels point
+const fakeLabelBase = "https://labels.phil-my-meds.test/"
+
+// zipPattern matches 5-digit and ZIP+4 codes
+var zipPattern = regexp.MustCompile(`^\d{5}(-\d{4})?$`)
+
+// fakeServices are the service levels the fake carrier quotes
+var fakeServices = []Rate{
+	{Carrier: "USPS", ServiceLevel: "usps_first", ServiceName: "First-Class Package", Amount: 4.50, Currency: "usd", EstimatedDays: 3},
+	{Carrier: "USPS", ServiceLevel: "usps_ground_advantage", ServiceName: "Ground Advantage", Amount: 6.10, Currency: "usd", EstimatedDays: 5},
+	{Carrier: "USPS", ServiceLevel: "usps_priority", ServiceName: "Priority Mail", Amount: 9.85, Currency: "usd", EstimatedDays: 2},
+	{Carrier: "UPS", ServiceLevel: "ups_ground", ServiceName: "Ground", Amount: 11.20, Currency: "usd", EstimatedDays: 4},
+	{Carrier: "FedEx", ServiceLevel: "fedex_priority_overnight", ServiceName: "Priority Overnight", Amount: 38.40, Currency: "usd", EstimatedDays: 1},
+}
+
+// FakeCarrier is an in-memory Carrier for tests and local runs without a carrier stub
+// It quotes a fixed rate card; tests move parcels along with SetTracking
+type FakeCarrier struct {
+	mu       sync.Mutex
+	rates    map[string]Rate
+	labels   map[string]*fakeLabel
+	tracking map[string]*TrackingStatus // tracking number -> status
+	purchase map[string]string          // idempotency key -> label ID
+}
+
+// fakeLabel is a label held by the fake carrier
+type fakeLabel struct {
+	label   Label
+	carrier string
+	voided  bool
+}
+
+// NewFakeCarrier creates an in-memory shipping carrier
+func NewFakeCarrier() *FakeCarrier {
+	return &FakeCarrier{
+		rates:    make(map[string]Rate),
+		labels:   make(map[string]*fakeLabel),
+		tracking: make(map[string]*TrackingStatus),
+		purchase: make(map[string]string),
+	}
+}
+
+// Name returns the integration name
+func (c *FakeCarrier) Name() string {
+	return CarrierFake
+}
+
+// ValidateAddress accepts complete US addresses with a well-formed ZIP code
+func (c *FakeCarrier) ValidateAddress(ctx context.Context, address Address) (*AddressValidation, error) {
+	var messages []string
+	if strings.TrimSpace(address.Street1) == "" {
+		messages = append(messages, "street is required")
+	}
+	if strings.TrimSpace(address.City) == "" {
+		messages = append(messages, "city is required")
+	}
+	if len(strings.TrimSpace(address.State)) != 2 {
+		messages = append(messages, "state must be a 2-letter code")
+	}
+	if !zipPattern.MatchString(strings.TrimSpace(address.Zip)) {
+		messages = append(messages, "zip code is not valid")
+	}
+	return &AddressValidation{Valid: len(messages) == 0, Messages: messages}, nil
+}
+
+// GetRates quotes the fixed rate card
+func (c *FakeCarrier) GetRates(ctx context.Context, req RateRequest) ([]Rate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rates := make([]Rate, 0, len(fakeServices))
+	for _, service := range fakeServices {
+		rate := service
+		rate.ID = "rate_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+		c.rates[rate.ID] = rate
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// BuyLabel purchases a label for a quoted rate; repeated idempotency keys return the same label
+func (c *FakeCarrier) BuyLabel(ctx context.Context, req LabelRequest) (*Label, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if id, ok := c.purchase[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		label := c.labels[id].label
+		return &label, nil
+	}
+
+	rate, ok := c.rates[req.RateID]
+	if !ok {
+		return nil, fmt.Errorf("fake: unknown rate %s", req.RateID)
+	}
+
+	id := "txn_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	trackingNumber := strings.ToUpper(rate.Carrier[:1]) + "Z" + strings.ToUpper(strings.ReplaceAll(uuid.New().String(), "-", "")[:16])
+	l := &fakeLabel{
+		label: Label{
+			ID:             id,
+			RateID:         rate.ID,
+			TrackingNumber: trackingNumber,
+			TrackingURL:    "https://track.phil-my-meds.test/" + trackingNumber,
+			LabelURL:       fakeLabelBase + id + ".pdf",
+		},
+		carrier: rate.Carrier,
+	}
+	c.labels[id] = l
+	c.tracking[trackingNumber] = &TrackingStatus{
+		Carrier:        rate.Carrier,
+		TrackingNumber: trackingNumber,
+		State:          TrackingPreTransit,
+		UpdatedAt:      time.Now(),
+	}
+	if req.IdempotencyKey != "" {
+		c.purchase[req.IdempotencyKey] = id
+	}
+
+	label := l.label
+	return &label, nil
+}
+
+// VoidLabel voids a label that has not entered the carrier network
+func (c *FakeCarrier) VoidLabel(ctx context.Context, labelID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.labels[labelID]
+	if !ok {
+		return ErrLabelNotFound
+	}
+	if status := c.tracking[l.label.TrackingNumber]; status != nil && status.State != TrackingPreTransit {
+		return fmt.Errorf("fake: label %s is %s and can no longer be voided", labelID, status.State)
+	}
+	l.voided = true
+	return nil
+}
+
+// Track returns the parcel's current tracking status
+func (c *FakeCarrier) Track(ctx context.Context, carrier, trackingNumber string) (*TrackingStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.tracking[trackingNumber]
+	if !ok {
+		return &TrackingStatus{Carrier: carrier, TrackingNumber: trackingNumber, State: TrackingUnknown}, nil
+	}
+	result := *status
+	return &result, nil
+}
+
+// SetTracking moves a parcel to a tracking state
+func (c *FakeCarrier) SetTracking(trackingNumber string, state TrackingState, details string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.tracking[trackingNumber]
+	if !ok {
+		return fmt.Errorf("fake: unknown tracking number %s", trackingNumber)
+	}
+	status.State = state
+	status.Details = details
+	status.UpdatedAt = time.Now()
+	return nil
+}
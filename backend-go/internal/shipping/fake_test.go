@@ -0,0 +1,57 @@
+// Package shipping provides shipping carrier tests
+package shipping
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestFakeCarrier_Lifecycle tests validation, rating, label purchase, tracking and voiding
+func TestFakeCarrier_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	var carrier Carrier = NewFakeCarrier()
+	fake := carrier.(*FakeCarrier)
+
+	invalid, _ := carrier.ValidateAddress(ctx, Address{Street1: "1 Main St", City: "Springfield", State: "Illinois", Zip: "627"})
+	if invalid.Valid || len(invalid.Messages) != 2 {
+		t.Errorf("Expected state and zip to be rejected, got %+v", invalid)
+	}
+	valid, _ := carrier.ValidateAddress(ctx, Address{Street1: "1 Main St", City: "Springfield", State: "IL", Zip: "62701"})
+	if !valid.Valid {
+		t.Errorf("Expected a complete address to be valid, got %+v", valid)
+	}
+
+	rates, err := carrier.GetRates(ctx, RateRequest{Parcel: DefaultParcel})
+	if err != nil || len(rates) == 0 {
+		t.Fatalf("Expected rates, got %v (%v)", rates, err)
+	}
+	rate, err := SelectRate(rates, DefaultParcel, time.Now().AddDate(0, 0, 3), time.Now())
+	if err != nil {
+		t.Fatalf("Expected an eligible rate, got %v", err)
+	}
+
+	label, err := carrier.BuyLabel(ctx, LabelRequest{RateID: rate.ID, IdempotencyKey: "label-rx_1"})
+	if err != nil {
+		t.Fatalf("Expected label, got %v", err)
+	}
+	again, _ := carrier.BuyLabel(ctx, LabelRequest{RateID: rate.ID, IdempotencyKey: "label-rx_1"})
+	if again.ID != label.ID {
+		t.Errorf("Expected idempotent label %s, got %s", label.ID, again.ID)
+	}
+
+	status, _ := carrier.Track(ctx, rate.Carrier, label.TrackingNumber)
+	if status.State != TrackingPreTransit {
+		t.Errorf("Expected pre_transit, got %s", status.State)
+	}
+
+	if err := fake.SetTracking(label.TrackingNumber, TrackingInTransit, "Accepted at facility"); err != nil {
+		t.Fatalf("Expected tracking update, got %v", err)
+	}
+	if err := carrier.VoidLabel(ctx, label.ID); err == nil {
+		t.Error("Expected a label in transit not to be voidable")
+	}
+	if err := carrier.VoidLabel(ctx, "txn_missing"); err != ErrLabelNotFound {
+		t.Errorf("Expected ErrLabelNotFound, got %v", err)
+	}
+}
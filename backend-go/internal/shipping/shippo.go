@@ -0,0 +1,430 @@
+// Package shipping provides shipping carrier integrations for prescription delivery
+package shipping
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultShippoAPIBase is the Shippo API; point SHIPPO_API_BASE at a local stub in development
+const DefaultShippoAPIBase = "https://api.goshippo.com"
+
+// ShippoCarrier is a Carrier backed by the Shippo multi-carrier API
+// It speaks the Shippo REST API directly, so it also works against a local stub
+type ShippoCarrier struct {
+	apiBase    string
+	token      string
+	httpClient *http.Client
+}
+
+// ShippoError is an error response from the Shippo API
+type ShippoError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ShippoError) Error() string {
+	return fmt.Sprintf("shippo: %s (%d)", e.Message, e.StatusCode)
+}
+
+// shippoAddress is a Shippo address object
+type shippoAddress struct {
+	ObjectID          string                   `json:"object_id,omitempty"`
+	Name              string                   `json:"name,omitempty"`
+	Company           string                   `json:"company,omitempty"`
+	Street1           string                   `json:"street1"`
+	Street2           string                   `json:"street2,omitempty"`
+	City              string                   `json:"city"`
+	State             string                   `json:"state"`
+	Zip               string                   `json:"zip"`
+	Country           string                   `json:"country"`
+	Phone             string                   `json:"phone,omitempty"`
+	Email             string                   `json:"email,omitempty"`
+	Validate          bool                     `json:"validate,omitempty"`
+	ValidationResults *shippoValidationResults `json:"validation_results,omitempty"`
+}
+
+// shippoValidationResults is the validation verdict on a Shippo address
+type shippoValidationResults struct {
+	IsValid  bool `json:"is_valid"`
+	Messages []struct {
+		Code string `json:"code"`
+		Text string `json:"text"`
+	} `json:"messages"`
+}
+
+// shippoParcel is a Shippo parcel object
+type shippoParcel struct {
+	Length       string `json:"length"`
+	Width        string `json:"width"`
+	Height       string `json:"height"`
+	DistanceUnit string `json:"distance_unit"`
+	Weight       string `json:"weight"`
+	MassUnit     string `json:"mass_unit"`
+}
+
+// shippoRate is the subset of a Shippo rate the carrier reads
+type shippoRate struct {
+	ObjectID     string `json:"object_id"`
+	Amount       string `json:"amount"`
+	Currency     string `json:"currency"`
+	Provider     string `json:"provider"`
+	ServiceLevel struct {
+		Name  string `json:"name"`
+		Token string `json:"token"`
+	} `json:"servicelevel"`
+	EstimatedDays int `json:"estimated_days"`
+}
+
+// shippoTransaction is the subset of a Shippo transaction (purchased label) the carrier reads
+type shippoTransaction struct {
+	ObjectID       string `json:"object_id"`
+	Status         string `json:"status"` // QUEUED, WAITING, SUCCESS, ERROR
+	Rate           string `json:"rate"`
+	TrackingNumber string `json:"tracking_number"`
+	TrackingURL    string `json:"tracking_url_provider"`
+	LabelURL       string `json:"label_url"`
+	Messages       []struct {
+		Text string `json:"text"`
+	} `json:"messages"`
+}
+
+// shippoTrack is the subset of a Shippo tracking status the carrier reads
+type shippoTrack struct {
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+	ETA            string `json:"eta"`
+	TrackingStatus *struct {
+		Status    string `json:"status"` // PRE_TRANSIT, TRANSIT, DELIVERED, RETURNED, FAILURE, UNKNOWN
+		Substatus *struct {
+			Code string `json:"code"` // e.g. out_for_delivery, delivery_attempted, package_damaged
+		} `json:"substatus"`
+		StatusDetails string `json:"status_details"`
+		StatusDate    string `json:"status_date"`
+		Location      *struct {
+			City  string `json:"city"`
+			State string `json:"state"`
+			Zip   string `json:"zip"`
+		} `json:"location"`
+	} `json:"tracking_status"`
+}
+
+// status converts a Shippo tracking object to a carrier-neutral tracking status
+func (t shippoTrack) status(carrier, trackingNumber string) *TrackingStatus {
+	if carrier == "" {
+		carrier = t.Carrier
+	}
+	if trackingNumber == "" {
+		trackingNumber = t.TrackingNumber
+	}
+	status := &TrackingStatus{
+		Carrier:        carrier,
+		TrackingNumber: trackingNumber,
+		State:          TrackingUnknown,
+	}
+	if ts := t.TrackingStatus; ts != nil {
+		substatus := ""
+		if ts.Substatus != nil {
+			substatus = ts.Substatus.Code
+		}
+		status.State = shippoTrackingState(ts.Status, substatus)
+		status.Details = ts.StatusDetails
+		if ts.Location != nil {
+			status.Location = strings.TrimSpace(strings.Trim(ts.Location.City+", "+ts.Location.State, ", ") + " " + ts.Location.Zip)
+		}
+		if updated, err := time.Parse(time.RFC3339, ts.StatusDate); err == nil {
+			status.UpdatedAt = updated
+		}
+	}
+	if eta, err := time.Parse(time.RFC3339, t.ETA); err == nil {
+		status.ETA = &eta
+	}
+	return status
+}
+
+// NewShippoCarrier creates a Shippo carrier
+func NewShippoCarrier(apiBase, token string) *ShippoCarrier {
+	if apiBase == "" {
+		apiBase = DefaultShippoAPIBase
+	}
+	return &ShippoCarrier{
+		apiBase:    strings.TrimRight(apiBase, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 20 * time.Second},
+	}
+}
+
+// Name returns the integration name
+func (c *ShippoCarrier) Name() string {
+	return CarrierShippo
+}
+
+// ValidateAddress creates a Shippo address with validation enabled and returns the verdict
+func (c *ShippoCarrier) ValidateAddress(ctx context.Context, address Address) (*AddressValidation, error) {
+	req := toShippoAddress(address)
+	req.Validate = true
+
+	var resp shippoAddress
+	if err := c.do(ctx, http.MethodPost, "/addresses/", req, "", &resp); err != nil {
+		return nil, err
+	}
+
+	validation := &AddressValidation{Valid: resp.ValidationResults != nil && resp.ValidationResults.IsValid}
+	if resp.ValidationResults != nil {
+		for _, m := range resp.ValidationResults.Messages {
+			validation.Messages = append(validation.Messages, m.Text)
+		}
+	}
+	if validation.Valid {
+		// Offer the carrier's normalized address only when it differs from what was sent
+		suggested := fromShippoAddress(resp)
+		if suggested != fromShippoAddress(req) {
+			validation.Suggested = &suggested
+		}
+	}
+	return validation, nil
+}
+
+// GetRates creates a Shippo shipment synchronously and returns its rates
+func (c *ShippoCarrier) GetRates(ctx context.Context, req RateRequest) ([]Rate, error) {
+	body := map[string]interface{}{
+		"address_from": toShippoAddress(req.From),
+		"address_to":   toShippoAddress(req.To),
+		"parcels":      []shippoParcel{toShippoParcel(req.Parcel)},
+		"async":        false,
+	}
+	if !req.ShipDate.IsZero() {
+		body["shipment_date"] = req.ShipDate.UTC().Format(time.RFC3339)
+	}
+
+	var resp struct {
+		ObjectID string       `json:"object_id"`
+		Rates    []shippoRate `json:"rates"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/shipments/", body, "", &resp); err != nil {
+		return nil, err
+	}
+
+	rates := make([]Rate, 0, len(resp.Rates))
+	for _, r := range resp.Rates {
+		amount, err := strconv.ParseFloat(r.Amount, 64)
+		if err != nil {
+			continue
+		}
+		rates = append(rates, Rate{
+			ID:            r.ObjectID,
+			Carrier:       r.Provider,
+			ServiceLevel:  r.ServiceLevel.Token,
+			ServiceName:   r.ServiceLevel.Name,
+			Amount:        amount,
+			Currency:      strings.ToLower(r.Currency),
+			EstimatedDays: r.EstimatedDays,
+		})
+	}
+	return rates, nil
+}
+
+// BuyLabel purchases a PDF label for a rate
+func (c *ShippoCarrier) BuyLabel(ctx context.Context, req LabelRequest) (*Label, error) {
+	body := map[string]interface{}{
+		"rate":            req.RateID,
+		"label_file_type": "PDF",
+		"async":           false,
+	}
+
+	var resp shippoTransaction
+	if err := c.do(ctx, http.MethodPost, "/transactions/", body, req.IdempotencyKey, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "SUCCESS" {
+		messages := make([]string, 0, len(resp.Messages))
+		for _, m := range resp.Messages {
+			messages = append(messages, m.Text)
+		}
+		return nil, fmt.Errorf("shippo: label purchase %s: %s", strings.ToLower(resp.Status), strings.Join(messages, "; "))
+	}
+
+	return &Label{
+		ID:             resp.ObjectID,
+		RateID:         resp.Rate,
+		TrackingNumber: resp.TrackingNumber,
+		TrackingURL:    resp.TrackingURL,
+		LabelURL:       resp.LabelURL,
+	}, nil
+}
+
+// VoidLabel requests a refund of an unused label
+func (c *ShippoCarrier) VoidLabel(ctx context.Context, labelID string) error {
+	body := map[string]interface{}{
+		"transaction": labelID,
+		"async":       false,
+	}
+
+	var resp struct {
+		Status string `json:"status"` // QUEUED, PENDING, SUCCESS, ERROR
+	}
+	if err := c.do(ctx, http.MethodPost, "/refunds/", body, "", &resp); err != nil {
+		if se, ok := err.(*ShippoError); ok && se.StatusCode == http.StatusNotFound {
+			return ErrLabelNotFound
+		}
+		return err
+	}
+	if resp.Status == "ERROR" {
+		return fmt.Errorf("shippo: void of label %s was refused", labelID)
+	}
+	return nil
+}
+
+// Track returns the latest tracking status for a parcel
+func (c *ShippoCarrier) Track(ctx context.Context, carrier, trackingNumber string) (*TrackingStatus, error) {
+	path := "/tracks/" + url.PathEscape(strings.ToLower(carrier)) + "/" + url.PathEscape(trackingNumber)
+
+	var resp shippoTrack
+	if err := c.do(ctx, http.MethodGet, path, nil, "", &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.status(carrier, trackingNumber), nil
+}
+
+// do sends a Shippo API request and decodes the JSON response into out
+func (c *ShippoCarrier) do(ctx context.Context, method, path string, body interface{}, idempotencyKey string, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode shippo request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.apiBase+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to build shippo request: %w", err)
+	}
+	req.Header.Set("Authorization", "ShippoToken "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("shippo request %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read shippo response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		var envelope struct {
+			Detail string `json:"detail"`
+		}
+		message := strings.TrimSpace(string(data))
+		if err := json.Unmarshal(data, &envelope); err == nil && envelope.Detail != "" {
+			message = envelope.Detail
+		}
+		return &ShippoError{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode shippo response: %w", err)
+	}
+	return nil
+}
+
+// shippoExceptionSubstatuses are Shippo substatus codes that need attention even while the parcel is in transit
+var shippoExceptionSubstatuses = map[string]bool{
+	"delivery_attempted":    true,
+	"address_issue":         true,
+	"contact_carrier":       true,
+	"package_damaged":       true,
+	"package_undeliverable": true,
+	"package_lost":          true,
+	"package_disposed":      true,
+}
+
+// shippoTrackingState maps a Shippo tracking status and substatus to a carrier-neutral state
+func shippoTrackingState(status, substatus string) TrackingState {
+	switch status {
+	case "PRE_TRANSIT":
+		return TrackingPreTransit
+	case "TRANSIT":
+		if substatus == "out_for_delivery" {
+			return TrackingOutForDelivery
+		}
+		if shippoExceptionSubstatuses[substatus] {
+			return TrackingException
+		}
+		return TrackingInTransit
+	case "DELIVERED":
+		return TrackingDelivered
+	case "RETURNED":
+		return TrackingReturned
+	case "FAILURE":
+		return TrackingException
+	}
+	return TrackingUnknown
+}
+
+// toShippoAddress converts an address to Shippo's format
+func toShippoAddress(a Address) shippoAddress {
+	country := a.Country
+	if country == "" {
+		country = "US"
+	}
+	return shippoAddress{
+		Name:    a.Name,
+		Company: a.Company,
+		Street1: a.Street1,
+		Street2: a.Street2,
+		City:    a.City,
+		State:   a.State,
+		Zip:     a.Zip,
+		Country: country,
+		Phone:   a.Phone,
+		Email:   a.Email,
+	}
+}
+
+// fromShippoAddress converts a Shippo address back to an address
+func fromShippoAddress(a shippoAddress) Address {
+	return Address{
+		Name:    a.Name,
+		Company: a.Company,
+		Street1: a.Street1,
+		Street2: a.Street2,
+		City:    a.City,
+		State:   a.State,
+		Zip:     a.Zip,
+		Country: a.Country,
+		Phone:   a.Phone,
+		Email:   a.Email,
+	}
+}
+
+// toShippoParcel converts a parcel to Shippo's format in inches and ounces
+func toShippoParcel(p Parcel) shippoParcel {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	return shippoParcel{
+		Length:       format(p.LengthIn),
+		Width:        format(p.WidthIn),
+		Height:       format(p.HeightIn),
+		DistanceUnit: "in",
+		Weight:       format(p.WeightOz),
+		MassUnit:     "oz",
+	}
+}
@@ -0,0 +1,124 @@
+// Package shipping provides shipping carrier tests
+package shipping
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newShippoStub serves canned Shippo responses and checks the token on every request
+func newShippoStub(t *testing.T, routes map[string]http.HandlerFunc) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "ShippoToken shippo_test_123" {
+			t.Errorf("Expected ShippoToken authorization, got %q", got)
+		}
+		handler, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r)
+	}))
+}
+
+// TestShippoCarrier_RatesAndLabel tests rate quoting and label purchase against a stub
+func TestShippoCarrier_RatesAndLabel(t *testing.T) {
+	server := newShippoStub(t, map[string]http.HandlerFunc{
+		"POST /shipments/": func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Parcels []shippoParcel `json:"parcels"`
+				Async   bool           `json:"async"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode shipment request: %v", err)
+			}
+			if len(body.Parcels) != 1 || body.Parcels[0].Weight != "16.00" || body.Parcels[0].MassUnit != "oz" {
+				t.Errorf("Unexpected parcels: %+v", body.Parcels)
+			}
+			w.Write([]byte(`{"object_id":"shp_1","rates":[
+				{"object_id":"rate_1","amount":"9.85","currency":"USD","provider":"USPS","servicelevel":{"name":"Priority Mail","token":"usps_priority"},"estimated_days":2},
+				{"object_id":"rate_bad","amount":"n/a","currency":"USD","provider":"USPS","servicelevel":{"token":"usps_first"}}
+			]}`))
+		},
+		"POST /transactions/": func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("Idempotency-Key"); got != "label-rx_1" {
+				t.Errorf("Expected idempotency key, got %q", got)
+			}
+			w.Write([]byte(`{"object_id":"txn_1","status":"SUCCESS","rate":"rate_1","tracking_number":"9400100000000000000000","tracking_url_provider":"https://tools.usps.com/t/9400","label_url":"https://shippo-delivery.s3.amazonaws.com/txn_1.pdf"}`))
+		},
+	})
+	defer server.Close()
+
+	carrier := NewShippoCarrier(server.URL, "shippo_test_123")
+	ctx := context.Background()
+
+	rates, err := carrier.GetRates(ctx, RateRequest{From: Address{Zip: "10001"}, To: Address{Zip: "94105"}, Parcel: DefaultParcel})
+	if err != nil {
+		t.Fatalf("Expected rates, got %v", err)
+	}
+	if len(rates) != 1 || rates[0].Amount != 9.85 || rates[0].ServiceLevel != "usps_priority" || rates[0].Currency != "usd" {
+		t.Fatalf("Unexpected rates: %+v", rates)
+	}
+
+	label, err := carrier.BuyLabel(ctx, LabelRequest{RateID: "rate_1", IdempotencyKey: "label-rx_1"})
+	if err != nil {
+		t.Fatalf("Expected label, got %v", err)
+	}
+	if label.ID != "txn_1" || label.TrackingNumber == "" || label.LabelURL == "" {
+		t.Errorf("Unexpected label: %+v", label)
+	}
+}
+
+// TestShippoCarrier_ValidateAndTrack tests address validation, label errors and tracking status mapping
+func TestShippoCarrier_ValidateAndTrack(t *testing.T) {
+	server := newShippoStub(t, map[string]http.HandlerFunc{
+		"POST /addresses/": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"object_id":"adr_1","street1":"1 MAIN ST","city":"SPRINGFIELD","state":"IL","zip":"62701-1234","country":"US",
+				"validation_results":{"is_valid":true,"messages":[{"code":"Default Match","text":"Address corrected"}]}}`))
+		},
+		"POST /transactions/": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"object_id":"txn_2","status":"ERROR","messages":[{"text":"Rate expired"}]}`))
+		},
+		"GET /tracks/usps/9400100000000000000000": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"carrier":"usps","tracking_number":"9400100000000000000000","eta":"2024-03-06T20:00:00Z",
+				"tracking_status":{"status":"DELIVERED","status_details":"Delivered, Front Door","status_date":"2024-03-06T15:04:05Z"}}`))
+		},
+		"POST /refunds/": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"detail":"Not found."}`))
+		},
+	})
+	defer server.Close()
+
+	carrier := NewShippoCarrier(server.URL, "shippo_test_123")
+	ctx := context.Background()
+
+	validation, err := carrier.ValidateAddress(ctx, Address{Street1: "1 Main St", City: "Springfield", State: "IL", Zip: "62701"})
+	if err != nil {
+		t.Fatalf("Expected validation, got %v", err)
+	}
+	if !validation.Valid || validation.Suggested == nil || validation.Suggested.Zip != "62701-1234" || len(validation.Messages) != 1 {
+		t.Errorf("Unexpected validation: %+v", validation)
+	}
+
+	if _, err := carrier.BuyLabel(ctx, LabelRequest{RateID: "rate_old"}); err == nil {
+		t.Error("Expected a failed label purchase to return an error")
+	}
+
+	status, err := carrier.Track(ctx, "USPS", "9400100000000000000000")
+	if err != nil {
+		t.Fatalf("Expected tracking status, got %v", err)
+	}
+	if status.State != TrackingDelivered || status.ETA == nil || status.UpdatedAt.IsZero() {
+		t.Errorf("Unexpected tracking status: %+v", status)
+	}
+
+	if err := carrier.VoidLabel(ctx, "txn_missing"); err != ErrLabelNotFound {
+		t.Errorf("Expected ErrLabelNotFound, got %v", err)
+	}
+}
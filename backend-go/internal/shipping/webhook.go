@@ -0,0 +1,130 @@
+// Package shipping provides shipping carrier integrations for prescription delivery
+package shipping
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the tracking webhook signature, as "t=<unix>,v1=<hex hmac>"
+const SignatureHeader = "X-Carrier-Signature"
+
+// DefaultWebhookTolerance is how far a signed timestamp may drift from now before the delivery is rejected
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// Webhook verification errors
+var (
+	ErrMissingSignature = errors.New("missing webhook signature")
+	ErrInvalidSignature = errors.New("webhook signature does not match payload")
+	ErrSignatureExpired = errors.New("webhook timestamp outside tolerance")
+)
+
+// TrackingWebhookEvent is a carrier webhook delivery
+// Status is nil for event types other than tracking updates
+type TrackingWebhookEvent struct {
+	Event  string
+	Test   bool
+	Status *TrackingStatus
+}
+
+// shippoWebhook is the envelope of a Shippo webhook delivery
+type shippoWebhook struct {
+	Event string          `json:"event"` // track_updated, transaction_created, ...
+	Test  bool            `json:"test"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// VerifyWebhookSignature checks the signature header against an HMAC-SHA256 of "<timestamp>.<payload>"
+// The header may carry several v1 signatures while a secret is being rolled; any match is accepted.
+func VerifyWebhookSignature(payload []byte, header, secret string, tolerance time.Duration, now time.Time) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return ErrMissingSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 {
+		drift := now.Sub(time.Unix(unix, 0))
+		if drift > tolerance || drift < -tolerance {
+			return ErrSignatureExpired
+		}
+	}
+
+	expected := computeSignature(payload, timestamp, secret)
+	for _, sig := range signatures {
+		decoded, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// SignWebhookPayload returns the signature header for a payload, as the carrier integration would send it
+func SignWebhookPayload(payload []byte, secret string, timestamp time.Time) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(computeSignature(payload, t, secret))
+}
+
+// computeSignature returns the HMAC-SHA256 of "<timestamp>.<payload>"
+func computeSignature(payload []byte, timestamp, secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// ParseTrackingWebhook decodes a Shippo webhook delivery
+// track_updated events carry the same tracking object the tracks API returns.
+func ParseTrackingWebhook(payload []byte) (*TrackingWebhookEvent, error) {
+	var envelope shippoWebhook
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook event: %w", err)
+	}
+	if envelope.Event == "" {
+		return nil, errors.New("webhook event is missing its type")
+	}
+
+	event := &TrackingWebhookEvent{Event: envelope.Event, Test: envelope.Test}
+	if envelope.Event != "track_updated" {
+		return event, nil
+	}
+
+	var track shippoTrack
+	if err := json.Unmarshal(envelope.Data, &track); err != nil {
+		return nil, fmt.Errorf("failed to decode tracking update: %w", err)
+	}
+	if track.TrackingNumber == "" || track.TrackingStatus == nil {
+		return nil, errors.New("tracking update is missing the tracking number or status")
+	}
+	event.Status = track.status("", "")
+	return event, nil
+}
@@ -0,0 +1,78 @@
+// Package shipping provides shipping carrier tests
+package shipping
+
+import (
+	"testing"
+	"time"
+)
+
+// TestVerifyWebhookSignature tests tracking webhook signature and tolerance handling
+func TestVerifyWebhookSignature(t *testing.T) {
+	payload := []byte(`{"event":"track_updated","data":{}}`)
+	secret := "trk_test"
+	now := time.Unix(1700000000, 0)
+	valid := SignWebhookPayload(payload, secret, now)
+
+	tests := []struct {
+		name    string
+		payload []byte
+		header  string
+		now     time.Time
+		want    error
+	}{
+		{"valid", payload, valid, now, nil},
+		{"too old", payload, valid, now.Add(6 * time.Minute), ErrSignatureExpired},
+		{"tampered payload", []byte(`{"event":"track_updated","data":{"x":1}}`), valid, now, ErrInvalidSignature},
+		{"wrong secret", payload, SignWebhookPayload(payload, "trk_other", now), now, ErrInvalidSignature},
+		{"missing header", payload, "", now, ErrMissingSignature},
+	}
+
+	for _, tt := range tests {
+		if err := VerifyWebhookSignature(tt.payload, tt.header, secret, DefaultWebhookTolerance, tt.now); err != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+// TestParseTrackingWebhook tests mapping Shippo track_updated deliveries to tracking states
+func TestParseTrackingWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    TrackingState
+	}{
+		{"pre transit", `{"event":"track_updated","data":{"carrier":"usps","tracking_number":"9400","tracking_status":{"status":"PRE_TRANSIT"}}}`, TrackingPreTransit},
+		{"in transit", `{"event":"track_updated","data":{"carrier":"usps","tracking_number":"9400","tracking_status":{"status":"TRANSIT","substatus":{"code":"package_accepted"}}}}`, TrackingInTransit},
+		{"out for delivery", `{"event":"track_updated","data":{"carrier":"usps","tracking_number":"9400","tracking_status":{"status":"TRANSIT","substatus":{"code":"out_for_delivery"}}}}`, TrackingOutForDelivery},
+		{"delivery attempted", `{"event":"track_updated","data":{"carrier":"usps","tracking_number":"9400","tracking_status":{"status":"TRANSIT","substatus":{"code":"delivery_attempted"}}}}`, TrackingException},
+		{"failure", `{"event":"track_updated","data":{"carrier":"usps","tracking_number":"9400","tracking_status":{"status":"FAILURE"}}}`, TrackingException},
+		{"returned", `{"event":"track_updated","data":{"carrier":"usps","tracking_number":"9400","tracking_status":{"status":"RETURNED"}}}`, TrackingReturned},
+	}
+
+	for _, tt := range tests {
+		event, err := ParseTrackingWebhook([]byte(tt.payload))
+		if err != nil {
+			t.Fatalf("%s: expected event, got %v", tt.name, err)
+		}
+		if event.Status == nil || event.Status.State != tt.want {
+			t.Errorf("%s: expected state %s, got %+v", tt.name, tt.want, event.Status)
+		}
+	}
+
+	event, err := ParseTrackingWebhook([]byte(`{"event":"track_updated","data":{"carrier":"usps","tracking_number":"9400","tracking_status":{"status":"DELIVERED","status_details":"Delivered, Front Door","status_date":"2024-03-06T15:04:05Z","location":{"city":"Springfield","state":"IL","zip":"62701"}}}}`))
+	if err != nil {
+		t.Fatalf("Expected event, got %v", err)
+	}
+	status := event.Status
+	if status.Carrier != "usps" || status.TrackingNumber != "9400" || status.Location != "Springfield, IL 62701" || status.UpdatedAt.IsZero() {
+		t.Errorf("Unexpected tracking status: %+v", status)
+	}
+
+	event, err = ParseTrackingWebhook([]byte(`{"event":"transaction_created","data":{}}`))
+	if err != nil || event.Status != nil {
+		t.Errorf("Expected other events to be ignored, got %+v, %v", event, err)
+	}
+	if _, err := ParseTrackingWebhook([]byte(`{"event":"track_updated","data":{"tracking_status":{"status":"TRANSIT"}}}`)); err == nil {
+		t.Error("Expected an update without a tracking number to be rejected")
+	}
+}
@@ -0,0 +1,68 @@
+// Package sms provides SMS provider integrations for patient notifications
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// FakeSender is an in-memory Sender for tests and local runs without an SMS provider
+// Messages are logged and kept; tests can make a number fail with Reject
+type FakeSender struct {
+	mu       sync.Mutex
+	sent     []FakeMessage
+	rejected map[string]error
+}
+
+// FakeMessage is a message the fake sender accepted
+type FakeMessage struct {
+	Message
+	ID string
+}
+
+// NewFakeSender creates an in-memory SMS sender
+func NewFakeSender() *FakeSender {
+	return &FakeSender{rejected: make(map[string]error)}
+}
+
+// Name returns the provider name
+func (s *FakeSender) Name() string {
+	return ProviderFake
+}
+
+// Send accepts a message for any valid E.164 number that has not been rejected
+func (s *FakeSender) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	if to, err := NormalizePhone(msg.To); err != nil || to != msg.To {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidNumber, msg.To)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err, ok := s.rejected[msg.To]; ok {
+		return nil, err
+	}
+	id := "SMfake" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	s.sent = append(s.sent, FakeMessage{Message: msg, ID: id})
+	log.Printf("📱 [fake sms] %s to %s: %s", id, msg.To, msg.Body)
+	return &Receipt{MessageID: id, Status: StatusQueued}, nil
+}
+
+// Reject makes sends to a number fail with err
+func (s *FakeSender) Reject(to string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejected[to] = err
+}
+
+// Sent returns the messages accepted so far
+func (s *FakeSender) Sent() []FakeMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]FakeMessage(nil), s.sent...)
+}
@@ -0,0 +1,102 @@
+// Package sms provides SMS provider integrations for patient notifications
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Provider names
+const (
+	ProviderTwilio = "twilio"
+	ProviderFake   = "fake"
+)
+
+// DeliveryStatus is the provider-neutral delivery status of a text message
+type DeliveryStatus string
+
+const (
+	StatusQueued      DeliveryStatus = "queued"      // accepted by the provider, not yet handed to the carrier
+	StatusSent        DeliveryStatus = "sent"        // handed to the carrier
+	StatusDelivered   DeliveryStatus = "delivered"   // the carrier confirmed delivery to the handset
+	StatusUndelivered DeliveryStatus = "undelivered" // the carrier could not deliver it
+	StatusFailed      DeliveryStatus = "failed"      // the provider could not send it
+)
+
+// IsFinalStatus reports whether no further delivery receipts follow a status
+func IsFinalStatus(status DeliveryStatus) bool {
+	return status == StatusDelivered || status == StatusUndelivered || status == StatusFailed
+}
+
+var (
+	// ErrInvalidNumber is returned when a phone number cannot be texted
+	ErrInvalidNumber = errors.New("invalid phone number")
+	// ErrUnsubscribed is returned when the recipient has opted out of texts with the provider (replied STOP)
+	ErrUnsubscribed = errors.New("recipient has opted out of text messages")
+)
+
+// Message is a text message to send
+type Message struct {
+	To             string // E.164
+	Body           string
+	StatusCallback string // URL the provider posts delivery receipts to; empty for none
+}
+
+// Receipt is the provider's acknowledgement of a sent message
+type Receipt struct {
+	MessageID    string
+	Status       DeliveryStatus
+	ErrorCode    string
+	ErrorMessage string
+}
+
+// Sender sends text messages
+type Sender interface {
+	// Name returns the provider name
+	Name() string
+	// Send hands a message to the provider; delivery is reported later through the status callback
+	Send(ctx context.Context, msg Message) (*Receipt, error)
+}
+
+// NewSender creates the configured SMS provider
+func NewSender(name, apiBase, accountSID, authToken, from string) (Sender, error) {
+	switch name {
+	case ProviderTwilio:
+		return NewTwilioSender(apiBase, accountSID, authToken, from), nil
+	case ProviderFake:
+		return NewFakeSender(), nil
+	}
+	return nil, fmt.Errorf("unknown SMS provider %q", name)
+}
+
+// NormalizePhone converts a phone number to E.164
+// Ten digit numbers, and eleven digit numbers starting with 1, are taken as US numbers; other numbers
+// must already carry a + and country code.
+func NormalizePhone(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	international := strings.HasPrefix(raw, "+")
+
+	var digits strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' || r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", fmt.Errorf("%w: %q", ErrInvalidNumber, raw)
+		}
+	}
+	d := digits.String()
+
+	switch {
+	case international && len(d) >= 8 && len(d) <= 15 && d[0] != '0':
+		return "+" + d, nil
+	case !international && len(d) == 10 && d[0] >= '2':
+		return "+1" + d, nil
+	case !international && len(d) == 11 && d[0] == '1' && d[1] >= '2':
+		return "+" + d, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidNumber, raw)
+}
@@ -0,0 +1,73 @@
+// Package sms provides SMS provider tests
+package sms
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestNormalizePhone tests conversion of patient phone numbers to E.164
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+		valid    bool
+	}{
+		{"(555) 201-0000", "+15552010000", true},
+		{"555.201.0000", "+15552010000", true},
+		{"1-555-201-0000", "+15552010000", true},
+		{"+1 555 201 0000", "+15552010000", true},
+		{"+44 20 7946 0958", "+442079460958", true},
+		{"555-0100", "", false},
+		{"(055) 201-0000", "", false},
+		{"555-201-0000 ext 4", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizePhone(tt.raw)
+		if tt.valid && (err != nil || got != tt.expected) {
+			t.Errorf("%q: expected %s, got %s (%v)", tt.raw, tt.expected, got, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidNumber) {
+			t.Errorf("%q: expected ErrInvalidNumber, got %s (%v)", tt.raw, got, err)
+		}
+	}
+}
+
+// TestNewSender tests provider selection by name
+func TestNewSender(t *testing.T) {
+	if sender, err := NewSender(ProviderTwilio, "", "AC123", "token", "+15550001111"); err != nil || sender.Name() != ProviderTwilio {
+		t.Errorf("Expected a twilio sender, got %v (%v)", sender, err)
+	}
+	if sender, err := NewSender(ProviderFake, "", "", "", ""); err != nil || sender.Name() != ProviderFake {
+		t.Errorf("Expected a fake sender, got %v (%v)", sender, err)
+	}
+	if _, err := NewSender("carrier-pigeon", "", "", "", ""); err == nil {
+		t.Error("Expected an unknown provider to be rejected")
+	}
+}
+
+// TestFakeSender tests that the fake sender records messages and honours rejections
+func TestFakeSender(t *testing.T) {
+	sender := NewFakeSender()
+	ctx := context.Background()
+
+	receipt, err := sender.Send(ctx, Message{To: "+15552010000", Body: "Your copay is ready"})
+	if err != nil || receipt.MessageID == "" || receipt.Status != StatusQueued {
+		t.Fatalf("Expected a queued message, got %+v (%v)", receipt, err)
+	}
+	if sent := sender.Sent(); len(sent) != 1 || sent[0].ID != receipt.MessageID {
+		t.Errorf("Expected the message to be recorded, got %+v", sent)
+	}
+
+	if _, err := sender.Send(ctx, Message{To: "555-201-0000", Body: "hi"}); !errors.Is(err, ErrInvalidNumber) {
+		t.Errorf("Expected numbers not in E.164 to be rejected, got %v", err)
+	}
+
+	sender.Reject("+15552010001", ErrUnsubscribed)
+	if _, err := sender.Send(ctx, Message{To: "+15552010001", Body: "hi"}); !errors.Is(err, ErrUnsubscribed) {
+		t.Errorf("Expected ErrUnsubscribed, got %v", err)
+	}
+}
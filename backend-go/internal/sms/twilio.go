@@ -0,0 +1,155 @@
+// Package sms provides SMS provider integrations for patient notifications
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTwilioAPIBase is the Twilio API; point TWILIO_API_BASE at a local stub in development
+const DefaultTwilioAPIBase = "https://api.twilio.com"
+
+// Twilio error codes the sender maps to sentinel errors
+const (
+	twilioErrInvalidTo    = 21211 // invalid 'To' phone number
+	twilioErrNotMobile    = 21614 // 'To' number is not a valid mobile number
+	twilioErrUnsubscribed = 21610 // attempt to send to unsubscribed recipient
+)
+
+// TwilioSender is a Sender backed by the Twilio Messages API
+// It speaks the REST API directly, so it also works against a local stub
+type TwilioSender struct {
+	apiBase    string
+	accountSID string
+	authToken  string
+	from       string
+	httpClient *http.Client
+}
+
+// TwilioError is an error response from the Twilio API
+type TwilioError struct {
+	StatusCode int    `json:"status"`
+	Code       int    `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *TwilioError) Error() string {
+	return fmt.Sprintf("twilio: %s (%d, code %d)", e.Message, e.StatusCode, e.Code)
+}
+
+// Unwrap maps Twilio error codes to the package's sentinel errors
+func (e *TwilioError) Unwrap() error {
+	switch e.Code {
+	case twilioErrInvalidTo, twilioErrNotMobile:
+		return ErrInvalidNumber
+	case twilioErrUnsubscribed:
+		return ErrUnsubscribed
+	}
+	return nil
+}
+
+// twilioMessage is the subset of a Twilio Message resource the sender reads
+type twilioMessage struct {
+	SID          string `json:"sid"`
+	Status       string `json:"status"`
+	ErrorCode    *int   `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+}
+
+// NewTwilioSender creates a Twilio SMS sender sending from the given number or messaging service SID
+func NewTwilioSender(apiBase, accountSID, authToken, from string) *TwilioSender {
+	if apiBase == "" {
+		apiBase = DefaultTwilioAPIBase
+	}
+	return &TwilioSender{
+		apiBase:    strings.TrimRight(apiBase, "/"),
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Name returns the provider name
+func (s *TwilioSender) Name() string {
+	return ProviderTwilio
+}
+
+// Send creates a Twilio message
+func (s *TwilioSender) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	form := url.Values{}
+	form.Set("To", msg.To)
+	form.Set("Body", msg.Body)
+	if strings.HasPrefix(s.from, "MG") {
+		form.Set("MessagingServiceSid", s.from)
+	} else {
+		form.Set("From", s.from)
+	}
+	if msg.StatusCallback != "" {
+		form.Set("StatusCallback", msg.StatusCallback)
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", s.apiBase, url.PathEscape(s.accountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("twilio: failed to build request: %w", err)
+	}
+	req.SetBasicAuth(s.accountSID, s.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("twilio: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("twilio: failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		apiErr := &TwilioError{StatusCode: resp.StatusCode}
+		if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(body))
+		}
+		apiErr.StatusCode = resp.StatusCode
+		return nil, apiErr
+	}
+
+	var created twilioMessage
+	if err := json.Unmarshal(body, &created); err != nil {
+		return nil, fmt.Errorf("twilio: failed to decode message: %w", err)
+	}
+	receipt := &Receipt{
+		MessageID:    created.SID,
+		Status:       twilioStatus(created.Status),
+		ErrorMessage: created.ErrorMessage,
+	}
+	if created.ErrorCode != nil {
+		receipt.ErrorCode = strconv.Itoa(*created.ErrorCode)
+	}
+	return receipt, nil
+}
+
+// twilioStatus maps a Twilio message status to a delivery status
+func twilioStatus(status string) DeliveryStatus {
+	switch status {
+	case "sent":
+		return StatusSent
+	case "delivered", "read":
+		return StatusDelivered
+	case "undelivered":
+		return StatusUndelivered
+	case "failed", "canceled":
+		return StatusFailed
+	}
+	// accepted, scheduled, queued and sending are all still on their way
+	return StatusQueued
+}
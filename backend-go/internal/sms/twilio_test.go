@@ -0,0 +1,83 @@
+// Package sms provides SMS provider tests
+package sms
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestTwilioSender_Send tests the Messages API request encoding and response parsing
+func TestTwilioSender_Send(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/2010-04-01/Accounts/AC123/Messages.json" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "AC123" || pass != "token" {
+			t.Errorf("Expected account SID and auth token as basic auth, got %q", user)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("Failed to parse form: %v", err)
+		}
+		if r.PostForm.Get("To") != "+15552010000" || r.PostForm.Get("From") != "+15550001111" || r.PostForm.Get("Body") != "Your copay is ready" {
+			t.Errorf("Unexpected message form %v", r.PostForm)
+		}
+		if r.PostForm.Get("StatusCallback") != "https://api.test/api/v1/webhooks/sms/status" {
+			t.Errorf("Expected the status callback URL, got %q", r.PostForm.Get("StatusCallback"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid":"SM0001","status":"queued","error_code":null,"error_message":null}`))
+	}))
+	defer server.Close()
+
+	sender := NewTwilioSender(server.URL, "AC123", "token", "+15550001111")
+	receipt, err := sender.Send(context.Background(), Message{
+		To:             "+15552010000",
+		Body:           "Your copay is ready",
+		StatusCallback: "https://api.test/api/v1/webhooks/sms/status",
+	})
+	if err != nil {
+		t.Fatalf("Expected the message to be sent, got %v", err)
+	}
+	if receipt.MessageID != "SM0001" || receipt.Status != StatusQueued {
+		t.Errorf("Unexpected receipt %+v", receipt)
+	}
+}
+
+// TestTwilioSender_Errors tests that Twilio error codes map to the package's errors
+func TestTwilioSender_Errors(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected error
+	}{
+		{`{"code":21211,"message":"The 'To' number is not a valid phone number.","status":400}`, ErrInvalidNumber},
+		{`{"code":21610,"message":"Attempt to send to unsubscribed recipient","status":400}`, ErrUnsubscribed},
+		{`{"code":20003,"message":"Authenticate","status":401}`, nil},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(tt.body))
+		}))
+		sender := NewTwilioSender(server.URL, "AC123", "token", "MG123")
+		_, err := sender.Send(context.Background(), Message{To: "+15552010000", Body: "hi"})
+		server.Close()
+
+		var apiErr *TwilioError
+		if !errors.As(err, &apiErr) {
+			t.Errorf("%s: expected a TwilioError, got %v", tt.body, err)
+			continue
+		}
+		if tt.expected != nil && !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.body, tt.expected, err)
+		}
+		if tt.expected == nil && (errors.Is(err, ErrInvalidNumber) || errors.Is(err, ErrUnsubscribed)) {
+			t.Errorf("%s: expected no sentinel error, got %v", tt.body, err)
+		}
+	}
+}
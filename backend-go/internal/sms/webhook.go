@@ -0,0 +1,144 @@
+// Package sms provides SMS provider integrations for patient notifications
+package sms
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// SignatureHeader carries Twilio's webhook signature: base64 HMAC-SHA1 of the URL and sorted form parameters
+const SignatureHeader = "X-Twilio-Signature"
+
+// Webhook verification errors
+var (
+	ErrMissingSignature = errors.New("missing webhook signature")
+	ErrInvalidSignature = errors.New("webhook signature does not match request")
+)
+
+// Keyword is what an inbound text asks for
+type Keyword string
+
+const (
+	KeywordNone   Keyword = ""
+	KeywordOptOut Keyword = "opt_out" // STOP and its synonyms
+	KeywordOptIn  Keyword = "opt_in"  // START, UNSTOP
+	KeywordHelp   Keyword = "help"
+)
+
+// keywords are the carrier-standard opt-out, opt-in and help keywords
+var keywords = map[string]Keyword{
+	"STOP":        KeywordOptOut,
+	"STOPALL":     KeywordOptOut,
+	"UNSUBSCRIBE": KeywordOptOut,
+	"CANCEL":      KeywordOptOut,
+	"END":         KeywordOptOut,
+	"QUIT":        KeywordOptOut,
+	"OPTOUT":      KeywordOptOut,
+	"REVOKE":      KeywordOptOut,
+	"START":       KeywordOptIn,
+	"UNSTOP":      KeywordOptIn,
+	"YES":         KeywordOptIn,
+	"HELP":        KeywordHelp,
+	"INFO":        KeywordHelp,
+}
+
+// StatusCallback is a delivery receipt posted by the provider
+type StatusCallback struct {
+	MessageID    string
+	Status       DeliveryStatus
+	To           string
+	ErrorCode    string
+	ErrorMessage string
+}
+
+// InboundMessage is a text a patient sent to our number
+type InboundMessage struct {
+	MessageID string
+	From      string
+	To        string
+	Body      string
+	Keyword   Keyword
+}
+
+// VerifyWebhookSignature checks a Twilio signature over the full request URL and its form parameters
+// requestURL must be the URL the provider was configured with, including any query string.
+func VerifyWebhookSignature(requestURL string, params url.Values, header, authToken string) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+	expected := SignWebhookRequest(requestURL, params, authToken)
+	if !hmac.Equal([]byte(header), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// SignWebhookRequest returns the Twilio signature of a request; used by tests and local stubs
+func SignWebhookRequest(requestURL string, params url.Values, authToken string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var data strings.Builder
+	data.WriteString(requestURL)
+	for _, key := range keys {
+		for _, value := range params[key] {
+			data.WriteString(key)
+			data.WriteString(value)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(data.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ParseStatusCallback reads a Twilio message status callback
+func ParseStatusCallback(params url.Values) (*StatusCallback, error) {
+	id := params.Get("MessageSid")
+	if id == "" {
+		id = params.Get("SmsSid")
+	}
+	status := params.Get("MessageStatus")
+	if status == "" {
+		status = params.Get("SmsStatus")
+	}
+	if id == "" || status == "" {
+		return nil, errors.New("status callback has no message ID or status")
+	}
+	return &StatusCallback{
+		MessageID:    id,
+		Status:       twilioStatus(status),
+		To:           params.Get("To"),
+		ErrorCode:    params.Get("ErrorCode"),
+		ErrorMessage: params.Get("ErrorMessage"),
+	}, nil
+}
+
+// ParseInboundMessage reads a Twilio incoming message webhook
+func ParseInboundMessage(params url.Values) (*InboundMessage, error) {
+	msg := &InboundMessage{
+		MessageID: params.Get("MessageSid"),
+		From:      params.Get("From"),
+		To:        params.Get("To"),
+		Body:      params.Get("Body"),
+	}
+	if msg.From == "" {
+		return nil, errors.New("inbound message has no sender")
+	}
+	msg.Keyword = ParseKeyword(msg.Body)
+	return msg, nil
+}
+
+// ParseKeyword returns the keyword a text consists of; keywords only count as the whole message
+func ParseKeyword(body string) Keyword {
+	word := strings.ToUpper(strings.Trim(strings.TrimSpace(body), ".!"))
+	return keywords[word]
+}
@@ -0,0 +1,87 @@
+// Package sms provides SMS provider tests
+package sms
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+// TestVerifyWebhookSignature tests Twilio request signing and verification
+func TestVerifyWebhookSignature(t *testing.T) {
+	requestURL := "https://mycompany.com/myapp.php?foo=1&bar=2"
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	token := "12345"
+
+	signature := SignWebhookRequest(requestURL, params, token)
+	if signature == SignWebhookRequest(requestURL, params, "54321") {
+		t.Error("Expected the signature to depend on the auth token")
+	}
+	if err := VerifyWebhookSignature("https://mycompany.com/myapp.php", params, signature, token); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected the signature to cover the query string, got %v", err)
+	}
+	if err := VerifyWebhookSignature(requestURL, params, signature, token); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+
+	if err := VerifyWebhookSignature(requestURL, params, "", token); !errors.Is(err, ErrMissingSignature) {
+		t.Errorf("Expected ErrMissingSignature, got %v", err)
+	}
+	params.Set("Digits", "9999")
+	if err := VerifyWebhookSignature(requestURL, params, signature, token); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a tampered request, got %v", err)
+	}
+}
+
+// TestParseStatusCallback tests reading delivery receipts
+func TestParseStatusCallback(t *testing.T) {
+	callback, err := ParseStatusCallback(url.Values{
+		"MessageSid":    {"SM0001"},
+		"MessageStatus": {"undelivered"},
+		"To":            {"+15552010000"},
+		"ErrorCode":     {"30003"},
+	})
+	if err != nil {
+		t.Fatalf("Expected a status callback, got %v", err)
+	}
+	if callback.MessageID != "SM0001" || callback.Status != StatusUndelivered || callback.ErrorCode != "30003" {
+		t.Errorf("Unexpected status callback %+v", callback)
+	}
+
+	if _, err := ParseStatusCallback(url.Values{"MessageSid": {"SM0001"}}); err == nil {
+		t.Error("Expected a callback without a status to be rejected")
+	}
+}
+
+// TestParseKeyword tests opt-out, opt-in and help keyword detection
+func TestParseKeyword(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected Keyword
+	}{
+		{"STOP", KeywordOptOut},
+		{" stop ", KeywordOptOut},
+		{"Unsubscribe.", KeywordOptOut},
+		{"start", KeywordOptIn},
+		{"HELP", KeywordHelp},
+		{"please stop texting me", KeywordNone},
+		{"When will my meds arrive?", KeywordNone},
+	}
+
+	for _, tt := range tests {
+		if got := ParseKeyword(tt.body); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.body, tt.expected, got)
+		}
+	}
+
+	msg, err := ParseInboundMessage(url.Values{"From": {"+15552010000"}, "Body": {"STOP"}})
+	if err != nil || msg.Keyword != KeywordOptOut {
+		t.Errorf("Expected an opt-out message, got %+v (%v)", msg, err)
+	}
+}
@@ -3,28 +3,52 @@ package workers
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
 	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// defaultDaysSupply is assumed when the prescription does not carry a days supply
+const defaultDaysSupply = 30
+
 // AdjudicationWorker handles insurance adjudication events
 type AdjudicationWorker struct {
 	mongoClient   *database.MongoClient
 	kafkaProducer kafka.Producer
+	redis         *database.RedisClient
+	simulator     *services.AdjudicationSimulator
+	programs      *services.ManufacturerProgramService
+	priorAuths    *services.PriorAuthService
+	adjudications *services.AdjudicationService
+	mode          models.AdjudicationMode
 }
 
 // NewAdjudicationWorker creates a new adjudication worker
-func NewAdjudicationWorker(mongoClient *database.MongoClient, kafkaProducer kafka.Producer) *AdjudicationWorker {
+func NewAdjudicationWorker(
+	mongoClient *database.MongoClient,
+	kafkaProducer kafka.Producer,
+	redis *database.RedisClient,
+	simulator *services.AdjudicationSimulator,
+	programs *services.ManufacturerProgramService,
+	priorAuths *services.PriorAuthService,
+	mode models.AdjudicationMode,
+) *AdjudicationWorker {
 	return &AdjudicationWorker{
 		mongoClient:   mongoClient,
 		kafkaProducer: kafkaProducer,
+		redis:         redis,
+		simulator:     simulator,
+		programs:      programs,
+		priorAuths:    priorAuths,
+		adjudications: services.NewAdjudicationService(mongoClient, redis, kafkaProducer),
+		mode:          mode,
 	}
 }
 
@@ -34,103 +58,168 @@ func (w *AdjudicationWorker) Topic() string {
 }
 
 // Handle processes a pharmacy selected event and performs insurance adjudication
+// In pharmacy mode the prescription waits for the pharmacy's results callback. In simulator mode,
+// paid and partial claims get a secondary manufacturer program claim and continue to payment;
+// rejected claims raise a prescription exception
 func (w *AdjudicationWorker) Handle(ctx context.Context, msg *kafka.Message) error {
+	// Extract correlation ID from message
+	correlationID := ExtractCorrelationID(msg)
+
 	// Parse the event payload
-	var event struct {
-		EventID         string `json:"event_id"`
-		PrescriptionID  string `json:"prescription_id"`
-		PatientID       string `json:"patient_id"`
-		PharmacyID      string `json:"pharmacy_id"`
-		PharmacyNCPDPID string `json:"pharmacy_ncpdp_id"`
-		SelectedAt      string `json:"selected_at"`
-		Timestamp       string `json:"timestamp"`
-	}
-
-	if err := json.Unmarshal(msg.Value, &event); err != nil {
-		log.Printf("❌ Failed to unmarshal adjudication event: %v", err)
+	var event events.PharmacySelected
+	if err := events.Decode(msg.Value, &event); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to unmarshal adjudication event: %v", correlationID, err)
 		return err
 	}
 
-	log.Printf("💳 Processing insurance adjudication for prescription: %s", event.PrescriptionID)
+	// Use correlation ID from event if available
+	if event.CorrelationID != "" {
+		correlationID = event.CorrelationID
+	}
+
+	log.Printf("💳 [correlation_id=%s] Processing insurance adjudication for prescription: %s", correlationID, event.PrescriptionID)
 
 	// Fetch prescription
 	prescriptionCollection := w.mongoClient.GetCollection("prescriptions")
 	prescriptionID, err := primitive.ObjectIDFromHex(event.PrescriptionID)
 	if err != nil {
-		log.Printf("❌ Invalid prescription ID format: %s", event.PrescriptionID)
+		log.Printf("❌ [correlation_id=%s] Invalid prescription ID format: %s", correlationID, event.PrescriptionID)
 		return err
 	}
 
-	var prescription bson.M
+	var prescription models.Prescription
 	err = prescriptionCollection.FindOne(ctx, bson.M{"_id": prescriptionID}).Decode(&prescription)
 	if err != nil {
-		log.Printf("❌ Prescription not found: %s", event.PrescriptionID)
+		log.Printf("❌ [correlation_id=%s] Prescription not found: %s", correlationID, event.PrescriptionID)
 		return err
 	}
 
-	// Perform adjudication (simplified - actual adjudication will call insurance APIs)
-	// For now, we'll simulate a successful adjudication
-	adjudicationResult := map[string]interface{}{
-		"status":         "approved",
-		"copay_amount":   25.00,
-		"insurance_pays": 175.00,
-		"total_cost":     200.00,
-		"adjudicated_at": time.Now(),
+	// In pharmacy mode the pharmacy bills the claims and reports back via POST /api/v1/adjudication/results
+	if w.mode != models.AdjudicationModeSimulator {
+		filter := bson.M{"_id": prescriptionID, "status": models.StatusPharmacySelected}
+		updated, err := services.ChangePrescriptionStatus(ctx, prescriptionCollection, correlationID, filter, models.StatusAwaitingAdjudication, nil)
+		if err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to update prescription status: %v", correlationID, err)
+			return err
+		}
+		if updated == nil {
+			log.Printf("ℹ️  [correlation_id=%s] Prescription %s is %s, not awaiting adjudication", correlationID, event.PrescriptionID, prescription.Status)
+			return nil
+		}
+
+		log.Printf("⏳ [correlation_id=%s] Prescription %s awaiting adjudication results from pharmacy %s", correlationID, event.PrescriptionID, event.PharmacyID)
+		return nil
 	}
 
-	// Store adjudication result in MongoDB
-	adjudicationCollection := w.mongoClient.GetCollection("adjudications")
-	adjudicationDoc := bson.M{
-		"prescription_id": prescriptionID,
-		"patient_id":      event.PatientID,
-		"pharmacy_id":     event.PharmacyID,
-		"result":          adjudicationResult,
-		"created_at":      time.Now(),
-		"updated_at":      time.Now(),
+	// A redelivered event finds the claim already adjudicated; only its outcome may still be unpublished
+	if prescription.Status != models.StatusPharmacySelected {
+		return w.resume(ctx, correlationID, &prescription)
 	}
 
-	_, err = adjudicationCollection.InsertOne(ctx, adjudicationDoc)
-	if err != nil {
-		log.Printf("❌ Failed to store adjudication result: %v", err)
-		return err
+	// Submit the claim to the simulator, which applies plan rules, formulary and fill history
+	daysSupply := prescription.Medication.DaysSupply
+	if daysSupply == 0 {
+		daysSupply = defaultDaysSupply
 	}
 
-	// Update prescription status
-	update := bson.M{
-		"$set": bson.M{
-			"status":     "adjudicated",
-			"updated_at": time.Now(),
-		},
+	now := time.Now()
+	approval, err := w.priorAuths.ActiveApproval(ctx, event.PrescriptionID, prescription.Insurance.MemberID, prescription.Medication.NDC, now)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to look up prior authorization: %v", correlationID, err)
+		return err
 	}
 
-	_, err = prescriptionCollection.UpdateOne(ctx, bson.M{"_id": prescriptionID}, update)
+	result, err := w.simulator.Adjudicate(ctx, services.ClaimRequest{
+		PrescriptionID:    event.PrescriptionID,
+		Plan:              prescription.Insurance.Plan(),
+		MemberID:          prescription.Insurance.MemberID,
+		NDC:               prescription.Medication.NDC,
+		Quantity:          prescription.Medication.Quantity,
+		DaysSupply:        daysSupply,
+		PriorAuthApproved: approval != nil,
+		At:                now,
+	})
 	if err != nil {
-		log.Printf("❌ Failed to update prescription status: %v", err)
+		log.Printf("❌ [correlation_id=%s] Failed to adjudicate claim: %v", correlationID, err)
 		return err
 	}
 
-	// Emit adjudication completed event
-	adjudicationEvent := map[string]interface{}{
-		"event_id":            uuid.New().String(),
-		"prescription_id":     event.PrescriptionID,
-		"patient_id":          event.PatientID,
-		"pharmacy_id":         event.PharmacyID,
-		"adjudication_result": adjudicationResult,
-		"adjudicated_at":      time.Now().Format(time.RFC3339),
-		"timestamp":           time.Now().Format(time.RFC3339),
+	// Secondary claim to the manufacturer copay program, if the drug has one
+	programClaims := []models.ProgramClaim{}
+	var breakdown *models.CostBreakdown
+	if result.Status != models.ClaimStatusRejected {
+		programClaim, err := w.applySecondary(ctx, event.PrescriptionID, &prescription, result)
+		if err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to run manufacturer program claim: %v", correlationID, err)
+			return err
+		}
+		if programClaim != nil {
+			programClaims = append(programClaims, *programClaim)
+		}
+
+		b := services.BuildCostBreakdown(result, programClaim)
+		breakdown = &b
 	}
 
-	eventBytes, err := json.Marshal(adjudicationEvent)
-	if err != nil {
-		log.Printf("❌ Failed to marshal adjudication event: %v", err)
-		return err
+	record := &models.AdjudicationRecord{
+		PrescriptionID:       prescriptionID,
+		PatientID:            event.PatientID,
+		PharmacyID:           event.PharmacyID,
+		MemberID:             prescription.Insurance.MemberID,
+		NDC:                  prescription.Medication.NDC,
+		Source:               models.AdjudicationSourceSimulator,
+		Result:               *result,
+		ManufacturerPrograms: programClaims,
+		CostBreakdown:        breakdown,
 	}
 
-	if err := w.kafkaProducer.Publish(ctx, kafka.TopicAdjudicationCompleted, event.PrescriptionID, eventBytes); err != nil {
-		log.Printf("❌ Failed to publish adjudication completed event: %v", err)
+	if err := w.adjudications.Complete(ctx, correlationID, models.StatusPharmacySelected, record); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to complete adjudication: %v", correlationID, err)
 		return err
 	}
 
-	log.Printf("✅ Adjudication completed for prescription: %s", event.PrescriptionID)
 	return nil
 }
+
+// resume publishes the outcome of the prescription's latest adjudication again if it has not moved on since
+func (w *AdjudicationWorker) resume(ctx context.Context, correlationID string, prescription *models.Prescription) error {
+	record, err := w.adjudications.Latest(ctx, prescription.ID)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to load adjudication record: %v", correlationID, err)
+		return err
+	}
+	if record == nil {
+		log.Printf("ℹ️  [correlation_id=%s] Prescription %s is %s, not adjudicated", correlationID, prescription.ID.Hex(), prescription.Status)
+		return nil
+	}
+	if _, err := w.adjudications.Resume(ctx, correlationID, models.StatusPharmacySelected, record); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to resume adjudication: %v", correlationID, err)
+		return err
+	}
+	return nil
+}
+
+// applySecondary runs the manufacturer program claim against the primary copay
+func (w *AdjudicationWorker) applySecondary(ctx context.Context, prescriptionID string, prescription *models.Prescription, primary *models.ClaimResult) (*models.ProgramClaim, error) {
+	if primary.CopayAmount <= 0 {
+		return nil, nil
+	}
+
+	insuranceType := ""
+	rule, err := w.simulator.PlanRule(ctx, prescription.Insurance.Plan())
+	if err != nil {
+		return nil, err
+	}
+	if rule != nil {
+		insuranceType = rule.InsuranceType
+	}
+
+	return w.programs.ApplySecondary(ctx, services.SecondaryClaimRequest{
+		PrescriptionID: prescriptionID,
+		MemberID:       prescription.Insurance.MemberID,
+		NDC:            prescription.Medication.NDC,
+		InsuranceType:  insuranceType,
+		PrimaryCopay:   primary.CopayAmount,
+		At:             primary.AdjudicatedAt,
+	})
+}
@@ -3,27 +3,28 @@ package workers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"log"
-	"time"
 
-	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
 	"github.com/phil-my-meds/backend-gogit/internal/kafka"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"github.com/phil-my-meds/backend-gogit/internal/shipping"
 )
 
-// DeliveryWorker handles delivery tracking events
+// DeliveryWorker starts tracking new shipments
+// Later updates arrive through the carrier webhook and the scheduler's tracking poll
 type DeliveryWorker struct {
-	mongoClient   *database.MongoClient
-	kafkaProducer kafka.Producer
+	carrier  shipping.Carrier
+	tracking *services.ShipmentTrackingService
 }
 
 // NewDeliveryWorker creates a new delivery tracking worker
-func NewDeliveryWorker(mongoClient *database.MongoClient, kafkaProducer kafka.Producer) *DeliveryWorker {
+func NewDeliveryWorker(carrier shipping.Carrier, tracking *services.ShipmentTrackingService) *DeliveryWorker {
 	return &DeliveryWorker{
-		mongoClient:   mongoClient,
-		kafkaProducer: kafkaProducer,
+		carrier:  carrier,
+		tracking: tracking,
 	}
 }
 
@@ -32,94 +33,40 @@ func (w *DeliveryWorker) Topic() string {
 	return kafka.TopicShipmentLabelCreated
 }
 
-// Handle processes a shipment label created event and tracks delivery
+// Handle processes a shipment label created event and records the parcel's first tracking status
 func (w *DeliveryWorker) Handle(ctx context.Context, msg *kafka.Message) error {
-	// Parse the event payload
-	var event struct {
-		EventID        string `json:"event_id"`
-		PrescriptionID string `json:"prescription_id"`
-		PatientID      string `json:"patient_id"`
-		TrackingNumber string `json:"tracking_number"`
-		LabelURL       string `json:"label_url"`
-		CreatedAt      string `json:"created_at"`
-		Timestamp      string `json:"timestamp"`
-	}
+	correlationID := ExtractCorrelationID(msg)
 
-	if err := json.Unmarshal(msg.Value, &event); err != nil {
-		log.Printf("❌ Failed to unmarshal delivery event: %v", err)
+	// Parse the event payload
+	var event events.ShipmentLabelCreated
+	if err := events.Decode(msg.Value, &event); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to unmarshal delivery event: %v", correlationID, err)
 		return err
 	}
 
-	log.Printf("🚚 Tracking delivery for prescription: %s (Tracking: %s)", event.PrescriptionID, event.TrackingNumber)
-
-	// In a real implementation, this worker would:
-	// 1. Poll shipping carrier API for delivery status
-	// 2. Update shipment status in MongoDB
-	// 3. When delivered, emit delivery completed event
+	log.Printf("🚚 [correlation_id=%s] Tracking delivery for prescription: %s (Tracking: %s)", correlationID, event.PrescriptionID, event.TrackingNumber)
 
-	// For now, we'll simulate tracking and mark as delivered after a delay
-	// In production, this would be handled by webhooks from the shipping carrier
-
-	// Update shipment status to "in_transit"
-	shipmentCollection := w.mongoClient.GetCollection("shipments")
-	prescriptionID, err := primitive.ObjectIDFromHex(event.PrescriptionID)
-	if err != nil {
-		log.Printf("❌ Invalid prescription ID format: %s", event.PrescriptionID)
-		return err
+	if event.TrackingNumber == "" {
+		log.Printf("⚠️  [correlation_id=%s] Shipment %s has no tracking number, skipping", correlationID, event.ShipmentID)
+		return nil
 	}
 
-	update := bson.M{
-		"$set": bson.M{
-			"status":     "in_transit",
-			"updated_at": time.Now(),
-		},
-	}
-
-	_, err = shipmentCollection.UpdateOne(
-		ctx,
-		bson.M{"prescription_id": prescriptionID},
-		update,
-	)
+	// The scheduler polls shipments that were never tracked, so a carrier outage here only delays the first status
+	status, err := w.carrier.Track(ctx, event.Carrier, event.TrackingNumber)
 	if err != nil {
-		log.Printf("⚠️  Failed to update shipment status: %v (may not exist yet)", err)
+		log.Printf("⚠️  [correlation_id=%s] Failed to track %s, leaving it to the tracking poll: %v", correlationID, event.TrackingNumber, err)
+		return nil
 	}
 
-	// Update prescription status
-	prescriptionCollection := w.mongoClient.GetCollection("prescriptions")
-	update = bson.M{
-		"$set": bson.M{
-			"status":     "in_transit",
-			"updated_at": time.Now(),
-		},
+	_, _, err = w.tracking.ApplyUpdate(ctx, correlationID, status, models.TrackingSourcePoll)
+	if errors.Is(err, services.ErrShipmentNotFound) {
+		log.Printf("⚠️  [correlation_id=%s] No shipment found for tracking number %s", correlationID, event.TrackingNumber)
+		return nil
 	}
-
-	_, err = prescriptionCollection.UpdateOne(ctx, bson.M{"_id": prescriptionID}, update)
 	if err != nil {
-		log.Printf("❌ Failed to update prescription status: %v", err)
 		return err
 	}
 
-	// Note: In production, delivery would be triggered by a webhook from the carrier
-	// For now, we'll just log that tracking has started
-	// The actual delivery event would be emitted when the carrier confirms delivery
-
-	log.Printf("✅ Delivery tracking started for prescription: %s", event.PrescriptionID)
-
-	// In a real scenario, you would:
-	// 1. Set up webhook listener for carrier delivery notifications
-	// 2. When delivery is confirmed, emit the delivery completed event:
-	//
-	// deliveryEvent := map[string]interface{}{
-	// 	"event_id":        uuid.New().String(),
-	// 	"prescription_id": event.PrescriptionID,
-	// 	"patient_id":      event.PatientID,
-	// 	"tracking_number": event.TrackingNumber,
-	// 	"delivered_at":    time.Now().Format(time.RFC3339),
-	// 	"timestamp":       time.Now().Format(time.RFC3339),
-	// }
-	//
-	// eventBytes, _ := json.Marshal(deliveryEvent)
-	// w.kafkaProducer.Publish(ctx, kafka.TopicShipmentDelivered, event.PrescriptionID, eventBytes)
-
+	log.Printf("✅ [correlation_id=%s] Delivery tracking started for prescription: %s", correlationID, event.PrescriptionID)
 	return nil
 }
@@ -3,12 +3,14 @@ package workers
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"time"
 
 	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
 	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -41,16 +43,8 @@ func (w *EnrollmentWorker) Handle(ctx context.Context, msg *kafka.Message) error
 	correlationID := ExtractCorrelationID(msg)
 
 	// Parse the event payload
-	var event struct {
-		EventID        string `json:"event_id"`
-		CorrelationID  string `json:"correlation_id,omitempty"`
-		PrescriptionID string `json:"prescription_id"`
-		PatientID      string `json:"patient_id"`
-		ValidatedAt    string `json:"validated_at"`
-		Timestamp      string `json:"timestamp"`
-	}
-
-	if err := json.Unmarshal(msg.Value, &event); err != nil {
+	var event events.ValidationCompleted
+	if err := events.Decode(msg.Value, &event); err != nil {
 		log.Printf("❌ [correlation_id=%s] Failed to unmarshal enrollment event: %v", correlationID, err)
 		return err
 	}
@@ -66,14 +60,14 @@ func (w *EnrollmentWorker) Handle(ctx context.Context, msg *kafka.Message) error
 	patientCollection := w.mongoClient.GetCollection("patients")
 	patientID, err := primitive.ObjectIDFromHex(event.PatientID)
 	if err != nil {
-		log.Printf("❌ Invalid patient ID format: %s", event.PatientID)
+		log.Printf("❌ [correlation_id=%s] Invalid patient ID format: %s", correlationID, event.PatientID)
 		return err
 	}
 
 	var patient bson.M
 	err = patientCollection.FindOne(ctx, bson.M{"_id": patientID}).Decode(&patient)
 	if err != nil {
-		log.Printf("❌ Patient not found: %s", event.PatientID)
+		log.Printf("❌ [correlation_id=%s] Patient not found: %s", correlationID, event.PatientID)
 		return err
 	}
 
@@ -95,43 +89,37 @@ func (w *EnrollmentWorker) Handle(ctx context.Context, msg *kafka.Message) error
 
 		_, err = patientCollection.UpdateOne(ctx, bson.M{"_id": patientID}, update)
 		if err != nil {
-			log.Printf("❌ Failed to update patient enrollment status: %v", err)
+			log.Printf("❌ [correlation_id=%s] Failed to update patient enrollment status: %v", correlationID, err)
 			return err
 		}
 
-		log.Printf("✅ Patient enrolled: %s", event.PatientID)
+		log.Printf("✅ [correlation_id=%s] Patient enrolled: %s", correlationID, event.PatientID)
 	} else {
-		log.Printf("ℹ️  Patient already enrolled: %s", event.PatientID)
+		log.Printf("ℹ️  [correlation_id=%s] Patient already enrolled: %s", correlationID, event.PatientID)
 	}
 
 	// Update prescription status
 	prescriptionCollection := w.mongoClient.GetCollection("prescriptions")
 	prescriptionID, err := primitive.ObjectIDFromHex(event.PrescriptionID)
 	if err != nil {
-		log.Printf("❌ Invalid prescription ID format: %s", event.PrescriptionID)
+		log.Printf("❌ [correlation_id=%s] Invalid prescription ID format: %s", correlationID, event.PrescriptionID)
 		return err
 	}
 
-	update := bson.M{
-		"$set": bson.M{
-			"status":     "enrolled",
-			"updated_at": time.Now(),
-		},
-	}
-
-	_, err = prescriptionCollection.UpdateOne(ctx, bson.M{"_id": prescriptionID}, update)
+	_, err = services.ChangePrescriptionStatus(ctx, prescriptionCollection, correlationID, bson.M{"_id": prescriptionID}, models.StatusEnrolled, nil)
 	if err != nil {
-		log.Printf("❌ Failed to update prescription status: %v", err)
+		log.Printf("❌ [correlation_id=%s] Failed to update prescription status: %v", correlationID, err)
 		return err
 	}
 
 	// 8.3.3: Emit enrollment completed event
-	enrollmentEvent := CreateEvent(correlationID, event.PrescriptionID, map[string]interface{}{
-		"patient_id":  event.PatientID,
-		"enrolled_at": time.Now().Format(time.RFC3339),
-	})
+	enrollmentEvent := &events.EnrollmentCompleted{
+		Envelope:   events.NewEnvelope(ctx, correlationID, event.PrescriptionID),
+		PatientID:  event.PatientID,
+		EnrolledAt: time.Now(),
+	}
 
-	if err := PublishEvent(ctx, w.kafkaProducer, kafka.TopicEnrollmentCompleted, event.PrescriptionID, enrollmentEvent); err != nil {
+	if err := events.Publish(ctx, w.kafkaProducer, enrollmentEvent); err != nil {
 		log.Printf("❌ [correlation_id=%s] Failed to publish enrollment completed event: %v", correlationID, err)
 		return err
 	}
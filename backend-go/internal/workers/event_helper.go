@@ -3,86 +3,56 @@ package workers
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
 	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
 )
 
-// EventMetadata represents common metadata for all events
-type EventMetadata struct {
-	EventID        string    `json:"event_id"`
-	CorrelationID  string    `json:"correlation_id,omitempty"`
-	PrescriptionID string    `json:"prescription_id,omitempty"`
-	Timestamp      time.Time `json:"timestamp"`
-}
-
 // ExtractCorrelationID extracts correlation ID from a Kafka message
-// It looks for correlation_id in the message value, or generates a new one
+// A message without one is logged and traced under its event ID (or topic, partition and offset)
+// rather than a fresh ID, so every redelivery of it and everything it causes share one ID that
+// points back at the message.
 func ExtractCorrelationID(msg *kafka.Message) string {
-	var eventData map[string]interface{}
-	if err := json.Unmarshal(msg.Value, &eventData); err == nil {
-		if corrID, ok := eventData["correlation_id"].(string); ok && corrID != "" {
-			return corrID
-		}
-	}
-	// Generate new correlation ID if not found
-	return uuid.New().String()
-}
-
-// CreateEvent creates a new event with proper metadata
-func CreateEvent(correlationID, prescriptionID string, additionalData map[string]interface{}) map[string]interface{} {
-	event := map[string]interface{}{
-		"event_id":        uuid.New().String(),
-		"correlation_id":  correlationID,
-		"prescription_id": prescriptionID,
-		"timestamp":       time.Now().Format(time.RFC3339),
-	}
-
-	// Merge additional data
-	for k, v := range additionalData {
-		event[k] = v
+	if envelope, err := events.ReadEnvelope(msg.Value); err == nil && envelope.CorrelationID != "" {
+		return envelope.CorrelationID
 	}
-
-	return event
+	correlationID := MessageEventID(msg)
+	log.Printf("⚠️  [correlation_id=%s] Message on %s has no correlation_id, tracing it by its event ID", correlationID, msg.Topic)
+	return correlationID
 }
 
-// PublishEvent publishes an event to Kafka with proper error handling
-func PublishEvent(ctx context.Context, producer kafka.Producer, topic, prescriptionID string, event map[string]interface{}) error {
-	eventBytes, err := json.Marshal(event)
-	if err != nil {
-		log.Printf("❌ Failed to marshal event: %v", err)
-		return err
-	}
-
-	if err := producer.Publish(ctx, topic, prescriptionID, eventBytes); err != nil {
-		log.Printf("❌ Failed to publish event to topic %s: %v", topic, err)
-		return err
-	}
-
-	correlationID := ""
-	if id, ok := event["correlation_id"].(string); ok {
-		correlationID = id
-	}
-
-	log.Printf("✅ Published event: topic=%s, prescription_id=%s, correlation_id=%s", topic, prescriptionID, correlationID)
-	return nil
+// PublishDURReviewRequested publishes a prescription.dur_review.requested event for a newly opened DUR review
+func PublishDURReviewRequested(ctx context.Context, producer kafka.Producer, correlationID string, review *models.DURReview) error {
+	return events.Publish(ctx, producer, &events.DURReviewRequested{
+		Envelope:     events.NewEnvelope(ctx, correlationID, review.PrescriptionID.Hex()),
+		ReviewID:     review.ID.Hex(),
+		PatientID:    review.PatientID,
+		NDC:          review.NDC,
+		Severity:     string(review.Severity),
+		FindingTypes: services.DURFindingTypes(review),
+		RequestedAt:  review.CreatedAt,
+	})
 }
 
 // PublishToDeadLetterQueue publishes a failed message to the dead letter queue
 func PublishToDeadLetterQueue(ctx context.Context, producer kafka.Producer, originalMsg *kafka.Message, errorMsg string) error {
-	dlqEvent := map[string]interface{}{
-		"event_id":       uuid.New().String(),
-		"original_topic": originalMsg.Topic,
-		"original_key":   string(originalMsg.Key),
-		"original_value": string(originalMsg.Value),
-		"error":          errorMsg,
-		"failed_at":      time.Now().Format(time.RFC3339),
-		"partition":      originalMsg.Partition,
-		"offset":         originalMsg.Offset,
+	envelope := events.NewEnvelope(ctx, "", string(originalMsg.Key))
+	if original, err := events.ReadEnvelope(originalMsg.Value); err == nil {
+		envelope.CorrelationID = original.CorrelationID
 	}
 
-	return PublishEvent(ctx, producer, kafka.TopicDeadLetterQueue, string(originalMsg.Key), dlqEvent)
+	return events.Publish(ctx, producer, &events.DeadLetter{
+		Envelope:      envelope,
+		OriginalTopic: originalMsg.Topic,
+		OriginalKey:   string(originalMsg.Key),
+		OriginalValue: string(originalMsg.Value),
+		Error:         errorMsg,
+		FailedAt:      time.Now(),
+		Partition:     originalMsg.Partition,
+		Offset:        originalMsg.Offset,
+	})
 }
@@ -0,0 +1,118 @@
+// Package workers provides worker handlers for processing Kafka events
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// envelopeFields are the event fields stored as event_log columns rather than in the payload
+var envelopeFields = []string{
+	"event_id",
+	"schema_version",
+	"correlation_id",
+	"causation_id",
+	"prescription_id",
+	"producer",
+	"occurred_at",
+}
+
+// EventLogWorker records every event published on a topic in event_log, which the correlation trace reads
+// One worker is registered per topic; it runs alongside the topic's other handlers.
+type EventLogWorker struct {
+	topic       string
+	mongoClient *database.MongoClient
+}
+
+// NewEventLogWorkers creates an event log worker for every topic with an event contract except the dead letter queue
+func NewEventLogWorkers(mongoClient *database.MongoClient) []*EventLogWorker {
+	topics := events.Topics()
+	eventLogWorkers := make([]*EventLogWorker, 0, len(topics))
+	for _, topic := range topics {
+		if topic == kafka.TopicDeadLetterQueue {
+			continue
+		}
+		eventLogWorkers = append(eventLogWorkers, &EventLogWorker{
+			topic:       topic,
+			mongoClient: mongoClient,
+		})
+	}
+	return eventLogWorkers
+}
+
+// Topic returns the Kafka topic this handler consumes from
+func (w *EventLogWorker) Topic() string {
+	return w.topic
+}
+
+// Handle records the event in event_log; a redelivered event is already there and is skipped
+func (w *EventLogWorker) Handle(ctx context.Context, msg *kafka.Message) error {
+	entry, err := NewEventLogEntry(msg)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to read %s event for the event log: %v", ExtractCorrelationID(msg), w.topic, err)
+		return err
+	}
+
+	if _, err := w.mongoClient.GetCollection("event_log").InsertOne(ctx, entry); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		log.Printf("❌ [correlation_id=%s] Failed to record event %s in the event log: %v", entry.CorrelationID, entry.EventID, err)
+		return err
+	}
+	return nil
+}
+
+// NewEventLogEntry builds the event_log entry for a consumed message
+// Events without an event_id are logged under MessageEventID and without a correlation ID under the
+// ID ExtractCorrelationID traces them by.
+func NewEventLogEntry(msg *kafka.Message) (*models.EventLogEntry, error) {
+	envelope, err := events.ReadEnvelope(msg.Value)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(msg.Value, &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event payload: %w", err)
+	}
+	for _, field := range envelopeFields {
+		delete(payload, field)
+	}
+
+	entry := &models.EventLogEntry{
+		EventID:        envelope.EventID,
+		Topic:          msg.Topic,
+		SchemaVersion:  envelope.SchemaVersion,
+		CorrelationID:  envelope.CorrelationID,
+		CausationID:    envelope.CausationID,
+		PrescriptionID: envelope.PrescriptionID,
+		Producer:       envelope.Producer,
+		OccurredAt:     envelope.OccurredAt,
+		Payload:        payload,
+		Partition:      msg.Partition,
+		Offset:         msg.Offset,
+	}
+	entry.RecordedAt = time.Now()
+	if entry.EventID == "" {
+		entry.EventID = MessageEventID(msg)
+	}
+	if entry.CorrelationID == "" {
+		entry.CorrelationID = ExtractCorrelationID(msg)
+	}
+	if entry.SchemaVersion == 0 {
+		entry.SchemaVersion = 1
+	}
+	if entry.OccurredAt.IsZero() {
+		entry.OccurredAt = entry.RecordedAt
+	}
+	return entry, nil
+}
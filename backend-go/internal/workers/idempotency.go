@@ -0,0 +1,137 @@
+// Package workers provides worker handlers for processing Kafka events
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+)
+
+// IdempotentHandler runs a handler at most once per event
+// It claims the event in the processed-event ledger before the handler runs: redeliveries of a completed
+// event are skipped, and a redelivery after a failure or crash resumes with the steps the handler
+// recorded through RecordOutcome.
+type IdempotentHandler struct {
+	handler Handler
+	events  *services.ProcessedEventService
+}
+
+// claimKey is the context key of the event claim a handler runs under
+type claimKey struct{}
+
+// Idempotent returns a registry middleware that wraps each handler in an IdempotentHandler
+func Idempotent(events *services.ProcessedEventService) func(Handler) Handler {
+	return func(handler Handler) Handler {
+		return NewIdempotentHandler(handler, events)
+	}
+}
+
+// NewIdempotentHandler wraps a handler so it runs at most once per event
+func NewIdempotentHandler(handler Handler, events *services.ProcessedEventService) *IdempotentHandler {
+	return &IdempotentHandler{
+		handler: handler,
+		events:  events,
+	}
+}
+
+// Topic returns the Kafka topic of the wrapped handler
+func (h *IdempotentHandler) Topic() string {
+	return h.handler.Topic()
+}
+
+// Unwrap returns the wrapped handler
+func (h *IdempotentHandler) Unwrap() Handler {
+	return h.handler
+}
+
+// Handle claims the event and runs the wrapped handler unless it already processed the event
+// A claim held by another consumer is waited out: it is released when that consumer finishes, or
+// expires if it crashed, and the event is then skipped or resumed here rather than dead-lettered.
+func (h *IdempotentHandler) Handle(ctx context.Context, msg *kafka.Message) error {
+	name := HandlerName(h.handler)
+	eventID := MessageEventID(msg)
+	// Events without a correlation ID are traced by their event ID, as ExtractCorrelationID does
+	cause, _ := events.Cause(ctx)
+	correlationID := cause.CorrelationID
+	if correlationID == "" {
+		correlationID = eventID
+	}
+
+	claim, err := h.events.AwaitClaim(ctx, msg.Topic, name, eventID)
+	if errors.Is(err, services.ErrEventAlreadyProcessed) {
+		log.Printf("⏭️  [correlation_id=%s] Skipping duplicate event %s for %s (topic=%s, offset=%d)", correlationID, eventID, name, msg.Topic, msg.Offset)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to claim event %s: %w", eventID, err)
+	}
+	if event := claim.Event(); event.Attempts > 1 {
+		log.Printf("🔁 [correlation_id=%s] Resuming event %s for %s (attempt %d, %d steps recorded)", correlationID, eventID, name, event.Attempts, len(event.Outcome))
+	}
+
+	handlerErr := h.handler.Handle(context.WithValue(ctx, claimKey{}, claim), msg)
+
+	// Record the result even if the message's context ended while the handler ran
+	recordCtx := context.WithoutCancel(ctx)
+	if handlerErr != nil {
+		if err := claim.Fail(recordCtx, handlerErr); err != nil {
+			log.Printf("⚠️  [correlation_id=%s] Failed to record failure of event %s for %s: %v", correlationID, eventID, name, err)
+		}
+		return handlerErr
+	}
+	if err := claim.Complete(recordCtx); err != nil {
+		// The work is done; the next redelivery resumes from the recorded steps
+		log.Printf("⚠️  [correlation_id=%s] Failed to mark event %s processed for %s: %v", correlationID, eventID, name, err)
+	}
+	return nil
+}
+
+// RecordOutcome saves the result of a handler step for the event being handled, so a retry can skip it
+// Outside an IdempotentHandler it does nothing.
+func RecordOutcome(ctx context.Context, step, value string) error {
+	claim, ok := ctx.Value(claimKey{}).(*services.EventClaim)
+	if !ok {
+		return nil
+	}
+	return claim.Record(ctx, step, value)
+}
+
+// RecordedOutcome returns the result a previous attempt at the event recorded for a step
+func RecordedOutcome(ctx context.Context, step string) (string, bool) {
+	claim, ok := ctx.Value(claimKey{}).(*services.EventClaim)
+	if !ok {
+		return "", false
+	}
+	return claim.Outcome(step)
+}
+
+// MessageEventID returns the event_id of a message, or its topic, partition and offset if it has none
+func MessageEventID(msg *kafka.Message) string {
+	if envelope, err := events.ReadEnvelope(msg.Value); err == nil && envelope.EventID != "" {
+		return envelope.EventID
+	}
+	return fmt.Sprintf("%s:%d:%d", msg.Topic, msg.Partition, msg.Offset)
+}
+
+// HandlerName returns the type name of a handler, looking through wrappers such as IdempotentHandler
+func HandlerName(handler Handler) string {
+	for {
+		wrapper, ok := handler.(interface{ Unwrap() Handler })
+		if !ok {
+			break
+		}
+		handler = wrapper.Unwrap()
+	}
+	name := fmt.Sprintf("%T", handler)
+	name = strings.TrimPrefix(name, "*")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,332 @@
+// Package workers provides worker handlers for processing Kafka events
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/notifications"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// notificationTemplates is the template each notified topic renders
+var notificationTemplates = map[string]string{
+	kafka.TopicPaymentLinkCreated:    notifications.TemplatePaymentLink,
+	kafka.TopicShipmentLabelCreated:  notifications.TemplateShipmentShipped,
+	kafka.TopicShipmentDelivered:     notifications.TemplateShipmentDelivered,
+	kafka.TopicEnrollmentLinkCreated: notifications.TemplateEnrollmentLink,
+}
+
+// NotificationSettings configures the content of patient notifications
+type NotificationSettings struct {
+	Support string // how to reach the pharmacy team, e.g. "PhilMyMeds support at (555) 010-2000"
+}
+
+// NotificationWorker notifies the patient by email or text when an event they need to know about happens
+// One worker is registered per topic; it runs alongside the topic's other handlers.
+type NotificationWorker struct {
+	topic         string
+	template      string
+	mongoClient   *database.MongoClient
+	notifications *services.NotificationService
+	settings      NotificationSettings
+}
+
+// NewNotificationWorkers creates a notification worker for each notified topic
+func NewNotificationWorkers(mongoClient *database.MongoClient, notificationService *services.NotificationService, settings NotificationSettings) []*NotificationWorker {
+	topics := []string{
+		kafka.TopicPaymentLinkCreated,
+		kafka.TopicShipmentLabelCreated,
+		kafka.TopicShipmentDelivered,
+		kafka.TopicEnrollmentLinkCreated,
+	}
+	notificationWorkers := make([]*NotificationWorker, 0, len(topics))
+	for _, topic := range topics {
+		notificationWorkers = append(notificationWorkers, &NotificationWorker{
+			topic:         topic,
+			template:      notificationTemplates[topic],
+			mongoClient:   mongoClient,
+			notifications: notificationService,
+			settings:      settings,
+		})
+	}
+	return notificationWorkers
+}
+
+// Topic returns the Kafka topic this handler consumes from
+func (w *NotificationWorker) Topic() string {
+	return w.topic
+}
+
+// notificationEvent holds the fields of the notified events; each topic sets its own
+type notificationEvent struct {
+	EventID        string
+	PrescriptionID string
+	PatientID      string
+
+	// payment.link.created
+	PaymentLinkURL string
+	Amount         float64
+	CostBreakdown  *models.CostBreakdown
+	LinkExpiresAt  time.Time
+
+	// shipment.label.created and shipment.delivered
+	ShipmentID string
+
+	// patient.enrollment.link.created
+	Email         string
+	Phone         string
+	SMSConsent    bool // the prescriber recorded the patient's consent to texts
+	EnrollmentURL string
+	ExpiresAt     time.Time
+}
+
+// decodeNotificationEvent decodes a message into the fields the notified topic carries
+func decodeNotificationEvent(msg *kafka.Message) (*notificationEvent, error) {
+	typed, err := events.New(msg.Topic)
+	if err != nil {
+		return nil, err
+	}
+	if err := events.Decode(msg.Value, typed); err != nil {
+		return nil, err
+	}
+
+	meta := typed.Metadata()
+	event := &notificationEvent{EventID: meta.EventID, PrescriptionID: meta.PrescriptionID}
+	switch e := typed.(type) {
+	case *events.PaymentLinkCreated:
+		event.PatientID = e.PatientID
+		event.PaymentLinkURL = e.PaymentLinkURL
+		event.Amount = e.Amount
+		event.CostBreakdown = e.CostBreakdown
+		event.LinkExpiresAt = e.LinkExpiresAt
+	case *events.ShipmentLabelCreated:
+		event.PatientID = e.PatientID
+		event.ShipmentID = e.ShipmentID
+	case *events.ShipmentDelivered:
+		event.PatientID = e.PatientID
+		event.ShipmentID = e.ShipmentID
+	case *events.EnrollmentLinkCreated:
+		event.PatientID = e.PatientID
+		event.Email = e.Email
+		event.Phone = e.Phone
+		event.SMSConsent = e.SMSConsent
+		event.EnrollmentURL = e.EnrollmentURL
+		event.ExpiresAt = e.ExpiresAt
+	default:
+		return nil, fmt.Errorf("no notification for %s events", msg.Topic)
+	}
+	return event, nil
+}
+
+// Handle renders the topic's template for the event and sends it on the patient's preferred channel
+// A failed send is recorded on the notification and retried or sent on another channel by the
+// scheduler, so it does not fail the message. Events for patients who cannot be reached are skipped.
+func (w *NotificationWorker) Handle(ctx context.Context, msg *kafka.Message) error {
+	correlationID := ExtractCorrelationID(msg)
+
+	event, err := decodeNotificationEvent(msg)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to decode %s notification event: %v", correlationID, w.topic, err)
+		return err
+	}
+	if event.EventID == "" {
+		// Fall back to the message position so a redelivery still dedupes
+		event.EventID = MessageEventID(msg)
+	}
+
+	prescription, err := w.prescription(ctx, event.PrescriptionID)
+	if err != nil {
+		return err
+	}
+	if event.PatientID == "" && prescription != nil {
+		event.PatientID = prescription.PatientID
+	}
+
+	contact, prefs, err := w.contact(ctx, event, prescription)
+	if err != nil {
+		return err
+	}
+	routes := services.NotificationRoutes(w.template, contact, prefs)
+	if len(routes) == 0 {
+		log.Printf("⚠️  [correlation_id=%s] No channel to reach patient %s, skipping %s notification", correlationID, event.PatientID, w.template)
+		return nil
+	}
+
+	data := notifications.Data{PatientName: contact.Name, Support: w.settings.Support}
+	if prescription != nil {
+		data.DrugName = prescription.Medication.Name
+		data.RxNumber = prescription.PrescriptionID
+	}
+	ok, err := w.templateData(ctx, event, &data)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Printf("⚠️  [correlation_id=%s] %s event %s is missing notification details, skipping", correlationID, w.topic, event.EventID)
+		return nil
+	}
+
+	message, err := notifications.Render(w.template, data)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to render %s: %v", correlationID, w.template, err)
+		return err
+	}
+
+	notification, err := w.notifications.Send(ctx, &models.Notification{
+		DedupeKey:       services.NotificationDedupeKey(event.EventID, message.Template),
+		EventID:         event.EventID,
+		Topic:           w.topic,
+		Template:        message.Template,
+		TemplateVersion: message.Version,
+		PrescriptionID:  event.PrescriptionID,
+		PatientID:       event.PatientID,
+		Subject:         message.Subject,
+		TextBody:        message.Text,
+		HTMLBody:        message.HTML,
+		SMSBody:         message.SMS,
+		CorrelationID:   correlationID,
+		CausationID:     event.EventID,
+	}, routes)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to record %s notification: %v", correlationID, w.template, err)
+		return err
+	}
+
+	switch notification.Status {
+	case models.NotificationSent:
+		log.Printf("✉️  [correlation_id=%s] Sent %s notification %s by %s for prescription: %s", correlationID, w.template, notification.ID.Hex(), notification.Channel, event.PrescriptionID)
+	case models.NotificationFailed:
+		log.Printf("❌ [correlation_id=%s] %s notification %s failed: %s", correlationID, w.template, notification.ID.Hex(), notification.LastError)
+	default:
+		log.Printf("⚠️  [correlation_id=%s] %s notification %s is %s on %s (attempt %d): %s", correlationID, w.template, notification.ID.Hex(), notification.Status, notification.Channel, notification.Attempts, notification.LastError)
+	}
+	return nil
+}
+
+// prescription loads the prescription an event is about, or nil if the event has none
+func (w *NotificationWorker) prescription(ctx context.Context, id string) (*models.Prescription, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	var prescription models.Prescription
+	err = w.mongoClient.GetCollection("prescriptions").FindOne(ctx, bson.M{"_id": objectID}).Decode(&prescription)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prescription %s: %w", id, err)
+	}
+	return &prescription, nil
+}
+
+// contact returns how to reach the patient and their communication preferences
+// Enrollment links go to the address and number in the event, as the patient has no record yet;
+// everything else goes to the enrolled patient's record.
+func (w *NotificationWorker) contact(ctx context.Context, event *notificationEvent, prescription *models.Prescription) (services.PatientContact, *models.CommunicationPreferences, error) {
+	contact := services.PatientContact{Email: event.Email, Phone: event.Phone}
+	if prescription != nil {
+		contact.Name = prescription.Patient.FirstName
+	}
+
+	if w.topic == kafka.TopicEnrollmentLinkCreated {
+		var prefs *models.CommunicationPreferences
+		if event.SMSConsent {
+			prefs = &models.CommunicationPreferences{SMS: models.ChannelConsent{Status: models.ConsentGranted, Source: models.ConsentSourcePatient}}
+		}
+		return contact, prefs, nil
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(event.PatientID)
+	if err != nil {
+		return contact, nil, nil
+	}
+	var patient models.Patient
+	err = w.mongoClient.GetCollection("patients").FindOne(ctx, bson.M{"_id": objectID}).Decode(&patient)
+	if err == mongo.ErrNoDocuments {
+		return contact, nil, nil
+	}
+	if err != nil {
+		return contact, nil, fmt.Errorf("failed to load patient %s: %w", event.PatientID, err)
+	}
+	contact.Email = patient.Email
+	contact.Phone = patient.Phone
+	if patient.Name.First != "" {
+		contact.Name = patient.Name.First
+	}
+	return contact, patient.Communication, nil
+}
+
+// templateData fills in the topic's section of the template data
+// It returns false when the event lacks what the template needs.
+func (w *NotificationWorker) templateData(ctx context.Context, event *notificationEvent, data *notifications.Data) (bool, error) {
+	switch w.topic {
+	case kafka.TopicPaymentLinkCreated:
+		if event.PaymentLinkURL == "" || event.LinkExpiresAt.IsZero() {
+			return false, nil
+		}
+		data.Payment = &notifications.PaymentDetails{
+			Amount:    event.Amount,
+			URL:       event.PaymentLinkURL,
+			ExpiresAt: event.LinkExpiresAt,
+		}
+		if event.CostBreakdown != nil {
+			data.Payment.Breakdown = &notifications.CostBreakdown{
+				TotalDrugCost:        event.CostBreakdown.TotalDrugCost,
+				InsuranceCovered:     event.CostBreakdown.InsuranceCovered,
+				ManufacturerDiscount: event.CostBreakdown.ManufacturerDiscount,
+			}
+		}
+		return true, nil
+
+	case kafka.TopicShipmentLabelCreated, kafka.TopicShipmentDelivered:
+		shipmentID, err := primitive.ObjectIDFromHex(event.ShipmentID)
+		if err != nil {
+			return false, nil
+		}
+		var shipment models.Shipment
+		err = w.mongoClient.GetCollection("shipments").FindOne(ctx, bson.M{"_id": shipmentID}).Decode(&shipment)
+		if err == mongo.ErrNoDocuments {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to load shipment %s: %w", event.ShipmentID, err)
+		}
+		data.Shipment = &notifications.ShipmentDetails{
+			Carrier:        shipment.Carrier,
+			Service:        shipment.ServiceLevel,
+			TrackingNumber: shipment.TrackingNumber,
+			TrackingURL:    shipment.TrackingURL,
+			DeliverBy:      shipment.DeliverBy,
+			ColdChain:      shipment.ColdChain != nil,
+		}
+		if shipment.DeliveredAt != nil {
+			data.Shipment.DeliveredAt = *shipment.DeliveredAt
+		}
+		if n := len(shipment.TrackingEvents); n > 0 && shipment.Status == models.ShipmentDelivered {
+			data.Shipment.Details = shipment.TrackingEvents[n-1].Details
+		}
+		return true, nil
+
+	case kafka.TopicEnrollmentLinkCreated:
+		if event.EnrollmentURL == "" || event.ExpiresAt.IsZero() {
+			return false, nil
+		}
+		data.Enrollment = &notifications.EnrollmentDetails{
+			URL:       event.EnrollmentURL,
+			ExpiresAt: event.ExpiresAt,
+		}
+		return true, nil
+	}
+	return false, nil
+}
@@ -3,28 +3,47 @@ package workers
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
 	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
 	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/payments"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// outcomePaymentID is the processed-event step recording the payment a checkout link was created for
+const outcomePaymentID = "payment_id"
+
 // PaymentWorker handles payment processing events
 type PaymentWorker struct {
 	mongoClient   *database.MongoClient
 	kafkaProducer kafka.Producer
+	provider      payments.PaymentProvider
+	links         PaymentLinkSettings
+}
+
+// PaymentLinkSettings configures the checkout links sent to patients
+type PaymentLinkSettings struct {
+	TTL        time.Duration
+	SuccessURL string
+	CancelURL  string
 }
 
 // NewPaymentWorker creates a new payment worker
-func NewPaymentWorker(mongoClient *database.MongoClient, kafkaProducer kafka.Producer) *PaymentWorker {
+func NewPaymentWorker(mongoClient *database.MongoClient, kafkaProducer kafka.Producer, provider payments.PaymentProvider, links PaymentLinkSettings) *PaymentWorker {
 	return &PaymentWorker{
 		mongoClient:   mongoClient,
 		kafkaProducer: kafkaProducer,
+		provider:      provider,
+		links:         links,
 	}
 }
 
@@ -33,149 +52,187 @@ func (w *PaymentWorker) Topic() string {
 	return kafka.TopicAdjudicationCompleted
 }
 
-// Handle processes an adjudication completed event and creates a payment link
+// Handle processes an adjudication completed event and creates a checkout link with the payment provider
 func (w *PaymentWorker) Handle(ctx context.Context, msg *kafka.Message) error {
 	// Parse the event payload
-	var event struct {
-		EventID            string                 `json:"event_id"`
-		PrescriptionID     string                 `json:"prescription_id"`
-		PatientID          string                 `json:"patient_id"`
-		PharmacyID         string                 `json:"pharmacy_id"`
-		AdjudicationResult map[string]interface{} `json:"adjudication_result"`
-		AdjudicatedAt      string                 `json:"adjudicated_at"`
-		Timestamp          string                 `json:"timestamp"`
-	}
-
-	if err := json.Unmarshal(msg.Value, &event); err != nil {
-		log.Printf("❌ Failed to unmarshal payment event: %v", err)
+	var event events.AdjudicationCompleted
+	if err := events.Decode(msg.Value, &event); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to unmarshal payment event: %v", ExtractCorrelationID(msg), err)
 		return err
 	}
 
-	log.Printf("💵 Processing payment for prescription: %s", event.PrescriptionID)
+	correlationID := event.CorrelationID
+	if correlationID == "" {
+		correlationID = ExtractCorrelationID(msg)
+	}
+
+	log.Printf("💵 [correlation_id=%s] Processing payment for prescription: %s", correlationID, event.PrescriptionID)
 
-	// Extract copay amount from adjudication result
+	// Extract copay amount: the final copay after manufacturer programs, else the primary claim copay
 	copayAmount := 0.0
-	if result, ok := event.AdjudicationResult["copay_amount"].(float64); ok {
-		copayAmount = result
+	if event.FinalCopay != nil {
+		copayAmount = *event.FinalCopay
+	} else {
+		copayAmount = event.AdjudicationResult.CopayAmount
 	}
 
 	// If copay is 0, skip payment and go directly to shipping
 	if copayAmount == 0 {
-		log.Printf("ℹ️  No copay required, skipping payment for prescription: %s", event.PrescriptionID)
+		log.Printf("ℹ️  [correlation_id=%s] No copay required, skipping payment for prescription: %s", correlationID, event.PrescriptionID)
 
 		// Update prescription status
 		prescriptionCollection := w.mongoClient.GetCollection("prescriptions")
 		prescriptionID, err := primitive.ObjectIDFromHex(event.PrescriptionID)
 		if err != nil {
-			log.Printf("❌ Invalid prescription ID format: %s", event.PrescriptionID)
+			log.Printf("❌ [correlation_id=%s] Invalid prescription ID format: %s", correlationID, event.PrescriptionID)
 			return err
 		}
 
-		update := bson.M{
-			"$set": bson.M{
-				"status":     "payment_waived",
-				"updated_at": time.Now(),
-			},
-		}
-
-		_, err = prescriptionCollection.UpdateOne(ctx, bson.M{"_id": prescriptionID}, update)
+		_, err = services.ChangePrescriptionStatus(ctx, prescriptionCollection, correlationID, bson.M{"_id": prescriptionID}, models.StatusPaymentWaived, nil)
 		if err != nil {
-			log.Printf("❌ Failed to update prescription status: %v", err)
+			log.Printf("❌ [correlation_id=%s] Failed to update prescription status: %v", correlationID, err)
 			return err
 		}
 
 		// Emit payment completed event (waived)
-		paymentEvent := map[string]interface{}{
-			"event_id":        uuid.New().String(),
-			"prescription_id": event.PrescriptionID,
-			"patient_id":      event.PatientID,
-			"amount":          0.0,
-			"status":          "waived",
-			"completed_at":    time.Now().Format(time.RFC3339),
-			"timestamp":       time.Now().Format(time.RFC3339),
+		paymentEvent := &events.PaymentCompleted{
+			Envelope:    events.NewEnvelope(ctx, correlationID, event.PrescriptionID),
+			PatientID:   event.PatientID,
+			Amount:      0,
+			Status:      "waived",
+			CompletedAt: time.Now(),
 		}
 
-		eventBytes, err := json.Marshal(paymentEvent)
-		if err != nil {
-			log.Printf("❌ Failed to marshal payment event: %v", err)
+		if err := events.Publish(ctx, w.kafkaProducer, paymentEvent); err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to publish payment completed event: %v", correlationID, err)
 			return err
 		}
 
-		if err := w.kafkaProducer.Publish(ctx, kafka.TopicPaymentCompleted, event.PrescriptionID, eventBytes); err != nil {
-			log.Printf("❌ Failed to publish payment completed event: %v", err)
+		return nil
+	}
+
+	prescriptionID, err := primitive.ObjectIDFromHex(event.PrescriptionID)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Invalid prescription ID format: %s", correlationID, event.PrescriptionID)
+		return err
+	}
+
+	// A retry of an event that already created its link only finishes publishing it
+	paymentCollection := w.mongoClient.GetCollection("payments")
+	if paymentID, ok := RecordedOutcome(ctx, outcomePaymentID); ok {
+		objectID, err := primitive.ObjectIDFromHex(paymentID)
+		if err != nil {
+			return fmt.Errorf("invalid recorded payment ID %q: %w", paymentID, err)
+		}
+		var payment models.Payment
+		if err := paymentCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&payment); err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to load recorded payment %s: %v", correlationID, paymentID, err)
 			return err
 		}
+		log.Printf("🔁 [correlation_id=%s] Resuming payment link %s for prescription: %s", correlationID, paymentID, event.PrescriptionID)
+		return w.publishLink(ctx, correlationID, prescriptionID, &payment)
+	}
 
+	// A new event for a prescription with a pending link must not send the patient a second link
+	var existing models.Payment
+	err = paymentCollection.FindOne(ctx, bson.M{"prescription_id": event.PrescriptionID, "status": models.PaymentPending}).Decode(&existing)
+	if err == nil {
+		log.Printf("ℹ️  [correlation_id=%s] Payment %s already pending for prescription: %s", correlationID, existing.ID.Hex(), event.PrescriptionID)
 		return nil
 	}
+	if err != mongo.ErrNoDocuments {
+		log.Printf("❌ [correlation_id=%s] Failed to look up pending payment: %v", correlationID, err)
+		return err
+	}
 
-	// Create payment link (simplified - actual implementation will integrate with Stripe)
-	paymentLinkID := uuid.New().String()
-	paymentLinkURL := "https://pay.phil-my-meds.com/" + paymentLinkID
+	// Create the checkout link with the payment provider
+	now := time.Now()
+	payment := models.Payment{
+		ID:             primitive.NewObjectID(),
+		PrescriptionID: event.PrescriptionID,
+		PatientID:      event.PatientID,
+		Status:         models.PaymentPending,
+		Amount:         copayAmount,
+		Currency:       "usd",
+		Breakdown:      event.CostBreakdown,
+		Provider:       w.provider.Name(),
+		LinkExpiresAt:  now.Add(w.links.TTL),
+		CorrelationID:  correlationID,
+		CausationID:    events.CausationID(ctx),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
 
-	// Store payment link in MongoDB
-	paymentCollection := w.mongoClient.GetCollection("payments")
-	paymentDoc := bson.M{
-		"prescription_id":  event.PrescriptionID,
-		"patient_id":       event.PatientID,
-		"amount":           copayAmount,
-		"payment_link_id":  paymentLinkID,
-		"payment_link_url": paymentLinkURL,
-		"status":           "pending",
-		"created_at":       time.Now(),
-		"updated_at":       time.Now(),
-	}
-
-	_, err := paymentCollection.InsertOne(ctx, paymentDoc)
+	session, err := w.provider.CreateCheckoutLink(ctx, payments.CheckoutRequest{
+		PaymentID:      payment.ID.Hex(),
+		PrescriptionID: event.PrescriptionID,
+		PatientID:      event.PatientID,
+		Amount:         copayAmount,
+		Currency:       payment.Currency,
+		Description:    "Prescription copay",
+		SuccessURL:     w.links.SuccessURL,
+		CancelURL:      w.links.CancelURL,
+		ExpiresAt:      payment.LinkExpiresAt,
+		IdempotencyKey: payments.CheckoutIdempotencyKey(event.PrescriptionID, event.EventID, event.AdjudicationResult.ClaimID, copayAmount),
+	})
 	if err != nil {
-		log.Printf("❌ Failed to create payment link: %v", err)
+		log.Printf("❌ [correlation_id=%s] Failed to create checkout link: %v", correlationID, err)
 		return err
 	}
 
-	// Update prescription status
-	prescriptionCollection := w.mongoClient.GetCollection("prescriptions")
-	prescriptionID, err := primitive.ObjectIDFromHex(event.PrescriptionID)
-	if err != nil {
-		log.Printf("❌ Invalid prescription ID format: %s", event.PrescriptionID)
+	payment.ProviderSessionID = session.ID
+	payment.ProviderPaymentIntentID = session.PaymentIntentID
+	payment.PaymentLinkURL = session.URL
+	if !session.ExpiresAt.IsZero() {
+		payment.LinkExpiresAt = session.ExpiresAt
+	}
+
+	// Store payment link in MongoDB
+	if _, err := paymentCollection.InsertOne(ctx, payment); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to create payment link: %v", correlationID, err)
+		return err
+	}
+	if err := services.RecordPaymentChange(ctx, correlationID, &payment, audit.ActionCreate); err != nil {
 		return err
 	}
 
-	update := bson.M{
-		"$set": bson.M{
-			"status":     "awaiting_payment",
-			"updated_at": time.Now(),
-		},
+	// A retry of this event picks up from here rather than creating another link
+	if err := RecordOutcome(ctx, outcomePaymentID, payment.ID.Hex()); err != nil {
+		log.Printf("⚠️  [correlation_id=%s] Failed to record payment %s for the event: %v", correlationID, payment.ID.Hex(), err)
 	}
 
-	_, err = prescriptionCollection.UpdateOne(ctx, bson.M{"_id": prescriptionID}, update)
+	return w.publishLink(ctx, correlationID, prescriptionID, &payment)
+}
+
+// publishLink moves the prescription to awaiting payment and publishes payment.link.created for a recorded payment
+func (w *PaymentWorker) publishLink(ctx context.Context, correlationID string, prescriptionID primitive.ObjectID, payment *models.Payment) error {
+	// Update prescription status
+	_, err := services.ChangePrescriptionStatus(ctx, w.mongoClient.GetCollection("prescriptions"), correlationID,
+		bson.M{"_id": prescriptionID}, models.StatusAwaitingPayment, nil)
 	if err != nil {
-		log.Printf("❌ Failed to update prescription status: %v", err)
+		log.Printf("❌ [correlation_id=%s] Failed to update prescription status: %v", correlationID, err)
 		return err
 	}
 
 	// Emit payment link created event
-	paymentLinkEvent := map[string]interface{}{
-		"event_id":         uuid.New().String(),
-		"prescription_id":  event.PrescriptionID,
-		"patient_id":       event.PatientID,
-		"payment_link_id":  paymentLinkID,
-		"payment_link_url": paymentLinkURL,
-		"amount":           copayAmount,
-		"created_at":       time.Now().Format(time.RFC3339),
-		"timestamp":        time.Now().Format(time.RFC3339),
-	}
-
-	eventBytes, err := json.Marshal(paymentLinkEvent)
-	if err != nil {
-		log.Printf("❌ Failed to marshal payment link event: %v", err)
-		return err
+	paymentLinkEvent := &events.PaymentLinkCreated{
+		Envelope:       events.NewEnvelope(ctx, correlationID, payment.PrescriptionID),
+		PatientID:      payment.PatientID,
+		PaymentID:      payment.ID.Hex(),
+		Provider:       payment.Provider,
+		PaymentLinkID:  payment.ProviderSessionID,
+		PaymentLinkURL: payment.PaymentLinkURL,
+		Amount:         payment.Amount,
+		CostBreakdown:  payment.Breakdown,
+		LinkExpiresAt:  payment.LinkExpiresAt,
+		CreatedAt:      payment.CreatedAt,
 	}
 
-	if err := w.kafkaProducer.Publish(ctx, kafka.TopicPaymentLinkCreated, event.PrescriptionID, eventBytes); err != nil {
-		log.Printf("❌ Failed to publish payment link created event: %v", err)
+	if err := events.Publish(ctx, w.kafkaProducer, paymentLinkEvent); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to publish payment link created event: %v", correlationID, err)
 		return err
 	}
 
-	log.Printf("✅ Payment link created for prescription: %s (Amount: $%.2f)", event.PrescriptionID, copayAmount)
+	log.Printf("✅ [correlation_id=%s] Payment link created for prescription: %s (Amount: $%.2f)", correlationID, payment.PrescriptionID, payment.Amount)
 	return nil
 }
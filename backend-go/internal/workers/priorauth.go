@@ -0,0 +1,96 @@
+// Package workers provides worker handlers for processing Kafka events
+package workers
+
+import (
+	"context"
+	"log"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PriorAuthWorker opens prior authorizations for claims rejected with reject code 75
+type PriorAuthWorker struct {
+	mongoClient   *database.MongoClient
+	kafkaProducer kafka.Producer
+	priorAuths    *services.PriorAuthService
+}
+
+// NewPriorAuthWorker creates a new prior authorization worker
+func NewPriorAuthWorker(mongoClient *database.MongoClient, kafkaProducer kafka.Producer, priorAuths *services.PriorAuthService) *PriorAuthWorker {
+	return &PriorAuthWorker{
+		mongoClient:   mongoClient,
+		kafkaProducer: kafkaProducer,
+		priorAuths:    priorAuths,
+	}
+}
+
+// Topic returns the Kafka topic this handler consumes from
+func (w *PriorAuthWorker) Topic() string {
+	return kafka.TopicPrescriptionException
+}
+
+// Handle processes a prescription exception and opens a prior authorization if the claim needs one
+func (w *PriorAuthWorker) Handle(ctx context.Context, msg *kafka.Message) error {
+	correlationID := ExtractCorrelationID(msg)
+
+	var event events.PrescriptionException
+	if err := events.Decode(msg.Value, &event); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to unmarshal prescription exception event: %v", correlationID, err)
+		return err
+	}
+
+	if event.CorrelationID != "" {
+		correlationID = event.CorrelationID
+	}
+
+	if event.ExceptionType != models.ExceptionClaimRejected || !containsCode(event.Codes, models.RejectPriorAuthRequired) {
+		return nil
+	}
+
+	prescriptionID, err := primitive.ObjectIDFromHex(event.PrescriptionID)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Invalid prescription ID format: %s", correlationID, event.PrescriptionID)
+		return err
+	}
+
+	var prescription models.Prescription
+	err = w.mongoClient.GetCollection("prescriptions").FindOne(ctx, bson.M{"_id": prescriptionID}).Decode(&prescription)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Prescription not found: %s", correlationID, event.PrescriptionID)
+		return err
+	}
+
+	pa, created, err := w.priorAuths.Open(ctx, &prescription, models.PriorAuthSourceClaimReject, "system", "claim rejected: prior authorization required")
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to open prior authorization: %v", correlationID, err)
+		return err
+	}
+	if !created {
+		log.Printf("ℹ️  [correlation_id=%s] Prior authorization %s already %s for prescription %s", correlationID, pa.ID.Hex(), pa.Status, event.PrescriptionID)
+		return nil
+	}
+
+	if err := events.PublishPriorAuthUpdated(ctx, w.kafkaProducer, correlationID, pa, ""); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to publish prior authorization event: %v", correlationID, err)
+		return err
+	}
+
+	log.Printf("📋 [correlation_id=%s] Opened prior authorization %s for prescription %s", correlationID, pa.ID.Hex(), event.PrescriptionID)
+	return nil
+}
+
+// containsCode reports whether a reject code is in the list
+func containsCode(codes []string, code string) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -7,20 +7,31 @@ import (
 )
 
 // Registry manages worker handlers for different Kafka topics
+// A topic can have several handlers (e.g. shipping and notifications); each receives every message
 type Registry struct {
-	handlers map[string]Handler
-	mu       sync.RWMutex
+	handlers   map[string][]Handler
+	middleware []func(Handler) Handler
+	mu         sync.RWMutex
 }
 
 // NewRegistry creates a new worker registry
 func NewRegistry() *Registry {
 	return &Registry{
-		handlers: make(map[string]Handler),
+		handlers: make(map[string][]Handler),
 	}
 }
 
+// Use adds a middleware that wraps every handler registered afterwards, e.g. Idempotent
+// Middleware added first is outermost
+func (r *Registry) Use(middleware func(Handler) Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.middleware = append(r.middleware, middleware)
+}
+
 // Register adds a handler for a specific topic
-// If a handler already exists for the topic, it will be replaced
+// Handlers for the same topic run in the order they were registered
 func (r *Registry) Register(handler Handler) error {
 	if handler == nil {
 		return fmt.Errorf("handler cannot be nil")
@@ -34,16 +45,19 @@ func (r *Registry) Register(handler Handler) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.handlers[topic] = handler
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	r.handlers[topic] = append(r.handlers[topic], handler)
 	return nil
 }
 
-// GetHandler returns the handler for a given topic, or nil if not found
-func (r *Registry) GetHandler(topic string) Handler {
+// GetHandlers returns the handlers for a given topic, or nil if there are none
+func (r *Registry) GetHandlers(topic string) []Handler {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	return r.handlers[topic]
+	return append([]Handler(nil), r.handlers[topic]...)
 }
 
 // GetTopics returns all registered topics
@@ -0,0 +1,119 @@
+// Package workers provides worker handlers for processing Kafka events
+package workers
+
+import (
+	"context"
+	"log"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"github.com/phil-my-meds/backend-gogit/internal/shipping"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ShipmentReplacementWorker ships a replacement for cold chain shipments reported out of range
+type ShipmentReplacementWorker struct {
+	mongoClient   *database.MongoClient
+	kafkaProducer kafka.Producer
+	carrier       shipping.Carrier
+	shipping      *ShippingWorker
+}
+
+// NewShipmentReplacementWorker creates a new shipment replacement worker
+// Replacement labels are bought through the shipping worker so they follow the same cold chain rules
+func NewShipmentReplacementWorker(mongoClient *database.MongoClient, kafkaProducer kafka.Producer, carrier shipping.Carrier, shippingWorker *ShippingWorker) *ShipmentReplacementWorker {
+	return &ShipmentReplacementWorker{
+		mongoClient:   mongoClient,
+		kafkaProducer: kafkaProducer,
+		carrier:       carrier,
+		shipping:      shippingWorker,
+	}
+}
+
+// Topic returns the Kafka topic this handler consumes from
+func (w *ShipmentReplacementWorker) Topic() string {
+	return kafka.TopicShipmentTemperatureExcursion
+}
+
+// Handle processes a temperature excursion event and ships a replacement once per flagged shipment
+func (w *ShipmentReplacementWorker) Handle(ctx context.Context, msg *kafka.Message) error {
+	var event events.ShipmentTemperatureExcursion
+	if err := events.Decode(msg.Value, &event); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to unmarshal temperature excursion event: %v", ExtractCorrelationID(msg), err)
+		return err
+	}
+
+	correlationID := event.CorrelationID
+	if correlationID == "" {
+		correlationID = ExtractCorrelationID(msg)
+	}
+
+	log.Printf("🌡️  [correlation_id=%s] Temperature excursion on shipment %s, prescription: %s", correlationID, event.ShipmentID, event.PrescriptionID)
+
+	shipmentID, err := primitive.ObjectIDFromHex(event.ShipmentID)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Invalid shipment ID format: %s", correlationID, event.ShipmentID)
+		return err
+	}
+
+	shipmentCollection := w.mongoClient.GetCollection("shipments")
+	var original models.Shipment
+	if err := shipmentCollection.FindOne(ctx, bson.M{"_id": shipmentID}).Decode(&original); err != nil {
+		log.Printf("❌ [correlation_id=%s] Shipment not found: %s", correlationID, event.ShipmentID)
+		return err
+	}
+	if original.ReplacementShipmentID != nil {
+		log.Printf("ℹ️  [correlation_id=%s] Shipment %s already replaced by %s", correlationID, event.ShipmentID, original.ReplacementShipmentID.Hex())
+		return nil
+	}
+
+	// A replacement recorded before a crash only needs linking back to the original
+	var replacement models.Shipment
+	err = shipmentCollection.FindOne(ctx, bson.M{"replaces_shipment_id": shipmentID}).Decode(&replacement)
+	if err != nil && err != mongo.ErrNoDocuments {
+		log.Printf("❌ [correlation_id=%s] Failed to look up replacement shipment: %v", correlationID, err)
+		return err
+	}
+
+	if err == mongo.ErrNoDocuments {
+		var prescription models.Prescription
+		if err := w.mongoClient.GetCollection("prescriptions").FindOne(ctx, bson.M{"_id": original.PrescriptionID}).Decode(&prescription); err != nil {
+			log.Printf("❌ [correlation_id=%s] Prescription not found: %s", correlationID, original.PrescriptionID.Hex())
+			return err
+		}
+
+		shipped, err := w.shipping.Ship(ctx, correlationID, &prescription, original.PatientID, &original)
+		if err != nil {
+			return err
+		}
+		if shipped == nil {
+			// The prescription went to exception (address or rate); ops take it from there
+			return nil
+		}
+		replacement = *shipped
+	}
+
+	update := bson.M{"replacement_shipment_id": replacement.ID}
+
+	// A label that never left the pharmacy is voided so it is not billed
+	if original.Status == models.ShipmentLabelCreated {
+		if err := w.carrier.VoidLabel(ctx, original.LabelID); err != nil {
+			log.Printf("⚠️  [correlation_id=%s] Failed to void label %s of replaced shipment: %v", correlationID, original.LabelID, err)
+		} else {
+			update["status"] = models.ShipmentVoided
+		}
+	}
+
+	if _, err := services.ChangeShipment(ctx, shipmentCollection, correlationID, "replaced", bson.M{"_id": shipmentID}, update); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to link replacement shipment: %v", correlationID, err)
+		return err
+	}
+
+	log.Printf("✅ [correlation_id=%s] Shipment %s replaced by %s (Tracking: %s)", correlationID, event.ShipmentID, replacement.ID.Hex(), replacement.TrackingNumber)
+	return nil
+}
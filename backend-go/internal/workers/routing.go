@@ -3,12 +3,15 @@ package workers
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
 	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -17,13 +20,25 @@ import (
 type RoutingWorker struct {
 	mongoClient   *database.MongoClient
 	kafkaProducer kafka.Producer
+	scoring       *services.PharmacyScoringService
+	policies      *services.RoutingPolicyService
+	selections    *services.PharmacySelectionService
 }
 
 // NewRoutingWorker creates a new routing worker
-func NewRoutingWorker(mongoClient *database.MongoClient, kafkaProducer kafka.Producer) *RoutingWorker {
+func NewRoutingWorker(
+	mongoClient *database.MongoClient,
+	kafkaProducer kafka.Producer,
+	scoring *services.PharmacyScoringService,
+	policies *services.RoutingPolicyService,
+	capacity *services.PharmacyCapacityService,
+) *RoutingWorker {
 	return &RoutingWorker{
 		mongoClient:   mongoClient,
 		kafkaProducer: kafkaProducer,
+		scoring:       scoring,
+		policies:      policies,
+		selections:    services.NewPharmacySelectionService(mongoClient, kafkaProducer, capacity),
 	}
 }
 
@@ -41,16 +56,8 @@ func (w *RoutingWorker) Handle(ctx context.Context, msg *kafka.Message) error {
 	correlationID := ExtractCorrelationID(msg)
 
 	// Parse the event payload
-	var event struct {
-		EventID        string `json:"event_id"`
-		CorrelationID  string `json:"correlation_id,omitempty"`
-		PrescriptionID string `json:"prescription_id"`
-		PatientID      string `json:"patient_id"`
-		EnrolledAt     string `json:"enrolled_at"`
-		Timestamp      string `json:"timestamp"`
-	}
-
-	if err := json.Unmarshal(msg.Value, &event); err != nil {
+	var event events.EnrollmentCompleted
+	if err := events.Decode(msg.Value, &event); err != nil {
 		log.Printf("❌ [correlation_id=%s] Failed to unmarshal routing event: %v", correlationID, err)
 		return err
 	}
@@ -66,67 +73,126 @@ func (w *RoutingWorker) Handle(ctx context.Context, msg *kafka.Message) error {
 	prescriptionCollection := w.mongoClient.GetCollection("prescriptions")
 	prescriptionID, err := primitive.ObjectIDFromHex(event.PrescriptionID)
 	if err != nil {
-		log.Printf("❌ Invalid prescription ID format: %s", event.PrescriptionID)
+		log.Printf("❌ [correlation_id=%s] Invalid prescription ID format: %s", correlationID, event.PrescriptionID)
 		return err
 	}
 
-	var prescription bson.M
+	var prescription models.Prescription
 	err = prescriptionCollection.FindOne(ctx, bson.M{"_id": prescriptionID}).Decode(&prescription)
 	if err != nil {
-		log.Printf("❌ Prescription not found: %s", event.PrescriptionID)
+		log.Printf("❌ [correlation_id=%s] Prescription not found: %s", correlationID, event.PrescriptionID)
 		return err
 	}
 
-	// Select a pharmacy (simplified - actual routing logic will be more sophisticated)
-	pharmacyCollection := w.mongoClient.GetCollection("pharmacies")
+	// A redelivered event finds the pharmacy already selected; only pharmacy.selected may still be unpublished
+	switch prescription.Status {
+	case models.StatusEnrolled:
+	case models.StatusPharmacySelected:
+		if _, err := w.selections.Resume(ctx, correlationID, &prescription); err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to resume pharmacy selection: %v", correlationID, err)
+			return err
+		}
+		return nil
+	default:
+		log.Printf("ℹ️  [correlation_id=%s] Prescription %s is %s, not routed again", correlationID, event.PrescriptionID, prescription.Status)
+		return nil
+	}
 
-	// Find an active pharmacy
-	// Note: In production, this would use aggregation pipeline to compare
-	// current_daily_count with max_prescriptions_per_day
-	var pharmacy bson.M
-	err = pharmacyCollection.FindOne(ctx, bson.M{"active": true}).Decode(&pharmacy)
+	// Score and rank pharmacies by distance, capacity, network, services and opening hours
+	recommendations, err := w.scoring.Recommend(ctx, services.RecommendationRequest{
+		PatientLocation: w.patientLocation(ctx, correlationID, event.PatientID, &prescription),
+		Plan:            prescription.Insurance.Plan(),
+		NDC:             prescription.Medication.NDC,
+	})
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to score pharmacies: %v", correlationID, err)
+		return err
+	}
+
+	if len(recommendations) == 0 {
+		log.Printf("❌ [correlation_id=%s] No active in-network pharmacy found for prescription: %s", correlationID, event.PrescriptionID)
+		return fmt.Errorf("no active in-network pharmacy found for prescription %s", event.PrescriptionID)
+	}
 
+	// Resolve auto vs manual selection for this drug / sponsor program
+	policy, err := w.policies.Resolve(ctx, prescription.Medication.NDC)
 	if err != nil {
-		log.Printf("❌ No active pharmacy found: %v", err)
+		log.Printf("❌ [correlation_id=%s] Failed to resolve routing policy: %v", correlationID, err)
 		return err
 	}
 
-	pharmacyID := pharmacy["_id"].(primitive.ObjectID).Hex()
-	pharmacyNCPDPID := ""
-	if ncpdpID, ok := pharmacy["ncpdp_id"].(string); ok {
-		pharmacyNCPDPID = ncpdpID
+	if policy.Mode == models.RoutingModeManual {
+		// Stop at awaiting_routing; ops selects via POST /api/v1/prescriptions/{id}/pharmacy
+		_, err := services.ChangePrescriptionStatus(ctx, prescriptionCollection, correlationID, bson.M{"_id": prescriptionID}, models.StatusAwaitingRouting, bson.M{
+			"pharmacy_recommendations": recommendations,
+			"patient_id":               event.PatientID,
+		})
+		if err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to store pharmacy recommendations: %v", correlationID, err)
+			return err
+		}
+
+		log.Printf("🗂️  [correlation_id=%s] Stored %d pharmacy recommendations, awaiting ops selection for prescription: %s", correlationID, len(recommendations), event.PrescriptionID)
+		return nil
 	}
 
-	log.Printf("🏥 Selected pharmacy: %s (NCPDP: %s)", pharmacyID, pharmacyNCPDPID)
+	// Auto-select the top ranked pharmacy
+	selected := recommendations[0]
+	log.Printf("🏥 [correlation_id=%s] Selected pharmacy: %s (NCPDP: %s, score: %.2f)", correlationID, selected.PharmacyID, selected.NCPDPID, selected.Score)
 
-	// Update prescription with selected pharmacy
 	update := bson.M{
 		"$set": bson.M{
-			"pharmacy_id": pharmacyID,
-			"status":      "pharmacy_selected",
-			"updated_at":  time.Now(),
+			"pharmacy_recommendations": recommendations,
+			"patient_id":               event.PatientID,
+			"updated_at":               time.Now(),
 		},
 	}
 
-	_, err = prescriptionCollection.UpdateOne(ctx, bson.M{"_id": prescriptionID}, update)
-	if err != nil {
-		log.Printf("❌ Failed to update prescription with pharmacy: %v", err)
+	if _, err := prescriptionCollection.UpdateOne(ctx, bson.M{"_id": prescriptionID, "status": models.StatusEnrolled}, update); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to store pharmacy recommendations: %v", correlationID, err)
 		return err
 	}
 
-	// 8.3.3: Emit pharmacy selected event
-	routingEvent := CreateEvent(correlationID, event.PrescriptionID, map[string]interface{}{
-		"patient_id":        event.PatientID,
-		"pharmacy_id":       pharmacyID,
-		"pharmacy_ncpdp_id": pharmacyNCPDPID,
-		"selected_at":       time.Now().Format(time.RFC3339),
-	})
+	selection := models.PharmacySelection{
+		PharmacyID: selected.PharmacyID,
+		NCPDPID:    selected.NCPDPID,
+		Name:       selected.Name,
+		Mode:       models.RoutingModeAuto,
+		Rank:       selected.Rank,
+		Score:      selected.Score,
+		SelectedBy: "system",
+		SelectedAt: time.Now(),
+	}
 
-	if err := PublishEvent(ctx, w.kafkaProducer, kafka.TopicPharmacySelected, event.PrescriptionID, routingEvent); err != nil {
-		log.Printf("❌ [correlation_id=%s] Failed to publish pharmacy selected event: %v", correlationID, err)
+	// 8.3.3: Reserve capacity and emit pharmacy selected event
+	if _, err := w.selections.Complete(ctx, correlationID, prescriptionID, event.PatientID, models.StatusEnrolled, selection); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to complete pharmacy selection: %v", correlationID, err)
 		return err
 	}
 
-	log.Printf("✅ Pharmacy selected for prescription: %s", event.PrescriptionID)
+	log.Printf("✅ [correlation_id=%s] Pharmacy selected for prescription: %s", correlationID, event.PrescriptionID)
 	return nil
 }
+
+// patientLocation resolves the patient's shipping location, preferring the prescription
+// address and falling back to the patient record
+func (w *RoutingWorker) patientLocation(ctx context.Context, correlationID, patientID string, prescription *models.Prescription) *models.GeoPoint {
+	if loc := prescription.Patient.Address.Location; loc != nil {
+		return loc
+	}
+
+	oid, err := primitive.ObjectIDFromHex(patientID)
+	if err != nil {
+		return nil
+	}
+
+	var patient struct {
+		Location *models.GeoPoint `bson:"location"`
+	}
+	if err := w.mongoClient.GetCollection("patients").FindOne(ctx, bson.M{"_id": oid}).Decode(&patient); err != nil {
+		log.Printf("⚠️  [correlation_id=%s] Patient location unavailable for %s: %v", correlationID, patientID, err)
+		return nil
+	}
+
+	return patient.Location
+}
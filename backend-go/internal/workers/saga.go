@@ -0,0 +1,496 @@
+// Package workers provides worker handlers for processing Kafka events
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/payments"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"github.com/phil-my-meds/backend-gogit/internal/shipping"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// sagaStepWorkers are the workers that perform each saga step
+// A dead-lettered message fails the saga only when its step's worker gave up on it; notification and
+// event log failures on the same topic leave the prescription where it is.
+var sagaStepWorkers = map[models.SagaStep]string{
+	models.SagaStepValidation:   "ValidationWorker",
+	models.SagaStepEnrollment:   "EnrollmentWorker",
+	models.SagaStepRouting:      "RoutingWorker",
+	models.SagaStepAdjudication: "AdjudicationWorker",
+	models.SagaStepPayment:      "PaymentWorker",
+	models.SagaStepVerification: "VerificationWorker",
+	models.SagaStepShipping:     "ShippingWorker",
+}
+
+// sagaExceptionHoldStatuses are the prescription statuses a failed saga leaves alone: already with ops, rejected or on its way
+var sagaExceptionHoldStatuses = []models.PrescriptionStatus{
+	models.StatusException,
+	models.StatusValidationFailed,
+	models.StatusShipped,
+	models.StatusFulfilled,
+}
+
+// sagaPayload holds the event fields a saga records, across the topics it follows
+type sagaPayload struct {
+	PharmacyID    string    `json:"pharmacy_id"`
+	PaymentID     string    `json:"payment_id"`
+	ShipmentID    string    `json:"shipment_id"`
+	ExceptionType string    `json:"exception_type"`
+	Reason        string    `json:"reason"`
+	Codes         []string  `json:"codes"`
+	Source        string    `json:"source"`
+	RaisedAt      time.Time `json:"raised_at"`
+}
+
+// SagaWorker follows prescriptions through the pipeline on one topic, recording their saga
+// One worker is registered per topic the saga follows, alongside the topic's other handlers, and one on
+// the dead letter queue, where a step's worker giving up on a message fails the saga.
+type SagaWorker struct {
+	topic       string
+	sagas       *services.SagaService
+	compensator *SagaCompensator
+}
+
+// NewSagaWorkers creates a saga worker for every topic the saga follows and for the dead letter queue
+func NewSagaWorkers(sagas *services.SagaService, compensator *SagaCompensator) []*SagaWorker {
+	topics := append(services.SagaTopics(), kafka.TopicDeadLetterQueue)
+	sagaWorkers := make([]*SagaWorker, 0, len(topics))
+	for _, topic := range topics {
+		sagaWorkers = append(sagaWorkers, &SagaWorker{
+			topic:       topic,
+			sagas:       sagas,
+			compensator: compensator,
+		})
+	}
+	return sagaWorkers
+}
+
+// Topic returns the Kafka topic this handler consumes from
+func (w *SagaWorker) Topic() string {
+	return w.topic
+}
+
+// Handle records the event on the prescription's saga, or fails the saga for a dead-lettered step
+func (w *SagaWorker) Handle(ctx context.Context, msg *kafka.Message) error {
+	if w.topic == kafka.TopicDeadLetterQueue {
+		return w.handleDeadLetter(ctx, msg)
+	}
+
+	envelope, err := events.ReadEnvelope(msg.Value)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to read %s event for the saga: %v", ExtractCorrelationID(msg), w.topic, err)
+		return err
+	}
+	var payload sagaPayload
+	if err := json.Unmarshal(msg.Value, &payload); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to unmarshal %s event for the saga: %v", ExtractCorrelationID(msg), w.topic, err)
+		return err
+	}
+
+	event := services.SagaEvent{
+		Topic:          msg.Topic,
+		EventID:        envelope.EventID,
+		PrescriptionID: envelope.PrescriptionID,
+		CorrelationID:  envelope.CorrelationID,
+		OccurredAt:     envelope.OccurredAt,
+		PharmacyID:     payload.PharmacyID,
+		PaymentID:      payload.PaymentID,
+		ShipmentID:     payload.ShipmentID,
+	}
+	if event.PrescriptionID == "" {
+		event.PrescriptionID = string(msg.Key)
+	}
+	if event.EventID == "" {
+		event.EventID = MessageEventID(msg)
+	}
+	if event.CorrelationID == "" {
+		event.CorrelationID = ExtractCorrelationID(msg)
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	if msg.Topic == kafka.TopicPrescriptionException {
+		event.Exception = &models.PrescriptionException{
+			Type:     payload.ExceptionType,
+			Reason:   payload.Reason,
+			Codes:    payload.Codes,
+			Source:   payload.Source,
+			RaisedAt: payload.RaisedAt,
+		}
+	}
+	if event.PrescriptionID == "" {
+		log.Printf("⚠️  [correlation_id=%s] %s event %s has no prescription ID, not recorded on a saga", event.CorrelationID, w.topic, event.EventID)
+		return nil
+	}
+
+	saga, changed, err := w.sagas.Record(ctx, event)
+	if errors.Is(err, services.ErrSagaNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to record %s on the saga of prescription %s: %v", event.CorrelationID, w.topic, event.PrescriptionID, err)
+		return err
+	}
+	if changed {
+		log.Printf("🧭 [correlation_id=%s] Saga of prescription %s %s at %s", event.CorrelationID, event.PrescriptionID, saga.Status, saga.CurrentStep)
+	}
+	return nil
+}
+
+// handleDeadLetter fails the saga of a prescription whose step's worker gave up on its message
+// A message dead-lettered because another consumer held its lease is still being handled and is ignored.
+func (w *SagaWorker) handleDeadLetter(ctx context.Context, msg *kafka.Message) error {
+	var letter events.DeadLetter
+	if err := events.Decode(msg.Value, &letter); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to unmarshal dead letter for the saga: %v", ExtractCorrelationID(msg), err)
+		return err
+	}
+
+	step, ok := services.SagaStepStartedBy(letter.OriginalTopic)
+	if !ok {
+		return nil
+	}
+	handler, reason, _ := strings.Cut(letter.Error, ": ")
+	if sagaStepWorkers[step] != handler || strings.Contains(reason, services.ErrEventInProgress.Error()) {
+		return nil
+	}
+
+	prescriptionID := letter.PrescriptionID
+	if prescriptionID == "" {
+		prescriptionID = letter.OriginalKey
+	}
+	correlationID := letter.CorrelationID
+	if correlationID == "" {
+		correlationID = ExtractCorrelationID(msg)
+	}
+	failedAt := letter.FailedAt
+	if failedAt.IsZero() {
+		failedAt = time.Now()
+	}
+
+	return w.compensator.Fail(ctx, correlationID, prescriptionID, models.SagaFailure{
+		Step:   step,
+		Reason: fmt.Sprintf("%s failed: %s", handler, reason),
+		At:     failedAt,
+	})
+}
+
+// SagaCompensator fails sagas and runs their compensating actions
+// It is shared by the saga workers, for failed steps, and the scheduler, for timed out steps and retries.
+type SagaCompensator struct {
+	mongoClient   *database.MongoClient
+	kafkaProducer kafka.Producer
+	sagas         *services.SagaService
+	capacity      *services.PharmacyCapacityService
+	provider      payments.PaymentProvider
+	carrier       shipping.Carrier
+	refunds       *services.RefundService
+}
+
+// NewSagaCompensator creates a new saga compensator
+func NewSagaCompensator(
+	mongoClient *database.MongoClient,
+	kafkaProducer kafka.Producer,
+	sagas *services.SagaService,
+	capacity *services.PharmacyCapacityService,
+	provider payments.PaymentProvider,
+	carrier shipping.Carrier,
+	refunds *services.RefundService,
+) *SagaCompensator {
+	return &SagaCompensator{
+		mongoClient:   mongoClient,
+		kafkaProducer: kafkaProducer,
+		sagas:         sagas,
+		capacity:      capacity,
+		provider:      provider,
+		carrier:       carrier,
+		refunds:       refunds,
+	}
+}
+
+// Fail fails a prescription's saga at a step: the prescription goes to exception for ops and what the
+// earlier steps did is undone. A step that already completed, or a saga already failed, is left alone.
+func (c *SagaCompensator) Fail(ctx context.Context, correlationID, prescriptionID string, failure models.SagaFailure) error {
+	saga, err := c.sagas.Get(ctx, prescriptionID)
+	if err != nil && !errors.Is(err, services.ErrSagaNotFound) {
+		return err
+	}
+	if saga != nil && !services.SagaCanFail(saga, failure.Step) {
+		log.Printf("ℹ️  [correlation_id=%s] Saga of prescription %s is %s, not failing it at %s", correlationID, prescriptionID, saga.Status, failure.Step)
+		return nil
+	}
+
+	// The exception is raised before the saga is failed, so a retry after an error raises it again
+	if err := c.raiseException(ctx, correlationID, prescriptionID, failure); err != nil {
+		return err
+	}
+
+	saga, failed, err := c.sagas.Fail(ctx, prescriptionID, correlationID, failure)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to fail the saga of prescription %s: %v", correlationID, prescriptionID, err)
+		return err
+	}
+	if !failed {
+		return nil
+	}
+
+	log.Printf("⛔ [correlation_id=%s] Saga of prescription %s failed at %s: %s (%d compensations)", correlationID, prescriptionID, failure.Step, failure.Reason, len(saga.Compensations))
+	return c.Compensate(ctx, saga)
+}
+
+// Compensate runs a failed saga's pending compensations, recording each outcome on the saga
+// A compensation that fails is retried by the scheduler with backoff.
+func (c *SagaCompensator) Compensate(ctx context.Context, saga *models.Saga) error {
+	for _, compensation := range saga.Compensations {
+		if compensation.Status != models.CompensationPending {
+			continue
+		}
+
+		result := c.compensate(ctx, saga, compensation.Action)
+		if result.Err != nil {
+			log.Printf("⚠️  [correlation_id=%s] Compensation %s of prescription %s failed: %v", saga.CorrelationID, compensation.Action, saga.PrescriptionID, result.Err)
+		} else {
+			log.Printf("↩️  [correlation_id=%s] Compensation %s of prescription %s %s: %s", saga.CorrelationID, compensation.Action, saga.PrescriptionID, result.Status, result.Detail)
+		}
+
+		updated, err := c.sagas.RecordCompensation(ctx, saga.PrescriptionID, compensation.Action, result, time.Now())
+		if err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to record compensation %s of prescription %s: %v", saga.CorrelationID, compensation.Action, saga.PrescriptionID, err)
+			return err
+		}
+		if updated.Status == models.SagaCompensationFailed {
+			log.Printf("🚨 [correlation_id=%s] Saga of prescription %s needs ops: compensation %s gave up", saga.CorrelationID, saga.PrescriptionID, compensation.Action)
+		}
+	}
+	return nil
+}
+
+// Sweep fails sagas whose current step is past its deadline and retries compensations that are due
+// It returns how many sagas were failed and how many had compensations retried.
+func (c *SagaCompensator) Sweep(ctx context.Context, now time.Time, limit int64) (int, int, error) {
+	timedOut, err := c.sagas.TimedOut(ctx, now, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	failed := 0
+	for _, saga := range timedOut {
+		failure := models.SagaFailure{
+			Step:     saga.CurrentStep,
+			Reason:   fmt.Sprintf("%s did not complete by %s", saga.CurrentStep, saga.Deadline.UTC().Format(time.RFC3339)),
+			TimedOut: true,
+			At:       now,
+		}
+		if err := c.Fail(ctx, saga.CorrelationID, saga.PrescriptionID, failure); err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to time out the saga of prescription %s: %v", saga.CorrelationID, saga.PrescriptionID, err)
+			continue
+		}
+		failed++
+	}
+
+	due, err := c.sagas.CompensationsDue(ctx, now, limit)
+	if err != nil {
+		return failed, 0, err
+	}
+	retried := 0
+	for i := range due {
+		if err := c.Compensate(ctx, &due[i]); err != nil {
+			continue
+		}
+		retried++
+	}
+	return failed, retried, nil
+}
+
+// raiseException moves the prescription to exception so ops can pick it up, unless it is already with ops or past fulfillment
+func (c *SagaCompensator) raiseException(ctx context.Context, correlationID, prescriptionID string, failure models.SagaFailure) error {
+	oid, err := primitive.ObjectIDFromHex(prescriptionID)
+	if err != nil {
+		log.Printf("⚠️  [correlation_id=%s] Invalid prescription ID format: %s", correlationID, prescriptionID)
+		return nil
+	}
+
+	exception := models.PrescriptionException{
+		Type:     models.ExceptionStepFailed,
+		Reason:   failure.Reason,
+		Codes:    []string{string(failure.Step)},
+		Source:   "saga",
+		RaisedAt: failure.At,
+	}
+	if failure.TimedOut {
+		exception.Type = models.ExceptionStepTimedOut
+	}
+
+	filter := bson.M{"_id": oid, "status": bson.M{"$nin": sagaExceptionHoldStatuses}}
+	updated, err := services.ChangePrescriptionStatus(ctx, c.mongoClient.GetCollection("prescriptions"), correlationID, filter,
+		models.StatusException, bson.M{"exception": exception})
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return nil
+	}
+	if err := events.PublishException(ctx, c.kafkaProducer, correlationID, prescriptionID, exception); err != nil {
+		return fmt.Errorf("failed to publish prescription exception event: %w", err)
+	}
+	return nil
+}
+
+// compensate runs one compensating action
+func (c *SagaCompensator) compensate(ctx context.Context, saga *models.Saga, action models.SagaCompensationAction) services.CompensationResult {
+	switch action {
+	case models.CompensationVoidLabel:
+		return c.voidLabel(ctx, saga)
+	case models.CompensationVoidPaymentLink:
+		return c.voidPaymentLink(ctx, saga)
+	case models.CompensationRefund:
+		return c.refund(ctx, saga)
+	case models.CompensationReleaseCapacity:
+		return c.releaseCapacity(ctx, saga)
+	}
+	return services.CompensationResult{Err: fmt.Errorf("unknown compensation %s", action)}
+}
+
+// voidLabel voids a shipping label that has not been picked up yet
+func (c *SagaCompensator) voidLabel(ctx context.Context, saga *models.Saga) services.CompensationResult {
+	shipmentID, err := primitive.ObjectIDFromHex(saga.ShipmentID)
+	if err != nil {
+		return compensationSkipped("invalid shipment ID %s", saga.ShipmentID)
+	}
+	shipments := c.mongoClient.GetCollection("shipments")
+	var shipment models.Shipment
+	if err := shipments.FindOne(ctx, bson.M{"_id": shipmentID}).Decode(&shipment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return compensationSkipped("shipment %s not found", saga.ShipmentID)
+		}
+		return services.CompensationResult{Err: err}
+	}
+	if shipment.Status != models.ShipmentLabelCreated {
+		return compensationSkipped("shipment is %s", shipment.Status)
+	}
+
+	if err := c.carrier.VoidLabel(ctx, shipment.LabelID); err != nil {
+		if errors.Is(err, shipping.ErrLabelNotFound) {
+			return compensationSkipped("label %s not found at the carrier", shipment.LabelID)
+		}
+		return services.CompensationResult{Err: err}
+	}
+
+	filter := bson.M{"_id": shipmentID, "status": models.ShipmentLabelCreated}
+	if _, err := services.ChangeShipment(ctx, shipments, saga.CorrelationID, "saga_compensation", filter, bson.M{"status": models.ShipmentVoided}); err != nil {
+		return services.CompensationResult{Err: err}
+	}
+	return compensationDone("label %s voided", shipment.LabelID)
+}
+
+// voidPaymentLink expires a checkout link the patient has not paid yet, so it cannot be paid
+func (c *SagaCompensator) voidPaymentLink(ctx context.Context, saga *models.Saga) services.CompensationResult {
+	paymentID, err := primitive.ObjectIDFromHex(saga.PaymentID)
+	if err != nil {
+		return compensationSkipped("invalid payment ID %s", saga.PaymentID)
+	}
+	paymentCollection := c.mongoClient.GetCollection("payments")
+	var payment models.Payment
+	if err := paymentCollection.FindOne(ctx, bson.M{"_id": paymentID}).Decode(&payment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return compensationSkipped("payment %s not found", saga.PaymentID)
+		}
+		return services.CompensationResult{Err: err}
+	}
+	unpaid := []models.PaymentStatus{models.PaymentPending, models.PaymentFailed}
+	if payment.Status != models.PaymentPending && payment.Status != models.PaymentFailed {
+		return compensationSkipped("payment is %s", payment.Status)
+	}
+
+	state, err := c.provider.RetrieveStatus(ctx, payment.ProviderSessionID)
+	if err != nil {
+		if errors.Is(err, payments.ErrSessionNotFound) {
+			return compensationSkipped("checkout session %s not found", payment.ProviderSessionID)
+		}
+		return services.CompensationResult{Err: err}
+	}
+	if state.Status != payments.SessionOpen {
+		return compensationSkipped("checkout link is %s", state.Status)
+	}
+	if err := c.provider.ExpireCheckoutLink(ctx, payment.ProviderSessionID); err != nil {
+		return services.CompensationResult{Err: err}
+	}
+
+	payment.Status = models.PaymentExpired
+	payment.FailureReason = "Prescription fulfillment failed at " + string(saga.Failure.Step)
+	updated, err := paymentCollection.UpdateOne(ctx, bson.M{"_id": paymentID, "status": bson.M{"$in": unpaid}},
+		bson.M{"$set": bson.M{"status": payment.Status, "failure_reason": payment.FailureReason, "updated_at": time.Now()}})
+	if err != nil {
+		return services.CompensationResult{Err: err}
+	}
+	if updated.MatchedCount == 1 {
+		if err := services.RecordPaymentChange(ctx, saga.CorrelationID, &payment, audit.ActionUpdate); err != nil {
+			return services.CompensationResult{Err: err}
+		}
+	}
+	return compensationDone("checkout link expired")
+}
+
+// refund refunds whatever of a captured copay is still refundable
+func (c *SagaCompensator) refund(ctx context.Context, saga *models.Saga) services.CompensationResult {
+	payment, refundable, err := c.refunds.Refundable(ctx, saga.PaymentID)
+	if err != nil {
+		if errors.Is(err, services.ErrRefundPaymentNotFound) {
+			return compensationSkipped("payment %s not found", saga.PaymentID)
+		}
+		return services.CompensationResult{Err: err}
+	}
+	if refundable == 0 {
+		return compensationSkipped("payment is %s with nothing to refund", payment.Status)
+	}
+
+	refund, payment, err := c.refunds.Issue(ctx, services.RefundRequest{
+		PaymentID: saga.PaymentID,
+		Reason:    models.RefundReasonCancelled,
+		Note:      "Prescription fulfillment failed at " + string(saga.Failure.Step),
+		By:        "system:saga",
+	})
+	if err != nil {
+		return services.CompensationResult{Err: err}
+	}
+	if err := events.PublishPaymentRefunded(ctx, c.kafkaProducer, saga.CorrelationID, refund, payment); err != nil {
+		log.Printf("⚠️  [correlation_id=%s] Refund %s issued but its event was not published: %v", saga.CorrelationID, refund.ID.Hex(), err)
+	}
+	return compensationDone("refunded $%.2f (refund %s)", refund.Amount, refund.ID.Hex())
+}
+
+// releaseCapacity gives back the capacity routing reserved at the pharmacy
+// Daily counts live in Redis for minutes; once a count has expired there is nothing to give back.
+func (c *SagaCompensator) releaseCapacity(ctx context.Context, saga *models.Saga) services.CompensationResult {
+	current, _, err := c.capacity.DecrementCapacity(ctx, saga.PharmacyID)
+	if err != nil {
+		if errors.Is(err, database.ErrKeyNotFound) {
+			return compensationSkipped("no capacity count held for pharmacy %s", saga.PharmacyID)
+		}
+		return services.CompensationResult{Err: err}
+	}
+	return compensationDone("released capacity at pharmacy %s (%d today)", saga.PharmacyID, current)
+}
+
+// compensationDone is a compensation that undid what its step did
+func compensationDone(format string, args ...interface{}) services.CompensationResult {
+	return services.CompensationResult{Status: models.CompensationDone, Detail: fmt.Sprintf(format, args...)}
+}
+
+// compensationSkipped is a compensation that found nothing to undo
+func compensationSkipped(format string, args ...interface{}) services.CompensationResult {
+	return services.CompensationResult{Status: models.CompensationSkipped, Detail: fmt.Sprintf(format, args...)}
+}
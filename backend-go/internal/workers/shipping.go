@@ -3,129 +3,446 @@ package workers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/phil-my-meds/backend-gogit/internal/audit"
 	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
 	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"github.com/phil-my-meds/backend-gogit/internal/shipping"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// outcomeShipmentID is the processed-event step recording the shipment a label was bought for
+const outcomeShipmentID = "shipment_id"
+
 // ShippingWorker handles shipping label creation events
 type ShippingWorker struct {
 	mongoClient   *database.MongoClient
 	kafkaProducer kafka.Producer
+	carrier       shipping.Carrier
+	documents     *services.ShipmentDocumentService
+	settings      ShippingSettings
+}
+
+// ShippingSettings configures how shipments are rated
+type ShippingSettings struct {
+	MaxTransitDays  int             // the delivery deadline, in days from label purchase
+	Parcel          shipping.Parcel // the box a prescription ships in
+	ColdChainCutoff string          // default "15:04" cut-off for refrigerated parcels, in pharmacy time
 }
 
 // NewShippingWorker creates a new shipping worker
-func NewShippingWorker(mongoClient *database.MongoClient, kafkaProducer kafka.Producer) *ShippingWorker {
+// documents renders the packing slip and fallback label for each shipment; nil skips them
+func NewShippingWorker(mongoClient *database.MongoClient, kafkaProducer kafka.Producer, carrier shipping.Carrier, documents *services.ShipmentDocumentService, settings ShippingSettings) *ShippingWorker {
+	if settings.Parcel == (shipping.Parcel{}) {
+		settings.Parcel = shipping.DefaultParcel
+	}
 	return &ShippingWorker{
 		mongoClient:   mongoClient,
 		kafkaProducer: kafkaProducer,
+		carrier:       carrier,
+		documents:     documents,
+		settings:      settings,
 	}
 }
 
 // Topic returns the Kafka topic this handler consumes from
 func (w *ShippingWorker) Topic() string {
-	return kafka.TopicPaymentCompleted
+	return kafka.TopicVerificationCompleted
 }
 
-// Handle processes a payment completed event and creates a shipping label
+// Handle processes a verification completed event and buys a shipping label with the carrier
+// Only prescriptions a pharmacist has verified are shipped.
 func (w *ShippingWorker) Handle(ctx context.Context, msg *kafka.Message) error {
 	// Parse the event payload
-	var event struct {
-		EventID        string  `json:"event_id"`
-		PrescriptionID string  `json:"prescription_id"`
-		PatientID      string  `json:"patient_id"`
-		Amount         float64 `json:"amount"`
-		Status         string  `json:"status"`
-		CompletedAt    string  `json:"completed_at"`
-		Timestamp      string  `json:"timestamp"`
-	}
-
-	if err := json.Unmarshal(msg.Value, &event); err != nil {
-		log.Printf("❌ Failed to unmarshal shipping event: %v", err)
+	var event events.VerificationCompleted
+	if err := events.Decode(msg.Value, &event); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to unmarshal shipping event: %v", ExtractCorrelationID(msg), err)
 		return err
 	}
 
-	log.Printf("📦 Processing shipping for prescription: %s", event.PrescriptionID)
+	correlationID := event.CorrelationID
+	if correlationID == "" {
+		correlationID = ExtractCorrelationID(msg)
+	}
+
+	log.Printf("📦 [correlation_id=%s] Processing shipping for prescription: %s", correlationID, event.PrescriptionID)
 
 	// Fetch prescription to get pharmacy and patient details
 	prescriptionCollection := w.mongoClient.GetCollection("prescriptions")
 	prescriptionID, err := primitive.ObjectIDFromHex(event.PrescriptionID)
 	if err != nil {
-		log.Printf("❌ Invalid prescription ID format: %s", event.PrescriptionID)
+		log.Printf("❌ [correlation_id=%s] Invalid prescription ID format: %s", correlationID, event.PrescriptionID)
 		return err
 	}
 
-	var prescription bson.M
+	var prescription models.Prescription
 	err = prescriptionCollection.FindOne(ctx, bson.M{"_id": prescriptionID}).Decode(&prescription)
 	if err != nil {
-		log.Printf("❌ Prescription not found: %s", event.PrescriptionID)
+		log.Printf("❌ [correlation_id=%s] Prescription not found: %s", correlationID, event.PrescriptionID)
 		return err
 	}
 
-	// Create shipping label (simplified - actual implementation will integrate with Shippo)
-	trackingNumber := "TRK" + uuid.New().String()[:12]
-	labelURL := "https://labels.phil-my-meds.com/" + trackingNumber
-
-	// Store shipment in MongoDB
+	// A retry of an event that already bought its label only finishes the shipment
 	shipmentCollection := w.mongoClient.GetCollection("shipments")
-	shipmentDoc := bson.M{
+	if shipmentID, ok := RecordedOutcome(ctx, outcomeShipmentID); ok {
+		objectID, err := primitive.ObjectIDFromHex(shipmentID)
+		if err != nil {
+			return fmt.Errorf("invalid recorded shipment ID %q: %w", shipmentID, err)
+		}
+		var shipment models.Shipment
+		if err := shipmentCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&shipment); err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to load recorded shipment %s: %v", correlationID, shipmentID, err)
+			return err
+		}
+		log.Printf("🔁 [correlation_id=%s] Resuming shipment %s for prescription: %s", correlationID, shipmentID, event.PrescriptionID)
+		return w.complete(ctx, correlationID, &shipment)
+	}
+
+	// A new event for a prescription that already shipped must not buy a second label
+	var existing models.Shipment
+	err = shipmentCollection.FindOne(ctx, bson.M{
 		"prescription_id": prescriptionID,
-		"patient_id":      event.PatientID,
-		"tracking_number": trackingNumber,
-		"label_url":       labelURL,
-		"status":          "label_created",
-		"created_at":      time.Now(),
-		"updated_at":      time.Now(),
+		"status":          bson.M{"$ne": models.ShipmentVoided},
+	}).Decode(&existing)
+	if err == nil {
+		log.Printf("ℹ️  [correlation_id=%s] Shipment %s already exists for prescription: %s", correlationID, existing.ID.Hex(), event.PrescriptionID)
+		return nil
+	}
+	if err != mongo.ErrNoDocuments {
+		log.Printf("❌ [correlation_id=%s] Failed to look up shipment: %v", correlationID, err)
+		return err
 	}
 
-	_, err = shipmentCollection.InsertOne(ctx, shipmentDoc)
+	_, err = w.Ship(ctx, correlationID, &prescription, event.PatientID, nil)
+	return err
+}
+
+// Ship validates the delivery address, rate shops and buys a label for the prescription, records the
+// shipment and publishes shipment.label.created. Refrigerated drugs ship overnight in insulated packaging
+// on the next cold chain ship day. When replaces is set the label is a replacement for that shipment.
+// It returns nil without error when the prescription was moved to exception instead.
+func (w *ShippingWorker) Ship(ctx context.Context, correlationID string, prescription *models.Prescription, patientID string, replaces *models.Shipment) (*models.Shipment, error) {
+	prescriptionID := prescription.ID
+	prescriptionHex := prescriptionID.Hex()
+
+	pharmacy, err := w.loadPharmacy(ctx, prescription.PharmacyID)
 	if err != nil {
-		log.Printf("❌ Failed to create shipment: %v", err)
-		return err
+		log.Printf("❌ [correlation_id=%s] %v", correlationID, err)
+		return nil, err
 	}
 
-	// Update prescription status
-	update := bson.M{
-		"$set": bson.M{
-			"status":     "shipped",
-			"updated_at": time.Now(),
+	coldChain, err := w.coldChain(ctx, prescription.Medication.NDC)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] %v", correlationID, err)
+		return nil, err
+	}
+
+	// Validate the delivery address before paying for a label
+	to := patientAddress(prescription)
+	validation, err := w.carrier.ValidateAddress(ctx, to)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to validate address: %v", correlationID, err)
+		return nil, err
+	}
+	if !validation.Valid {
+		return nil, w.raiseException(ctx, correlationID, prescriptionID, models.ExceptionAddressInvalid,
+			"Delivery address failed carrier validation: "+strings.Join(validation.Messages, "; "))
+	}
+	if validation.Suggested != nil {
+		to = *validation.Suggested
+	}
+
+	now := time.Now()
+	parcel := w.settings.Parcel
+	shipDate := now
+	maxTransitDays := w.settings.MaxTransitDays
+	if coldChain != nil {
+		parcel = coldChain.Parcel
+		maxTransitDays = coldChain.MaxTransitDays
+		cutoff := pharmacy.ColdChainCutoff
+		if cutoff == "" {
+			cutoff = w.settings.ColdChainCutoff
+		}
+		shipDate, err = shipping.ColdChainShipDate(now, cutoff, pharmacyLocation(pharmacy))
+		if err != nil {
+			log.Printf("❌ [correlation_id=%s] %v", correlationID, err)
+			return nil, err
+		}
+	}
+
+	// Pick the cheapest service that arrives in time and accepts the parcel
+	rates, err := w.carrier.GetRates(ctx, shipping.RateRequest{From: pharmacyShipFrom(pharmacy), To: to, Parcel: parcel, ShipDate: shipDate})
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to get shipping rates: %v", correlationID, err)
+		return nil, err
+	}
+
+	deliverBy := shipDate.AddDate(0, 0, maxTransitDays)
+	rate, err := shipping.SelectRate(rates, parcel, deliverBy, shipDate)
+	if errors.Is(err, shipping.ErrNoEligibleRate) {
+		reason := fmt.Sprintf("None of %d rates delivers within %d days", len(rates), maxTransitDays)
+		if coldChain != nil {
+			reason = fmt.Sprintf("None of %d rates delivers a refrigerated parcel overnight", len(rates))
+		}
+		return nil, w.raiseException(ctx, correlationID, prescriptionID, models.ExceptionNoShippingRate, reason)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	idempotencyKey := "label-" + prescriptionHex
+	if replaces != nil {
+		idempotencyKey += "-replaces-" + replaces.ID.Hex()
+	}
+	label, err := w.carrier.BuyLabel(ctx, shipping.LabelRequest{
+		RateID:         rate.ID,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to buy shipping label: %v", correlationID, err)
+		return nil, err
+	}
+
+	// Store shipment in MongoDB
+	shipment := models.Shipment{
+		ID:             primitive.NewObjectID(),
+		PrescriptionID: prescriptionID,
+		PatientID:      patientID,
+		PharmacyID:     prescription.PharmacyID,
+		Status:         models.ShipmentLabelCreated,
+		Provider:       w.carrier.Name(),
+		Carrier:        rate.Carrier,
+		ServiceLevel:   rate.ServiceLevel,
+		ServiceName:    rate.ServiceName,
+		Cost:           rate.Amount,
+		Currency:       rate.Currency,
+		EstimatedDays:  rate.EstimatedDays,
+		RateID:         rate.ID,
+		LabelID:        label.ID,
+		TrackingNumber: label.TrackingNumber,
+		TrackingURL:    label.TrackingURL,
+		LabelURL:       label.LabelURL,
+		Parcel: models.ShipmentParcel{
+			LengthIn: parcel.LengthIn,
+			WidthIn:  parcel.WidthIn,
+			HeightIn: parcel.HeightIn,
+			WeightOz: parcel.WeightOz,
+		},
+		ShipTo: models.Address{
+			Street:  strings.TrimSpace(to.Street1 + " " + to.Street2),
+			City:    to.City,
+			State:   to.State,
+			ZipCode: to.Zip,
+		},
+		DeliverBy:     deliverBy,
+		CorrelationID: correlationID,
+		CausationID:   events.CausationID(ctx),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if coldChain != nil {
+		shipment.ColdChain = &models.ShipmentColdChain{
+			MinTempC:  coldChain.MinTempC,
+			MaxTempC:  coldChain.MaxTempC,
+			Packaging: coldChain.Packaging,
+			ShipOn:    shipDate,
+		}
+	}
+	if replaces != nil {
+		shipment.ReplacesShipmentID = &replaces.ID
+	}
+
+	shipmentCollection := w.mongoClient.GetCollection("shipments")
+	if _, err := shipmentCollection.InsertOne(ctx, shipment); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to create shipment: %v", correlationID, err)
+		if voidErr := w.carrier.VoidLabel(ctx, label.ID); voidErr != nil {
+			log.Printf("⚠️  [correlation_id=%s] Failed to void unrecorded label %s: %v", correlationID, label.ID, voidErr)
+		}
+		return nil, err
+	}
+	err = audit.Record(ctx, audit.Entry{
+		EventType:  audit.EventShipmentChanged,
+		EntityType: audit.EntityShipment,
+		EntityID:   shipment.ID.Hex(),
+		Action:     audit.ActionCreate,
+		Details: map[string]interface{}{
+			"prescription_id": shipment.PrescriptionID.Hex(),
+			"carrier":         shipment.Carrier,
+			"tracking_number": shipment.TrackingNumber,
+			"correlation_id":  correlationID,
 		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A retry of this event picks up from here rather than buying another label
+	if err := RecordOutcome(ctx, outcomeShipmentID, shipment.ID.Hex()); err != nil {
+		log.Printf("⚠️  [correlation_id=%s] Failed to record shipment %s for the event: %v", correlationID, shipment.ID.Hex(), err)
+	}
+
+	if err := w.complete(ctx, correlationID, &shipment); err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+// complete renders a recorded shipment's documents, marks the prescription shipped and publishes
+// shipment.label.created
+func (w *ShippingWorker) complete(ctx context.Context, correlationID string, shipment *models.Shipment) error {
+	prescriptionHex := shipment.PrescriptionID.Hex()
+
+	// Documents can be rendered again on request, so a storage outage does not hold up the shipment
+	if w.documents != nil && len(shipment.Documents) == 0 {
+		if _, err := w.documents.Generate(ctx, shipment); err != nil {
+			log.Printf("⚠️  [correlation_id=%s] Failed to generate documents for shipment %s: %v", correlationID, shipment.ID.Hex(), err)
+		}
 	}
 
-	_, err = prescriptionCollection.UpdateOne(ctx, bson.M{"_id": prescriptionID}, update)
+	// Update prescription status
+	_, err := services.ChangePrescriptionStatus(ctx, w.mongoClient.GetCollection("prescriptions"), correlationID,
+		bson.M{"_id": shipment.PrescriptionID}, models.StatusShipped, nil)
 	if err != nil {
-		log.Printf("❌ Failed to update prescription status: %v", err)
+		log.Printf("❌ [correlation_id=%s] Failed to update prescription status: %v", correlationID, err)
 		return err
 	}
 
 	// Emit shipment label created event
-	shippingEvent := map[string]interface{}{
-		"event_id":        uuid.New().String(),
-		"prescription_id": event.PrescriptionID,
-		"patient_id":      event.PatientID,
-		"tracking_number": trackingNumber,
-		"label_url":       labelURL,
-		"created_at":      time.Now().Format(time.RFC3339),
-		"timestamp":       time.Now().Format(time.RFC3339),
+	event := &events.ShipmentLabelCreated{
+		Envelope:       events.NewEnvelope(ctx, correlationID, prescriptionHex),
+		PatientID:      shipment.PatientID,
+		ShipmentID:     shipment.ID.Hex(),
+		Carrier:        shipment.Carrier,
+		ServiceLevel:   shipment.ServiceLevel,
+		Cost:           shipment.Cost,
+		TrackingNumber: shipment.TrackingNumber,
+		TrackingURL:    shipment.TrackingURL,
+		LabelURL:       shipment.LabelURL,
+		Documents:      len(shipment.Documents),
+		DeliverBy:      shipment.DeliverBy,
+		ColdChain:      shipment.ColdChain != nil,
+		CreatedAt:      shipment.CreatedAt,
+	}
+	if shipment.ColdChain != nil {
+		event.ShipOn = &shipment.ColdChain.ShipOn
+		event.Packaging = shipment.ColdChain.Packaging
+	}
+	if shipment.ReplacesShipmentID != nil {
+		event.ReplacesShipmentID = shipment.ReplacesShipmentID.Hex()
 	}
 
-	eventBytes, err := json.Marshal(shippingEvent)
-	if err != nil {
-		log.Printf("❌ Failed to marshal shipping event: %v", err)
+	if err := events.Publish(ctx, w.kafkaProducer, event); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to publish shipment label created event: %v", correlationID, err)
 		return err
 	}
 
-	if err := w.kafkaProducer.Publish(ctx, kafka.TopicShipmentLabelCreated, event.PrescriptionID, eventBytes); err != nil {
-		log.Printf("❌ Failed to publish shipment label created event: %v", err)
+	log.Printf("✅ [correlation_id=%s] Shipping label created for prescription: %s (%s %s, $%.2f, Tracking: %s)", correlationID, prescriptionHex, shipment.Carrier, shipment.ServiceLevel, shipment.Cost, shipment.TrackingNumber)
+	return nil
+}
+
+// coldChain returns the cold chain handling the drug needs, or nil if it ships at room temperature
+func (w *ShippingWorker) coldChain(ctx context.Context, ndc string) (*shipping.ColdChain, error) {
+	if ndc == "" {
+		return nil, nil
+	}
+
+	var drug models.Drug
+	err := w.mongoClient.GetCollection("drugs").FindOne(ctx, bson.M{"ndc": ndc}).Decode(&drug)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up drug %s: %w", ndc, err)
+	}
+
+	minC, maxC, refrigerated := drug.StorageRange()
+	if !refrigerated {
+		return nil, nil
+	}
+	coldChain := shipping.NewColdChain(minC, maxC)
+	return &coldChain, nil
+}
+
+// loadPharmacy loads the dispensing pharmacy
+func (w *ShippingWorker) loadPharmacy(ctx context.Context, pharmacyID string) (*models.Pharmacy, error) {
+	oid, err := primitive.ObjectIDFromHex(pharmacyID)
+	if err != nil {
+		return nil, fmt.Errorf("prescription has no valid pharmacy ID %q", pharmacyID)
+	}
+
+	var pharmacy models.Pharmacy
+	if err := w.mongoClient.GetCollection("pharmacies").FindOne(ctx, bson.M{"_id": oid}).Decode(&pharmacy); err != nil {
+		return nil, fmt.Errorf("failed to load pharmacy %s: %w", pharmacyID, err)
+	}
+	return &pharmacy, nil
+}
+
+// pharmacyShipFrom returns the ship-from address of the dispensing pharmacy
+func pharmacyShipFrom(pharmacy *models.Pharmacy) shipping.Address {
+	return shipping.Address{
+		Company: pharmacy.Name,
+		Street1: pharmacy.Address.Street,
+		City:    pharmacy.Address.City,
+		State:   pharmacy.Address.State,
+		Zip:     pharmacy.Address.Zip,
+		Phone:   pharmacy.Phone,
+		Email:   pharmacy.Email,
+	}
+}
+
+// pharmacyLocation returns the pharmacy's timezone, or server local time if it has none
+func pharmacyLocation(pharmacy *models.Pharmacy) *time.Location {
+	if pharmacy.Timezone != "" {
+		if loc, err := time.LoadLocation(pharmacy.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// patientAddress returns the delivery address on the prescription
+func patientAddress(prescription *models.Prescription) shipping.Address {
+	patient := prescription.Patient
+	return shipping.Address{
+		Name:    strings.TrimSpace(patient.FirstName + " " + patient.LastName),
+		Street1: patient.Address.Street,
+		City:    patient.Address.City,
+		State:   patient.Address.State,
+		Zip:     patient.Address.ZipCode,
+		Phone:   patient.Phone,
+	}
+}
+
+// raiseException moves the prescription to exception for ops instead of shipping it
+func (w *ShippingWorker) raiseException(ctx context.Context, correlationID string, prescriptionID primitive.ObjectID, exceptionType, reason string) error {
+	exception := models.PrescriptionException{
+		Type:     exceptionType,
+		Reason:   reason,
+		Source:   "shipping",
+		RaisedAt: time.Now(),
+	}
+
+	_, err := services.ChangePrescriptionStatus(ctx, w.mongoClient.GetCollection("prescriptions"), correlationID,
+		bson.M{"_id": prescriptionID}, models.StatusException, bson.M{"exception": exception})
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to update prescription status: %v", correlationID, err)
 		return err
 	}
 
-	log.Printf("✅ Shipping label created for prescription: %s (Tracking: %s)", event.PrescriptionID, trackingNumber)
+	if err := events.PublishException(ctx, w.kafkaProducer, correlationID, prescriptionID.Hex(), exception); err != nil {
+		return fmt.Errorf("failed to publish prescription exception event: %w", err)
+	}
+
+	log.Printf("⚠️  [correlation_id=%s] Shipping stopped for prescription %s: %s", correlationID, prescriptionID.Hex(), reason)
 	return nil
 }
@@ -3,12 +3,14 @@ package workers
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"time"
 
 	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
 	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -17,13 +19,17 @@ import (
 type ValidationWorker struct {
 	mongoClient   *database.MongoClient
 	kafkaProducer kafka.Producer
+	formulary     *services.FormularyService
+	dur           *services.DURService
 }
 
 // NewValidationWorker creates a new validation worker
-func NewValidationWorker(mongoClient *database.MongoClient, kafkaProducer kafka.Producer) *ValidationWorker {
+func NewValidationWorker(mongoClient *database.MongoClient, kafkaProducer kafka.Producer, formulary *services.FormularyService, dur *services.DURService) *ValidationWorker {
 	return &ValidationWorker{
 		mongoClient:   mongoClient,
 		kafkaProducer: kafkaProducer,
+		formulary:     formulary,
+		dur:           dur,
 	}
 }
 
@@ -35,22 +41,14 @@ func (w *ValidationWorker) Topic() string {
 // Handle processes a prescription intake event and validates it
 // 8.3.1: Consumes Kafka event (handled by worker loop)
 // 8.3.2: Processes business logic (validation)
-// 8.3.3: Emits next Kafka event (validation.completed)
+// 8.3.3: Emits next Kafka event (validation.completed, or dur_review.requested for a pharmacist)
 func (w *ValidationWorker) Handle(ctx context.Context, msg *kafka.Message) error {
 	// Extract correlation ID from message
 	correlationID := ExtractCorrelationID(msg)
 
 	// Parse the event payload
-	var event struct {
-		EventID        string    `json:"event_id"`
-		CorrelationID  string    `json:"correlation_id,omitempty"`
-		PrescriptionID string    `json:"prescription_id"`
-		PatientID      string    `json:"patient_id,omitempty"`
-		DrugNDC        string    `json:"drug_ndc,omitempty"`
-		Timestamp      time.Time `json:"timestamp"`
-	}
-
-	if err := json.Unmarshal(msg.Value, &event); err != nil {
+	var event events.IntakeReceived
+	if err := events.Decode(msg.Value, &event); err != nil {
 		log.Printf("❌ [correlation_id=%s] Failed to unmarshal validation event: %v", correlationID, err)
 		return err
 	}
@@ -66,14 +64,20 @@ func (w *ValidationWorker) Handle(ctx context.Context, msg *kafka.Message) error
 	prescriptionCollection := w.mongoClient.GetCollection("prescriptions")
 	prescriptionID, err := primitive.ObjectIDFromHex(event.PrescriptionID)
 	if err != nil {
-		log.Printf("❌ Invalid prescription ID format: %s", event.PrescriptionID)
+		log.Printf("❌ [correlation_id=%s] Invalid prescription ID format: %s", correlationID, event.PrescriptionID)
 		return err
 	}
 
+	result := prescriptionCollection.FindOne(ctx, bson.M{"_id": prescriptionID})
 	var prescription bson.M
-	err = prescriptionCollection.FindOne(ctx, bson.M{"_id": prescriptionID}).Decode(&prescription)
-	if err != nil {
-		log.Printf("❌ Prescription not found: %s", event.PrescriptionID)
+	if err := result.Decode(&prescription); err != nil {
+		log.Printf("❌ [correlation_id=%s] Prescription not found: %s", correlationID, event.PrescriptionID)
+		return err
+	}
+
+	var rx models.Prescription
+	if err := result.Decode(&rx); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to decode prescription %s: %v", correlationID, event.PrescriptionID, err)
 		return err
 	}
 
@@ -97,29 +101,70 @@ func (w *ValidationWorker) Handle(ctx context.Context, msg *kafka.Message) error
 		isValid = false
 	}
 
-	// Update prescription status in MongoDB
-	update := bson.M{
-		"$set": bson.M{
-			"status":            getValidationStatus(isValid),
-			"validation_errors": validationErrors,
-			"updated_at":        time.Now(),
-		},
+	// Flag non-formulary drugs, PA requirements and quantity limits before adjudication
+	// Flags are warnings: they do not fail validation
+	formularyCheck, validationFlags, err := w.checkFormulary(ctx, &rx)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to check formulary: %v", correlationID, err)
+		return err
+	}
+	if len(validationFlags) > 0 {
+		log.Printf("🚩 [correlation_id=%s] Formulary flags for prescription %s: %v", correlationID, event.PrescriptionID, validationFlags)
 	}
 
-	_, err = prescriptionCollection.UpdateOne(ctx, bson.M{"_id": prescriptionID}, update)
+	// Check the drug against the patient's active prescriptions and allergies
+	// Findings hold a valid prescription for a pharmacist's review instead of failing it
+	durCheck, err := w.dur.Check(ctx, &rx, time.Now())
 	if err != nil {
-		log.Printf("❌ Failed to update prescription status: %v", err)
+		log.Printf("❌ [correlation_id=%s] Failed to run drug utilization review: %v", correlationID, err)
+		return err
+	}
+	status := getValidationStatus(isValid)
+	var review *models.DURReview
+	if isValid && durCheck.Status == models.DURStatusFlagged {
+		validationFlags = append(validationFlags, services.DURValidationFlags(durCheck)...)
+		review, _, err = w.dur.OpenReview(ctx, &rx, durCheck)
+		if err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to open DUR review: %v", correlationID, err)
+			return err
+		}
+		durCheck.ReviewID = review.ID.Hex()
+		status = models.StatusAwaitingDURReview
+		log.Printf("🚩 [correlation_id=%s] DUR flagged prescription %s (%s), review %s", correlationID, event.PrescriptionID, durCheck.Severity, durCheck.ReviewID)
+	}
+
+	// Update prescription status in MongoDB
+	fields := bson.M{
+		"validation_errors": validationErrors,
+		"validation_flags":  validationFlags,
+		"dur_check":         durCheck,
+	}
+	if formularyCheck != nil {
+		fields["formulary_check"] = formularyCheck
+	}
+	_, err = services.ChangePrescriptionStatus(ctx, prescriptionCollection, correlationID, bson.M{"_id": prescriptionID}, status, fields)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to update prescription status: %v", correlationID, err)
 		return err
 	}
 
 	// 8.3.3: Emit next Kafka event if validation passed
-	if isValid {
-		validationEvent := CreateEvent(correlationID, event.PrescriptionID, map[string]interface{}{
-			"patient_id":   event.PatientID,
-			"validated_at": time.Now().Format(time.RFC3339),
-		})
+	if review != nil {
+		if err := PublishDURReviewRequested(ctx, w.kafkaProducer, correlationID, review); err != nil {
+			log.Printf("❌ [correlation_id=%s] Failed to publish DUR review requested event: %v", correlationID, err)
+			return err
+		}
 
-		if err := PublishEvent(ctx, w.kafkaProducer, kafka.TopicValidationCompleted, event.PrescriptionID, validationEvent); err != nil {
+		log.Printf("⏸️  [correlation_id=%s] Prescription %s awaiting pharmacist DUR review", correlationID, event.PrescriptionID)
+	} else if isValid {
+		validationEvent := &events.ValidationCompleted{
+			Envelope:        events.NewEnvelope(ctx, correlationID, event.PrescriptionID),
+			PatientID:       event.Patient.ID,
+			ValidatedAt:     time.Now(),
+			ValidationFlags: validationFlags,
+		}
+
+		if err := events.Publish(ctx, w.kafkaProducer, validationEvent); err != nil {
 			log.Printf("❌ [correlation_id=%s] Failed to publish validation completed event: %v", correlationID, err)
 			return err
 		}
@@ -132,10 +177,39 @@ func (w *ValidationWorker) Handle(ctx context.Context, msg *kafka.Message) error
 	return nil
 }
 
+// checkFormulary evaluates the prescribed drug against the patient's plan formulary
+// Returns nil when the prescription has no insurance or drug to check
+func (w *ValidationWorker) checkFormulary(ctx context.Context, rx *models.Prescription) (*models.FormularyCheck, []string, error) {
+	plan := rx.Insurance.Plan()
+	if plan.BIN == "" || rx.Medication.NDC == "" {
+		return nil, []string{}, nil
+	}
+
+	now := time.Now()
+
+	// Without formulary data for the plan we cannot say the drug is non-formulary
+	loaded, err := w.formulary.HasPlan(ctx, plan)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !loaded {
+		return &models.FormularyCheck{Status: models.FormularyStatusUnknown, CheckedAt: now}, []string{}, nil
+	}
+
+	check, flags, err := w.formulary.Check(ctx, plan, rx.Medication, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if flags == nil {
+		flags = []string{}
+	}
+	return &check, flags, nil
+}
+
 // getValidationStatus returns the status based on validation result
-func getValidationStatus(isValid bool) string {
+func getValidationStatus(isValid bool) models.PrescriptionStatus {
 	if isValid {
-		return "validated"
+		return models.StatusValidated
 	}
-	return "validation_failed"
+	return models.StatusValidationFailed
 }
@@ -0,0 +1,87 @@
+// Package workers provides worker handlers for processing Kafka events
+package workers
+
+import (
+	"context"
+	"log"
+
+	"github.com/phil-my-meds/backend-gogit/internal/database"
+	"github.com/phil-my-meds/backend-gogit/internal/events"
+	"github.com/phil-my-meds/backend-gogit/internal/kafka"
+	"github.com/phil-my-meds/backend-gogit/internal/models"
+	"github.com/phil-my-meds/backend-gogit/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// VerificationWorker queues paid prescriptions for a pharmacist's clinical verification
+// Nothing is dispensed until a licensed pharmacist approves it; the approval publishes
+// prescription.verification.completed, which the shipping worker consumes.
+type VerificationWorker struct {
+	mongoClient   *database.MongoClient
+	verifications *services.VerificationService
+}
+
+// NewVerificationWorker creates a new verification worker
+func NewVerificationWorker(mongoClient *database.MongoClient, verifications *services.VerificationService) *VerificationWorker {
+	return &VerificationWorker{
+		mongoClient:   mongoClient,
+		verifications: verifications,
+	}
+}
+
+// Topic returns the Kafka topic this handler consumes from
+func (w *VerificationWorker) Topic() string {
+	return kafka.TopicPaymentCompleted
+}
+
+// Handle processes a payment completed event, opening the prescription's verification and
+// moving it to pending_verification
+func (w *VerificationWorker) Handle(ctx context.Context, msg *kafka.Message) error {
+	var event events.PaymentCompleted
+	if err := events.Decode(msg.Value, &event); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to unmarshal verification event: %v", ExtractCorrelationID(msg), err)
+		return err
+	}
+
+	correlationID := event.CorrelationID
+	if correlationID == "" {
+		correlationID = ExtractCorrelationID(msg)
+	}
+
+	prescriptionID, err := primitive.ObjectIDFromHex(event.PrescriptionID)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Invalid prescription ID format: %s", correlationID, event.PrescriptionID)
+		return err
+	}
+
+	collection := w.mongoClient.GetCollection("prescriptions")
+	var rx models.Prescription
+	if err := collection.FindOne(ctx, bson.M{"_id": prescriptionID}).Decode(&rx); err != nil {
+		log.Printf("❌ [correlation_id=%s] Prescription not found: %s", correlationID, event.PrescriptionID)
+		return err
+	}
+	switch rx.Status {
+	case models.StatusPaid, models.StatusPaymentWaived, models.StatusPendingVerification:
+	default:
+		log.Printf("ℹ️  [correlation_id=%s] Prescription %s is %s, not queued for verification", correlationID, event.PrescriptionID, rx.Status)
+		return nil
+	}
+
+	verification, opened, err := w.verifications.Open(ctx, &rx, event.PaymentID)
+	if err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to open verification: %v", correlationID, err)
+		return err
+	}
+
+	filter := bson.M{"_id": prescriptionID, "status": bson.M{"$in": []models.PrescriptionStatus{models.StatusPaid, models.StatusPaymentWaived}}}
+	if _, err := services.ChangePrescriptionStatus(ctx, collection, correlationID, filter, models.StatusPendingVerification, nil); err != nil {
+		log.Printf("❌ [correlation_id=%s] Failed to update prescription status: %v", correlationID, err)
+		return err
+	}
+
+	if opened {
+		log.Printf("🩺 [correlation_id=%s] Prescription %s queued for pharmacist verification %s", correlationID, event.PrescriptionID, verification.ID.Hex())
+	}
+	return nil
+}
@@ -68,6 +68,7 @@ type MedicationXML struct {
 	Name       string `xml:"Name"`
 	Quantity   int    `xml:"Quantity"`
 	Refills    int    `xml:"Refills,omitempty"`
+	DaysSupply int    `xml:"DaysSupply,omitempty"`
 	Dosage     string `xml:"Dosage,omitempty"`
 	Directions string `xml:"Directions,omitempty"`
 }
@@ -151,6 +152,7 @@ func ParseXML(xmlData string) (*models.Prescription, error) {
 		Name:       script.Body.Prescription.Medication.Name,
 		Quantity:   script.Body.Prescription.Medication.Quantity,
 		Refills:    script.Body.Prescription.Medication.Refills,
+		DaysSupply: script.Body.Prescription.Medication.DaysSupply,
 		Dosage:     script.Body.Prescription.Medication.Dosage,
 		Directions: script.Body.Prescription.Medication.Directions,
 	}
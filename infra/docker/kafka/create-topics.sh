@@ -22,12 +22,19 @@ echo "✅ Kafka is ready!"
 TOPICS=(
     "prescription.intake.received"
     "prescription.validation.completed"
+    "prescription.dur_review.requested"
     "patient.enrollment.completed"
+    "patient.enrollment.link.created"
     "pharmacy.selected"
     "insurance.adjudication.completed"
+    "prescription.exception"
+    "prior_authorization.updated"
     "payment.link.created"
     "payment.completed"
+    "prescription.verification.completed"
+    "payment.refunded"
     "shipment.label.created"
+    "shipment.temperature_excursion"
     "shipment.delivered"
     "dead_letter_queue"
 )